@@ -0,0 +1,17 @@
+DROP TABLE IF EXISTS `ExchangeQuote`;
+
+DROP TABLE IF EXISTS `BalanceDiscrepancy`;
+
+DROP TABLE IF EXISTS `BalanceSnapshot`;
+
+DROP TABLE IF EXISTS `ScheduleRun`;
+
+DROP TABLE IF EXISTS `PaymentSchedule`;
+
+DROP TABLE IF EXISTS `BonusGrant`;
+
+DROP TABLE IF EXISTS `TableLock`;
+
+DROP TABLE IF EXISTS `Operation`;
+
+DROP TABLE IF EXISTS `User`
\ No newline at end of file
@@ -0,0 +1,139 @@
+CREATE TABLE IF NOT EXISTS `User`
+(
+    user_id    BIGINT PRIMARY KEY,
+    user_name  TEXT NOT NULL,
+    balance    DECIMAL(19, 4),
+    created_at DATETIME(6)
+);
+
+CREATE TABLE IF NOT EXISTS `Operation`
+(
+    operation_id      BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id           BIGINT,
+    comment           TEXT,
+    amount            DECIMAL(19, 4),
+    date              DATETIME(6),
+    idempotency_token VARCHAR(255),
+    original_amount   DECIMAL(38, 18),
+    original_currency VARCHAR(10),
+    converted_amount  DECIMAL(19, 4),
+    rate              DECIMAL(38, 18),
+    rates_date        VARCHAR(10),
+    bonus_amount      DECIMAL(19, 4),
+    FOREIGN KEY (user_id) REFERENCES `User` (user_id),
+    INDEX (idempotency_token)
+);
+
+CREATE TABLE IF NOT EXISTS `TableLock`
+(
+    table_name VARCHAR(64) PRIMARY KEY
+);
+
+INSERT IGNORE INTO `TableLock` (table_name)
+VALUES ('User'),
+       ('Operation');
+
+CREATE TABLE IF NOT EXISTS `PaymentSchedule`
+(
+    schedule_id            BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id                BIGINT         NOT NULL,
+    receiver_id            BIGINT,
+    operation_type         VARCHAR(32)    NOT NULL,
+    amount                 DECIMAL(19, 4) NOT NULL,
+    purpose                TEXT           NOT NULL,
+    schedule_type          VARCHAR(32)    NOT NULL,
+    interval_seconds       BIGINT         NOT NULL DEFAULT 0,
+    cron_expression        VARCHAR(255)   NOT NULL DEFAULT '',
+    next_run_at            DATETIME(6)    NOT NULL,
+    next_attempt_at        DATETIME(6)    NOT NULL,
+    attempt                INT            NOT NULL DEFAULT 0,
+    max_attempts           INT            NOT NULL DEFAULT 1,
+    retry_interval_seconds BIGINT         NOT NULL DEFAULT 60,
+    active                 BOOLEAN        NOT NULL DEFAULT true,
+    created_at             DATETIME(6)    NOT NULL,
+    idempotency_token      VARCHAR(255)   NOT NULL UNIQUE,
+    FOREIGN KEY (user_id) REFERENCES `User` (user_id),
+    FOREIGN KEY (receiver_id) REFERENCES `User` (user_id),
+    INDEX (active, next_attempt_at),
+    INDEX (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS `ScheduleRun`
+(
+    run_id            BIGINT AUTO_INCREMENT PRIMARY KEY,
+    schedule_id       BIGINT       NOT NULL,
+    scheduled_at      DATETIME(6)  NOT NULL,
+    attempt           INT          NOT NULL,
+    started_at        DATETIME(6)  NOT NULL,
+    finished_at       DATETIME(6)  NOT NULL,
+    status            VARCHAR(32)  NOT NULL,
+    error             TEXT         NOT NULL,
+    idempotency_token VARCHAR(255) NOT NULL,
+    FOREIGN KEY (schedule_id) REFERENCES `PaymentSchedule` (schedule_id)
+);
+
+CREATE TABLE IF NOT EXISTS `BalanceSnapshot`
+(
+    user_id       BIGINT         NOT NULL,
+    snapshot_date DATE           NOT NULL,
+    balance       DECIMAL(19, 4) NOT NULL,
+    created_at    DATETIME(6)    NOT NULL,
+    PRIMARY KEY (user_id, snapshot_date),
+    FOREIGN KEY (user_id) REFERENCES `User` (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS `BalanceDiscrepancy`
+(
+    discrepancy_id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    run_started_at DATETIME(6)    NOT NULL,
+    user_id        BIGINT         NOT NULL,
+    balance        DECIMAL(19, 4) NOT NULL,
+    operations_sum DECIMAL(19, 4) NOT NULL,
+    delta          DECIMAL(19, 4) NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES `User` (user_id),
+    INDEX (run_started_at)
+)
+;
+
+CREATE TABLE IF NOT EXISTS `ExchangeQuote`
+(
+    quote_id          VARCHAR(36) PRIMARY KEY,
+    from_currency     VARCHAR(10)     NOT NULL,
+    to_currency       VARCHAR(10)     NOT NULL,
+    amount            DECIMAL(38, 18) NOT NULL,
+    rate              DECIMAL(38, 18) NOT NULL,
+    converted_amount  DECIMAL(38, 18) NOT NULL,
+    rates_date        VARCHAR(10)     NOT NULL,
+    created_at        DATETIME(6)     NOT NULL,
+    expires_at        DATETIME(6)     NOT NULL,
+    used_at           DATETIME(6),
+    idempotency_token VARCHAR(255),
+    INDEX (idempotency_token)
+)
+;
+
+CREATE TABLE IF NOT EXISTS `BonusGrant`
+(
+    grant_id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id           BIGINT         NOT NULL,
+    amount            DECIMAL(19, 4) NOT NULL,
+    remaining         DECIMAL(19, 4) NOT NULL,
+    granted_at        DATETIME(6)    NOT NULL,
+    expires_at        DATETIME(6)    NOT NULL,
+    idempotency_token VARCHAR(255)   NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES `User` (user_id),
+    INDEX (user_id, expires_at),
+    INDEX (expires_at)
+)
+;
+
+INSERT INTO `User` (user_id, user_name, balance, created_at)
+VALUES (1, 'Mr. Smith', 0, '2020-08-11 07:23:58'),
+       (2, 'Mr. Jones', 10, '2020-08-11 07:23:58');
+
+INSERT INTO `Operation` (user_id, comment, amount, date, idempotency_token)
+VALUES (1, 'incoming payment', 10, '2020-08-11 07:23:58', '1'),
+       (2, 'incoming payment', 10, '2020-08-11 07:23:59', '2'),
+       (1, 'transfer to Mr. Jones', -10, '2020-08-11 07:24:00', '3'),
+       (2, 'transfer from Mr. Smith', 10, '2020-08-11 07:24:01', '4'),
+       (2, 'payment to advertisement service', -10, '2020-08-11 07:24:02', '5')
@@ -13,6 +13,111 @@ Create table if not exists "Operation"
     comment         text,
     amount          DECIMAL(19, 4),
     date            timestamptz,
-    idempotency_token text
+    idempotency_token text,
+    original_amount   DECIMAL(38, 18),
+    original_currency varchar(10),
+    converted_amount  DECIMAL(19, 4),
+    rate              DECIMAL(38, 18),
+    rates_date        varchar(10),
+    bonus_amount      DECIMAL(19, 4)
 );
 CREATE  INDEX ON "Operation" (idempotency_token) WHERE idempotency_token IS NOT NULL;
+
+Create table if not exists "PaymentSchedule"
+(
+    schedule_id            serial primary key,
+    user_id                bigint NOT NULL references "User" (user_id),
+    receiver_id            bigint references "User" (user_id),
+    operation_type         text NOT NULL,
+    amount                 DECIMAL(19, 4) NOT NULL,
+    purpose                text NOT NULL DEFAULT '',
+    schedule_type          text NOT NULL,
+    interval_seconds       bigint NOT NULL DEFAULT 0,
+    cron_expression        text NOT NULL DEFAULT '',
+    next_run_at            timestamptz NOT NULL,
+    next_attempt_at        timestamptz NOT NULL,
+    attempt                int NOT NULL DEFAULT 0,
+    max_attempts           int NOT NULL DEFAULT 1,
+    retry_interval_seconds bigint NOT NULL DEFAULT 60,
+    active                 boolean NOT NULL DEFAULT true,
+    created_at             timestamptz NOT NULL,
+    idempotency_token      text NOT NULL UNIQUE
+);
+CREATE  INDEX ON "PaymentSchedule" (next_attempt_at) WHERE active;
+CREATE  INDEX ON "PaymentSchedule" (user_id);
+
+Create table if not exists "ScheduleRun"
+(
+    run_id            serial primary key,
+    schedule_id       int NOT NULL references "PaymentSchedule" (schedule_id),
+    scheduled_at      timestamptz NOT NULL,
+    attempt           int NOT NULL,
+    started_at        timestamptz NOT NULL,
+    finished_at       timestamptz NOT NULL,
+    status            text NOT NULL,
+    error             text NOT NULL DEFAULT '',
+    idempotency_token text NOT NULL
+);
+CREATE  INDEX ON "ScheduleRun" (schedule_id);
+
+Create table if not exists "BalanceSnapshot"
+(
+    user_id       bigint NOT NULL references "User" (user_id),
+    snapshot_date date NOT NULL,
+    balance       DECIMAL(19, 4) NOT NULL,
+    created_at    timestamptz NOT NULL,
+    primary key (user_id, snapshot_date)
+);
+
+Create table if not exists "BalanceDiscrepancy"
+(
+    discrepancy_id serial primary key,
+    run_started_at timestamptz NOT NULL,
+    user_id        bigint NOT NULL references "User" (user_id),
+    balance        DECIMAL(19, 4) NOT NULL,
+    operations_sum DECIMAL(19, 4) NOT NULL,
+    delta          DECIMAL(19, 4) NOT NULL
+);
+
+CREATE  INDEX ON "BalanceDiscrepancy" (run_started_at);
+
+Create table if not exists "IdempotencyKey"
+(
+    idempotency_key text primary key,
+    endpoint        text        NOT NULL,
+    state           text        NOT NULL,
+    expires_at      timestamptz NOT NULL
+);
+
+CREATE  INDEX ON "IdempotencyKey" (expires_at);
+
+Create table if not exists "ExchangeQuote"
+(
+    quote_id          varchar(36) primary key,
+    from_currency     varchar(10)     NOT NULL,
+    to_currency       varchar(10)     NOT NULL,
+    amount            DECIMAL(38, 18) NOT NULL,
+    rate              DECIMAL(38, 18) NOT NULL,
+    converted_amount  DECIMAL(38, 18) NOT NULL,
+    rates_date        varchar(10)     NOT NULL,
+    created_at        timestamptz     NOT NULL,
+    expires_at        timestamptz     NOT NULL,
+    used_at           timestamptz,
+    idempotency_token text
+);
+
+CREATE  INDEX ON "ExchangeQuote" (idempotency_token);
+
+Create table if not exists "BonusGrant"
+(
+    grant_id          serial primary key,
+    user_id           bigint         NOT NULL references "User" (user_id),
+    amount            DECIMAL(19, 4) NOT NULL,
+    remaining         DECIMAL(19, 4) NOT NULL,
+    granted_at        timestamptz    NOT NULL,
+    expires_at        timestamptz    NOT NULL,
+    idempotency_token text           NOT NULL
+);
+
+CREATE  INDEX ON "BonusGrant" (user_id, expires_at) WHERE remaining > 0;
+CREATE  INDEX ON "BonusGrant" (expires_at) WHERE remaining > 0;
@@ -1 +1,2 @@
-swagger generate spec /o swagger.json /m
\ No newline at end of file
+swagger generate spec /o swagger.json /m --exclude-tag=v2
+swagger generate spec /b ./v2 /o ./v2/swagger.json /m --include-tag=v2
\ No newline at end of file
@@ -0,0 +1,285 @@
+// Job-trainee-assignment API v2.
+//
+// Resource-oriented api v2, user identifier is passed in request path.
+//
+//     Schemes: http
+//     BasePath: /
+//     Version: 2.0
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//
+// swagger:meta
+package v2
+
+import (
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/http_app_handler"
+	"job-backend-trainee-assignment/internal/scheduler"
+)
+
+//
+// Response body wrappers for swagger docs
+//
+
+//swagger:model ProblemTypesResponseBody
+//ProblemTypesResponseBody represents a response body with catalogue of problem types
+type ProblemTypesResponseBody struct {
+	//in: body
+	ProblemTypesResponseBody []http_app_handler.ProblemTypeDescription `json:"result"`
+}
+
+//swagger:model ProblemTypeResponseBody
+//ProblemTypeResponseBody represents a response body with description of problem type
+type ProblemTypeResponseBody struct {
+	//in: body
+	ProblemTypeResponseBody http_app_handler.ProblemTypeDescription `json:"result"`
+}
+
+//swagger:model EffectiveConfigResponseBody
+//EffectiveConfigResponseBody represents a response body with active config sections, secrets are masked
+type EffectiveConfigResponseBody struct {
+	//in: body
+	EffectiveConfigResponseBody map[string]interface{} `json:"result"`
+}
+
+//swagger:model HealthResponseBody
+//HealthResponseBody represents a response body with states of circuit breakers
+type HealthResponseBody struct {
+	//in: body
+	HealthResponseBody http_app_handler.HealthStatus `json:"result"`
+}
+
+//swagger:model CurrenciesResponseBody
+//CurrenciesResponseBody represents a response body with currencies, supported by exchange rates service
+type CurrenciesResponseBody struct {
+	//in: body
+	CurrenciesResponseBody http_app_handler.CurrenciesCatalogue `json:"result"`
+}
+
+//swagger:model RatesResponseBody
+//RatesResponseBody represents a response body with current rates against base currency
+type RatesResponseBody struct {
+	//in: body
+	RatesResponseBody http_app_handler.RatesList `json:"result"`
+}
+
+//swagger:model QuoteResponseBody
+//QuoteResponseBody represents a response body with exchange rate quote
+type QuoteResponseBody struct {
+	//in: body
+	QuoteResponseBody app.Quote `json:"result"`
+}
+
+//swagger:model BonusGrantResponseBody
+//BonusGrantResponseBody represents a response body with result of bonus grant
+type BonusGrantResponseBody struct {
+	//in: body
+	BonusGrantResponseBody app.ResultState `json:"result"`
+}
+
+//swagger:model BonusBalanceResponseBody
+//BonusBalanceResponseBody represents a response body with bonus balance of user
+type BonusBalanceResponseBody struct {
+	//in: body
+	BonusBalanceResponseBody app.BonusBalance `json:"result"`
+}
+
+//swagger:model ScheduleResponseBody
+//ScheduleResponseBody represents a response body with payment schedule
+type ScheduleResponseBody struct {
+	//in: body
+	ScheduleResponseBody scheduler.Schedule `json:"result"`
+}
+
+//swagger:model SchedulesResponseBody
+//SchedulesResponseBody represents a response body with list of payment schedules
+type SchedulesResponseBody struct {
+	//in: body
+	SchedulesResponseBody []scheduler.Schedule `json:"result"`
+}
+
+//swagger:model ScheduleRunsResponseBody
+//ScheduleRunsResponseBody represents a response body with run history of payment schedule
+type ScheduleRunsResponseBody struct {
+	//in: body
+	ScheduleRunsResponseBody []scheduler.ScheduleRun `json:"result"`
+}
+
+//swagger:model BalanceOnDateResponseBody
+//BalanceOnDateResponseBody represents a response body with balance of user at the end of the day
+type BalanceOnDateResponseBody struct {
+	//in: body
+	BalanceOnDateResponseBody app.BalanceOnDate `json:"result"`
+}
+
+//swagger:model StatementResponseBody
+//StatementResponseBody represents a response body with statement of user account
+type StatementResponseBody struct {
+	//in: body
+	StatementResponseBody app.Statement `json:"result"`
+}
+
+//
+// Request params wrappers for swagger docs
+//
+
+//swagger:parameters V2GetProblemType
+type ProblemCodePathParam struct {
+	//problem type (error) code
+	//in: path
+	//required: true
+	Code string `json:"code"`
+}
+
+//swagger:parameters V2GetUserBalance V2GetUserOperations V2CreditUserAccount V2WithdrawUserAccount V2TransferUserMoney V2CreateUserSchedule V2GetUserSchedules V2GetUserBalanceOnDate V2GetUserStatement V2ExportUserStatement V2GrantUserBonus V2GetUserBonuses
+type UserIdPathParam struct {
+	//identifier of user
+	//in: path
+	//required: true
+	//example: 1
+	Id int64 `json:"id"`
+}
+
+//swagger:parameters V2GetUserBalance
+type BalanceQueryParams struct {
+	//name of currency in which balance value is required, supported currencies are listed at /v2/currencies
+	//in: query
+	//default: RUB
+	Currency string `json:"currency"`
+	//comma separated names of currencies, balance is additionally required in, unknown currency is reported in its item
+	//in: query
+	//example: RUB,USD,EUR
+	Currencies string `json:"currencies"`
+	//consistency token, returned by write operation. Balance is read from replica only if replica has this write
+	//in: query
+	ConsistencyToken string `json:"consistency_token"`
+}
+
+//swagger:parameters V2GetUserOperations
+type OperationLogQueryParams struct {
+	//field name to order operations by
+	//in: query
+	//enum: date,amount
+	//default: date
+	OrderField string `json:"order_field"`
+	//operations order direction
+	//in: query
+	//enum: desc,asc
+	//default: desc
+	OrderDirection string `json:"order_direction"`
+	//desired page of operation log
+	//in: query
+	//default: 1
+	Page int64 `json:"page"`
+	//limit the number of operations per page
+	//in: query
+	//default: -1
+	Limit int64 `json:"limit"`
+	//first day of operations period in format YYYY-MM-DD (UTC)
+	//in: query
+	From string `json:"from"`
+	//last day of operations period in format YYYY-MM-DD (UTC), included in period
+	//in: query
+	To string `json:"to"`
+	//consistency token, returned by write operation. Operations are read from replica only if replica has this write
+	//in: query
+	ConsistencyToken string `json:"consistency_token"`
+}
+
+//swagger:parameters V2CreditUserAccount
+type CreditRequestBody struct {
+	//Represents request to credit certain amount of money to user account
+	//in: body
+	CreditRequestBody http_app_handler.CreditRequestV2
+}
+
+//swagger:parameters V2WithdrawUserAccount
+type WithdrawalRequestBody struct {
+	//Represents request to withdraw certain amount of money from user account
+	//in: body
+	WithdrawalRequestBody http_app_handler.WithdrawalRequestV2
+}
+
+//swagger:parameters V2TransferUserMoney
+type TransferRequestBody struct {
+	//Represents request to transfer certain amount of money to another user
+	//in: body
+	TransferRequestBody http_app_handler.TransferRequestV2
+}
+
+//swagger:parameters V2GrantUserBonus
+type BonusGrantRequestBody struct {
+	//Represents request to grant promotional bonus to user
+	//in: body
+	BonusGrantRequestBody http_app_handler.BonusGrantRequestV2
+}
+
+//swagger:parameters V2CreateUserSchedule
+type ScheduleRequestBody struct {
+	//Represents request to create one-off or recurring payment
+	//in: body
+	ScheduleRequestBody http_app_handler.ScheduleRequestV2
+}
+
+//swagger:parameters V2CreateQuote
+type QuoteRequestBody struct {
+	//Represents request for exchange rate quote
+	//in: body
+	QuoteRequestBody app.QuoteRequest
+}
+
+//swagger:parameters V2GetQuote
+type QuoteIdPathParam struct {
+	//identifier of quote
+	//in: path
+	//required: true
+	//example: 0b1c6f2e-8d7a-4a53-9d1e-3f1f1c5b2a7e
+	Id string `json:"id"`
+}
+
+//swagger:parameters V2GetSchedule V2CancelSchedule V2GetScheduleRuns
+type ScheduleIdPathParam struct {
+	//identifier of payment schedule
+	//in: path
+	//required: true
+	//example: 1
+	Id int64 `json:"id"`
+}
+
+//swagger:parameters V2GetUserBalanceOnDate
+type DatePathParam struct {
+	//day in format YYYY-MM-DD (UTC)
+	//in: path
+	//required: true
+	//example: 2020-08-11
+	Date string `json:"date"`
+}
+
+//swagger:parameters V2GetUserStatement V2ExportUserStatement
+type StatementQueryParams struct {
+	//first day of period in format YYYY-MM-DD (UTC)
+	//in: query
+	//required: true
+	From string `json:"from"`
+	//last day of period in format YYYY-MM-DD (UTC), included in period
+	//in: query
+	//required: true
+	To string `json:"to"`
+}
+
+//swagger:parameters V2ExportUserStatement
+type ExportQueryParams struct {
+	//format of exported file: csv, excel compatible csv or printable html
+	//in: query
+	//enum: csv,excel,html
+	//default: csv
+	Format string `json:"format"`
+	//consistency token, returned by write operation. Statement is read from replica only if replica has this write
+	//in: query
+	ConsistencyToken string `json:"consistency_token"`
+}
@@ -4,7 +4,8 @@ go 1.14
 
 require (
 	github.com/cockroachdb/apd v1.1.0 // indirect
-	github.com/fsnotify/fsnotify v1.4.9 // indirect
+	github.com/fsnotify/fsnotify v1.4.9
+	github.com/go-sql-driver/mysql v1.5.0
 	github.com/gofrs/uuid v3.3.0+incompatible // indirect
 	github.com/gomodule/redigo v1.8.2
 	github.com/jackc/fake v0.0.0-20150926172116-812a484cc733 // indirect
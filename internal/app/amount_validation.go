@@ -0,0 +1,41 @@
+package app
+
+import (
+	"github.com/shopspring/decimal"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"net/http"
+	"strings"
+)
+
+//validateOperationAmount checks amount of operation in base currency: amount must be at least min amount of currency
+//and min monetary unit of config, fit into minor units of currency and into whole digits, stored in database
+func (ba *BillingApp) validateOperationAmount(method string, amount decimal.Decimal) error {
+	cfg := ba.GetConfig()
+	baseCurrency := cfg.currencies().Get(exchanger.RUBCode)
+
+	minAmount := baseCurrency.Min()
+	if cfg.MinOpsMonetaryUnit.GreaterThan(minAmount) {
+		minAmount = cfg.MinOpsMonetaryUnit
+	}
+
+	if amount.LessThan(minAmount) {
+		ba.logger.Error("%s, %s", method, ErrAmountValueIsLessThanMin.Error())
+		return &AppError{WithDetails(ErrAmountValueIsLessThanMin,
+			map[string]interface{}{"min_amount": minAmount.String()}), http.StatusBadRequest}
+	}
+
+	if !baseCurrency.FitsMinorUnits(amount) {
+		ba.logger.Error("%s, %s", method, ErrAmountHasExcessiveFractionalDigits.Error())
+		return &AppError{WithDetails(ErrAmountHasExcessiveFractionalDigits,
+			map[string]interface{}{"max_fractional_digits": baseCurrency.MinorUnits}), http.StatusBadRequest}
+	}
+
+	//check number of digits to the left of decimal point (whole part)
+	wholeDigits := strings.Split(amount.String(), ".")[0]
+	if len(wholeDigits) > cfg.MaxDecimalWholeDigitsNum {
+		ba.logger.Error("%s, %s", method, ErrAmountHasExcessiveWholeDigits.Error())
+		return &AppError{WithDetails(ErrAmountHasExcessiveWholeDigits,
+			map[string]interface{}{"max_whole_digits": cfg.MaxDecimalWholeDigitsNum}), http.StatusBadRequest}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/cache"
+	"job-backend-trainee-assignment/internal/currency"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"job-backend-trainee-assignment/internal/logger"
+	"testing"
+)
+
+func TestBillingApp_ValidatesAmountByCurrency(t *testing.T) {
+	ctx := context.Background()
+
+	newApp := func(t *testing.T, rub currency.Currency) *BillingApp {
+		registry, err := currency.NewRegistry(currency.RoundBank, rub)
+		require.NoError(t, err)
+
+		app, err := NewAppWithStorage(&logger.DummyLogger{}, NewMemoryStorage(), &exchanger.StubExchanger{}, &cache.DummyCacheCommon{},
+			&Config{MinOpsMonetaryUnit: decimal.RequireFromString("0.01"), MaxDecimalWholeDigitsNum: 15,
+				MaxDecimalFracDigitsNum: 2, Currencies: registry})
+		require.NoError(t, err)
+		return app
+	}
+
+	t.Run("currency without minor units", func(t *testing.T) {
+		app := newApp(t, currency.Currency{Code: exchanger.RUBCode, MinorUnits: 0})
+
+		_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "10.5", IdempotencyToken: "1"})
+		require.Error(t, err)
+		details := err.(*AppError).Err.(*ErrorWithDetails)
+		assert.Equal(t, ErrAmountHasExcessiveFractionalDigits, details.Err)
+		assert.Equal(t, int32(0), details.Details["max_fractional_digits"])
+
+		_, err = app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "10", IdempotencyToken: "2"})
+		require.NoError(t, err)
+
+		_, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Amount: "0.5", IdempotencyToken: "3"})
+		require.Error(t, err)
+		details = err.(*AppError).Err.(*ErrorWithDetails)
+		assert.Equal(t, ErrAmountValueIsLessThanMin, details.Err)
+		assert.Equal(t, "1", details.Details["min_amount"], "min amount must be one minor unit")
+	})
+
+	t.Run("min amount of currency", func(t *testing.T) {
+		app := newApp(t, currency.Currency{Code: exchanger.RUBCode, MinorUnits: 2, MinAmount: decimal.NewFromInt(5)})
+
+		_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "4.99", IdempotencyToken: "1"})
+		require.Error(t, err)
+		details := err.(*AppError).Err.(*ErrorWithDetails)
+		assert.Equal(t, ErrAmountValueIsLessThanMin, details.Err)
+		assert.Equal(t, "5", details.Details["min_amount"])
+
+		_, err = app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Name: "sender", Amount: "20", IdempotencyToken: "2"})
+		require.NoError(t, err)
+		_, err = app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 2, Name: "receiver", Amount: "5", IdempotencyToken: "3"})
+		require.NoError(t, err)
+
+		_, err = app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "1",
+			IdempotencyToken: "4"})
+		require.Error(t, err)
+		assert.Equal(t, ErrAmountValueIsLessThanMin, err.(*AppError).Err.(*ErrorWithDetails).Err)
+	})
+
+	t.Run("base currency must fit database", func(t *testing.T) {
+		registry, err := currency.NewRegistry(currency.RoundBank, currency.Currency{Code: exchanger.RUBCode, MinorUnits: 3})
+		require.NoError(t, err)
+
+		cfg := &Config{MinOpsMonetaryUnit: decimal.RequireFromString("0.01"), MaxDecimalWholeDigitsNum: 15,
+			MaxDecimalFracDigitsNum: 2, Currencies: registry}
+		assert.Error(t, cfg.Validate(), "base currency minor units must not exceed fractional digits of database")
+	})
+}
@@ -6,9 +6,14 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/shopspring/decimal"
 	"job-backend-trainee-assignment/internal/cache"
+	"job-backend-trainee-assignment/internal/currency"
+	"job-backend-trainee-assignment/internal/db_connector"
 	"job-backend-trainee-assignment/internal/exchanger"
+	"job-backend-trainee-assignment/internal/fees"
+	"job-backend-trainee-assignment/internal/idempotency"
 	"job-backend-trainee-assignment/internal/logger"
 	"sync"
+	"time"
 )
 
 type IBillingApp interface {
@@ -17,30 +22,96 @@ type IBillingApp interface {
 	TransferMoneyFromUserToUser(ctx context.Context, in *MoneyTransferRequest) (*ResultState, error)
 	GetUserBalance(ctx context.Context, in *BalanceRequest) (*UserBalance, error)
 	GetUserOperations(ctx context.Context, in *OperationLogRequest) (*OperationsLog, error)
+	GetUserInfo(ctx context.Context, in *UserInfoRequest) (*UserInfo, error)
+	GetOperationsByIdempotencyToken(ctx context.Context, in *IdempotencyLookupRequest) ([]Operation, error)
+	GetUserBalanceOnDate(ctx context.Context, in *BalanceOnDateRequest) (*BalanceOnDate, error)
+	GetUserStatement(ctx context.Context, in *StatementRequest) (*Statement, error)
+	StreamUserStatement(ctx context.Context, in *StatementStreamRequest, onOpening func(decimal.Decimal) error,
+		onPage func([]Operation) error) error
+	CreateQuote(ctx context.Context, in *QuoteRequest) (*Quote, error)
+	GetQuote(ctx context.Context, in *QuoteLookupRequest) (*Quote, error)
+	GrantBonus(ctx context.Context, in *BonusGrantRequest) (*ResultState, error)
+	GetUserBonuses(ctx context.Context, in *BonusBalanceRequest) (*BonusBalance, error)
 }
 
 type BillingApp struct {
+	storage IStorage
+	//db is a primary database of postgres storage, it is nil for other storages
 	db        *sqlx.DB
 	logger    logger.ILogger
 	exchanger exchanger.ICurrencyExchanger
 	cfg       *Config
 	cache     cache.ICacher
-	mu        sync.Mutex
+	//staleBalanceUsers keeps users, whose cached balance was not invalidated after operation,
+	//with sequence number of the mark
+	staleBalanceUsers        map[int64]uint64
+	staleBalanceSeq          uint64
+	staleBalanceRetryRunning bool
+	//staleBalanceRetryInterval is an interval of background version increments of stale users
+	staleBalanceRetryInterval time.Duration
+	idempotency               idempotency.IIdempotencyStore
+	//replicas are used for reads of balance and operations log, if they are set
+	replicas IReadReplicas
+	mu       sync.Mutex
 }
 
 type Config struct {
 	MinOpsMonetaryUnit       decimal.Decimal
 	MaxDecimalWholeDigitsNum int
 	MaxDecimalFracDigitsNum  int
+	//Currencies gives precision and min amount of currencies, ISO 4217 registry is used if it is nil
+	Currencies *currency.Registry
+	//QuoteTTL is a time, exchange rate quote may be used by operation, default ttl is used if it is zero
+	QuoteTTL time.Duration
+	//ConversionSpread is a share of converted amount of cross-currency transfer, which is booked to revenue account
+	ConversionSpread decimal.Decimal
+	//RevenueAccountId is an identifier of user account, conversion spreads are credited to
+	RevenueAccountId int64
+	//Fees gives fees of transfers and withdrawals, operations have no fees if it is nil
+	Fees *fees.Schedule
+	//FeesAccountId is an identifier of user account, fees are credited to
+	FeesAccountId int64
+	//BonusWithdrawPolicy is an order of bonus and real balance use by withdrawals, bonus first is used if it is empty
+	BonusWithdrawPolicy string
 }
 
 var (
 	defaultMinOpsMonetaryUnit    = "0.01"
 	defaultDecimalWholeDigitsNum = 15
 	defaultDecimalFracDigitsNum  = 2
+	defaultQuoteTTL              = time.Minute
 )
 
+//NewApp creates app, which keeps users and operations in database of db, storage is chosen by db driver
 func NewApp(logger logger.ILogger, db *sqlx.DB, exchanger exchanger.ICurrencyExchanger, cache cache.ICacher, cfg *Config) (
+	*BillingApp, error) {
+	if db == nil {
+		return nil, fmt.Errorf("must provide non-nil sqlx.DB pointer")
+	}
+
+	if db.DriverName() == db_connector.DriverMySQL {
+		storage, err := NewMySQLStorage(db)
+		if err != nil {
+			return nil, err
+		}
+		return NewAppWithStorage(logger, storage, exchanger, cache, cfg)
+	}
+
+	storage, err := NewPostgresStorage(db)
+	if err != nil {
+		return nil, err
+	}
+
+	ba, err := NewAppWithStorage(logger, storage, exchanger, cache, cfg)
+	if err != nil {
+		return nil, err
+	}
+	ba.db = db
+	return ba, nil
+}
+
+//NewAppWithStorage creates app, which keeps users and operations in given storage
+func NewAppWithStorage(logger logger.ILogger, storage IStorage, exchanger exchanger.ICurrencyExchanger, cache cache.ICacher, cfg *Config) (
 	*BillingApp, error) {
 	if logger == nil {
 		return nil, fmt.Errorf("must provide non-nil logger instance")
@@ -54,8 +125,8 @@ func NewApp(logger logger.ILogger, db *sqlx.DB, exchanger exchanger.ICurrencyExc
 		cfg = &Config{MinOpsMonetaryUnit: defaultMinAmount, MaxDecimalWholeDigitsNum: defaultDecimalWholeDigitsNum, MaxDecimalFracDigitsNum: defaultDecimalFracDigitsNum}
 	}
 
-	if db == nil {
-		return nil, fmt.Errorf("must provide non-nil sqlx.DB pointer")
+	if storage == nil {
+		return nil, fmt.Errorf("must provide non-nil storage instance")
 	}
 
 	if exchanger == nil {
@@ -66,12 +137,108 @@ func NewApp(logger logger.ILogger, db *sqlx.DB, exchanger exchanger.ICurrencyExc
 		return nil, fmt.Errorf("must provide non-nil cache instance")
 	}
 
+	idempotencyStore, err := idempotency.NewMemoryStore(nil)
+	if err != nil {
+		return nil, fmt.Errorf("fail to create default idempotency store, %v", err)
+	}
+
 	return &BillingApp{
-		logger:    logger,
-		db:        db,
-		exchanger: exchanger,
-		cfg:       cfg,
-		cache:     cache,
-		mu:        sync.Mutex{},
+		storage:                   storage,
+		logger:                    logger,
+		exchanger:                 exchanger,
+		cfg:                       cfg,
+		cache:                     cache,
+		staleBalanceUsers:         map[int64]uint64{},
+		staleBalanceRetryInterval: balanceCacheStaleRetryInterval,
+		idempotency:               idempotencyStore,
+		mu:                        sync.Mutex{},
 	}, nil
 }
+
+//GetConfig returns copy of current app config
+func (ba *BillingApp) GetConfig() Config {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+	return *ba.cfg
+}
+
+//Validate checks config, which may be changed on config reload.
+//Min monetary unit must be positive and fit into fractional digits, stored in database
+func (cfg *Config) Validate() error {
+	if !cfg.MinOpsMonetaryUnit.IsPositive() {
+		return fmt.Errorf("min monetary unit must be positive")
+	}
+
+	if !cfg.MinOpsMonetaryUnit.Equal(cfg.MinOpsMonetaryUnit.Truncate(int32(cfg.MaxDecimalFracDigitsNum))) {
+		return fmt.Errorf("min monetary unit must have at most %d fractional digits", cfg.MaxDecimalFracDigitsNum)
+	}
+
+	baseCurrency := cfg.currencies().Get(exchanger.RUBCode)
+	if baseCurrency.MinorUnits > int32(cfg.MaxDecimalFracDigitsNum) {
+		return fmt.Errorf("base currency %s must have at most %d minor units", baseCurrency.Code, cfg.MaxDecimalFracDigitsNum)
+	}
+
+	if cfg.QuoteTTL < 0 {
+		return fmt.Errorf("quote ttl must be non-negative")
+	}
+
+	if cfg.ConversionSpread.IsNegative() || cfg.ConversionSpread.GreaterThanOrEqual(decimal.New(1, 0)) {
+		return fmt.Errorf("conversion spread must be in range [0, 1)")
+	}
+
+	if cfg.ConversionSpread.IsPositive() && cfg.RevenueAccountId <= 0 {
+		return fmt.Errorf("revenue account must be set, if conversion spread is positive")
+	}
+
+	if !cfg.fees().Empty() && cfg.FeesAccountId <= 0 {
+		return fmt.Errorf("fees account must be set, if fee rules are set")
+	}
+
+	switch cfg.bonusWithdrawPolicy() {
+	case BonusPolicyBonusFirst, BonusPolicyRealFirst, BonusPolicyRealOnly:
+	default:
+		return fmt.Errorf("bonus withdraw policy must be %s, %s or %s", BonusPolicyBonusFirst, BonusPolicyRealFirst,
+			BonusPolicyRealOnly)
+	}
+	return nil
+}
+
+//currencies returns registry of config or ISO 4217 registry
+func (cfg *Config) currencies() *currency.Registry {
+	if cfg.Currencies == nil {
+		return currency.DefaultRegistry()
+	}
+	return cfg.Currencies
+}
+
+//fees returns fee schedule of config or schedule without fees
+func (cfg *Config) fees() *fees.Schedule {
+	if cfg.Fees == nil {
+		return noFees
+	}
+	return cfg.Fees
+}
+
+//quoteTTL returns quote ttl of config or default ttl
+func (cfg *Config) quoteTTL() time.Duration {
+	if cfg.QuoteTTL == 0 {
+		return defaultQuoteTTL
+	}
+	return cfg.QuoteTTL
+}
+
+//SetConfig replaces app config, it is used on config reload
+func (ba *BillingApp) SetConfig(cfg *Config) error {
+	if cfg == nil {
+		return fmt.Errorf("must provide non-nil config")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	ba.mu.Lock()
+	ba.cfg = cfg
+	ba.mu.Unlock()
+	return nil
+}
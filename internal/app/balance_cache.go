@@ -0,0 +1,179 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"job-backend-trainee-assignment/internal/cache"
+	"time"
+)
+
+var (
+	//balanceCacheInvalidateTimeout limits version increments, performed after operation commit,
+	//it does not depend on request context, so cancelled request does not leave stale balance in cache
+	balanceCacheInvalidateTimeout = 5 * time.Second
+	//balanceCacheInvalidateAttempts is a number of attempts to increment balance version of one user
+	balanceCacheInvalidateAttempts   = 3
+	balanceCacheInvalidateRetryDelay = 100 * time.Millisecond
+	//balanceCacheStaleRetryInterval is a default interval of background version increments of stale users
+	balanceCacheStaleRetryInterval = time.Second
+)
+
+func balanceVersionKey(userId int64) string {
+	return fmt.Sprintf("balance_version:%d", userId)
+}
+
+func balanceValueKey(userId int64, version int64, currency string) string {
+	return fmt.Sprintf("balance:%d:%d:%s", userId, version, currency)
+}
+
+//getBalanceCacheVersion returns current balance version of user, ok is false if cache must not be used.
+//Version must be read before balance is fetched from database: version incremented by concurrent operation
+//makes value, stored with previous version, unreachable
+func (ba *BillingApp) getBalanceCacheVersion(ctx context.Context, userId int64) (version int64, ok bool) {
+	ba.mu.Lock()
+	staleSeq, isStale := ba.staleBalanceUsers[userId]
+	ba.mu.Unlock()
+
+	if isStale {
+		version, err := ba.cache.IncrVersion(ctx, balanceVersionKey(userId))
+		if err != nil {
+			ba.logger.Error("getBalanceCacheVersion, %s, err %v, user %d", ErrCacheWriteFailed.Error(), err, userId)
+			return 0, false
+		}
+
+		ba.unmarkStaleBalance(userId, staleSeq)
+		return version, true
+	}
+
+	version, err := ba.cache.GetVersion(ctx, balanceVersionKey(userId))
+	if err != nil {
+		ba.logger.Error("getBalanceCacheVersion, %s, err %v, user %d", ErrCacheLookupFailed.Error(), err, userId)
+		return 0, false
+	}
+
+	return version, true
+}
+
+//getCachedBalance returns balance of user in currency, stored with given version
+func (ba *BillingApp) getCachedBalance(ctx context.Context, userId int64, version int64, currency string) (string, bool) {
+	balance, found, err := ba.cache.GetValue(ctx, balanceValueKey(userId, version, currency))
+	if err != nil {
+		ba.logger.Error("getCachedBalance, %s, err %v, user %d", ErrCacheLookupFailed.Error(), err, userId)
+		return "", false
+	}
+
+	return balance, found
+}
+
+//setCachedBalance stores balance of user in currency with given version
+func (ba *BillingApp) setCachedBalance(ctx context.Context, userId int64, version int64, currency string, balance string) {
+	err := ba.cache.SetValue(ctx, balanceValueKey(userId, version, currency), balance)
+	if err != nil {
+		ba.logger.Error("setCachedBalance, %s, err %v, user %d", ErrCacheWriteFailed.Error(), err, userId)
+	}
+}
+
+//invalidateCachedBalances increments balance versions of users, must be called after operation commit
+//and before operation result is returned. Committed operation is acknowledged even if increment failed:
+//the user is marked as stale, this instance does not use cache for the user, and increment is retried
+//in background until it succeeds. Until then other instances may serve balance, cached before operation,
+//for at most key expiration time of cache
+func (ba *BillingApp) invalidateCachedBalances(methodName string, userIds ...int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), balanceCacheInvalidateTimeout)
+	defer cancel()
+
+	for _, userId := range userIds {
+		ba.mu.Lock()
+		staleSeq, isStale := ba.staleBalanceUsers[userId]
+		ba.mu.Unlock()
+
+		err := ba.incrBalanceVersion(ctx, userId)
+		if err != nil {
+			ba.logger.Error("%s, %s, err %v, cached balance of user %d is marked as stale", methodName, ErrCacheWriteFailed.Error(), err, userId)
+			ba.markStaleBalance(userId)
+			continue
+		}
+
+		if isStale {
+			ba.unmarkStaleBalance(userId, staleSeq)
+		}
+	}
+}
+
+//markStaleBalance marks cached balance of user as stale and starts background retry of version increments,
+//if it is not running
+func (ba *BillingApp) markStaleBalance(userId int64) {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+
+	ba.staleBalanceSeq++
+	ba.staleBalanceUsers[userId] = ba.staleBalanceSeq
+	if !ba.staleBalanceRetryRunning {
+		ba.staleBalanceRetryRunning = true
+		go ba.retryStaleBalances(ba.staleBalanceRetryInterval)
+	}
+}
+
+//unmarkStaleBalance removes stale mark of user, if the user was not marked again after staleSeq was read:
+//version increment, which started before the new mark, does not invalidate balance of later operation
+func (ba *BillingApp) unmarkStaleBalance(userId int64, staleSeq uint64) {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+
+	if seq, ok := ba.staleBalanceUsers[userId]; ok && seq == staleSeq {
+		delete(ba.staleBalanceUsers, userId)
+	}
+}
+
+//retryStaleBalances increments balance versions of stale users every interval,
+//it returns when there are no stale users left
+func (ba *BillingApp) retryStaleBalances(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+
+		ba.mu.Lock()
+		staleUsers := make(map[int64]uint64, len(ba.staleBalanceUsers))
+		for userId, seq := range ba.staleBalanceUsers {
+			staleUsers[userId] = seq
+		}
+		ba.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), balanceCacheInvalidateTimeout)
+		for userId, staleSeq := range staleUsers {
+			_, err := ba.cache.IncrVersion(ctx, balanceVersionKey(userId))
+			if err != nil {
+				ba.logger.Error("retryStaleBalances, %s, err %v, user %d", ErrCacheWriteFailed.Error(), err, userId)
+				continue
+			}
+
+			ba.unmarkStaleBalance(userId, staleSeq)
+		}
+		cancel()
+
+		ba.mu.Lock()
+		if len(ba.staleBalanceUsers) == 0 {
+			ba.staleBalanceRetryRunning = false
+			ba.mu.Unlock()
+			return
+		}
+		ba.mu.Unlock()
+	}
+}
+
+//incrBalanceVersion increments balance version of user, making at most balanceCacheInvalidateAttempts attempts
+func (ba *BillingApp) incrBalanceVersion(ctx context.Context, userId int64) (err error) {
+	for attempt := 1; ; attempt++ {
+		_, err = ba.cache.IncrVersion(ctx, balanceVersionKey(userId))
+		//unavailable cache is not retried, increment is retried in background
+		if err == nil || attempt >= balanceCacheInvalidateAttempts || errors.Is(err, cache.ErrCacheIsUnavailable) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(balanceCacheInvalidateRetryDelay):
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// +build integration
+
+package app
+
+import (
+	"context"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/cache"
+	"job-backend-trainee-assignment/internal/db_connector"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"job-backend-trainee-assignment/internal/logger"
+	"job-backend-trainee-assignment/internal/test_helpers"
+	"testing"
+	"time"
+)
+
+func TestBillingApp_BalanceCache_Common(t *testing.T) {
+	v := viper.New()
+
+	v.AddConfigPath(".")
+	v.AddConfigPath("../../")
+	v.SetConfigName("config")
+	v.AutomaticEnv()
+
+	err := v.ReadInConfig()
+	require.NoErrorf(t, err, "failed to read config file at: %s, err %v", "config", err)
+
+	var pgHost string
+	if v.GetString("DATABASE_HOST") != "" {
+		pgHost = v.GetString("DATABASE_HOST")
+	} else {
+		pgHost = v.GetString("db_params.DATABASE_HOST")
+	}
+
+	dbConfig := &db_connector.Config{
+		DriverName:    v.GetString("db_params.driver_name"),
+		DBUser:        v.GetString("db_params.user"),
+		DBPass:        v.GetString("db_params.password"),
+		DBName:        v.GetString("db_params.db_name"),
+		DBPort:        v.GetString("db_params.port"),
+		DBHost:        pgHost,
+		SSLMode:       v.GetString("db_params.ssl_mode"),
+		RetryInterval: v.GetDuration("db_params.conn_retry_interval") * time.Second,
+	}
+
+	dbConnTimeout := v.GetDuration("db_params.conn_timeout") * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), dbConnTimeout)
+	defer cancel()
+	dummyLogger := &logger.DummyLogger{}
+
+	db, dbCloseFunc, err := db_connector.DBConnectWithTimeout(ctx, dbConfig, dummyLogger)
+	require.NoErrorf(t, err, "failed to connect to db,err %v", err)
+
+	defer dbCloseFunc()
+
+	ex := &exchanger.StubExchanger{}
+	caseTimeout := v.GetDuration("testing_params.test_case_timeout") * time.Second
+
+	prepareDB := func(ctx context.Context, t *testing.T) {
+		err := test_helpers.PrepareDB(ctx, db, test_helpers.Config{
+			InitFilePath:    filePathPrefix + v.GetString("testing_params.db_init_file_path"),
+			CleanUpFilePath: filePathPrefix + v.GetString("testing_params.db_cleanup_file_path"),
+		})
+		require.NoError(t, err, "PrepareDB must not return error")
+	}
+
+	t.Run("balance is served from cache and invalidated by operations", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		memCache := cache.NewStubCacheInMemory()
+		app, err := NewApp(dummyLogger, db, ex, memCache, nil)
+		require.NoErrorf(t, err, "failed to create BillingApp instance, err %v", err)
+
+		res, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 2})
+		require.NoError(t, err)
+		assert.Equal(t, "10", res.Balance)
+
+		cached, found, _ := memCache.GetValue(ctx, balanceValueKey(2, 0, "RUB"))
+		assert.True(t, found, "balance must be stored in cache")
+		assert.Equal(t, "10", cached, "cached balance must match")
+
+		_, err = app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 2, Amount: "5", IdempotencyToken: uuid.NewV4().String()})
+		require.NoError(t, err)
+
+		res, err = app.GetUserBalance(ctx, &BalanceRequest{UserId: 2})
+		require.NoError(t, err)
+		assert.Equal(t, "15", res.Balance, "balance after credit must not be stale")
+
+		_, err = app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 2, ReceiverId: 1, Amount: "3", IdempotencyToken: uuid.NewV4().String()})
+		require.NoError(t, err)
+
+		res, err = app.GetUserBalance(ctx, &BalanceRequest{UserId: 2})
+		require.NoError(t, err)
+		assert.Equal(t, "12", res.Balance, "sender balance after transfer must not be stale")
+
+		res, err = app.GetUserBalance(ctx, &BalanceRequest{UserId: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "3", res.Balance, "receiver balance after transfer must not be stale")
+
+		_, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Amount: "1", IdempotencyToken: uuid.NewV4().String()})
+		require.NoError(t, err)
+
+		res, err = app.GetUserBalance(ctx, &BalanceRequest{UserId: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "2", res.Balance, "balance after withdraw must not be stale")
+	})
+
+	t.Run("balance is not served from cache if invalidation failed", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		faultyCache := &stubCacheFaultyIncr{StubCacheInMemory: cache.NewStubCacheInMemory()}
+		app, err := NewApp(dummyLogger, db, ex, faultyCache, nil)
+		require.NoErrorf(t, err, "failed to create BillingApp instance, err %v", err)
+		//background retry must not invalidate cache before balance is read
+		app.staleBalanceRetryInterval = time.Hour
+
+		res, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 2})
+		require.NoError(t, err)
+		assert.Equal(t, "10", res.Balance)
+
+		faultyCache.setFailIncrs(-1)
+		token := uuid.NewV4().String()
+		result, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 2, Amount: "5", IdempotencyToken: token})
+		require.NoError(t, err, "committed operation must be acknowledged, if cache is not invalidated")
+		assert.Equal(t, MsgAccountCreditingDone, result.State)
+
+		res, err = app.GetUserBalance(ctx, &BalanceRequest{UserId: 2})
+		require.NoError(t, err)
+		assert.Equal(t, "15", res.Balance, "balance must be fetched from database")
+
+		faultyCache.setFailIncrs(0)
+		res, err = app.GetUserBalance(ctx, &BalanceRequest{UserId: 2})
+		require.NoError(t, err)
+		assert.Equal(t, "15", res.Balance, "balance must be fetched from database")
+
+		version, err := faultyCache.GetVersion(ctx, balanceVersionKey(2))
+		require.NoError(t, err)
+		cached, found, _ := faultyCache.GetValue(ctx, balanceValueKey(2, version, "RUB"))
+		assert.True(t, found, "balance must be stored in cache with incremented version")
+		assert.Equal(t, "15", cached, "cached balance must match")
+	})
+}
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/breaker"
+	"job-backend-trainee-assignment/internal/cache"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"job-backend-trainee-assignment/internal/logger"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBillingApp_BalanceCacheInvalidation(t *testing.T) {
+	ctx := context.Background()
+
+	//two instances of service share storage and cache
+	newInstances := func(t *testing.T, c cache.ICacher) (*BillingApp, *BillingApp) {
+		storage := NewMemoryStorage()
+		first, err := NewAppWithStorage(&logger.DummyLogger{}, storage, &exchanger.StubExchanger{}, c, nil)
+		require.NoError(t, err)
+		second, err := NewAppWithStorage(&logger.DummyLogger{}, storage, &exchanger.StubExchanger{}, c, nil)
+		require.NoError(t, err)
+		return first, second
+	}
+
+	balanceOf := func(t *testing.T, app *BillingApp, userId int64) string {
+		balance, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: userId})
+		require.NoError(t, err)
+		return balance.Balance
+	}
+
+	t.Run("positive path, failed version increment is retried", func(t *testing.T) {
+		faultyCache := &stubCacheFaultyIncr{StubCacheInMemory: cache.NewStubCacheInMemory()}
+		first, second := newInstances(t, faultyCache)
+
+		_, err := first.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "10", IdempotencyToken: "1"})
+		require.NoError(t, err)
+		assert.Equal(t, "10", balanceOf(t, second, 1))
+
+		faultyCache.setFailIncrs(balanceCacheInvalidateAttempts - 1)
+		_, err = first.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Amount: "4", IdempotencyToken: "2"})
+		require.NoError(t, err)
+		assert.Equal(t, "6", balanceOf(t, second, 1), "other instance must not serve stale balance")
+	})
+
+	t.Run("positive path, committed operation is acknowledged, stale cache is invalidated in background", func(t *testing.T) {
+		faultyCache := &stubCacheFaultyIncr{StubCacheInMemory: cache.NewStubCacheInMemory()}
+		first, second := newInstances(t, faultyCache)
+		first.staleBalanceRetryInterval = 10 * time.Millisecond
+
+		_, err := first.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "10", IdempotencyToken: "1"})
+		require.NoError(t, err)
+		_, err = first.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 2, Amount: "10", IdempotencyToken: "2"})
+		require.NoError(t, err)
+		assert.Equal(t, "10", balanceOf(t, second, 1))
+		assert.Equal(t, "10", balanceOf(t, second, 2))
+
+		faultyCache.setFailIncrs(-1)
+		res, err := first.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "3",
+			IdempotencyToken: "3"})
+		require.NoError(t, err, "committed operation must be acknowledged, if cache is not invalidated")
+		assert.Equal(t, MsgMoneyTransferDone, res.State)
+		assert.Equal(t, "7", balanceOf(t, first, 1), "instance, which failed to invalidate cache, must read database")
+
+		//retry of committed operation is acknowledged too
+		res, err = first.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "3",
+			IdempotencyToken: "3"})
+		require.NoError(t, err)
+		assert.Equal(t, OperationTokenIsAlreadyUsed, res.State)
+
+		faultyCache.setFailIncrs(0)
+		assert.Eventually(t, func() bool {
+			return balanceOf(t, second, 1) == "7" && balanceOf(t, second, 2) == "13"
+		}, time.Second, 10*time.Millisecond, "background retry must invalidate cache of sender and receiver")
+
+		first.mu.Lock()
+		staleUsers := len(first.staleBalanceUsers)
+		first.mu.Unlock()
+		assert.Zero(t, staleUsers, "stale marks must be removed after versions are incremented")
+		assert.Equal(t, "7", balanceOf(t, first, 1))
+	})
+
+	t.Run("positive path, instance with open breaker invalidates shared cache after breaker is closed", func(t *testing.T) {
+		sharedCache := cache.NewStubCacheInMemory()
+		newBreakerCache := func(t *testing.T, openTimeout time.Duration) (*cache.BreakerCache, *breaker.Breaker) {
+			b, err := breaker.NewBreaker("redis", &breaker.Config{FailureThreshold: 1, OpenTimeout: openTimeout})
+			require.NoError(t, err)
+			c, err := cache.NewBreakerCache(&logger.DummyLogger{}, sharedCache, b)
+			require.NoError(t, err)
+			return c, b
+		}
+
+		openCache, openBreaker := newBreakerCache(t, 100*time.Millisecond)
+		closedCache, _ := newBreakerCache(t, time.Hour)
+
+		storage := NewMemoryStorage()
+		first, err := NewAppWithStorage(&logger.DummyLogger{}, storage, &exchanger.StubExchanger{}, openCache, nil)
+		require.NoError(t, err)
+		first.staleBalanceRetryInterval = 10 * time.Millisecond
+		second, err := NewAppWithStorage(&logger.DummyLogger{}, storage, &exchanger.StubExchanger{}, closedCache, nil)
+		require.NoError(t, err)
+
+		_, err = first.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "10", IdempotencyToken: "1"})
+		require.NoError(t, err)
+		assert.Equal(t, "10", balanceOf(t, second, 1))
+
+		require.NoError(t, openBreaker.Allow())
+		openBreaker.Done(true)
+		require.Equal(t, breaker.StateOpen, openBreaker.Status().State, "breaker of first instance must be open")
+
+		start := time.Now()
+		_, err = first.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Amount: "4", IdempotencyToken: "2"})
+		require.NoError(t, err)
+		assert.Less(t, int64(time.Since(start)), int64(balanceCacheInvalidateRetryDelay),
+			"operation must not wait for retries of increment, skipped by open breaker")
+		assert.Equal(t, "6", balanceOf(t, first, 1), "instance with open breaker must read database")
+
+		assert.Eventually(t, func() bool {
+			return balanceOf(t, second, 1) == "6"
+		}, 2*time.Second, 10*time.Millisecond, "other instance must not serve stale balance after breaker is closed")
+	})
+}
+
+//stubCacheFaultyIncr fails version increments on demand
+type stubCacheFaultyIncr struct {
+	*cache.StubCacheInMemory
+	failIncrs int
+	mu        sync.Mutex
+}
+
+//setFailIncrs makes next n version increments fail, negative n fails all increments
+func (c *stubCacheFaultyIncr) setFailIncrs(n int) {
+	c.mu.Lock()
+	c.failIncrs = n
+	c.mu.Unlock()
+}
+
+func (c *stubCacheFaultyIncr) IncrVersion(ctx context.Context, key string) (int64, error) {
+	c.mu.Lock()
+	fail := c.failIncrs != 0
+	if c.failIncrs > 0 {
+		c.failIncrs--
+	}
+	c.mu.Unlock()
+
+	if fail {
+		return 0, cache.ErrFailedToGetConnFromPool
+	}
+	return c.StubCacheInMemory.IncrVersion(ctx, key)
+}
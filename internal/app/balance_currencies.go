@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"net/http"
+	"strings"
+)
+
+//maxBalanceCurrencies is a max number of currencies of one balance request
+const maxBalanceCurrencies = 20
+
+//normalizeBalanceCurrencies returns upper cased currency names of balance request without empty names and duplicates
+func normalizeBalanceCurrencies(currencies []string) ([]string, error) {
+	names := make([]string, 0, len(currencies))
+	seen := make(map[string]bool, len(currencies))
+	for _, name := range currencies {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	if len(names) > maxBalanceCurrencies {
+		return nil, ErrTooManyCurrencies
+	}
+	return names, nil
+}
+
+//convertBalanceToCurrencies converts balance to currency of request and to requested currencies by one snapshot
+//of rates. Unknown currency of request fails the request, unknown requested currency is reported in its item
+func (ba *BillingApp) convertBalanceToCurrencies(ctx context.Context, user *User, currency string,
+	currencies []string) (*UserBalance, error) {
+
+	targets := append([]string{currency}, currencies...)
+	conversions, err := ba.exchanger.GetConversions(ctx, user.Balance, targets)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetUserBalance, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserBalance, %s, err %v", ErrCurrencyExchangeFailed.Error(), err)
+		return nil, &AppError{ErrCurrencyExchangeFailed, http.StatusInternalServerError}
+	}
+
+	main := conversions.Items[0]
+	if main.Err != nil {
+		if errors.Is(main.Err, exchanger.ErrTargetCurrencyNameNotFound) {
+			ba.logger.Error("GetUserBalance, %s, err %v", ErrCurrencyDoesNotExist.Error(), main.Err)
+			return nil, &AppError{ErrCurrencyDoesNotExist, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserBalance, %s, err %v", ErrCurrencyExchangeFailed.Error(), main.Err)
+		return nil, &AppError{ErrCurrencyExchangeFailed, http.StatusInternalServerError}
+	}
+
+	userBalance := &UserBalance{
+		Balance:   main.Conversion.Amount.String(),
+		Currency:  currency,
+		Stale:     conversions.Stale,
+		RatesDate: conversions.RatesDate,
+		Balances:  make([]CurrencyBalance, 0, len(currencies)),
+	}
+
+	for _, item := range conversions.Items[1:] {
+		balance := CurrencyBalance{Currency: item.Currency}
+		switch {
+		case item.Err == nil:
+			balance.Balance = item.Conversion.Amount.String()
+		case errors.Is(item.Err, exchanger.ErrTargetCurrencyNameNotFound):
+			balance.ErrorCode, balance.Error = CodeCurrencyNotFound, ErrCurrencyDoesNotExist.Error()
+		default:
+			ba.logger.Error("GetUserBalance, %s, currency %s, err %v", ErrCurrencyExchangeFailed.Error(),
+				item.Currency, item.Err)
+			balance.ErrorCode, balance.Error = CodeCurrencyExchangeFailed, ErrCurrencyExchangeFailed.Error()
+		}
+		userBalance.Balances = append(userBalance.Balances, balance)
+	}
+	return userBalance, nil
+}
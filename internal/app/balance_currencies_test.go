@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBillingApp_GetUserBalance_Currencies(t *testing.T) {
+	ctx := context.Background()
+
+	app := newMemoryStorageApp(t, NewMemoryStorage())
+	_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "1000", IdempotencyToken: "1"})
+	require.NoError(t, err)
+
+	t.Run("positive path, balance in several currencies", func(t *testing.T) {
+		balance, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 1,
+			Currencies: []string{"rub", "USD", "EUR", "USD", "", "XYZ"}})
+		require.NoError(t, err)
+		assert.Equal(t, "1000", balance.Balance)
+		assert.Equal(t, "RUB", balance.Currency)
+		assert.Equal(t, "2020-08-15", balance.RatesDate)
+		assert.Equal(t, []CurrencyBalance{
+			{Currency: "RUB", Balance: "1000"},
+			{Currency: "USD", Balance: "13"},
+			{Currency: "EUR", Balance: "11"},
+			{Currency: "XYZ", ErrorCode: CodeCurrencyNotFound, Error: ErrCurrencyDoesNotExist.Error()},
+		}, balance.Balances, "unknown currency must be reported in its item")
+	})
+
+	t.Run("positive path, currency of request is converted by the same rates", func(t *testing.T) {
+		balance, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 1, Currency: "EUR", Currencies: []string{"USD"}})
+		require.NoError(t, err)
+		assert.Equal(t, "11", balance.Balance)
+		assert.Equal(t, "EUR", balance.Currency)
+		assert.Equal(t, []CurrencyBalance{{Currency: "USD", Balance: "13"}}, balance.Balances)
+	})
+
+	t.Run("negative path, bad request", func(t *testing.T) {
+		_, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 1, Currency: "XYZ", Currencies: []string{"USD"}})
+		assert.ErrorIs(t, err, ErrCurrencyDoesNotExist, "unknown currency of request must fail request")
+
+		_, err = app.GetUserBalance(ctx, &BalanceRequest{UserId: 5, Currencies: []string{"USD"}})
+		assert.ErrorIs(t, err, ErrUserDoesNotExist)
+
+		currencies := make([]string, 0, maxBalanceCurrencies+1)
+		for i := 0; i <= maxBalanceCurrencies; i++ {
+			currencies = append(currencies, string(rune('A'+i))+"XX")
+		}
+		_, err = app.GetUserBalance(ctx, &BalanceRequest{UserId: 1, Currencies: currencies})
+		assert.ErrorIs(t, err, ErrTooManyCurrencies)
+	})
+}
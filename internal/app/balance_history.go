@@ -0,0 +1,502 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"github.com/shopspring/decimal"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"net/http"
+	"time"
+)
+
+//dateLayout is a layout of days in balance history requests, days are counted in UTC
+const dateLayout = "2006-01-02"
+
+//parseDate parses day in YYYY-MM-DD format, returns start of the day in UTC
+func parseDate(date string) (time.Time, error) {
+	day, err := time.ParseInLocation(dateLayout, date, time.UTC)
+	if err != nil {
+		return time.Time{}, ErrBadDateFormat
+	}
+	return day, nil
+}
+
+//StartOfDay returns start of the day of given time in UTC
+func StartOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+//SnapshotUserBalances stores closing balances of all users, existing at the end of given day.
+//Balance is computed as current balance minus operations made after the day end, so the job may be run late.
+//Already stored snapshots are never changed, returns number of stored snapshots
+func (ba *BillingApp) SnapshotUserBalances(ctx context.Context, day time.Time) (int64, error) {
+	day = StartOfDay(day)
+	dayEnd := day.AddDate(0, 0, 1)
+
+	if dayEnd.After(time.Now()) {
+		ba.logger.Error("SnapshotUserBalances, %s, day %s", ErrDateIsInFuture.Error(), day.Format(dateLayout))
+		return 0, &AppError{ErrDateIsInFuture, http.StatusBadRequest}
+	}
+
+	//repeatable read gives consistent view of balances and operations
+	tx, err := ba.storage.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead, ReadOnly: false})
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("SnapshotUserBalances, %s, err %v", ctxErr.Error(), err)
+			return 0, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("SnapshotUserBalances, %s, err %v", ErrDBTransactionBeginFailed.Error(), err)
+		return 0, &AppError{ErrDBTransactionBeginFailed, http.StatusInternalServerError}
+	}
+
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("SnapshotUserBalances, %s, err %v", ctxErr.Error(), err)
+			}
+
+			ba.logger.Error("SnapshotUserBalances, %s, err %v", ErrDBTransactionRollbackFailed.Error(), err)
+		}
+	}()
+
+	stored, err := tx.Snapshots().InsertClosingBalances(ctx, day, dayEnd)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("SnapshotUserBalances, %s, err %v", ctxErr.Error(), err)
+			return 0, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("SnapshotUserBalances, %s, err %v", ErrDBFailedToInsertSnapshotRows.Error(), err)
+		return 0, &AppError{ErrDBFailedToInsertSnapshotRows, http.StatusInternalServerError}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("SnapshotUserBalances, %s, err %v", ctxErr.Error(), err)
+			return 0, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("SnapshotUserBalances, %s, err %v", ErrDBTransactionCommitFailed.Error(), err)
+		return 0, &AppError{ErrDBTransactionCommitFailed, http.StatusInternalServerError}
+	}
+
+	return stored, nil
+}
+
+//getClosingBalance returns balance of user at the end of the day. Stored snapshot of the day is used if exists,
+//otherwise balance is computed from the latest earlier snapshot and operations after it,
+//or from current balance and operations made after the day end if user has no snapshots
+func (ba *BillingApp) getClosingBalance(ctx context.Context, tx IStorageTx, user *User, day time.Time) (decimal.Decimal, error) {
+	dayEnd := day.AddDate(0, 0, 1)
+
+	snapshot, err := tx.Snapshots().GetLatest(ctx, user.Id, day)
+	if err != nil && err != sql.ErrNoRows {
+		return decimal.Decimal{}, err
+	}
+
+	if err == sql.ErrNoRows {
+		opsSum, err := tx.Operations().SumAmount(ctx, user.Id, &dayEnd, nil)
+		if err != nil {
+			return decimal.Decimal{}, err
+		}
+		return user.Balance.Sub(opsSum.Decimal), nil
+	}
+
+	snapshotDay := StartOfDay(snapshot.Date)
+	if snapshotDay.Equal(day) {
+		return snapshot.Balance, nil
+	}
+
+	snapshotDayEnd := snapshotDay.AddDate(0, 0, 1)
+	opsSum, err := tx.Operations().SumAmount(ctx, user.Id, &snapshotDayEnd, &dayEnd)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	return snapshot.Balance.Add(opsSum.Decimal), nil
+}
+
+func (ba *BillingApp) GetUserBalanceOnDate(ctx context.Context, in *BalanceOnDateRequest) (*BalanceOnDate, error) {
+	if in == nil {
+		ba.logger.Error("GetUserBalanceOnDate, %s", ErrParamsStructIsNil.Error())
+		return nil, &AppError{ErrParamsStructIsNil, http.StatusBadRequest}
+	}
+
+	day, err := parseDate(in.Date)
+	if err != nil {
+		ba.logger.Error("GetUserBalanceOnDate, %s, date %s", err.Error(), in.Date)
+		return nil, &AppError{err, http.StatusBadRequest}
+	}
+
+	if day.After(time.Now()) {
+		ba.logger.Error("GetUserBalanceOnDate, %s, date %s", ErrDateIsInFuture.Error(), in.Date)
+		return nil, &AppError{ErrDateIsInFuture, http.StatusBadRequest}
+	}
+
+	tx, err := ba.storage.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetUserBalanceOnDate, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserBalanceOnDate, %s, err %v", ErrDBTransactionBeginFailed.Error(), err)
+		return nil, &AppError{ErrDBTransactionBeginFailed, http.StatusInternalServerError}
+	}
+
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("GetUserBalanceOnDate, %s, err %v", ctxErr.Error(), err)
+			}
+
+			ba.logger.Error("GetUserBalanceOnDate, %s, err %v", ErrDBTransactionRollbackFailed.Error(), err)
+		}
+	}()
+
+	user, err := tx.Users().Get(ctx, in.UserId, RowLockNone)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetUserBalanceOnDate, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		if err == sql.ErrNoRows {
+			ba.logger.Error("GetUserBalanceOnDate, %s, err %v", ErrUserDoesNotExist.Error(), err)
+			return nil, &AppError{ErrUserDoesNotExist, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserBalanceOnDate, %s, err %v", ErrDBFailedToFetchUserRow.Error(), err)
+		return nil, &AppError{ErrDBFailedToFetchUserRow, http.StatusInternalServerError}
+	}
+
+	balance, err := ba.getClosingBalance(ctx, tx, user, day)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetUserBalanceOnDate, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserBalanceOnDate, %s, err %v", ErrDBFailedToFetchSnapshotRows.Error(), err)
+		return nil, &AppError{ErrDBFailedToFetchSnapshotRows, http.StatusInternalServerError}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetUserBalanceOnDate, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserBalanceOnDate, %s, err %v", ErrDBTransactionCommitFailed.Error(), err)
+		return nil, &AppError{ErrDBTransactionCommitFailed, http.StatusInternalServerError}
+	}
+
+	return &BalanceOnDate{
+		UserId:   user.Id,
+		Date:     day.Format(dateLayout),
+		Balance:  balance.String(),
+		Currency: exchanger.RUBCode,
+	}, nil
+}
+
+func (ba *BillingApp) GetUserStatement(ctx context.Context, in *StatementRequest) (*Statement, error) {
+	if in == nil {
+		ba.logger.Error("GetUserStatement, %s", ErrParamsStructIsNil.Error())
+		return nil, &AppError{ErrParamsStructIsNil, http.StatusBadRequest}
+	}
+
+	from, err := parseDate(in.From)
+	if err != nil {
+		ba.logger.Error("GetUserStatement, %s, from %s", err.Error(), in.From)
+		return nil, &AppError{err, http.StatusBadRequest}
+	}
+
+	to, err := parseDate(in.To)
+	if err != nil {
+		ba.logger.Error("GetUserStatement, %s, to %s", err.Error(), in.To)
+		return nil, &AppError{err, http.StatusBadRequest}
+	}
+
+	if from.After(to) {
+		ba.logger.Error("GetUserStatement, %s, from %s, to %s", ErrPeriodStartIsAfterEnd.Error(), in.From, in.To)
+		return nil, &AppError{ErrPeriodStartIsAfterEnd, http.StatusBadRequest}
+	}
+
+	if to.After(time.Now()) {
+		ba.logger.Error("GetUserStatement, %s, to %s", ErrDateIsInFuture.Error(), in.To)
+		return nil, &AppError{ErrDateIsInFuture, http.StatusBadRequest}
+	}
+
+	tx, err := ba.storage.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetUserStatement, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserStatement, %s, err %v", ErrDBTransactionBeginFailed.Error(), err)
+		return nil, &AppError{ErrDBTransactionBeginFailed, http.StatusInternalServerError}
+	}
+
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("GetUserStatement, %s, err %v", ctxErr.Error(), err)
+			}
+
+			ba.logger.Error("GetUserStatement, %s, err %v", ErrDBTransactionRollbackFailed.Error(), err)
+		}
+	}()
+
+	user, err := tx.Users().Get(ctx, in.UserId, RowLockNone)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetUserStatement, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		if err == sql.ErrNoRows {
+			ba.logger.Error("GetUserStatement, %s, err %v", ErrUserDoesNotExist.Error(), err)
+			return nil, &AppError{ErrUserDoesNotExist, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserStatement, %s, err %v", ErrDBFailedToFetchUserRow.Error(), err)
+		return nil, &AppError{ErrDBFailedToFetchUserRow, http.StatusInternalServerError}
+	}
+
+	openingBalance, err := ba.getClosingBalance(ctx, tx, user, from.AddDate(0, 0, -1))
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetUserStatement, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserStatement, %s, err %v", ErrDBFailedToFetchSnapshotRows.Error(), err)
+		return nil, &AppError{ErrDBFailedToFetchSnapshotRows, http.StatusInternalServerError}
+	}
+
+	closingBalance, err := ba.getClosingBalance(ctx, tx, user, to)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetUserStatement, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserStatement, %s, err %v", ErrDBFailedToFetchSnapshotRows.Error(), err)
+		return nil, &AppError{ErrDBFailedToFetchSnapshotRows, http.StatusInternalServerError}
+	}
+
+	periodEnd := to.AddDate(0, 0, 1)
+	operations, err := tx.Operations().List(ctx, &OperationFilter{
+		UserId: user.Id, From: &from, To: &periodEnd, OrderField: "date", OrderDirection: "asc", Limit: -1})
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetUserStatement, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserStatement, %s, err %v", ErrDBFailedToFetchOperationRows.Error(), err)
+		return nil, &AppError{ErrDBFailedToFetchOperationRows, http.StatusInternalServerError}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetUserStatement, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserStatement, %s, err %v", ErrDBTransactionCommitFailed.Error(), err)
+		return nil, &AppError{ErrDBTransactionCommitFailed, http.StatusInternalServerError}
+	}
+
+	statement := NewStatement(user.Id, from, to, openingBalance, closingBalance, operations)
+	if !statement.Consistent {
+		ba.logger.Error("GetUserStatement, statement of user %d from %s to %s is inconsistent, discrepancy %s",
+			user.Id, statement.From, statement.To, statement.Discrepancy)
+	}
+
+	return statement, nil
+}
+
+//StreamUserStatement reads opening balance and operations of the period in one repeatable read transaction,
+//so streamed statement is consistent, even if operations are stored while it is streamed. Opening balance is
+//passed to onOpening, then operations, ordered by date and id, are read by pages and passed to onPage.
+//Next page is read after the last operation of previous one, so pages do not overlap
+func (ba *BillingApp) StreamUserStatement(ctx context.Context, in *StatementStreamRequest,
+	onOpening func(decimal.Decimal) error, onPage func([]Operation) error) error {
+	if in == nil {
+		ba.logger.Error("StreamUserStatement, %s", ErrParamsStructIsNil.Error())
+		return &AppError{ErrParamsStructIsNil, http.StatusBadRequest}
+	}
+
+	from, err := parseDate(in.From)
+	if err != nil {
+		ba.logger.Error("StreamUserStatement, %s, from %s", err.Error(), in.From)
+		return &AppError{err, http.StatusBadRequest}
+	}
+
+	to, err := parseDate(in.To)
+	if err != nil {
+		ba.logger.Error("StreamUserStatement, %s, to %s", err.Error(), in.To)
+		return &AppError{err, http.StatusBadRequest}
+	}
+
+	if from.After(to) {
+		ba.logger.Error("StreamUserStatement, %s, from %s, to %s", ErrPeriodStartIsAfterEnd.Error(), in.From, in.To)
+		return &AppError{ErrPeriodStartIsAfterEnd, http.StatusBadRequest}
+	}
+
+	openingDay := from.AddDate(0, 0, -1)
+	if openingDay.After(time.Now()) {
+		ba.logger.Error("StreamUserStatement, %s, from %s", ErrDateIsInFuture.Error(), in.From)
+		return &AppError{ErrDateIsInFuture, http.StatusBadRequest}
+	}
+
+	if in.PageSize <= 0 {
+		ba.logger.Error("StreamUserStatement, %s user %d, page size %d", ErrLimitParamIsLessThanMin.Error(), in.UserId,
+			in.PageSize)
+		return &AppError{ErrLimitParamIsLessThanMin, http.StatusBadRequest}
+	}
+
+	if err := ValidateConsistencyToken(in.ConsistencyToken); err != nil {
+		ba.logger.Error("StreamUserStatement, %s, token %s", err.Error(), in.ConsistencyToken)
+		return &AppError{err, http.StatusBadRequest}
+	}
+
+	tx, _, err := ba.beginReadTx(ctx, "StreamUserStatement", in.ConsistencyToken, sql.LevelRepeatableRead)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("StreamUserStatement, %s, err %v", ctxErr.Error(), err)
+			return &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("StreamUserStatement, %s, err %v", ErrDBTransactionBeginFailed.Error(), err)
+		return &AppError{ErrDBTransactionBeginFailed, http.StatusInternalServerError}
+	}
+
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("StreamUserStatement, %s, err %v", ctxErr.Error(), err)
+			}
+
+			ba.logger.Error("StreamUserStatement, %s, err %v", ErrDBTransactionRollbackFailed.Error(), err)
+		}
+	}()
+
+	user, err := tx.Users().Get(ctx, in.UserId, RowLockNone)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("StreamUserStatement, %s, err %v", ctxErr.Error(), err)
+			return &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		if err == sql.ErrNoRows {
+			ba.logger.Error("StreamUserStatement, %s, err %v", ErrUserDoesNotExist.Error(), err)
+			return &AppError{ErrUserDoesNotExist, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("StreamUserStatement, %s, err %v", ErrDBFailedToFetchUserRow.Error(), err)
+		return &AppError{ErrDBFailedToFetchUserRow, http.StatusInternalServerError}
+	}
+
+	openingBalance, err := ba.getClosingBalance(ctx, tx, user, openingDay)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("StreamUserStatement, %s, err %v", ctxErr.Error(), err)
+			return &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("StreamUserStatement, %s, err %v", ErrDBFailedToFetchSnapshotRows.Error(), err)
+		return &AppError{ErrDBFailedToFetchSnapshotRows, http.StatusInternalServerError}
+	}
+
+	err = onOpening(openingBalance)
+	if err != nil {
+		return err
+	}
+
+	periodEnd := to.AddDate(0, 0, 1)
+	filter := &OperationFilter{UserId: user.Id, From: &from, To: &periodEnd, OrderField: "date",
+		OrderDirection: "asc", Limit: in.PageSize}
+	for {
+		operations, err := tx.Operations().List(ctx, filter)
+		if err != nil && err != sql.ErrNoRows {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("StreamUserStatement, %s, err %v", ctxErr.Error(), err)
+				return &AppError{ctxErr, http.StatusBadRequest}
+			}
+
+			ba.logger.Error("StreamUserStatement, %s, err %v", ErrDBFailedToFetchOperationRows.Error(), err)
+			return &AppError{ErrDBFailedToFetchOperationRows, http.StatusInternalServerError}
+		}
+
+		if len(operations) == 0 {
+			break
+		}
+
+		err = onPage(operations)
+		if err != nil {
+			return err
+		}
+
+		if int64(len(operations)) < in.PageSize {
+			break
+		}
+		last := operations[len(operations)-1]
+		filter.After = &OperationCursor{Date: last.Date, Id: last.Id}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("StreamUserStatement, %s, err %v", ctxErr.Error(), err)
+			return &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("StreamUserStatement, %s, err %v", ErrDBTransactionCommitFailed.Error(), err)
+		return &AppError{ErrDBTransactionCommitFailed, http.StatusInternalServerError}
+	}
+
+	return nil
+}
+
+//NewStatement sums operations of the period and checks that opening balance plus operations gives closing balance
+func NewStatement(userId int64, from, to time.Time, openingBalance, closingBalance decimal.Decimal, operations []Operation) *Statement {
+	totalCredits := decimal.Zero
+	totalDebits := decimal.Zero
+	for _, op := range operations {
+		if op.Amount.IsNegative() {
+			totalDebits = totalDebits.Add(op.Amount)
+		} else {
+			totalCredits = totalCredits.Add(op.Amount)
+		}
+	}
+
+	discrepancy := closingBalance.Sub(openingBalance).Sub(totalCredits).Sub(totalDebits)
+
+	return &Statement{
+		UserId:         userId,
+		From:           from.Format(dateLayout),
+		To:             to.Format(dateLayout),
+		Currency:       exchanger.RUBCode,
+		OpeningBalance: openingBalance.String(),
+		Operations:     operations,
+		TotalCredits:   totalCredits.String(),
+		TotalDebits:    totalDebits.String(),
+		ClosingBalance: closingBalance.String(),
+		Consistent:     discrepancy.IsZero(),
+		Discrepancy:    discrepancy.String(),
+	}
+}
@@ -0,0 +1,163 @@
+// +build integration
+
+package app
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/cache"
+	"job-backend-trainee-assignment/internal/db_connector"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"job-backend-trainee-assignment/internal/logger"
+	"job-backend-trainee-assignment/internal/test_helpers"
+	"testing"
+	"time"
+)
+
+func TestBillingApp_BalanceHistory_Common(t *testing.T) {
+	v := viper.New()
+
+	v.AddConfigPath(".")
+	v.AddConfigPath("../../")
+	v.SetConfigName("config")
+	v.AutomaticEnv()
+
+	err := v.ReadInConfig()
+	require.NoErrorf(t, err, "failed to read config file at: %s, err %v", "config", err)
+
+	var pgHost string
+	if v.GetString("DATABASE_HOST") != "" {
+		pgHost = v.GetString("DATABASE_HOST")
+	} else {
+		pgHost = v.GetString("db_params.DATABASE_HOST")
+	}
+
+	dbConfig := &db_connector.Config{
+		DriverName:    v.GetString("db_params.driver_name"),
+		DBUser:        v.GetString("db_params.user"),
+		DBPass:        v.GetString("db_params.password"),
+		DBName:        v.GetString("db_params.db_name"),
+		DBPort:        v.GetString("db_params.port"),
+		DBHost:        pgHost,
+		SSLMode:       v.GetString("db_params.ssl_mode"),
+		RetryInterval: v.GetDuration("db_params.conn_retry_interval") * time.Second,
+	}
+
+	dbConnTimeout := v.GetDuration("db_params.conn_timeout") * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), dbConnTimeout)
+	defer cancel()
+	dummyLogger := &logger.DummyLogger{}
+
+	db, dbCloseFunc, err := db_connector.DBConnectWithTimeout(ctx, dbConfig, dummyLogger)
+	require.NoErrorf(t, err, "failed to connect to db,err %v", err)
+
+	defer dbCloseFunc()
+
+	ex := &exchanger.StubExchanger{}
+	dummyCacher := &cache.DummyCacheWithNoKeyExists{}
+	app, err := NewApp(dummyLogger, db, ex, dummyCacher, nil)
+	require.NoErrorf(t, err, "failed to create BillingApp instance, err %v", err)
+
+	caseTimeout := v.GetDuration("testing_params.test_case_timeout") * time.Second
+
+	prepareDB := func(ctx context.Context, t *testing.T) {
+		err := test_helpers.PrepareDB(ctx, db, test_helpers.Config{
+			InitFilePath:    filePathPrefix + v.GetString("testing_params.db_init_file_path"),
+			CleanUpFilePath: filePathPrefix + v.GetString("testing_params.db_cleanup_file_path"),
+		})
+		require.NoError(t, err, "PrepareDB must not return error")
+	}
+
+	t.Run("balance on date, without snapshots", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		res, err := app.GetUserBalanceOnDate(ctx, &BalanceOnDateRequest{UserId: 2, Date: "2020-08-10"})
+		require.NoError(t, err)
+		assert.Equal(t, "0", res.Balance)
+
+		res, err = app.GetUserBalanceOnDate(ctx, &BalanceOnDateRequest{UserId: 2, Date: "2020-08-11"})
+		require.NoError(t, err)
+		assert.Equal(t, "10", res.Balance)
+	})
+
+	t.Run("balance on date, with snapshots", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		day := time.Date(2020, 8, 11, 0, 0, 0, 0, time.UTC)
+		stored, err := app.SnapshotUserBalances(ctx, day)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), stored)
+
+		stored, err = app.SnapshotUserBalances(ctx, day)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), stored, "snapshot of the same day must be stored once")
+
+		res, err := app.GetUserBalanceOnDate(ctx, &BalanceOnDateRequest{UserId: 2, Date: "2020-08-12"})
+		require.NoError(t, err)
+		assert.Equal(t, "10", res.Balance)
+	})
+
+	t.Run("balance on date, user not found", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		_, err := app.GetUserBalanceOnDate(ctx, &BalanceOnDateRequest{UserId: 100, Date: "2020-08-11"})
+		require.Error(t, err)
+		assert.Equal(t, ErrUserDoesNotExist, err.(*AppError).Err)
+	})
+
+	t.Run("statement, Common", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		res, err := app.GetUserStatement(ctx, &StatementRequest{UserId: 2, From: "2020-08-01", To: "2020-08-31"})
+		require.NoError(t, err)
+		assert.Equal(t, "0", res.OpeningBalance)
+		assert.Equal(t, "10", res.ClosingBalance)
+		assert.Equal(t, "20", res.TotalCredits)
+		assert.Equal(t, "-10", res.TotalDebits)
+		assert.Len(t, res.Operations, 3)
+		assert.True(t, res.Consistent)
+		assert.True(t, decimal.Zero.Equal(decimal.RequireFromString(res.Discrepancy)))
+	})
+
+	t.Run("statement, period start is after end", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+
+		_, err := app.GetUserStatement(ctx, &StatementRequest{UserId: 2, From: "2020-08-31", To: "2020-08-01"})
+		require.Error(t, err)
+		assert.Equal(t, ErrPeriodStartIsAfterEnd, err.(*AppError).Err)
+	})
+
+	t.Run("operations, period filter", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		res, err := app.GetUserOperations(ctx, &OperationLogRequest{UserId: 2, Limit: 2, Page: 1,
+			OrderDirection: "asc", From: "2020-08-11", To: "2020-08-11"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), res.OperationsNum)
+		assert.Equal(t, int64(2), res.PagesTotal)
+		assert.Len(t, res.Operations, 2)
+
+		res, err = app.GetUserOperations(ctx, &OperationLogRequest{UserId: 2, Limit: -1, From: "2020-08-12"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), res.OperationsNum)
+		assert.Len(t, res.Operations, 0)
+
+		_, err = app.GetUserOperations(ctx, &OperationLogRequest{UserId: 2, Limit: -1, From: "2020-08-12", To: "2020-08-11"})
+		require.Error(t, err)
+		assert.Equal(t, ErrPeriodStartIsAfterEnd, err.(*AppError).Err)
+	})
+}
@@ -0,0 +1,422 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"net/http"
+	"time"
+)
+
+//policies of bonus balance use by withdrawals
+const (
+	//BonusPolicyBonusFirst spends bonus balance first, the rest of amount is withdrawn from real balance
+	BonusPolicyBonusFirst = "bonus_first"
+	//BonusPolicyRealFirst spends real balance first, the rest of amount is paid by bonus balance
+	BonusPolicyRealFirst = "real_first"
+	//BonusPolicyRealOnly withdraws real balance only, bonus balance is not spent
+	BonusPolicyRealOnly = "real_only"
+)
+
+//bonusWithdrawPolicy returns policy of config or default bonus first policy
+func (cfg *Config) bonusWithdrawPolicy() string {
+	if cfg.BonusWithdrawPolicy == "" {
+		return BonusPolicyBonusFirst
+	}
+	return cfg.BonusWithdrawPolicy
+}
+
+//bonusPartOfWithdrawal returns part of withdrawal amount, paid by bonus balance. Fee is paid by real balance only
+func (cfg *Config) bonusPartOfWithdrawal(amount, fee, balance, bonusBalance decimal.Decimal) decimal.Decimal {
+	var bonusPart decimal.Decimal
+	switch cfg.bonusWithdrawPolicy() {
+	case BonusPolicyBonusFirst:
+		bonusPart = amount
+	case BonusPolicyRealFirst:
+		bonusPart = amount.Sub(decimal.Max(balance.Sub(fee), decimal.Zero))
+	default:
+		return decimal.Zero
+	}
+	return decimal.Max(decimal.Min(bonusPart, bonusBalance), decimal.Zero)
+}
+
+//sumRemaining returns bonus balance of grants
+func sumRemaining(grants []BonusGrant) decimal.Decimal {
+	sum := decimal.Zero
+	for _, grant := range grants {
+		sum = sum.Add(grant.Remaining)
+	}
+	return sum
+}
+
+//GrantBonus grants promotional bonus to user. Bonus may be spent on withdrawals until expiration,
+//it may not be transferred to other users. Grant is stored in operation log with zero amount of real money
+func (ba *BillingApp) GrantBonus(ctx context.Context, in *BonusGrantRequest) (*ResultState, error) {
+	if in == nil {
+		ba.logger.Error("GrantBonus, %s", ErrParamsStructIsNil.Error())
+		return nil, &AppError{ErrParamsStructIsNil, http.StatusBadRequest}
+	}
+
+	if in.IdempotencyToken == "" {
+		ba.logger.Error("GrantBonus, %s", ErrIdempotencyTokenIsEmpty.Error())
+		return nil, &AppError{ErrIdempotencyTokenIsEmpty, http.StatusBadRequest}
+	}
+
+	began, done, err := ba.beginIdempotentOperation(ctx, "GrantBonus", EndpointBonus, in.IdempotencyToken)
+	if err != nil {
+		return nil, err
+	}
+	if done {
+		return &ResultState{State: OperationTokenIsAlreadyUsed, ConsistencyToken: ba.consistencyToken("GrantBonus")}, nil
+	}
+
+	completed := false
+	defer func() {
+		ba.finishIdempotentOperation("GrantBonus", EndpointBonus, in.IdempotencyToken, began, completed)
+	}()
+
+	amount, err := decimal.NewFromString(in.Amount)
+	if err != nil {
+		ba.logger.Error("GrantBonus, %s, err %v", ErrFailedToCastAmountToDecimal.Error(), err)
+		return nil, &AppError{ErrFailedToCastAmountToDecimal, http.StatusBadRequest}
+	}
+
+	if amount.IsNegative() {
+		ba.logger.Error("GrantBonus, %s", ErrAmountValueIsNegative.Error())
+		return nil, &AppError{ErrAmountValueIsNegative, http.StatusBadRequest}
+	}
+
+	if err := ba.validateOperationAmount("GrantBonus", amount); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !in.ExpiresAt.After(now) {
+		ba.logger.Error("GrantBonus, %s, expires at %s", ErrBonusExpirationIsNotInFuture.Error(),
+			in.ExpiresAt.Format(time.RFC3339))
+		return nil, &AppError{WithDetails(ErrBonusExpirationIsNotInFuture,
+			map[string]interface{}{"expires_at": in.ExpiresAt.Format(time.RFC3339)}), http.StatusBadRequest}
+	}
+
+	tx, err := ba.storage.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted, ReadOnly: false})
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GrantBonus, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GrantBonus, %s, err %v", ErrDBTransactionBeginFailed.Error(), err)
+		return nil, &AppError{ErrDBTransactionBeginFailed, http.StatusInternalServerError}
+	}
+
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("GrantBonus, %s, err %v", ctxErr.Error(), err)
+			}
+
+			ba.logger.Error("GrantBonus, %s, err %v", ErrDBTransactionRollbackFailed.Error(), err)
+		}
+	}()
+	{
+		err = tx.Operations().LockForInsert(ctx)
+		if err != nil {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("GrantBonus, %s, err %v", ctxErr.Error(), err)
+				return nil, &AppError{ctxErr, http.StatusBadRequest}
+			}
+
+			ba.logger.Error("GrantBonus, %s, err %v", ErrDBFailedToLockOperationTableForInsert.Error(), err)
+			return nil, &AppError{ErrDBFailedToLockOperationTableForInsert, http.StatusInternalServerError}
+		}
+
+		tokenIsUsed, err := tx.Idempotency().TokenIsUsed(ctx, in.IdempotencyToken)
+		if err != nil {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("GrantBonus, %s, err %v", ctxErr.Error(), err)
+				return nil, &AppError{ctxErr, http.StatusBadRequest}
+			}
+
+			ba.logger.Error("GrantBonus, %s, err %v", ErrFailedToCheckIdempotencyTokenExistenceInDB.Error(), err)
+			return nil, &AppError{ErrFailedToCheckIdempotencyTokenExistenceInDB, http.StatusInternalServerError}
+		}
+
+		if tokenIsUsed {
+			ba.logger.Info("GrantBonus, operation token found in database, returning success response")
+			result, err := ba.replayOperation(ctx, "GrantBonus", tx, in.IdempotencyToken)
+			completed = err == nil
+			return result, err
+		}
+
+		_, err = tx.Users().Get(ctx, in.UserId, RowLockNone)
+		if err != nil {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("GrantBonus, %s, err %v", ctxErr.Error(), err)
+				return nil, &AppError{ctxErr, http.StatusBadRequest}
+			}
+
+			if err == sql.ErrNoRows {
+				ba.logger.Error("GrantBonus, %s, err %v", ErrUserDoesNotExist.Error(), err)
+				return nil, &AppError{ErrUserDoesNotExist, http.StatusBadRequest}
+			}
+
+			ba.logger.Error("GrantBonus, %s, err %v", ErrDBFailedToFetchUserRow.Error(), err)
+			return nil, &AppError{ErrDBFailedToFetchUserRow, http.StatusInternalServerError}
+		}
+
+		err = tx.Bonuses().Insert(ctx, &BonusGrant{
+			UserId:           in.UserId,
+			Amount:           amount,
+			Remaining:        amount,
+			GrantedAt:        now,
+			ExpiresAt:        in.ExpiresAt,
+			IdempotencyToken: in.IdempotencyToken,
+		})
+		if err != nil {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("GrantBonus, %s, err %v", ctxErr.Error(), err)
+				return nil, &AppError{ctxErr, http.StatusBadRequest}
+			}
+
+			ba.logger.Error("GrantBonus, %s, err %v", ErrDBFailedToInsertBonusRow.Error(), err)
+			return nil, &AppError{ErrDBFailedToInsertBonusRow, http.StatusInternalServerError}
+		}
+
+		err = tx.Operations().Insert(ctx, Operation{
+			UserId:           in.UserId,
+			Comment:          fmt.Sprintf(CommentBonusGrantWithComment, in.Purpose),
+			Amount:           decimal.Zero,
+			Date:             now,
+			IdempotencyToken: in.IdempotencyToken,
+			BonusAmount:      &amount,
+		})
+		if err != nil {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("GrantBonus, %s, err %v", ctxErr.Error(), err)
+				return nil, &AppError{ctxErr, http.StatusBadRequest}
+			}
+
+			ba.logger.Error("GrantBonus, %s, err %v", ErrFailedToInsertOperationRow.Error(), err)
+			return nil, &AppError{ErrFailedToInsertOperationRow, http.StatusInternalServerError}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GrantBonus, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GrantBonus, %s, err %v", ErrDBTransactionCommitFailed.Error(), err)
+		return nil, &AppError{ErrDBTransactionCommitFailed, http.StatusInternalServerError}
+	}
+	completed = true
+
+	return &ResultState{State: MsgBonusGrantDone, ConsistencyToken: ba.consistencyToken("GrantBonus")}, nil
+}
+
+//GetUserBonuses returns bonus balance of user and active grants in order of spending
+func (ba *BillingApp) GetUserBonuses(ctx context.Context, in *BonusBalanceRequest) (*BonusBalance, error) {
+	if in == nil {
+		ba.logger.Error("GetUserBonuses, %s", ErrParamsStructIsNil.Error())
+		return nil, &AppError{ErrParamsStructIsNil, http.StatusBadRequest}
+	}
+
+	tx, err := ba.storage.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted, ReadOnly: true})
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetUserBonuses, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserBonuses, %s, err %v", ErrDBTransactionBeginFailed.Error(), err)
+		return nil, &AppError{ErrDBTransactionBeginFailed, http.StatusInternalServerError}
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.Users().Get(ctx, in.UserId, RowLockNone)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetUserBonuses, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		if err == sql.ErrNoRows {
+			ba.logger.Error("GetUserBonuses, %s, err %v", ErrUserDoesNotExist.Error(), err)
+			return nil, &AppError{ErrUserDoesNotExist, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserBonuses, %s, err %v", ErrDBFailedToFetchUserRow.Error(), err)
+		return nil, &AppError{ErrDBFailedToFetchUserRow, http.StatusInternalServerError}
+	}
+
+	grants, err := ba.activeBonusGrants(ctx, "GetUserBonuses", tx, in.UserId, time.Now(), RowLockNone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BonusBalance{
+		UserId:       in.UserId,
+		BonusBalance: sumRemaining(grants).String(),
+		Currency:     exchanger.RUBCode,
+		Grants:       grants,
+	}, nil
+}
+
+//activeBonusGrants returns not spent grants of user, which are not expired at now, in order of spending
+func (ba *BillingApp) activeBonusGrants(ctx context.Context, method string, tx IStorageTx, userId int64,
+	now time.Time, lock RowLock) ([]BonusGrant, error) {
+	grants, err := tx.Bonuses().ListActive(ctx, userId, now, lock)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("%s, %s, err %v", method, ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("%s, %s, err %v", method, ErrDBFailedToFetchBonusRows.Error(), err)
+		return nil, &AppError{ErrDBFailedToFetchBonusRows, http.StatusInternalServerError}
+	}
+	return grants, nil
+}
+
+//spendBonusGrants decreases remaining amounts of grants in given order by amount, grants must be locked
+func (ba *BillingApp) spendBonusGrants(ctx context.Context, method string, tx IStorageTx, grants []BonusGrant,
+	amount decimal.Decimal) error {
+	for _, grant := range grants {
+		if !amount.IsPositive() {
+			return nil
+		}
+
+		spent := decimal.Min(grant.Remaining, amount)
+		err := tx.Bonuses().SetRemaining(ctx, grant.Id, grant.Remaining.Sub(spent))
+		if err != nil {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("%s, %s, err %v", method, ctxErr.Error(), err)
+				return &AppError{ctxErr, http.StatusBadRequest}
+			}
+
+			ba.logger.Error("%s, %s, err %v", method, ErrDBFailedToUpdateBonusRow.Error(), err)
+			return &AppError{ErrDBFailedToUpdateBonusRow, http.StatusInternalServerError}
+		}
+		amount = amount.Sub(spent)
+	}
+	return nil
+}
+
+//ExpireBonuses writes off remaining amounts of at most limit grants, expired at now, in order of expiration.
+//Each written off grant is stored in operation log of user with the time of write off, not of expiration,
+//so statements and snapshots of closed periods are never changed. Returns number of expired grants
+func (ba *BillingApp) ExpireBonuses(ctx context.Context, now time.Time, limit int64) (int64, error) {
+	if limit <= 0 {
+		ba.logger.Error("ExpireBonuses, %s", ErrLimitParamIsLessThanMin.Error())
+		return 0, &AppError{ErrLimitParamIsLessThanMin, http.StatusBadRequest}
+	}
+
+	tx, err := ba.storage.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted, ReadOnly: false})
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("ExpireBonuses, %s, err %v", ctxErr.Error(), err)
+			return 0, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("ExpireBonuses, %s, err %v", ErrDBTransactionBeginFailed.Error(), err)
+		return 0, &AppError{ErrDBTransactionBeginFailed, http.StatusInternalServerError}
+	}
+
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("ExpireBonuses, %s, err %v", ctxErr.Error(), err)
+			}
+
+			ba.logger.Error("ExpireBonuses, %s, err %v", ErrDBTransactionRollbackFailed.Error(), err)
+		}
+	}()
+
+	err = tx.Operations().LockForInsert(ctx)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("ExpireBonuses, %s, err %v", ctxErr.Error(), err)
+			return 0, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("ExpireBonuses, %s, err %v", ErrDBFailedToLockOperationTableForInsert.Error(), err)
+		return 0, &AppError{ErrDBFailedToLockOperationTableForInsert, http.StatusInternalServerError}
+	}
+
+	grants, err := tx.Bonuses().ListExpired(ctx, now, limit, RowLockForUpdate)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("ExpireBonuses, %s, err %v", ctxErr.Error(), err)
+			return 0, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("ExpireBonuses, %s, err %v", ErrDBFailedToFetchBonusRows.Error(), err)
+		return 0, &AppError{ErrDBFailedToFetchBonusRows, http.StatusInternalServerError}
+	}
+
+	runAt := time.Now()
+	operations := make([]Operation, 0, len(grants))
+	for _, grant := range grants {
+		err = tx.Bonuses().SetRemaining(ctx, grant.Id, decimal.Zero)
+		if err != nil {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("ExpireBonuses, %s, err %v", ctxErr.Error(), err)
+				return 0, &AppError{ctxErr, http.StatusBadRequest}
+			}
+
+			ba.logger.Error("ExpireBonuses, %s, err %v", ErrDBFailedToUpdateBonusRow.Error(), err)
+			return 0, &AppError{ErrDBFailedToUpdateBonusRow, http.StatusInternalServerError}
+		}
+
+		expired := grant.Remaining.Neg()
+		comment := fmt.Sprintf(CommentBonusExpirationWithDates, grant.GrantedAt.UTC().Format(dateLayout),
+			grant.ExpiresAt.UTC().Format(time.RFC3339))
+		operations = append(operations, Operation{
+			UserId:           grant.UserId,
+			Comment:          comment,
+			Amount:           decimal.Zero,
+			Date:             runAt,
+			IdempotencyToken: bonusExpirationToken(grant.Id),
+			BonusAmount:      &expired,
+		})
+	}
+
+	err = tx.Operations().Insert(ctx, operations...)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("ExpireBonuses, %s, err %v", ctxErr.Error(), err)
+			return 0, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("ExpireBonuses, %s, err %v", ErrFailedToInsertOperationRow.Error(), err)
+		return 0, &AppError{ErrFailedToInsertOperationRow, http.StatusInternalServerError}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("ExpireBonuses, %s, err %v", ctxErr.Error(), err)
+			return 0, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("ExpireBonuses, %s, err %v", ErrDBTransactionCommitFailed.Error(), err)
+		return 0, &AppError{ErrDBTransactionCommitFailed, http.StatusInternalServerError}
+	}
+
+	return int64(len(grants)), nil
+}
+
+//bonusExpirationToken returns idempotency token of expiration operation of grant, grant expires once
+func bonusExpirationToken(grantId int64) string {
+	return fmt.Sprintf("bonus-expiration-%d", grantId)
+}
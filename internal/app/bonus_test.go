@@ -0,0 +1,210 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBillingApp_Bonuses(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	newApp := func(t *testing.T, policy string, balance string) *BillingApp {
+		app := newMemoryStorageApp(t, NewMemoryStorage())
+		cfg := app.GetConfig()
+		cfg.BonusWithdrawPolicy = policy
+		require.NoError(t, app.SetConfig(&cfg))
+
+		for _, userId := range []int64{1, 2} {
+			_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: userId, Name: "user",
+				Amount: balance, IdempotencyToken: fmt.Sprintf("c%d", userId)})
+			require.NoError(t, err)
+		}
+		return app
+	}
+
+	grant := func(t *testing.T, app *BillingApp, userId int64, amount string, expiresIn time.Duration, token string) {
+		res, err := app.GrantBonus(ctx, &BonusGrantRequest{UserId: userId, Amount: amount,
+			ExpiresAt: now.Add(expiresIn), Purpose: "promo", IdempotencyToken: token})
+		require.NoError(t, err)
+		require.Equal(t, MsgBonusGrantDone, res.State)
+	}
+
+	balancesOf := func(t *testing.T, app *BillingApp, userId int64) (string, string) {
+		balance, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: userId})
+		require.NoError(t, err)
+		bonuses, err := app.GetUserBonuses(ctx, &BonusBalanceRequest{UserId: userId})
+		require.NoError(t, err)
+		return balance.Balance, bonuses.BonusBalance
+	}
+
+	t.Run("positive path, bonus first policy spends grants in order of expiration", func(t *testing.T) {
+		app := newApp(t, BonusPolicyBonusFirst, "1000")
+		grant(t, app, 1, "50", 2*time.Hour, "g1")
+		grant(t, app, 1, "100", time.Hour, "g2")
+
+		res, err := app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Purpose: "ads", Amount: "120",
+			IdempotencyToken: "1"})
+		require.NoError(t, err)
+		require.NotNil(t, res.BonusAmount)
+		assert.Equal(t, "120", res.BonusAmount.String())
+
+		balance, bonusBalance := balancesOf(t, app, 1)
+		assert.Equal(t, "1000", balance, "real balance must not be spent")
+		assert.Equal(t, "30", bonusBalance)
+
+		bonuses, err := app.GetUserBonuses(ctx, &BonusBalanceRequest{UserId: 1})
+		require.NoError(t, err)
+		require.Len(t, bonuses.Grants, 1, "earlier expiring grant must be spent first")
+		assert.Equal(t, "g1", bonuses.Grants[0].IdempotencyToken)
+		assert.Equal(t, "30", bonuses.Grants[0].Remaining.String())
+
+		res, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Purpose: "ads", Amount: "100",
+			IdempotencyToken: "2"})
+		require.NoError(t, err)
+		require.NotNil(t, res.BonusAmount)
+		assert.Equal(t, "30", res.BonusAmount.String())
+
+		balance, bonusBalance = balancesOf(t, app, 1)
+		assert.Equal(t, "930", balance)
+		assert.Equal(t, "0", bonusBalance)
+
+		ops, err := app.GetOperationsByIdempotencyToken(ctx, &IdempotencyLookupRequest{IdempotencyToken: "2"})
+		require.NoError(t, err)
+		require.Len(t, ops, 1)
+		assert.Equal(t, "-70", ops[0].Amount.String())
+		require.NotNil(t, ops[0].BonusAmount)
+		assert.Equal(t, "-30", ops[0].BonusAmount.String())
+	})
+
+	t.Run("positive path, real first policy spends bonus after real balance", func(t *testing.T) {
+		app := newApp(t, BonusPolicyRealFirst, "100")
+		grant(t, app, 1, "100", time.Hour, "g1")
+
+		res, err := app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Amount: "60", IdempotencyToken: "1"})
+		require.NoError(t, err)
+		assert.Nil(t, res.BonusAmount)
+
+		res, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Amount: "90", IdempotencyToken: "2"})
+		require.NoError(t, err)
+		require.NotNil(t, res.BonusAmount)
+		assert.Equal(t, "50", res.BonusAmount.String())
+
+		balance, bonusBalance := balancesOf(t, app, 1)
+		assert.Equal(t, "0", balance)
+		assert.Equal(t, "50", bonusBalance)
+	})
+
+	t.Run("positive path, bonus is not spent by real only policy and adjustments", func(t *testing.T) {
+		app := newApp(t, BonusPolicyRealOnly, "100")
+		grant(t, app, 1, "100", time.Hour, "g1")
+
+		_, err := app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Amount: "150", IdempotencyToken: "1"})
+		assert.ErrorIs(t, err, ErrUserDoesNotHaveEnoughMoney)
+
+		cfg := app.GetConfig()
+		cfg.BonusWithdrawPolicy = BonusPolicyBonusFirst
+		require.NoError(t, app.SetConfig(&cfg))
+
+		res, err := app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Amount: "10", IdempotencyToken: "2",
+			WithoutBonus: true})
+		require.NoError(t, err)
+		assert.Nil(t, res.BonusAmount)
+
+		balance, bonusBalance := balancesOf(t, app, 1)
+		assert.Equal(t, "90", balance)
+		assert.Equal(t, "100", bonusBalance)
+	})
+
+	t.Run("negative path, bonus may not be transferred or overspent", func(t *testing.T) {
+		app := newApp(t, BonusPolicyBonusFirst, "10")
+		grant(t, app, 1, "100", time.Hour, "g1")
+
+		_, err := app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "50",
+			IdempotencyToken: "1"})
+		assert.ErrorIs(t, err, ErrUserDoesNotHaveEnoughMoney, "transfer must use real balance only")
+
+		_, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Amount: "111", IdempotencyToken: "2"})
+		assert.ErrorIs(t, err, ErrUserDoesNotHaveEnoughMoney)
+		assert.Equal(t, "100", GetErrorDetails(err)["bonus_balance"])
+
+		balance, bonusBalance := balancesOf(t, app, 1)
+		assert.Equal(t, "10", balance)
+		assert.Equal(t, "100", bonusBalance, "failed withdrawal must not spend bonus")
+	})
+
+	t.Run("positive path, expired grants are written off in order of expiration", func(t *testing.T) {
+		app := newApp(t, BonusPolicyBonusFirst, "10")
+		grant(t, app, 1, "100", time.Hour, "g1")
+		grant(t, app, 2, "50", 2*time.Hour, "g2")
+		grant(t, app, 1, "70", 3*time.Hour, "g3")
+
+		_, err := app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Amount: "40", IdempotencyToken: "1"})
+		require.NoError(t, err)
+
+		expiredAt := time.Now()
+		expired, err := app.ExpireBonuses(ctx, now.Add(150*time.Minute), 1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), expired)
+
+		_, bonusBalance := balancesOf(t, app, 2)
+		assert.Equal(t, "50", bonusBalance, "grant of the latter expiration must be left by limit")
+
+		expired, err = app.ExpireBonuses(ctx, now.Add(150*time.Minute), 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), expired)
+
+		expired, err = app.ExpireBonuses(ctx, now.Add(150*time.Minute), 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), expired, "grant must expire once")
+
+		balance, bonusBalance := balancesOf(t, app, 1)
+		assert.Equal(t, "10", balance, "expiration must not change real balance")
+		assert.Equal(t, "70", bonusBalance)
+
+		ops, err := app.GetOperationsByIdempotencyToken(ctx, &IdempotencyLookupRequest{
+			IdempotencyToken: bonusExpirationToken(1)})
+		require.NoError(t, err)
+		require.Len(t, ops, 1, "expiration must be stored in operation log")
+		assert.Equal(t, int64(1), ops[0].UserId)
+		assert.Equal(t, "0", ops[0].Amount.String())
+		require.NotNil(t, ops[0].BonusAmount)
+		assert.Equal(t, "-60", ops[0].BonusAmount.String(), "remaining amount must be written off")
+		assert.Equal(t, fmt.Sprintf(CommentBonusExpirationWithDates, now.UTC().Format(dateLayout),
+			now.Add(time.Hour).UTC().Format(time.RFC3339)), ops[0].Comment)
+		assert.False(t, ops[0].Date.Before(expiredAt), "expiration must be dated by the time of write off")
+		assert.True(t, ops[0].Date.Before(now.Add(time.Hour)), "expiration must not be dated by expiration of grant")
+	})
+
+	t.Run("negative path, bad grants", func(t *testing.T) {
+		app := newApp(t, BonusPolicyBonusFirst, "10")
+		grant(t, app, 1, "100", time.Hour, "g1")
+
+		res, err := app.GrantBonus(ctx, &BonusGrantRequest{UserId: 1, Amount: "100", ExpiresAt: now.Add(time.Hour),
+			IdempotencyToken: "g1"})
+		require.NoError(t, err)
+		assert.Equal(t, OperationTokenIsAlreadyUsed, res.State)
+
+		_, err = app.GrantBonus(ctx, &BonusGrantRequest{UserId: 1, Amount: "100", ExpiresAt: now.Add(-time.Hour),
+			IdempotencyToken: "g2"})
+		assert.ErrorIs(t, err, ErrBonusExpirationIsNotInFuture)
+
+		_, err = app.GrantBonus(ctx, &BonusGrantRequest{UserId: 100500, Amount: "100", ExpiresAt: now.Add(time.Hour),
+			IdempotencyToken: "g3"})
+		assert.ErrorIs(t, err, ErrUserDoesNotExist)
+
+		_, err = app.GetUserBonuses(ctx, &BonusBalanceRequest{UserId: 100500})
+		assert.ErrorIs(t, err, ErrUserDoesNotExist)
+
+		_, bonusBalance := balancesOf(t, app, 1)
+		assert.Equal(t, "100", bonusBalance, "replayed grant must not be stored twice")
+
+		cfg := app.GetConfig()
+		cfg.BonusWithdrawPolicy = "random"
+		assert.Error(t, cfg.Validate(), "unknown bonus policy must be rejected")
+	})
+}
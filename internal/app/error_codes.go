@@ -0,0 +1,196 @@
+package app
+
+import (
+	"errors"
+)
+
+// ErrorCode is a stable machine-readable error identifier, returned to api clients
+type ErrorCode string
+
+const (
+	CodeUnknownError ErrorCode = "UNKNOWN_ERROR"
+
+	CodeIdempotencyTokenCheckFailed ErrorCode = "IDEMPOTENCY_TOKEN_CHECK_FAILED"
+	CodeIdempotencyTokenIsEmpty     ErrorCode = "IDEMPOTENCY_TOKEN_EMPTY"
+	CodeOperationInProgress         ErrorCode = "OPERATION_IN_PROGRESS"
+	CodeCacheLookupFailed           ErrorCode = "CACHE_LOOKUP_FAILED"
+	CodeCacheWriteFailed            ErrorCode = "CACHE_WRITE_FAILED"
+	CodeParamsStructIsNil           ErrorCode = "PARAMS_MISSING"
+
+	CodeAmountIsLessThanMin           ErrorCode = "AMOUNT_LESS_THAN_MIN"
+	CodeAmountExceedsMaximumValue     ErrorCode = "AMOUNT_EXCEEDS_MAX"
+	CodeAmountHasExcessiveFracDigits  ErrorCode = "AMOUNT_EXCESSIVE_FRACTIONAL_DIGITS"
+	CodeAmountHasExcessiveWholeDigits ErrorCode = "AMOUNT_EXCESSIVE_WHOLE_DIGITS"
+	CodeAmountIsNegative              ErrorCode = "AMOUNT_NEGATIVE"
+	CodeAmountIsNotDecimal            ErrorCode = "AMOUNT_NOT_DECIMAL"
+	CodeUserNotFound                  ErrorCode = "USER_NOT_FOUND"
+	CodeInsufficientFunds             ErrorCode = "INSUFFICIENT_FUNDS"
+	CodeSenderNotFound                ErrorCode = "SENDER_NOT_FOUND"
+	CodeReceiverNotFound              ErrorCode = "RECEIVER_NOT_FOUND"
+	CodeSenderAndReceiverNotFound     ErrorCode = "SENDER_AND_RECEIVER_NOT_FOUND"
+	CodeSenderIsReceiver              ErrorCode = "SENDER_IS_RECEIVER"
+	CodeCurrencyExchangeFailed        ErrorCode = "CURRENCY_EXCHANGE_FAILED"
+	CodeCurrencyNotFound              ErrorCode = "CURRENCY_NOT_FOUND"
+	CodeTooManyCurrencies             ErrorCode = "TOO_MANY_CURRENCIES"
+	CodeQuoteNotFound                 ErrorCode = "QUOTE_NOT_FOUND"
+	CodeQuoteExpired                  ErrorCode = "QUOTE_EXPIRED"
+	CodeQuoteAlreadyUsed              ErrorCode = "QUOTE_ALREADY_USED"
+	CodeQuoteAmountMismatch           ErrorCode = "QUOTE_AMOUNT_MISMATCH"
+	CodeQuoteCurrencyIsNotBase        ErrorCode = "QUOTE_CURRENCY_NOT_BASE"
+	CodeRatesAreStale                 ErrorCode = "RATES_STALE"
+	CodeRevenueAccountNotFound        ErrorCode = "REVENUE_ACCOUNT_NOT_FOUND"
+	CodeFeesAccountNotFound           ErrorCode = "FEES_ACCOUNT_NOT_FOUND"
+	CodeBonusExpirationIsNotInFuture  ErrorCode = "BONUS_EXPIRATION_NOT_IN_FUTURE"
+	CodeDBTransactionBeginFailed      ErrorCode = "DB_TRANSACTION_BEGIN_FAILED"
+	CodeDBTransactionRollbackFailed   ErrorCode = "DB_TRANSACTION_ROLLBACK_FAILED"
+	CodeDBTransactionCommitFailed     ErrorCode = "DB_TRANSACTION_COMMIT_FAILED"
+	CodeDBUserUpdateFailed            ErrorCode = "DB_USER_UPDATE_FAILED"
+	CodeDBUserCreateFailed            ErrorCode = "DB_USER_CREATE_FAILED"
+	CodeDBUserTableLockFailed         ErrorCode = "DB_USER_TABLE_LOCK_FAILED"
+	CodeDBOperationFetchFailed        ErrorCode = "DB_OPERATION_FETCH_FAILED"
+	CodeDBOperationInsertFailed       ErrorCode = "DB_OPERATION_INSERT_FAILED"
+	CodeDBOperationsFetchFailed       ErrorCode = "DB_OPERATIONS_FETCH_FAILED"
+	CodeDBOperationCountFetchFailed   ErrorCode = "DB_OPERATION_COUNT_FETCH_FAILED"
+	CodeDBOperationTableLockFailed    ErrorCode = "DB_OPERATION_TABLE_LOCK_FAILED"
+	CodeDBUserFetchFailed             ErrorCode = "DB_USER_FETCH_FAILED"
+	CodeDBUsersFetchFailed            ErrorCode = "DB_USERS_FETCH_FAILED"
+	CodeDBSnapshotFetchFailed         ErrorCode = "DB_BALANCE_SNAPSHOT_FETCH_FAILED"
+	CodeDBSnapshotInsertFailed        ErrorCode = "DB_BALANCE_SNAPSHOT_INSERT_FAILED"
+	CodeDBQuoteInsertFailed           ErrorCode = "DB_QUOTE_INSERT_FAILED"
+	CodeDBQuoteFetchFailed            ErrorCode = "DB_QUOTE_FETCH_FAILED"
+	CodeDBQuoteUpdateFailed           ErrorCode = "DB_QUOTE_UPDATE_FAILED"
+	CodeDBBonusInsertFailed           ErrorCode = "DB_BONUS_GRANT_INSERT_FAILED"
+	CodeDBBonusFetchFailed            ErrorCode = "DB_BONUS_GRANT_FETCH_FAILED"
+	CodeDBBonusUpdateFailed           ErrorCode = "DB_BONUS_GRANT_UPDATE_FAILED"
+	CodePageParamIsNegative           ErrorCode = "PAGE_NEGATIVE"
+	CodeLimitParamIsLessThanMin       ErrorCode = "LIMIT_LESS_THAN_MIN"
+	CodeBadOrderField                 ErrorCode = "BAD_ORDER_FIELD"
+	CodeBadOrderDirection             ErrorCode = "BAD_ORDER_DIRECTION"
+	CodeBadDateFormat                 ErrorCode = "BAD_DATE_FORMAT"
+	CodePeriodStartIsAfterEnd         ErrorCode = "PERIOD_START_AFTER_END"
+	CodeDateIsInFuture                ErrorCode = "DATE_IN_FUTURE"
+	CodeBadConsistencyToken           ErrorCode = "BAD_CONSISTENCY_TOKEN"
+	CodeRequestCancelled              ErrorCode = "REQUEST_CANCELLED"
+	CodeRequestDeadlineExceeded       ErrorCode = "REQUEST_DEADLINE_EXCEEDED"
+)
+
+// ErrorCatalogueEntry describes an error, known to api clients
+type ErrorCatalogueEntry struct {
+	Err   error
+	Code  ErrorCode
+	Title string
+}
+
+var errorCatalogue = []ErrorCatalogueEntry{
+	{ErrFailedToCheckIdempotencyTokenExistenceInDB, CodeIdempotencyTokenCheckFailed, "Idempotency token check failed"},
+	{ErrIdempotencyTokenIsEmpty, CodeIdempotencyTokenIsEmpty, "Idempotency token is empty"},
+	{ErrOperationIsInProgress, CodeOperationInProgress, "Operation with the same token is in progress"},
+	{ErrCacheLookupFailed, CodeCacheLookupFailed, "Cache lookup failed"},
+	{ErrCacheWriteFailed, CodeCacheWriteFailed, "Cache write failed"},
+	{ErrParamsStructIsNil, CodeParamsStructIsNil, "Request params are missing"},
+
+	{ErrAmountValueIsLessThanMin, CodeAmountIsLessThanMin, "Amount is less than minimum"},
+	{ErrAmountToStoreExceedsMaximumValue, CodeAmountExceedsMaximumValue, "Amount exceeds maximum"},
+	{ErrAmountHasExcessiveFractionalDigits, CodeAmountHasExcessiveFracDigits, "Amount has excessive fractional digits"},
+	{ErrAmountHasExcessiveWholeDigits, CodeAmountHasExcessiveWholeDigits, "Amount has excessive whole digits"},
+	{ErrAmountValueIsNegative, CodeAmountIsNegative, "Amount is negative"},
+	{ErrFailedToCastAmountToDecimal, CodeAmountIsNotDecimal, "Amount is not a decimal number"},
+
+	{ErrUserDoesNotExist, CodeUserNotFound, "User not found"},
+	{ErrUserDoesNotHaveEnoughMoney, CodeInsufficientFunds, "Insufficient funds"},
+	{ErrMoneySenderDoesNotExist, CodeSenderNotFound, "Sender not found"},
+	{ErrMoneyReceiverDoesNotExist, CodeReceiverNotFound, "Receiver not found"},
+	{ErrMoneySenderAndReceiverDoNotExist, CodeSenderAndReceiverNotFound, "Sender and receiver not found"},
+	{ErrSenderIdIsEqualToReceiverId, CodeSenderIsReceiver, "Sender is receiver"},
+
+	{ErrCurrencyExchangeFailed, CodeCurrencyExchangeFailed, "Currency exchange failed"},
+	{ErrCurrencyDoesNotExist, CodeCurrencyNotFound, "Currency not found"},
+	{ErrTooManyCurrencies, CodeTooManyCurrencies, "Too many currencies"},
+	{ErrQuoteDoesNotExist, CodeQuoteNotFound, "Quote not found"},
+	{ErrQuoteIsExpired, CodeQuoteExpired, "Quote is expired"},
+	{ErrQuoteIsAlreadyUsed, CodeQuoteAlreadyUsed, "Quote is already used"},
+	{ErrQuoteAmountMismatch, CodeQuoteAmountMismatch, "Amount does not match quote"},
+	{ErrQuoteCurrencyIsNotBase, CodeQuoteCurrencyIsNotBase, "Quote currencies do not include base currency"},
+	{ErrRatesAreStale, CodeRatesAreStale, "Exchange rates are outdated"},
+	{ErrRevenueAccountDoesNotExist, CodeRevenueAccountNotFound, "Revenue account not found"},
+	{ErrFeesAccountDoesNotExist, CodeFeesAccountNotFound, "Fees account not found"},
+	{ErrBonusExpirationIsNotInFuture, CodeBonusExpirationIsNotInFuture, "Bonus expiration is not in the future"},
+
+	{ErrDBTransactionBeginFailed, CodeDBTransactionBeginFailed, "Database transaction begin failed"},
+	{ErrDBTransactionRollbackFailed, CodeDBTransactionRollbackFailed, "Database transaction rollback failed"},
+	{ErrDBTransactionCommitFailed, CodeDBTransactionCommitFailed, "Database transaction commit failed"},
+	{ErrDBFailedToUpdateUserRow, CodeDBUserUpdateFailed, "User update failed"},
+	{ErrDBFailedToCreateUserRow, CodeDBUserCreateFailed, "User creation failed"},
+	{ErrDBFailedToLockUserTableForInsert, CodeDBUserTableLockFailed, "User table lock failed"},
+	{ErrFailedToFetchOperationRow, CodeDBOperationFetchFailed, "Operation fetch failed"},
+	{ErrFailedToInsertOperationRow, CodeDBOperationInsertFailed, "Operation insert failed"},
+	{ErrDBFailedToFetchOperationRows, CodeDBOperationsFetchFailed, "Operations fetch failed"},
+	{ErrDBFailedToFetchOperationCountRow, CodeDBOperationCountFetchFailed, "Operation count fetch failed"},
+	{ErrDBFailedToLockOperationTableForInsert, CodeDBOperationTableLockFailed, "Operation table lock failed"},
+	{ErrDBFailedToFetchUserRow, CodeDBUserFetchFailed, "User fetch failed"},
+	{ErrDBFailedToFetchUsersRows, CodeDBUsersFetchFailed, "Users fetch failed"},
+	{ErrDBFailedToFetchSnapshotRows, CodeDBSnapshotFetchFailed, "Balance snapshot fetch failed"},
+	{ErrDBFailedToInsertSnapshotRows, CodeDBSnapshotInsertFailed, "Balance snapshot insert failed"},
+	{ErrDBFailedToInsertQuoteRow, CodeDBQuoteInsertFailed, "Quote insert failed"},
+	{ErrDBFailedToFetchQuoteRow, CodeDBQuoteFetchFailed, "Quote fetch failed"},
+	{ErrDBFailedToUpdateQuoteRow, CodeDBQuoteUpdateFailed, "Quote update failed"},
+	{ErrDBFailedToInsertBonusRow, CodeDBBonusInsertFailed, "Bonus grant insert failed"},
+	{ErrDBFailedToFetchBonusRows, CodeDBBonusFetchFailed, "Bonus grants fetch failed"},
+	{ErrDBFailedToUpdateBonusRow, CodeDBBonusUpdateFailed, "Bonus grant update failed"},
+
+	{ErrPageParamIsLessThanZero, CodePageParamIsNegative, "Page is negative"},
+	{ErrLimitParamIsLessThanMin, CodeLimitParamIsLessThanMin, "Limit is less than minimum"},
+	{ErrBadOrderFieldParam, CodeBadOrderField, "Bad order field"},
+	{ErrBadOrderDirectionParam, CodeBadOrderDirection, "Bad order direction"},
+	{ErrBadDateFormat, CodeBadDateFormat, "Bad date format"},
+	{ErrPeriodStartIsAfterEnd, CodePeriodStartIsAfterEnd, "Period start is after period end"},
+	{ErrDateIsInFuture, CodeDateIsInFuture, "Date is in the future"},
+	{ErrBadConsistencyToken, CodeBadConsistencyToken, "Bad consistency token"},
+	{ErrContextCancelled, CodeRequestCancelled, "Request cancelled"},
+	{ErrContextDeadlineExceeded, CodeRequestDeadlineExceeded, "Request deadline exceeded"},
+}
+
+// ErrorCatalogue returns all errors of app package, known to api clients
+func ErrorCatalogue() []ErrorCatalogueEntry {
+	catalogue := make([]ErrorCatalogueEntry, len(errorCatalogue))
+	copy(catalogue, errorCatalogue)
+	return catalogue
+}
+
+// LookupErrorCode returns catalogue entry of given error, ok is false if error is not in catalogue
+func LookupErrorCode(err error) (entry ErrorCatalogueEntry, ok bool) {
+	for _, e := range errorCatalogue {
+		if errors.Is(err, e.Err) {
+			return e, true
+		}
+	}
+	return ErrorCatalogueEntry{Code: CodeUnknownError, Title: "Unknown error"}, false
+}
+
+// ErrorWithDetails wraps error with additional machine-readable data (e.g. current balance), returned to api clients
+type ErrorWithDetails struct {
+	Err     error
+	Details map[string]interface{}
+}
+
+func (ed *ErrorWithDetails) Error() string {
+	return ed.Err.Error()
+}
+
+func (ed *ErrorWithDetails) Unwrap() error {
+	return ed.Err
+}
+
+// WithDetails attaches details to error
+func WithDetails(err error, details map[string]interface{}) error {
+	return &ErrorWithDetails{Err: err, Details: details}
+}
+
+// GetErrorDetails returns details attached to error or nil
+func GetErrorDetails(err error) map[string]interface{} {
+	var ed *ErrorWithDetails
+	if errors.As(err, &ed) {
+		return ed.Details
+	}
+	return nil
+}
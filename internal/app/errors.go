@@ -22,6 +22,7 @@ var (
 	ErrFailedToCheckIdempotencyTokenExistenceInDB = errors.New("failed to check if idempotency token is already used")
 
 	ErrIdempotencyTokenIsEmpty = errors.New("request must include \"idempotency_token\" json field")
+	ErrOperationIsInProgress   = errors.New("operation with specified token is in progress")
 
 	ErrCacheLookupFailed = errors.New("failed to get data from cache")
 	ErrCacheWriteFailed  = errors.New("failed to write data to cache")
@@ -43,6 +44,19 @@ var (
 	ErrCurrencyExchangeFailed      = errors.New("failed to get user balance in specified currency")
 	ErrSenderIdIsEqualToReceiverId = errors.New("sender user and receiver user must have different identifiers")
 	ErrCurrencyDoesNotExist        = errors.New("currency with provided name was not found")
+	ErrTooManyCurrencies           = errors.New("number of requested currencies exceeds maximum")
+
+	ErrQuoteDoesNotExist      = errors.New("quote with specified id does not exist")
+	ErrQuoteIsExpired         = errors.New("quote with specified id is expired")
+	ErrQuoteIsAlreadyUsed     = errors.New("quote with specified id is already used by another operation")
+	ErrQuoteAmountMismatch    = errors.New("amount of operation does not match amount of quote")
+	ErrQuoteCurrencyIsNotBase = errors.New("one of quote currencies must be base currency")
+	ErrRatesAreStale          = errors.New("exchange rates are outdated, quote may not be created")
+
+	ErrRevenueAccountDoesNotExist = errors.New("revenue account user was not found")
+	ErrFeesAccountDoesNotExist    = errors.New("fees account user was not found")
+
+	ErrBonusExpirationIsNotInFuture = errors.New("bonus expiration date must be in the future")
 
 	ErrDBTransactionBeginFailed    = fmt.Errorf("failed to begin transaction")
 	ErrDBTransactionRollbackFailed = fmt.Errorf("failed to rollback transaction")
@@ -63,10 +77,25 @@ var (
 
 	ErrDBFailedToFetchUsersRows = fmt.Errorf("failed to fetch users rows from database")
 
+	ErrDBFailedToFetchSnapshotRows  = fmt.Errorf("failed to fetch balance snapshot rows from database")
+	ErrDBFailedToInsertSnapshotRows = fmt.Errorf("failed to insert balance snapshot rows to database")
+
+	ErrDBFailedToInsertQuoteRow = fmt.Errorf("failed to insert quote row to database")
+	ErrDBFailedToFetchQuoteRow  = fmt.Errorf("failed to fetch quote row from database")
+	ErrDBFailedToUpdateQuoteRow = fmt.Errorf("failed to update quote row to database")
+
+	ErrDBFailedToInsertBonusRow = fmt.Errorf("failed to insert bonus grant row to database")
+	ErrDBFailedToFetchBonusRows = fmt.Errorf("failed to fetch bonus grant rows from database")
+	ErrDBFailedToUpdateBonusRow = fmt.Errorf("failed to update bonus grant row to database")
+
 	ErrPageParamIsLessThanZero = errors.New("given param page is negative")
 	ErrLimitParamIsLessThanMin = errors.New("given param limit is less than min of -1")
 	ErrBadOrderFieldParam      = errors.New("given param order field has bad value")
 	ErrBadOrderDirectionParam  = errors.New("given param order direction has bad value")
+	ErrBadDateFormat           = errors.New("date must be in format YYYY-MM-DD")
+	ErrPeriodStartIsAfterEnd   = errors.New("period start date is after period end date")
+	ErrDateIsInFuture          = errors.New("given date is in the future")
+	ErrBadConsistencyToken     = errors.New("consistency token must be a token, returned by write operation")
 	ErrContextCancelled        = fmt.Errorf("context canceled")
 	ErrContextDeadlineExceeded = fmt.Errorf("context deadline exceeded")
 )
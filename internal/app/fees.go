@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"job-backend-trainee-assignment/internal/fees"
+	"net/http"
+	"time"
+)
+
+var noFees, _ = fees.NewSchedule(nil, nil)
+
+//operationFee returns fee of operation of user in base currency, rounded to its minor units.
+//Fees account does not pay fees to itself
+func (cfg *Config) operationFee(operationType fees.OperationType, userId int64, amount decimal.Decimal) decimal.Decimal {
+	if userId == cfg.FeesAccountId {
+		return decimal.Zero
+	}
+	return cfg.currencies().Get(exchanger.RUBCode).Round(cfg.fees().Fee(operationType, userId, amount))
+}
+
+//creditFeesAccount credits fee to fees account in transaction and returns operation of fees account,
+//which must be inserted with operations of fee payer
+func (ba *BillingApp) creditFeesAccount(ctx context.Context, method string, tx IStorageTx, cfg *Config,
+	fee decimal.Decimal, payerName string, date time.Time, token string) (*Operation, error) {
+	feesUser, err := tx.Users().Get(ctx, cfg.FeesAccountId, RowLockForNoKeyUpdate)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("%s, %s, err %v", method, ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		if err == sql.ErrNoRows {
+			ba.logger.Error("%s, %s, user %d", method, ErrFeesAccountDoesNotExist.Error(), cfg.FeesAccountId)
+			return nil, &AppError{ErrFeesAccountDoesNotExist, http.StatusInternalServerError}
+		}
+
+		ba.logger.Error("%s, %s, err %v", method, ErrDBFailedToFetchUserRow.Error(), err)
+		return nil, &AppError{ErrDBFailedToFetchUserRow, http.StatusInternalServerError}
+	}
+
+	maxPossibleDecimal := decimal.New(1, int32(cfg.MaxDecimalWholeDigitsNum))
+	if feesUser.Balance.Add(fee).GreaterThanOrEqual(maxPossibleDecimal) {
+		ba.logger.Error("%s, %s, fees account", method, ErrAmountToStoreExceedsMaximumValue.Error())
+		return nil, &AppError{ErrAmountToStoreExceedsMaximumValue, http.StatusInternalServerError}
+	}
+
+	err = tx.Users().AddToBalance(ctx, cfg.FeesAccountId, fee)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("%s, %s, err %v", method, ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("%s, %s, err %v", method, ErrDBFailedToUpdateUserRow.Error(), err)
+		return nil, &AppError{ErrDBFailedToUpdateUserRow, http.StatusInternalServerError}
+	}
+
+	return &Operation{
+		UserId:           cfg.FeesAccountId,
+		Comment:          fmt.Sprintf(CommentFeeOfUserOperation, payerName),
+		Amount:           fee,
+		Date:             date,
+		IdempotencyToken: token,
+	}, nil
+}
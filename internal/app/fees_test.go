@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/fees"
+	"testing"
+)
+
+func TestBillingApp_Fees(t *testing.T) {
+	ctx := context.Background()
+
+	schedule, err := fees.NewSchedule([]fees.Rule{
+		{OperationType: fees.OperationTransfer, Tier: "premium"},
+		{OperationType: fees.OperationTransfer, Percent: decimal.NewFromInt(1), MinFee: decimal.NewFromInt(5),
+			MaxFee: decimal.NewFromInt(50)},
+		{OperationType: fees.OperationWithdraw, Flat: decimal.NewFromInt(30)},
+	}, map[string][]int64{"premium": {3}})
+	require.NoError(t, err)
+
+	newApp := func(t *testing.T, feesAccountId int64) *BillingApp {
+		app := newMemoryStorageApp(t, NewMemoryStorage())
+		cfg := app.GetConfig()
+		cfg.Fees = schedule
+		cfg.FeesAccountId = feesAccountId
+		require.NoError(t, app.SetConfig(&cfg))
+
+		for _, userId := range []int64{1, 2, 3, 9} {
+			_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: userId, Name: "user",
+				Amount: "1000", IdempotencyToken: fmt.Sprintf("c%d", userId)})
+			require.NoError(t, err)
+		}
+		return app
+	}
+
+	balanceOf := func(t *testing.T, app *BillingApp, userId int64) string {
+		balance, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: userId})
+		require.NoError(t, err)
+		return balance.Balance
+	}
+
+	t.Run("positive path, fees are booked to fees account", func(t *testing.T) {
+		app := newApp(t, 9)
+
+		res, err := app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "100",
+			IdempotencyToken: "1"})
+		require.NoError(t, err)
+		require.NotNil(t, res.Fee)
+		assert.Equal(t, "5", res.Fee.String(), "percent fee must be limited by min fee")
+
+		res, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Purpose: "ads", Amount: "200",
+			IdempotencyToken: "2"})
+		require.NoError(t, err)
+		require.NotNil(t, res.Fee)
+		assert.Equal(t, "30", res.Fee.String())
+
+		assert.Equal(t, "665", balanceOf(t, app, 1))
+		assert.Equal(t, "1100", balanceOf(t, app, 2), "receiver must get full amount")
+		assert.Equal(t, "1035", balanceOf(t, app, 9))
+
+		ops, err := app.GetOperationsByIdempotencyToken(ctx, &IdempotencyLookupRequest{IdempotencyToken: "2"})
+		require.NoError(t, err)
+		require.Len(t, ops, 3, "withdrawal, its fee and fee of fees account must be stored")
+		assert.Equal(t, "fee of payment to service, ads", ops[1].Comment)
+		assert.Equal(t, "-30", ops[1].Amount.String())
+		assert.Equal(t, int64(9), ops[2].UserId)
+		assert.Equal(t, "30", ops[2].Amount.String())
+	})
+
+	t.Run("positive path, no fee by tier rule and for fees account", func(t *testing.T) {
+		app := newApp(t, 9)
+
+		res, err := app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 3, ReceiverId: 2, Amount: "100",
+			IdempotencyToken: "1"})
+		require.NoError(t, err)
+		assert.Nil(t, res.Fee, "first matching rule of premium tier has no fee")
+
+		res, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 9, Amount: "100", IdempotencyToken: "2"})
+		require.NoError(t, err)
+		assert.Nil(t, res.Fee, "fees account must not pay fees")
+
+		res, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Amount: "100", IdempotencyToken: "3",
+			WithoutFee: true})
+		require.NoError(t, err)
+		assert.Nil(t, res.Fee)
+		assert.Equal(t, "900", balanceOf(t, app, 1))
+	})
+
+	t.Run("negative path, fee is checked with balance", func(t *testing.T) {
+		app := newApp(t, 9)
+
+		_, err := app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Amount: "980", IdempotencyToken: "1"})
+		assert.ErrorIs(t, err, ErrUserDoesNotHaveEnoughMoney)
+		assert.Equal(t, "30", GetErrorDetails(err)["fee"])
+
+		_, err = app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "995",
+			IdempotencyToken: "2"})
+		assert.ErrorIs(t, err, ErrUserDoesNotHaveEnoughMoney)
+		assert.Equal(t, "1000", balanceOf(t, app, 1))
+	})
+
+	t.Run("negative path, missing fees account", func(t *testing.T) {
+		app := newApp(t, 100500)
+
+		_, err := app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "100",
+			IdempotencyToken: "1"})
+		assert.ErrorIs(t, err, ErrFeesAccountDoesNotExist)
+		assert.Equal(t, "1000", balanceOf(t, app, 1), "transfer must be rolled back")
+
+		cfg := app.GetConfig()
+		cfg.FeesAccountId = 0
+		assert.Error(t, cfg.Validate(), "fees account must be set for fee rules")
+	})
+}
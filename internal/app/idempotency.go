@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"job-backend-trainee-assignment/internal/idempotency"
+	"net/http"
+	"time"
+)
+
+//endpoint names of operations, used to choose ttl of idempotency keys
+const (
+	EndpointCredit   = "credit"
+	EndpointWithdraw = "withdraw"
+	EndpointTransfer = "transfer"
+	EndpointBonus    = "bonus"
+)
+
+//idempotencyFinishTimeout limits completion or release of idempotency key, performed after operation,
+//it does not depend on request context, so cancelled request does not leave key in progress
+var idempotencyFinishTimeout = 5 * time.Second
+
+//SetIdempotencyStore replaces store of idempotency keys, in memory store is used by default
+func (ba *BillingApp) SetIdempotencyStore(store idempotency.IIdempotencyStore) error {
+	if store == nil {
+		return fmt.Errorf("must provide non-nil idempotency store instance")
+	}
+
+	ba.mu.Lock()
+	ba.idempotency = store
+	ba.mu.Unlock()
+	return nil
+}
+
+func (ba *BillingApp) getIdempotencyStore() idempotency.IIdempotencyStore {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+	return ba.idempotency
+}
+
+//beginIdempotentOperation marks token as in progress, done is true if operation with the token is already completed.
+//If store fails, began is false and operation goes on, token is checked in database anyway
+func (ba *BillingApp) beginIdempotentOperation(ctx context.Context, methodName string, endpoint string, token string) (began bool, done bool, err error) {
+	rec, err := ba.getIdempotencyStore().Begin(ctx, endpoint, token)
+	if err != nil {
+		ba.logger.Error("%s, %s, err:%v, performing lookup in database", methodName, ErrCacheLookupFailed.Error(), err)
+		return false, false, nil
+	}
+
+	if rec == nil {
+		return true, false, nil
+	}
+
+	if rec.State == idempotency.StateInProgress {
+		ba.logger.Error("%s, %s", methodName, ErrOperationIsInProgress.Error())
+		return false, false, &AppError{ErrOperationIsInProgress, http.StatusConflict}
+	}
+
+	ba.logger.Info("%s, operation token found in idempotency store, returning success response", methodName)
+	return false, true, nil
+}
+
+//finishIdempotentOperation marks token of completed operation as completed,
+//token of failed operation is released, so the operation may be retried
+func (ba *BillingApp) finishIdempotentOperation(methodName string, endpoint string, token string, began bool, completed bool) {
+	if !began && !completed {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), idempotencyFinishTimeout)
+	defer cancel()
+
+	store := ba.getIdempotencyStore()
+	if completed {
+		err := store.Complete(ctx, endpoint, token)
+		if err != nil {
+			ba.logger.Error("%s, %s, err: %v, key is not saved in idempotency store", methodName, ErrCacheWriteFailed.Error(), err)
+		}
+		return
+	}
+
+	err := store.Release(ctx, endpoint, token)
+	if err != nil {
+		ba.logger.Error("%s, %s, err: %v, key is left in progress until its ttl expires", methodName, ErrCacheWriteFailed.Error(), err)
+	}
+}
+
+//replayOperation returns result of operation, which is already stored in database with the token.
+//Previous attempt of the operation may have failed to invalidate cached balances after commit,
+//so balance versions of users of the operation are incremented again before result is returned.
+//Transaction is rolled back first, so operations table is not locked during cache calls
+func (ba *BillingApp) replayOperation(ctx context.Context, methodName string, tx IStorageTx, token string) (*ResultState, error) {
+	operations, err := tx.Operations().ListByIdempotencyToken(ctx, token)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("%s, %s, err %v", methodName, ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("%s, %s, err %v", methodName, ErrDBFailedToFetchOperationRows.Error(), err)
+		return nil, &AppError{ErrDBFailedToFetchOperationRows, http.StatusInternalServerError}
+	}
+
+	err = tx.Rollback()
+	if err != nil && err != sql.ErrTxDone {
+		ba.logger.Error("%s, %s, err %v", methodName, ErrDBTransactionRollbackFailed.Error(), err)
+	}
+
+	userIds := make([]int64, 0, len(operations))
+	seen := make(map[int64]struct{}, len(operations))
+	for _, operation := range operations {
+		if _, ok := seen[operation.UserId]; !ok {
+			seen[operation.UserId] = struct{}{}
+			userIds = append(userIds, operation.UserId)
+		}
+	}
+
+	ba.invalidateCachedBalances(methodName, userIds...)
+	return &ResultState{State: OperationTokenIsAlreadyUsed, ConsistencyToken: ba.consistencyToken(methodName)}, nil
+}
@@ -0,0 +1,127 @@
+// +build integration
+
+package app
+
+import (
+	"context"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/cache"
+	"job-backend-trainee-assignment/internal/db_connector"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"job-backend-trainee-assignment/internal/idempotency"
+	"job-backend-trainee-assignment/internal/logger"
+	"job-backend-trainee-assignment/internal/test_helpers"
+	"testing"
+	"time"
+)
+
+func TestBillingApp_IdempotencyStore_Common(t *testing.T) {
+	v := viper.New()
+
+	v.AddConfigPath(".")
+	v.AddConfigPath("../../")
+	v.SetConfigName("config")
+	v.AutomaticEnv()
+
+	err := v.ReadInConfig()
+	require.NoErrorf(t, err, "failed to read config file at: %s, err %v", "config", err)
+
+	var pgHost string
+	if v.GetString("DATABASE_HOST") != "" {
+		pgHost = v.GetString("DATABASE_HOST")
+	} else {
+		pgHost = v.GetString("db_params.DATABASE_HOST")
+	}
+
+	dbConfig := &db_connector.Config{
+		DriverName:    v.GetString("db_params.driver_name"),
+		DBUser:        v.GetString("db_params.user"),
+		DBPass:        v.GetString("db_params.password"),
+		DBName:        v.GetString("db_params.db_name"),
+		DBPort:        v.GetString("db_params.port"),
+		DBHost:        pgHost,
+		SSLMode:       v.GetString("db_params.ssl_mode"),
+		RetryInterval: v.GetDuration("db_params.conn_retry_interval") * time.Second,
+	}
+
+	dbConnTimeout := v.GetDuration("db_params.conn_timeout") * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), dbConnTimeout)
+	defer cancel()
+	dummyLogger := &logger.DummyLogger{}
+
+	db, dbCloseFunc, err := db_connector.DBConnectWithTimeout(ctx, dbConfig, dummyLogger)
+	require.NoErrorf(t, err, "failed to connect to db,err %v", err)
+
+	defer dbCloseFunc()
+
+	ex := &exchanger.StubExchanger{}
+	caseTimeout := v.GetDuration("testing_params.test_case_timeout") * time.Second
+
+	prepareDB := func(ctx context.Context, t *testing.T) {
+		err := test_helpers.PrepareDB(ctx, db, test_helpers.Config{
+			InitFilePath:    filePathPrefix + v.GetString("testing_params.db_init_file_path"),
+			CleanUpFilePath: filePathPrefix + v.GetString("testing_params.db_cleanup_file_path"),
+		})
+		require.NoError(t, err, "PrepareDB must not return error")
+	}
+
+	t.Run("operation with token in progress is rejected", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		app, err := NewApp(dummyLogger, db, ex, &cache.DummyCacheCommon{}, nil)
+		require.NoErrorf(t, err, "failed to create BillingApp instance, err %v", err)
+		require.NoError(t, app.SetIdempotencyStore(&idempotency.StubStoreWithAnyKeyInProgress{}))
+
+		_, err = app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 2, Amount: "5", IdempotencyToken: uuid.NewV4().String()})
+		assert.ErrorIs(t, err, ErrOperationIsInProgress, "credit must return in progress error")
+
+		_, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 2, Amount: "5", IdempotencyToken: uuid.NewV4().String()})
+		assert.ErrorIs(t, err, ErrOperationIsInProgress, "withdraw must return in progress error")
+
+		_, err = app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 2, ReceiverId: 1, Amount: "5", IdempotencyToken: uuid.NewV4().String()})
+		assert.ErrorIs(t, err, ErrOperationIsInProgress, "transfer must return in progress error")
+
+		res, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 2})
+		require.NoError(t, err)
+		assert.Equal(t, "10", res.Balance, "rejected operations must not change balance")
+	})
+
+	t.Run("token is completed after operation and released after failure", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		store, err := idempotency.NewMemoryStore(nil)
+		require.NoError(t, err)
+		app, err := NewApp(dummyLogger, db, ex, &cache.DummyCacheCommon{}, nil)
+		require.NoErrorf(t, err, "failed to create BillingApp instance, err %v", err)
+		require.NoError(t, app.SetIdempotencyStore(store))
+
+		token := uuid.NewV4().String()
+		_, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 2, Amount: "50", IdempotencyToken: token})
+		assert.ErrorIs(t, err, ErrUserDoesNotHaveEnoughMoney, "withdraw must fail")
+
+		rec, err := store.Begin(ctx, EndpointWithdraw, token)
+		require.NoError(t, err)
+		assert.Nil(t, rec, "token of failed operation must be released")
+		require.NoError(t, store.Release(ctx, EndpointWithdraw, token))
+
+		res, err := app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 2, Amount: "5", IdempotencyToken: token})
+		require.NoError(t, err, "operation must be retried with released token")
+		assert.Equal(t, MsgAccountWithdrawDone, res.State)
+
+		res, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 2, Amount: "5", IdempotencyToken: token})
+		require.NoError(t, err)
+		assert.Equal(t, OperationTokenIsAlreadyUsed, res.State, "completed token must not be used again")
+
+		rec, err = store.Begin(ctx, EndpointWithdraw, token)
+		require.NoError(t, err)
+		require.NotNil(t, rec)
+		assert.Equal(t, idempotency.StateCompleted, rec.State, "token must be completed")
+	})
+}
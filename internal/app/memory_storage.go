@@ -0,0 +1,563 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"sort"
+	"strings"
+	"time"
+)
+
+//ErrTxIsReadOnly is returned on change in read only transaction of memory storage
+var ErrTxIsReadOnly = fmt.Errorf("cannot change data in read only transaction")
+
+//MemoryStorage keeps users, operations and balance snapshots in memory, it is used in tests and local runs.
+//Transactions are serialized: transaction holds storage until commit or rollback and changes its own copy of data,
+//which replaces storage data on commit, so transactions see committed data only and rollback discards changes
+type MemoryStorage struct {
+	//lock is a semaphore, held by running transaction
+	lock chan struct{}
+	data *memoryData
+}
+
+type memoryData struct {
+	users      map[int64]User
+	operations []Operation
+	snapshots  map[memorySnapshotKey]BalanceSnapshot
+	quotes     map[string]Quote
+	bonuses    map[int64]BonusGrant
+	//lastOperationId is id of the last inserted operation, ids are not reused after rollback as postgres serial
+	lastOperationId  *int64
+	lastBonusGrantId *int64
+}
+
+type memorySnapshotKey struct {
+	userId int64
+	day    string
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	var lastOperationId, lastBonusGrantId int64
+	return &MemoryStorage{
+		lock: make(chan struct{}, 1),
+		data: &memoryData{
+			users:            map[int64]User{},
+			operations:       make([]Operation, 0),
+			snapshots:        map[memorySnapshotKey]BalanceSnapshot{},
+			quotes:           map[string]Quote{},
+			bonuses:          map[int64]BonusGrant{},
+			lastOperationId:  &lastOperationId,
+			lastBonusGrantId: &lastBonusGrantId,
+		},
+	}
+}
+
+//clone copies data for read write transaction
+func (md *memoryData) clone() *memoryData {
+	users := make(map[int64]User, len(md.users))
+	for id, user := range md.users {
+		users[id] = user
+	}
+
+	operations := make([]Operation, len(md.operations))
+	copy(operations, md.operations)
+
+	snapshots := make(map[memorySnapshotKey]BalanceSnapshot, len(md.snapshots))
+	for key, snapshot := range md.snapshots {
+		snapshots[key] = snapshot
+	}
+
+	quotes := make(map[string]Quote, len(md.quotes))
+	for id, quote := range md.quotes {
+		quotes[id] = quote
+	}
+
+	bonuses := make(map[int64]BonusGrant, len(md.bonuses))
+	for id, grant := range md.bonuses {
+		bonuses[id] = grant
+	}
+
+	return &memoryData{
+		users:            users,
+		operations:       operations,
+		snapshots:        snapshots,
+		quotes:           quotes,
+		bonuses:          bonuses,
+		lastOperationId:  md.lastOperationId,
+		lastBonusGrantId: md.lastBonusGrantId,
+	}
+}
+
+func (ms *MemoryStorage) BeginTx(ctx context.Context, opts *sql.TxOptions) (IStorageTx, error) {
+	select {
+	case ms.lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	readOnly := opts != nil && opts.ReadOnly
+	data := ms.data
+	if !readOnly {
+		data = data.clone()
+	}
+	return &memoryTx{storage: ms, data: data, readOnly: readOnly}, nil
+}
+
+//memoryTx implements all repositories over a copy of storage data
+type memoryTx struct {
+	storage  *MemoryStorage
+	data     *memoryData
+	readOnly bool
+	done     bool
+}
+
+func (mt *memoryTx) Users() IUserRepository {
+	return mt
+}
+
+func (mt *memoryTx) Operations() IOperationRepository {
+	return (*memoryOperations)(mt)
+}
+
+func (mt *memoryTx) Idempotency() IIdempotencyRepository {
+	return (*memoryIdempotency)(mt)
+}
+
+func (mt *memoryTx) Snapshots() ISnapshotRepository {
+	return (*memorySnapshots)(mt)
+}
+
+func (mt *memoryTx) Quotes() IQuoteRepository {
+	return (*memoryQuotes)(mt)
+}
+
+func (mt *memoryTx) Bonuses() IBonusRepository {
+	return (*memoryBonuses)(mt)
+}
+
+func (mt *memoryTx) Commit() error {
+	if mt.done {
+		return sql.ErrTxDone
+	}
+
+	if !mt.readOnly {
+		mt.storage.data = mt.data
+	}
+	mt.finish()
+	return nil
+}
+
+func (mt *memoryTx) Rollback() error {
+	if mt.done {
+		return sql.ErrTxDone
+	}
+
+	mt.finish()
+	return nil
+}
+
+func (mt *memoryTx) finish() {
+	mt.done = true
+	mt.data = nil
+	<-mt.storage.lock
+}
+
+//check returns error if transaction may not be used
+func (mt *memoryTx) check(ctx context.Context, write bool) error {
+	if mt.done {
+		return sql.ErrTxDone
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if write && mt.readOnly {
+		return ErrTxIsReadOnly
+	}
+	return nil
+}
+
+//LockForInsert does nothing, transactions are serialized
+func (mt *memoryTx) LockForInsert(ctx context.Context) error {
+	return mt.check(ctx, true)
+}
+
+func (mt *memoryTx) Get(ctx context.Context, userId int64, lock RowLock) (*User, error) {
+	if err := mt.check(ctx, lock != RowLockNone); err != nil {
+		return nil, err
+	}
+
+	user, ok := mt.data.users[userId]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &user, nil
+}
+
+func (mt *memoryTx) GetMany(ctx context.Context, userIds []int64, lock RowLock) ([]User, error) {
+	if err := mt.check(ctx, lock != RowLockNone); err != nil {
+		return nil, err
+	}
+
+	users := make([]User, 0, len(userIds))
+	for _, id := range userIds {
+		if user, ok := mt.data.users[id]; ok {
+			users = append(users, user)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Id < users[j].Id
+	})
+	return users, nil
+}
+
+func (mt *memoryTx) Create(ctx context.Context, user *User) error {
+	if err := mt.check(ctx, true); err != nil {
+		return err
+	}
+
+	if _, ok := mt.data.users[user.Id]; ok {
+		return fmt.Errorf("user %d already exists", user.Id)
+	}
+	mt.data.users[user.Id] = *user
+	return nil
+}
+
+func (mt *memoryTx) AddToBalance(ctx context.Context, userId int64, amount decimal.Decimal) error {
+	if err := mt.check(ctx, true); err != nil {
+		return err
+	}
+
+	user, ok := mt.data.users[userId]
+	if !ok {
+		return nil
+	}
+	user.Balance = user.Balance.Add(amount)
+	mt.data.users[userId] = user
+	return nil
+}
+
+type memoryOperations memoryTx
+
+//inPeriod checks that date is in [from, to), nil bound is not applied
+func inPeriod(date time.Time, from, to *time.Time) bool {
+	if from != nil && date.Before(*from) {
+		return false
+	}
+	if to != nil && !date.Before(*to) {
+		return false
+	}
+	return true
+}
+
+func (mo *memoryOperations) LockForInsert(ctx context.Context) error {
+	return (*memoryTx)(mo).check(ctx, true)
+}
+
+func (mo *memoryOperations) Insert(ctx context.Context, operations ...Operation) error {
+	if err := (*memoryTx)(mo).check(ctx, true); err != nil {
+		return err
+	}
+
+	for _, op := range operations {
+		if _, ok := mo.data.users[op.UserId]; !ok {
+			return fmt.Errorf("user %d of operation does not exist", op.UserId)
+		}
+	}
+
+	for _, op := range operations {
+		*mo.data.lastOperationId++
+		op.Id = *mo.data.lastOperationId
+		mo.data.operations = append(mo.data.operations, op)
+	}
+	return nil
+}
+
+//filter returns operations of user in period
+func (mo *memoryOperations) filter(filter *OperationFilter) []Operation {
+	operations := make([]Operation, 0)
+	for _, op := range mo.data.operations {
+		if op.UserId == filter.UserId && inPeriod(op.Date, filter.From, filter.To) {
+			operations = append(operations, op)
+		}
+	}
+	return operations
+}
+
+//isAfter checks that operation follows cursor in order of date and id
+func isAfter(op *Operation, cursor *OperationCursor) bool {
+	if op.Date.Equal(cursor.Date) {
+		return op.Id > cursor.Id
+	}
+	return op.Date.After(cursor.Date)
+}
+
+func (mo *memoryOperations) List(ctx context.Context, filter *OperationFilter) ([]Operation, error) {
+	if err := (*memoryTx)(mo).check(ctx, false); err != nil {
+		return nil, err
+	}
+
+	operations := mo.filter(filter)
+	if filter.After != nil {
+		after := make([]Operation, 0, len(operations))
+		for i := range operations {
+			if isAfter(&operations[i], filter.After) {
+				after = append(after, operations[i])
+			}
+		}
+		operations = after
+	}
+
+	byAmount := strings.ToLower(filter.OrderField) == "amount"
+	desc := strings.ToLower(filter.OrderDirection) == "desc"
+	sort.SliceStable(operations, func(i, j int) bool {
+		a, b := operations[i], operations[j]
+		if desc {
+			a, b = b, a
+		}
+
+		if byAmount {
+			if !a.Amount.Equal(b.Amount) {
+				return a.Amount.LessThan(b.Amount)
+			}
+		} else if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		return a.Id < b.Id
+	})
+
+	if filter.Limit == -1 {
+		return operations, nil
+	}
+
+	if filter.Offset >= int64(len(operations)) {
+		return make([]Operation, 0), nil
+	}
+	operations = operations[filter.Offset:]
+	if filter.Limit < int64(len(operations)) {
+		operations = operations[:filter.Limit]
+	}
+	return operations, nil
+}
+
+func (mo *memoryOperations) Count(ctx context.Context, filter *OperationFilter) (int64, error) {
+	if err := (*memoryTx)(mo).check(ctx, false); err != nil {
+		return 0, err
+	}
+	return int64(len(mo.filter(filter))), nil
+}
+
+func (mo *memoryOperations) ListByIdempotencyToken(ctx context.Context, token string) ([]Operation, error) {
+	if err := (*memoryTx)(mo).check(ctx, false); err != nil {
+		return nil, err
+	}
+
+	operations := make([]Operation, 0)
+	for _, op := range mo.data.operations {
+		if op.IdempotencyToken == token {
+			operations = append(operations, op)
+		}
+	}
+	return operations, nil
+}
+
+func (mo *memoryOperations) SumAmount(ctx context.Context, userId int64, from, to *time.Time) (decimal.NullDecimal, error) {
+	if err := (*memoryTx)(mo).check(ctx, false); err != nil {
+		return decimal.NullDecimal{}, err
+	}
+
+	sum := decimal.NullDecimal{Decimal: decimal.Zero}
+	for _, op := range mo.data.operations {
+		if op.UserId == userId && inPeriod(op.Date, from, to) {
+			sum.Decimal = sum.Decimal.Add(op.Amount)
+			sum.Valid = true
+		}
+	}
+	return sum, nil
+}
+
+type memoryIdempotency memoryTx
+
+func (mi *memoryIdempotency) TokenIsUsed(ctx context.Context, token string) (bool, error) {
+	if err := (*memoryTx)(mi).check(ctx, false); err != nil {
+		return false, err
+	}
+
+	for _, op := range mi.data.operations {
+		if op.IdempotencyToken == token {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memorySnapshots memoryTx
+
+func (ms *memorySnapshots) InsertClosingBalances(ctx context.Context, day, dayEnd time.Time) (int64, error) {
+	if err := (*memoryTx)(ms).check(ctx, true); err != nil {
+		return 0, err
+	}
+
+	var stored int64
+	now := time.Now()
+	for _, user := range ms.data.users {
+		if !user.CreatedAt.Before(dayEnd) {
+			continue
+		}
+
+		key := memorySnapshotKey{userId: user.Id, day: day.Format(dateLayout)}
+		if _, ok := ms.data.snapshots[key]; ok {
+			continue
+		}
+
+		balance := user.Balance
+		for _, op := range ms.data.operations {
+			if op.UserId == user.Id && !op.Date.Before(dayEnd) {
+				balance = balance.Sub(op.Amount)
+			}
+		}
+
+		ms.data.snapshots[key] = BalanceSnapshot{UserId: user.Id, Date: StartOfDay(day), Balance: balance, CreatedAt: now}
+		stored++
+	}
+	return stored, nil
+}
+
+func (ms *memorySnapshots) GetLatest(ctx context.Context, userId int64, day time.Time) (*BalanceSnapshot, error) {
+	if err := (*memoryTx)(ms).check(ctx, false); err != nil {
+		return nil, err
+	}
+
+	var latest *BalanceSnapshot
+	for _, snapshot := range ms.data.snapshots {
+		if snapshot.UserId != userId || snapshot.Date.After(day) {
+			continue
+		}
+		if latest == nil || snapshot.Date.After(latest.Date) {
+			s := snapshot
+			latest = &s
+		}
+	}
+
+	if latest == nil {
+		return nil, sql.ErrNoRows
+	}
+	return latest, nil
+}
+
+type memoryQuotes memoryTx
+
+func (mq *memoryQuotes) Insert(ctx context.Context, quote *Quote) error {
+	if err := (*memoryTx)(mq).check(ctx, true); err != nil {
+		return err
+	}
+
+	if _, ok := mq.data.quotes[quote.Id]; ok {
+		return fmt.Errorf("quote %s already exists", quote.Id)
+	}
+	mq.data.quotes[quote.Id] = *quote
+	return nil
+}
+
+func (mq *memoryQuotes) Get(ctx context.Context, quoteId string, lock RowLock) (*Quote, error) {
+	if err := (*memoryTx)(mq).check(ctx, lock != RowLockNone); err != nil {
+		return nil, err
+	}
+
+	quote, ok := mq.data.quotes[quoteId]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &quote, nil
+}
+
+func (mq *memoryQuotes) MarkUsed(ctx context.Context, quoteId string, token string, usedAt time.Time) error {
+	if err := (*memoryTx)(mq).check(ctx, true); err != nil {
+		return err
+	}
+
+	quote, ok := mq.data.quotes[quoteId]
+	if !ok {
+		return nil
+	}
+	quote.UsedAt = &usedAt
+	quote.IdempotencyToken = &token
+	mq.data.quotes[quoteId] = quote
+	return nil
+}
+
+type memoryBonuses memoryTx
+
+func (mb *memoryBonuses) Insert(ctx context.Context, grant *BonusGrant) error {
+	if err := (*memoryTx)(mb).check(ctx, true); err != nil {
+		return err
+	}
+
+	if _, ok := mb.data.users[grant.UserId]; !ok {
+		return fmt.Errorf("user %d of bonus grant does not exist", grant.UserId)
+	}
+	*mb.data.lastBonusGrantId++
+	grant.Id = *mb.data.lastBonusGrantId
+	mb.data.bonuses[grant.Id] = *grant
+	return nil
+}
+
+//list returns selected not spent grants, ordered by expiration and id
+func (mb *memoryBonuses) list(selected func(grant BonusGrant) bool) []BonusGrant {
+	grants := make([]BonusGrant, 0)
+	for _, grant := range mb.data.bonuses {
+		if grant.Remaining.IsPositive() && selected(grant) {
+			grants = append(grants, grant)
+		}
+	}
+
+	sort.Slice(grants, func(i, j int) bool {
+		if !grants[i].ExpiresAt.Equal(grants[j].ExpiresAt) {
+			return grants[i].ExpiresAt.Before(grants[j].ExpiresAt)
+		}
+		return grants[i].Id < grants[j].Id
+	})
+	return grants
+}
+
+func (mb *memoryBonuses) ListActive(ctx context.Context, userId int64, now time.Time,
+	lock RowLock) ([]BonusGrant, error) {
+	if err := (*memoryTx)(mb).check(ctx, lock != RowLockNone); err != nil {
+		return nil, err
+	}
+
+	return mb.list(func(grant BonusGrant) bool {
+		return grant.UserId == userId && grant.ExpiresAt.After(now)
+	}), nil
+}
+
+func (mb *memoryBonuses) ListExpired(ctx context.Context, now time.Time, limit int64,
+	lock RowLock) ([]BonusGrant, error) {
+	if err := (*memoryTx)(mb).check(ctx, lock != RowLockNone); err != nil {
+		return nil, err
+	}
+
+	grants := mb.list(func(grant BonusGrant) bool {
+		return !grant.ExpiresAt.After(now)
+	})
+	if int64(len(grants)) > limit {
+		grants = grants[:limit]
+	}
+	return grants, nil
+}
+
+func (mb *memoryBonuses) SetRemaining(ctx context.Context, grantId int64, remaining decimal.Decimal) error {
+	if err := (*memoryTx)(mb).check(ctx, true); err != nil {
+		return err
+	}
+
+	grant, ok := mb.data.bonuses[grantId]
+	if !ok {
+		return nil
+	}
+	grant.Remaining = remaining
+	mb.data.bonuses[grantId] = grant
+	return nil
+}
@@ -0,0 +1,261 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/cache"
+	"job-backend-trainee-assignment/internal/db_connector"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"job-backend-trainee-assignment/internal/logger"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newMemoryStorageApp(t *testing.T, storage IStorage) *BillingApp {
+	app, err := NewAppWithStorage(&logger.DummyLogger{}, storage, &exchanger.StubExchanger{}, &cache.DummyCacheCommon{}, nil)
+	require.NoErrorf(t, err, "failed to create BillingApp instance, err %v", err)
+	return app
+}
+
+func TestNewAppWithStorage_Function(t *testing.T) {
+	_, err := NewAppWithStorage(&logger.DummyLogger{}, nil, &exchanger.StubExchanger{}, &cache.DummyCacheCommon{}, nil)
+	assert.Error(t, err, "storage must be provided")
+
+	app := newMemoryStorageApp(t, NewMemoryStorage())
+	err = app.SetReadReplicas(&stubNoReplicas{})
+	assert.Error(t, err, "read replicas must not be set on memory storage")
+}
+
+type stubNoReplicas struct{}
+
+func (s *stubNoReplicas) Replica() (replica *db_connector.Replica, ok bool) {
+	return nil, false
+}
+
+func TestBillingApp_WithMemoryStorage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("credit, withdraw and balance", func(t *testing.T) {
+		app := newMemoryStorageApp(t, NewMemoryStorage())
+
+		res, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Name: "Mr. Jones", Purpose: "salary",
+			Amount: "100.50", IdempotencyToken: "1"})
+		require.NoError(t, err)
+		assert.Equal(t, MsgAccountCreditingDone, res.State)
+
+		res, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Purpose: "fee", Amount: "0.50",
+			IdempotencyToken: "2"})
+		require.NoError(t, err)
+		assert.Equal(t, MsgAccountWithdrawDone, res.State)
+
+		balance, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "100", balance.Balance)
+		assert.Equal(t, exchanger.RUBCode, balance.Currency)
+
+		info, err := app.GetUserInfo(ctx, &UserInfoRequest{UserId: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "Mr. Jones", info.Name)
+
+		_, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Amount: "1000", IdempotencyToken: "3"})
+		require.Error(t, err)
+		assert.Equal(t, ErrUserDoesNotHaveEnoughMoney, err.(*AppError).Err.(*ErrorWithDetails).Err)
+
+		_, err = app.GetUserBalance(ctx, &BalanceRequest{UserId: 2})
+		require.Error(t, err)
+		assert.Equal(t, ErrUserDoesNotExist, err.(*AppError).Err)
+	})
+
+	t.Run("token of stored operation is checked in storage", func(t *testing.T) {
+		storage := NewMemoryStorage()
+		app := newMemoryStorageApp(t, storage)
+
+		_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "10", IdempotencyToken: "1"})
+		require.NoError(t, err)
+
+		//new app has empty idempotency store, so token is found in storage only
+		res, err := newMemoryStorageApp(t, storage).CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "10",
+			IdempotencyToken: "1"})
+		require.NoError(t, err)
+		assert.Equal(t, OperationTokenIsAlreadyUsed, res.State)
+
+		balance, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "10", balance.Balance)
+
+		ops, err := app.GetOperationsByIdempotencyToken(ctx, &IdempotencyLookupRequest{IdempotencyToken: "1"})
+		require.NoError(t, err)
+		assert.Len(t, ops, 1)
+	})
+
+	t.Run("transfer, failed transfer changes nothing", func(t *testing.T) {
+		app := newMemoryStorageApp(t, NewMemoryStorage())
+
+		_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Name: "sender", Amount: "10", IdempotencyToken: "1"})
+		require.NoError(t, err)
+		_, err = app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 2, Name: "receiver", Amount: "1", IdempotencyToken: "2"})
+		require.NoError(t, err)
+
+		_, err = app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 3, Amount: "5",
+			IdempotencyToken: "3"})
+		require.Error(t, err)
+		assert.Equal(t, ErrMoneyReceiverDoesNotExist, err.(*AppError).Err)
+
+		res, err := app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "5",
+			IdempotencyToken: "4"})
+		require.NoError(t, err)
+		assert.Equal(t, MsgMoneyTransferDone, res.State)
+
+		sender, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "5", sender.Balance)
+
+		receiver, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 2})
+		require.NoError(t, err)
+		assert.Equal(t, "6", receiver.Balance)
+
+		ops, err := app.GetOperationsByIdempotencyToken(ctx, &IdempotencyLookupRequest{IdempotencyToken: "4"})
+		require.NoError(t, err)
+		require.Len(t, ops, 2)
+		assert.Equal(t, fmt.Sprintf(CommentTransferToUserWithName, "receiver"), ops[0].Comment)
+		assert.Equal(t, "-5", ops[0].Amount.String())
+	})
+
+	t.Run("operations log", func(t *testing.T) {
+		app := newMemoryStorageApp(t, NewMemoryStorage())
+
+		for i, amount := range []string{"30", "10", "20"} {
+			_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: amount,
+				IdempotencyToken: fmt.Sprint(i)})
+			require.NoError(t, err)
+		}
+
+		log, err := app.GetUserOperations(ctx, &OperationLogRequest{UserId: 1, OrderField: "amount",
+			OrderDirection: "asc", Limit: 2, Page: 2})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), log.OperationsNum)
+		assert.Equal(t, int64(2), log.PagesTotal)
+		require.Len(t, log.Operations, 1)
+		assert.Equal(t, "30", log.Operations[0].Amount.String())
+
+		log, err = app.GetUserOperations(ctx, &OperationLogRequest{UserId: 1, Limit: -1})
+		require.NoError(t, err)
+		require.Len(t, log.Operations, 3)
+		assert.Equal(t, "20", log.Operations[0].Amount.String(), "latest operation must be the first by default")
+
+		_, err = app.GetUserOperations(ctx, &OperationLogRequest{UserId: 2, Limit: -1})
+		require.Error(t, err)
+		assert.Equal(t, ErrUserDoesNotExist, err.(*AppError).Err)
+	})
+
+	t.Run("statement and balance on date", func(t *testing.T) {
+		app := newMemoryStorageApp(t, NewMemoryStorage())
+
+		_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "10", IdempotencyToken: "1"})
+		require.NoError(t, err)
+		_, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Amount: "3", IdempotencyToken: "2"})
+		require.NoError(t, err)
+
+		today := StartOfDay(time.Now())
+		statement, err := app.GetUserStatement(ctx, &StatementRequest{UserId: 1, From: today.Format(dateLayout),
+			To: today.Format(dateLayout)})
+		require.NoError(t, err)
+		assert.Equal(t, "0", statement.OpeningBalance)
+		assert.Equal(t, "7", statement.ClosingBalance)
+		assert.Len(t, statement.Operations, 2)
+		assert.True(t, statement.Consistent)
+
+		yesterday := today.AddDate(0, 0, -1)
+		balance, err := app.GetUserBalanceOnDate(ctx, &BalanceOnDateRequest{UserId: 1, Date: yesterday.Format(dateLayout)})
+		require.NoError(t, err)
+		assert.Equal(t, "0", balance.Balance)
+
+		stored, err := app.SnapshotUserBalances(ctx, yesterday)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), stored, "user created today must not have snapshot of yesterday")
+	})
+
+	t.Run("statement stream", func(t *testing.T) {
+		app := newMemoryStorageApp(t, NewMemoryStorage())
+
+		for i, amount := range []string{"30", "10", "20", "5"} {
+			_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: amount,
+				IdempotencyToken: fmt.Sprint(i)})
+			require.NoError(t, err)
+		}
+
+		today := StartOfDay(time.Now()).Format(dateLayout)
+		in := &StatementStreamRequest{UserId: 1, From: today, To: today, PageSize: 3}
+		var opening string
+		var pages [][]string
+		err := app.StreamUserStatement(ctx, in, func(openingBalance decimal.Decimal) error {
+			opening = openingBalance.String()
+			return nil
+		}, func(operations []Operation) error {
+			page := make([]string, 0, len(operations))
+			for _, op := range operations {
+				page = append(page, op.Amount.String())
+			}
+			pages = append(pages, page)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "0", opening)
+		assert.Equal(t, [][]string{{"30", "10", "20"}, {"5"}}, pages, "pages must follow each other in order of date")
+
+		errWriteFailed := errors.New("write failed")
+		err = app.StreamUserStatement(ctx, in, func(decimal.Decimal) error {
+			return nil
+		}, func([]Operation) error {
+			return errWriteFailed
+		})
+		assert.ErrorIs(t, err, errWriteFailed, "error of page writer must be returned")
+
+		noop := func(decimal.Decimal) error { return nil }
+		noopPage := func([]Operation) error { return nil }
+		err = app.StreamUserStatement(ctx, &StatementStreamRequest{UserId: 2, From: today, To: today, PageSize: 3},
+			noop, noopPage)
+		assert.ErrorIs(t, err, ErrUserDoesNotExist)
+		err = app.StreamUserStatement(ctx, &StatementStreamRequest{UserId: 1, From: today, To: today}, noop, noopPage)
+		assert.ErrorIs(t, err, ErrLimitParamIsLessThanMin)
+		err = app.StreamUserStatement(ctx, &StatementStreamRequest{UserId: 1, From: today, To: today, PageSize: 3,
+			ConsistencyToken: "bad"}, noop, noopPage)
+		assert.ErrorIs(t, err, ErrBadConsistencyToken)
+	})
+
+	t.Run("concurrent operations", func(t *testing.T) {
+		app := newMemoryStorageApp(t, NewMemoryStorage())
+
+		_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "100", IdempotencyToken: "init"})
+		require.NoError(t, err)
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if i%2 == 0 {
+					_, _ = app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "1",
+						IdempotencyToken: fmt.Sprintf("credit-%d", i)})
+					return
+				}
+				_, _ = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, Amount: "2",
+					IdempotencyToken: fmt.Sprintf("withdraw-%d", i)})
+			}(i)
+		}
+		wg.Wait()
+
+		balance, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "75", balance.Balance)
+
+		log, err := app.GetUserOperations(ctx, &OperationLogRequest{UserId: 1, Limit: -1})
+		require.NoError(t, err)
+		assert.Equal(t, int64(51), log.OperationsNum)
+	})
+}
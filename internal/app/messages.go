@@ -4,6 +4,7 @@ var (
 	MsgAccountCreditingDone = "Account crediting Done"
 	MsgAccountWithdrawDone  = "Account withdraw Done"
 	MsgMoneyTransferDone    = "Money transfer Done"
+	MsgBonusGrantDone       = "Bonus grant Done"
 
 	OperationTokenIsAlreadyUsed = "Operation with specified token had already been done"
 )
@@ -14,4 +15,10 @@ var (
 	CommentTransferToServiceWithComment   = "payment to service, %s"
 	CommentTransferToUserWithName         = "transfer to user %s"
 	CommentTransferFromUserWithName       = "transfer from user %s"
+	CommentConversionSpreadOfTransfer     = "conversion spread of transfer from user %s to user %s"
+	CommentFeeOfPaymentToService          = "fee of payment to service, %s"
+	CommentFeeOfTransferToUser            = "fee of transfer to user %s"
+	CommentFeeOfUserOperation             = "fee of operation of user %s"
+	CommentBonusGrantWithComment          = "bonus grant, %s"
+	CommentBonusExpirationWithDates       = "expiration of bonus, granted %s, expired at %s"
 )
@@ -8,6 +8,7 @@ import (
 	_ "github.com/jackc/pgx/stdlib"
 	"github.com/shopspring/decimal"
 	"job-backend-trainee-assignment/internal/exchanger"
+	"job-backend-trainee-assignment/internal/fees"
 	"math"
 	"net/http"
 	"strings"
@@ -33,9 +34,36 @@ func (ba *BillingApp) GetUserBalance(ctx context.Context, in *BalanceRequest) (*
 		return nil, &AppError{ErrParamsStructIsNil, http.StatusBadRequest}
 	}
 
-	user := &User{}
-	tx, err := ba.db.BeginTxx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelReadCommitted, ReadOnly: false})
+	if err := ValidateConsistencyToken(in.ConsistencyToken); err != nil {
+		ba.logger.Error("GetUserBalance, %s, token %s", err.Error(), in.ConsistencyToken)
+		return nil, &AppError{err, http.StatusBadRequest}
+	}
+
+	currency := in.Currency
+	if currency == "" {
+		currency = exchanger.RUBCode
+	}
+
+	currencies, err := normalizeBalanceCurrencies(in.Currencies)
+	if err != nil {
+		ba.logger.Error("GetUserBalance, %s, currencies %d", err.Error(), len(in.Currencies))
+		return nil, &AppError{err, http.StatusBadRequest}
+	}
+
+	//balance in several currencies is not cached, it is read once and converted by one snapshot of rates
+	var cacheVersion int64
+	useCache := false
+	if len(currencies) == 0 {
+		cacheVersion, useCache = ba.getBalanceCacheVersion(ctx, in.UserId)
+	}
+	if useCache {
+		if balance, found := ba.getCachedBalance(ctx, in.UserId, cacheVersion, currency); found {
+			return &UserBalance{Balance: balance, Currency: currency}, nil
+		}
+	}
+
+	var user *User
+	tx, fromReplica, err := ba.beginReadTx(ctx, "GetUserBalance", in.ConsistencyToken, sql.LevelReadCommitted)
 	if err != nil {
 		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 			ba.logger.Error("GetUserBalance, %s, err %v", ctxErr.Error(), err)
@@ -57,8 +85,7 @@ func (ba *BillingApp) GetUserBalance(ctx context.Context, in *BalanceRequest) (*
 		}
 	}()
 	{
-		err := tx.GetContext(ctx, user, `SELECT user_id, user_name,
-			balance, created_at FROM "User" WHERE user_id = $1`, in.UserId)
+		user, err = tx.Users().Get(ctx, in.UserId, RowLockNone)
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("GetUserBalance, %s, err %v", ctxErr.Error(), err)
@@ -89,10 +116,14 @@ func (ba *BillingApp) GetUserBalance(ctx context.Context, in *BalanceRequest) (*
 		return nil, &AppError{ErrDBTransactionCommitFailed, http.StatusInternalServerError}
 	}
 
+	if len(currencies) > 0 {
+		return ba.convertBalanceToCurrencies(ctx, user, currency, currencies)
+	}
+
 	var userBalance *UserBalance
 
 	if in.Currency != "" && in.Currency != exchanger.RUBCode {
-		amountInCurrency, err := ba.exchanger.GetAmountInCurrency(ctx, user.Balance, in.Currency)
+		conversion, err := ba.exchanger.GetConversion(ctx, user.Balance, in.Currency)
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("GetUserBalance, %s, err %v", ctxErr.Error(), err)
@@ -108,8 +139,9 @@ func (ba *BillingApp) GetUserBalance(ctx context.Context, in *BalanceRequest) (*
 			return nil, &AppError{ErrCurrencyExchangeFailed, http.StatusInternalServerError}
 		}
 		userBalance = &UserBalance{
-			Balance:  amountInCurrency.String(),
+			Balance:  conversion.Amount.String(),
 			Currency: in.Currency,
+			Stale:    conversion.Stale,
 		}
 
 	} else {
@@ -119,6 +151,12 @@ func (ba *BillingApp) GetUserBalance(ctx context.Context, in *BalanceRequest) (*
 		}
 	}
 
+	//balance, converted by last known rates, is not cached, so it is recalculated once rates are available.
+	//Balance, read from replica, is not cached, because replica may not have replayed write, which changed cache version
+	if useCache && !userBalance.Stale && !fromReplica {
+		ba.setCachedBalance(ctx, in.UserId, cacheVersion, userBalance.Currency, userBalance.Balance)
+	}
+
 	return userBalance, nil
 }
 
@@ -133,55 +171,40 @@ func (ba *BillingApp) CreditUserAccount(ctx context.Context, in *CreditAccountRe
 		return nil, &AppError{ErrIdempotencyTokenIsEmpty, http.StatusBadRequest}
 	}
 
-	found, err := ba.cache.CheckKeyExistence(ctx, in.IdempotencyToken)
-	if err != nil {
-		ba.logger.Error("CreditUserAccount, %s, err:%v,  performing lookup in database", ErrCacheLookupFailed.Error(), err)
-	}
-	if found {
-		ba.logger.Info("CreditUserAccount, operation token found in cache, returning success response")
-		return &ResultState{State: OperationTokenIsAlreadyUsed}, nil
-	}
-
-	amountToCredit, err := decimal.NewFromString(in.Amount)
+	began, done, err := ba.beginIdempotentOperation(ctx, "CreditUserAccount", EndpointCredit, in.IdempotencyToken)
 	if err != nil {
-		ba.logger.Error("CreditUserAccount, %s, err %v", ErrFailedToCastAmountToDecimal.Error(), err)
-		return nil, &AppError{ErrFailedToCastAmountToDecimal, http.StatusBadRequest}
+		return nil, err
 	}
-
-	if amountToCredit.IsNegative() {
-		ba.logger.Error("CreditUserAccount, %s", ErrAmountValueIsNegative.Error())
-		return nil, &AppError{ErrAmountValueIsNegative, http.StatusBadRequest}
+	if done {
+		return &ResultState{State: OperationTokenIsAlreadyUsed, ConsistencyToken: ba.consistencyToken("CreditUserAccount")}, nil
 	}
 
-	ba.mu.Lock()
-	minOpsMonetaryUnit := ba.cfg.MinOpsMonetaryUnit
-	maxDecimalWholeDigitsNum := ba.cfg.MaxDecimalWholeDigitsNum
-	maxDecimalFracDigitsNum := ba.cfg.MaxDecimalFracDigitsNum
-	ba.mu.Unlock()
+	completed := false
+	defer func() {
+		ba.finishIdempotentOperation("CreditUserAccount", EndpointCredit, in.IdempotencyToken, began, completed)
+	}()
 
-	if amountToCredit.LessThan(minOpsMonetaryUnit) {
-		ba.logger.Error("CreditUserAccount, %s", ErrAmountValueIsLessThanMin.Error())
-		return nil, &AppError{ErrAmountValueIsLessThanMin, http.StatusBadRequest}
-	}
+	//amount of operation with quote is taken from quote in transaction, where quote is locked
+	var amountToCredit decimal.Decimal
+	if in.QuoteId == "" {
+		amountToCredit, err = decimal.NewFromString(in.Amount)
+		if err != nil {
+			ba.logger.Error("CreditUserAccount, %s, err %v", ErrFailedToCastAmountToDecimal.Error(), err)
+			return nil, &AppError{ErrFailedToCastAmountToDecimal, http.StatusBadRequest}
+		}
 
-	pointSeparatedDecimalSlice := strings.Split(amountToCredit.String(), ".")
-	//check number of digits to the right of decimal point (fractional part)
-	if len(pointSeparatedDecimalSlice) > 1 {
-		gotDecimalFracDigitsNum := len(pointSeparatedDecimalSlice[1])
-		if gotDecimalFracDigitsNum > maxDecimalFracDigitsNum {
-			ba.logger.Error("CreditUserAccount, %s", ErrAmountHasExcessiveFractionalDigits.Error())
-			return nil, &AppError{ErrAmountHasExcessiveFractionalDigits, http.StatusBadRequest}
+		if amountToCredit.IsNegative() {
+			ba.logger.Error("CreditUserAccount, %s", ErrAmountValueIsNegative.Error())
+			return nil, &AppError{ErrAmountValueIsNegative, http.StatusBadRequest}
 		}
-	}
 
-	//check number of digits to the left of decimal point (whole part)
-	gotDecimalWholeDigitsNum := pointSeparatedDecimalSlice[0]
-	if len(gotDecimalWholeDigitsNum) > maxDecimalWholeDigitsNum {
-		ba.logger.Error("CreditUserAccount, %s", ErrAmountHasExcessiveWholeDigits.Error())
-		return nil, &AppError{ErrAmountHasExcessiveWholeDigits, http.StatusBadRequest}
+		if err := ba.validateOperationAmount("CreditUserAccount", amountToCredit); err != nil {
+			return nil, err
+		}
 	}
+	maxDecimalWholeDigitsNum := ba.GetConfig().MaxDecimalWholeDigitsNum
 
-	tx, err := ba.db.BeginTxx(ctx, &sql.TxOptions{
+	tx, err := ba.storage.BeginTx(ctx, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted, ReadOnly: false})
 	if err != nil {
 		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
@@ -204,7 +227,7 @@ func (ba *BillingApp) CreditUserAccount(ctx context.Context, in *CreditAccountRe
 		}
 	}()
 	{
-		_, err = tx.ExecContext(ctx, `LOCK TABLE "Operation" IN EXCLUSIVE MODE`)
+		err = tx.Operations().LockForInsert(ctx)
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("CreditUserAccount, %s, err %v", ctxErr.Error(), err)
@@ -215,9 +238,8 @@ func (ba *BillingApp) CreditUserAccount(ctx context.Context, in *CreditAccountRe
 			return nil, &AppError{ErrDBFailedToLockOperationTableForInsert, http.StatusInternalServerError}
 		}
 
-		var idempotencyToken string
-		err := tx.GetContext(ctx, &idempotencyToken, `SELECT idempotency_token FROM "Operation" WHERE idempotency_token = $1 LIMIT 1`, in.IdempotencyToken)
-		if err != nil && err != sql.ErrNoRows {
+		tokenIsUsed, err := tx.Idempotency().TokenIsUsed(ctx, in.IdempotencyToken)
+		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("CreditUserAccount, %s, err %v", ctxErr.Error(), err)
 				return nil, &AppError{ctxErr, http.StatusBadRequest}
@@ -227,12 +249,22 @@ func (ba *BillingApp) CreditUserAccount(ctx context.Context, in *CreditAccountRe
 			return nil, &AppError{ErrFailedToCheckIdempotencyTokenExistenceInDB, http.StatusInternalServerError}
 		}
 
-		if err == nil {
+		if tokenIsUsed {
 			ba.logger.Info("CreditUserAccount, operation token found in database, returning success response")
-			return &ResultState{State: OperationTokenIsAlreadyUsed}, nil
+			result, err := ba.replayOperation(ctx, "CreditUserAccount", tx, in.IdempotencyToken)
+			completed = err == nil
+			return result, err
+		}
+
+		if in.QuoteId != "" {
+			quote, err := ba.useQuote(ctx, "CreditUserAccount", tx, in.QuoteId, in.Amount, in.IdempotencyToken)
+			if err != nil {
+				return nil, err
+			}
+			amountToCredit = quote.BaseAmount()
 		}
 
-		_, err = tx.ExecContext(ctx, `LOCK TABLE "User" IN ROW SHARE MODE`)
+		err = tx.Users().LockForInsert(ctx)
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("CreditUserAccount, %s, err %v", ctxErr.Error(), err)
@@ -243,10 +275,8 @@ func (ba *BillingApp) CreditUserAccount(ctx context.Context, in *CreditAccountRe
 			return nil, &AppError{ErrDBFailedToLockUserTableForInsert, http.StatusInternalServerError}
 		}
 
-		user := &User{}
 		userAlreadyExist := true
-		err = tx.GetContext(ctx, user, `SELECT user_id, user_name,
-			balance, created_at FROM "User" WHERE user_id = $1 FOR UPDATE`, in.UserId)
+		user, err := tx.Users().Get(ctx, in.UserId, RowLockForUpdate)
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("CreditUserAccount, %s, err %v", ctxErr.Error(), err)
@@ -257,8 +287,7 @@ func (ba *BillingApp) CreditUserAccount(ctx context.Context, in *CreditAccountRe
 				ba.logger.Error("CreditUserAccount, %s, err %v", ErrDBFailedToFetchUserRow.Error(), err)
 				return nil, &AppError{ErrDBFailedToFetchUserRow, http.StatusInternalServerError}
 			} else {
-				_, err = tx.ExecContext(ctx, `INSERT INTO "User" (user_id, user_name, balance, created_at) VALUES ($1,$2,$3,$4)`,
-					in.UserId, in.Name, decimal.NewFromInt(0), time.Now())
+				err = tx.Users().Create(ctx, &User{Id: in.UserId, Name: in.Name, Balance: decimal.NewFromInt(0), CreatedAt: time.Now()})
 				if err != nil {
 					if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 						ba.logger.Error("CreditUserAccount, %s, err %v", ctxErr.Error(), err)
@@ -285,7 +314,7 @@ func (ba *BillingApp) CreditUserAccount(ctx context.Context, in *CreditAccountRe
 			return nil, &AppError{ErrAmountToStoreExceedsMaximumValue, http.StatusBadRequest}
 		}
 
-		_, err = tx.ExecContext(ctx, `UPDATE "User" SET balance=balance+$1 WHERE user_id=$2`, amountToCredit, in.UserId)
+		err = tx.Users().AddToBalance(ctx, in.UserId, amountToCredit)
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("CreditUserAccount, %s, err %v", ctxErr.Error(), err)
@@ -296,8 +325,13 @@ func (ba *BillingApp) CreditUserAccount(ctx context.Context, in *CreditAccountRe
 			return nil, &AppError{ErrDBFailedToUpdateUserRow, http.StatusInternalServerError}
 		}
 
-		_, err = tx.ExecContext(ctx, `INSERT INTO "Operation" (user_id, comment, amount, date, idempotency_token)
-				VALUES ($1,$2,$3,$4,$5)`, in.UserId, fmt.Sprintf(CommentTransferFromServiceWithComment, in.Purpose), in.Amount, time.Now(), in.IdempotencyToken)
+		err = tx.Operations().Insert(ctx, Operation{
+			UserId:           in.UserId,
+			Comment:          fmt.Sprintf(CommentTransferFromServiceWithComment, in.Purpose),
+			Amount:           amountToCredit,
+			Date:             time.Now(),
+			IdempotencyToken: in.IdempotencyToken,
+		})
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("CreditUserAccount, %s, err %v", ctxErr.Error(), err)
@@ -324,12 +358,10 @@ func (ba *BillingApp) CreditUserAccount(ctx context.Context, in *CreditAccountRe
 		return nil, &AppError{ErrDBTransactionCommitFailed, http.StatusInternalServerError}
 	}
 
-	err = ba.cache.AddKey(ctx, in.IdempotencyToken)
-	if err != nil {
-		ba.logger.Error("CreditUserAccount, %s,err: %v, key is not saved in cache", ErrCacheWriteFailed.Error(), err)
-	}
+	ba.invalidateCachedBalances("CreditUserAccount", in.UserId)
+	completed = true
 
-	return &ResultState{State: MsgAccountCreditingDone}, nil
+	return &ResultState{State: MsgAccountCreditingDone, ConsistencyToken: ba.consistencyToken("CreditUserAccount")}, nil
 }
 
 func (ba *BillingApp) WithdrawUserAccount(ctx context.Context, in *WithdrawAccountRequest) (*ResultState, error) {
@@ -343,55 +375,41 @@ func (ba *BillingApp) WithdrawUserAccount(ctx context.Context, in *WithdrawAccou
 		return nil, &AppError{ErrIdempotencyTokenIsEmpty, http.StatusBadRequest}
 	}
 
-	found, err := ba.cache.CheckKeyExistence(ctx, in.IdempotencyToken)
-	if err != nil {
-		ba.logger.Error("WithdrawUserAccount, %s,err: %v, performing lookup in database", ErrCacheLookupFailed.Error(), err)
-	}
-	if found {
-		ba.logger.Info("WithdrawUserAccount, operation token found in cache, returning success response")
-		return &ResultState{State: OperationTokenIsAlreadyUsed}, nil
-	}
-
-	amountToWithdraw, err := decimal.NewFromString(in.Amount)
+	began, done, err := ba.beginIdempotentOperation(ctx, "WithdrawUserAccount", EndpointWithdraw, in.IdempotencyToken)
 	if err != nil {
-		ba.logger.Error("WithdrawUserAccount, %s, err %v", ErrFailedToCastAmountToDecimal.Error(), err)
-		return nil, &AppError{ErrFailedToCastAmountToDecimal, http.StatusBadRequest}
+		return nil, err
 	}
-
-	if amountToWithdraw.IsNegative() {
-		ba.logger.Error("WithdrawUserAccount, %s", ErrAmountValueIsNegative.Error())
-		return nil, &AppError{ErrAmountValueIsNegative, http.StatusBadRequest}
+	if done {
+		return &ResultState{State: OperationTokenIsAlreadyUsed, ConsistencyToken: ba.consistencyToken("WithdrawUserAccount")}, nil
 	}
 
-	ba.mu.Lock()
-	minOpsMonetaryUnit := ba.cfg.MinOpsMonetaryUnit
-	maxDecimalWholeDigitsNum := ba.cfg.MaxDecimalWholeDigitsNum
-	maxDecimalFracDigitsNum := ba.cfg.MaxDecimalFracDigitsNum
-	ba.mu.Unlock()
+	completed := false
+	defer func() {
+		ba.finishIdempotentOperation("WithdrawUserAccount", EndpointWithdraw, in.IdempotencyToken, began, completed)
+	}()
 
-	if amountToWithdraw.LessThan(minOpsMonetaryUnit) {
-		ba.logger.Error("CreditUserAccount, %s", ErrAmountValueIsLessThanMin.Error())
-		return nil, &AppError{ErrAmountValueIsLessThanMin, http.StatusBadRequest}
-	}
+	//amount of operation with quote is taken from quote in transaction, where quote is locked
+	var amountToWithdraw decimal.Decimal
+	if in.QuoteId == "" {
+		amountToWithdraw, err = decimal.NewFromString(in.Amount)
+		if err != nil {
+			ba.logger.Error("WithdrawUserAccount, %s, err %v", ErrFailedToCastAmountToDecimal.Error(), err)
+			return nil, &AppError{ErrFailedToCastAmountToDecimal, http.StatusBadRequest}
+		}
 
-	pointSeparatedDecimalSlice := strings.Split(amountToWithdraw.String(), ".")
-	//check number of digits to the right of decimal point
-	if len(pointSeparatedDecimalSlice) > 1 {
-		gotDecimalFracDigitsNum := len(pointSeparatedDecimalSlice[1])
-		if gotDecimalFracDigitsNum > maxDecimalFracDigitsNum {
-			ba.logger.Error("CreditUserAccount, %s", ErrAmountHasExcessiveFractionalDigits.Error())
-			return nil, &AppError{ErrAmountHasExcessiveFractionalDigits, http.StatusBadRequest}
+		if amountToWithdraw.IsNegative() {
+			ba.logger.Error("WithdrawUserAccount, %s", ErrAmountValueIsNegative.Error())
+			return nil, &AppError{ErrAmountValueIsNegative, http.StatusBadRequest}
 		}
-	}
 
-	//check number of digits to the left of decimal point
-	gotDecimalWholeDigitsNum := pointSeparatedDecimalSlice[0]
-	if len(gotDecimalWholeDigitsNum) > maxDecimalWholeDigitsNum {
-		ba.logger.Error("CreditUserAccount, %s", ErrAmountHasExcessiveWholeDigits.Error())
-		return nil, &AppError{ErrAmountHasExcessiveWholeDigits, http.StatusBadRequest}
+		if err := ba.validateOperationAmount("WithdrawUserAccount", amountToWithdraw); err != nil {
+			return nil, err
+		}
 	}
+	cfg := ba.GetConfig()
+	var fee, bonusPart decimal.Decimal
 
-	tx, err := ba.db.BeginTxx(ctx, &sql.TxOptions{
+	tx, err := ba.storage.BeginTx(ctx, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted, ReadOnly: false})
 	if err != nil {
 		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
@@ -414,7 +432,7 @@ func (ba *BillingApp) WithdrawUserAccount(ctx context.Context, in *WithdrawAccou
 		}
 	}()
 	{
-		_, err = tx.ExecContext(ctx, `LOCK TABLE "Operation" IN  EXCLUSIVE MODE`)
+		err = tx.Operations().LockForInsert(ctx)
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("WithdrawUserAccount, %s, err %v", ctxErr.Error(), err)
@@ -425,9 +443,8 @@ func (ba *BillingApp) WithdrawUserAccount(ctx context.Context, in *WithdrawAccou
 			return nil, &AppError{ErrDBFailedToLockOperationTableForInsert, http.StatusInternalServerError}
 		}
 
-		var idempotencyToken string
-		err := tx.GetContext(ctx, &idempotencyToken, `SELECT idempotency_token FROM "Operation" WHERE idempotency_token = $1 LIMIT 1`, in.IdempotencyToken)
-		if err != nil && err != sql.ErrNoRows {
+		tokenIsUsed, err := tx.Idempotency().TokenIsUsed(ctx, in.IdempotencyToken)
+		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("WithdrawUserAccount, %s, err %v", ctxErr.Error(), err)
 				return nil, &AppError{ctxErr, http.StatusBadRequest}
@@ -437,14 +454,22 @@ func (ba *BillingApp) WithdrawUserAccount(ctx context.Context, in *WithdrawAccou
 			return nil, &AppError{ErrFailedToCheckIdempotencyTokenExistenceInDB, http.StatusInternalServerError}
 		}
 
-		if err == nil {
+		if tokenIsUsed {
 			ba.logger.Info("WithdrawUserAccount, operation token found in database, returning success response")
-			return &ResultState{State: OperationTokenIsAlreadyUsed}, nil
+			result, err := ba.replayOperation(ctx, "WithdrawUserAccount", tx, in.IdempotencyToken)
+			completed = err == nil
+			return result, err
 		}
 
-		user := &User{}
-		err = tx.GetContext(ctx, user, `SELECT user_id, user_name,
-			balance, created_at FROM "User" WHERE user_id = $1 FOR NO KEY UPDATE`, in.UserId)
+		if in.QuoteId != "" {
+			quote, err := ba.useQuote(ctx, "WithdrawUserAccount", tx, in.QuoteId, in.Amount, in.IdempotencyToken)
+			if err != nil {
+				return nil, err
+			}
+			amountToWithdraw = quote.BaseAmount()
+		}
+
+		user, err := tx.Users().Get(ctx, in.UserId, RowLockForNoKeyUpdate)
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("WithdrawUserAccount, %s, err %v", ctxErr.Error(), err)
@@ -460,12 +485,43 @@ func (ba *BillingApp) WithdrawUserAccount(ctx context.Context, in *WithdrawAccou
 			return nil, &AppError{ErrDBFailedToFetchUserRow, http.StatusInternalServerError}
 		}
 
-		if user.Balance.Sub(amountToWithdraw).IsNegative() {
+		//fee is debited in addition to amount of withdrawal
+		if !in.WithoutFee {
+			fee = cfg.operationFee(fees.OperationWithdraw, in.UserId, amountToWithdraw)
+		}
+
+		//part of amount is paid by bonus balance by policy, fee is paid by real balance
+		now := time.Now()
+		var grants []BonusGrant
+		bonusBalance := decimal.Zero
+		if !in.WithoutBonus && cfg.bonusWithdrawPolicy() != BonusPolicyRealOnly {
+			grants, err = ba.activeBonusGrants(ctx, "WithdrawUserAccount", tx, in.UserId, now, RowLockForUpdate)
+			if err != nil {
+				return nil, err
+			}
+			bonusBalance = sumRemaining(grants)
+			bonusPart = cfg.bonusPartOfWithdrawal(amountToWithdraw, fee, user.Balance, bonusBalance)
+		}
+		realPart := amountToWithdraw.Sub(bonusPart)
+
+		if user.Balance.Sub(realPart).Sub(fee).IsNegative() {
 			ba.logger.Error("WithdrawUserAccount, %s", ErrUserDoesNotHaveEnoughMoney.Error())
-			return nil, &AppError{ErrUserDoesNotHaveEnoughMoney, http.StatusBadRequest}
+			details := map[string]interface{}{"balance": user.Balance.String(), "amount": amountToWithdraw.String()}
+			if fee.IsPositive() {
+				details["fee"] = fee.String()
+			}
+			if bonusBalance.IsPositive() {
+				details["bonus_balance"] = bonusBalance.String()
+			}
+			return nil, &AppError{WithDetails(ErrUserDoesNotHaveEnoughMoney, details), http.StatusBadRequest}
+		}
+
+		err = ba.spendBonusGrants(ctx, "WithdrawUserAccount", tx, grants, bonusPart)
+		if err != nil {
+			return nil, err
 		}
 
-		_, err = tx.ExecContext(ctx, `UPDATE "User" SET balance=balance-$1 WHERE user_id=$2`, in.Amount, in.UserId)
+		err = tx.Users().AddToBalance(ctx, in.UserId, realPart.Add(fee).Neg())
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("WithdrawUserAccount, %s, err %v", ctxErr.Error(), err)
@@ -476,8 +532,35 @@ func (ba *BillingApp) WithdrawUserAccount(ctx context.Context, in *WithdrawAccou
 			return nil, &AppError{ErrDBFailedToUpdateUserRow, http.StatusInternalServerError}
 		}
 
-		_, err = tx.ExecContext(ctx, `INSERT INTO "Operation" (user_id, comment, amount, date,idempotency_token)
-				VALUES ($1,$2,$3,$4,$5)`, in.UserId, fmt.Sprintf(CommentTransferToServiceWithComment, in.Purpose), "-"+in.Amount, time.Now(), in.IdempotencyToken)
+		operations := []Operation{{
+			UserId:           in.UserId,
+			Comment:          fmt.Sprintf(CommentTransferToServiceWithComment, in.Purpose),
+			Amount:           realPart.Neg(),
+			Date:             now,
+			IdempotencyToken: in.IdempotencyToken,
+		}}
+		if bonusPart.IsPositive() {
+			spentBonus := bonusPart.Neg()
+			operations[0].BonusAmount = &spentBonus
+		}
+
+		if fee.IsPositive() {
+			feeOperation, err := ba.creditFeesAccount(ctx, "WithdrawUserAccount", tx, &cfg, fee, user.Name, now,
+				in.IdempotencyToken)
+			if err != nil {
+				return nil, err
+			}
+
+			operations = append(operations, Operation{
+				UserId:           in.UserId,
+				Comment:          fmt.Sprintf(CommentFeeOfPaymentToService, in.Purpose),
+				Amount:           fee.Neg(),
+				Date:             now,
+				IdempotencyToken: in.IdempotencyToken,
+			}, *feeOperation)
+		}
+
+		err = tx.Operations().Insert(ctx, operations...)
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("WithdrawUserAccount, %s, err %v", ctxErr.Error(), err)
@@ -504,14 +587,24 @@ func (ba *BillingApp) WithdrawUserAccount(ctx context.Context, in *WithdrawAccou
 		return nil, &AppError{ErrDBTransactionCommitFailed, http.StatusInternalServerError}
 	}
 
-	err = ba.cache.AddKey(ctx, in.IdempotencyToken)
-	if err != nil {
-		ba.logger.Error("WithdrawUserAccount, %s,err: %v, key is not saved in cache", ErrCacheWriteFailed.Error(), err)
+	invalidatedUserIds := []int64{in.UserId}
+	if fee.IsPositive() {
+		invalidatedUserIds = append(invalidatedUserIds, cfg.FeesAccountId)
 	}
+	ba.invalidateCachedBalances("WithdrawUserAccount", invalidatedUserIds...)
+	completed = true
 
-	return &ResultState{State: MsgAccountWithdrawDone}, nil
+	result := &ResultState{State: MsgAccountWithdrawDone, ConsistencyToken: ba.consistencyToken("WithdrawUserAccount")}
+	if fee.IsPositive() {
+		result.Fee = &fee
+	}
+	if bonusPart.IsPositive() {
+		result.BonusAmount = &bonusPart
+	}
+	return result, nil
 }
 
+//TransferMoneyFromUserToUser transfers real money only, bonus balance may not be transferred to other users
 func (ba *BillingApp) TransferMoneyFromUserToUser(ctx context.Context, in *MoneyTransferRequest) (*ResultState, error) {
 	if in == nil {
 		ba.logger.Error("TransferMoneyFromUserToUser, %s", ErrParamsStructIsNil.Error())
@@ -523,61 +616,59 @@ func (ba *BillingApp) TransferMoneyFromUserToUser(ctx context.Context, in *Money
 		return nil, &AppError{ErrIdempotencyTokenIsEmpty, http.StatusBadRequest}
 	}
 
-	found, err := ba.cache.CheckKeyExistence(ctx, in.IdempotencyToken)
+	began, done, err := ba.beginIdempotentOperation(ctx, "TransferMoneyFromUserToUser", EndpointTransfer, in.IdempotencyToken)
 	if err != nil {
-		ba.logger.Error("TransferMoneyFromUserToUser, %s,err: %v, performing lookup in database", ErrCacheLookupFailed.Error(), err)
+		return nil, err
 	}
-
-	if found {
-		ba.logger.Info("TransferMoneyFromUserToUser, operation token found in cache, returning success response")
-		return &ResultState{State: OperationTokenIsAlreadyUsed}, nil
+	if done {
+		return &ResultState{State: OperationTokenIsAlreadyUsed, ConsistencyToken: ba.consistencyToken("TransferMoneyFromUserToUser")}, nil
 	}
 
+	completed := false
+	defer func() {
+		ba.finishIdempotentOperation("TransferMoneyFromUserToUser", EndpointTransfer, in.IdempotencyToken, began, completed)
+	}()
+
 	if in.ReceiverId == in.SenderId {
 		ba.logger.Error("TransferMoneyFromUserToUser, %s", ErrSenderIdIsEqualToReceiverId.Error())
 		return nil, &AppError{ErrSenderIdIsEqualToReceiverId, http.StatusBadRequest}
 	}
 
-	amountToTransfer, err := decimal.NewFromString(in.Amount)
-	if err != nil {
-		ba.logger.Error("TransferMoneyFromUserToUser, %s, err %v", ErrFailedToCastAmountToDecimal.Error(), err)
-		return nil, &AppError{ErrFailedToCastAmountToDecimal, http.StatusBadRequest}
-	}
-
-	if amountToTransfer.IsNegative() {
-		ba.logger.Error("TransferMoneyFromUserToUser, %s", ErrAmountValueIsNegative.Error())
-		return nil, &AppError{ErrAmountValueIsNegative, http.StatusBadRequest}
-	}
-
-	ba.mu.Lock()
-	minOpsMonetaryUnit := ba.cfg.MinOpsMonetaryUnit
-	maxDecimalWholeDigitsNum := ba.cfg.MaxDecimalWholeDigitsNum
-	maxDecimalFracDigitsNum := ba.cfg.MaxDecimalFracDigitsNum
-	ba.mu.Unlock()
-
-	if amountToTransfer.LessThan(minOpsMonetaryUnit) {
-		ba.logger.Error("CreditUserAccount, %s", ErrAmountValueIsLessThanMin.Error())
-		return nil, &AppError{ErrAmountValueIsLessThanMin, http.StatusBadRequest}
-	}
+	//amount of operation with quote is taken from quote in transaction, where quote is locked.
+	//Amount in other currency is converted by snapshot of current rates, which is stored as used quote
+	amountCurrency := strings.ToUpper(in.Currency)
+	var amountToTransfer decimal.Decimal
+	var conversionQuote *Quote
+	if in.QuoteId == "" {
+		amount, err := decimal.NewFromString(in.Amount)
+		if err != nil {
+			ba.logger.Error("TransferMoneyFromUserToUser, %s, err %v", ErrFailedToCastAmountToDecimal.Error(), err)
+			return nil, &AppError{ErrFailedToCastAmountToDecimal, http.StatusBadRequest}
+		}
 
-	pointSeparatedDecimalSlice := strings.Split(amountToTransfer.String(), ".")
-	//check number of digits to the right of decimal point
-	if len(pointSeparatedDecimalSlice) > 1 {
-		gotDecimalFracDigitsNum := len(pointSeparatedDecimalSlice[1])
-		if gotDecimalFracDigitsNum > maxDecimalFracDigitsNum {
-			ba.logger.Error("CreditUserAccount, %s", ErrAmountHasExcessiveFractionalDigits.Error())
-			return nil, &AppError{ErrAmountHasExcessiveFractionalDigits, http.StatusBadRequest}
+		if amount.IsNegative() {
+			ba.logger.Error("TransferMoneyFromUserToUser, %s", ErrAmountValueIsNegative.Error())
+			return nil, &AppError{ErrAmountValueIsNegative, http.StatusBadRequest}
 		}
-	}
 
-	//check number of digits to the left of decimal point
-	gotDecimalWholeDigitsNum := pointSeparatedDecimalSlice[0]
-	if len(gotDecimalWholeDigitsNum) > maxDecimalWholeDigitsNum {
-		ba.logger.Error("CreditUserAccount, %s", ErrAmountHasExcessiveWholeDigits.Error())
-		return nil, &AppError{ErrAmountHasExcessiveWholeDigits, http.StatusBadRequest}
+		if amountCurrency == "" || amountCurrency == exchanger.RUBCode {
+			if err := ba.validateOperationAmount("TransferMoneyFromUserToUser", amount); err != nil {
+				return nil, err
+			}
+			amountToTransfer = amount
+		} else {
+			conversionQuote, err = ba.newQuote(ctx, "TransferMoneyFromUserToUser", amountCurrency, exchanger.RUBCode, amount)
+			if err != nil {
+				return nil, err
+			}
+			amountToTransfer = conversionQuote.ConvertedAmount
+		}
 	}
+	cfg := ba.GetConfig()
+	maxDecimalWholeDigitsNum := cfg.MaxDecimalWholeDigitsNum
+	var amountToCredit, spread, fee decimal.Decimal
 
-	tx, err := ba.db.BeginTxx(ctx, &sql.TxOptions{
+	tx, err := ba.storage.BeginTx(ctx, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted, ReadOnly: false})
 	if err != nil {
 		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
@@ -600,7 +691,7 @@ func (ba *BillingApp) TransferMoneyFromUserToUser(ctx context.Context, in *Money
 		}
 	}()
 	{
-		_, err = tx.ExecContext(ctx, `LOCK TABLE "Operation" IN  EXCLUSIVE MODE`)
+		err = tx.Operations().LockForInsert(ctx)
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("TransferMoneyFromUserToUser, %s, err %v", ctxErr.Error(), err)
@@ -611,9 +702,8 @@ func (ba *BillingApp) TransferMoneyFromUserToUser(ctx context.Context, in *Money
 			return nil, &AppError{ErrDBFailedToLockOperationTableForInsert, http.StatusInternalServerError}
 		}
 
-		var idempotencyToken string
-		err := tx.GetContext(ctx, &idempotencyToken, `SELECT idempotency_token FROM "Operation" WHERE idempotency_token = $1 LIMIT 1`, in.IdempotencyToken)
-		if err != nil && err != sql.ErrNoRows {
+		tokenIsUsed, err := tx.Idempotency().TokenIsUsed(ctx, in.IdempotencyToken)
+		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("TransferMoneyFromUserToUser, %s, err %v", ctxErr.Error(), err)
 				return nil, &AppError{ctxErr, http.StatusBadRequest}
@@ -622,14 +712,45 @@ func (ba *BillingApp) TransferMoneyFromUserToUser(ctx context.Context, in *Money
 			ba.logger.Error("TransferMoneyFromUserToUser, %s, err %v", ErrFailedToCheckIdempotencyTokenExistenceInDB.Error(), err)
 			return nil, &AppError{ErrFailedToCheckIdempotencyTokenExistenceInDB, http.StatusInternalServerError}
 		}
-		if err == nil {
+		if tokenIsUsed {
 			ba.logger.Info("TransferMoneyFromUserToUser, operation token found in database, returning success response")
-			return &ResultState{State: OperationTokenIsAlreadyUsed}, nil
+			result, err := ba.replayOperation(ctx, "TransferMoneyFromUserToUser", tx, in.IdempotencyToken)
+			completed = err == nil
+			return result, err
+		}
+
+		if in.QuoteId != "" {
+			quote, err := ba.useQuote(ctx, "TransferMoneyFromUserToUser", tx, in.QuoteId, in.Amount, in.IdempotencyToken)
+			if err != nil {
+				return nil, err
+			}
+
+			if amountCurrency != "" && amountCurrency != quote.FromCurrency {
+				ba.logger.Error("TransferMoneyFromUserToUser, %s, quote %s, currency %s", ErrQuoteAmountMismatch.Error(),
+					in.QuoteId, in.Currency)
+				return nil, &AppError{WithDetails(ErrQuoteAmountMismatch,
+					map[string]interface{}{"quote_currency": quote.FromCurrency}), http.StatusBadRequest}
+			}
+
+			amountToTransfer = quote.BaseAmount()
+			if quote.FromCurrency != exchanger.RUBCode {
+				conversionQuote = quote
+			}
+		} else if conversionQuote != nil {
+			err = ba.storeUsedQuote(ctx, "TransferMoneyFromUserToUser", tx, conversionQuote, in.IdempotencyToken)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		//receiver of cross-currency transfer is credited with converted amount less conversion spread
+		amountToCredit = amountToTransfer
+		if conversionQuote != nil {
+			spread = cfg.currencies().Get(exchanger.RUBCode).Round(amountToTransfer.Mul(cfg.ConversionSpread))
+			amountToCredit = amountToTransfer.Sub(spread)
 		}
 
-		usersInvolved := make([]User, 0)
-		err = tx.SelectContext(ctx, &usersInvolved, `SELECT user_id, user_name,
-			balance, created_at FROM "User" WHERE user_id = $1 OR user_id = $2 ORDER BY user_id FOR NO KEY UPDATE`, in.SenderId, in.ReceiverId)
+		usersInvolved, err := tx.Users().GetMany(ctx, []int64{in.SenderId, in.ReceiverId}, RowLockForNoKeyUpdate)
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("TransferMoneyFromUserToUser, %s, err %v", ctxErr.Error(), err)
@@ -673,20 +794,50 @@ func (ba *BillingApp) TransferMoneyFromUserToUser(ctx context.Context, in *Money
 			return nil, &AppError{ErrMoneyReceiverDoesNotExist, http.StatusBadRequest}
 		}
 
-		if senderUser.Balance.Sub(amountToTransfer).IsNegative() {
+		//fee is debited from sender in addition to amount of transfer
+		fee = cfg.operationFee(fees.OperationTransfer, in.SenderId, amountToTransfer)
+		if senderUser.Balance.Sub(amountToTransfer).Sub(fee).IsNegative() {
 			ba.logger.Error("TransferMoneyFromUserToUser, %s", ErrUserDoesNotHaveEnoughMoney.Error())
-			return nil, &AppError{ErrUserDoesNotHaveEnoughMoney, http.StatusBadRequest}
+			details := map[string]interface{}{"balance": senderUser.Balance.String(), "amount": amountToTransfer.String()}
+			if fee.IsPositive() {
+				details["fee"] = fee.String()
+			}
+			return nil, &AppError{WithDetails(ErrUserDoesNotHaveEnoughMoney, details), http.StatusBadRequest}
 		}
 
 		maxPossibleDecimal := decimal.New(1, int32(maxDecimalWholeDigitsNum))
-		expectedReceiverNewBalance := receiverUser.Balance.Add(amountToTransfer)
+		expectedReceiverNewBalance := receiverUser.Balance.Add(amountToCredit)
 		if expectedReceiverNewBalance.GreaterThanOrEqual(maxPossibleDecimal) {
 			ba.logger.Error("TransferMoneyFromUserToUser, %s", ErrAmountToStoreExceedsMaximumValue.Error())
 			return nil, &AppError{ErrAmountToStoreExceedsMaximumValue, http.StatusBadRequest}
 
 		}
 
-		_, err = tx.ExecContext(ctx, `UPDATE "User" SET balance=balance-$1 WHERE user_id=$2`, amountToTransfer, in.SenderId)
+		if spread.IsPositive() {
+			revenueUser, err := tx.Users().Get(ctx, cfg.RevenueAccountId, RowLockForNoKeyUpdate)
+			if err != nil {
+				if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+					ba.logger.Error("TransferMoneyFromUserToUser, %s, err %v", ctxErr.Error(), err)
+					return nil, &AppError{ctxErr, http.StatusBadRequest}
+				}
+
+				if err == sql.ErrNoRows {
+					ba.logger.Error("TransferMoneyFromUserToUser, %s, user %d", ErrRevenueAccountDoesNotExist.Error(),
+						cfg.RevenueAccountId)
+					return nil, &AppError{ErrRevenueAccountDoesNotExist, http.StatusInternalServerError}
+				}
+
+				ba.logger.Error("TransferMoneyFromUserToUser, %s, err %v", ErrDBFailedToFetchUserRow.Error(), err)
+				return nil, &AppError{ErrDBFailedToFetchUserRow, http.StatusInternalServerError}
+			}
+
+			if revenueUser.Balance.Add(spread).GreaterThanOrEqual(maxPossibleDecimal) {
+				ba.logger.Error("TransferMoneyFromUserToUser, %s, revenue account", ErrAmountToStoreExceedsMaximumValue.Error())
+				return nil, &AppError{ErrAmountToStoreExceedsMaximumValue, http.StatusInternalServerError}
+			}
+		}
+
+		err = tx.Users().AddToBalance(ctx, in.SenderId, amountToTransfer.Add(fee).Neg())
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("TransferMoneyFromUserToUser, %s, err %v", ctxErr.Error(), err)
@@ -697,8 +848,7 @@ func (ba *BillingApp) TransferMoneyFromUserToUser(ctx context.Context, in *Money
 			return nil, &AppError{ErrDBFailedToUpdateUserRow, http.StatusInternalServerError}
 		}
 
-		_, err = tx.ExecContext(ctx, `UPDATE "User" SET balance=balance+$1 WHERE user_id=$2`,
-			amountToTransfer, in.ReceiverId)
+		err = tx.Users().AddToBalance(ctx, in.ReceiverId, amountToCredit)
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("TransferMoneyFromUserToUser, %s, err %v", ctxErr.Error(), err)
@@ -709,10 +859,59 @@ func (ba *BillingApp) TransferMoneyFromUserToUser(ctx context.Context, in *Money
 			return nil, &AppError{ErrDBFailedToUpdateUserRow, http.StatusInternalServerError}
 		}
 
-		_, err = tx.ExecContext(ctx, `INSERT INTO "Operation" ( user_id, comment, amount, date, idempotency_token)
-						VALUES ($1,$2,$3,$4,$8), ($5,$6,$7,$4,$8)`,
-			in.SenderId, fmt.Sprintf(CommentTransferToUserWithName, receiverUser.Name), "-"+in.Amount, time.Now(),
-			in.ReceiverId, fmt.Sprintf(CommentTransferFromUserWithName, senderUser.Name), in.Amount, in.IdempotencyToken)
+		now := time.Now()
+		operations := []Operation{withConversion(Operation{
+			UserId:           in.SenderId,
+			Comment:          fmt.Sprintf(CommentTransferToUserWithName, receiverUser.Name),
+			Amount:           amountToTransfer.Neg(),
+			Date:             now,
+			IdempotencyToken: in.IdempotencyToken,
+		}, conversionQuote), withConversion(Operation{
+			UserId:           in.ReceiverId,
+			Comment:          fmt.Sprintf(CommentTransferFromUserWithName, senderUser.Name),
+			Amount:           amountToCredit,
+			Date:             now,
+			IdempotencyToken: in.IdempotencyToken,
+		}, conversionQuote)}
+
+		if spread.IsPositive() {
+			err = tx.Users().AddToBalance(ctx, cfg.RevenueAccountId, spread)
+			if err != nil {
+				if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+					ba.logger.Error("TransferMoneyFromUserToUser, %s, err %v", ctxErr.Error(), err)
+					return nil, &AppError{ctxErr, http.StatusBadRequest}
+				}
+
+				ba.logger.Error("TransferMoneyFromUserToUser, %s, err %v", ErrDBFailedToUpdateUserRow.Error(), err)
+				return nil, &AppError{ErrDBFailedToUpdateUserRow, http.StatusInternalServerError}
+			}
+
+			operations = append(operations, withConversion(Operation{
+				UserId:           cfg.RevenueAccountId,
+				Comment:          fmt.Sprintf(CommentConversionSpreadOfTransfer, senderUser.Name, receiverUser.Name),
+				Amount:           spread,
+				Date:             now,
+				IdempotencyToken: in.IdempotencyToken,
+			}, conversionQuote))
+		}
+
+		if fee.IsPositive() {
+			feeOperation, err := ba.creditFeesAccount(ctx, "TransferMoneyFromUserToUser", tx, &cfg, fee, senderUser.Name,
+				now, in.IdempotencyToken)
+			if err != nil {
+				return nil, err
+			}
+
+			operations = append(operations, Operation{
+				UserId:           in.SenderId,
+				Comment:          fmt.Sprintf(CommentFeeOfTransferToUser, receiverUser.Name),
+				Amount:           fee.Neg(),
+				Date:             now,
+				IdempotencyToken: in.IdempotencyToken,
+			}, *feeOperation)
+		}
+
+		err = tx.Operations().Insert(ctx, operations...)
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("TransferMoneyFromUserToUser, %s, err %v", ctxErr.Error(), err)
@@ -738,12 +937,32 @@ func (ba *BillingApp) TransferMoneyFromUserToUser(ctx context.Context, in *Money
 		return nil, &AppError{ErrDBTransactionCommitFailed, http.StatusInternalServerError}
 	}
 
-	err = ba.cache.AddKey(ctx, in.IdempotencyToken)
-	if err != nil {
-		ba.logger.Error("TransferMoneyFromUserToUser, %s,err: %v, key is not saved in cache", ErrCacheWriteFailed.Error(), err)
+	invalidatedUserIds := []int64{in.SenderId, in.ReceiverId}
+	if spread.IsPositive() {
+		invalidatedUserIds = append(invalidatedUserIds, cfg.RevenueAccountId)
 	}
-
-	return &ResultState{State: MsgMoneyTransferDone}, nil
+	if fee.IsPositive() {
+		invalidatedUserIds = append(invalidatedUserIds, cfg.FeesAccountId)
+	}
+	ba.invalidateCachedBalances("TransferMoneyFromUserToUser", invalidatedUserIds...)
+	completed = true
+
+	result := &ResultState{State: MsgMoneyTransferDone, ConsistencyToken: ba.consistencyToken("TransferMoneyFromUserToUser")}
+	if conversionQuote != nil {
+		result.Conversion = &TransferConversion{
+			OriginalAmount:   conversionQuote.Amount,
+			OriginalCurrency: conversionQuote.FromCurrency,
+			ConvertedAmount:  conversionQuote.ConvertedAmount,
+			Rate:             conversionQuote.Rate,
+			RatesDate:        conversionQuote.RatesDate,
+			Spread:           spread,
+			CreditedAmount:   amountToCredit,
+		}
+	}
+	if fee.IsPositive() {
+		result.Fee = &fee
+	}
+	return result, nil
 
 }
 
@@ -767,6 +986,11 @@ func (ba *BillingApp) GetUserOperations(ctx context.Context, in *OperationLogReq
 		return nil, &AppError{ErrLimitParamIsLessThanMin, http.StatusBadRequest}
 	}
 
+	if err := ValidateConsistencyToken(in.ConsistencyToken); err != nil {
+		ba.logger.Error("GetUserOperations, %s, token %s", err.Error(), in.ConsistencyToken)
+		return nil, &AppError{err, http.StatusBadRequest}
+	}
+
 	if in.OrderField == "" {
 		in.OrderField = "date"
 	}
@@ -785,12 +1009,37 @@ func (ba *BillingApp) GetUserOperations(ctx context.Context, in *OperationLogReq
 		return nil, &AppError{ErrBadOrderDirectionParam, http.StatusBadRequest}
 	}
 
+	//optional period of operations, is passed to queries as [periodStart, periodEnd)
+	var periodStart, periodEnd *time.Time
+	if in.From != "" {
+		from, err := parseDate(in.From)
+		if err != nil {
+			ba.logger.Error("GetUserOperations, %s user %d, from %s", err.Error(), in.UserId, in.From)
+			return nil, &AppError{err, http.StatusBadRequest}
+		}
+		periodStart = &from
+	}
+
+	if in.To != "" {
+		to, err := parseDate(in.To)
+		if err != nil {
+			ba.logger.Error("GetUserOperations, %s user %d, to %s", err.Error(), in.UserId, in.To)
+			return nil, &AppError{err, http.StatusBadRequest}
+		}
+		to = to.AddDate(0, 0, 1)
+		periodEnd = &to
+	}
+
+	if periodStart != nil && periodEnd != nil && !periodStart.Before(*periodEnd) {
+		ba.logger.Error("GetUserOperations, %s user %d, from %s, to %s", ErrPeriodStartIsAfterEnd.Error(), in.UserId, in.From, in.To)
+		return nil, &AppError{ErrPeriodStartIsAfterEnd, http.StatusBadRequest}
+	}
+
 	zeroUserOperations := false
 	userOperations := make([]Operation, 0)
 	var allOperationsNum int64 = 0
 
-	tx, err := ba.db.BeginTxx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelReadCommitted, ReadOnly: false})
+	tx, _, err := ba.beginReadTx(ctx, "GetUserOperations", in.ConsistencyToken, sql.LevelReadCommitted)
 	if err != nil {
 		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 			ba.logger.Error("GetUserOperations, %s, err %v", ctxErr.Error(), err)
@@ -811,9 +1060,7 @@ func (ba *BillingApp) GetUserOperations(ctx context.Context, in *OperationLogReq
 		}
 	}()
 	{
-		user := &User{}
-		err := tx.GetContext(ctx, user, `SELECT user_id ,user_name,
-			balance, created_at FROM "User" WHERE user_id = $1`, in.UserId)
+		_, err := tx.Users().Get(ctx, in.UserId, RowLockNone)
 		if err != nil {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("GetUserOperations, %s, err %v", ctxErr.Error(), err)
@@ -829,14 +1076,17 @@ func (ba *BillingApp) GetUserOperations(ctx context.Context, in *OperationLogReq
 			return nil, &AppError{ErrDBFailedToFetchUserRow, http.StatusInternalServerError}
 		}
 
-		if in.Limit == -1 {
-			query := fmt.Sprintf(`SELECT * FROM "Operation" WHERE "Operation".user_id=$1 ORDER BY %s %s`, in.OrderField, in.OrderDirection)
-			err = tx.SelectContext(ctx, &userOperations, query, in.UserId)
-		} else {
-			query := fmt.Sprintf(`SELECT * FROM "Operation" WHERE "Operation".user_id=$1 ORDER BY %s %s LIMIT $2 OFFSET $3`, in.OrderField, in.OrderDirection)
-			err = tx.SelectContext(ctx, &userOperations, query, in.UserId, in.Limit, in.Limit*(in.Page-1))
+		filter := &OperationFilter{
+			UserId:         in.UserId,
+			From:           periodStart,
+			To:             periodEnd,
+			OrderField:     in.OrderField,
+			OrderDirection: in.OrderDirection,
+			Limit:          in.Limit,
+			Offset:         in.Limit * (in.Page - 1),
 		}
 
+		userOperations, err = tx.Operations().List(ctx, filter)
 		if err != nil && err != sql.ErrNoRows {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("GetUserOperations, %s, err %v", ctxErr.Error(), err)
@@ -850,7 +1100,7 @@ func (ba *BillingApp) GetUserOperations(ctx context.Context, in *OperationLogReq
 			zeroUserOperations = true
 		}
 
-		err = tx.Get(&allOperationsNum, `SELECT count(*) FROM "Operation" WHERE user_id=$1`, in.UserId)
+		allOperationsNum, err = tx.Operations().Count(ctx, filter)
 		if err != nil && err != sql.ErrNoRows {
 			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
 				ba.logger.Error("GetUserOperations, %s, err %v", ctxErr.Error(), err)
@@ -901,3 +1151,89 @@ func (ba *BillingApp) GetUserOperations(ctx context.Context, in *OperationLogReq
 	}, nil
 
 }
+
+//GetUserInfo returns user account info: name, balance currency and creation date
+func (ba *BillingApp) GetUserInfo(ctx context.Context, in *UserInfoRequest) (*UserInfo, error) {
+	if in == nil {
+		ba.logger.Error("GetUserInfo, %s", ErrParamsStructIsNil.Error())
+		return nil, &AppError{ErrParamsStructIsNil, http.StatusBadRequest}
+	}
+
+	tx, err := ba.storage.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted, ReadOnly: true})
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetUserInfo, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserInfo, %s, err %v", ErrDBTransactionBeginFailed.Error(), err)
+		return nil, &AppError{ErrDBTransactionBeginFailed, http.StatusInternalServerError}
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	user, err := tx.Users().Get(ctx, in.UserId, RowLockNone)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetUserInfo, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		if err == sql.ErrNoRows {
+			ba.logger.Error("GetUserInfo, %s, err %v", ErrUserDoesNotExist.Error(), err)
+			return nil, &AppError{ErrUserDoesNotExist, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetUserInfo, %s, err %v", ErrDBFailedToFetchUserRow.Error(), err)
+		return nil, &AppError{ErrDBFailedToFetchUserRow, http.StatusInternalServerError}
+	}
+
+	return &UserInfo{
+		UserId:    user.Id,
+		Name:      user.Name,
+		Currency:  exchanger.RUBCode,
+		CreatedAt: user.CreatedAt,
+	}, nil
+}
+
+//GetOperationsByIdempotencyToken returns operations, performed with given idempotency token.
+//Transfer operation is stored as two operations of sender and receiver, so up to two operations are returned
+func (ba *BillingApp) GetOperationsByIdempotencyToken(ctx context.Context, in *IdempotencyLookupRequest) ([]Operation, error) {
+	if in == nil {
+		ba.logger.Error("GetOperationsByIdempotencyToken, %s", ErrParamsStructIsNil.Error())
+		return nil, &AppError{ErrParamsStructIsNil, http.StatusBadRequest}
+	}
+
+	if in.IdempotencyToken == "" {
+		ba.logger.Error("GetOperationsByIdempotencyToken, %s", ErrIdempotencyTokenIsEmpty.Error())
+		return nil, &AppError{ErrIdempotencyTokenIsEmpty, http.StatusBadRequest}
+	}
+
+	tx, err := ba.storage.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted, ReadOnly: true})
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetOperationsByIdempotencyToken, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetOperationsByIdempotencyToken, %s, err %v", ErrDBTransactionBeginFailed.Error(), err)
+		return nil, &AppError{ErrDBTransactionBeginFailed, http.StatusInternalServerError}
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	operations, err := tx.Operations().ListByIdempotencyToken(ctx, in.IdempotencyToken)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetOperationsByIdempotencyToken, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetOperationsByIdempotencyToken, %s, err %v", ErrDBFailedToFetchOperationRows.Error(), err)
+		return nil, &AppError{ErrDBFailedToFetchOperationRows, http.StatusInternalServerError}
+	}
+
+	return operations, nil
+}
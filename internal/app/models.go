@@ -2,6 +2,7 @@ package app
 
 import (
 	"github.com/shopspring/decimal"
+	"job-backend-trainee-assignment/internal/exchanger"
 	"time"
 )
 
@@ -12,11 +13,19 @@ type BalanceRequest struct {
 	//required: true
 	//example: 1
 	UserId int64 `json:"user_id"`
-	//name of currency in which balance value is required
+	//name of currency in which balance value is required, supported currencies are listed at /v2/currencies
 	//required: false
-	//enum: RUB,USD,EUR
 	//default: RUB
 	Currency string `json:"currency"`
+	//names of currencies, balance is additionally required in. Balances are converted by one snapshot of rates,
+	//unknown currency is reported in its item
+	//required: false
+	//example: ["RUB","USD","EUR"]
+	Currencies []string `json:"currencies,omitempty"`
+	//consistency token, returned by write operation. Balance is read from replica only if replica has this write
+	//required: false
+	//example: 0/3000148
+	ConsistencyToken string `json:"consistency_token,omitempty"`
 }
 
 //swagger:model MoneyTransferRequest
@@ -36,10 +45,20 @@ type MoneyTransferRequest struct {
 	//minimum: 1.00
 	//example: 100
 	Amount string `json:"amount"`
+	//currency of amount. Balances are kept in base currency, so amount in other currency is converted to base currency
+	//by stored rate snapshot, receiver is credited with converted amount less conversion spread
+	//required: false
+	//default: RUB
+	//example: USD
+	Currency string `json:"currency,omitempty"`
 	//unique operation token (must be unique for any operation that changes data)
 	//required: true
 	//example: 123456789
 	IdempotencyToken string `json:"idempotency_token"`
+	//identifier of exchange rate quote, operation amount is the base currency amount of quote, locked at quote creation
+	//required: false
+	//example: 0b1c6f2e-8d7a-4a53-9d1e-3f1f1c5b2a7e
+	QuoteId string `json:"quote_id,omitempty"`
 }
 
 //swagger:model WithdrawAccountRequest
@@ -61,6 +80,14 @@ type WithdrawAccountRequest struct {
 	//required: true
 	//example: 123456789
 	IdempotencyToken string `json:"idempotency_token"`
+	//identifier of exchange rate quote, operation amount is the base currency amount of quote, locked at quote creation
+	//required: false
+	//example: 0b1c6f2e-8d7a-4a53-9d1e-3f1f1c5b2a7e
+	QuoteId string `json:"quote_id,omitempty"`
+	//WithoutFee is set by administrative adjustments, it may not be set by api clients
+	WithoutFee bool `json:"-"`
+	//WithoutBonus is set by administrative adjustments, only real balance is withdrawn
+	WithoutBonus bool `json:"-"`
 }
 
 //swagger:model CreditAccountRequest
@@ -85,6 +112,10 @@ type CreditAccountRequest struct {
 	//required: true
 	//example: 123456789
 	IdempotencyToken string `json:"idempotency_token"`
+	//identifier of exchange rate quote, operation amount is the base currency amount of quote, locked at quote creation
+	//required: false
+	//example: 0b1c6f2e-8d7a-4a53-9d1e-3f1f1c5b2a7e
+	QuoteId string `json:"quote_id,omitempty"`
 }
 
 //swagger:model
@@ -108,6 +139,24 @@ type Operation struct {
 	//unique token, used to perform the operation
 	//example: 123456789
 	IdempotencyToken string `json:"idempotency_token" db:"idempotency_token"`
+	//amount of cross-currency transfer in currency, given by sender
+	//example: 10
+	OriginalAmount *decimal.Decimal `json:"original_amount,omitempty" db:"original_amount"`
+	//currency of original amount
+	//example: USD
+	OriginalCurrency *string `json:"original_currency,omitempty" db:"original_currency"`
+	//original amount, converted to "RUB" before conversion spread
+	//example: 769.23
+	ConvertedAmount *decimal.Decimal `json:"converted_amount,omitempty" db:"converted_amount"`
+	//amount of "RUB" for one unit of original currency
+	//example: 76.923076923076923077
+	Rate *decimal.Decimal `json:"rate,omitempty" db:"rate"`
+	//date of exchange rates of conversion
+	//example: 2020-08-15
+	RatesDate *string `json:"rates_date,omitempty" db:"rates_date"`
+	//change of bonus balance in "RUB", it is negative for spent and expired bonus
+	//example: -50
+	BonusAmount *decimal.Decimal `json:"bonus_amount,omitempty" db:"bonus_amount"`
 }
 
 // swagger:model
@@ -155,6 +204,18 @@ type OperationLogRequest struct {
 	//default: -1
 	//required: false
 	Limit int64 `json:"limit"`
+	//first day of operations period in format YYYY-MM-DD (UTC)
+	//required: false
+	//example: 2020-08-01
+	From string `json:"from"`
+	//last day of operations period in format YYYY-MM-DD (UTC), included in period
+	//required: false
+	//example: 2020-08-31
+	To string `json:"to"`
+	//consistency token, returned by write operation. Operations are read from replica only if replica has this write
+	//required: false
+	//example: 0/3000148
+	ConsistencyToken string `json:"consistency_token,omitempty"`
 }
 
 // swagger:model UserBalance
@@ -167,6 +228,32 @@ type UserBalance struct {
 	//currency name of given balance value
 	//example: RUB
 	Currency string `json:"currency"`
+	//true if balance is converted by last known exchange rates, because exchange rates service is unavailable
+	//example: false
+	Stale bool `json:"stale,omitempty"`
+	//date of exchange rates, balances in requested currencies are converted by
+	//example: 2020-08-15
+	RatesDate string `json:"rates_date,omitempty"`
+	//balances in requested currencies, in order of request
+	Balances []CurrencyBalance `json:"balances,omitempty"`
+}
+
+// swagger:model CurrencyBalance
+//CurrencyBalance represents user balance in one of requested currencies
+//
+type CurrencyBalance struct {
+	//currency name of given balance value
+	//example: USD
+	Currency string `json:"currency"`
+	//User balance in currency, empty if balance failed to be converted
+	//example: 1.3
+	Balance string `json:"balance,omitempty"`
+	//code of error, balance failed to be converted with
+	//example: CURRENCY_NOT_FOUND
+	ErrorCode ErrorCode `json:"error_code,omitempty"`
+	//error, balance failed to be converted with
+	//example: currency with provided name was not found
+	Error string `json:"error,omitempty"`
 }
 
 // swagger:model OperationsLog
@@ -189,4 +276,332 @@ type ResultState struct {
 	// Example: account crediting done
 	//example: Money transfer operation done
 	State string `json:"state"`
+	//wal position of primary database after operation, pass it to reads to see result of operation.
+	//It is returned only if read replicas are used
+	//example: 0/3000148
+	ConsistencyToken string `json:"consistency_token,omitempty"`
+	//conversion of cross-currency transfer
+	Conversion *TransferConversion `json:"conversion,omitempty"`
+	//fee of transfer or withdrawal in base currency, it is debited from user in addition to operation amount
+	//example: 15
+	Fee *decimal.Decimal `json:"fee,omitempty"`
+	//part of withdrawal amount, paid by bonus balance
+	//example: 50
+	BonusAmount *decimal.Decimal `json:"bonus_amount,omitempty"`
+}
+
+// swagger:model TransferConversion
+// represents conversion of cross-currency transfer amount to base currency
+type TransferConversion struct {
+	//amount of transfer in currency, given by sender
+	//example: 10
+	OriginalAmount decimal.Decimal `json:"original_amount"`
+	//currency of original amount
+	//example: USD
+	OriginalCurrency string `json:"original_currency"`
+	//original amount in base currency, it is debited from sender
+	//example: 769.23
+	ConvertedAmount decimal.Decimal `json:"converted_amount"`
+	//amount of base currency for one unit of original currency
+	//example: 76.923076923076923077
+	Rate decimal.Decimal `json:"rate"`
+	//date of exchange rates of conversion
+	//example: 2020-08-15
+	RatesDate string `json:"rates_date"`
+	//conversion spread in base currency, it is booked to revenue account
+	//example: 7.69
+	Spread decimal.Decimal `json:"spread"`
+	//amount in base currency, credited to receiver
+	//example: 761.54
+	CreditedAmount decimal.Decimal `json:"credited_amount"`
+}
+
+//swagger:model UserInfoRequest
+//UserInfoRequest represents a request for user account info
+type UserInfoRequest struct {
+	//identifier of user
+	//required: true
+	//example: 1
+	UserId int64 `json:"user_id"`
+}
+
+//swagger:model UserInfo
+//UserInfo represents user account info
+type UserInfo struct {
+	//identifier of user
+	//example: 1
+	UserId int64 `json:"user_id"`
+	//user name to be shown to other users
+	//example: Mr. Jones
+	Name string `json:"name"`
+	//currency of user balance
+	//example: RUB
+	Currency string `json:"currency"`
+	//date, the user record was created
+	//example: 2020-08-10T10:23:58Z
+	CreatedAt time.Time `json:"created_at"`
+}
+
+//swagger:model IdempotencyLookupRequest
+//IdempotencyLookupRequest represents a request for operations, performed with idempotency token
+type IdempotencyLookupRequest struct {
+	//idempotency token of operation
+	//required: true
+	//example: 123456789
+	IdempotencyToken string `json:"idempotency_token"`
+}
+
+//swagger:model BalanceOnDateRequest
+//BalanceOnDateRequest represents a request for balance of user at the end of the given day
+type BalanceOnDateRequest struct {
+	//identifier of user
+	//required: true
+	//example: 1
+	UserId int64 `json:"user_id"`
+	//day in format YYYY-MM-DD (UTC)
+	//required: true
+	//example: 2020-08-11
+	Date string `json:"date"`
+}
+
+//swagger:model BalanceOnDate
+//BalanceOnDate represents balance of user at the end of the day
+type BalanceOnDate struct {
+	//identifier of user
+	//example: 1
+	UserId int64 `json:"user_id"`
+	//day in format YYYY-MM-DD (UTC)
+	//example: 2020-08-11
+	Date string `json:"date"`
+	//closing balance of the day
+	//example: 100
+	Balance string `json:"balance"`
+	//currency of balance
+	//example: RUB
+	Currency string `json:"currency"`
+}
+
+//swagger:model StatementRequest
+//StatementRequest represents a request for statement of user account for the period
+type StatementRequest struct {
+	//identifier of user
+	//required: true
+	//example: 1
+	UserId int64 `json:"user_id"`
+	//first day of period in format YYYY-MM-DD (UTC)
+	//required: true
+	//example: 2020-08-01
+	From string `json:"from"`
+	//last day of period in format YYYY-MM-DD (UTC), included in period
+	//required: true
+	//example: 2020-08-31
+	To string `json:"to"`
+}
+
+//StatementStreamRequest represents a request for operations of user account for the period, which are streamed
+//by pages with opening balance of the period
+type StatementStreamRequest struct {
+	UserId int64
+	//From is the first day of period in format YYYY-MM-DD (UTC)
+	From string
+	//To is the last day of period in format YYYY-MM-DD (UTC), included in period
+	To string
+	//PageSize is a number of operations, read at once
+	PageSize int64
+	//ConsistencyToken is a token, returned by write operation. Operations are read from replica only if replica has
+	//this write
+	ConsistencyToken string
+}
+
+//swagger:model Statement
+//Statement represents user account statement for the period
+type Statement struct {
+	//identifier of user
+	//example: 1
+	UserId int64 `json:"user_id"`
+	//first day of period
+	//example: 2020-08-01
+	From string `json:"from"`
+	//last day of period
+	//example: 2020-08-31
+	To string `json:"to"`
+	//currency of statement
+	//example: RUB
+	Currency string `json:"currency"`
+	//balance at the end of the day before period start
+	//example: 100
+	OpeningBalance string `json:"opening_balance"`
+	//all operations of the period, ordered by date
+	Operations []Operation `json:"operations"`
+	//sum of positive operations amounts
+	//example: 50
+	TotalCredits string `json:"total_credits"`
+	//sum of negative operations amounts
+	//example: -30
+	TotalDebits string `json:"total_debits"`
+	//balance at the end of the last day of period
+	//example: 120
+	ClosingBalance string `json:"closing_balance"`
+	//true if opening balance plus sum of operations equals closing balance
+	//example: true
+	Consistent bool `json:"consistent"`
+	//closing balance minus opening balance minus sum of operations, zero for consistent statement
+	//example: 0
+	Discrepancy string `json:"discrepancy"`
+}
+
+//BalanceSnapshot represents stored closing balance of user at the end of the day
+type BalanceSnapshot struct {
+	UserId    int64           `db:"user_id"`
+	Date      time.Time       `db:"snapshot_date"`
+	Balance   decimal.Decimal `db:"balance"`
+	CreatedAt time.Time       `db:"created_at"`
+}
+
+//swagger:model QuoteRequest
+//QuoteRequest represents a request for exchange rate quote, one of currencies must be base currency
+type QuoteRequest struct {
+	//currency of amount
+	//required: true
+	//example: USD
+	From string `json:"from"`
+	//currency of converted amount
+	//required: true
+	//example: RUB
+	To string `json:"to"`
+	//amount of money in "from" currency
+	//required: true
+	//example: 100
+	Amount string `json:"amount"`
+}
+
+//swagger:model QuoteLookupRequest
+//QuoteLookupRequest represents a request for quote with given identifier
+type QuoteLookupRequest struct {
+	//identifier of quote
+	//required: true
+	//example: 0b1c6f2e-8d7a-4a53-9d1e-3f1f1c5b2a7e
+	QuoteId string `json:"quote_id"`
+}
+
+//swagger:model Quote
+//Quote represents exchange rate, locked until expiration. Quote is stored for audit and may be used by one operation
+type Quote struct {
+	//identifier of quote
+	//example: 0b1c6f2e-8d7a-4a53-9d1e-3f1f1c5b2a7e
+	Id string `json:"quote_id" db:"quote_id"`
+	//currency of amount
+	//example: USD
+	FromCurrency string `json:"from" db:"from_currency"`
+	//currency of converted amount
+	//example: RUB
+	ToCurrency string `json:"to" db:"to_currency"`
+	//amount of money in "from" currency
+	//example: 100
+	Amount decimal.Decimal `json:"amount" db:"amount"`
+	//price of one unit of "from" currency in "to" currency
+	//example: 74.349442379182156134
+	Rate decimal.Decimal `json:"rate" db:"rate"`
+	//amount of money in "to" currency, rounded to its minor units
+	//example: 7434.94
+	ConvertedAmount decimal.Decimal `json:"converted_amount" db:"converted_amount"`
+	//date of exchange rates
+	//example: 2020-08-15
+	RatesDate string `json:"rates_date" db:"rates_date"`
+	//date, the quote was created
+	//example: 2020-08-15T10:23:58Z
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	//date, the quote may not be used after
+	//example: 2020-08-15T10:24:58Z
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	//date, the quote was used by operation
+	//example: 2020-08-15T10:24:12Z
+	UsedAt *time.Time `json:"used_at,omitempty" db:"used_at"`
+	//idempotency token of operation, which used the quote
+	//example: 123456789
+	IdempotencyToken *string `json:"idempotency_token,omitempty" db:"idempotency_token"`
+}
+
+//BaseAmount returns amount of quote in base currency
+func (q *Quote) BaseAmount() decimal.Decimal {
+	if q.FromCurrency == exchanger.RUBCode {
+		return q.Amount
+	}
+	return q.ConvertedAmount
+}
+
+//swagger:model BonusGrantRequest
+//BonusGrantRequest represents a request to grant promotional bonus to user
+type BonusGrantRequest struct {
+	//identifier of user
+	//required: true
+	//example: 1
+	UserId int64 `json:"user_id"`
+	//amount of bonus in "RUB"
+	//required: true
+	//minimum: 1.00
+	//example: 100
+	Amount string `json:"amount"`
+	//date, the bonus expires at
+	//required: true
+	//example: 2020-09-15T00:00:00Z
+	ExpiresAt time.Time `json:"expires_at"`
+	//bonus grant purpose
+	//example: promo campaign
+	Purpose string `json:"purpose"`
+	//unique operation token (must be unique for any operation that changes data)
+	//required: true
+	//example: 123456789
+	IdempotencyToken string `json:"idempotency_token"`
+}
+
+//swagger:model BonusGrant
+//BonusGrant represents promotional bonus, granted to user. Bonus may be spent on withdrawals only until expiration
+type BonusGrant struct {
+	//identifier of grant
+	//example: 1
+	Id int64 `json:"grant_id" db:"grant_id"`
+	//identifier of user
+	//example: 1
+	UserId int64 `json:"user_id" db:"user_id"`
+	//granted amount of bonus in "RUB"
+	//example: 100
+	Amount decimal.Decimal `json:"amount" db:"amount"`
+	//amount of bonus, which is not spent or expired yet
+	//example: 50
+	Remaining decimal.Decimal `json:"remaining" db:"remaining"`
+	//date, the bonus was granted
+	//example: 2020-08-15T10:23:58Z
+	GrantedAt time.Time `json:"granted_at" db:"granted_at"`
+	//date, the bonus expires at
+	//example: 2020-09-15T00:00:00Z
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	//token of grant operation
+	//example: 123456789
+	IdempotencyToken string `json:"idempotency_token" db:"idempotency_token"`
+}
+
+//swagger:model BonusBalanceRequest
+//BonusBalanceRequest represents a request for bonus balance of user
+type BonusBalanceRequest struct {
+	//identifier of user
+	//required: true
+	//example: 1
+	UserId int64 `json:"user_id"`
+}
+
+//swagger:model BonusBalance
+//BonusBalance represents bonus balance of user and its active grants
+type BonusBalance struct {
+	//identifier of user
+	//example: 1
+	UserId int64 `json:"user_id"`
+	//sum of remaining amounts of active grants
+	//example: 50
+	BonusBalance string `json:"bonus_balance"`
+	//currency of bonus balance
+	//example: RUB
+	Currency string `json:"currency"`
+	//active grants ordered by expiration, they are spent in this order
+	Grants []BonusGrant `json:"grants"`
 }
@@ -0,0 +1,328 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+	"strings"
+	"time"
+)
+
+//MySQLStorage keeps users, operations and balance snapshots in mysql 8 database.
+//LOCK TABLES commits transaction in mysql, so table locks of postgres are replaced by locks of "TableLock" rows:
+//insert lock of operations is an exclusive row lock, insert lock of users is a shared row lock.
+//Row locks are released on commit or rollback, as postgres table locks are
+type MySQLStorage struct {
+	db *sqlx.DB
+}
+
+func NewMySQLStorage(db *sqlx.DB) (*MySQLStorage, error) {
+	if db == nil {
+		return nil, fmt.Errorf("must provide non-nil sqlx.DB pointer")
+	}
+	return &MySQLStorage{db: db}, nil
+}
+
+func (ms *MySQLStorage) BeginTx(ctx context.Context, opts *sql.TxOptions) (IStorageTx, error) {
+	tx, err := ms.db.BeginTxx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &mysqlTx{tx: tx}, nil
+}
+
+//mysqlTx implements all repositories over one database transaction
+type mysqlTx struct {
+	tx *sqlx.Tx
+}
+
+func (mt *mysqlTx) Users() IUserRepository {
+	return mt
+}
+
+func (mt *mysqlTx) Operations() IOperationRepository {
+	return (*mysqlOperations)(mt)
+}
+
+func (mt *mysqlTx) Idempotency() IIdempotencyRepository {
+	return (*mysqlIdempotency)(mt)
+}
+
+func (mt *mysqlTx) Snapshots() ISnapshotRepository {
+	return (*mysqlSnapshots)(mt)
+}
+
+func (mt *mysqlTx) Quotes() IQuoteRepository {
+	return (*mysqlQuotes)(mt)
+}
+
+func (mt *mysqlTx) Bonuses() IBonusRepository {
+	return (*mysqlBonuses)(mt)
+}
+
+func (mt *mysqlTx) Commit() error {
+	return mt.tx.Commit()
+}
+
+func (mt *mysqlTx) Rollback() error {
+	return mt.tx.Rollback()
+}
+
+//lockTable locks row of table in "TableLock", row must exist
+func (mt *mysqlTx) lockTable(ctx context.Context, table string, exclusive bool) error {
+	mode := "FOR SHARE"
+	if exclusive {
+		mode = "FOR UPDATE"
+	}
+
+	var lockedTable string
+	err := mt.tx.GetContext(ctx, &lockedTable, "SELECT table_name FROM `TableLock` WHERE table_name = ? "+mode, table)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("lock row of table %s is not found", table)
+	}
+	return err
+}
+
+//mysqlRowLockClause returns locking clause of select, mysql has no lock of non key columns, so it is FOR UPDATE as well
+func mysqlRowLockClause(lock RowLock) string {
+	if lock == RowLockNone {
+		return ""
+	}
+	return " FOR UPDATE"
+}
+
+func (mt *mysqlTx) LockForInsert(ctx context.Context) error {
+	return mt.lockTable(ctx, "User", false)
+}
+
+func (mt *mysqlTx) Get(ctx context.Context, userId int64, lock RowLock) (*User, error) {
+	user := &User{}
+	err := mt.tx.GetContext(ctx, user, "SELECT user_id, user_name, balance, created_at FROM `User` WHERE user_id = ?"+
+		mysqlRowLockClause(lock), userId)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (mt *mysqlTx) GetMany(ctx context.Context, userIds []int64, lock RowLock) ([]User, error) {
+	users := make([]User, 0, len(userIds))
+	if len(userIds) == 0 {
+		return users, nil
+	}
+
+	query, args, err := sqlx.In("SELECT user_id, user_name, balance, created_at FROM `User` WHERE user_id IN (?) ORDER BY user_id"+
+		mysqlRowLockClause(lock), userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	err = mt.tx.SelectContext(ctx, &users, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (mt *mysqlTx) Create(ctx context.Context, user *User) error {
+	_, err := mt.tx.ExecContext(ctx, "INSERT INTO `User` (user_id, user_name, balance, created_at) VALUES (?,?,?,?)",
+		user.Id, user.Name, user.Balance, user.CreatedAt)
+	return err
+}
+
+func (mt *mysqlTx) AddToBalance(ctx context.Context, userId int64, amount decimal.Decimal) error {
+	_, err := mt.tx.ExecContext(ctx, "UPDATE `User` SET balance=balance+? WHERE user_id=?", amount, userId)
+	return err
+}
+
+type mysqlOperations mysqlTx
+
+//mysqlPeriodCond selects operations in optional period, NULL bound is not applied, takes bounds as from, from, to, to
+const mysqlPeriodCond = "(? IS NULL OR date >= ?) AND (? IS NULL OR date < ?)"
+
+func (mo *mysqlOperations) LockForInsert(ctx context.Context) error {
+	return (*mysqlTx)(mo).lockTable(ctx, "Operation", true)
+}
+
+func (mo *mysqlOperations) Insert(ctx context.Context, operations ...Operation) error {
+	if len(operations) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(operations))
+	args := make([]interface{}, 0, 11*len(operations))
+	for _, op := range operations {
+		values = append(values, "(?,?,?,?,?,?,?,?,?,?,?)")
+		args = append(args, op.UserId, op.Comment, op.Amount, op.Date, op.IdempotencyToken, op.OriginalAmount,
+			op.OriginalCurrency, op.ConvertedAmount, op.Rate, op.RatesDate, op.BonusAmount)
+	}
+
+	_, err := mo.tx.ExecContext(ctx, "INSERT INTO `Operation` (user_id, comment, amount, date, idempotency_token, "+
+		"original_amount, original_currency, converted_amount, rate, rates_date, bonus_amount) VALUES "+
+		strings.Join(values, ", "), args...)
+	return err
+}
+
+func (mo *mysqlOperations) List(ctx context.Context, filter *OperationFilter) ([]Operation, error) {
+	operations := make([]Operation, 0)
+
+	query := "SELECT * FROM `Operation` WHERE user_id=? AND " + mysqlPeriodCond
+	args := []interface{}{filter.UserId, filter.From, filter.From, filter.To, filter.To}
+	if filter.After != nil {
+		query += " AND (date, operation_id) > (?, ?)"
+		args = append(args, filter.After.Date, filter.After.Id)
+	}
+
+	//operation_id makes order stable for operations with equal field values, so pages do not overlap
+	query += fmt.Sprintf(" ORDER BY %s %s, operation_id %s", filter.OrderField, filter.OrderDirection,
+		filter.OrderDirection)
+	if filter.Limit != -1 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	err := mo.tx.SelectContext(ctx, &operations, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+func (mo *mysqlOperations) Count(ctx context.Context, filter *OperationFilter) (int64, error) {
+	var count int64
+	err := mo.tx.GetContext(ctx, &count, "SELECT count(*) FROM `Operation` WHERE user_id=? AND "+mysqlPeriodCond,
+		filter.UserId, filter.From, filter.From, filter.To, filter.To)
+	return count, err
+}
+
+func (mo *mysqlOperations) ListByIdempotencyToken(ctx context.Context, token string) ([]Operation, error) {
+	operations := make([]Operation, 0)
+	err := mo.tx.SelectContext(ctx, &operations, "SELECT * FROM `Operation` WHERE idempotency_token = ? ORDER BY operation_id",
+		token)
+	if err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+func (mo *mysqlOperations) SumAmount(ctx context.Context, userId int64, from, to *time.Time) (decimal.NullDecimal, error) {
+	var sum decimal.NullDecimal
+	err := mo.tx.GetContext(ctx, &sum, "SELECT sum(amount) FROM `Operation` WHERE user_id = ? AND "+mysqlPeriodCond,
+		userId, from, from, to, to)
+	return sum, err
+}
+
+type mysqlIdempotency mysqlTx
+
+func (mi *mysqlIdempotency) TokenIsUsed(ctx context.Context, token string) (bool, error) {
+	var idempotencyToken string
+	err := mi.tx.GetContext(ctx, &idempotencyToken, "SELECT idempotency_token FROM `Operation` WHERE idempotency_token = ? LIMIT 1",
+		token)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+type mysqlSnapshots mysqlTx
+
+func (ms *mysqlSnapshots) InsertClosingBalances(ctx context.Context, day, dayEnd time.Time) (int64, error) {
+	//no op update keeps stored snapshot, affected rows count of unchanged row is 0
+	res, err := ms.tx.ExecContext(ctx, "INSERT INTO `BalanceSnapshot` (user_id, snapshot_date, balance, created_at) "+
+		"SELECT u.user_id, ?, u.balance - COALESCE((SELECT sum(o.amount) FROM `Operation` o "+
+		"WHERE o.user_id = u.user_id AND o.date >= ?), 0), ? "+
+		"FROM `User` u WHERE u.created_at < ? "+
+		"ON DUPLICATE KEY UPDATE `BalanceSnapshot`.user_id = `BalanceSnapshot`.user_id",
+		day.Format(dateLayout), dayEnd, time.Now(), dayEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	stored, _ := res.RowsAffected()
+	return stored, nil
+}
+
+func (ms *mysqlSnapshots) GetLatest(ctx context.Context, userId int64, day time.Time) (*BalanceSnapshot, error) {
+	snapshot := &BalanceSnapshot{}
+	err := ms.tx.GetContext(ctx, snapshot, "SELECT user_id, snapshot_date, balance, created_at FROM `BalanceSnapshot` "+
+		"WHERE user_id = ? AND snapshot_date <= ? ORDER BY snapshot_date DESC LIMIT 1", userId, day.Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+type mysqlQuotes mysqlTx
+
+func (mq *mysqlQuotes) Insert(ctx context.Context, quote *Quote) error {
+	_, err := mq.tx.ExecContext(ctx, "INSERT INTO `ExchangeQuote` (quote_id, from_currency, to_currency, amount, rate, "+
+		"converted_amount, rates_date, created_at, expires_at) VALUES (?,?,?,?,?,?,?,?,?)",
+		quote.Id, quote.FromCurrency, quote.ToCurrency, quote.Amount, quote.Rate, quote.ConvertedAmount, quote.RatesDate,
+		quote.CreatedAt, quote.ExpiresAt)
+	return err
+}
+
+func (mq *mysqlQuotes) Get(ctx context.Context, quoteId string, lock RowLock) (*Quote, error) {
+	quote := &Quote{}
+	err := mq.tx.GetContext(ctx, quote, "SELECT quote_id, from_currency, to_currency, amount, rate, converted_amount, "+
+		"rates_date, created_at, expires_at, used_at, idempotency_token FROM `ExchangeQuote` WHERE quote_id = ?"+
+		mysqlRowLockClause(lock), quoteId)
+	if err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+func (mq *mysqlQuotes) MarkUsed(ctx context.Context, quoteId string, token string, usedAt time.Time) error {
+	_, err := mq.tx.ExecContext(ctx, "UPDATE `ExchangeQuote` SET used_at=?, idempotency_token=? WHERE quote_id=?",
+		usedAt, token, quoteId)
+	return err
+}
+
+type mysqlBonuses mysqlTx
+
+func (mb *mysqlBonuses) Insert(ctx context.Context, grant *BonusGrant) error {
+	res, err := mb.tx.ExecContext(ctx, "INSERT INTO `BonusGrant` (user_id, amount, remaining, granted_at, expires_at, "+
+		"idempotency_token) VALUES (?,?,?,?,?,?)",
+		grant.UserId, grant.Amount, grant.Remaining, grant.GrantedAt, grant.ExpiresAt, grant.IdempotencyToken)
+	if err != nil {
+		return err
+	}
+
+	grant.Id, err = res.LastInsertId()
+	return err
+}
+
+func (mb *mysqlBonuses) ListActive(ctx context.Context, userId int64, now time.Time,
+	lock RowLock) ([]BonusGrant, error) {
+	grants := make([]BonusGrant, 0)
+	err := mb.tx.SelectContext(ctx, &grants, "SELECT "+bonusColumns+" FROM `BonusGrant` "+
+		"WHERE user_id = ? AND remaining > 0 AND expires_at > ? ORDER BY expires_at, grant_id"+
+		mysqlRowLockClause(lock), userId, now)
+	if err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+func (mb *mysqlBonuses) ListExpired(ctx context.Context, now time.Time, limit int64,
+	lock RowLock) ([]BonusGrant, error) {
+	grants := make([]BonusGrant, 0)
+	err := mb.tx.SelectContext(ctx, &grants, "SELECT "+bonusColumns+" FROM `BonusGrant` "+
+		"WHERE remaining > 0 AND expires_at <= ? ORDER BY expires_at, grant_id LIMIT ?"+
+		mysqlRowLockClause(lock), now, limit)
+	if err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+func (mb *mysqlBonuses) SetRemaining(ctx context.Context, grantId int64, remaining decimal.Decimal) error {
+	_, err := mb.tx.ExecContext(ctx, "UPDATE `BonusGrant` SET remaining=? WHERE grant_id=?", remaining, grantId)
+	return err
+}
@@ -0,0 +1,159 @@
+// +build mysql_integration
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/cache"
+	"job-backend-trainee-assignment/internal/db_connector"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"job-backend-trainee-assignment/internal/logger"
+	"job-backend-trainee-assignment/internal/test_helpers"
+	"sync"
+	"testing"
+	"time"
+)
+
+const mysqlFilePathPrefix = "../../"
+
+func connectToMySQL(t *testing.T) (*viper.Viper, *sqlx.DB, func()) {
+	v := viper.New()
+
+	v.AddConfigPath(".")
+	v.AddConfigPath("../../")
+	v.SetConfigName("config")
+	v.AutomaticEnv()
+
+	err := v.ReadInConfig()
+	require.NoErrorf(t, err, "failed to read config file at: %s, err %v", "config", err)
+
+	var mysqlHost string
+	if v.GetString("MYSQL_DATABASE_HOST") != "" {
+		mysqlHost = v.GetString("MYSQL_DATABASE_HOST")
+	} else {
+		mysqlHost = v.GetString("testing_params.mysql_db_params.DATABASE_HOST")
+	}
+
+	dbConfig := &db_connector.Config{
+		DriverName:    db_connector.DriverMySQL,
+		DBUser:        v.GetString("testing_params.mysql_db_params.user"),
+		DBPass:        v.GetString("testing_params.mysql_db_params.password"),
+		DBName:        v.GetString("testing_params.mysql_db_params.db_name"),
+		DBPort:        v.GetString("testing_params.mysql_db_params.port"),
+		DBHost:        mysqlHost,
+		SSLMode:       "disable",
+		RetryInterval: v.GetDuration("db_params.conn_retry_interval") * time.Second,
+	}
+
+	dbConnTimeout := v.GetDuration("db_params.conn_timeout") * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), dbConnTimeout)
+	defer cancel()
+
+	db, dbCloseFunc, err := db_connector.DBConnectWithTimeout(ctx, dbConfig, &logger.DummyLogger{})
+	require.NoErrorf(t, err, "failed to connect to db,err %v", err)
+	return v, db, dbCloseFunc
+}
+
+func TestMySQLStorage_Behaviour(t *testing.T) {
+	v, db, dbCloseFunc := connectToMySQL(t)
+	defer dbCloseFunc()
+
+	caseTimeout := v.GetDuration("testing_params.test_case_timeout") * time.Second
+
+	testStorageBehaviour(t, func(t *testing.T) IStorage {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+
+		err := test_helpers.PrepareDB(ctx, db, test_helpers.Config{
+			InitFilePath:    mysqlFilePathPrefix + v.GetString("testing_params.mysql_db_init_file_path"),
+			CleanUpFilePath: mysqlFilePathPrefix + v.GetString("testing_params.mysql_db_cleanup_file_path"),
+		})
+		require.NoError(t, err, "PrepareDB must not return error")
+
+		storage, err := NewMySQLStorage(db)
+		require.NoError(t, err)
+		return storage
+	})
+}
+
+func TestBillingApp_WithMySQLStorage(t *testing.T) {
+	v, db, dbCloseFunc := connectToMySQL(t)
+	defer dbCloseFunc()
+
+	caseTimeout := v.GetDuration("testing_params.test_case_timeout") * time.Second
+
+	prepareDB := func(ctx context.Context, t *testing.T) {
+		err := test_helpers.PrepareDB(ctx, db, test_helpers.Config{
+			InitFilePath:    mysqlFilePathPrefix + v.GetString("testing_params.mysql_db_init_file_path"),
+			CleanUpFilePath: mysqlFilePathPrefix + v.GetString("testing_params.mysql_db_cleanup_file_path"),
+		})
+		require.NoError(t, err, "PrepareDB must not return error")
+	}
+
+	newApp := func(t *testing.T) *BillingApp {
+		app, err := NewApp(&logger.DummyLogger{}, db, &exchanger.StubExchanger{}, &cache.DummyCacheCommon{}, nil)
+		require.NoErrorf(t, err, "failed to create BillingApp instance, err %v", err)
+		_, ok := app.storage.(*MySQLStorage)
+		require.True(t, ok, "mysql storage must be chosen by driver name")
+		return app
+	}
+
+	t.Run("operations on seeded users", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+		app := newApp(t)
+
+		balance, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 2})
+		require.NoError(t, err)
+		assert.Equal(t, "10", balance.Balance)
+
+		res, err := app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 2, ReceiverId: 1, Amount: "4",
+			IdempotencyToken: "mysql-transfer"})
+		require.NoError(t, err)
+		assert.Equal(t, MsgMoneyTransferDone, res.State)
+
+		res, err = newApp(t).TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 2, ReceiverId: 1,
+			Amount: "4", IdempotencyToken: "mysql-transfer"})
+		require.NoError(t, err)
+		assert.Equal(t, OperationTokenIsAlreadyUsed, res.State, "token must be found in database")
+
+		log, err := app.GetUserOperations(ctx, &OperationLogRequest{UserId: 2, Limit: 2, Page: 1})
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), log.OperationsNum)
+		assert.Equal(t, int64(2), log.PagesTotal)
+
+		statement, err := app.GetUserStatement(ctx, &StatementRequest{UserId: 2, From: "2020-08-01", To: "2020-08-31"})
+		require.NoError(t, err)
+		assert.Equal(t, "10", statement.ClosingBalance)
+		assert.True(t, statement.Consistent)
+	})
+
+	t.Run("concurrent operations", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+		app := newApp(t)
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 3, Name: "new user", Amount: "1",
+					IdempotencyToken: fmt.Sprintf("mysql-credit-%d", i)})
+				assert.NoError(t, err)
+			}(i)
+		}
+		wg.Wait()
+
+		balance, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 3})
+		require.NoError(t, err)
+		assert.Equal(t, "20", balance.Balance)
+	})
+}
@@ -0,0 +1,318 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+	"strings"
+	"time"
+)
+
+//PostgresStorage keeps users, operations and balance snapshots in postgres database
+type PostgresStorage struct {
+	db *sqlx.DB
+}
+
+func NewPostgresStorage(db *sqlx.DB) (*PostgresStorage, error) {
+	if db == nil {
+		return nil, fmt.Errorf("must provide non-nil sqlx.DB pointer")
+	}
+	return &PostgresStorage{db: db}, nil
+}
+
+func (ps *PostgresStorage) BeginTx(ctx context.Context, opts *sql.TxOptions) (IStorageTx, error) {
+	tx, err := ps.db.BeginTxx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newPostgresTx(tx), nil
+}
+
+//postgresTx implements all repositories over one database transaction
+type postgresTx struct {
+	tx *sqlx.Tx
+}
+
+func newPostgresTx(tx *sqlx.Tx) *postgresTx {
+	return &postgresTx{tx: tx}
+}
+
+func (pt *postgresTx) Users() IUserRepository {
+	return pt
+}
+
+func (pt *postgresTx) Operations() IOperationRepository {
+	return (*postgresOperations)(pt)
+}
+
+func (pt *postgresTx) Idempotency() IIdempotencyRepository {
+	return (*postgresIdempotency)(pt)
+}
+
+func (pt *postgresTx) Snapshots() ISnapshotRepository {
+	return (*postgresSnapshots)(pt)
+}
+
+func (pt *postgresTx) Quotes() IQuoteRepository {
+	return (*postgresQuotes)(pt)
+}
+
+func (pt *postgresTx) Bonuses() IBonusRepository {
+	return (*postgresBonuses)(pt)
+}
+
+func (pt *postgresTx) Commit() error {
+	return pt.tx.Commit()
+}
+
+func (pt *postgresTx) Rollback() error {
+	return pt.tx.Rollback()
+}
+
+//rowLockClause returns locking clause of select
+func rowLockClause(lock RowLock) string {
+	switch lock {
+	case RowLockForUpdate:
+		return " FOR UPDATE"
+	case RowLockForNoKeyUpdate:
+		return " FOR NO KEY UPDATE"
+	default:
+		return ""
+	}
+}
+
+func (pt *postgresTx) LockForInsert(ctx context.Context) error {
+	_, err := pt.tx.ExecContext(ctx, `LOCK TABLE "User" IN ROW SHARE MODE`)
+	return err
+}
+
+func (pt *postgresTx) Get(ctx context.Context, userId int64, lock RowLock) (*User, error) {
+	user := &User{}
+	err := pt.tx.GetContext(ctx, user, `SELECT user_id, user_name,
+			balance, created_at FROM "User" WHERE user_id = $1`+rowLockClause(lock), userId)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (pt *postgresTx) GetMany(ctx context.Context, userIds []int64, lock RowLock) ([]User, error) {
+	users := make([]User, 0, len(userIds))
+	if len(userIds) == 0 {
+		return users, nil
+	}
+
+	query, args, err := sqlx.In(`SELECT user_id, user_name,
+			balance, created_at FROM "User" WHERE user_id IN (?) ORDER BY user_id`+rowLockClause(lock), userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pt.tx.SelectContext(ctx, &users, pt.tx.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (pt *postgresTx) Create(ctx context.Context, user *User) error {
+	_, err := pt.tx.ExecContext(ctx, `INSERT INTO "User" (user_id, user_name, balance, created_at) VALUES ($1,$2,$3,$4)`,
+		user.Id, user.Name, user.Balance, user.CreatedAt)
+	return err
+}
+
+func (pt *postgresTx) AddToBalance(ctx context.Context, userId int64, amount decimal.Decimal) error {
+	_, err := pt.tx.ExecContext(ctx, `UPDATE "User" SET balance=balance+$1 WHERE user_id=$2`, amount, userId)
+	return err
+}
+
+type postgresOperations postgresTx
+
+//periodCond selects operations in optional period, NULL bound is not applied
+const periodCond = `($2::timestamptz IS NULL OR date >= $2) AND ($3::timestamptz IS NULL OR date < $3)`
+
+func (po *postgresOperations) LockForInsert(ctx context.Context) error {
+	_, err := po.tx.ExecContext(ctx, `LOCK TABLE "Operation" IN EXCLUSIVE MODE`)
+	return err
+}
+
+func (po *postgresOperations) Insert(ctx context.Context, operations ...Operation) error {
+	if len(operations) == 0 {
+		return nil
+	}
+
+	const columnsNum = 11
+	values := make([]string, 0, len(operations))
+	args := make([]interface{}, 0, columnsNum*len(operations))
+	for i, op := range operations {
+		placeholders := make([]string, 0, columnsNum)
+		for j := 1; j <= columnsNum; j++ {
+			placeholders = append(placeholders, fmt.Sprintf("$%d", columnsNum*i+j))
+		}
+		values = append(values, "("+strings.Join(placeholders, ",")+")")
+		args = append(args, op.UserId, op.Comment, op.Amount, op.Date, op.IdempotencyToken, op.OriginalAmount,
+			op.OriginalCurrency, op.ConvertedAmount, op.Rate, op.RatesDate, op.BonusAmount)
+	}
+
+	_, err := po.tx.ExecContext(ctx, `INSERT INTO "Operation" (user_id, comment, amount, date, idempotency_token,
+				original_amount, original_currency, converted_amount, rate, rates_date, bonus_amount)
+				VALUES `+strings.Join(values, ", "), args...)
+	return err
+}
+
+func (po *postgresOperations) List(ctx context.Context, filter *OperationFilter) ([]Operation, error) {
+	operations := make([]Operation, 0)
+
+	query := `SELECT * FROM "Operation" WHERE "Operation".user_id=$1 AND ` + periodCond
+	args := []interface{}{filter.UserId, filter.From, filter.To}
+	if filter.After != nil {
+		args = append(args, filter.After.Date, filter.After.Id)
+		query += fmt.Sprintf(` AND (date, operation_id) > ($%d, $%d)`, len(args)-1, len(args))
+	}
+
+	//operation_id makes order stable for operations with equal field values, so pages do not overlap
+	query += fmt.Sprintf(` ORDER BY %s %s, operation_id %s`, filter.OrderField, filter.OrderDirection, filter.OrderDirection)
+	if filter.Limit != -1 {
+		args = append(args, filter.Limit, filter.Offset)
+		query += fmt.Sprintf(` LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+	}
+
+	err := po.tx.SelectContext(ctx, &operations, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+func (po *postgresOperations) Count(ctx context.Context, filter *OperationFilter) (int64, error) {
+	var count int64
+	err := po.tx.GetContext(ctx, &count, `SELECT count(*) FROM "Operation" WHERE user_id=$1 AND `+periodCond,
+		filter.UserId, filter.From, filter.To)
+	return count, err
+}
+
+func (po *postgresOperations) ListByIdempotencyToken(ctx context.Context, token string) ([]Operation, error) {
+	operations := make([]Operation, 0)
+	err := po.tx.SelectContext(ctx, &operations, `SELECT * FROM "Operation" WHERE idempotency_token = $1
+		ORDER BY operation_id`, token)
+	if err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+func (po *postgresOperations) SumAmount(ctx context.Context, userId int64, from, to *time.Time) (decimal.NullDecimal, error) {
+	var sum decimal.NullDecimal
+	err := po.tx.GetContext(ctx, &sum, `SELECT sum(amount) FROM "Operation" WHERE user_id = $1 AND `+periodCond,
+		userId, from, to)
+	return sum, err
+}
+
+type postgresIdempotency postgresTx
+
+func (pi *postgresIdempotency) TokenIsUsed(ctx context.Context, token string) (bool, error) {
+	var idempotencyToken string
+	err := pi.tx.GetContext(ctx, &idempotencyToken, `SELECT idempotency_token FROM "Operation" WHERE idempotency_token = $1 LIMIT 1`, token)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+type postgresSnapshots postgresTx
+
+func (ps *postgresSnapshots) InsertClosingBalances(ctx context.Context, day, dayEnd time.Time) (int64, error) {
+	res, err := ps.tx.ExecContext(ctx, `INSERT INTO "BalanceSnapshot" (user_id, snapshot_date, balance, created_at)
+		SELECT u.user_id, $1, u.balance - COALESCE((SELECT sum(o.amount) FROM "Operation" o
+			WHERE o.user_id = u.user_id AND o.date >= $2), 0), $3
+		FROM "User" u WHERE u.created_at < $2
+		ON CONFLICT (user_id, snapshot_date) DO NOTHING`, day.Format(dateLayout), dayEnd, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	stored, _ := res.RowsAffected()
+	return stored, nil
+}
+
+func (ps *postgresSnapshots) GetLatest(ctx context.Context, userId int64, day time.Time) (*BalanceSnapshot, error) {
+	snapshot := &BalanceSnapshot{}
+	err := ps.tx.GetContext(ctx, snapshot, `SELECT user_id, snapshot_date, balance, created_at FROM "BalanceSnapshot"
+		WHERE user_id = $1 AND snapshot_date <= $2 ORDER BY snapshot_date DESC LIMIT 1`, userId, day.Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+type postgresQuotes postgresTx
+
+func (pq *postgresQuotes) Insert(ctx context.Context, quote *Quote) error {
+	_, err := pq.tx.ExecContext(ctx, `INSERT INTO "ExchangeQuote" (quote_id, from_currency, to_currency, amount, rate,
+		converted_amount, rates_date, created_at, expires_at) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)`,
+		quote.Id, quote.FromCurrency, quote.ToCurrency, quote.Amount, quote.Rate, quote.ConvertedAmount, quote.RatesDate,
+		quote.CreatedAt, quote.ExpiresAt)
+	return err
+}
+
+func (pq *postgresQuotes) Get(ctx context.Context, quoteId string, lock RowLock) (*Quote, error) {
+	quote := &Quote{}
+	err := pq.tx.GetContext(ctx, quote, `SELECT quote_id, from_currency, to_currency, amount, rate, converted_amount,
+		rates_date, created_at, expires_at, used_at, idempotency_token FROM "ExchangeQuote" WHERE quote_id = $1`+
+		rowLockClause(lock), quoteId)
+	if err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+func (pq *postgresQuotes) MarkUsed(ctx context.Context, quoteId string, token string, usedAt time.Time) error {
+	_, err := pq.tx.ExecContext(ctx, `UPDATE "ExchangeQuote" SET used_at=$1, idempotency_token=$2 WHERE quote_id=$3`,
+		usedAt, token, quoteId)
+	return err
+}
+
+type postgresBonuses postgresTx
+
+//bonusColumns are selected columns of "BonusGrant"
+const bonusColumns = `grant_id, user_id, amount, remaining, granted_at, expires_at, idempotency_token`
+
+func (pb *postgresBonuses) Insert(ctx context.Context, grant *BonusGrant) error {
+	return pb.tx.GetContext(ctx, &grant.Id, `INSERT INTO "BonusGrant" (user_id, amount, remaining, granted_at,
+		expires_at, idempotency_token) VALUES ($1,$2,$3,$4,$5,$6) RETURNING grant_id`,
+		grant.UserId, grant.Amount, grant.Remaining, grant.GrantedAt, grant.ExpiresAt, grant.IdempotencyToken)
+}
+
+func (pb *postgresBonuses) ListActive(ctx context.Context, userId int64, now time.Time,
+	lock RowLock) ([]BonusGrant, error) {
+	grants := make([]BonusGrant, 0)
+	err := pb.tx.SelectContext(ctx, &grants, `SELECT `+bonusColumns+` FROM "BonusGrant"
+		WHERE user_id = $1 AND remaining > 0 AND expires_at > $2 ORDER BY expires_at, grant_id`+rowLockClause(lock),
+		userId, now)
+	if err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+func (pb *postgresBonuses) ListExpired(ctx context.Context, now time.Time, limit int64,
+	lock RowLock) ([]BonusGrant, error) {
+	grants := make([]BonusGrant, 0)
+	err := pb.tx.SelectContext(ctx, &grants, `SELECT `+bonusColumns+` FROM "BonusGrant"
+		WHERE remaining > 0 AND expires_at <= $1 ORDER BY expires_at, grant_id LIMIT $2`+rowLockClause(lock),
+		now, limit)
+	if err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+func (pb *postgresBonuses) SetRemaining(ctx context.Context, grantId int64, remaining decimal.Decimal) error {
+	_, err := pb.tx.ExecContext(ctx, `UPDATE "BonusGrant" SET remaining=$1 WHERE grant_id=$2`, remaining, grantId)
+	return err
+}
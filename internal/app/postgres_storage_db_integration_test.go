@@ -0,0 +1,70 @@
+// +build integration
+
+package app
+
+import (
+	"context"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/db_connector"
+	"job-backend-trainee-assignment/internal/logger"
+	"job-backend-trainee-assignment/internal/test_helpers"
+	"testing"
+	"time"
+)
+
+func TestPostgresStorage_Behaviour(t *testing.T) {
+	v := viper.New()
+
+	v.AddConfigPath(".")
+	v.AddConfigPath("../../")
+	v.SetConfigName("config")
+	v.AutomaticEnv()
+
+	err := v.ReadInConfig()
+	require.NoErrorf(t, err, "failed to read config file at: %s, err %v", "config", err)
+
+	var pgHost string
+	if v.GetString("DATABASE_HOST") != "" {
+		pgHost = v.GetString("DATABASE_HOST")
+	} else {
+		pgHost = v.GetString("db_params.DATABASE_HOST")
+	}
+
+	dbConfig := &db_connector.Config{
+		DriverName:    v.GetString("db_params.driver_name"),
+		DBUser:        v.GetString("db_params.user"),
+		DBPass:        v.GetString("db_params.password"),
+		DBName:        v.GetString("db_params.db_name"),
+		DBPort:        v.GetString("db_params.port"),
+		DBHost:        pgHost,
+		SSLMode:       v.GetString("db_params.ssl_mode"),
+		RetryInterval: v.GetDuration("db_params.conn_retry_interval") * time.Second,
+	}
+
+	dbConnTimeout := v.GetDuration("db_params.conn_timeout") * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), dbConnTimeout)
+	defer cancel()
+
+	db, dbCloseFunc, err := db_connector.DBConnectWithTimeout(ctx, dbConfig, &logger.DummyLogger{})
+	require.NoErrorf(t, err, "failed to connect to db,err %v", err)
+
+	defer dbCloseFunc()
+
+	caseTimeout := v.GetDuration("testing_params.test_case_timeout") * time.Second
+
+	testStorageBehaviour(t, func(t *testing.T) IStorage {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+
+		err := test_helpers.PrepareDB(ctx, db, test_helpers.Config{
+			InitFilePath:    filePathPrefix + v.GetString("testing_params.db_init_file_path"),
+			CleanUpFilePath: filePathPrefix + v.GetString("testing_params.db_cleanup_file_path"),
+		})
+		require.NoError(t, err, "PrepareDB must not return error")
+
+		storage, err := NewPostgresStorage(db)
+		require.NoError(t, err)
+		return storage
+	})
+}
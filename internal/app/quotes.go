@@ -0,0 +1,319 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//CreateQuote locks current exchange rate of amount for quote ttl and stores quote for audit.
+//One of currencies must be base currency, so quote may be used by operation, which changes balance in base currency
+func (ba *BillingApp) CreateQuote(ctx context.Context, in *QuoteRequest) (*Quote, error) {
+	if in == nil {
+		ba.logger.Error("CreateQuote, %s", ErrParamsStructIsNil.Error())
+		return nil, &AppError{ErrParamsStructIsNil, http.StatusBadRequest}
+	}
+
+	from := strings.ToUpper(in.From)
+	to := strings.ToUpper(in.To)
+	if from != exchanger.RUBCode && to != exchanger.RUBCode {
+		ba.logger.Error("CreateQuote, %s, from %s, to %s", ErrQuoteCurrencyIsNotBase.Error(), in.From, in.To)
+		return nil, &AppError{WithDetails(ErrQuoteCurrencyIsNotBase,
+			map[string]interface{}{"base_currency": exchanger.RUBCode}), http.StatusBadRequest}
+	}
+
+	amount, err := decimal.NewFromString(in.Amount)
+	if err != nil {
+		ba.logger.Error("CreateQuote, %s, err %v", ErrFailedToCastAmountToDecimal.Error(), err)
+		return nil, &AppError{ErrFailedToCastAmountToDecimal, http.StatusBadRequest}
+	}
+
+	if amount.IsNegative() {
+		ba.logger.Error("CreateQuote, %s", ErrAmountValueIsNegative.Error())
+		return nil, &AppError{ErrAmountValueIsNegative, http.StatusBadRequest}
+	}
+
+	quote, err := ba.newQuote(ctx, "CreateQuote", from, to, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := ba.storage.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted, ReadOnly: false})
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("CreateQuote, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("CreateQuote, %s, err %v", ErrDBTransactionBeginFailed.Error(), err)
+		return nil, &AppError{ErrDBTransactionBeginFailed, http.StatusInternalServerError}
+	}
+
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("CreateQuote, %s, err %v", ctxErr.Error(), err)
+			}
+
+			ba.logger.Error("CreateQuote, %s, err %v", ErrDBTransactionRollbackFailed.Error(), err)
+		}
+	}()
+
+	err = tx.Quotes().Insert(ctx, quote)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("CreateQuote, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("CreateQuote, %s, err %v", ErrDBFailedToInsertQuoteRow.Error(), err)
+		return nil, &AppError{ErrDBFailedToInsertQuoteRow, http.StatusInternalServerError}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("CreateQuote, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("CreateQuote, %s, err %v", ErrDBTransactionCommitFailed.Error(), err)
+		return nil, &AppError{ErrDBTransactionCommitFailed, http.StatusInternalServerError}
+	}
+
+	return quote, nil
+}
+
+//newQuote converts amount by current rates to quote, which is not stored yet. Amount must fit precision
+//of source currency, base currency amount of quote must be a valid operation amount
+func (ba *BillingApp) newQuote(ctx context.Context, method string, from string, to string,
+	amount decimal.Decimal) (*Quote, error) {
+	cfg := ba.GetConfig()
+	fromCurrency := cfg.currencies().Get(from)
+	if amount.LessThan(fromCurrency.Min()) {
+		ba.logger.Error("%s, %s", method, ErrAmountValueIsLessThanMin.Error())
+		return nil, &AppError{WithDetails(ErrAmountValueIsLessThanMin,
+			map[string]interface{}{"min_amount": fromCurrency.Min().String()}), http.StatusBadRequest}
+	}
+
+	if !fromCurrency.FitsMinorUnits(amount) {
+		ba.logger.Error("%s, %s", method, ErrAmountHasExcessiveFractionalDigits.Error())
+		return nil, &AppError{WithDetails(ErrAmountHasExcessiveFractionalDigits,
+			map[string]interface{}{"max_fractional_digits": fromCurrency.MinorUnits}), http.StatusBadRequest}
+	}
+
+	conversion, err := ba.exchanger.ConvertBetween(ctx, amount, from, to)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("%s, %s, err %v", method, ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		if errors.Is(err, exchanger.ErrTargetCurrencyNameNotFound) || errors.Is(err, exchanger.ErrSourceCurrencyNameNotFound) {
+			ba.logger.Error("%s, %s, err %v", method, ErrCurrencyDoesNotExist.Error(), err)
+			return nil, &AppError{ErrCurrencyDoesNotExist, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("%s, %s, err %v", method, ErrCurrencyExchangeFailed.Error(), err)
+		return nil, &AppError{ErrCurrencyExchangeFailed, http.StatusInternalServerError}
+	}
+
+	//rate is guaranteed until quote expiration, so it must not be taken from last known rates
+	if conversion.Stale {
+		ba.logger.Error("%s, %s, rates date %s", method, ErrRatesAreStale.Error(), conversion.RatesDate)
+		return nil, &AppError{ErrRatesAreStale, http.StatusInternalServerError}
+	}
+
+	now := time.Now()
+	quote := &Quote{
+		Id:              uuid.NewV4().String(),
+		FromCurrency:    from,
+		ToCurrency:      to,
+		Amount:          amount,
+		Rate:            conversion.Rate,
+		ConvertedAmount: conversion.Amount,
+		RatesDate:       conversion.RatesDate,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(cfg.quoteTTL()),
+	}
+
+	if err := ba.validateOperationAmount(method, quote.BaseAmount()); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+//GetQuote returns stored quote, used quote has time of use and token of operation
+func (ba *BillingApp) GetQuote(ctx context.Context, in *QuoteLookupRequest) (*Quote, error) {
+	if in == nil {
+		ba.logger.Error("GetQuote, %s", ErrParamsStructIsNil.Error())
+		return nil, &AppError{ErrParamsStructIsNil, http.StatusBadRequest}
+	}
+
+	tx, err := ba.storage.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted, ReadOnly: true})
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetQuote, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetQuote, %s, err %v", ErrDBTransactionBeginFailed.Error(), err)
+		return nil, &AppError{ErrDBTransactionBeginFailed, http.StatusInternalServerError}
+	}
+
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+				ba.logger.Error("GetQuote, %s, err %v", ctxErr.Error(), err)
+			}
+
+			ba.logger.Error("GetQuote, %s, err %v", ErrDBTransactionRollbackFailed.Error(), err)
+		}
+	}()
+
+	quote, err := tx.Quotes().Get(ctx, in.QuoteId, RowLockNone)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetQuote, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		if err == sql.ErrNoRows {
+			ba.logger.Error("GetQuote, %s, quote %s", ErrQuoteDoesNotExist.Error(), in.QuoteId)
+			return nil, &AppError{ErrQuoteDoesNotExist, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetQuote, %s, err %v", ErrDBFailedToFetchQuoteRow.Error(), err)
+		return nil, &AppError{ErrDBFailedToFetchQuoteRow, http.StatusInternalServerError}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("GetQuote, %s, err %v", ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("GetQuote, %s, err %v", ErrDBTransactionCommitFailed.Error(), err)
+		return nil, &AppError{ErrDBTransactionCommitFailed, http.StatusInternalServerError}
+	}
+
+	return quote, nil
+}
+
+//useQuote locks quote of operation in transaction and marks it used by operation token.
+//Base currency amount of returned quote is an amount of operation. Amount of request is optional,
+//if it is given, it must be equal to amount of quote
+func (ba *BillingApp) useQuote(ctx context.Context, method string, tx IStorageTx, quoteId string, amount string,
+	token string) (*Quote, error) {
+	quote, err := tx.Quotes().Get(ctx, quoteId, RowLockForUpdate)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("%s, %s, err %v", method, ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		if err == sql.ErrNoRows {
+			ba.logger.Error("%s, %s, quote %s", method, ErrQuoteDoesNotExist.Error(), quoteId)
+			return nil, &AppError{ErrQuoteDoesNotExist, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("%s, %s, err %v", method, ErrDBFailedToFetchQuoteRow.Error(), err)
+		return nil, &AppError{ErrDBFailedToFetchQuoteRow, http.StatusInternalServerError}
+	}
+
+	if quote.UsedAt != nil {
+		ba.logger.Error("%s, %s, quote %s", method, ErrQuoteIsAlreadyUsed.Error(), quoteId)
+		return nil, &AppError{ErrQuoteIsAlreadyUsed, http.StatusBadRequest}
+	}
+
+	now := time.Now()
+	if !now.Before(quote.ExpiresAt) {
+		ba.logger.Error("%s, %s, quote %s", method, ErrQuoteIsExpired.Error(), quoteId)
+		return nil, &AppError{WithDetails(ErrQuoteIsExpired,
+			map[string]interface{}{"expires_at": quote.ExpiresAt.UTC().Format(time.RFC3339)}), http.StatusBadRequest}
+	}
+
+	if amount != "" {
+		requestAmount, err := decimal.NewFromString(amount)
+		if err != nil {
+			ba.logger.Error("%s, %s, err %v", method, ErrFailedToCastAmountToDecimal.Error(), err)
+			return nil, &AppError{ErrFailedToCastAmountToDecimal, http.StatusBadRequest}
+		}
+
+		if !requestAmount.Equal(quote.Amount) {
+			ba.logger.Error("%s, %s, quote %s", method, ErrQuoteAmountMismatch.Error(), quoteId)
+			return nil, &AppError{WithDetails(ErrQuoteAmountMismatch,
+				map[string]interface{}{"quote_amount": quote.Amount.String()}), http.StatusBadRequest}
+		}
+	}
+
+	baseAmount := quote.BaseAmount()
+	if err := ba.validateOperationAmount(method, baseAmount); err != nil {
+		return nil, err
+	}
+
+	err = tx.Quotes().MarkUsed(ctx, quoteId, token, now)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("%s, %s, err %v", method, ctxErr.Error(), err)
+			return nil, &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("%s, %s, err %v", method, ErrDBFailedToUpdateQuoteRow.Error(), err)
+		return nil, &AppError{ErrDBFailedToUpdateQuoteRow, http.StatusInternalServerError}
+	}
+	return quote, nil
+}
+
+//storeUsedQuote stores rate snapshot of operation as quote, used by operation token
+func (ba *BillingApp) storeUsedQuote(ctx context.Context, method string, tx IStorageTx, quote *Quote,
+	token string) error {
+	err := tx.Quotes().Insert(ctx, quote)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("%s, %s, err %v", method, ctxErr.Error(), err)
+			return &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("%s, %s, err %v", method, ErrDBFailedToInsertQuoteRow.Error(), err)
+		return &AppError{ErrDBFailedToInsertQuoteRow, http.StatusInternalServerError}
+	}
+
+	err = tx.Quotes().MarkUsed(ctx, quote.Id, token, quote.CreatedAt)
+	if err != nil {
+		if ctxErr := GetCtxError(ctx, err); ctxErr != nil {
+			ba.logger.Error("%s, %s, err %v", method, ctxErr.Error(), err)
+			return &AppError{ctxErr, http.StatusBadRequest}
+		}
+
+		ba.logger.Error("%s, %s, err %v", method, ErrDBFailedToUpdateQuoteRow.Error(), err)
+		return &AppError{ErrDBFailedToUpdateQuoteRow, http.StatusInternalServerError}
+	}
+
+	quote.UsedAt, quote.IdempotencyToken = &quote.CreatedAt, &token
+	return nil
+}
+
+//withConversion sets conversion of transfer amount by quote to operation, operation is not changed if quote is nil
+func withConversion(operation Operation, quote *Quote) Operation {
+	if quote == nil {
+		return operation
+	}
+
+	amount, currency, convertedAmount, rate, ratesDate := quote.Amount, quote.FromCurrency, quote.ConvertedAmount,
+		quote.Rate, quote.RatesDate
+	operation.OriginalAmount, operation.OriginalCurrency, operation.ConvertedAmount = &amount, &currency, &convertedAmount
+	operation.Rate, operation.RatesDate = &rate, &ratesDate
+	return operation
+}
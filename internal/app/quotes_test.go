@@ -0,0 +1,316 @@
+package app
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/cache"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"job-backend-trainee-assignment/internal/logger"
+	"testing"
+	"time"
+)
+
+//staleStubExchanger converts amounts by last known rates
+type staleStubExchanger struct {
+	exchanger.StubExchanger
+}
+
+func (se *staleStubExchanger) ConvertBetween(ctx context.Context, amount decimal.Decimal, sourceCurrencyName string,
+	targetCurrencyName string) (*exchanger.Conversion, error) {
+	conversion, err := se.StubExchanger.ConvertBetween(ctx, amount, sourceCurrencyName, targetCurrencyName)
+	if err != nil {
+		return nil, err
+	}
+	conversion.Stale = true
+	return conversion, nil
+}
+
+func TestBillingApp_Quotes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("positive path, credit by quote", func(t *testing.T) {
+		app := newMemoryStorageApp(t, NewMemoryStorage())
+
+		quote, err := app.CreateQuote(ctx, &QuoteRequest{From: "usd", To: "RUB", Amount: "100"})
+		require.NoError(t, err)
+		assert.NotEmpty(t, quote.Id)
+		assert.Equal(t, "USD", quote.FromCurrency, "currency code must be upper cased")
+		assert.Equal(t, "76.923076923076923077", quote.Rate.String())
+		assert.Equal(t, "7692.31", quote.ConvertedAmount.String())
+		assert.Equal(t, "2020-08-15", quote.RatesDate)
+		assert.Equal(t, defaultQuoteTTL, quote.ExpiresAt.Sub(quote.CreatedAt))
+
+		res, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "100", QuoteId: quote.Id,
+			IdempotencyToken: "1"})
+		require.NoError(t, err)
+		assert.Equal(t, MsgAccountCreditingDone, res.State)
+
+		balance, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "7692.31", balance.Balance, "operation amount must be base currency amount of quote")
+
+		stored, err := app.GetQuote(ctx, &QuoteLookupRequest{QuoteId: quote.Id})
+		require.NoError(t, err)
+		require.NotNil(t, stored.UsedAt)
+		require.NotNil(t, stored.IdempotencyToken)
+		assert.Equal(t, "1", *stored.IdempotencyToken)
+
+		res, err = app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, QuoteId: quote.Id, IdempotencyToken: "1"})
+		require.NoError(t, err)
+		assert.Equal(t, OperationTokenIsAlreadyUsed, res.State, "repeated operation must not check used quote")
+
+		_, err = app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, QuoteId: quote.Id, IdempotencyToken: "2"})
+		assert.ErrorIs(t, err, ErrQuoteIsAlreadyUsed)
+	})
+
+	t.Run("positive path, withdraw and transfer by quote of base currency", func(t *testing.T) {
+		app := newMemoryStorageApp(t, NewMemoryStorage())
+
+		_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "3000", IdempotencyToken: "1"})
+		require.NoError(t, err)
+		_, err = app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 2, Amount: "1", IdempotencyToken: "2"})
+		require.NoError(t, err)
+
+		withdrawQuote, err := app.CreateQuote(ctx, &QuoteRequest{From: "RUB", To: "USD", Amount: "1000"})
+		require.NoError(t, err)
+		assert.Equal(t, "0.013", withdrawQuote.Rate.String())
+		assert.Equal(t, "13", withdrawQuote.ConvertedAmount.String())
+
+		_, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, QuoteId: withdrawQuote.Id,
+			IdempotencyToken: "3"})
+		require.NoError(t, err)
+
+		transferQuote, err := app.CreateQuote(ctx, &QuoteRequest{From: "RUB", To: "EUR", Amount: "500"})
+		require.NoError(t, err)
+
+		_, err = app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "500",
+			QuoteId: transferQuote.Id, IdempotencyToken: "4"})
+		require.NoError(t, err)
+
+		sender, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "1500", sender.Balance)
+
+		receiver, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 2})
+		require.NoError(t, err)
+		assert.Equal(t, "501", receiver.Balance)
+	})
+
+	t.Run("negative path, expired quote", func(t *testing.T) {
+		app := newMemoryStorageApp(t, NewMemoryStorage())
+		cfg := app.GetConfig()
+		cfg.QuoteTTL = time.Nanosecond
+		require.NoError(t, app.SetConfig(&cfg))
+
+		_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "100", IdempotencyToken: "1"})
+		require.NoError(t, err)
+
+		quote, err := app.CreateQuote(ctx, &QuoteRequest{From: "RUB", To: "USD", Amount: "10"})
+		require.NoError(t, err)
+
+		_, err = app.WithdrawUserAccount(ctx, &WithdrawAccountRequest{UserId: 1, QuoteId: quote.Id, IdempotencyToken: "2"})
+		assert.ErrorIs(t, err, ErrQuoteIsExpired)
+
+		balance, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "100", balance.Balance)
+
+		stored, err := app.GetQuote(ctx, &QuoteLookupRequest{QuoteId: quote.Id})
+		require.NoError(t, err)
+		assert.Nil(t, stored.UsedAt, "expired quote must not be marked used")
+	})
+
+	t.Run("negative path, quote of operation", func(t *testing.T) {
+		app := newMemoryStorageApp(t, NewMemoryStorage())
+
+		_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, QuoteId: "missing", IdempotencyToken: "1"})
+		assert.ErrorIs(t, err, ErrQuoteDoesNotExist)
+
+		quote, err := app.CreateQuote(ctx, &QuoteRequest{From: "USD", To: "RUB", Amount: "1"})
+		require.NoError(t, err)
+
+		_, err = app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "76.92", QuoteId: quote.Id,
+			IdempotencyToken: "2"})
+		assert.ErrorIs(t, err, ErrQuoteAmountMismatch, "amount of request must be amount of quote")
+		assert.Equal(t, "1", GetErrorDetails(err)["quote_amount"])
+
+		_, err = app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "1.00", QuoteId: quote.Id,
+			IdempotencyToken: "3"})
+		require.NoError(t, err, "equal amounts with different precision must match")
+	})
+
+	t.Run("negative path, bad quote request", func(t *testing.T) {
+		app := newMemoryStorageApp(t, NewMemoryStorage())
+
+		_, err := app.CreateQuote(ctx, nil)
+		assert.ErrorIs(t, err, ErrParamsStructIsNil)
+
+		_, err = app.CreateQuote(ctx, &QuoteRequest{From: "EUR", To: "USD", Amount: "1"})
+		assert.ErrorIs(t, err, ErrQuoteCurrencyIsNotBase)
+
+		_, err = app.CreateQuote(ctx, &QuoteRequest{From: "XYZ", To: "RUB", Amount: "1"})
+		assert.ErrorIs(t, err, ErrCurrencyDoesNotExist)
+
+		_, err = app.CreateQuote(ctx, &QuoteRequest{From: "USD", To: "RUB", Amount: "ten"})
+		assert.ErrorIs(t, err, ErrFailedToCastAmountToDecimal)
+
+		_, err = app.CreateQuote(ctx, &QuoteRequest{From: "USD", To: "RUB", Amount: "-1"})
+		assert.ErrorIs(t, err, ErrAmountValueIsNegative)
+
+		_, err = app.CreateQuote(ctx, &QuoteRequest{From: "USD", To: "RUB", Amount: "1.001"})
+		assert.ErrorIs(t, err, ErrAmountHasExcessiveFractionalDigits, "amount must fit minor units of source currency")
+
+		cfg := app.GetConfig()
+		cfg.MinOpsMonetaryUnit = decimal.NewFromInt(1)
+		require.NoError(t, app.SetConfig(&cfg))
+		_, err = app.CreateQuote(ctx, &QuoteRequest{From: "USD", To: "RUB", Amount: "0.01"})
+		assert.ErrorIs(t, err, ErrAmountValueIsLessThanMin, "base currency amount of quote must be at least min amount")
+
+		_, err = app.GetQuote(ctx, &QuoteLookupRequest{QuoteId: "missing"})
+		assert.ErrorIs(t, err, ErrQuoteDoesNotExist)
+	})
+
+	t.Run("negative path, stale rates", func(t *testing.T) {
+		app, err := NewAppWithStorage(&logger.DummyLogger{}, NewMemoryStorage(), &staleStubExchanger{},
+			&cache.DummyCacheCommon{}, nil)
+		require.NoError(t, err)
+
+		_, err = app.CreateQuote(ctx, &QuoteRequest{From: "USD", To: "RUB", Amount: "1"})
+		assert.ErrorIs(t, err, ErrRatesAreStale)
+	})
+}
+
+func TestBillingApp_CrossCurrencyTransfer(t *testing.T) {
+	ctx := context.Background()
+
+	newApp := func(t *testing.T) *BillingApp {
+		app := newMemoryStorageApp(t, NewMemoryStorage())
+		cfg := app.GetConfig()
+		cfg.ConversionSpread = decimal.RequireFromString("0.01")
+		cfg.RevenueAccountId = 3
+		require.NoError(t, app.SetConfig(&cfg))
+
+		_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 1, Amount: "1000", IdempotencyToken: "c1"})
+		require.NoError(t, err)
+		_, err = app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 2, Amount: "1", IdempotencyToken: "c2"})
+		require.NoError(t, err)
+		return app
+	}
+
+	balanceOf := func(t *testing.T, app *BillingApp, userId int64) string {
+		balance, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: userId})
+		require.NoError(t, err)
+		return balance.Balance
+	}
+
+	t.Run("positive path, spread is booked to revenue account", func(t *testing.T) {
+		app := newApp(t)
+		_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 3, Amount: "1", IdempotencyToken: "c3"})
+		require.NoError(t, err)
+
+		res, err := app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "10",
+			Currency: "usd", IdempotencyToken: "1"})
+		require.NoError(t, err)
+		assert.Equal(t, MsgMoneyTransferDone, res.State)
+		require.NotNil(t, res.Conversion)
+		assert.Equal(t, "USD", res.Conversion.OriginalCurrency)
+		assert.Equal(t, "10", res.Conversion.OriginalAmount.String())
+		assert.Equal(t, "769.23", res.Conversion.ConvertedAmount.String())
+		assert.Equal(t, "76.923076923076923077", res.Conversion.Rate.String())
+		assert.Equal(t, "2020-08-15", res.Conversion.RatesDate)
+		assert.Equal(t, "7.69", res.Conversion.Spread.String())
+		assert.Equal(t, "761.54", res.Conversion.CreditedAmount.String())
+
+		assert.Equal(t, "230.77", balanceOf(t, app, 1))
+		assert.Equal(t, "762.54", balanceOf(t, app, 2))
+		assert.Equal(t, "8.69", balanceOf(t, app, 3))
+
+		for _, userId := range []int64{1, 2, 3} {
+			log, err := app.GetUserOperations(ctx, &OperationLogRequest{UserId: userId, Limit: -1})
+			require.NoError(t, err)
+			var transferOp *Operation
+			for i := range log.Operations {
+				if log.Operations[i].IdempotencyToken == "1" {
+					transferOp = &log.Operations[i]
+				}
+			}
+			require.NotNil(t, transferOp, "user %d must have operation of transfer", userId)
+			require.NotNil(t, transferOp.OriginalCurrency)
+			assert.Equal(t, "USD", *transferOp.OriginalCurrency)
+			require.NotNil(t, transferOp.Rate)
+			assert.Equal(t, "76.923076923076923077", transferOp.Rate.String())
+			require.NotNil(t, transferOp.ConvertedAmount)
+			assert.Equal(t, "769.23", transferOp.ConvertedAmount.String())
+		}
+
+		res, err = app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "10",
+			Currency: "USD", IdempotencyToken: "1"})
+		require.NoError(t, err)
+		assert.Equal(t, OperationTokenIsAlreadyUsed, res.State)
+		assert.Equal(t, "230.77", balanceOf(t, app, 1), "repeated transfer must not convert amount again")
+	})
+
+	t.Run("positive path, transfer by quote of other currency", func(t *testing.T) {
+		app := newApp(t)
+		_, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 3, Amount: "1", IdempotencyToken: "c3"})
+		require.NoError(t, err)
+
+		quote, err := app.CreateQuote(ctx, &QuoteRequest{From: "USD", To: "RUB", Amount: "10"})
+		require.NoError(t, err)
+
+		_, err = app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "10",
+			Currency: "EUR", QuoteId: quote.Id, IdempotencyToken: "1"})
+		assert.ErrorIs(t, err, ErrQuoteAmountMismatch, "currency of request must be currency of quote")
+		assert.Equal(t, "USD", GetErrorDetails(err)["quote_currency"])
+
+		res, err := app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2,
+			QuoteId: quote.Id, IdempotencyToken: "2"})
+		require.NoError(t, err)
+		require.NotNil(t, res.Conversion)
+		assert.Equal(t, quote.Rate.String(), res.Conversion.Rate.String())
+		assert.Equal(t, "761.54", res.Conversion.CreditedAmount.String())
+		assert.Equal(t, "762.54", balanceOf(t, app, 2))
+	})
+
+	t.Run("positive path, transfer in base currency has no conversion", func(t *testing.T) {
+		app := newApp(t)
+
+		res, err := app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "10",
+			Currency: "RUB", IdempotencyToken: "1"})
+		require.NoError(t, err)
+		assert.Nil(t, res.Conversion)
+		assert.Equal(t, "11", balanceOf(t, app, 2), "spread must not be kept from transfer in base currency")
+	})
+
+	t.Run("negative path, conversion errors", func(t *testing.T) {
+		app := newApp(t)
+
+		_, err := app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "10",
+			Currency: "XYZ", IdempotencyToken: "1"})
+		assert.ErrorIs(t, err, ErrCurrencyDoesNotExist)
+
+		_, err = app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "20",
+			Currency: "USD", IdempotencyToken: "2"})
+		assert.ErrorIs(t, err, ErrUserDoesNotHaveEnoughMoney, "converted amount must be debited from sender")
+
+		_, err = app.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "10",
+			Currency: "USD", IdempotencyToken: "3"})
+		assert.ErrorIs(t, err, ErrRevenueAccountDoesNotExist)
+		assert.Equal(t, "1000", balanceOf(t, app, 1), "transfer must be rolled back")
+
+		cfg := app.GetConfig()
+		cfg.RevenueAccountId = 0
+		assert.Error(t, cfg.Validate(), "revenue account must be set for positive spread")
+		cfg.ConversionSpread = decimal.NewFromInt(1)
+		assert.Error(t, cfg.Validate(), "spread must be less than one")
+
+		staleApp, err := NewAppWithStorage(&logger.DummyLogger{}, NewMemoryStorage(), &staleStubExchanger{},
+			&cache.DummyCacheCommon{}, nil)
+		require.NoError(t, err)
+		_, err = staleApp.TransferMoneyFromUserToUser(ctx, &MoneyTransferRequest{SenderId: 1, ReceiverId: 2, Amount: "10",
+			Currency: "USD", IdempotencyToken: "1"})
+		assert.ErrorIs(t, err, ErrRatesAreStale)
+	})
+}
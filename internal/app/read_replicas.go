@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"job-backend-trainee-assignment/internal/db_connector"
+	"regexp"
+	"time"
+)
+
+//IReadReplicas chooses read replica, which replication lag is acceptable
+type IReadReplicas interface {
+	Replica() (replica *db_connector.Replica, ok bool)
+}
+
+//consistencyTokenRe matches postgres wal position, used as consistency token
+var consistencyTokenRe = regexp.MustCompile(`^[0-9A-Fa-f]{1,8}/[0-9A-Fa-f]{1,8}$`)
+
+//consistencyTokenTimeout limits wal position query, performed after committed write
+var consistencyTokenTimeout = 2 * time.Second
+
+//SetReadReplicas enables reads of balance and operations log from replicas, all reads are done on primary by default
+func (ba *BillingApp) SetReadReplicas(replicas IReadReplicas) error {
+	if replicas == nil {
+		return fmt.Errorf("must provide non-nil read replicas instance")
+	}
+
+	if ba.db == nil {
+		return fmt.Errorf("read replicas are supported by postgres storage only")
+	}
+
+	ba.mu.Lock()
+	ba.replicas = replicas
+	ba.mu.Unlock()
+	return nil
+}
+
+func (ba *BillingApp) getReadReplicas() IReadReplicas {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+	return ba.replicas
+}
+
+//ValidateConsistencyToken checks that token is empty or is a wal position
+func ValidateConsistencyToken(token string) error {
+	if token != "" && !consistencyTokenRe.MatchString(token) {
+		return ErrBadConsistencyToken
+	}
+	return nil
+}
+
+//beginReadTx begins read only transaction of given isolation on healthy replica. Transaction is begun on primary
+//if there is no healthy replica, or if replica has not replayed changes up to given consistency token yet
+func (ba *BillingApp) beginReadTx(ctx context.Context, methodName string, consistencyToken string,
+	isolation sql.IsolationLevel) (tx IStorageTx, fromReplica bool, err error) {
+	txOptions := &sql.TxOptions{Isolation: isolation, ReadOnly: true}
+
+	if replicas := ba.getReadReplicas(); replicas != nil {
+		if replica, ok := replicas.Replica(); ok {
+			tx, err := replica.DB.BeginTxx(ctx, txOptions)
+			if err == nil {
+				if consistencyToken == "" {
+					return newPostgresTx(tx), true, nil
+				}
+
+				caughtUp := false
+				err = tx.GetContext(ctx, &caughtUp,
+					`SELECT COALESCE(pg_last_wal_replay_lsn() >= $1::pg_lsn, false)`, consistencyToken)
+				if err == nil && caughtUp {
+					return newPostgresTx(tx), true, nil
+				}
+				_ = tx.Rollback()
+			}
+
+			if ctx.Err() != nil {
+				return nil, false, err
+			}
+			ba.logger.Info("%s, replica %s is behind consistency token or unavailable, reading from primary, err %v",
+				methodName, replica.Name, err)
+		}
+	}
+
+	tx, err = ba.storage.BeginTx(ctx, txOptions)
+	return tx, false, err
+}
+
+//consistencyToken returns current wal position of primary. Reads with this token see all writes,
+//committed before token is got. Empty token is returned if there are no replicas or position query failed
+func (ba *BillingApp) consistencyToken(methodName string) string {
+	if ba.getReadReplicas() == nil {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), consistencyTokenTimeout)
+	defer cancel()
+
+	token := ""
+	err := ba.db.GetContext(ctx, &token, `SELECT pg_current_wal_lsn()::text`)
+	if err != nil {
+		ba.logger.Error("%s, failed to get consistency token, err %v", methodName, err)
+		return ""
+	}
+	return token
+}
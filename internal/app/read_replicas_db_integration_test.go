@@ -0,0 +1,150 @@
+// +build integration
+
+package app
+
+import (
+	"context"
+	"errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/cache"
+	"job-backend-trainee-assignment/internal/db_connector"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"job-backend-trainee-assignment/internal/logger"
+	"job-backend-trainee-assignment/internal/test_helpers"
+	"testing"
+	"time"
+)
+
+//stubReadReplicas always returns the same replica
+type stubReadReplicas struct {
+	replica *db_connector.Replica
+	calls   int
+}
+
+func (r *stubReadReplicas) Replica() (*db_connector.Replica, bool) {
+	r.calls++
+	return r.replica, true
+}
+
+func TestBillingApp_ReadReplicas_Common(t *testing.T) {
+	v := viper.New()
+
+	v.AddConfigPath(".")
+	v.AddConfigPath("../../")
+	v.SetConfigName("config")
+	v.AutomaticEnv()
+
+	err := v.ReadInConfig()
+	require.NoErrorf(t, err, "failed to read config file at: %s, err %v", "config", err)
+
+	var pgHost string
+	if v.GetString("DATABASE_HOST") != "" {
+		pgHost = v.GetString("DATABASE_HOST")
+	} else {
+		pgHost = v.GetString("db_params.DATABASE_HOST")
+	}
+
+	dbConfig := &db_connector.Config{
+		DriverName:    v.GetString("db_params.driver_name"),
+		DBUser:        v.GetString("db_params.user"),
+		DBPass:        v.GetString("db_params.password"),
+		DBName:        v.GetString("db_params.db_name"),
+		DBPort:        v.GetString("db_params.port"),
+		DBHost:        pgHost,
+		SSLMode:       v.GetString("db_params.ssl_mode"),
+		RetryInterval: v.GetDuration("db_params.conn_retry_interval") * time.Second,
+	}
+
+	dbConnTimeout := v.GetDuration("db_params.conn_timeout") * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), dbConnTimeout)
+	defer cancel()
+	dummyLogger := &logger.DummyLogger{}
+
+	db, dbCloseFunc, err := db_connector.DBConnectWithTimeout(ctx, dbConfig, dummyLogger)
+	require.NoErrorf(t, err, "failed to connect to db,err %v", err)
+
+	defer dbCloseFunc()
+
+	ex := &exchanger.StubExchanger{}
+	caseTimeout := v.GetDuration("testing_params.test_case_timeout") * time.Second
+
+	prepareDB := func(ctx context.Context, t *testing.T) {
+		err := test_helpers.PrepareDB(ctx, db, test_helpers.Config{
+			InitFilePath:    filePathPrefix + v.GetString("testing_params.db_init_file_path"),
+			CleanUpFilePath: filePathPrefix + v.GetString("testing_params.db_cleanup_file_path"),
+		})
+		require.NoError(t, err, "PrepareDB must not return error")
+	}
+
+	t.Run("negative path, nil replicas", func(t *testing.T) {
+		app, err := NewApp(dummyLogger, db, ex, &cache.DummyCacheCommon{}, nil)
+		require.NoError(t, err)
+		assert.Error(t, app.SetReadReplicas(nil), "SetReadReplicas must return error")
+	})
+
+	t.Run("positive path, write returns consistency token, reads use replica", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		app, err := NewApp(dummyLogger, db, ex, &cache.DummyCacheCommon{}, nil)
+		require.NoError(t, err)
+
+		//test database is not a replica, so it has no replayed wal position and reads with token go to primary
+		replicas := &stubReadReplicas{replica: &db_connector.Replica{Name: "primary", DB: db}}
+		require.NoError(t, app.SetReadReplicas(replicas))
+
+		res, err := app.CreditUserAccount(ctx, &CreditAccountRequest{UserId: 2, Amount: "5", IdempotencyToken: uuid.NewV4().String()})
+		require.NoError(t, err)
+		assert.Regexp(t, consistencyTokenRe, res.ConsistencyToken, "consistency token must be wal position")
+
+		balance, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 2})
+		require.NoError(t, err)
+		assert.Equal(t, "15", balance.Balance)
+		assert.Equal(t, 1, replicas.calls, "replica must be used")
+
+		balance, err = app.GetUserBalance(ctx, &BalanceRequest{UserId: 2, ConsistencyToken: res.ConsistencyToken})
+		require.NoError(t, err)
+		assert.Equal(t, "15", balance.Balance, "read with token must see the write")
+
+		ops, err := app.GetUserOperations(ctx, &OperationLogRequest{UserId: 2, Limit: -1, ConsistencyToken: res.ConsistencyToken})
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), ops.OperationsNum, "read with token must see the write")
+	})
+
+	t.Run("positive path, unavailable replica falls back to primary", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		app, err := NewApp(dummyLogger, db, ex, &cache.DummyCacheCommon{}, nil)
+		require.NoError(t, err)
+
+		closedDB, closedDBCloseFunc, err := db_connector.DBConnectWithTimeout(ctx, dbConfig, dummyLogger)
+		require.NoError(t, err)
+		closedDBCloseFunc()
+
+		require.NoError(t, app.SetReadReplicas(&stubReadReplicas{replica: &db_connector.Replica{Name: "closed", DB: closedDB}}))
+
+		balance, err := app.GetUserBalance(ctx, &BalanceRequest{UserId: 2})
+		require.NoError(t, err)
+		assert.Equal(t, "10", balance.Balance)
+	})
+
+	t.Run("negative path, bad consistency token", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+
+		app, err := NewApp(dummyLogger, db, ex, &cache.DummyCacheCommon{}, nil)
+		require.NoError(t, err)
+
+		_, err = app.GetUserBalance(ctx, &BalanceRequest{UserId: 2, ConsistencyToken: "0/3000148; DROP"})
+		assert.True(t, errors.Is(err, ErrBadConsistencyToken), "must return ErrBadConsistencyToken")
+
+		_, err = app.GetUserOperations(ctx, &OperationLogRequest{UserId: 2, ConsistencyToken: "token"})
+		assert.True(t, errors.Is(err, ErrBadConsistencyToken), "must return ErrBadConsistencyToken")
+	})
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+//IStorage begins transactions, users, operations and idempotency tokens are read and changed inside them.
+//Not found rows are reported with sql.ErrNoRows, finished transaction is reported with sql.ErrTxDone
+type IStorage interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (IStorageTx, error)
+}
+
+//IStorageTx is a transaction of storage, changes are visible to other transactions after commit only
+type IStorageTx interface {
+	Users() IUserRepository
+	Operations() IOperationRepository
+	Idempotency() IIdempotencyRepository
+	Snapshots() ISnapshotRepository
+	Quotes() IQuoteRepository
+	Bonuses() IBonusRepository
+	Commit() error
+	Rollback() error
+}
+
+//RowLock is a lock of user row, taken on read until transaction end
+type RowLock int
+
+const (
+	//RowLockNone reads row without lock
+	RowLockNone RowLock = iota
+	//RowLockForUpdate locks row for any change, including deletion
+	RowLockForUpdate
+	//RowLockForNoKeyUpdate locks row for change of non key columns, e.g. balance
+	RowLockForNoKeyUpdate
+)
+
+type IUserRepository interface {
+	//LockForInsert prevents concurrent creation of users with the same id until transaction end
+	LockForInsert(ctx context.Context) error
+	//Get returns sql.ErrNoRows if user does not exist
+	Get(ctx context.Context, userId int64, lock RowLock) (*User, error)
+	//GetMany returns existing users of given ids ordered by id, rows are locked in this order
+	GetMany(ctx context.Context, userIds []int64, lock RowLock) ([]User, error)
+	Create(ctx context.Context, user *User) error
+	//AddToBalance adds amount to user balance, amount is negative for withdraw
+	AddToBalance(ctx context.Context, userId int64, amount decimal.Decimal) error
+}
+
+//OperationFilter selects operations of user, optional period is [From, To)
+type OperationFilter struct {
+	UserId int64
+	From   *time.Time
+	To     *time.Time
+	//OrderField is "date" or "amount", operations with equal field are ordered by id in the same direction
+	OrderField string
+	//OrderDirection is "asc" or "desc"
+	OrderDirection string
+	//Limit is -1 for all operations
+	Limit  int64
+	Offset int64
+	//After selects operations, which follow given one in order of date and id, it is a keyset of the next page.
+	//It is applied to operations ordered by date ascending and is not applied to count
+	After *OperationCursor
+}
+
+//OperationCursor is a position of operation in order of date and id
+type OperationCursor struct {
+	Date time.Time
+	Id   int64
+}
+
+type IOperationRepository interface {
+	//LockForInsert serializes operations inserts until transaction end, so idempotency token check is not raced
+	LockForInsert(ctx context.Context) error
+	//Insert stores operations, ids are assigned by storage
+	Insert(ctx context.Context, operations ...Operation) error
+	List(ctx context.Context, filter *OperationFilter) ([]Operation, error)
+	Count(ctx context.Context, filter *OperationFilter) (int64, error)
+	//ListByIdempotencyToken returns operations performed with the token ordered by id
+	ListByIdempotencyToken(ctx context.Context, token string) ([]Operation, error)
+	//SumAmount returns sum of user operations amounts in [from, to), nil bound is not applied.
+	//Sum is not valid if there are no operations
+	SumAmount(ctx context.Context, userId int64, from, to *time.Time) (decimal.NullDecimal, error)
+}
+
+//IIdempotencyRepository checks tokens of stored operations, it is the last line of idempotency check
+type IIdempotencyRepository interface {
+	TokenIsUsed(ctx context.Context, token string) (bool, error)
+}
+
+type ISnapshotRepository interface {
+	//InsertClosingBalances stores balances of users, created before dayEnd, at the end of the day.
+	//Already stored snapshots are kept, returns number of stored snapshots
+	InsertClosingBalances(ctx context.Context, day, dayEnd time.Time) (int64, error)
+	//GetLatest returns the latest snapshot of user on or before the day, sql.ErrNoRows if there is no such snapshot
+	GetLatest(ctx context.Context, userId int64, day time.Time) (*BalanceSnapshot, error)
+}
+
+type IQuoteRepository interface {
+	Insert(ctx context.Context, quote *Quote) error
+	//Get returns sql.ErrNoRows if quote does not exist
+	Get(ctx context.Context, quoteId string, lock RowLock) (*Quote, error)
+	//MarkUsed stores token of operation, which used the quote, and time of use
+	MarkUsed(ctx context.Context, quoteId string, token string, usedAt time.Time) error
+}
+
+type IBonusRepository interface {
+	//Insert stores grant, id of grant is assigned by storage
+	Insert(ctx context.Context, grant *BonusGrant) error
+	//ListActive returns not spent grants of user, which are not expired at now, ordered by expiration and id
+	ListActive(ctx context.Context, userId int64, now time.Time, lock RowLock) ([]BonusGrant, error)
+	//ListExpired returns at most limit not spent grants of all users, expired at now, ordered by expiration and id
+	ListExpired(ctx context.Context, now time.Time, limit int64, lock RowLock) ([]BonusGrant, error)
+	SetRemaining(ctx context.Context, grantId int64, remaining decimal.Decimal) error
+}
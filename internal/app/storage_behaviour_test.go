@@ -0,0 +1,389 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+//testStorageBehaviour checks behaviour of storage, which app relies on. newStorage returns storage without
+//users and operations of ids and tokens used here, so the suite is run on postgres with test data as well
+func testStorageBehaviour(t *testing.T, newStorage func(t *testing.T) IStorage) {
+	writeOpts := &sql.TxOptions{Isolation: sql.LevelReadCommitted, ReadOnly: false}
+	readOpts := &sql.TxOptions{Isolation: sql.LevelReadCommitted, ReadOnly: true}
+	created := time.Date(2020, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	inTx := func(t *testing.T, storage IStorage, opts *sql.TxOptions, f func(tx IStorageTx)) {
+		tx, err := storage.BeginTx(context.Background(), opts)
+		require.NoError(t, err)
+		f(tx)
+		require.NoError(t, tx.Commit())
+	}
+
+	createUser := func(t *testing.T, storage IStorage, userId int64, balance string) {
+		inTx(t, storage, writeOpts, func(tx IStorageTx) {
+			err := tx.Users().Create(context.Background(), &User{Id: userId, Name: "user",
+				Balance: decimal.RequireFromString(balance), CreatedAt: created})
+			require.NoError(t, err)
+		})
+	}
+
+	t.Run("users, create, get and change balance", func(t *testing.T) {
+		storage := newStorage(t)
+		ctx := context.Background()
+
+		inTx(t, storage, readOpts, func(tx IStorageTx) {
+			_, err := tx.Users().Get(ctx, 1001, RowLockNone)
+			assert.Equal(t, sql.ErrNoRows, err)
+		})
+
+		createUser(t, storage, 1001, "10")
+		inTx(t, storage, writeOpts, func(tx IStorageTx) {
+			require.NoError(t, tx.Users().LockForInsert(ctx))
+			err := tx.Users().Create(ctx, &User{Id: 1001, Name: "again", Balance: decimal.Zero, CreatedAt: created})
+			assert.Error(t, err, "user with existing id must not be created")
+		})
+
+		inTx(t, storage, writeOpts, func(tx IStorageTx) {
+			user, err := tx.Users().Get(ctx, 1001, RowLockForUpdate)
+			require.NoError(t, err)
+			assert.True(t, decimal.NewFromInt(10).Equal(user.Balance))
+
+			require.NoError(t, tx.Users().AddToBalance(ctx, 1001, decimal.RequireFromString("-2.5")))
+		})
+
+		inTx(t, storage, readOpts, func(tx IStorageTx) {
+			user, err := tx.Users().Get(ctx, 1001, RowLockNone)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1001), user.Id)
+			assert.Equal(t, "user", user.Name)
+			assert.True(t, decimal.RequireFromString("7.5").Equal(user.Balance))
+		})
+	})
+
+	t.Run("users, get many are ordered by id and skip missing", func(t *testing.T) {
+		storage := newStorage(t)
+		createUser(t, storage, 1003, "3")
+		createUser(t, storage, 1002, "2")
+
+		inTx(t, storage, writeOpts, func(tx IStorageTx) {
+			users, err := tx.Users().GetMany(context.Background(), []int64{1003, 1002, 1009}, RowLockForNoKeyUpdate)
+			require.NoError(t, err)
+			require.Len(t, users, 2)
+			assert.Equal(t, int64(1002), users[0].Id)
+			assert.Equal(t, int64(1003), users[1].Id)
+		})
+	})
+
+	t.Run("rollback discards changes", func(t *testing.T) {
+		storage := newStorage(t)
+		ctx := context.Background()
+		createUser(t, storage, 1004, "10")
+
+		tx, err := storage.BeginTx(ctx, writeOpts)
+		require.NoError(t, err)
+		require.NoError(t, tx.Users().AddToBalance(ctx, 1004, decimal.NewFromInt(5)))
+		require.NoError(t, tx.Operations().Insert(ctx, Operation{UserId: 1004, Amount: decimal.NewFromInt(5),
+			Date: created, IdempotencyToken: "storage-rollback"}))
+		require.NoError(t, tx.Users().Create(ctx, &User{Id: 1005, Name: "new", Balance: decimal.Zero, CreatedAt: created}))
+		require.NoError(t, tx.Rollback())
+		assert.Equal(t, sql.ErrTxDone, tx.Rollback())
+		assert.Equal(t, sql.ErrTxDone, tx.Commit())
+
+		inTx(t, storage, readOpts, func(tx IStorageTx) {
+			user, err := tx.Users().Get(ctx, 1004, RowLockNone)
+			require.NoError(t, err)
+			assert.True(t, decimal.NewFromInt(10).Equal(user.Balance))
+
+			_, err = tx.Users().Get(ctx, 1005, RowLockNone)
+			assert.Equal(t, sql.ErrNoRows, err)
+
+			used, err := tx.Idempotency().TokenIsUsed(ctx, "storage-rollback")
+			require.NoError(t, err)
+			assert.False(t, used)
+		})
+	})
+
+	t.Run("committed transaction is rolled back with ErrTxDone", func(t *testing.T) {
+		storage := newStorage(t)
+
+		tx, err := storage.BeginTx(context.Background(), readOpts)
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+		assert.Equal(t, sql.ErrTxDone, tx.Rollback())
+	})
+
+	t.Run("read only transaction does not change data", func(t *testing.T) {
+		storage := newStorage(t)
+		ctx := context.Background()
+		createUser(t, storage, 1006, "10")
+
+		tx, err := storage.BeginTx(ctx, readOpts)
+		require.NoError(t, err)
+		err = tx.Users().AddToBalance(ctx, 1006, decimal.NewFromInt(5))
+		assert.Error(t, err)
+		_ = tx.Rollback()
+
+		inTx(t, storage, readOpts, func(tx IStorageTx) {
+			user, err := tx.Users().Get(ctx, 1006, RowLockNone)
+			require.NoError(t, err)
+			assert.True(t, decimal.NewFromInt(10).Equal(user.Balance))
+		})
+	})
+
+	t.Run("operations, insert, list and count", func(t *testing.T) {
+		storage := newStorage(t)
+		ctx := context.Background()
+		createUser(t, storage, 1007, "0")
+		createUser(t, storage, 1008, "0")
+
+		day := func(d int) time.Time {
+			return time.Date(2020, 8, d, 10, 0, 0, 0, time.UTC)
+		}
+
+		inTx(t, storage, writeOpts, func(tx IStorageTx) {
+			require.NoError(t, tx.Operations().LockForInsert(ctx))
+			err := tx.Operations().Insert(ctx,
+				Operation{UserId: 1007, Comment: "a", Amount: decimal.NewFromInt(10), Date: day(10), IdempotencyToken: "storage-op-1"},
+				Operation{UserId: 1008, Comment: "b", Amount: decimal.NewFromInt(-10), Date: day(10), IdempotencyToken: "storage-op-1"})
+			require.NoError(t, err)
+			err = tx.Operations().Insert(ctx, withConversion(
+				Operation{UserId: 1007, Comment: "c", Amount: decimal.NewFromInt(30), Date: day(11), IdempotencyToken: "storage-op-2"},
+				&Quote{Amount: decimal.NewFromInt(1), FromCurrency: "USD", ConvertedAmount: decimal.NewFromInt(30),
+					Rate: decimal.RequireFromString("30.123456789"), RatesDate: "2020-08-15"}))
+			require.NoError(t, err)
+			err = tx.Operations().Insert(ctx,
+				Operation{UserId: 1007, Comment: "d", Amount: decimal.NewFromInt(20), Date: day(12), IdempotencyToken: "storage-op-3"})
+			require.NoError(t, err)
+		})
+
+		inTx(t, storage, readOpts, func(tx IStorageTx) {
+			ops, err := tx.Operations().List(ctx, &OperationFilter{UserId: 1007, OrderField: "date",
+				OrderDirection: "desc", Limit: -1})
+			require.NoError(t, err)
+			require.Len(t, ops, 3)
+			assert.Equal(t, []string{"d", "c", "a"}, []string{ops[0].Comment, ops[1].Comment, ops[2].Comment})
+			assert.True(t, ops[1].Id < ops[0].Id, "ids must grow in insert order")
+
+			ops, err = tx.Operations().List(ctx, &OperationFilter{UserId: 1007, OrderField: "amount",
+				OrderDirection: "asc", Limit: 2, Offset: 1})
+			require.NoError(t, err)
+			require.Len(t, ops, 2)
+			assert.Equal(t, []string{"d", "c"}, []string{ops[0].Comment, ops[1].Comment})
+
+			ops, err = tx.Operations().List(ctx, &OperationFilter{UserId: 1007, OrderField: "date",
+				OrderDirection: "asc", Limit: 2, Offset: 4})
+			require.NoError(t, err)
+			assert.Len(t, ops, 0)
+
+			first, err := tx.Operations().List(ctx, &OperationFilter{UserId: 1007, OrderField: "date",
+				OrderDirection: "asc", Limit: 1})
+			require.NoError(t, err)
+			require.Len(t, first, 1)
+			ops, err = tx.Operations().List(ctx, &OperationFilter{UserId: 1007, OrderField: "date",
+				OrderDirection: "asc", Limit: 2, After: &OperationCursor{Date: first[0].Date, Id: first[0].Id}})
+			require.NoError(t, err)
+			require.Len(t, ops, 2, "next page must start after cursor")
+			assert.Equal(t, []string{"c", "d"}, []string{ops[0].Comment, ops[1].Comment})
+
+			from, to := day(11), time.Date(2020, 8, 12, 0, 0, 0, 0, time.UTC)
+			filter := &OperationFilter{UserId: 1007, From: &from, To: &to, OrderField: "date", OrderDirection: "asc", Limit: -1}
+			ops, err = tx.Operations().List(ctx, filter)
+			require.NoError(t, err)
+			require.Len(t, ops, 1)
+			assert.Equal(t, "c", ops[0].Comment)
+			require.NotNil(t, ops[0].OriginalCurrency, "conversion of operation must be stored")
+			assert.Equal(t, "USD", *ops[0].OriginalCurrency)
+			assert.Equal(t, "30.123456789", ops[0].Rate.String())
+			assert.Equal(t, "2020-08-15", *ops[0].RatesDate)
+
+			count, err := tx.Operations().Count(ctx, filter)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), count)
+
+			count, err = tx.Operations().Count(ctx, &OperationFilter{UserId: 1007})
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), count)
+
+			ops, err = tx.Operations().ListByIdempotencyToken(ctx, "storage-op-1")
+			require.NoError(t, err)
+			require.Len(t, ops, 2)
+			assert.Equal(t, int64(1007), ops[0].UserId)
+			assert.Equal(t, int64(1008), ops[1].UserId)
+			assert.True(t, decimal.NewFromInt(-10).Equal(ops[1].Amount))
+
+			sum, err := tx.Operations().SumAmount(ctx, 1007, &from, nil)
+			require.NoError(t, err)
+			assert.True(t, sum.Valid)
+			assert.True(t, decimal.NewFromInt(50).Equal(sum.Decimal))
+
+			sum, err = tx.Operations().SumAmount(ctx, 1007, nil, &from)
+			require.NoError(t, err)
+			assert.True(t, decimal.NewFromInt(10).Equal(sum.Decimal))
+
+			sum, err = tx.Operations().SumAmount(ctx, 1009, nil, nil)
+			require.NoError(t, err)
+			assert.False(t, sum.Valid, "sum of no operations must be null")
+		})
+	})
+
+	t.Run("operation of not existing user is not inserted", func(t *testing.T) {
+		storage := newStorage(t)
+
+		tx, err := storage.BeginTx(context.Background(), writeOpts)
+		require.NoError(t, err)
+		defer func() {
+			_ = tx.Rollback()
+		}()
+		err = tx.Operations().Insert(context.Background(), Operation{UserId: 1999, Amount: decimal.NewFromInt(1),
+			Date: created, IdempotencyToken: "storage-no-user"})
+		assert.Error(t, err)
+	})
+
+	t.Run("idempotency, token of committed operation is used", func(t *testing.T) {
+		storage := newStorage(t)
+		ctx := context.Background()
+		createUser(t, storage, 1010, "0")
+
+		inTx(t, storage, writeOpts, func(tx IStorageTx) {
+			used, err := tx.Idempotency().TokenIsUsed(ctx, "storage-token")
+			require.NoError(t, err)
+			assert.False(t, used)
+
+			err = tx.Operations().Insert(ctx, Operation{UserId: 1010, Amount: decimal.NewFromInt(1), Date: created,
+				IdempotencyToken: "storage-token"})
+			require.NoError(t, err)
+		})
+
+		inTx(t, storage, readOpts, func(tx IStorageTx) {
+			used, err := tx.Idempotency().TokenIsUsed(ctx, "storage-token")
+			require.NoError(t, err)
+			assert.True(t, used)
+		})
+	})
+
+	t.Run("snapshots, closing balances are stored once", func(t *testing.T) {
+		storage := newStorage(t)
+		ctx := context.Background()
+		createUser(t, storage, 1011, "100")
+
+		inTx(t, storage, writeOpts, func(tx IStorageTx) {
+			err := tx.Operations().Insert(ctx,
+				Operation{UserId: 1011, Amount: decimal.NewFromInt(40), Date: time.Date(2020, 8, 5, 10, 0, 0, 0, time.UTC),
+					IdempotencyToken: "storage-snapshot"})
+			require.NoError(t, err)
+		})
+
+		day := time.Date(2020, 8, 3, 0, 0, 0, 0, time.UTC)
+		var storedFirst int64
+		inTx(t, storage, writeOpts, func(tx IStorageTx) {
+			var err error
+			storedFirst, err = tx.Snapshots().InsertClosingBalances(ctx, day, day.AddDate(0, 0, 1))
+			require.NoError(t, err)
+			assert.True(t, storedFirst >= 1)
+		})
+
+		inTx(t, storage, writeOpts, func(tx IStorageTx) {
+			stored, err := tx.Snapshots().InsertClosingBalances(ctx, day, day.AddDate(0, 0, 1))
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), stored, "snapshots of the same day must be stored once")
+		})
+
+		inTx(t, storage, readOpts, func(tx IStorageTx) {
+			snapshot, err := tx.Snapshots().GetLatest(ctx, 1011, day.AddDate(0, 0, 5))
+			require.NoError(t, err)
+			assert.Equal(t, int64(1011), snapshot.UserId)
+			assert.True(t, day.Equal(StartOfDay(snapshot.Date)))
+			assert.True(t, decimal.NewFromInt(60).Equal(snapshot.Balance))
+
+			_, err = tx.Snapshots().GetLatest(ctx, 1011, day.AddDate(0, 0, -1))
+			assert.Equal(t, sql.ErrNoRows, err)
+		})
+	})
+
+	t.Run("quotes, insert, get and mark used", func(t *testing.T) {
+		storage := newStorage(t)
+		ctx := context.Background()
+		quote := &Quote{Id: uuid.NewV4().String(), FromCurrency: "USD", ToCurrency: "RUB", Amount: decimal.NewFromInt(100),
+			Rate: decimal.RequireFromString("74.349442379182156134"), ConvertedAmount: decimal.RequireFromString("7434.94"),
+			RatesDate: "2020-08-15", CreatedAt: created, ExpiresAt: created.Add(time.Minute)}
+
+		inTx(t, storage, writeOpts, func(tx IStorageTx) {
+			require.NoError(t, tx.Quotes().Insert(ctx, quote))
+		})
+
+		usedAt := created.Add(30 * time.Second)
+		inTx(t, storage, writeOpts, func(tx IStorageTx) {
+			stored, err := tx.Quotes().Get(ctx, quote.Id, RowLockForUpdate)
+			require.NoError(t, err)
+			assert.Nil(t, stored.UsedAt)
+			assert.Nil(t, stored.IdempotencyToken)
+			require.NoError(t, tx.Quotes().MarkUsed(ctx, quote.Id, "storage-quote", usedAt))
+		})
+
+		inTx(t, storage, readOpts, func(tx IStorageTx) {
+			stored, err := tx.Quotes().Get(ctx, quote.Id, RowLockNone)
+			require.NoError(t, err)
+			assert.Equal(t, "USD", stored.FromCurrency)
+			assert.Equal(t, "RUB", stored.ToCurrency)
+			assert.True(t, quote.Amount.Equal(stored.Amount))
+			assert.True(t, quote.Rate.Equal(stored.Rate), "rate must be stored with full precision")
+			assert.True(t, quote.ConvertedAmount.Equal(stored.ConvertedAmount))
+			assert.Equal(t, "2020-08-15", stored.RatesDate)
+			assert.True(t, quote.ExpiresAt.Equal(stored.ExpiresAt))
+			require.NotNil(t, stored.UsedAt)
+			assert.True(t, usedAt.Equal(*stored.UsedAt))
+			require.NotNil(t, stored.IdempotencyToken)
+			assert.Equal(t, "storage-quote", *stored.IdempotencyToken)
+
+			_, err = tx.Quotes().Get(ctx, uuid.NewV4().String(), RowLockNone)
+			assert.Equal(t, sql.ErrNoRows, err)
+		})
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		storage := newStorage(t)
+		createUser(t, storage, 1012, "0")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		tx, err := storage.BeginTx(ctx, writeOpts)
+		require.NoError(t, err)
+		defer func() {
+			_ = tx.Rollback()
+		}()
+
+		cancel()
+		err = tx.Users().AddToBalance(ctx, 1012, decimal.NewFromInt(1))
+		require.Error(t, err)
+		assert.NotNil(t, GetCtxError(ctx, err))
+	})
+}
+
+func TestMemoryStorage_Behaviour(t *testing.T) {
+	testStorageBehaviour(t, func(t *testing.T) IStorage {
+		return NewMemoryStorage()
+	})
+}
+
+func TestMemoryStorage_TransactionsAreSerialized(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+
+	tx, err := storage.BeginTx(ctx, &sql.TxOptions{})
+	require.NoError(t, err)
+
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = storage.BeginTx(waitCtx, &sql.TxOptions{ReadOnly: true})
+	assert.Equal(t, context.DeadlineExceeded, err, "transaction must wait until running transaction is finished")
+
+	require.NoError(t, tx.Commit())
+	tx, err = storage.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+}
@@ -15,26 +15,41 @@ func (dba *StubBillingAppCommon) GetUserBalance(ctx context.Context, in *Balance
 		return nil, &AppError{ErrUserDoesNotExist, http.StatusBadRequest}
 	}
 
+	balance := &UserBalance{
+		Balance:  "0",
+		Currency: in.Currency,
+	}
 	if in.UserId == 2 {
-		return &UserBalance{
-			Balance:  "10",
-			Currency: in.Currency,
-		}, nil
-	} else {
-		return &UserBalance{
-			Balance:  "0",
-			Currency: in.Currency,
-		}, nil
+		balance.Balance = "10"
 	}
 
+	if len(in.Currencies) > 0 {
+		balance.RatesDate = "2020-08-15"
+		for _, name := range in.Currencies {
+			if name == "UNKNOWN_CURRENCY" {
+				balance.Balances = append(balance.Balances, CurrencyBalance{Currency: name,
+					ErrorCode: CodeCurrencyNotFound, Error: ErrCurrencyDoesNotExist.Error()})
+				continue
+			}
+			balance.Balances = append(balance.Balances, CurrencyBalance{Currency: name, Balance: balance.Balance})
+		}
+	}
+	return balance, nil
 }
 
 func (dba *StubBillingAppCommon) CreditUserAccount(ctx context.Context, in *CreditAccountRequest) (*ResultState, error) {
+	if err := stubQuoteError(in.QuoteId); err != nil {
+		return nil, err
+	}
 
 	return &ResultState{State: MsgAccountCreditingDone}, nil
 }
 
 func (dba *StubBillingAppCommon) WithdrawUserAccount(ctx context.Context, in *WithdrawAccountRequest) (*ResultState, error) {
+	if err := stubQuoteError(in.QuoteId); err != nil {
+		return nil, err
+	}
+
 	if in.UserId != 1 && in.UserId != 2 {
 		return nil, &AppError{ErrUserDoesNotExist, http.StatusBadRequest}
 	}
@@ -47,6 +62,10 @@ func (dba *StubBillingAppCommon) WithdrawUserAccount(ctx context.Context, in *Wi
 }
 
 func (dba *StubBillingAppCommon) TransferMoneyFromUserToUser(ctx context.Context, in *MoneyTransferRequest) (*ResultState, error) {
+	if err := stubQuoteError(in.QuoteId); err != nil {
+		return nil, err
+	}
+
 	if in.SenderId != 1 && in.SenderId != 2 {
 		return nil, &AppError{ErrMoneySenderDoesNotExist, http.StatusBadRequest}
 	}
@@ -91,3 +110,190 @@ func (dba *StubBillingAppCommon) GetUserOperations(ctx context.Context, in *Oper
 		PagesTotal: 1,
 	}, nil
 }
+
+func (dba *StubBillingAppCommon) GetUserInfo(ctx context.Context, in *UserInfoRequest) (*UserInfo, error) {
+	if in.UserId != 1 && in.UserId != 2 {
+		return nil, &AppError{ErrUserDoesNotExist, http.StatusBadRequest}
+	}
+
+	createdAt, _ := time.Parse(time.RFC3339, "2020-08-11T10:23:58+03:00")
+	names := map[int64]string{1: "Mr. Smith", 2: "Mr. Jones"}
+
+	return &UserInfo{
+		UserId:    in.UserId,
+		Name:      names[in.UserId],
+		Currency:  "RUB",
+		CreatedAt: createdAt,
+	}, nil
+}
+
+func (dba *StubBillingAppCommon) GetOperationsByIdempotencyToken(ctx context.Context, in *IdempotencyLookupRequest) ([]Operation, error) {
+	if in.IdempotencyToken == "" {
+		return nil, &AppError{ErrIdempotencyTokenIsEmpty, http.StatusBadRequest}
+	}
+
+	if in.IdempotencyToken != "1" {
+		return []Operation{}, nil
+	}
+
+	datetime, _ := time.Parse(time.RFC3339, "2020-08-11T10:23:58+03:00")
+	return []Operation{{
+		Id:               1,
+		UserId:           1,
+		Comment:          "incoming payment",
+		Amount:           decimal.NewFromInt(10),
+		Date:             datetime,
+		IdempotencyToken: "1",
+	}}, nil
+}
+
+func (dba *StubBillingAppCommon) GetUserBalanceOnDate(ctx context.Context, in *BalanceOnDateRequest) (*BalanceOnDate, error) {
+	if in.UserId != 1 && in.UserId != 2 {
+		return nil, &AppError{ErrUserDoesNotExist, http.StatusBadRequest}
+	}
+
+	day, err := parseDate(in.Date)
+	if err != nil {
+		return nil, &AppError{err, http.StatusBadRequest}
+	}
+
+	balance := "0"
+	if in.UserId == 2 {
+		balance = "10"
+	}
+
+	return &BalanceOnDate{
+		UserId:   in.UserId,
+		Date:     day.Format(dateLayout),
+		Balance:  balance,
+		Currency: "RUB",
+	}, nil
+}
+
+func (dba *StubBillingAppCommon) GetUserStatement(ctx context.Context, in *StatementRequest) (*Statement, error) {
+	if in.UserId != 1 && in.UserId != 2 {
+		return nil, &AppError{ErrUserDoesNotExist, http.StatusBadRequest}
+	}
+
+	from, err := parseDate(in.From)
+	if err != nil {
+		return nil, &AppError{err, http.StatusBadRequest}
+	}
+
+	to, err := parseDate(in.To)
+	if err != nil {
+		return nil, &AppError{err, http.StatusBadRequest}
+	}
+
+	if from.After(to) {
+		return nil, &AppError{ErrPeriodStartIsAfterEnd, http.StatusBadRequest}
+	}
+
+	operationsLog, err := dba.GetUserOperations(ctx, &OperationLogRequest{UserId: 1})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStatement(in.UserId, from, to, decimal.Zero, decimal.Zero, operationsLog.Operations), nil
+}
+
+func (dba *StubBillingAppCommon) StreamUserStatement(ctx context.Context, in *StatementStreamRequest,
+	onOpening func(decimal.Decimal) error, onPage func([]Operation) error) error {
+	opening, err := dba.GetUserBalanceOnDate(ctx, &BalanceOnDateRequest{UserId: in.UserId, Date: in.From})
+	if err != nil {
+		return err
+	}
+
+	err = onOpening(decimal.RequireFromString(opening.Balance))
+	if err != nil {
+		return err
+	}
+
+	operationsLog, err := dba.GetUserOperations(ctx, &OperationLogRequest{UserId: in.UserId})
+	if err != nil {
+		return err
+	}
+	return onPage(operationsLog.Operations)
+}
+
+//stub quotes: quote "1" is valid, quote "2" is expired, other quotes do not exist
+const (
+	stubValidQuoteId   = "1"
+	stubExpiredQuoteId = "2"
+)
+
+func stubQuoteError(quoteId string) error {
+	switch quoteId {
+	case "", stubValidQuoteId:
+		return nil
+	case stubExpiredQuoteId:
+		return &AppError{ErrQuoteIsExpired, http.StatusBadRequest}
+	default:
+		return &AppError{ErrQuoteDoesNotExist, http.StatusBadRequest}
+	}
+}
+
+func (dba *StubBillingAppCommon) CreateQuote(ctx context.Context, in *QuoteRequest) (*Quote, error) {
+	if in.From != "RUB" && in.To != "RUB" {
+		return nil, &AppError{ErrQuoteCurrencyIsNotBase, http.StatusBadRequest}
+	}
+
+	amount, err := decimal.NewFromString(in.Amount)
+	if err != nil {
+		return nil, &AppError{ErrFailedToCastAmountToDecimal, http.StatusBadRequest}
+	}
+
+	createdAt, _ := time.Parse(time.RFC3339, "2020-08-15T10:23:58Z")
+	return &Quote{
+		Id:              stubValidQuoteId,
+		FromCurrency:    in.From,
+		ToCurrency:      in.To,
+		Amount:          amount,
+		Rate:            decimal.NewFromInt(2),
+		ConvertedAmount: amount.Mul(decimal.NewFromInt(2)),
+		RatesDate:       "2020-08-15",
+		CreatedAt:       createdAt,
+		ExpiresAt:       createdAt.Add(defaultQuoteTTL),
+	}, nil
+}
+
+func (dba *StubBillingAppCommon) GetQuote(ctx context.Context, in *QuoteLookupRequest) (*Quote, error) {
+	if in.QuoteId != stubValidQuoteId {
+		return nil, &AppError{ErrQuoteDoesNotExist, http.StatusBadRequest}
+	}
+	return dba.CreateQuote(ctx, &QuoteRequest{From: "USD", To: "RUB", Amount: "100"})
+}
+
+func (dba *StubBillingAppCommon) GrantBonus(ctx context.Context, in *BonusGrantRequest) (*ResultState, error) {
+	if in.UserId != 1 && in.UserId != 2 {
+		return nil, &AppError{ErrUserDoesNotExist, http.StatusBadRequest}
+	}
+
+	if in.ExpiresAt.IsZero() {
+		return nil, &AppError{ErrBonusExpirationIsNotInFuture, http.StatusBadRequest}
+	}
+
+	return &ResultState{State: MsgBonusGrantDone}, nil
+}
+
+func (dba *StubBillingAppCommon) GetUserBonuses(ctx context.Context, in *BonusBalanceRequest) (*BonusBalance, error) {
+	if in.UserId != 1 && in.UserId != 2 {
+		return nil, &AppError{ErrUserDoesNotExist, http.StatusBadRequest}
+	}
+
+	balance := &BonusBalance{UserId: in.UserId, BonusBalance: "0", Currency: "RUB", Grants: []BonusGrant{}}
+	if in.UserId == 2 {
+		grantedAt, _ := time.Parse(time.RFC3339, "2020-08-15T10:23:58Z")
+		balance.BonusBalance = "50"
+		balance.Grants = append(balance.Grants, BonusGrant{
+			Id:               1,
+			UserId:           2,
+			Amount:           decimal.NewFromInt(100),
+			Remaining:        decimal.NewFromInt(50),
+			GrantedAt:        grantedAt,
+			ExpiresAt:        grantedAt.AddDate(0, 1, 0),
+			IdempotencyToken: "1",
+		})
+	}
+	return balance, nil
+}
@@ -13,6 +13,7 @@ import (
 	"job-backend-trainee-assignment/internal/cache"
 	"job-backend-trainee-assignment/internal/db_connector"
 	"job-backend-trainee-assignment/internal/exchanger"
+	"job-backend-trainee-assignment/internal/idempotency"
 	"job-backend-trainee-assignment/internal/logger"
 	"job-backend-trainee-assignment/internal/test_helpers"
 	"testing"
@@ -488,7 +489,7 @@ func TestBillingApp_WithStubExchanger_StubCacheCommonWithNoKeyExist_Common(t *te
 					Amount:           "10",
 					IdempotencyToken: uuid.NewV4().String(),
 				},
-				expectedResult: &ResultState{MsgMoneyTransferDone},
+				expectedResult: &ResultState{State: MsgMoneyTransferDone},
 				expectedError:  nil,
 			},
 			{
@@ -499,7 +500,7 @@ func TestBillingApp_WithStubExchanger_StubCacheCommonWithNoKeyExist_Common(t *te
 					Amount:           "10",
 					IdempotencyToken: "1",
 				},
-				expectedResult: &ResultState{OperationTokenIsAlreadyUsed},
+				expectedResult: &ResultState{State: OperationTokenIsAlreadyUsed},
 				expectedError:  nil,
 			},
 			{
@@ -1548,6 +1549,9 @@ func TestBillingApp_WithStubExchanger_StubCacheWithAnyKeyExist_Common(t *testing
 	app, err := NewApp(dummyLogger, db, ex, dummyCacher, nil)
 	require.NoErrorf(t, err, "failed to create BillingApp instance, err %v", err)
 
+	err = app.SetIdempotencyStore(&idempotency.StubStoreWithAnyKeyCompleted{})
+	require.NoErrorf(t, err, "failed to set idempotency store, err %v", err)
+
 	caseTimeout := v.GetDuration("testing_params.test_case_timeout") * time.Second
 
 	t.Run("CreditUserAccount method of BillingApp", func(t *testing.T) {
@@ -1666,7 +1670,7 @@ func TestBillingApp_WithStubExchanger_StubCacheWithAnyKeyExist_Common(t *testing
 					Amount:           "10",
 					IdempotencyToken: uuid.NewV4().String(),
 				},
-				expectedResult: &ResultState{OperationTokenIsAlreadyUsed},
+				expectedResult: &ResultState{State: OperationTokenIsAlreadyUsed},
 				expectedError:  nil,
 			},
 			{
@@ -1694,8 +1698,9 @@ func TestBillingApp_WithStubExchanger_StubCacheWithAnyKeyExist_Common(t *testing
 
 }
 
-//even if cache returns error, app must go on working
-func TestBillingApp_WithStubExchanger_StubCacheFaulty_Common(t *testing.T) {
+//even if idempotency store returns error, app must go on working.
+//Operations are not acknowledged, if balance cache is not invalidated, see TestBillingApp_BalanceCache_Common
+func TestBillingApp_WithStubExchanger_StubStoreFaulty_Common(t *testing.T) {
 	v := viper.New()
 
 	v.AddConfigPath(".")
@@ -1735,10 +1740,13 @@ func TestBillingApp_WithStubExchanger_StubCacheFaulty_Common(t *testing.T) {
 	defer dbCloseFunc()
 
 	ex := &exchanger.StubExchanger{}
-	dummyCacher := &cache.StubCacheFaulty{}
+	dummyCacher := &cache.DummyCacheCommon{}
 	app, err := NewApp(dummyLogger, db, ex, dummyCacher, nil)
 	require.NoErrorf(t, err, "failed to create BillingApp instance, err %v", err)
 
+	err = app.SetIdempotencyStore(&idempotency.StubStoreFaulty{})
+	require.NoErrorf(t, err, "failed to set idempotency store, err %v", err)
+
 	caseTimeout := v.GetDuration("testing_params.test_case_timeout") * time.Second
 
 	//only those methods which use checkig for token in cache
@@ -2056,7 +2064,7 @@ func TestBillingApp_WithStubExchanger_StubCacheFaulty_Common(t *testing.T) {
 					Amount:           "10",
 					IdempotencyToken: uuid.NewV4().String(),
 				},
-				expectedResult: &ResultState{MsgMoneyTransferDone},
+				expectedResult: &ResultState{State: MsgMoneyTransferDone},
 				expectedError:  nil,
 			},
 			{
@@ -2067,7 +2075,7 @@ func TestBillingApp_WithStubExchanger_StubCacheFaulty_Common(t *testing.T) {
 					Amount:           "10",
 					IdempotencyToken: "1",
 				},
-				expectedResult: &ResultState{OperationTokenIsAlreadyUsed},
+				expectedResult: &ResultState{State: OperationTokenIsAlreadyUsed},
 				expectedError:  nil,
 			},
 			{
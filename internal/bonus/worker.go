@@ -0,0 +1,104 @@
+package bonus
+
+import (
+	"context"
+	"fmt"
+	"job-backend-trainee-assignment/internal/logger"
+	"sync"
+	"time"
+)
+
+type IBonusExpirer interface {
+	ExpireBonuses(ctx context.Context, now time.Time, limit int64) (int64, error)
+}
+
+//Worker periodically writes off expired bonus grants, oldest expiration first
+type Worker struct {
+	logger  logger.ILogger
+	expirer IBonusExpirer
+	cfg     *Config
+	mu      sync.Mutex
+}
+
+type Config struct {
+	//CheckInterval is a period of expired grants check
+	CheckInterval time.Duration
+	//BatchSize is a number of grants, expired in one transaction
+	BatchSize int64
+}
+
+var (
+	defaultCheckInterval       = time.Minute
+	defaultBatchSize     int64 = 100
+)
+
+func NewWorker(logger logger.ILogger, expirer IBonusExpirer, cfg *Config) (*Worker, error) {
+	if logger == nil {
+		return nil, fmt.Errorf("must provide non-nil logger instance")
+	}
+
+	if expirer == nil {
+		return nil, fmt.Errorf("must provide non-nil bonus expirer instance")
+	}
+
+	if cfg == nil {
+		cfg = &Config{CheckInterval: defaultCheckInterval, BatchSize: defaultBatchSize}
+	}
+
+	if cfg.CheckInterval <= 0 || cfg.BatchSize <= 0 {
+		return nil, fmt.Errorf("check interval and batch size must be positive")
+	}
+
+	return &Worker{
+		logger:  logger,
+		expirer: expirer,
+		cfg:     cfg,
+		mu:      sync.Mutex{},
+	}, nil
+}
+
+//Run expires bonus grants until ctx is done
+func (w *Worker) Run(ctx context.Context) {
+	w.mu.Lock()
+	checkInterval := w.cfg.CheckInterval
+	w.mu.Unlock()
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := w.ExpireGrants(ctx, time.Now())
+		if err != nil {
+			w.logger.Error("Run, failed to expire bonus grants, err %v", err)
+		} else if expired > 0 {
+			w.logger.Info("Run, expired %d bonus grants", expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			w.logger.Info("Run, context is done, stopping bonus worker")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//ExpireGrants writes off grants, expired at now, by batches while expired grants are left
+func (w *Worker) ExpireGrants(ctx context.Context, now time.Time) (int64, error) {
+	w.mu.Lock()
+	batchSize := w.cfg.BatchSize
+	w.mu.Unlock()
+
+	var expiredTotal int64
+	for {
+		expired, err := w.expirer.ExpireBonuses(ctx, now, batchSize)
+		if err != nil {
+			return expiredTotal, err
+		}
+		expiredTotal += expired
+
+		if expired < batchSize {
+			return expiredTotal, nil
+		}
+	}
+}
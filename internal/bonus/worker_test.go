@@ -0,0 +1,75 @@
+package bonus
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/logger"
+	"testing"
+	"time"
+)
+
+var errStubExpirationFailed = errors.New("stub expiration failed")
+
+type StubExpirer struct {
+	expired   int64
+	batches   []int64
+	failAfter int
+}
+
+func (se *StubExpirer) ExpireBonuses(ctx context.Context, now time.Time, limit int64) (int64, error) {
+	if se.failAfter > 0 && len(se.batches) == se.failAfter {
+		return 0, errStubExpirationFailed
+	}
+
+	batch := limit
+	if se.expired < limit {
+		batch = se.expired
+	}
+	se.expired -= batch
+	se.batches = append(se.batches, batch)
+	return batch, nil
+}
+
+func TestWorker_ExpireGrants(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2020-08-11T10:23:58+03:00")
+
+	t.Run("positive path, grants are expired by batches", func(t *testing.T) {
+		expirer := &StubExpirer{expired: 5}
+		w, err := NewWorker(&logger.DummyLogger{}, expirer, &Config{CheckInterval: time.Minute, BatchSize: 2})
+		require.NoError(t, err, "NewWorker must not return error")
+
+		expired, err := w.ExpireGrants(context.Background(), now)
+		assert.NoError(t, err, "must not return error")
+		assert.Equal(t, int64(5), expired, "expired grants number must match")
+		assert.Equal(t, []int64{2, 2, 1}, expirer.batches, "batches must match")
+	})
+
+	t.Run("positive path, full last batch is followed by empty batch", func(t *testing.T) {
+		expirer := &StubExpirer{expired: 4}
+		w, err := NewWorker(&logger.DummyLogger{}, expirer, &Config{CheckInterval: time.Minute, BatchSize: 2})
+		require.NoError(t, err, "NewWorker must not return error")
+
+		expired, err := w.ExpireGrants(context.Background(), now)
+		assert.NoError(t, err, "must not return error")
+		assert.Equal(t, int64(4), expired, "expired grants number must match")
+		assert.Equal(t, []int64{2, 2, 0}, expirer.batches, "batches must match")
+	})
+
+	t.Run("negative path, expiration failure stops the check", func(t *testing.T) {
+		expirer := &StubExpirer{expired: 5, failAfter: 1}
+		w, err := NewWorker(&logger.DummyLogger{}, expirer, &Config{CheckInterval: time.Minute, BatchSize: 2})
+		require.NoError(t, err, "NewWorker must not return error")
+
+		expired, err := w.ExpireGrants(context.Background(), now)
+		assert.True(t, errors.Is(err, errStubExpirationFailed), "must return expirer error")
+		assert.Equal(t, int64(2), expired, "expired grants number must match")
+	})
+
+	t.Run("negative path, expirer is nil", func(t *testing.T) {
+		w, err := NewWorker(&logger.DummyLogger{}, nil, nil)
+		assert.Error(t, err, "must get error on NewWorker creating")
+		assert.Nil(t, w, "ptr to worker instance must be nil")
+	})
+}
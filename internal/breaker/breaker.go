@@ -0,0 +1,169 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//State is a state of circuit breaker
+type State string
+
+const (
+	//StateClosed passes all calls, consecutive failures are counted
+	StateClosed State = "closed"
+	//StateOpen rejects all calls until open timeout is passed
+	StateOpen State = "open"
+	//StateHalfOpen passes limited number of probe calls, result of probe closes or reopens breaker
+	StateHalfOpen State = "half_open"
+)
+
+var ErrBreakerIsOpen = errors.New("circuit breaker is open")
+
+type Config struct {
+	//FailureThreshold is a number of consecutive failures, which opens breaker
+	FailureThreshold int
+	//OpenTimeout is a time, breaker stays open before probing
+	OpenTimeout time.Duration
+	//HalfOpenProbes is a max number of concurrent probe calls in half open state
+	HalfOpenProbes int
+}
+
+var (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 10 * time.Second
+	defaultHalfOpenProbes   = 1
+)
+
+//Validate checks config, zero values are replaced by defaults
+func (cfg *Config) Validate() error {
+	if cfg.FailureThreshold < 0 || cfg.OpenTimeout < 0 || cfg.HalfOpenProbes < 0 {
+		return fmt.Errorf("breaker config params must be non-negative")
+	}
+	return nil
+}
+
+//swagger:model BreakerStatus
+//Status represents current state of breaker, shown in health check
+type Status struct {
+	//name of protected dependency
+	//example: redis
+	Name string `json:"name"`
+	//state of breaker, one of closed, open, half_open
+	//example: closed
+	State State `json:"state"`
+	//number of consecutive failed calls
+	//example: 0
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	//time, breaker was opened at, omitted for closed breaker
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+}
+
+//Breaker is a circuit breaker, protecting callers from waiting for unavailable dependency
+type Breaker struct {
+	name     string
+	cfg      Config
+	state    State
+	failures int
+	openedAt time.Time
+	probes   int
+	now      func() time.Time
+	mu       sync.Mutex
+}
+
+func NewBreaker(name string, cfg *Config) (*Breaker, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	c := *cfg
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = defaultFailureThreshold
+	}
+	if c.OpenTimeout == 0 {
+		c.OpenTimeout = defaultOpenTimeout
+	}
+	if c.HalfOpenProbes == 0 {
+		c.HalfOpenProbes = defaultHalfOpenProbes
+	}
+
+	return &Breaker{name: name, cfg: c, state: StateClosed, now: time.Now}, nil
+}
+
+//Allow returns ErrBreakerIsOpen if call must be skipped, otherwise call must be reported by Done
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen {
+		if b.now().Sub(b.openedAt) < b.cfg.OpenTimeout {
+			return ErrBreakerIsOpen
+		}
+		b.state = StateHalfOpen
+		b.probes = 0
+	}
+
+	if b.state == StateHalfOpen {
+		if b.probes >= b.cfg.HalfOpenProbes {
+			return ErrBreakerIsOpen
+		}
+		b.probes++
+	}
+	return nil
+}
+
+//Done reports result of allowed call, failed is true if dependency did not respond properly
+func (b *Breaker) Done(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen {
+		b.probes--
+		if failed {
+			b.open()
+		} else {
+			b.state = StateClosed
+			b.failures = 0
+		}
+		return
+	}
+
+	if !failed {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == StateClosed && b.failures >= b.cfg.FailureThreshold {
+		b.open()
+	}
+}
+
+func (b *Breaker) open() {
+	b.state = StateOpen
+	b.openedAt = b.now()
+	b.probes = 0
+}
+
+//Status returns current state of breaker
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := Status{Name: b.name, State: b.state, ConsecutiveFailures: b.failures}
+	if b.state != StateClosed {
+		openedAt := b.openedAt
+		s.OpenedAt = &openedAt
+	}
+	return s
+}
+
+//Name returns name of protected dependency
+func (b *Breaker) Name() string {
+	return b.name
+}
@@ -0,0 +1,76 @@
+package breaker
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBreaker_Common(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2020-08-11T10:23:58+03:00")
+
+	newBreaker := func(t *testing.T) *Breaker {
+		b, err := NewBreaker("redis", &Config{FailureThreshold: 2, OpenTimeout: 10 * time.Second, HalfOpenProbes: 1})
+		require.NoError(t, err, "NewBreaker must not return error")
+		b.now = func() time.Time { return now }
+		return b
+	}
+
+	t.Run("positive path, breaker opens after consecutive failures", func(t *testing.T) {
+		b := newBreaker(t)
+		require.NoError(t, b.Allow())
+		b.Done(true)
+		require.NoError(t, b.Allow())
+		b.Done(false)
+		require.NoError(t, b.Allow())
+		b.Done(true)
+		assert.Equal(t, StateClosed, b.Status().State, "success must reset failures")
+
+		require.NoError(t, b.Allow())
+		b.Done(true)
+		assert.Equal(t, StateOpen, b.Status().State, "breaker must be open")
+		assert.Equal(t, ErrBreakerIsOpen, b.Allow(), "open breaker must reject calls")
+	})
+
+	t.Run("positive path, successful probe closes breaker", func(t *testing.T) {
+		b := newBreaker(t)
+		for i := 0; i < 2; i++ {
+			require.NoError(t, b.Allow())
+			b.Done(true)
+		}
+
+		b.now = func() time.Time { return now.Add(10 * time.Second) }
+		require.NoError(t, b.Allow(), "probe must be allowed after open timeout")
+		assert.Equal(t, StateHalfOpen, b.Status().State, "breaker must be half open")
+		assert.Equal(t, ErrBreakerIsOpen, b.Allow(), "only one probe must be allowed")
+
+		b.Done(false)
+		assert.Equal(t, StateClosed, b.Status().State, "breaker must be closed")
+		assert.NoError(t, b.Allow())
+	})
+
+	t.Run("negative path, failed probe reopens breaker", func(t *testing.T) {
+		b := newBreaker(t)
+		for i := 0; i < 2; i++ {
+			require.NoError(t, b.Allow())
+			b.Done(true)
+		}
+
+		probeTime := now.Add(15 * time.Second)
+		b.now = func() time.Time { return probeTime }
+		require.NoError(t, b.Allow())
+		b.Done(true)
+
+		status := b.Status()
+		assert.Equal(t, StateOpen, status.State, "breaker must be open")
+		require.NotNil(t, status.OpenedAt)
+		assert.Equal(t, probeTime, *status.OpenedAt, "open time must be updated")
+		assert.Equal(t, ErrBreakerIsOpen, b.Allow())
+	})
+
+	t.Run("negative path, negative config params", func(t *testing.T) {
+		_, err := NewBreaker("redis", &Config{FailureThreshold: -1})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"job-backend-trainee-assignment/internal/breaker"
+	"job-backend-trainee-assignment/internal/logger"
+)
+
+// BreakerCache is a ICacher, which skips cache calls while circuit breaker is open,
+// so callers fall back to database at once instead of waiting for lookup timeout of unavailable cache
+type BreakerCache struct {
+	logger  logger.ILogger
+	cache   ICacher
+	breaker *breaker.Breaker
+}
+
+func NewBreakerCache(log logger.ILogger, cache ICacher, b *breaker.Breaker) (*BreakerCache, error) {
+	if log == nil {
+		return nil, fmt.Errorf("provided logger param is nil")
+	}
+
+	if cache == nil {
+		return nil, fmt.Errorf("provided cache param is nil")
+	}
+
+	if b == nil {
+		return nil, fmt.Errorf("provided breaker param is nil")
+	}
+
+	return &BreakerCache{logger: log, cache: cache, breaker: b}, nil
+}
+
+// allow returns ErrCacheIsUnavailable if breaker is open
+func (c *BreakerCache) allow(methodName string) error {
+	if err := c.breaker.Allow(); err != nil {
+		return fmt.Errorf("%s, %v, err: %w", methodName, err, ErrCacheIsUnavailable)
+	}
+	return nil
+}
+
+// done reports call result to breaker, cancellation of caller context is not a cache failure
+func (c *BreakerCache) done(ctx context.Context, err error) {
+	before := c.breaker.Status().State
+	c.breaker.Done(err != nil && ctx.Err() == nil)
+	if after := c.breaker.Status().State; after != before {
+		c.logger.Error("cache circuit breaker state changed from %s to %s, last err: %v", before, after, err)
+	}
+}
+
+func (c *BreakerCache) CheckKeyExistence(ctx context.Context, key string) (keyExists bool, err error) {
+	if err := c.allow("CheckKeyExistence"); err != nil {
+		return false, err
+	}
+	keyExists, err = c.cache.CheckKeyExistence(ctx, key)
+	c.done(ctx, err)
+	return keyExists, err
+}
+
+func (c *BreakerCache) AddKey(ctx context.Context, key string) (err error) {
+	if err := c.allow("AddKey"); err != nil {
+		return err
+	}
+	err = c.cache.AddKey(ctx, key)
+	c.done(ctx, err)
+	return err
+}
+
+func (c *BreakerCache) GetValue(ctx context.Context, key string) (value string, found bool, err error) {
+	if err := c.allow("GetValue"); err != nil {
+		return "", false, err
+	}
+	value, found, err = c.cache.GetValue(ctx, key)
+	c.done(ctx, err)
+	return value, found, err
+}
+
+func (c *BreakerCache) SetValue(ctx context.Context, key string, value string) (err error) {
+	if err := c.allow("SetValue"); err != nil {
+		return err
+	}
+	err = c.cache.SetValue(ctx, key, value)
+	c.done(ctx, err)
+	return err
+}
+
+func (c *BreakerCache) GetVersion(ctx context.Context, key string) (version int64, err error) {
+	if err := c.allow("GetVersion"); err != nil {
+		return 0, err
+	}
+	version, err = c.cache.GetVersion(ctx, key)
+	c.done(ctx, err)
+	return version, err
+}
+
+// IncrVersion is skipped while breaker is open, like other calls: caller must handle the error,
+// for example by retrying the increment in background, so writes do not wait for unavailable cache
+func (c *BreakerCache) IncrVersion(ctx context.Context, key string) (version int64, err error) {
+	if err := c.allow("IncrVersion"); err != nil {
+		return 0, err
+	}
+	version, err = c.cache.IncrVersion(ctx, key)
+	c.done(ctx, err)
+	return version, err
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/breaker"
+	"job-backend-trainee-assignment/internal/logger"
+	"testing"
+	"time"
+)
+
+func TestBreakerCache_Common(t *testing.T) {
+	dummyLogger := &logger.DummyLogger{}
+
+	newBreaker := func(t *testing.T) *breaker.Breaker {
+		b, err := breaker.NewBreaker("redis", &breaker.Config{FailureThreshold: 2, OpenTimeout: time.Hour})
+		require.NoError(t, err, "NewBreaker must not return error")
+		return b
+	}
+
+	t.Run("negative path, faulty cache is skipped after failures", func(t *testing.T) {
+		b := newBreaker(t)
+		c, err := NewBreakerCache(dummyLogger, &StubCacheFaulty{}, b)
+		require.NoError(t, err, "NewBreakerCache must not return error")
+
+		for i := 0; i < 2; i++ {
+			_, err := c.GetVersion(context.Background(), "key")
+			assert.True(t, errors.Is(err, ErrFailedToGetConnFromPool), "error of cache must be returned")
+		}
+		assert.Equal(t, breaker.StateOpen, b.Status().State, "breaker must be open")
+
+		_, _, err = c.GetValue(context.Background(), "key")
+		assert.True(t, errors.Is(err, ErrCacheIsUnavailable), "cache call must be skipped")
+	})
+
+	t.Run("negative path, version increment is skipped by open breaker", func(t *testing.T) {
+		b := newBreaker(t)
+		countingCache := &stubCacheCountingCalls{StubCacheInMemory: NewStubCacheInMemory()}
+		c, err := NewBreakerCache(dummyLogger, countingCache, b)
+		require.NoError(t, err, "NewBreakerCache must not return error")
+
+		for i := 0; i < 2; i++ {
+			require.NoError(t, b.Allow())
+			b.Done(true)
+		}
+		require.Equal(t, breaker.StateOpen, b.Status().State, "breaker must be open")
+
+		_, err = c.IncrVersion(context.Background(), "key")
+		assert.True(t, errors.Is(err, ErrCacheIsUnavailable), "version increment must be skipped")
+		assert.Equal(t, 0, countingCache.calls, "cache must not be called while breaker is open")
+
+		version, err := countingCache.GetVersion(context.Background(), "key")
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), version, "version must not be incremented in cache")
+	})
+
+	t.Run("positive path, cancelled caller context is not a cache failure", func(t *testing.T) {
+		b := newBreaker(t)
+		c, err := NewBreakerCache(dummyLogger, &StubCacheFaulty{}, b)
+		require.NoError(t, err, "NewBreakerCache must not return error")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		for i := 0; i < 3; i++ {
+			_ = c.AddKey(ctx, "key")
+		}
+		assert.Equal(t, breaker.StateClosed, b.Status().State, "breaker must be closed")
+	})
+
+	t.Run("positive path, working cache keeps breaker closed", func(t *testing.T) {
+		b := newBreaker(t)
+		c, err := NewBreakerCache(dummyLogger, NewStubCacheInMemory(), b)
+		require.NoError(t, err, "NewBreakerCache must not return error")
+
+		require.NoError(t, c.SetValue(context.Background(), "key", "value"))
+		value, found, err := c.GetValue(context.Background(), "key")
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "value", value)
+		assert.Equal(t, breaker.StateClosed, b.Status().State, "breaker must be closed")
+	})
+}
+
+//stubCacheCountingCalls counts version increments, passed to wrapped cache
+type stubCacheCountingCalls struct {
+	*StubCacheInMemory
+	calls int
+}
+
+func (c *stubCacheCountingCalls) IncrVersion(ctx context.Context, key string) (int64, error) {
+	c.calls++
+	return c.StubCacheInMemory.IncrVersion(ctx, key)
+}
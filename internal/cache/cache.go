@@ -18,6 +18,10 @@ var (
 type ICacher interface {
 	CheckKeyExistence(ctx context.Context, key string) (keyExists bool, err error)
 	AddKey(ctx context.Context, key string) (err error)
+	GetValue(ctx context.Context, key string) (value string, found bool, err error)
+	SetValue(ctx context.Context, key string, value string) (err error)
+	GetVersion(ctx context.Context, key string) (version int64, err error)
+	IncrVersion(ctx context.Context, key string) (version int64, err error)
 }
 
 type CacheConfig struct {
@@ -61,3 +65,34 @@ func NewRedisCache(log logger.ILogger, redisPool *redis.Pool, cfg *CacheConfig)
 		mu:     sync.Mutex{},
 	}, nil
 }
+
+//GetConfig returns copy of current cache config
+func (c *RedisCache) GetConfig() CacheConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return *c.cfg
+}
+
+//Validate checks config, which may be changed on config reload
+func (cfg *CacheConfig) Validate() error {
+	if cfg.KeyExpirationTime <= 0 || cfg.KeySetTimeout <= 0 || cfg.KeyLookupTimeout <= 0 {
+		return fmt.Errorf("key expiration time and timeouts must be positive")
+	}
+	return nil
+}
+
+//SetConfig replaces cache config, it is used on config reload
+func (c *RedisCache) SetConfig(cfg *CacheConfig) error {
+	if cfg == nil {
+		return fmt.Errorf("must provide non-nil config")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.cfg = cfg
+	c.mu.Unlock()
+	return nil
+}
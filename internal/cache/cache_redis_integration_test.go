@@ -139,6 +139,42 @@ func TestCacheCommon_TestCacheIntegrationWithRedis(t *testing.T) {
 			cancelTestCase()
 		}
 	})
+
+	t.Run("check value and version behaviour", func(t *testing.T) {
+		ctx, cancelCase := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancelCase()
+
+		versionKey := "SomeVersionKey" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		version, err := redisCache.GetVersion(ctx, versionKey)
+		assert.NoError(t, err, "GetVersion must not return error")
+		assert.Equal(t, int64(0), version, "not existing version must be zero")
+
+		version, err = redisCache.IncrVersion(ctx, versionKey)
+		assert.NoError(t, err, "IncrVersion must not return error")
+		assert.Equal(t, int64(1), version, "incremented version must match")
+
+		version, err = redisCache.GetVersion(ctx, versionKey)
+		assert.NoError(t, err, "GetVersion must not return error")
+		assert.Equal(t, int64(1), version, "version must match")
+
+		valueKey := "SomeValueKey" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		_, found, err := redisCache.GetValue(ctx, valueKey)
+		assert.NoError(t, err, "GetValue must not return error")
+		assert.False(t, found, "not existing value must not be found")
+
+		err = redisCache.SetValue(ctx, valueKey, "100.50")
+		assert.NoError(t, err, "SetValue must not return error")
+
+		value, found, err := redisCache.GetValue(ctx, valueKey)
+		assert.NoError(t, err, "GetValue must not return error")
+		assert.True(t, found, "stored value must be found")
+		assert.Equal(t, "100.50", value, "stored value must match")
+
+		timeoutCtx, cancelTimeout := context.WithTimeout(ctx, time.Nanosecond)
+		defer cancelTimeout()
+		_, _, err = redisCache.GetValue(timeoutCtx, valueKey)
+		assert.ErrorIs(t, err, ErrContextDeadlineExceeded, "GetValue must return timeout error")
+	})
 }
 
 //test intended to check concurrent redis cacheStruct + connector + redis instance behaviour
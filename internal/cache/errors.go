@@ -11,4 +11,5 @@ var (
 	ErrFailedToPerformDoCommand = errors.New("failed to perform Do redigo command")
 	ErrKeyLookUpTimeout         = errors.New("failed to lookup key, context timeout")
 	ErrKeySetTimeout            = errors.New("failed to set key, context timeout")
+	ErrCacheIsUnavailable       = errors.New("cache is unavailable, call is skipped")
 )
@@ -0,0 +1,397 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"job-backend-trainee-assignment/internal/logger"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	clusterSlotsNum     = 16384
+	clusterMaxRedirects = 5
+	clusterRetryPause   = 100 * time.Millisecond
+)
+
+var ErrClusterPipeliningIsNotSupported = errors.New("pipelining is not supported in redis cluster mode")
+
+// clusterRouter keeps connection pools of cluster nodes and routes commands to node, serving slot of command key.
+// Slots are loaded by CLUSTER SLOTS and reloaded on MOVED redirection
+type clusterRouter struct {
+	logger  logger.ILogger
+	cfg     *ConnConfig
+	options []redis.DialOption
+	pools   map[string]*redis.Pool
+	slots   [clusterSlotsNum]string
+	loaded  bool
+	mu      sync.RWMutex
+}
+
+func newClusterRouter(log logger.ILogger, cfg *ConnConfig, tlsConfig *tls.Config) *clusterRouter {
+	return &clusterRouter{
+		logger:  log,
+		cfg:     cfg,
+		options: cfg.dialOptions(tlsConfig, false),
+		pools:   map[string]*redis.Pool{},
+		mu:      sync.RWMutex{},
+	}
+}
+
+// dial returns connection, routing every command to cluster node, slots are loaded on first dial
+func (cr *clusterRouter) dial(ctx context.Context) (redis.Conn, error) {
+	cr.mu.RLock()
+	loaded := cr.loaded
+	cr.mu.RUnlock()
+
+	if !loaded {
+		if err := cr.refresh(ctx); err != nil {
+			cr.logger.Error("DialContext error, err: %v", err)
+			return nil, err
+		}
+	}
+	return &clusterConn{router: cr}, nil
+}
+
+// nodePool returns pool of connections to node with given address, pool is created on first use
+func (cr *clusterRouter) nodePool(addr string) *redis.Pool {
+	cr.mu.RLock()
+	pool, ok := cr.pools[addr]
+	cr.mu.RUnlock()
+	if ok {
+		return pool
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if pool, ok := cr.pools[addr]; ok {
+		return pool
+	}
+
+	pool = &redis.Pool{
+		DialContext: func(ctx context.Context) (redis.Conn, error) {
+			return redis.DialContext(ctx, "tcp", addr, cr.options...)
+		},
+		TestOnBorrow: func(conn redis.Conn, t time.Time) error {
+			if time.Since(t) < time.Minute {
+				return nil
+			}
+			_, err := conn.Do("PING")
+			return err
+		},
+		MaxIdle:     cr.cfg.MaxIdleConn,
+		MaxActive:   cr.cfg.MaxConn,
+		IdleTimeout: cr.cfg.IdleTimeout,
+		Wait:        true,
+	}
+	cr.pools[addr] = pool
+	return pool
+}
+
+// knownAddrs returns addresses of configured and discovered nodes
+func (cr *clusterRouter) knownAddrs() []string {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+
+	addrs := make([]string, 0, len(cr.cfg.ClusterAddrs)+len(cr.pools))
+	seen := map[string]bool{}
+	for _, addr := range cr.cfg.ClusterAddrs {
+		seen[addr] = true
+		addrs = append(addrs, addr)
+	}
+	for addr := range cr.pools {
+		if !seen[addr] {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// refresh loads slots from first node, which answers CLUSTER SLOTS
+func (cr *clusterRouter) refresh(ctx context.Context) error {
+	var lastErr error
+	for _, addr := range cr.knownAddrs() {
+		conn, err := cr.nodePool(addr).GetContext(ctx)
+		if err != nil {
+			lastErr = fmt.Errorf("node %s, err: %v", addr, err)
+			continue
+		}
+
+		reply, err := redis.Values(conn.Do("CLUSTER", "SLOTS"))
+		_ = conn.Close()
+		if err != nil {
+			lastErr = fmt.Errorf("node %s, err: %v", addr, err)
+			continue
+		}
+
+		slots, err := parseClusterSlots(reply, addr)
+		if err != nil {
+			lastErr = fmt.Errorf("node %s, err: %v", addr, err)
+			continue
+		}
+
+		cr.mu.Lock()
+		cr.slots = slots
+		cr.loaded = true
+		cr.mu.Unlock()
+		return nil
+	}
+
+	return fmt.Errorf("failed to load cluster slots, err: %v", lastErr)
+}
+
+// parseClusterSlots parses CLUSTER SLOTS reply, node with empty host is reachable by host of replying node
+func parseClusterSlots(reply []interface{}, replyingAddr string) ([clusterSlotsNum]string, error) {
+	var slots [clusterSlotsNum]string
+
+	replyingHost, _, err := net.SplitHostPort(replyingAddr)
+	if err != nil {
+		return slots, err
+	}
+
+	for _, rangeReply := range reply {
+		slotRange, err := redis.Values(rangeReply, nil)
+		if err != nil || len(slotRange) < 3 {
+			return slots, fmt.Errorf("unexpected slot range %v, err: %v", rangeReply, err)
+		}
+
+		start, err := redis.Int(slotRange[0], nil)
+		if err != nil {
+			return slots, err
+		}
+
+		end, err := redis.Int(slotRange[1], nil)
+		if err != nil {
+			return slots, err
+		}
+
+		master, err := redis.Values(slotRange[2], nil)
+		if err != nil || len(master) < 2 {
+			return slots, fmt.Errorf("unexpected master node %v, err: %v", slotRange[2], err)
+		}
+
+		host, err := redis.String(master[0], nil)
+		if err != nil {
+			return slots, err
+		}
+		if host == "" {
+			host = replyingHost
+		}
+
+		port, err := redis.Int(master[1], nil)
+		if err != nil {
+			return slots, err
+		}
+
+		if start < 0 || end >= clusterSlotsNum || start > end {
+			return slots, fmt.Errorf("unexpected slot range %d-%d", start, end)
+		}
+
+		addr := net.JoinHostPort(host, strconv.Itoa(port))
+		for slot := start; slot <= end; slot++ {
+			slots[slot] = addr
+		}
+	}
+	return slots, nil
+}
+
+// addrForSlot returns address of node serving slot, any configured node if slot is not served
+func (cr *clusterRouter) addrForSlot(slot int) string {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	if addr := cr.slots[slot]; addr != "" {
+		return addr
+	}
+	return cr.cfg.ClusterAddrs[0]
+}
+
+func (cr *clusterRouter) close() {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	for addr, pool := range cr.pools {
+		if err := pool.Close(); err != nil {
+			cr.logger.Error("cluster, failed to close pool of node %s, err: %v", addr, err)
+		}
+	}
+}
+
+// do performs command on node, serving command key, following MOVED and ASK redirections
+func (cr *clusterRouter) do(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	addr := cr.cfg.ClusterAddrs[0]
+	if key, ok := commandKey(cmd, args); ok {
+		addr = cr.addrForSlot(keySlot(key))
+	}
+
+	asking := false
+	for redirects := 0; redirects <= clusterMaxRedirects; redirects++ {
+		reply, err := cr.doOnNode(addr, asking, timeout, cmd, args...)
+		asking = false
+
+		redisErr, ok := err.(redis.Error)
+		if !ok {
+			return reply, err
+		}
+
+		parts := strings.Fields(redisErr.Error())
+		switch {
+		case len(parts) == 3 && parts[0] == "MOVED":
+			addr = parts[2]
+			if slot, err := strconv.Atoi(parts[1]); err == nil && slot >= 0 && slot < clusterSlotsNum {
+				cr.mu.Lock()
+				cr.slots[slot] = addr
+				cr.mu.Unlock()
+			}
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				if err := cr.refresh(ctx); err != nil {
+					cr.logger.Error("cluster, failed to refresh slots after MOVED, err: %v", err)
+				}
+			}()
+		case len(parts) == 3 && parts[0] == "ASK":
+			addr = parts[2]
+			asking = true
+		case len(parts) > 0 && (parts[0] == "TRYAGAIN" || parts[0] == "CLUSTERDOWN"):
+			time.Sleep(clusterRetryPause)
+		default:
+			return reply, err
+		}
+	}
+
+	return nil, fmt.Errorf("too many cluster redirections of %s", cmd)
+}
+
+func (cr *clusterRouter) doOnNode(addr string, asking bool, timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	conn, err := cr.nodePool(addr).GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if asking {
+		if _, err := conn.Do("ASKING"); err != nil {
+			return nil, err
+		}
+	}
+
+	if timeout > 0 {
+		return redis.DoWithTimeout(conn, timeout, cmd, args...)
+	}
+	return conn.Do(cmd, args...)
+}
+
+// clusterConn is a connection of pool in cluster mode, every command is routed by clusterRouter,
+// so connection keeps no state and pipelining is not supported
+type clusterConn struct {
+	router *clusterRouter
+}
+
+func (c *clusterConn) Close() error {
+	return nil
+}
+
+func (c *clusterConn) Err() error {
+	return nil
+}
+
+func (c *clusterConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	//empty command is used by pool to flush connection on close
+	if cmd == "" {
+		return nil, nil
+	}
+	return c.router.do(0, cmd, args...)
+}
+
+func (c *clusterConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	if cmd == "" {
+		return nil, nil
+	}
+	return c.router.do(timeout, cmd, args...)
+}
+
+func (c *clusterConn) Send(cmd string, args ...interface{}) error {
+	return ErrClusterPipeliningIsNotSupported
+}
+
+func (c *clusterConn) Flush() error {
+	return nil
+}
+
+func (c *clusterConn) Receive() (interface{}, error) {
+	return nil, ErrClusterPipeliningIsNotSupported
+}
+
+func (c *clusterConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return nil, ErrClusterPipeliningIsNotSupported
+}
+
+// commandKey returns key of command, used to choose cluster slot, ok is false for commands without keys
+func commandKey(cmd string, args []interface{}) (key string, ok bool) {
+	switch strings.ToUpper(cmd) {
+	case "PING", "ECHO", "INFO", "ROLE", "AUTH", "SELECT", "SCRIPT", "CLUSTER", "ASKING":
+		return "", false
+	case "EVAL", "EVALSHA":
+		if len(args) < 3 {
+			return "", false
+		}
+		if numKeys, err := strconv.Atoi(argToString(args[1])); err != nil || numKeys < 1 {
+			return "", false
+		}
+		return argToString(args[2]), true
+	default:
+		if len(args) == 0 {
+			return "", false
+		}
+		return argToString(args[0]), true
+	}
+}
+
+func argToString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// keySlot returns cluster slot of key, only hash tag in braces is hashed, if key has non-empty one
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % clusterSlotsNum
+}
+
+// crc16 is a CRC16-CCITT (XMODEM) checksum, used by redis cluster
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -2,21 +2,123 @@ package cache
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"github.com/gomodule/redigo/redis"
+	"io/ioutil"
 	"job-backend-trainee-assignment/internal/logger"
 	"time"
 )
 
+//Redis deployment modes
+const (
+	ModeSingle   = "single"
+	ModeSentinel = "sentinel"
+	ModeCluster  = "cluster"
+)
+
 type ConnConfig struct {
-	Host          string
-	Port          string
-	DBName        int
+	//Mode is one of ModeSingle, ModeSentinel or ModeCluster, single node mode is used if it is empty
+	Mode   string
+	Host   string
+	Port   string
+	DBName int
+	//Username is ACL username, default user is used if it is empty
+	Username      string
 	Pass          string
 	MaxConn       int
 	MaxIdleConn   int
 	IdleTimeout   time.Duration
 	RetryInterval time.Duration
+
+	//UseTLS enables TLS connections to redis nodes and sentinels
+	UseTLS        bool
+	TLSSkipVerify bool
+	//TLSCAFile is a path to pem file with CA certificates, system pool is used if it is empty
+	TLSCAFile string
+
+	//SentinelAddrs are host:port addresses of sentinels
+	SentinelAddrs      []string
+	SentinelMasterName string
+	SentinelUsername   string
+	SentinelPass       string
+	//SentinelCheckInterval is a period of master address check, connections to former master are closed on failover
+	SentinelCheckInterval time.Duration
+
+	//ClusterAddrs are host:port addresses of cluster nodes, used to discover cluster slots
+	ClusterAddrs []string
+}
+
+var defaultSentinelCheckInterval = time.Second
+
+//Validate checks that params of chosen mode are filled
+func (cfg *ConnConfig) Validate() error {
+	if cfg.Pass == "" {
+		return fmt.Errorf("config param  %s is not filled or have default value", "Pass")
+	}
+
+	switch cfg.Mode {
+	case "", ModeSingle:
+		if cfg.Host == "" || cfg.Port == "" {
+			return fmt.Errorf("config param %s or %s is not filled or have default value", "Host", "Port")
+		}
+	case ModeSentinel:
+		if len(cfg.SentinelAddrs) == 0 || cfg.SentinelMasterName == "" {
+			return fmt.Errorf("config param %s or %s is not filled or have default value", "SentinelAddrs", "SentinelMasterName")
+		}
+		if cfg.SentinelCheckInterval < 0 {
+			return fmt.Errorf("config param %s must be non-negative", "SentinelCheckInterval")
+		}
+	case ModeCluster:
+		if len(cfg.ClusterAddrs) == 0 {
+			return fmt.Errorf("config param %s is not filled or have default value", "ClusterAddrs")
+		}
+		if cfg.DBName != 0 {
+			return fmt.Errorf("redis cluster supports only db 0, got db %d", cfg.DBName)
+		}
+	default:
+		return fmt.Errorf("unknown redis mode %q, must be one of %s, %s, %s", cfg.Mode, ModeSingle, ModeSentinel, ModeCluster)
+	}
+	return nil
+}
+
+//tlsConfig returns tls config of connections, nil if default config of redigo is enough
+func (cfg *ConnConfig) tlsConfig() (*tls.Config, error) {
+	if !cfg.UseTLS || cfg.TLSCAFile == "" {
+		return nil, nil
+	}
+
+	pem, err := ioutil.ReadFile(cfg.TLSCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tls ca file, err: %v", err)
+	}
+
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("tls ca file %s does not contain pem certificates", cfg.TLSCAFile)
+	}
+
+	return &tls.Config{RootCAs: certPool, InsecureSkipVerify: cfg.TLSSkipVerify}, nil
+}
+
+//dialOptions returns options of connections to redis nodes, auth and db selection are done on dial
+func (cfg *ConnConfig) dialOptions(tlsConfig *tls.Config, withDB bool) []redis.DialOption {
+	options := []redis.DialOption{
+		redis.DialUsername(cfg.Username),
+		redis.DialPassword(cfg.Pass),
+		redis.DialUseTLS(cfg.UseTLS),
+		redis.DialTLSSkipVerify(cfg.TLSSkipVerify),
+	}
+
+	if tlsConfig != nil {
+		options = append(options, redis.DialTLSConfig(tlsConfig))
+	}
+
+	if withDB {
+		options = append(options, redis.DialDatabase(cfg.DBName))
+	}
+	return options
 }
 
 func ConnectToRedisWithTimeout(ctx context.Context, log logger.ILogger, cfg *ConnConfig) (redisPool *redis.Pool, closeFunc func(), err error) {
@@ -29,55 +131,20 @@ func ConnectToRedisWithTimeout(ctx context.Context, log logger.ILogger, cfg *Con
 		return nil, nil, fmt.Errorf("provided config param is nil")
 	}
 
-	if cfg.Pass == "" {
-		log.Error("provided config param Pass is not filled")
-		return nil, nil, fmt.Errorf("config param  %s is not filled or have default value", "Pass")
-	}
-	if cfg.Host == "" || cfg.Port == "" {
-		log.Error("provided config params Host or Port is not filled")
-		return nil, nil, fmt.Errorf("config param %s or %s is not filled or have default value", "Host", "Port")
+	if err := cfg.Validate(); err != nil {
+		log.Error("provided config is not valid, err: %v", err)
+		return nil, nil, err
 	}
 
-	connWaitChan := make(chan struct{})
-
-	dialContextFunc := func(host string, port string,
-		pass string, DBName int) func(ctx context.Context) (redis.Conn, error) {
-
-		return func(ctxDial context.Context) (redis.Conn, error) {
-			conn, err := redis.DialContext(ctxDial, "tcp", fmt.Sprintf("%s:%s", host, port))
-			if err != nil {
-				log.Error("DialContext error, err: %v", err)
-				return nil, err
-			}
-
-			_, err = conn.Do("AUTH", pass)
-			if err != nil {
-				closeErr := conn.Close()
-				if closeErr != nil {
-					log.Error("AUTH error, err: %v", err)
-				}
-				return nil, err
-			}
-
-			_, err = conn.Do("SELECT", DBName)
-			if err != nil {
-				closeErr := conn.Close()
-				if closeErr != nil {
-					log.Error("SELECT redis db error, err: %v", err)
-				}
-				return nil, err
-			}
-			return conn, nil
-		}
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		log.Error("failed to create tls config, err: %v", err)
+		return nil, nil, err
 	}
 
-	redisPassword := cfg.Pass
-	redisHost := cfg.Host
-	redisPort := cfg.Port
-	redisDBName := cfg.DBName
+	connWaitChan := make(chan struct{})
 
 	pool := &redis.Pool{
-		DialContext: dialContextFunc(redisHost, redisPort, redisPassword, redisDBName),
 		TestOnBorrow: func(conn redis.Conn, t time.Time) error {
 			if time.Since(t) < time.Minute {
 				return nil
@@ -91,6 +158,32 @@ func ConnectToRedisWithTimeout(ctx context.Context, log logger.ILogger, cfg *Con
 		Wait:        true,
 	}
 
+	//stopFunc stops background work of sentinel and cluster modes
+	stopFunc := func() {}
+
+	switch cfg.Mode {
+	case ModeSentinel:
+		sentinel := newSentinelDialer(log, cfg, tlsConfig)
+		pool.DialContext = sentinel.dial
+		pool.TestOnBorrow = sentinel.testOnBorrow(pool.TestOnBorrow)
+		stopFunc = sentinel.watch()
+	case ModeCluster:
+		cluster := newClusterRouter(log, cfg, tlsConfig)
+		pool.DialContext = cluster.dial
+		stopFunc = cluster.close
+	default:
+		addr := fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)
+		options := cfg.dialOptions(tlsConfig, true)
+		pool.DialContext = func(ctxDial context.Context) (redis.Conn, error) {
+			conn, err := redis.DialContext(ctxDial, "tcp", addr, options...)
+			if err != nil {
+				log.Error("DialContext error, err: %v", err)
+				return nil, err
+			}
+			return conn, nil
+		}
+	}
+
 	go func() {
 		for {
 			conn, err := pool.GetContext(ctx)
@@ -131,12 +224,14 @@ func ConnectToRedisWithTimeout(ctx context.Context, log logger.ILogger, cfg *Con
 	case <-ctx.Done():
 		{
 			log.Error("ConnectToRedisWithTimeout, connection timeout exceeded")
+			stopFunc()
 			return nil, nil, fmt.Errorf("db connection context timeout")
 		}
 	case <-connWaitChan:
 		{
 			log.Info("ConnectToRedisWithTimeout, connection established")
 			return pool, func() {
+				stopFunc()
 				err = pool.Close()
 				if err != nil {
 					log.Info("ConnectToRedisWithTimeout, on pool close error,err: %s", err.Error())
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCache_KeySlot(t *testing.T) {
+	t.Log("TestCache_KeySlot")
+
+	assert.Equal(t, uint16(0x31C3), crc16("123456789"), "crc16 of check string")
+	assert.Equal(t, 12182, keySlot("foo"))
+	assert.Equal(t, 5061, keySlot("bar"))
+
+	assert.Equal(t, keySlot("user1000"), keySlot("{user1000}.following"), "only hash tag must be hashed")
+	assert.Equal(t, keySlot("{user1000}.following"), keySlot("{user1000}.followers"), "keys with same hash tag")
+	assert.Equal(t, keySlot("{}foo"), keySlot("{}foo"))
+	assert.NotEqual(t, keySlot("foo"), keySlot("{}foo"), "empty hash tag must be ignored")
+}
+
+func TestCache_CommandKey(t *testing.T) {
+	t.Log("TestCache_CommandKey")
+
+	cases := []struct {
+		CaseName    string
+		Cmd         string
+		Args        []interface{}
+		ExpectedKey string
+		ExpectedOk  bool
+	}{
+		{CaseName: "get", Cmd: "GET", Args: []interface{}{"balance:1"}, ExpectedKey: "balance:1", ExpectedOk: true},
+		{CaseName: "setex", Cmd: "setex", Args: []interface{}{[]byte("key"), 10, "value"}, ExpectedKey: "key", ExpectedOk: true},
+		{CaseName: "eval with key", Cmd: "EVAL", Args: []interface{}{"script", 1, "idempotency:token", 60}, ExpectedKey: "idempotency:token", ExpectedOk: true},
+		{CaseName: "eval without keys", Cmd: "EVAL", Args: []interface{}{"script", 0}, ExpectedOk: false},
+		{CaseName: "ping", Cmd: "PING", ExpectedOk: false},
+		{CaseName: "no args", Cmd: "DBSIZE", ExpectedOk: false},
+	}
+
+	for _, c := range cases {
+		key, ok := commandKey(c.Cmd, c.Args)
+		assert.Equal(t, c.ExpectedOk, ok, c.CaseName)
+		assert.Equal(t, c.ExpectedKey, key, c.CaseName)
+	}
+}
+
+func TestCache_ParseClusterSlots(t *testing.T) {
+	t.Log("TestCache_ParseClusterSlots")
+
+	reply := []interface{}{
+		[]interface{}{int64(0), int64(8191), []interface{}{[]byte("10.0.0.1"), int64(7000), []byte("id1")}},
+		[]interface{}{int64(8192), int64(16383), []interface{}{[]byte(""), int64(7001), []byte("id2")},
+			[]interface{}{[]byte("10.0.0.3"), int64(7002), []byte("id3")}},
+	}
+
+	slots, err := parseClusterSlots(reply, "10.0.0.2:7001")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:7000", slots[0])
+	assert.Equal(t, "10.0.0.1:7000", slots[8191])
+	assert.Equal(t, "10.0.0.2:7001", slots[8192], "empty host must be replaced by host of replying node")
+	assert.Equal(t, "10.0.0.2:7001", slots[16383])
+
+	_, err = parseClusterSlots([]interface{}{[]interface{}{int64(0), int64(16384),
+		[]interface{}{[]byte("10.0.0.1"), int64(7000)}}}, "10.0.0.1:7000")
+	assert.Error(t, err, "slot out of range")
+}
+
+func TestCache_ConnConfigValidate(t *testing.T) {
+	t.Log("TestCache_ConnConfigValidate")
+
+	cases := []struct {
+		CaseName  string
+		Config    ConnConfig
+		ExpectErr bool
+	}{
+		{CaseName: "single", Config: ConnConfig{Host: "localhost", Port: "6379", Pass: "1234"}},
+		{CaseName: "single without host", Config: ConnConfig{Mode: ModeSingle, Port: "6379", Pass: "1234"}, ExpectErr: true},
+		{CaseName: "without pass", Config: ConnConfig{Host: "localhost", Port: "6379"}, ExpectErr: true},
+		{CaseName: "sentinel", Config: ConnConfig{Mode: ModeSentinel, Pass: "1234",
+			SentinelAddrs: []string{"localhost:26379"}, SentinelMasterName: "mymaster"}},
+		{CaseName: "sentinel without master name", Config: ConnConfig{Mode: ModeSentinel, Pass: "1234",
+			SentinelAddrs: []string{"localhost:26379"}}, ExpectErr: true},
+		{CaseName: "cluster", Config: ConnConfig{Mode: ModeCluster, Pass: "1234", ClusterAddrs: []string{"localhost:7000"}}},
+		{CaseName: "cluster with db", Config: ConnConfig{Mode: ModeCluster, Pass: "1234", DBName: 1,
+			ClusterAddrs: []string{"localhost:7000"}}, ExpectErr: true},
+		{CaseName: "unknown mode", Config: ConnConfig{Mode: "replica", Pass: "1234"}, ExpectErr: true},
+	}
+
+	for _, c := range cases {
+		err := c.Config.Validate()
+		if c.ExpectErr {
+			assert.Error(t, err, c.CaseName)
+		} else {
+			assert.NoError(t, err, c.CaseName)
+		}
+	}
+}
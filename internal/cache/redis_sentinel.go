@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"job-backend-trainee-assignment/internal/logger"
+	"net"
+	"sync"
+	"time"
+)
+
+// sentinelDialer dials current master, discovered via sentinels. Master address is checked periodically,
+// pooled connections to former master are closed on borrow after failover
+type sentinelDialer struct {
+	logger        logger.ILogger
+	cfg           *ConnConfig
+	nodeOptions   []redis.DialOption
+	sentinelOpts  []redis.DialOption
+	checkInterval time.Duration
+	masterAddr    string
+	mu            sync.Mutex
+}
+
+// addrConn is a connection, which knows address of redis node it is connected to
+type addrConn struct {
+	redis.Conn
+	addr string
+}
+
+func (c *addrConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	return redis.DoWithTimeout(c.Conn, timeout, cmd, args...)
+}
+
+func (c *addrConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return redis.ReceiveWithTimeout(c.Conn, timeout)
+}
+
+func newSentinelDialer(log logger.ILogger, cfg *ConnConfig, tlsConfig *tls.Config) *sentinelDialer {
+	sentinelOpts := []redis.DialOption{
+		redis.DialUsername(cfg.SentinelUsername),
+		redis.DialPassword(cfg.SentinelPass),
+		redis.DialUseTLS(cfg.UseTLS),
+		redis.DialTLSSkipVerify(cfg.TLSSkipVerify),
+	}
+	if tlsConfig != nil {
+		sentinelOpts = append(sentinelOpts, redis.DialTLSConfig(tlsConfig))
+	}
+
+	checkInterval := cfg.SentinelCheckInterval
+	if checkInterval == 0 {
+		checkInterval = defaultSentinelCheckInterval
+	}
+
+	return &sentinelDialer{
+		logger:        log,
+		cfg:           cfg,
+		nodeOptions:   cfg.dialOptions(tlsConfig, true),
+		sentinelOpts:  sentinelOpts,
+		checkInterval: checkInterval,
+		mu:            sync.Mutex{},
+	}
+}
+
+// discoverMaster asks sentinels for master address, first sentinel, which knows master, is used
+func (sd *sentinelDialer) discoverMaster(ctx context.Context) (string, error) {
+	var lastErr error
+	for _, sentinelAddr := range sd.cfg.SentinelAddrs {
+		conn, err := redis.DialContext(ctx, "tcp", sentinelAddr, sd.sentinelOpts...)
+		if err != nil {
+			lastErr = fmt.Errorf("sentinel %s, err: %v", sentinelAddr, err)
+			continue
+		}
+
+		hostPort, err := redis.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", sd.cfg.SentinelMasterName))
+		_ = conn.Close()
+		if err != nil {
+			lastErr = fmt.Errorf("sentinel %s, err: %v", sentinelAddr, err)
+			continue
+		}
+
+		if len(hostPort) != 2 {
+			lastErr = fmt.Errorf("sentinel %s does not know master %s", sentinelAddr, sd.cfg.SentinelMasterName)
+			continue
+		}
+
+		return net.JoinHostPort(hostPort[0], hostPort[1]), nil
+	}
+
+	return "", fmt.Errorf("failed to discover master %s, err: %v", sd.cfg.SentinelMasterName, lastErr)
+}
+
+func (sd *sentinelDialer) dial(ctx context.Context) (redis.Conn, error) {
+	masterAddr, err := sd.discoverMaster(ctx)
+	if err != nil {
+		sd.logger.Error("DialContext error, err: %v", err)
+		return nil, err
+	}
+
+	conn, err := redis.DialContext(ctx, "tcp", masterAddr, sd.nodeOptions...)
+	if err != nil {
+		sd.logger.Error("DialContext error, err: %v", err)
+		return nil, err
+	}
+
+	//sentinels may report former master for a while after failover
+	role, err := redis.Values(conn.Do("ROLE"))
+	if err != nil || len(role) == 0 {
+		_ = conn.Close()
+		sd.logger.Error("DialContext, failed to check role of %s, err: %v", masterAddr, err)
+		return nil, fmt.Errorf("failed to check role of %s, err: %v", masterAddr, err)
+	}
+
+	if roleName, _ := redis.String(role[0], nil); roleName != "master" {
+		_ = conn.Close()
+		sd.logger.Error("DialContext, %s is %s, not master", masterAddr, roleName)
+		return nil, fmt.Errorf("%s is %s, not master", masterAddr, roleName)
+	}
+
+	sd.setMasterAddr(masterAddr)
+	return &addrConn{Conn: conn, addr: masterAddr}, nil
+}
+
+func (sd *sentinelDialer) setMasterAddr(addr string) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	if sd.masterAddr != "" && sd.masterAddr != addr {
+		sd.logger.Info("sentinel, master %s changed from %s to %s", sd.cfg.SentinelMasterName, sd.masterAddr, addr)
+	}
+	sd.masterAddr = addr
+}
+
+func (sd *sentinelDialer) getMasterAddr() string {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	return sd.masterAddr
+}
+
+// testOnBorrow rejects connections to former master, then performs given test
+func (sd *sentinelDialer) testOnBorrow(next func(conn redis.Conn, t time.Time) error) func(conn redis.Conn, t time.Time) error {
+	return func(conn redis.Conn, t time.Time) error {
+		if c, ok := conn.(*addrConn); ok {
+			if masterAddr := sd.getMasterAddr(); masterAddr != "" && c.addr != masterAddr {
+				return fmt.Errorf("connection to %s is closed, current master is %s", c.addr, masterAddr)
+			}
+		}
+		return next(conn, t)
+	}
+}
+
+// watch checks master address periodically until returned stop func is called
+func (sd *sentinelDialer) watch() (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	doneCh := make(chan struct{})
+
+	go func() {
+		defer close(doneCh)
+		ticker := time.NewTicker(sd.checkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			checkCtx, checkCancel := context.WithTimeout(ctx, sd.checkInterval)
+			masterAddr, err := sd.discoverMaster(checkCtx)
+			checkCancel()
+			if err != nil {
+				sd.logger.Error("sentinel, master check failed, err: %v", err)
+				continue
+			}
+			sd.setMasterAddr(masterAddr)
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-doneCh
+	}
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"sync"
 )
 
 // DummyCacheCommon is a struct implementing ICacher interface, made for tests, returns false, nil error
@@ -17,6 +18,26 @@ func (c *DummyCacheCommon) AddKey(ctx context.Context, key string) (err error) {
 	return nil
 }
 
+//GetValue stores nothing, always returns nil error
+func (c *DummyCacheCommon) GetValue(ctx context.Context, key string) (value string, found bool, err error) {
+	return "", false, nil
+}
+
+//SetValue stores nothing, always returns nil error
+func (c *DummyCacheCommon) SetValue(ctx context.Context, key string, value string) (err error) {
+	return nil
+}
+
+//GetVersion stores nothing, always returns nil error
+func (c *DummyCacheCommon) GetVersion(ctx context.Context, key string) (version int64, err error) {
+	return 0, nil
+}
+
+//IncrVersion stores nothing, always returns nil error
+func (c *DummyCacheCommon) IncrVersion(ctx context.Context, key string) (version int64, err error) {
+	return 0, nil
+}
+
 //StubCacheFaulty is a struct implementing ICacher interface,made for tests, its method always returns an error
 type StubCacheFaulty struct {
 }
@@ -31,6 +52,26 @@ func (c *StubCacheFaulty) AddKey(ctx context.Context, key string) (err error) {
 	return ErrFailedToGetConnFromPool
 }
 
+//GetValue does nothing, always returns error
+func (c *StubCacheFaulty) GetValue(ctx context.Context, key string) (value string, found bool, err error) {
+	return "", false, ErrFailedToGetConnFromPool
+}
+
+//SetValue does nothing, always returns error
+func (c *StubCacheFaulty) SetValue(ctx context.Context, key string, value string) (err error) {
+	return ErrFailedToGetConnFromPool
+}
+
+//GetVersion does nothing, always returns error
+func (c *StubCacheFaulty) GetVersion(ctx context.Context, key string) (version int64, err error) {
+	return 0, ErrFailedToGetConnFromPool
+}
+
+//IncrVersion does nothing, always returns error
+func (c *StubCacheFaulty) IncrVersion(ctx context.Context, key string) (version int64, err error) {
+	return 0, ErrFailedToGetConnFromPool
+}
+
 // DummyCacheWithAnyKeyExists is a struct implementing ICacher interface, made for tests
 type DummyCacheWithAnyKeyExists struct {
 }
@@ -44,6 +85,26 @@ func (c *DummyCacheWithAnyKeyExists) AddKey(ctx context.Context, key string) (er
 	return nil
 }
 
+//GetValue stores nothing, always returns nil error
+func (c *DummyCacheWithAnyKeyExists) GetValue(ctx context.Context, key string) (value string, found bool, err error) {
+	return "", false, nil
+}
+
+//SetValue stores nothing, always returns nil error
+func (c *DummyCacheWithAnyKeyExists) SetValue(ctx context.Context, key string, value string) (err error) {
+	return nil
+}
+
+//GetVersion stores nothing, always returns nil error
+func (c *DummyCacheWithAnyKeyExists) GetVersion(ctx context.Context, key string) (version int64, err error) {
+	return 0, nil
+}
+
+//IncrVersion stores nothing, always returns nil error
+func (c *DummyCacheWithAnyKeyExists) IncrVersion(ctx context.Context, key string) (version int64, err error) {
+	return 0, nil
+}
+
 // DummyCacheWithNoKeyExists is a struct implementing ICacher interface, made for tests
 type DummyCacheWithNoKeyExists struct {
 }
@@ -56,3 +117,79 @@ func (c *DummyCacheWithNoKeyExists) CheckKeyExistence(ctx context.Context, key s
 func (c *DummyCacheWithNoKeyExists) AddKey(ctx context.Context, key string) (err error) {
 	return nil
 }
+
+//GetValue stores nothing, always returns nil error
+func (c *DummyCacheWithNoKeyExists) GetValue(ctx context.Context, key string) (value string, found bool, err error) {
+	return "", false, nil
+}
+
+//SetValue stores nothing, always returns nil error
+func (c *DummyCacheWithNoKeyExists) SetValue(ctx context.Context, key string, value string) (err error) {
+	return nil
+}
+
+//GetVersion stores nothing, always returns nil error
+func (c *DummyCacheWithNoKeyExists) GetVersion(ctx context.Context, key string) (version int64, err error) {
+	return 0, nil
+}
+
+//IncrVersion stores nothing, always returns nil error
+func (c *DummyCacheWithNoKeyExists) IncrVersion(ctx context.Context, key string) (version int64, err error) {
+	return 0, nil
+}
+
+//StubCacheInMemory is a struct implementing ICacher interface, made for tests, keeps keys in memory without expiration
+type StubCacheInMemory struct {
+	values   map[string]string
+	versions map[string]int64
+	mu       sync.Mutex
+}
+
+func NewStubCacheInMemory() *StubCacheInMemory {
+	return &StubCacheInMemory{
+		values:   map[string]string{},
+		versions: map[string]int64{},
+		mu:       sync.Mutex{},
+	}
+}
+
+func (c *StubCacheInMemory) CheckKeyExistence(ctx context.Context, key string) (keyExists bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, keyExists = c.values[key]
+	return keyExists, nil
+}
+
+func (c *StubCacheInMemory) AddKey(ctx context.Context, key string) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = "1"
+	return nil
+}
+
+func (c *StubCacheInMemory) GetValue(ctx context.Context, key string) (value string, found bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, found = c.values[key]
+	return value, found, nil
+}
+
+func (c *StubCacheInMemory) SetValue(ctx context.Context, key string, value string) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+	return nil
+}
+
+func (c *StubCacheInMemory) GetVersion(ctx context.Context, key string) (version int64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.versions[key], nil
+}
+
+func (c *StubCacheInMemory) IncrVersion(ctx context.Context, key string) (version int64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.versions[key]++
+	return c.versions[key], nil
+}
@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"time"
+)
+
+type doResult struct {
+	reply interface{}
+	err   error
+}
+
+// do performs redis command with given timeout, connection is returned to pool after command is done
+func (c *RedisCache) do(ctx context.Context, timeout time.Duration, methodName string, cmd string, args ...interface{}) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := c.redis.GetContext(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			c.logger.Error("%s, failed to Get Conn from pool, err: %v", methodName, err)
+			return nil, fmt.Errorf("failed to get conn from pool, context err: %w", ErrContextDeadlineExceeded)
+		}
+
+		c.logger.Error("%s, failed to Get Conn from pool, err: %v", methodName, err)
+		return nil, fmt.Errorf("failed to get conn from pool, err: %w, err: %v", ErrFailedToGetConnFromPool, err)
+	}
+
+	resCh := make(chan doResult, 1)
+	go func() {
+		reply, locErr := conn.Do(cmd, args...)
+		errOnClose := conn.Close()
+		if errOnClose != nil {
+			c.logger.Error("%s, failed to Close conn, err: %v", methodName, errOnClose)
+		}
+		resCh <- doResult{reply: reply, err: locErr}
+	}()
+
+	select {
+	case <-ctx.Done():
+		{
+			c.logger.Error("%s, failed to perform %s, context timeout, err: %v", methodName, cmd, ctx.Err())
+			return nil, fmt.Errorf("%s context timeout, err: %w", cmd, ErrContextDeadlineExceeded)
+		}
+	case res := <-resCh:
+		{
+			if res.err != nil {
+				c.logger.Error("%s, failed to perform %s, err: %v", methodName, cmd, res.err)
+				return nil, fmt.Errorf("failed to perform %s, err:%v, err:%w", cmd, res.err, ErrFailedToPerformDoCommand)
+			}
+			return res.reply, nil
+		}
+	}
+}
+
+// GetValue returns value, stored with specified key, found is false if key does not exist
+func (c *RedisCache) GetValue(ctx context.Context, key string) (value string, found bool, err error) {
+	c.mu.Lock()
+	keyLookupTimeout := c.cfg.KeyLookupTimeout
+	c.mu.Unlock()
+
+	reply, err := c.do(ctx, keyLookupTimeout, "GetValue", "GET", key)
+	if err != nil {
+		return "", false, err
+	}
+
+	value, err = redis.String(reply, nil)
+	if err == redis.ErrNil {
+		return "", false, nil
+	}
+	if err != nil {
+		c.logger.Error("GetValue, failed to parse redis response, err: %v", err)
+		return "", false, fmt.Errorf("failed to parse value, err:%v, err:%w", err, ErrFailedToPerformDoCommand)
+	}
+
+	return value, true, nil
+}
+
+// SetValue stores value with specified key, the key expires after key expiration time
+func (c *RedisCache) SetValue(ctx context.Context, key string, value string) error {
+	c.mu.Lock()
+	keyExpirationTime := c.cfg.KeyExpirationTime
+	keySetTimeout := c.cfg.KeySetTimeout
+	c.mu.Unlock()
+
+	reply, err := c.do(ctx, keySetTimeout, "SetValue", "SETEX", key, int64(keyExpirationTime.Seconds()), value)
+	if err != nil {
+		return err
+	}
+
+	okResp, err := redis.String(reply, nil)
+	if err != nil || okResp != "OK" {
+		c.logger.Error("SetValue, failed to parse redis response, resp: %s, err: %v", okResp, err)
+		return fmt.Errorf("failed to set value, err:%v, err:%w", err, ErrFailedToPerformDoCommand)
+	}
+
+	return nil
+}
+
+// GetVersion returns version counter, stored with specified key, not existing counter has zero version
+func (c *RedisCache) GetVersion(ctx context.Context, key string) (int64, error) {
+	c.mu.Lock()
+	keyLookupTimeout := c.cfg.KeyLookupTimeout
+	c.mu.Unlock()
+
+	reply, err := c.do(ctx, keyLookupTimeout, "GetVersion", "GET", key)
+	if err != nil {
+		return 0, err
+	}
+
+	version, err := redis.Int64(reply, nil)
+	if err == redis.ErrNil {
+		return 0, nil
+	}
+	if err != nil {
+		c.logger.Error("GetVersion, failed to parse redis response, err: %v", err)
+		return 0, fmt.Errorf("failed to parse version, err:%v, err:%w", err, ErrFailedToPerformDoCommand)
+	}
+
+	return version, nil
+}
+
+// IncrVersion increments version counter, stored with specified key, and returns new version.
+// Version counters do not expire, so values stored with previous versions are never used again
+func (c *RedisCache) IncrVersion(ctx context.Context, key string) (int64, error) {
+	c.mu.Lock()
+	keySetTimeout := c.cfg.KeySetTimeout
+	c.mu.Unlock()
+
+	reply, err := c.do(ctx, keySetTimeout, "IncrVersion", "INCR", key)
+	if err != nil {
+		return 0, err
+	}
+
+	version, err := redis.Int64(reply, nil)
+	if err != nil {
+		c.logger.Error("IncrVersion, failed to parse redis response, err: %v", err)
+		return 0, fmt.Errorf("failed to parse version, err:%v, err:%w", err, ErrFailedToPerformDoCommand)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/reconciliation"
+)
+
+var (
+	ErrUnknownCommand      = errors.New("unknown command")
+	ErrBadCommandArgs      = errors.New("invalid command arguments")
+	ErrUnknownOutputFormat = errors.New("output format must be either \"table\" or \"json\"")
+)
+
+// Usage describes admin commands of bill_service binary
+const Usage = `usage: bill_service [-config name] <command> [flags]
+
+commands:
+  serve                                 start http server and background jobs (default)
+  user show --id ID                     show user name, balance and creation date
+  user adjust --id ID --amount AMOUNT --reason REASON [--token TOKEN]
+                                        credit (positive amount) or withdraw (negative amount) user account
+  operations list --id ID [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--page N] [--limit N]
+                  [--order-field date|amount] [--order-direction desc|asc]
+                                        list user operations
+  reconcile                             compare user balances with sums of their operations
+  idempotency lookup TOKEN              show operations, performed with idempotency token
+
+all commands, except serve, accept --output table|json flag
+
+global flags:
+`
+
+type IReconciler interface {
+	Reconcile(ctx context.Context) (*reconciliation.Report, error)
+}
+
+// CLI runs admin commands with billing app and prints results to out
+type CLI struct {
+	app        app.IBillingApp
+	reconciler IReconciler
+	out        io.Writer
+}
+
+func NewCLI(billingApp app.IBillingApp, reconciler IReconciler, out io.Writer) (*CLI, error) {
+	if billingApp == nil {
+		return nil, fmt.Errorf("must provide non-nil billing app instance")
+	}
+
+	if reconciler == nil {
+		return nil, fmt.Errorf("must provide non-nil reconciler instance")
+	}
+
+	if out == nil {
+		return nil, fmt.Errorf("must provide non-nil output writer")
+	}
+
+	return &CLI{
+		app:        billingApp,
+		reconciler: reconciler,
+		out:        out,
+	}, nil
+}
+
+// Run runs command, given by args without binary name, e.g. ["user", "show", "--id", "1"]
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("command is not set, err: %w", ErrBadCommandArgs)
+	}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "user", "operations", "idempotency":
+		if len(args) == 0 {
+			return fmt.Errorf("subcommand of %s is not set, err: %w", command, ErrBadCommandArgs)
+		}
+		command, args = command+" "+args[0], args[1:]
+	}
+
+	switch command {
+	case "user show":
+		return c.userShow(ctx, args)
+	case "user adjust":
+		return c.userAdjust(ctx, args)
+	case "operations list":
+		return c.operationsList(ctx, args)
+	case "reconcile":
+		return c.reconcile(ctx, args)
+	case "idempotency lookup":
+		return c.idempotencyLookup(ctx, args)
+	}
+
+	return fmt.Errorf("%s, err: %w", command, ErrUnknownCommand)
+}
+
+// newFlagSet returns flag set of command with common --output flag,
+// parse errors are returned instead of printing usage and exiting
+func newFlagSet(command string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	output := fs.String("output", outputTable, "output format: table or json")
+	return fs, output
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil {
+		return fmt.Errorf("%s: %s, err: %w", fs.Name(), err.Error(), ErrBadCommandArgs)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/reconciliation"
+	"testing"
+)
+
+type StubReconciler struct {
+}
+
+func (sr *StubReconciler) Reconcile(ctx context.Context) (*reconciliation.Report, error) {
+	return &reconciliation.Report{
+		UsersChecked: 2,
+		Discrepancies: []reconciliation.Discrepancy{{
+			UserId:        2,
+			Balance:       decimal.NewFromInt(15),
+			OperationsSum: decimal.NewFromInt(10),
+			Delta:         decimal.NewFromInt(5),
+		}},
+	}, nil
+}
+
+//StubAdjustApp records adjustment requests
+type StubAdjustApp struct {
+	app.StubBillingAppCommon
+	credits   []*app.CreditAccountRequest
+	withdraws []*app.WithdrawAccountRequest
+}
+
+func (sa *StubAdjustApp) CreditUserAccount(ctx context.Context, in *app.CreditAccountRequest) (*app.ResultState, error) {
+	sa.credits = append(sa.credits, in)
+	return sa.StubBillingAppCommon.CreditUserAccount(ctx, in)
+}
+
+func (sa *StubAdjustApp) WithdrawUserAccount(ctx context.Context, in *app.WithdrawAccountRequest) (*app.ResultState, error) {
+	sa.withdraws = append(sa.withdraws, in)
+	return sa.StubBillingAppCommon.WithdrawUserAccount(ctx, in)
+}
+
+func TestCLI_Run(t *testing.T) {
+	testCases := []struct {
+		CaseName string
+		Args     []string
+		Out      string
+		Err      error
+	}{
+		{
+			CaseName: "positive path, user show, table",
+			Args:     []string{"user", "show", "--id", "2"},
+			Out: "USER_ID  NAME       BALANCE  CURRENCY  CREATED_AT\n" +
+				"2        Mr. Jones  10       RUB       2020-08-11T07:23:58Z\n",
+		},
+		{
+			CaseName: "positive path, user show, json",
+			Args:     []string{"user", "show", "--id", "2", "--output", "json"},
+			Out: "{\n  \"user_id\": 2,\n  \"name\": \"Mr. Jones\",\n  \"balance\": \"10\",\n" +
+				"  \"currency\": \"RUB\",\n  \"created_at\": \"2020-08-11T10:23:58+03:00\"\n}\n",
+		},
+		{
+			CaseName: "negative path, user show, user does not exist",
+			Args:     []string{"user", "show", "--id", "100500"},
+			Err:      app.ErrUserDoesNotExist,
+		},
+		{
+			CaseName: "negative path, user show, id is not set",
+			Args:     []string{"user", "show"},
+			Err:      ErrBadCommandArgs,
+		},
+		{
+			CaseName: "negative path, user show, unknown output format",
+			Args:     []string{"user", "show", "--id", "2", "--output", "xml"},
+			Err:      ErrUnknownOutputFormat,
+		},
+		{
+			CaseName: "positive path, user adjust, withdraw",
+			Args:     []string{"user", "adjust", "--id", "2", "--amount", "-5", "--reason", "refund", "--token", "t1"},
+			Out: "USER_ID  AMOUNT  REASON  IDEMPOTENCY_TOKEN  STATE\n" +
+				"2        -5      refund  t1                 Account withdraw Done\n",
+		},
+		{
+			CaseName: "negative path, user adjust, reason is not set",
+			Args:     []string{"user", "adjust", "--id", "2", "--amount", "5"},
+			Err:      ErrBadCommandArgs,
+		},
+		{
+			CaseName: "negative path, user adjust, bad amount",
+			Args:     []string{"user", "adjust", "--id", "2", "--amount", "five", "--reason", "bonus"},
+			Err:      ErrBadCommandArgs,
+		},
+		{
+			CaseName: "negative path, user adjust, not enough money",
+			Args:     []string{"user", "adjust", "--id", "1", "--amount", "-5", "--reason", "fee"},
+			Err:      app.ErrUserDoesNotHaveEnoughMoney,
+		},
+		{
+			CaseName: "positive path, operations list, table",
+			Args:     []string{"operations", "list", "--id", "1"},
+			Out: "OPERATION_ID  USER_ID  DATE                  AMOUNT  PURPOSE                IDEMPOTENCY_TOKEN\n" +
+				"1             1        2020-08-11T07:23:58Z  10      incoming payment       \n" +
+				"3             1        2020-08-11T07:23:58Z  -10     transfer to Mr. Jones  \n" +
+				"page 1 of 1, 2 operations total\n",
+		},
+		{
+			CaseName: "positive path, reconcile, table",
+			Args:     []string{"reconcile"},
+			Out: "USER_ID  BALANCE  OPERATIONS_SUM  DELTA\n" +
+				"2        15       10              5\n" +
+				"checked 2 users, found 1 discrepancies\n",
+		},
+		{
+			CaseName: "positive path, idempotency lookup, token before flags",
+			Args:     []string{"idempotency", "lookup", "1", "--output", "table"},
+			Out: "OPERATION_ID  USER_ID  DATE                  AMOUNT  PURPOSE           IDEMPOTENCY_TOKEN\n" +
+				"1             1        2020-08-11T07:23:58Z  10      incoming payment  1\n",
+		},
+		{
+			CaseName: "positive path, idempotency lookup, unknown token",
+			Args:     []string{"idempotency", "lookup", "--output", "table", "unknown"},
+			Out: "OPERATION_ID  USER_ID  DATE  AMOUNT  PURPOSE  IDEMPOTENCY_TOKEN\n" +
+				"no operations with idempotency token unknown\n",
+		},
+		{
+			CaseName: "negative path, idempotency lookup, token is not set",
+			Args:     []string{"idempotency", "lookup"},
+			Err:      ErrBadCommandArgs,
+		},
+		{
+			CaseName: "negative path, unknown command",
+			Args:     []string{"user", "delete", "--id", "1"},
+			Err:      ErrUnknownCommand,
+		},
+	}
+
+	for caseIdx, tc := range testCases {
+		t.Logf("\ttesting case:%d \"%s\"", caseIdx, tc.CaseName)
+		out := &bytes.Buffer{}
+		c, err := NewCLI(&app.StubBillingAppCommon{}, &StubReconciler{}, out)
+		require.NoError(t, err, "NewCLI must not return error")
+
+		err = c.Run(context.Background(), tc.Args)
+		if tc.Err != nil {
+			assert.True(t, errors.Is(err, tc.Err), "\t\terror must match, got %v", err)
+			continue
+		}
+		require.NoError(t, err, "\t\tRun must not return error")
+		assert.Equal(t, tc.Out, out.String(), "\t\toutput must match")
+	}
+}
+
+func TestCLI_UserAdjust(t *testing.T) {
+	t.Run("positive path, positive amount is credited to existing user", func(t *testing.T) {
+		stubApp := &StubAdjustApp{}
+		out := &bytes.Buffer{}
+		c, err := NewCLI(stubApp, &StubReconciler{}, out)
+		require.NoError(t, err, "NewCLI must not return error")
+
+		err = c.Run(context.Background(), []string{"user", "adjust", "--id", "2", "--amount", "12.50",
+			"--reason", "bonus", "--output", "json"})
+		require.NoError(t, err, "Run must not return error")
+
+		require.Len(t, stubApp.credits, 1, "account must be credited once")
+		assert.Equal(t, "Mr. Jones", stubApp.credits[0].Name, "user name must be kept")
+		assert.Equal(t, "12.5", stubApp.credits[0].Amount, "amount must match")
+		assert.NotEmpty(t, stubApp.credits[0].IdempotencyToken, "idempotency token must be generated")
+
+		result := &AdjustmentResult{}
+		require.NoError(t, json.Unmarshal(out.Bytes(), result), "output must be json")
+		assert.Equal(t, stubApp.credits[0].IdempotencyToken, result.IdempotencyToken, "token must be printed")
+	})
+
+	t.Run("positive path, withdrawal adjustment has no fee and does not spend bonus", func(t *testing.T) {
+		stubApp := &StubAdjustApp{}
+		c, err := NewCLI(stubApp, &StubReconciler{}, &bytes.Buffer{})
+		require.NoError(t, err, "NewCLI must not return error")
+
+		err = c.Run(context.Background(), []string{"user", "adjust", "--id", "2", "--amount", "-5", "--reason", "refund"})
+		require.NoError(t, err, "Run must not return error")
+
+		require.Len(t, stubApp.withdraws, 1, "account must be withdrawn once")
+		assert.Equal(t, "5", stubApp.withdraws[0].Amount, "amount must be positive")
+		assert.True(t, stubApp.withdraws[0].WithoutFee, "fee must not be charged for adjustment")
+		assert.True(t, stubApp.withdraws[0].WithoutBonus, "bonus must not be spent by adjustment")
+	})
+
+	t.Run("negative path, missing user is not created", func(t *testing.T) {
+		stubApp := &StubAdjustApp{}
+		c, err := NewCLI(stubApp, &StubReconciler{}, &bytes.Buffer{})
+		require.NoError(t, err, "NewCLI must not return error")
+
+		err = c.Run(context.Background(), []string{"user", "adjust", "--id", "100500", "--amount", "10", "--reason", "bonus"})
+		assert.True(t, errors.Is(err, app.ErrUserDoesNotExist), "must return user does not exist error")
+		assert.Empty(t, stubApp.credits, "account must not be credited")
+	})
+}
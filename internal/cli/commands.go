@@ -0,0 +1,265 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"job-backend-trainee-assignment/internal/app"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//UserView represents user account, shown by "user show" command
+type UserView struct {
+	UserId    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Balance   string    `json:"balance"`
+	Currency  string    `json:"currency"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+//AdjustmentResult represents result of "user adjust" command
+type AdjustmentResult struct {
+	UserId           int64  `json:"user_id"`
+	Amount           string `json:"amount"`
+	Reason           string `json:"reason"`
+	IdempotencyToken string `json:"idempotency_token"`
+	State            string `json:"state"`
+}
+
+var operationsHeader = []string{"OPERATION_ID", "USER_ID", "DATE", "AMOUNT", "PURPOSE", "IDEMPOTENCY_TOKEN"}
+
+func operationRows(operations []app.Operation) [][]string {
+	rows := make([][]string, 0, len(operations))
+	for _, op := range operations {
+		rows = append(rows, []string{
+			strconv.FormatInt(op.Id, 10),
+			strconv.FormatInt(op.UserId, 10),
+			op.Date.UTC().Format(time.RFC3339),
+			op.Amount.String(),
+			op.Comment,
+			op.IdempotencyToken,
+		})
+	}
+	return rows
+}
+
+func validateUserId(userId int64) error {
+	if userId <= 0 {
+		return fmt.Errorf("--id must be positive user identifier, err: %w", ErrBadCommandArgs)
+	}
+	return nil
+}
+
+func (c *CLI) userShow(ctx context.Context, args []string) error {
+	fs, output := newFlagSet("user show")
+	userId := fs.Int64("id", 0, "user identifier")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validateUserId(*userId); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	info, err := c.app.GetUserInfo(ctx, &app.UserInfoRequest{UserId: *userId})
+	if err != nil {
+		return err
+	}
+
+	balance, err := c.app.GetUserBalance(ctx, &app.BalanceRequest{UserId: *userId, Currency: info.Currency})
+	if err != nil {
+		return err
+	}
+
+	view := &UserView{
+		UserId:    info.UserId,
+		Name:      info.Name,
+		Balance:   balance.Balance,
+		Currency:  balance.Currency,
+		CreatedAt: info.CreatedAt,
+	}
+
+	return c.print(*output, view, []string{"USER_ID", "NAME", "BALANCE", "CURRENCY", "CREATED_AT"}, [][]string{{
+		strconv.FormatInt(view.UserId, 10), view.Name, view.Balance, view.Currency, view.CreatedAt.UTC().Format(time.RFC3339),
+	}})
+}
+
+func (c *CLI) userAdjust(ctx context.Context, args []string) error {
+	fs, output := newFlagSet("user adjust")
+	userId := fs.Int64("id", 0, "user identifier")
+	amountStr := fs.String("amount", "", "amount to credit, negative amount is withdrawn")
+	reason := fs.String("reason", "", "reason of adjustment, stored in operation comment")
+	token := fs.String("token", "", "idempotency token, generated if not set")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validateUserId(*userId); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	amount, err := decimal.NewFromString(*amountStr)
+	if err != nil || amount.IsZero() {
+		return fmt.Errorf("--amount must be non-zero decimal number, err: %w", ErrBadCommandArgs)
+	}
+
+	if strings.TrimSpace(*reason) == "" {
+		return fmt.Errorf("--reason must be set, err: %w", ErrBadCommandArgs)
+	}
+
+	if *token == "" {
+		*token = uuid.NewV4().String()
+	}
+
+	//crediting creates missing users, so user existence is checked before adjustment
+	info, err := c.app.GetUserInfo(ctx, &app.UserInfoRequest{UserId: *userId})
+	if err != nil {
+		return err
+	}
+
+	var result *app.ResultState
+	if amount.IsPositive() {
+		result, err = c.app.CreditUserAccount(ctx, &app.CreditAccountRequest{
+			UserId:           info.UserId,
+			Name:             info.Name,
+			Purpose:          *reason,
+			Amount:           amount.String(),
+			IdempotencyToken: *token,
+		})
+	} else {
+		result, err = c.app.WithdrawUserAccount(ctx, &app.WithdrawAccountRequest{
+			UserId:           info.UserId,
+			Purpose:          *reason,
+			Amount:           amount.Abs().String(),
+			IdempotencyToken: *token,
+			WithoutFee:       true,
+			WithoutBonus:     true,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	adjustment := &AdjustmentResult{
+		UserId:           info.UserId,
+		Amount:           amount.String(),
+		Reason:           *reason,
+		IdempotencyToken: *token,
+		State:            result.State,
+	}
+
+	return c.print(*output, adjustment, []string{"USER_ID", "AMOUNT", "REASON", "IDEMPOTENCY_TOKEN", "STATE"}, [][]string{{
+		strconv.FormatInt(adjustment.UserId, 10), adjustment.Amount, adjustment.Reason, adjustment.IdempotencyToken, adjustment.State,
+	}})
+}
+
+func (c *CLI) operationsList(ctx context.Context, args []string) error {
+	fs, output := newFlagSet("operations list")
+	userId := fs.Int64("id", 0, "user identifier")
+	from := fs.String("from", "", "first day of period, YYYY-MM-DD")
+	to := fs.String("to", "", "last day of period, YYYY-MM-DD")
+	page := fs.Int64("page", 1, "page number")
+	limit := fs.Int64("limit", 20, "operations per page, -1 for all operations")
+	orderField := fs.String("order-field", "date", "field to order operations by: date or amount")
+	orderDirection := fs.String("order-direction", "desc", "order direction: desc or asc")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validateUserId(*userId); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	opsLog, err := c.app.GetUserOperations(ctx, &app.OperationLogRequest{
+		UserId:         *userId,
+		OrderField:     *orderField,
+		OrderDirection: *orderDirection,
+		Page:           *page,
+		Limit:          *limit,
+		From:           *from,
+		To:             *to,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.print(*output, opsLog, operationsHeader, operationRows(opsLog.Operations))
+	if err != nil || *output != outputTable {
+		return err
+	}
+
+	_, err = fmt.Fprintf(c.out, "page %d of %d, %d operations total\n", opsLog.Page, opsLog.PagesTotal, opsLog.OperationsNum)
+	return err
+}
+
+func (c *CLI) reconcile(ctx context.Context, args []string) error {
+	fs, output := newFlagSet("reconcile")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	report, err := c.reconciler.Reconcile(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(report.Discrepancies))
+	for _, d := range report.Discrepancies {
+		rows = append(rows, []string{strconv.FormatInt(d.UserId, 10), d.Balance.String(), d.OperationsSum.String(), d.Delta.String()})
+	}
+
+	err = c.print(*output, report, []string{"USER_ID", "BALANCE", "OPERATIONS_SUM", "DELTA"}, rows)
+	if err != nil || *output != outputTable {
+		return err
+	}
+
+	_, err = fmt.Fprintf(c.out, "checked %d users, found %d discrepancies\n", report.UsersChecked, len(report.Discrepancies))
+	return err
+}
+
+func (c *CLI) idempotencyLookup(ctx context.Context, args []string) error {
+	fs, output := newFlagSet("idempotency lookup")
+
+	//token may be set before or after flags
+	var token string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		token, args = args[0], args[1:]
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if token == "" {
+		token = fs.Arg(0)
+	}
+	if token == "" {
+		return fmt.Errorf("idempotency token must be set, err: %w", ErrBadCommandArgs)
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	operations, err := c.app.GetOperationsByIdempotencyToken(ctx, &app.IdempotencyLookupRequest{IdempotencyToken: token})
+	if err != nil {
+		return err
+	}
+
+	err = c.print(*output, operations, operationsHeader, operationRows(operations))
+	if err != nil || *output != outputTable || len(operations) != 0 {
+		return err
+	}
+
+	_, err = fmt.Fprintf(c.out, "no operations with idempotency token %s\n", token)
+	return err
+}
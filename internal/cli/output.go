@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	outputTable = "table"
+	outputJson  = "json"
+)
+
+// print writes value as indented json or rows as aligned table with header
+func (c *CLI) print(output string, value interface{}, header []string, rows [][]string) error {
+	switch output {
+	case outputJson:
+		enc := json.NewEncoder(c.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(value)
+	case outputTable:
+		tw := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("%s, err: %w", output, ErrUnknownOutputFormat)
+}
+
+func validateOutput(output string) error {
+	if output != outputTable && output != outputJson {
+		return fmt.Errorf("%s, err: %w", output, ErrUnknownOutputFormat)
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package config_reload
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"job-backend-trainee-assignment/internal/logger"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//ApplyFunc validates reloaded config and returns function, applying it.
+//Changes are applied only if all ApplyFuncs succeed, so config is never applied partially
+type ApplyFunc func(v *viper.Viper) (apply func(), err error)
+
+//maskedValue replaces values of secret params in effective config
+const maskedValue = "******"
+
+//secretKeys are names of secret params, param is secret if its name is one of them or ends with _<name>,
+//e.g. "password", "sentinel_pass" or "api_key"
+var secretKeys = []string{"pass", "password", "dsn", "token", "secret", "key"}
+
+//Reloader rereads config file on change or on demand and applies changes of safe params.
+//Changes of other params, e.g. database host, require restart, they are logged and ignored
+type Reloader struct {
+	logger     logger.ILogger
+	configFile string
+	safeKeys   map[string]bool
+	effective  map[string]interface{}
+	appliers   []ApplyFunc
+	mu         sync.Mutex
+}
+
+//NewReloader creates reloader of config, read by v. Safe keys are full param names, e.g. "log_params.log_level"
+func NewReloader(logger logger.ILogger, v *viper.Viper, safeKeys []string) (*Reloader, error) {
+	if logger == nil {
+		return nil, fmt.Errorf("must provide non-nil logger instance")
+	}
+
+	if v == nil || v.ConfigFileUsed() == "" {
+		return nil, fmt.Errorf("must provide viper instance with read config file")
+	}
+
+	safe := make(map[string]bool, len(safeKeys))
+	for _, k := range safeKeys {
+		safe[strings.ToLower(k)] = true
+	}
+
+	return &Reloader{
+		logger:     logger,
+		configFile: v.ConfigFileUsed(),
+		safeKeys:   safe,
+		effective:  flatten("", v.AllSettings()),
+		mu:         sync.Mutex{},
+	}, nil
+}
+
+//OnReload adds function, applying changed config
+func (r *Reloader) OnReload(apply ApplyFunc) {
+	r.mu.Lock()
+	r.appliers = append(r.appliers, apply)
+	r.mu.Unlock()
+}
+
+//Watch reloads config on each change of config file
+func (r *Reloader) Watch() error {
+	v := viper.New()
+	v.SetConfigFile(r.configFile)
+	err := v.ReadInConfig()
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s, err %v", r.configFile, err)
+	}
+
+	v.OnConfigChange(func(e fsnotify.Event) {
+		r.logger.Info("Watch, config file %s changed, reloading", e.Name)
+		_ = r.Reload()
+	})
+	v.WatchConfig()
+	return nil
+}
+
+//Reload rereads config file and applies changed safe params
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v := viper.New()
+	v.SetConfigFile(r.configFile)
+	err := v.ReadInConfig()
+	if err != nil {
+		r.logger.Error("Reload, failed to read config file %s, config is not changed, err %v", r.configFile, err)
+		return err
+	}
+
+	reloaded := flatten("", v.AllSettings())
+	merged := make(map[string]interface{}, len(r.effective))
+	for k, val := range r.effective {
+		merged[k] = val
+	}
+
+	changed := make([]string, 0)
+	for _, k := range unionKeys(r.effective, reloaded) {
+		if reflect.DeepEqual(r.effective[k], reloaded[k]) {
+			continue
+		}
+
+		if !r.safeKeys[k] {
+			r.logger.Error("Reload, WARNING change of %s requires restart, the change is ignored", k)
+			continue
+		}
+
+		merged[k] = reloaded[k]
+		changed = append(changed, k)
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	mergedViper := viper.New()
+	for k, val := range merged {
+		mergedViper.Set(k, val)
+	}
+
+	applyFuncs := make([]func(), 0, len(r.appliers))
+	for _, applier := range r.appliers {
+		apply, err := applier(mergedViper)
+		if err != nil {
+			r.logger.Error("Reload, changed config is invalid, config is not changed, err %v", err)
+			return err
+		}
+		applyFuncs = append(applyFuncs, apply)
+	}
+
+	for _, apply := range applyFuncs {
+		apply()
+	}
+
+	r.effective = merged
+	r.logger.Info("Reload, applied changes of %s", strings.Join(changed, ", "))
+	return nil
+}
+
+//EffectiveConfig returns active config as nested sections, values of secret params are masked,
+//including params of list items, e.g. of db replicas
+func (r *Reloader) EffectiveConfig() map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := make(map[string]interface{})
+	for k, val := range r.effective {
+		path := strings.Split(k, ".")
+		val = maskSecrets(path[len(path)-1], val)
+
+		section := res
+		for _, name := range path[:len(path)-1] {
+			next, ok := section[name].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				section[name] = next
+			}
+			section = next
+		}
+		section[path[len(path)-1]] = val
+	}
+	return res
+}
+
+//isSecretKey returns true if param with given name must not be shown
+func isSecretKey(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range secretKeys {
+		if name == secret || strings.HasSuffix(name, "_"+secret) {
+			return true
+		}
+	}
+	return false
+}
+
+//maskSecrets returns copy of param value, in which values of secret params are masked
+//through nested sections and lists
+func maskSecrets(name string, val interface{}) interface{} {
+	if isSecretKey(name) {
+		return maskedValue
+	}
+
+	switch v := val.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			res[k] = maskSecrets(k, item)
+		}
+		return res
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			key := fmt.Sprint(k)
+			res[key] = maskSecrets(key, item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = maskSecrets("", item)
+		}
+		return res
+	default:
+		return val
+	}
+}
+
+//flatten converts nested config sections to map of full param names
+func flatten(prefix string, settings map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{})
+	for k, val := range settings {
+		key := strings.ToLower(k)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if section, ok := val.(map[string]interface{}); ok {
+			for sk, sv := range flatten(key, section) {
+				res[sk] = sv
+			}
+			continue
+		}
+		res[key] = val
+	}
+	return res
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
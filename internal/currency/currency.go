@@ -0,0 +1,194 @@
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"sort"
+	"strings"
+)
+
+//RoundingMode is a rule of rounding amount to minor units of currency
+type RoundingMode string
+
+const (
+	//RoundBank rounds half to even
+	RoundBank RoundingMode = "bank"
+	//RoundHalfUp rounds half away from zero
+	RoundHalfUp RoundingMode = "half_up"
+	//RoundDown truncates digits after minor units
+	RoundDown RoundingMode = "down"
+)
+
+var ErrUnknownRoundingMode = errors.New("unknown rounding mode")
+
+//ParseRoundingMode returns rounding mode by name, empty name is banker's rounding
+func ParseRoundingMode(name string) (RoundingMode, error) {
+	switch mode := RoundingMode(strings.ToLower(name)); mode {
+	case "":
+		return RoundBank, nil
+	case RoundBank, RoundHalfUp, RoundDown:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%s, err: %w", name, ErrUnknownRoundingMode)
+	}
+}
+
+//Currency keeps precision of currency amounts
+type Currency struct {
+	Code string
+	Name string
+	//MinorUnits is a number of digits after decimal point, ISO 4217 exponent for fiat currencies
+	MinorUnits int32
+	//MinAmount is a min amount of operation, zero means one minor unit
+	MinAmount decimal.Decimal
+	Rounding  RoundingMode
+}
+
+//MinorUnit returns the smallest amount of currency
+func (c Currency) MinorUnit() decimal.Decimal {
+	return decimal.New(1, -c.MinorUnits)
+}
+
+//Min returns min amount of operation
+func (c Currency) Min() decimal.Decimal {
+	if c.MinAmount.IsPositive() {
+		return c.MinAmount
+	}
+	return c.MinorUnit()
+}
+
+//Round rounds amount to minor units by rounding mode of currency
+func (c Currency) Round(amount decimal.Decimal) decimal.Decimal {
+	switch c.Rounding {
+	case RoundHalfUp:
+		return amount.Round(c.MinorUnits)
+	case RoundDown:
+		return amount.Truncate(c.MinorUnits)
+	default:
+		return amount.RoundBank(c.MinorUnits)
+	}
+}
+
+//FitsMinorUnits checks that amount has no digits after minor units
+func (c Currency) FitsMinorUnits(amount decimal.Decimal) bool {
+	return amount.Equal(amount.Truncate(c.MinorUnits))
+}
+
+//Validate checks currency settings
+func (c Currency) Validate() error {
+	if c.Code == "" {
+		return fmt.Errorf("currency code must not be empty")
+	}
+	if c.MinorUnits < 0 || c.MinorUnits > maxMinorUnits {
+		return fmt.Errorf("currency %s minor units must be in [0, %d]", c.Code, maxMinorUnits)
+	}
+	if c.MinAmount.IsNegative() {
+		return fmt.Errorf("currency %s min amount must not be negative", c.Code)
+	}
+	if !c.FitsMinorUnits(c.MinAmount) {
+		return fmt.Errorf("currency %s min amount must have at most %d fractional digits", c.Code, c.MinorUnits)
+	}
+	if _, err := ParseRoundingMode(string(c.Rounding)); err != nil {
+		return fmt.Errorf("currency %s, %w", c.Code, err)
+	}
+	return nil
+}
+
+const (
+	maxMinorUnits = 18
+	//defaultMinorUnits is used for currencies, which are missing in registry
+	defaultMinorUnits = 2
+)
+
+type isoCurrency struct {
+	name       string
+	minorUnits int32
+}
+
+//isoCurrencies are ISO 4217 names and exponents of currencies, supported by exchange rates service, and some others
+var isoCurrencies = map[string]isoCurrency{
+	"AUD": {"Australian Dollar", 2}, "BGN": {"Bulgarian Lev", 2}, "BHD": {"Bahraini Dinar", 3},
+	"BRL": {"Brazilian Real", 2}, "CAD": {"Canadian Dollar", 2}, "CHF": {"Swiss Franc", 2},
+	"CLP": {"Chilean Peso", 0}, "CNY": {"Yuan Renminbi", 2}, "CZK": {"Czech Koruna", 2},
+	"DKK": {"Danish Krone", 2}, "EUR": {"Euro", 2}, "GBP": {"Pound Sterling", 2},
+	"HKD": {"Hong Kong Dollar", 2}, "HRK": {"Kuna", 2}, "HUF": {"Forint", 2},
+	"IDR": {"Rupiah", 2}, "ILS": {"New Israeli Sheqel", 2}, "INR": {"Indian Rupee", 2},
+	"ISK": {"Iceland Krona", 0}, "JOD": {"Jordanian Dinar", 3}, "JPY": {"Yen", 0},
+	"KRW": {"Won", 0}, "KWD": {"Kuwaiti Dinar", 3}, "MXN": {"Mexican Peso", 2},
+	"MYR": {"Malaysian Ringgit", 2}, "NOK": {"Norwegian Krone", 2}, "NZD": {"New Zealand Dollar", 2},
+	"OMR": {"Rial Omani", 3}, "PHP": {"Philippine Peso", 2}, "PLN": {"Zloty", 2},
+	"RON": {"Romanian Leu", 2}, "RUB": {"Russian Ruble", 2}, "SEK": {"Swedish Krona", 2},
+	"SGD": {"Singapore Dollar", 2}, "THB": {"Baht", 2}, "TND": {"Tunisian Dinar", 3},
+	"TRY": {"Turkish Lira", 2}, "USD": {"US Dollar", 2}, "VND": {"Dong", 0},
+	"ZAR": {"Rand", 2},
+}
+
+//Registry keeps currencies by code, it is not changed after creation and may be used concurrently
+type Registry struct {
+	currencies      map[string]Currency
+	defaultRounding RoundingMode
+}
+
+//NewRegistry creates registry of ISO 4217 currencies with default rounding mode.
+//Currencies replace ISO currencies with the same code or add new ones, e.g. crypto currencies
+func NewRegistry(defaultRounding RoundingMode, currencies ...Currency) (*Registry, error) {
+	defaultRounding, err := ParseRoundingMode(string(defaultRounding))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Registry{currencies: make(map[string]Currency, len(isoCurrencies)+len(currencies)), defaultRounding: defaultRounding}
+	for code, iso := range isoCurrencies {
+		r.currencies[code] = Currency{Code: code, Name: iso.name, MinorUnits: iso.minorUnits, Rounding: defaultRounding}
+	}
+
+	for _, c := range currencies {
+		c.Code = strings.ToUpper(c.Code)
+		if c.Name == "" {
+			c.Name = isoCurrencies[c.Code].name
+		}
+		if c.Rounding == "" {
+			c.Rounding = defaultRounding
+		}
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+		r.currencies[c.Code] = c
+	}
+	return r, nil
+}
+
+var defaultRegistry, _ = NewRegistry(RoundBank)
+
+//DefaultRegistry returns registry of ISO 4217 currencies with banker's rounding
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+//Get returns currency by code, currency missing in registry has 2 minor units and default rounding
+func (r *Registry) Get(code string) Currency {
+	if c, ok := r.currencies[code]; ok {
+		return c
+	}
+	return Currency{Code: code, MinorUnits: defaultMinorUnits, Rounding: r.defaultRounding}
+}
+
+//Known checks that currency is in registry
+func (r *Registry) Known(code string) bool {
+	_, ok := r.currencies[code]
+	return ok
+}
+
+//All returns currencies of registry, sorted by code
+func (r *Registry) All() []Currency {
+	currencies := make([]Currency, 0, len(r.currencies))
+	for _, c := range r.currencies {
+		currencies = append(currencies, c)
+	}
+
+	sort.Slice(currencies, func(i, j int) bool {
+		return currencies[i].Code < currencies[j].Code
+	})
+	return currencies
+}
@@ -0,0 +1,97 @@
+package currency
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCurrency_Round(t *testing.T) {
+	testCases := []struct {
+		caseName string
+		currency Currency
+		amount   string
+		expected string
+	}{
+		{"bank, half to even down", Currency{MinorUnits: 2, Rounding: RoundBank}, "1.125", "1.12"},
+		{"bank, half to even up", Currency{MinorUnits: 2, Rounding: RoundBank}, "1.135", "1.14"},
+		{"half up", Currency{MinorUnits: 2, Rounding: RoundHalfUp}, "1.125", "1.13"},
+		{"half up, negative", Currency{MinorUnits: 2, Rounding: RoundHalfUp}, "-1.125", "-1.13"},
+		{"down", Currency{MinorUnits: 2, Rounding: RoundDown}, "1.129", "1.12"},
+		{"down, negative", Currency{MinorUnits: 2, Rounding: RoundDown}, "-1.129", "-1.12"},
+		{"no minor units", Currency{MinorUnits: 0, Rounding: RoundHalfUp}, "145.5", "146"},
+		{"8 minor units", Currency{MinorUnits: 8, Rounding: RoundDown}, "0.123456789", "0.12345678"},
+		{"empty mode is bank", Currency{MinorUnits: 0}, "2.5", "2"},
+	}
+
+	for _, testCase := range testCases {
+		amount := decimal.RequireFromString(testCase.amount)
+		assert.Equal(t, testCase.expected, testCase.currency.Round(amount).String(), testCase.caseName)
+	}
+}
+
+func TestCurrency_MinAndMinorUnits(t *testing.T) {
+	jpy := Currency{Code: "JPY", MinorUnits: 0}
+	assert.Equal(t, "1", jpy.Min().String(), "min amount must be one minor unit by default")
+	assert.True(t, jpy.FitsMinorUnits(decimal.NewFromInt(100)))
+	assert.False(t, jpy.FitsMinorUnits(decimal.RequireFromString("100.5")))
+
+	btc := Currency{Code: "BTC", MinorUnits: 8, MinAmount: decimal.RequireFromString("0.0001")}
+	assert.Equal(t, "0.0001", btc.Min().String())
+	assert.Equal(t, "0.00000001", btc.MinorUnit().String())
+	assert.True(t, btc.FitsMinorUnits(decimal.RequireFromString("0.00000001")))
+	assert.False(t, btc.FitsMinorUnits(decimal.RequireFromString("0.000000001")))
+}
+
+func TestNewRegistry(t *testing.T) {
+	t.Run("ISO currencies", func(t *testing.T) {
+		r := DefaultRegistry()
+		assert.Equal(t, int32(0), r.Get("JPY").MinorUnits)
+		assert.Equal(t, int32(3), r.Get("KWD").MinorUnits)
+		assert.Equal(t, int32(2), r.Get("RUB").MinorUnits)
+		assert.Equal(t, RoundBank, r.Get("RUB").Rounding)
+		assert.Equal(t, "Russian Ruble", r.Get("RUB").Name)
+
+		assert.False(t, r.Known("XYZ"))
+		assert.Equal(t, Currency{Code: "XYZ", MinorUnits: 2, Rounding: RoundBank}, r.Get("XYZ"),
+			"currency missing in registry must have 2 minor units")
+
+		all := r.All()
+		require.NotEmpty(t, all)
+		for i := 1; i < len(all); i++ {
+			assert.Less(t, all[i-1].Code, all[i].Code, "currencies must be sorted by code")
+		}
+	})
+
+	t.Run("configured currencies", func(t *testing.T) {
+		r, err := NewRegistry(RoundHalfUp,
+			Currency{Code: "btc", MinorUnits: 8, Rounding: RoundDown},
+			Currency{Code: "RUB", MinorUnits: 2, MinAmount: decimal.NewFromInt(1)})
+		require.NoError(t, err)
+
+		assert.True(t, r.Known("BTC"), "code must be upper cased")
+		assert.Equal(t, RoundDown, r.Get("BTC").Rounding)
+		assert.Equal(t, "1", r.Get("RUB").Min().String())
+		assert.Equal(t, "Russian Ruble", r.Get("RUB").Name, "empty name must be ISO name")
+		assert.Equal(t, RoundHalfUp, r.Get("RUB").Rounding, "empty rounding must be default rounding")
+		assert.Equal(t, RoundHalfUp, r.Get("USD").Rounding)
+	})
+
+	t.Run("negative path, bad params", func(t *testing.T) {
+		_, err := NewRegistry("up")
+		assert.ErrorIs(t, err, ErrUnknownRoundingMode)
+
+		_, err = NewRegistry(RoundBank, Currency{Code: "BTC", MinorUnits: -1})
+		assert.Error(t, err, "negative minor units must be rejected")
+
+		_, err = NewRegistry(RoundBank, Currency{Code: "JPY", MinorUnits: 0, MinAmount: decimal.RequireFromString("0.5")})
+		assert.Error(t, err, "min amount must fit minor units")
+
+		_, err = NewRegistry(RoundBank, Currency{Code: "", MinorUnits: 2})
+		assert.Error(t, err, "empty code must be rejected")
+
+		_, err = NewRegistry(RoundBank, Currency{Code: "BTC", MinorUnits: 8, Rounding: "ceil"})
+		assert.ErrorIs(t, err, ErrUnknownRoundingMode)
+	})
+}
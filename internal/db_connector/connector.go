@@ -3,13 +3,21 @@ package db_connector
 import (
 	"context"
 	"fmt"
+	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"job-backend-trainee-assignment/internal/logger"
 	"os"
 	"time"
 )
 
+//Supported database drivers
+const (
+	DriverPostgres = "pgx"
+	DriverMySQL    = "mysql"
+)
+
 type Config struct {
+	//DriverName is DriverPostgres or DriverMySQL
 	DriverName    string
 	DBUser        string
 	DBPass        string
@@ -30,11 +38,13 @@ func DBConnectWithTimeout(ctx context.Context, cfg *Config, log logger.ILogger)
 		log = logger.NewLogger(os.Stdout, "DbConnector ", logger.L_INFO)
 	}
 
-	DSN := "user=%s password=%s host=%s port=%s database=%s sslmode=%s"
+	connString, err := cfg.dataSourceName()
+	if err != nil {
+		log.Error("DBConnectWithTimeout, %v", err)
+		return nil, nil, err
+	}
 
 	connWaitChan := make(chan struct{})
-	connString := fmt.Sprintf(DSN, cfg.DBUser, cfg.DBPass, cfg.DBHost,
-		cfg.DBPort, cfg.DBName, cfg.SSLMode)
 	go func() {
 		for {
 			db, err = sqlx.Connect(cfg.DriverName, connString)
@@ -65,3 +75,28 @@ func DBConnectWithTimeout(ctx context.Context, cfg *Config, log logger.ILogger)
 		}
 	}
 }
+
+//dataSourceName returns connection string of driver. Times of mysql are read and written in UTC,
+//ssl mode is mapped to tls param of mysql driver: disable - no tls, verify-full - verified tls, others - unverified tls
+func (cfg *Config) dataSourceName() (string, error) {
+	switch cfg.DriverName {
+	case DriverPostgres:
+		DSN := "user=%s password=%s host=%s port=%s database=%s sslmode=%s"
+		return fmt.Sprintf(DSN, cfg.DBUser, cfg.DBPass, cfg.DBHost,
+			cfg.DBPort, cfg.DBName, cfg.SSLMode), nil
+	case DriverMySQL:
+		tlsMode := "skip-verify"
+		switch cfg.SSLMode {
+		case "", "disable":
+			tlsMode = "false"
+		case "verify-full":
+			tlsMode = "true"
+		}
+
+		DSN := "%s:%s@tcp(%s:%s)/%s?parseTime=true&loc=UTC&tls=%s"
+		return fmt.Sprintf(DSN, cfg.DBUser, cfg.DBPass, cfg.DBHost,
+			cfg.DBPort, cfg.DBName, tlsMode), nil
+	default:
+		return "", fmt.Errorf("unsupported driver %q, must be one of %s, %s", cfg.DriverName, DriverPostgres, DriverMySQL)
+	}
+}
@@ -0,0 +1,35 @@
+package db_connector
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_DataSourceName(t *testing.T) {
+	cfg := &Config{DriverName: DriverPostgres, DBUser: "user", DBPass: "pass", DBHost: "db", DBPort: "5432",
+		DBName: "billing_db", SSLMode: "disable"}
+	dsn, err := cfg.dataSourceName()
+	require.NoError(t, err)
+	assert.Equal(t, "user=user password=pass host=db port=5432 database=billing_db sslmode=disable", dsn)
+
+	cfg.DriverName = DriverMySQL
+	cfg.DBPort = "3306"
+	dsn, err = cfg.dataSourceName()
+	require.NoError(t, err)
+	assert.Equal(t, "user:pass@tcp(db:3306)/billing_db?parseTime=true&loc=UTC&tls=false", dsn)
+
+	cfg.SSLMode = "verify-full"
+	dsn, err = cfg.dataSourceName()
+	require.NoError(t, err)
+	assert.Equal(t, "user:pass@tcp(db:3306)/billing_db?parseTime=true&loc=UTC&tls=true", dsn)
+
+	cfg.SSLMode = "require"
+	dsn, err = cfg.dataSourceName()
+	require.NoError(t, err)
+	assert.Equal(t, "user:pass@tcp(db:3306)/billing_db?parseTime=true&loc=UTC&tls=skip-verify", dsn)
+
+	cfg.DriverName = "sqlite3"
+	_, err = cfg.dataSourceName()
+	assert.Error(t, err, "unsupported driver must be rejected")
+}
@@ -0,0 +1,177 @@
+package db_connector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"job-backend-trainee-assignment/internal/logger"
+	"sync"
+	"time"
+)
+
+// Replica is a read replica of primary database
+type Replica struct {
+	//Name is used in logs, host:port of replica
+	Name string
+	DB   *sqlx.DB
+}
+
+type ReplicaConfig struct {
+	//MaxLag is a max replication lag of replica, used for reads
+	MaxLag time.Duration
+	//CheckInterval is a period of replication lag check
+	CheckInterval time.Duration
+}
+
+var (
+	defaultMaxLag           = 5 * time.Second
+	defaultLagCheckInterval = 5 * time.Second
+	lagCheckTimeout         = 2 * time.Second
+)
+
+// ReplicaStatus represents result of last replication lag check
+type ReplicaStatus struct {
+	Name    string
+	Healthy bool
+	Lag     time.Duration
+}
+
+// ReplicaSet chooses replica for reads among replicas, which lag is checked periodically
+type ReplicaSet struct {
+	logger   logger.ILogger
+	cfg      ReplicaConfig
+	replicas []*Replica
+	statuses []ReplicaStatus
+	next     int
+	mu       sync.Mutex
+}
+
+func NewReplicaSet(log logger.ILogger, cfg *ReplicaConfig, replicas ...*Replica) (*ReplicaSet, error) {
+	if log == nil {
+		return nil, fmt.Errorf("must provide non-nil logger instance")
+	}
+
+	if cfg == nil {
+		cfg = &ReplicaConfig{}
+	}
+
+	if cfg.MaxLag < 0 || cfg.CheckInterval < 0 {
+		return nil, fmt.Errorf("replica config params must be non-negative")
+	}
+
+	c := *cfg
+	if c.MaxLag == 0 {
+		c.MaxLag = defaultMaxLag
+	}
+	if c.CheckInterval == 0 {
+		c.CheckInterval = defaultLagCheckInterval
+	}
+
+	statuses := make([]ReplicaStatus, 0, len(replicas))
+	for _, r := range replicas {
+		if r == nil || r.DB == nil {
+			return nil, fmt.Errorf("must provide non-nil replica instances")
+		}
+		//replica is not used until its lag is checked
+		statuses = append(statuses, ReplicaStatus{Name: r.Name})
+	}
+
+	return &ReplicaSet{logger: log, cfg: c, replicas: replicas, statuses: statuses}, nil
+}
+
+// Replica returns next healthy replica, ok is false if there is no healthy replica
+func (rs *ReplicaSet) Replica() (replica *Replica, ok bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for i := 0; i < len(rs.replicas); i++ {
+		idx := (rs.next + i) % len(rs.replicas)
+		if rs.statuses[idx].Healthy {
+			rs.next = idx + 1
+			return rs.replicas[idx], true
+		}
+	}
+	return nil, false
+}
+
+// Statuses returns results of last lag check
+func (rs *ReplicaSet) Statuses() []ReplicaStatus {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	statuses := make([]ReplicaStatus, len(rs.statuses))
+	copy(statuses, rs.statuses)
+	return statuses
+}
+
+// CheckLag checks replication lag of replicas, replica is healthy if its lag is not greater than max lag.
+// Replica with no transactions to replay has zero lag, even if last replayed transaction is old
+func (rs *ReplicaSet) CheckLag(ctx context.Context) {
+	for idx, r := range rs.replicas {
+		status := ReplicaStatus{Name: r.Name}
+
+		lag, err := replicationLag(ctx, r.DB)
+		switch {
+		case err != nil:
+			rs.logger.Error("CheckLag, replica %s is unavailable, err %v", r.Name, err)
+		case lag > rs.cfg.MaxLag:
+			status.Lag = lag
+			rs.logger.Error("CheckLag, replica %s lag %v exceeds max lag %v", r.Name, lag, rs.cfg.MaxLag)
+		default:
+			status.Lag = lag
+			status.Healthy = true
+		}
+
+		rs.mu.Lock()
+		if rs.statuses[idx].Healthy != status.Healthy {
+			rs.logger.Info("CheckLag, replica %s healthy state changed to %v", r.Name, status.Healthy)
+		}
+		rs.statuses[idx] = status
+		rs.mu.Unlock()
+	}
+}
+
+func replicationLag(ctx context.Context, db *sqlx.DB) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, lagCheckTimeout)
+	defer cancel()
+
+	var row struct {
+		InRecovery bool            `db:"in_recovery"`
+		LagSeconds sql.NullFloat64 `db:"lag_seconds"`
+	}
+	err := db.GetContext(ctx, &row, `SELECT pg_is_in_recovery() AS in_recovery,
+		CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END AS lag_seconds`)
+	if err != nil {
+		return 0, err
+	}
+
+	if !row.InRecovery {
+		return 0, fmt.Errorf("database is not in recovery, it is not a replica")
+	}
+
+	if !row.LagSeconds.Valid {
+		return 0, fmt.Errorf("replica has not replayed any transaction yet")
+	}
+	return time.Duration(row.LagSeconds.Float64 * float64(time.Second)), nil
+}
+
+// Run checks replication lag periodically until ctx is done
+func (rs *ReplicaSet) Run(ctx context.Context) {
+	rs.logger.Info("replica lag checker started, interval %v", rs.cfg.CheckInterval)
+	rs.CheckLag(ctx)
+
+	ticker := time.NewTicker(rs.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			rs.logger.Info("replica lag checker stopped")
+			return
+		case <-ticker.C:
+			rs.CheckLag(ctx)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package db_connector
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/logger"
+	"testing"
+	"time"
+)
+
+func TestReplicaSet_Replica(t *testing.T) {
+	dummyLogger := &logger.DummyLogger{}
+
+	t.Run("negative path, bad params", func(t *testing.T) {
+		_, err := NewReplicaSet(nil, nil)
+		assert.Error(t, err, "must return error on nil logger")
+
+		_, err = NewReplicaSet(dummyLogger, &ReplicaConfig{MaxLag: -time.Second})
+		assert.Error(t, err, "must return error on negative max lag")
+
+		_, err = NewReplicaSet(dummyLogger, nil, &Replica{Name: "replica"})
+		assert.Error(t, err, "must return error on nil replica db")
+	})
+
+	t.Run("positive path, healthy replicas are chosen in turn", func(t *testing.T) {
+		first := &Replica{Name: "first", DB: &sqlx.DB{}}
+		second := &Replica{Name: "second", DB: &sqlx.DB{}}
+		third := &Replica{Name: "third", DB: &sqlx.DB{}}
+
+		rs, err := NewReplicaSet(dummyLogger, nil, first, second, third)
+		require.NoError(t, err, "NewReplicaSet must not return error")
+
+		_, ok := rs.Replica()
+		assert.False(t, ok, "replicas must not be used before lag check")
+
+		rs.statuses[0].Healthy = true
+		rs.statuses[2].Healthy = true
+
+		chosen := make([]string, 0, 4)
+		for i := 0; i < 4; i++ {
+			r, ok := rs.Replica()
+			require.True(t, ok, "healthy replica must be found")
+			chosen = append(chosen, r.Name)
+		}
+		assert.Equal(t, []string{"first", "third", "first", "third"}, chosen, "unhealthy replica must be skipped")
+	})
+}
@@ -10,6 +10,8 @@ var (
 	ErrContextDeadlineExceeded = fmt.Errorf("context deadline exceeded")
 
 	ErrTargetCurrencyNameNotFound  = errors.New("target currency with given name was not found")
+	ErrSourceCurrencyNameNotFound  = errors.New("source currency with given name was not found")
+	ErrRateIsNotPositive           = errors.New("exchange rate is not positive")
 	ErrExchangeServiceRequestError = errors.New("failed to get exchange rates from remote service")
 	ErrBaseCurrencyNameNotFound    = errors.New("base currency with given name was not found")
 	ErrAmountParamPtrIsNil         = errors.New("got nil ptr in amount param")
@@ -18,4 +20,6 @@ var (
 	ErrNewRequestCreateFailed      = errors.New("failed to unmarshal response body")
 	ErrErrorResponseUnknownError   = errors.New("got response with unknown error")
 	ErrRequestDoerError            = errors.New("error occured in request doer")
+
+	ErrExchangeServiceIsUnavailable = errors.New("exchange rates service is unavailable, request is skipped")
 )
@@ -3,9 +3,12 @@ package exchanger
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/shopspring/decimal"
 	"io/ioutil"
+	"job-backend-trainee-assignment/internal/breaker"
+	"job-backend-trainee-assignment/internal/currency"
 	"job-backend-trainee-assignment/internal/logger"
 	"net/http"
 	"sync"
@@ -19,13 +22,24 @@ type RequestDoer interface {
 type ICurrencyExchanger interface {
 	GetAmountInCurrency(ctx context.Context, amount decimal.Decimal, targetCurrencyName string) (
 		amountInCurrency *decimal.Decimal, err error)
+	GetConversion(ctx context.Context, amount decimal.Decimal, targetCurrencyName string) (
+		conversion *Conversion, err error)
+	ConvertBetween(ctx context.Context, amount decimal.Decimal, sourceCurrencyName string, targetCurrencyName string) (
+		conversion *Conversion, err error)
+	GetConversions(ctx context.Context, amount decimal.Decimal, targetCurrencyNames []string) (
+		conversions *Conversions, err error)
+}
+
+//IRatesProvider gives current rates and currencies, supported by exchanger
+type IRatesProvider interface {
+	GetRates(ctx context.Context) (*CurrentRates, error)
+	Currencies() *currency.Registry
 }
 
 const (
-	USDCode   = "USD"
-	RUBCode   = "RUB"
-	EURCode   = "EUR"
-	layoutISO = "2006-01-02"
+	USDCode = "USD"
+	RUBCode = "RUB"
+	EURCode = "EUR"
 )
 
 const StackExchangeApiURL = "https://api.exchangeratesapi.io/latest?base=%s"
@@ -36,118 +50,419 @@ type CurrencyExchanger struct {
 	logger       logger.ILogger
 	baseCurrency string
 	exchangeURL  string
+	//ratesBase is a currency of last fetched rates: base currency or pivot currency, if base is not supported
+	ratesBase    string
+	pivots       []string
 	cachedResult *ExchangeRates
 	cachedTime   time.Time
+	breaker      *breaker.Breaker
+	currencies   *currency.Registry
+	maxRatesAge  time.Duration
+	fetchTimeout time.Duration
+	inflight     *ratesFetch
 	mu           sync.Mutex
 }
 
+//ratesFetch is a request of rates to remote service, shared by concurrent callers
+type ratesFetch struct {
+	done        chan struct{}
+	result      *ExchangeRates
+	exchangeURL string
+	err         error
+}
+
+const (
+	//defaultMaxRatesAge is an age of fetched rates, after which rates are fetched on request
+	defaultMaxRatesAge = 24 * time.Hour
+	//defaultFetchTimeout is a max duration of rates fetch, shared by concurrent callers
+	defaultFetchTimeout = 30 * time.Second
+)
+
 func NewExchanger(logger logger.ILogger, requestDoer RequestDoer, baseCurrency string) (*CurrencyExchanger, error) {
 	exURL := fmt.Sprintf(StackExchangeApiURL, baseCurrency)
-	return &CurrencyExchanger{logger: logger, client: requestDoer, baseCurrency: baseCurrency, exchangeURL: exURL}, nil
+	return &CurrencyExchanger{logger: logger, client: requestDoer, baseCurrency: baseCurrency, exchangeURL: exURL,
+		ratesBase: baseCurrency, currencies: currency.DefaultRegistry(), maxRatesAge: defaultMaxRatesAge,
+		fetchTimeout: defaultFetchTimeout}, nil
+}
+
+//SetBreaker sets circuit breaker, which stops requests to remote service while it is unavailable
+func (ce *CurrencyExchanger) SetBreaker(b *breaker.Breaker) {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	ce.breaker = b
+}
+
+//SetMaxRatesAge sets age of fetched rates, after which they are fetched on request. Rates are refreshed
+//in background by Refresher, so it is a fallback for failed or stopped refresher
+func (ce *CurrencyExchanger) SetMaxRatesAge(maxAge time.Duration) {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	ce.maxRatesAge = maxAge
+}
+
+//SetFetchTimeout sets max duration of rates fetch. Fetch is shared by concurrent callers, so it does not depend
+//on their contexts, default timeout is used if it is not positive
+func (ce *CurrencyExchanger) SetFetchTimeout(timeout time.Duration) {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	if timeout <= 0 {
+		timeout = defaultFetchTimeout
+	}
+	ce.fetchTimeout = timeout
+}
+
+//SetPivotCurrencies sets currencies, which rates are fetched in, if remote service does not support base currency.
+//Pivot currencies are tried in given order, amounts are converted by cross rates through pivot currency
+func (ce *CurrencyExchanger) SetPivotCurrencies(pivots ...string) {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	ce.pivots = append([]string(nil), pivots...)
+}
+
+//SetCurrencies sets registry, which gives precision and rounding mode of converted amounts
+func (ce *CurrencyExchanger) SetCurrencies(r *currency.Registry) {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	ce.currencies = r
 }
 
 func (ce *CurrencyExchanger) GetAmountInCurrency(ctx context.Context, amount decimal.Decimal,
 	targetCurrencyName string) (*decimal.Decimal, error) {
+	conversion, err := ce.GetConversion(ctx, amount, targetCurrencyName)
+	if err != nil {
+		return nil, err
+	}
+	return &conversion.Amount, nil
+}
 
-	baseCurrency := ""
-	exchangeURL := ""
-	var cachedTime time.Time
-	cachedResultIsNil := true
+//GetConversion converts amount to target currency. If rates are outdated and remote service is unavailable,
+//last known rates are used and conversion is marked as stale
+func (ce *CurrencyExchanger) GetConversion(ctx context.Context, amount decimal.Decimal,
+	targetCurrencyName string) (*Conversion, error) {
 
 	ce.mu.Lock()
-	{
-		cachedTime = ce.cachedTime
-		baseCurrency = ce.baseCurrency
-		exchangeURL = ce.exchangeURL
-		if ce.cachedResult != nil {
-			cachedResultIsNil = false
-		}
+	baseCurrency := ce.baseCurrency
+	ce.mu.Unlock()
+
+	return ce.ConvertBetween(ctx, amount, baseCurrency, targetCurrencyName)
+}
+
+//ConvertBetween converts amount from source to target currency by direct, inverse or cross rate,
+//converted amount is rounded to minor units of target currency
+func (ce *CurrencyExchanger) ConvertBetween(ctx context.Context, amount decimal.Decimal, sourceCurrencyName string,
+	targetCurrencyName string) (*Conversion, error) {
+
+	ce.mu.Lock()
+	currencies := ce.currencies
+	baseCurrency := ce.baseCurrency
+	ce.mu.Unlock()
+
+	if targetCurrencyName == sourceCurrencyName {
+		return &Conversion{Amount: amount, Rate: decimal.New(1, 0)}, nil
+	}
+
+	result, stale, err := ce.getRates(ctx)
+	if err != nil {
+		return nil, err
 	}
+
+	return ce.convertByRates(result, stale, currencies, baseCurrency, amount, sourceCurrencyName, targetCurrencyName)
+}
+
+//GetConversions converts amount of base currency to several target currencies by one snapshot of rates.
+//Conversion to unknown currency fails only its item, other errors fail all conversions
+func (ce *CurrencyExchanger) GetConversions(ctx context.Context, amount decimal.Decimal,
+	targetCurrencyNames []string) (*Conversions, error) {
+
+	ce.mu.Lock()
+	currencies := ce.currencies
+	baseCurrency := ce.baseCurrency
 	ce.mu.Unlock()
 
-	if time.Since(cachedTime).Minutes() >= 24*60 || cachedResultIsNil {
-		ce.logger.Info("updating cached ExchangeRates value")
+	result, stale, err := ce.getRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conversions := &Conversions{RatesDate: result.Date, Pivot: pivotOf(result, baseCurrency), Stale: stale,
+		Items: make([]ConversionItem, 0, len(targetCurrencyNames))}
+	for _, targetCurrencyName := range targetCurrencyNames {
+		item := ConversionItem{Currency: targetCurrencyName}
 		if targetCurrencyName == baseCurrency {
-			return &amount, nil
+			item.Conversion = &Conversion{Amount: amount, Rate: decimal.New(1, 0)}
+		} else {
+			item.Conversion, item.Err = ce.convertByRates(result, stale, currencies, baseCurrency, amount, baseCurrency,
+				targetCurrencyName)
 		}
+		conversions.Items = append(conversions.Items, item)
+	}
+	return conversions, nil
+}
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, exchangeURL, nil)
-		if err != nil {
-			ce.logger.Error("Failed to create currency rates request  at:%s, err:%v", exchangeURL, err)
-			return nil, fmt.Errorf("NewRequest err: %w", err)
-		}
+//convertByRates converts amount by given rates, converted amount is rounded to minor units of target currency
+func (ce *CurrencyExchanger) convertByRates(result *ExchangeRates, stale bool, currencies *currency.Registry,
+	baseCurrency string, amount decimal.Decimal, sourceCurrencyName string, targetCurrencyName string) (
+	*Conversion, error) {
 
-		resp, err := ce.client.Do(req)
-		if err != nil {
-			ce.logger.Error("Failed to get currency rates at:%s, err:%v, %v", exchangeURL, err)
-			return nil, fmt.Errorf("client.Get err: %v, err: %w", err, ErrRequestDoerError)
-		}
+	rate, err := result.Rate(sourceCurrencyName, targetCurrencyName)
+	if err != nil {
+		ce.logger.Error("failed to convert %s to %s, err %v", sourceCurrencyName, targetCurrencyName, err)
+		return nil, err
+	}
 
-		resBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			ce.logger.Error("Failed to read currency rates response body ,at:%s, err:%v", exchangeURL, err)
-			return nil, fmt.Errorf("ReadAll(resp.Body) err: %v, err:%w", err, ErrResponseBodyReadFailed)
-		}
+	pivot := pivotOf(result, baseCurrency)
+	if amount.IsZero() {
+		return &Conversion{Amount: amount, Rate: rate, RatesDate: result.Date, Pivot: pivot, Stale: stale}, nil
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			errBody := &ErrorResponseBody{}
-			err = json.Unmarshal(resBytes, errBody)
-			if err != nil {
-				ce.logger.Error("Failed to unmarshal error response body ,at:%s, err:%v", exchangeURL, err)
-				return nil, fmt.Errorf("reponse error json Unmarshal err: %w", ErrResponseJSONUnmarshalFailed)
-			}
+	amountInCurrency, err := result.Convert(amount, sourceCurrencyName, targetCurrencyName)
+	if err != nil {
+		return nil, err
+	}
 
-			if errBody.Err == fmt.Sprintf("Base '%s' is not supported.", baseCurrency) {
-				ce.logger.Error("base currency %s is not supported by remote service at:%s", baseCurrency, exchangeURL)
-				return nil, fmt.Errorf("base currency %s is not supported by remote service, err: %w", baseCurrency, ErrBaseCurrencyNameNotFound)
-			}
+	amountInCurrency = currencies.Get(targetCurrencyName).Round(amountInCurrency)
+	return &Conversion{Amount: amountInCurrency, Rate: rate, RatesDate: result.Date, Pivot: pivot, Stale: stale}, nil
+}
+
+//GetRates returns current rates of base currency, rates are stale if remote service is unavailable.
+//Rates, fetched in pivot currency, are converted to cross rates of base currency
+func (ce *CurrencyExchanger) GetRates(ctx context.Context) (*CurrentRates, error) {
+	result, stale, err := ce.getRates(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-			ce.logger.Error("got non-ok status code from exchange rates service at:%s, err:%s", exchangeURL, errBody.Err)
-			return nil, fmt.Errorf("got non-ok status code from exchange rates service err: %s, %w", errBody.Err, ErrErrorResponseUnknownError)
+	ce.mu.Lock()
+	source := ce.exchangeURL
+	baseCurrency := ce.baseCurrency
+	ce.mu.Unlock()
+
+	pivot := pivotOf(result, baseCurrency)
+	rates := make(map[string]decimal.Decimal, len(result.Rates)+1)
+	if pivot == "" {
+		for currName, rate := range result.Rates {
+			rates[currName] = rate
 		}
-		exchangeRatesResult := &ExchangeRates{}
-		err = json.Unmarshal(resBytes, exchangeRatesResult)
+	} else {
+		rates[pivot], err = result.Rate(baseCurrency, pivot)
 		if err != nil {
-			ce.logger.Error("Failed to unmarshal currency rates response body ,at:%s, err:%v", exchangeURL, err)
-			return nil, fmt.Errorf("exchangeRates json Unmarshal err: %w", ErrResponseJSONUnmarshalFailed)
+			return nil, err
 		}
+		for currName := range result.Rates {
+			rates[currName], err = result.Rate(baseCurrency, currName)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &CurrentRates{Base: baseCurrency, Date: result.Date, Source: source, Pivot: pivot, Rates: rates,
+		Stale: stale}, nil
+}
 
-		ratesLastUpdatedDate, err := time.Parse(layoutISO, exchangeRatesResult.Date)
-		if err != nil {
-			ce.logger.Error("Failed to parse current rates date ,at:%s, err:%v", exchangeURL, err)
+//pivotOf returns currency of rates, if it is not base currency, or empty string
+func pivotOf(result *ExchangeRates, baseCurrency string) string {
+	if result.Base == baseCurrency {
+		return ""
+	}
+	return result.Base
+}
+
+//Currencies returns registry, which gives precision and rounding mode of converted amounts
+func (ce *CurrencyExchanger) Currencies() *currency.Registry {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	return ce.currencies
+}
+
+//Refresh fetches current rates from remote service and caches them, concurrent fetches are shared
+func (ce *CurrencyExchanger) Refresh(ctx context.Context) error {
+	_, err := ce.refreshRates(ctx)
+	return err
+}
+
+//getRates returns cached rates or updates them, if they are older than max rates age. Age of rates is counted
+//from the fetch, not from the rates date, which does not change on weekends. If remote service is unavailable,
+//last known rates are returned as stale
+func (ce *CurrencyExchanger) getRates(ctx context.Context) (result *ExchangeRates, stale bool, err error) {
+	var cachedTime time.Time
+	var cachedResult *ExchangeRates
+	var maxRatesAge time.Duration
+
+	ce.mu.Lock()
+	{
+		cachedTime = ce.cachedTime
+		cachedResult = ce.cachedResult
+		maxRatesAge = ce.maxRatesAge
+	}
+	ce.mu.Unlock()
+
+	if cachedResult != nil && time.Since(cachedTime) < maxRatesAge {
+		ce.logger.Info("using cached ExchangeRates value")
+		return cachedResult, false, nil
+	}
+
+	ce.logger.Info("updating cached ExchangeRates value")
+	result, err = ce.refreshRates(ctx)
+	if err != nil {
+		if cachedResult == nil || ctx.Err() != nil {
+			return nil, false, err
 		}
+		ce.logger.Error("using last known ExchangeRates value, err: %v", err)
+		return cachedResult, true, nil
+	}
+	return result, false, nil
+}
+
+//refreshRates fetches rates and caches them. If rates are already being fetched, it waits for that fetch
+//instead of starting a new one, so concurrent misses make a single request to remote service.
+//Fetch runs with its own timeout, so cancellation of the caller, which started it, does not fail other callers
+func (ce *CurrencyExchanger) refreshRates(ctx context.Context) (*ExchangeRates, error) {
+	ce.mu.Lock()
+	fetch := ce.inflight
+	if fetch == nil {
+		fetch = &ratesFetch{done: make(chan struct{})}
+		ce.inflight = fetch
+		go ce.runFetch(fetch, ce.baseCurrency, ratesBasesOrder(ce.ratesBase, ce.baseCurrency, ce.pivots),
+			ce.breaker, ce.fetchTimeout)
+	}
+	ce.mu.Unlock()
+
+	select {
+	case <-fetch.done:
+		return fetch.result, fetch.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
 
-		ce.mu.Lock()
-		ce.cachedTime = ratesLastUpdatedDate
-		ce.cachedResult = exchangeRatesResult
-		ce.mu.Unlock()
+//runFetch does shared fetch of rates, caches fetched rates and notifies waiting callers
+func (ce *CurrencyExchanger) runFetch(fetch *ratesFetch, baseCurrency string, ratesBases []string,
+	cb *breaker.Breaker, timeout time.Duration) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var err error
+	if cb != nil {
+		err = cb.Allow()
+	}
+
+	if err == nil {
+		fetch.result, fetch.exchangeURL, fetch.err = ce.fetchSupportedRates(ctx, baseCurrency, ratesBases)
+		if cb != nil {
+			before := cb.Status().State
+			cb.Done(fetch.err != nil)
+			if after := cb.Status().State; after != before {
+				ce.logger.Error("exchanger circuit breaker state changed from %s to %s, last err: %v", before, after,
+					fetch.err)
+			}
+		}
 	} else {
-		ce.logger.Info("using cached ExchangeRates value")
+		fetch.err = fmt.Errorf("%v, err: %w", err, ErrExchangeServiceIsUnavailable)
 	}
 
 	ce.mu.Lock()
-	result := ce.cachedResult
+	ce.inflight = nil
+	if fetch.err == nil {
+		ce.cachedTime = time.Now()
+		ce.cachedResult = fetch.result
+		ce.exchangeURL = fetch.exchangeURL
+		ce.ratesBase = fetch.result.Base
+	}
 	ce.mu.Unlock()
 
-	found := false
-	var rate float64
-	for currName, currRate := range result.Rates {
-		if currName == targetCurrencyName {
-			found = true
-			rate = currRate
-			break
+	close(fetch.done)
+}
+
+//ratesBasesOrder returns currencies, which rates are requested in: currency of last fetched rates first,
+//then base currency and pivot currencies
+func ratesBasesOrder(ratesBase string, baseCurrency string, pivots []string) []string {
+	bases := make([]string, 0, len(pivots)+2)
+	seen := make(map[string]bool, len(pivots)+2)
+	for _, code := range append([]string{ratesBase, baseCurrency}, pivots...) {
+		if !seen[code] {
+			seen[code] = true
+			bases = append(bases, code)
 		}
 	}
+	return bases
+}
+
+//fetchSupportedRates requests rates in given currencies until remote service supports one of them.
+//Rates in pivot currency must include base currency, so cross rates of base currency can be computed
+func (ce *CurrencyExchanger) fetchSupportedRates(ctx context.Context, baseCurrency string, ratesBases []string) (
+	*ExchangeRates, string, error) {
+
+	var err error
+	for _, ratesBase := range ratesBases {
+		exchangeURL := fmt.Sprintf(StackExchangeApiURL, ratesBase)
 
-	if !found {
-		ce.logger.Error("failed to convert base to target currency, currency with name %s was not found", targetCurrencyName)
-		return nil, fmt.Errorf("unable to find specified currency name:%s in rates, err:%w", result.Base, ErrTargetCurrencyNameNotFound)
+		var result *ExchangeRates
+		result, err = ce.fetchRates(ctx, exchangeURL, ratesBase)
+		if err == nil {
+			if _, ok := result.rateOf(baseCurrency); ok {
+				return result, exchangeURL, nil
+			}
+			ce.logger.Error("rates of %s at:%s do not include base currency %s", ratesBase, exchangeURL, baseCurrency)
+			err = fmt.Errorf("rates of %s do not include base currency %s, err: %w", ratesBase, baseCurrency,
+				ErrBaseCurrencyNameNotFound)
+		}
+
+		if !errors.Is(err, ErrBaseCurrencyNameNotFound) {
+			return nil, "", err
+		}
 	}
+	return nil, "", err
+}
 
-	if amount.IsZero() {
-		return &amount, nil
+//fetchRates requests current rates of base currency from remote service
+func (ce *CurrencyExchanger) fetchRates(ctx context.Context, exchangeURL string, baseCurrency string) (*ExchangeRates, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, exchangeURL, nil)
+	if err != nil {
+		ce.logger.Error("Failed to create currency rates request  at:%s, err:%v", exchangeURL, err)
+		return nil, fmt.Errorf("NewRequest err: %w", err)
+	}
+
+	resp, err := ce.client.Do(req)
+	if err != nil {
+		ce.logger.Error("Failed to get currency rates at:%s, err:%v", exchangeURL, err)
+		return nil, fmt.Errorf("client.Get err: %v, err: %w", err, ErrRequestDoerError)
+	}
+
+	resBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		ce.logger.Error("Failed to read currency rates response body ,at:%s, err:%v", exchangeURL, err)
+		return nil, fmt.Errorf("ReadAll(resp.Body) err: %v, err:%w", err, ErrResponseBodyReadFailed)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		errBody := &ErrorResponseBody{}
+		err = json.Unmarshal(resBytes, errBody)
+		if err != nil {
+			ce.logger.Error("Failed to unmarshal error response body ,at:%s, err:%v", exchangeURL, err)
+			return nil, fmt.Errorf("reponse error json Unmarshal err: %w", ErrResponseJSONUnmarshalFailed)
+		}
+
+		if errBody.Err == fmt.Sprintf("Base '%s' is not supported.", baseCurrency) {
+			ce.logger.Error("base currency %s is not supported by remote service at:%s", baseCurrency, exchangeURL)
+			return nil, fmt.Errorf("base currency %s is not supported by remote service, err: %w", baseCurrency, ErrBaseCurrencyNameNotFound)
+		}
+
+		ce.logger.Error("got non-ok status code from exchange rates service at:%s, err:%s", exchangeURL, errBody.Err)
+		return nil, fmt.Errorf("got non-ok status code from exchange rates service err: %s, %w", errBody.Err, ErrErrorResponseUnknownError)
+	}
+
+	exchangeRatesResult := &ExchangeRates{}
+	err = json.Unmarshal(resBytes, exchangeRatesResult)
+	if err != nil {
+		ce.logger.Error("Failed to unmarshal currency rates response body ,at:%s, err:%v", exchangeURL, err)
+		return nil, fmt.Errorf("exchangeRates json Unmarshal err: %w", ErrResponseJSONUnmarshalFailed)
 	}
 
-	amountInCurrency := amount.Mul(decimal.NewFromFloat(rate)).RoundBank(2)
-	return &amountInCurrency, nil
+	err = exchangeRatesResult.fixPrecision()
+	if err != nil {
+		ce.logger.Error("got bad currency rates ,at:%s, err:%v", exchangeURL, err)
+		return nil, err
+	}
+	return exchangeRatesResult, nil
 }
@@ -1,11 +1,122 @@
 package exchanger
 
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+)
+
+//RateFracDigits is a number of digits after decimal point, rates are stored with
+const RateFracDigits = 18
+
 type ExchangeRates struct {
-	Rates map[string]float64 `json:"rates"`
-	Base  string             `json:"base"`
-	Date  string             `json:"date"`
+	//Rates are amounts of currencies for one unit of base currency, decimal keeps all digits of response
+	Rates map[string]decimal.Decimal `json:"rates"`
+	Base  string                     `json:"base"`
+	Date  string                     `json:"date"`
+}
+
+//fixPrecision rounds rates to RateFracDigits and checks that rates are positive
+func (er *ExchangeRates) fixPrecision() error {
+	for currName, rate := range er.Rates {
+		rate = rate.Round(RateFracDigits)
+		if !rate.IsPositive() {
+			return fmt.Errorf("rate of %s is %s, err: %w", currName, rate.String(), ErrRateIsNotPositive)
+		}
+		er.Rates[currName] = rate
+	}
+	return nil
+}
+
+//rateOf returns amount of currency for one unit of base currency
+func (er *ExchangeRates) rateOf(currName string) (decimal.Decimal, bool) {
+	if currName == er.Base {
+		return decimal.New(1, 0), true
+	}
+	rate, ok := er.Rates[currName]
+	return rate, ok
+}
+
+//Convert converts amount from one currency to another. Amount in base currency is multiplied by rate, amount in other
+//currency is converted to base currency by inverse rate or to third currency by cross rate through base currency.
+//Result is not rounded to minor units of currency, division keeps RateFracDigits digits after decimal point
+func (er *ExchangeRates) Convert(amount decimal.Decimal, fromCurrName, toCurrName string) (decimal.Decimal, error) {
+	if fromCurrName == toCurrName {
+		return amount, nil
+	}
+
+	fromRate, ok := er.rateOf(fromCurrName)
+	if !ok {
+		return decimal.Decimal{}, fmt.Errorf("unable to find currency name:%s in rates of %s, err:%w", fromCurrName, er.Base,
+			ErrSourceCurrencyNameNotFound)
+	}
+
+	toRate, ok := er.rateOf(toCurrName)
+	if !ok {
+		return decimal.Decimal{}, fmt.Errorf("unable to find currency name:%s in rates of %s, err:%w", toCurrName, er.Base,
+			ErrTargetCurrencyNameNotFound)
+	}
+
+	if fromCurrName == er.Base {
+		return amount.Mul(toRate), nil
+	}
+	return amount.Mul(toRate).DivRound(fromRate, RateFracDigits), nil
+}
+
+//Rate returns amount of target currency for one unit of source currency
+func (er *ExchangeRates) Rate(fromCurrName, toCurrName string) (decimal.Decimal, error) {
+	rate, err := er.Convert(decimal.New(1, 0), fromCurrName, toCurrName)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	return rate.Round(RateFracDigits), nil
+}
+
+//CurrentRates are rates of base currency, used by exchanger
+type CurrentRates struct {
+	Base string
+	//Date is a date of rates, given by remote service
+	Date string
+	//Source is url of remote service
+	Source string
+	//Pivot is a currency, rates were fetched in, if remote service does not support base currency
+	Pivot string
+	Rates map[string]decimal.Decimal
+	//Stale is true if last known rates are returned, because remote service is unavailable
+	Stale bool
 }
 
 type ErrorResponseBody struct {
 	Err string `json:"error"`
 }
+
+//Conversion is a result of amount conversion to target currency
+type Conversion struct {
+	Amount decimal.Decimal
+	//Rate is amount of target currency for one unit of source currency
+	Rate decimal.Decimal
+	//RatesDate is a date of used rates, it is empty for base currency
+	RatesDate string
+	//Pivot is a currency of cross rate, if remote service does not support base currency
+	Pivot string
+	//Stale is true if last known rates were used, because remote service is unavailable
+	Stale bool
+}
+
+//Conversions are results of amount conversion to several currencies by one snapshot of rates
+type Conversions struct {
+	//RatesDate is a date of used rates
+	RatesDate string
+	//Pivot is a currency of cross rates, if remote service does not support base currency
+	Pivot string
+	//Stale is true if last known rates were used, because remote service is unavailable
+	Stale bool
+	//Items are conversions in order of target currencies
+	Items []ConversionItem
+}
+
+//ConversionItem is a conversion to one of target currencies, Err is set if currency is unknown
+type ConversionItem struct {
+	Currency   string
+	Conversion *Conversion
+	Err        error
+}
@@ -0,0 +1,86 @@
+package exchanger
+
+import (
+	"encoding/json"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/currency"
+	"testing"
+)
+
+//highPrecisionRatesBody has rate of USD with more digits, than float64 keeps
+const highPrecisionRatesBody = `{"rates":{"USD":0.0136125478123456789123,"EUR":0.011534,"JPY":1.4411,"RUB":1},
+	"base":"RUB","date":"2020-08-15"}`
+
+func parseHighPrecisionRates(t *testing.T) *ExchangeRates {
+	rates := &ExchangeRates{}
+	require.NoError(t, json.Unmarshal([]byte(highPrecisionRatesBody), rates))
+	require.NoError(t, rates.fixPrecision())
+	return rates
+}
+
+func TestExchangeRates_ParsedWithFixedPrecision(t *testing.T) {
+	rates := parseHighPrecisionRates(t)
+	assert.Equal(t, "0.013612547812345679", rates.Rates[USDCode].String(), "rate must be rounded to 18 digits")
+	assert.Equal(t, "0.011534", rates.Rates[EURCode].String())
+
+	badRates := &ExchangeRates{}
+	require.NoError(t, json.Unmarshal([]byte(`{"rates":{"USD":0.0000000000000000001},"base":"RUB"}`), badRates))
+	assert.ErrorIs(t, badRates.fixPrecision(), ErrRateIsNotPositive, "rate rounded to zero must be rejected")
+}
+
+func TestExchangeRates_Convert(t *testing.T) {
+	rates := parseHighPrecisionRates(t)
+	registry := currency.DefaultRegistry()
+
+	testCases := []struct {
+		caseName string
+		amount   string
+		from     string
+		to       string
+		expected string
+	}{
+		{"direct, amount of max whole digits", "100000000000000", RUBCode, USDCode, "1361254781234.57"},
+		{"direct, half to even", "0.5", RUBCode, USDCode, "0.01"},
+		{"inverse", "100", USDCode, RUBCode, "7346.16"},
+		{"cross", "100", USDCode, EURCode, "84.73"},
+		{"cross to currency without minor units", "100", USDCode, "JPY", "10587"},
+		{"cross back", "84.73", EURCode, USDCode, "100"},
+		{"same currency", "12.34", USDCode, USDCode, "12.34"},
+	}
+
+	for _, testCase := range testCases {
+		amount, err := rates.Convert(decimal.RequireFromString(testCase.amount), testCase.from, testCase.to)
+		require.NoError(t, err, testCase.caseName)
+		assert.Equal(t, testCase.expected, registry.Get(testCase.to).Round(amount).String(), testCase.caseName)
+	}
+
+	_, err := rates.Convert(decimal.New(1, 0), "XYZ", USDCode)
+	assert.ErrorIs(t, err, ErrSourceCurrencyNameNotFound)
+
+	_, err = rates.Convert(decimal.New(1, 0), USDCode, "XYZ")
+	assert.ErrorIs(t, err, ErrTargetCurrencyNameNotFound)
+}
+
+func TestExchangeRates_Rate(t *testing.T) {
+	rates := parseHighPrecisionRates(t)
+
+	testCases := []struct {
+		caseName string
+		from     string
+		to       string
+		expected string
+	}{
+		{"direct", RUBCode, USDCode, "0.013612547812345679"},
+		{"inverse", USDCode, RUBCode, "73.461633618143566962"},
+		{"cross", USDCode, EURCode, "0.847306482151667901"},
+		{"same currency", EURCode, EURCode, "1"},
+	}
+
+	for _, testCase := range testCases {
+		rate, err := rates.Rate(testCase.from, testCase.to)
+		require.NoError(t, err, testCase.caseName)
+		assert.Equal(t, testCase.expected, rate.String(), testCase.caseName)
+	}
+}
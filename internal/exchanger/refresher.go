@@ -0,0 +1,94 @@
+package exchanger
+
+import (
+	"context"
+	"fmt"
+	"job-backend-trainee-assignment/internal/logger"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type IRatesRefresher interface {
+	Refresh(ctx context.Context) error
+}
+
+//Refresher periodically fetches current rates in background, so user requests are served by cached rates
+type Refresher struct {
+	logger    logger.ILogger
+	refresher IRatesRefresher
+	cfg       *RefresherConfig
+	rand      *rand.Rand
+	mu        sync.Mutex
+}
+
+type RefresherConfig struct {
+	//Interval is a period of rates fetch
+	Interval time.Duration
+	//Jitter is a max random delay, added to every period, so instances of app do not fetch rates at once
+	Jitter time.Duration
+}
+
+var (
+	defaultRefreshInterval = time.Hour
+	defaultRefreshJitter   = time.Minute
+)
+
+func NewRefresher(logger logger.ILogger, refresher IRatesRefresher, cfg *RefresherConfig) (*Refresher, error) {
+	if logger == nil {
+		return nil, fmt.Errorf("must provide non-nil logger instance")
+	}
+
+	if refresher == nil {
+		return nil, fmt.Errorf("must provide non-nil rates refresher instance")
+	}
+
+	if cfg == nil {
+		cfg = &RefresherConfig{Interval: defaultRefreshInterval, Jitter: defaultRefreshJitter}
+	}
+
+	if cfg.Interval <= 0 || cfg.Jitter < 0 {
+		return nil, fmt.Errorf("refresh interval must be positive and jitter must not be negative")
+	}
+
+	return &Refresher{
+		logger:    logger,
+		refresher: refresher,
+		cfg:       cfg,
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		mu:        sync.Mutex{},
+	}, nil
+}
+
+//Run fetches rates at start and then on every period until ctx is done
+func (r *Refresher) Run(ctx context.Context) {
+	for {
+		err := r.refresher.Refresh(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("Run, failed to refresh rates, err %v", err)
+		} else if err == nil {
+			r.logger.Info("Run, rates are refreshed")
+		}
+
+		timer := time.NewTimer(r.NextDelay())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			r.logger.Info("Run, context is done, stopping rates refresher")
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+//NextDelay returns delay before next fetch: interval and random jitter
+func (r *Refresher) NextDelay() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delay := r.cfg.Interval
+	if r.cfg.Jitter > 0 {
+		delay += time.Duration(r.rand.Int63n(int64(r.cfg.Jitter) + 1))
+	}
+	return delay
+}
@@ -0,0 +1,72 @@
+package exchanger
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/logger"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type StubRatesRefresher struct {
+	calls int32
+	err   error
+}
+
+func (srr *StubRatesRefresher) Refresh(ctx context.Context) error {
+	atomic.AddInt32(&srr.calls, 1)
+	return srr.err
+}
+
+func TestRefresher_Run(t *testing.T) {
+	t.Run("positive path, rates are refreshed at start and on every period", func(t *testing.T) {
+		refresher := &StubRatesRefresher{err: errors.New("connection refused")}
+		r, err := NewRefresher(&logger.DummyLogger{}, refresher, &RefresherConfig{Interval: time.Millisecond,
+			Jitter: time.Millisecond})
+		require.NoError(t, err, "NewRefresher must not return error")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		doneCh := make(chan struct{})
+		go func() {
+			r.Run(ctx)
+			close(doneCh)
+		}()
+
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&refresher.calls) >= 3
+		}, time.Second, time.Millisecond, "failed refresh must not stop refresher")
+		cancel()
+		<-doneCh
+	})
+
+	t.Run("positive path, delay is interval with jitter", func(t *testing.T) {
+		r, err := NewRefresher(&logger.DummyLogger{}, &StubRatesRefresher{}, &RefresherConfig{Interval: time.Hour,
+			Jitter: time.Minute})
+		require.NoError(t, err, "NewRefresher must not return error")
+
+		for i := 0; i < 100; i++ {
+			delay := r.NextDelay()
+			assert.GreaterOrEqual(t, int64(delay), int64(time.Hour))
+			assert.LessOrEqual(t, int64(delay), int64(time.Hour+time.Minute))
+		}
+
+		r, err = NewRefresher(&logger.DummyLogger{}, &StubRatesRefresher{}, &RefresherConfig{Interval: time.Hour})
+		require.NoError(t, err, "NewRefresher must not return error")
+		assert.Equal(t, time.Hour, r.NextDelay(), "delay without jitter must be interval")
+	})
+
+	t.Run("negative path, bad config", func(t *testing.T) {
+		_, err := NewRefresher(&logger.DummyLogger{}, &StubRatesRefresher{}, &RefresherConfig{Interval: 0})
+		assert.Error(t, err)
+
+		_, err = NewRefresher(&logger.DummyLogger{}, &StubRatesRefresher{}, &RefresherConfig{Interval: time.Hour,
+			Jitter: -time.Second})
+		assert.Error(t, err)
+
+		_, err = NewRefresher(&logger.DummyLogger{}, nil, nil)
+		assert.Error(t, err)
+	})
+}
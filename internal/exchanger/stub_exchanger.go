@@ -3,6 +3,7 @@ package exchanger
 import (
 	"context"
 	"github.com/shopspring/decimal"
+	"job-backend-trainee-assignment/internal/currency"
 )
 
 type StubExchanger struct{}
@@ -16,3 +17,64 @@ func (se *StubExchanger) GetAmountInCurrency(ctx context.Context,
 	r := decimal.NewFromFloat(750.0)
 	return &r, nil
 }
+
+func (se *StubExchanger) GetConversion(ctx context.Context,
+	amount decimal.Decimal,
+	targetCurrencyName string) (conversion *Conversion, err error) {
+	amountInCurrency, err := se.GetAmountInCurrency(ctx, amount, targetCurrencyName)
+	if err != nil {
+		return nil, err
+	}
+	return &Conversion{Amount: *amountInCurrency, RatesDate: "2020-08-15"}, nil
+}
+
+//ConvertBetween converts amount by rates of GetRates
+func (se *StubExchanger) ConvertBetween(ctx context.Context, amount decimal.Decimal, sourceCurrencyName string,
+	targetCurrencyName string) (*Conversion, error) {
+	current, err := se.GetRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := &ExchangeRates{Rates: current.Rates, Base: current.Base, Date: current.Date}
+	rate, err := rates.Rate(sourceCurrencyName, targetCurrencyName)
+	if err != nil {
+		return nil, err
+	}
+
+	amountInCurrency, err := rates.Convert(amount, sourceCurrencyName, targetCurrencyName)
+	if err != nil {
+		return nil, err
+	}
+	return &Conversion{Amount: se.Currencies().Get(targetCurrencyName).Round(amountInCurrency), Rate: rate,
+		RatesDate: current.Date}, nil
+}
+
+//GetConversions converts amount of base currency by rates of GetRates
+func (se *StubExchanger) GetConversions(ctx context.Context, amount decimal.Decimal,
+	targetCurrencyNames []string) (*Conversions, error) {
+	conversions := &Conversions{RatesDate: "2020-08-15", Items: make([]ConversionItem, 0, len(targetCurrencyNames))}
+	for _, targetCurrencyName := range targetCurrencyNames {
+		item := ConversionItem{Currency: targetCurrencyName}
+		item.Conversion, item.Err = se.ConvertBetween(ctx, amount, RUBCode, targetCurrencyName)
+		conversions.Items = append(conversions.Items, item)
+	}
+	return conversions, nil
+}
+
+func (se *StubExchanger) GetRates(ctx context.Context) (*CurrentRates, error) {
+	return &CurrentRates{
+		Base:   RUBCode,
+		Date:   "2020-08-15",
+		Source: "stub",
+		Rates: map[string]decimal.Decimal{
+			USDCode: decimal.RequireFromString("0.013"),
+			EURCode: decimal.RequireFromString("0.011"),
+			"BTC":   decimal.RequireFromString("0.00000123"),
+		},
+	}, nil
+}
+
+func (se *StubExchanger) Currencies() *currency.Registry {
+	return currency.DefaultRegistry()
+}
@@ -8,9 +8,14 @@ import (
 	"fmt"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"io/ioutil"
+	"job-backend-trainee-assignment/internal/breaker"
+	"job-backend-trainee-assignment/internal/currency"
 	"job-backend-trainee-assignment/internal/logger"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -27,9 +32,9 @@ type StubRequestDoerCommon struct{}
 
 func (srd *StubRequestDoerCommon) Do(*http.Request) (*http.Response, error) {
 	body, _ := json.Marshal(&ExchangeRates{
-		Rates: map[string]float64{
-			USDCode: 0.05,
-			RUBCode: 1,
+		Rates: map[string]decimal.Decimal{
+			USDCode: decimal.RequireFromString("0.05"),
+			RUBCode: decimal.New(1, 0),
 		},
 		Base: RUBCode,
 		Date: "2020-08-15",
@@ -413,3 +418,424 @@ func TestCurrencyExchanger_WithStubRequestDoer_WithResponseBodyReadError(t *test
 		}
 	}
 }
+
+type StubRequestDoerFailingAfterFirstCall struct {
+	calls int
+}
+
+func (srd *StubRequestDoerFailingAfterFirstCall) Do(r *http.Request) (*http.Response, error) {
+	srd.calls++
+	if srd.calls > 1 {
+		return nil, fmt.Errorf("connection refused")
+	}
+	return (&StubRequestDoerCommon{}).Do(r)
+}
+
+func TestCurrencyExchanger_WithBreaker_ServesLastKnownRates(t *testing.T) {
+	t.Log("TestCurrencyExchanger_WithBreaker_ServesLastKnownRates")
+
+	doer := &StubRequestDoerFailingAfterFirstCall{}
+	ex, err := NewExchanger(&logger.DummyLogger{}, doer, RUBCode)
+	require.NoError(t, err, "NewExchanger Must return no errors")
+
+	b, err := breaker.NewBreaker("exchanger", &breaker.Config{FailureThreshold: 1, OpenTimeout: time.Hour})
+	require.NoError(t, err, "NewBreaker Must return no errors")
+	ex.SetBreaker(b)
+
+	conversion, err := ex.GetConversion(context.Background(), decimal.NewFromInt(10), USDCode)
+	require.NoError(t, err)
+	assert.False(t, conversion.Stale, "fetched rates must not be stale")
+	assert.Equal(t, "2020-08-15", conversion.RatesDate)
+
+	//rates are outdated, remote service fails, breaker opens
+	ex.cachedTime = ex.cachedTime.Add(-48 * time.Hour)
+	conversion, err = ex.GetConversion(context.Background(), decimal.NewFromInt(10), USDCode)
+	require.NoError(t, err)
+	assert.True(t, conversion.Stale, "last known rates must be marked as stale")
+	assert.True(t, conversion.Amount.Equal(decimal.NewFromFloat(0.5)), "last known rate must be used")
+	assert.Equal(t, breaker.StateOpen, b.Status().State, "breaker must be open")
+	assert.Equal(t, 2, doer.calls)
+
+	//remote service is not requested, while breaker is open
+	conversion, err = ex.GetConversion(context.Background(), decimal.NewFromInt(10), USDCode)
+	require.NoError(t, err)
+	assert.True(t, conversion.Stale, "last known rates must be marked as stale")
+	assert.Equal(t, 2, doer.calls, "remote service must not be requested")
+}
+
+func TestCurrencyExchanger_WithBreaker_NoKnownRates(t *testing.T) {
+	t.Log("TestCurrencyExchanger_WithBreaker_NoKnownRates")
+
+	ex, err := NewExchanger(&logger.DummyLogger{}, &StubRequestDoerWithBadResponseBody{}, RUBCode)
+	require.NoError(t, err, "NewExchanger Must return no errors")
+
+	b, err := breaker.NewBreaker("exchanger", &breaker.Config{FailureThreshold: 1, OpenTimeout: time.Hour})
+	require.NoError(t, err, "NewBreaker Must return no errors")
+	ex.SetBreaker(b)
+
+	_, err = ex.GetAmountInCurrency(context.Background(), decimal.NewFromInt(10), USDCode)
+	assert.ErrorIs(t, err, ErrResponseBodyReadFailed)
+
+	_, err = ex.GetAmountInCurrency(context.Background(), decimal.NewFromInt(10), USDCode)
+	assert.ErrorIs(t, err, ErrExchangeServiceIsUnavailable, "open breaker must skip request")
+}
+
+type StubRequestDoerPrecision struct{}
+
+func (srd *StubRequestDoerPrecision) Do(*http.Request) (*http.Response, error) {
+	body, _ := json.Marshal(&ExchangeRates{
+		Rates: map[string]decimal.Decimal{
+			"JPY":   decimal.RequireFromString("1.4567"),
+			"BHD":   decimal.RequireFromString("0.0051234"),
+			"BTC":   decimal.RequireFromString("0.00000123456789"),
+			USDCode: decimal.RequireFromString("0.01345"),
+		},
+		Base: RUBCode,
+		Date: "2020-08-15",
+	})
+	return &http.Response{
+		Status:     "OK",
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+func TestCurrencyExchanger_RoundsByCurrency(t *testing.T) {
+	t.Log("TestCurrencyExchanger_RoundsByCurrency")
+
+	ex, err := NewExchanger(&logger.DummyLogger{}, &StubRequestDoerPrecision{}, RUBCode)
+	require.NoError(t, err, "NewExchanger Must return no errors")
+
+	testCases := []struct {
+		caseName       string
+		targetCurrency string
+		expected       string
+	}{
+		{"JPY has no minor units", "JPY", "146"},
+		{"BHD has 3 minor units", "BHD", "0.512"},
+		{"currency missing in registry has 2 minor units", "BTC", "0"},
+		{"USD rounded half to even", USDCode, "1.34"},
+	}
+	for _, testCase := range testCases {
+		amount, err := ex.GetAmountInCurrency(context.Background(), decimal.NewFromInt(100), testCase.targetCurrency)
+		require.NoError(t, err, testCase.caseName)
+		assert.Equal(t, testCase.expected, amount.String(), testCase.caseName)
+	}
+
+	registry, err := currency.NewRegistry(currency.RoundHalfUp, currency.Currency{Code: "BTC", MinorUnits: 8,
+		Rounding: currency.RoundDown})
+	require.NoError(t, err)
+	ex.SetCurrencies(registry)
+
+	amount, err := ex.GetAmountInCurrency(context.Background(), decimal.NewFromInt(100), USDCode)
+	require.NoError(t, err)
+	assert.Equal(t, "1.35", amount.String(), "USD must be rounded half up")
+
+	amount, err = ex.GetAmountInCurrency(context.Background(), decimal.NewFromInt(100), "BTC")
+	require.NoError(t, err)
+	assert.Equal(t, "0.00012345", amount.String(), "BTC must be rounded down to 8 minor units")
+}
+
+func TestCurrencyExchanger_ConvertBetween(t *testing.T) {
+	t.Log("TestCurrencyExchanger_ConvertBetween")
+
+	ex, err := NewExchanger(&logger.DummyLogger{}, &StubRequestDoerPrecision{}, RUBCode)
+	require.NoError(t, err, "NewExchanger Must return no errors")
+
+	testCases := []struct {
+		caseName       string
+		sourceCurrency string
+		targetCurrency string
+		expected       string
+		expectedRate   string
+	}{
+		{"cross rate, rounded to target minor units", USDCode, "JPY", "10830", "108.30483271375464684"},
+		{"inverse rate", USDCode, RUBCode, "7434.94", "74.349442379182156134"},
+		{"direct rate", RUBCode, USDCode, "1.34", "0.01345"},
+		{"same currency", USDCode, USDCode, "100", "1"},
+	}
+	for _, testCase := range testCases {
+		conversion, err := ex.ConvertBetween(context.Background(), decimal.NewFromInt(100), testCase.sourceCurrency,
+			testCase.targetCurrency)
+		require.NoError(t, err, testCase.caseName)
+		assert.Equal(t, testCase.expected, conversion.Amount.String(), testCase.caseName)
+		assert.Equal(t, testCase.expectedRate, conversion.Rate.String(), testCase.caseName)
+	}
+
+	_, err = ex.ConvertBetween(context.Background(), decimal.NewFromInt(100), "XYZ", RUBCode)
+	assert.ErrorIs(t, err, ErrSourceCurrencyNameNotFound)
+
+	_, err = ex.ConvertBetween(context.Background(), decimal.NewFromInt(100), RUBCode, "XYZ")
+	assert.ErrorIs(t, err, ErrTargetCurrencyNameNotFound)
+}
+
+func TestCurrencyExchanger_GetRates(t *testing.T) {
+	t.Log("TestCurrencyExchanger_GetRates")
+
+	doer := &StubRequestDoerFailingAfterFirstCall{}
+	ex, err := NewExchanger(&logger.DummyLogger{}, doer, RUBCode)
+	require.NoError(t, err, "NewExchanger Must return no errors")
+
+	rates, err := ex.GetRates(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, RUBCode, rates.Base)
+	assert.Equal(t, "2020-08-15", rates.Date)
+	assert.Equal(t, fmt.Sprintf(StackExchangeApiURL, RUBCode), rates.Source)
+	assert.False(t, rates.Stale)
+	assert.Equal(t, "0.05", rates.Rates[USDCode].String())
+	assert.Equal(t, currency.DefaultRegistry(), ex.Currencies())
+
+	//returned rates are a copy, cached rates are not changed
+	rates.Rates[USDCode] = decimal.NewFromInt(100)
+	amount, err := ex.GetAmountInCurrency(context.Background(), decimal.NewFromInt(10), USDCode)
+	require.NoError(t, err)
+	assert.Equal(t, "0.5", amount.String())
+
+	//rates are outdated, remote service fails
+	ex.cachedTime = ex.cachedTime.Add(-48 * time.Hour)
+	rates, err = ex.GetRates(context.Background())
+	require.NoError(t, err)
+	assert.True(t, rates.Stale, "last known rates must be marked as stale")
+}
+
+type StubRequestDoerBlocking struct {
+	release chan struct{}
+	calls   int32
+}
+
+func (srd *StubRequestDoerBlocking) Do(r *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&srd.calls, 1)
+	<-srd.release
+	return (&StubRequestDoerCommon{}).Do(r)
+}
+
+func TestCurrencyExchanger_CoalescesConcurrentFetches(t *testing.T) {
+	t.Log("TestCurrencyExchanger_CoalescesConcurrentFetches")
+
+	doer := &StubRequestDoerBlocking{release: make(chan struct{})}
+	ex, err := NewExchanger(&logger.DummyLogger{}, doer, RUBCode)
+	require.NoError(t, err, "NewExchanger Must return no errors")
+
+	const callers = 10
+	wg := sync.WaitGroup{}
+	errCh := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ex.GetAmountInCurrency(context.Background(), decimal.NewFromInt(10), USDCode)
+			errCh <- err
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		ex.mu.Lock()
+		defer ex.mu.Unlock()
+		return ex.inflight != nil
+	}, time.Second, time.Millisecond, "fetch must be started")
+	time.Sleep(10 * time.Millisecond)
+	close(doer.release)
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&doer.calls), "concurrent misses must share one fetch")
+}
+
+//StubRequestDoerCancellable blocks until released or until request context is done
+type StubRequestDoerCancellable struct {
+	release chan struct{}
+	calls   int32
+}
+
+func (srd *StubRequestDoerCancellable) Do(r *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&srd.calls, 1)
+	select {
+	case <-srd.release:
+		return (&StubRequestDoerCommon{}).Do(r)
+	case <-r.Context().Done():
+		return nil, r.Context().Err()
+	}
+}
+
+func TestCurrencyExchanger_SharedFetchOutlivesCaller(t *testing.T) {
+	t.Log("TestCurrencyExchanger_SharedFetchOutlivesCaller")
+
+	t.Run("positive path, cancellation of first caller does not fail waiting caller", func(t *testing.T) {
+		doer := &StubRequestDoerCancellable{release: make(chan struct{})}
+		ex, err := NewExchanger(&logger.DummyLogger{}, doer, RUBCode)
+		require.NoError(t, err, "NewExchanger Must return no errors")
+
+		firstCtx, cancelFirst := context.WithCancel(context.Background())
+		firstErrCh := make(chan error, 1)
+		go func() {
+			_, err := ex.GetRates(firstCtx)
+			firstErrCh <- err
+		}()
+		require.Eventually(t, func() bool {
+			return atomic.LoadInt32(&doer.calls) == 1
+		}, time.Second, time.Millisecond, "fetch must be started")
+
+		waiterCh := make(chan *CurrentRates, 1)
+		waiterErrCh := make(chan error, 1)
+		go func() {
+			rates, err := ex.GetRates(context.Background())
+			waiterCh <- rates
+			waiterErrCh <- err
+		}()
+
+		cancelFirst()
+		assert.ErrorIs(t, <-firstErrCh, context.Canceled, "first caller must get its own context error")
+
+		close(doer.release)
+		require.NoError(t, <-waiterErrCh, "waiting caller must get fetched rates")
+		rates := <-waiterCh
+		assert.False(t, rates.Stale, "rates must be fresh")
+		assert.Equal(t, "0.05", rates.Rates[USDCode].String())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&doer.calls), "callers must share one fetch")
+	})
+
+	t.Run("negative path, fetch is stopped by its own timeout", func(t *testing.T) {
+		doer := &StubRequestDoerCancellable{release: make(chan struct{})}
+		ex, err := NewExchanger(&logger.DummyLogger{}, doer, RUBCode)
+		require.NoError(t, err, "NewExchanger Must return no errors")
+		ex.SetFetchTimeout(20 * time.Millisecond)
+
+		_, err = ex.GetRates(context.Background())
+		assert.ErrorIs(t, err, ErrRequestDoerError, "fetch must be stopped by timeout")
+
+		ex.mu.Lock()
+		defer ex.mu.Unlock()
+		assert.Nil(t, ex.inflight, "stopped fetch must not be shared")
+	})
+}
+
+func TestCurrencyExchanger_FreshnessByFetchTime(t *testing.T) {
+	t.Log("TestCurrencyExchanger_FreshnessByFetchTime")
+
+	doer := &StubRequestDoerFailingAfterFirstCall{}
+	ex, err := NewExchanger(&logger.DummyLogger{}, doer, RUBCode)
+	require.NoError(t, err, "NewExchanger Must return no errors")
+
+	//rates date is years old, but rates are just fetched
+	for i := 0; i < 3; i++ {
+		conversion, err := ex.GetConversion(context.Background(), decimal.NewFromInt(10), USDCode)
+		require.NoError(t, err)
+		assert.False(t, conversion.Stale)
+		assert.Equal(t, "2020-08-15", conversion.RatesDate)
+	}
+	assert.Equal(t, 1, doer.calls, "fresh rates must not be fetched again")
+
+	ex.SetMaxRatesAge(time.Minute)
+	ex.cachedTime = time.Now().Add(-2 * time.Minute)
+	conversion, err := ex.GetConversion(context.Background(), decimal.NewFromInt(10), USDCode)
+	require.NoError(t, err)
+	assert.True(t, conversion.Stale, "rates older than max age must be fetched")
+	assert.Equal(t, 2, doer.calls)
+
+	err = ex.Refresh(context.Background())
+	assert.Error(t, err, "failed refresh must return error")
+	assert.Equal(t, 3, doer.calls, "refresh must fetch rates regardless of their age")
+}
+
+//StubRequestDoerPivot supports EUR as base currency only, rates of USD do not include RUB
+type StubRequestDoerPivot struct {
+	bases []string
+}
+
+func (srd *StubRequestDoerPivot) Do(r *http.Request) (*http.Response, error) {
+	base := r.URL.Query().Get("base")
+	srd.bases = append(srd.bases, base)
+
+	var body []byte
+	switch base {
+	case EURCode:
+		body, _ = json.Marshal(&ExchangeRates{
+			Rates: map[string]decimal.Decimal{
+				RUBCode: decimal.RequireFromString("86.1234"),
+				USDCode: decimal.RequireFromString("1.1812"),
+				"JPY":   decimal.RequireFromString("125.92"),
+			},
+			Base: EURCode,
+			Date: "2020-08-15",
+		})
+	case USDCode:
+		body, _ = json.Marshal(&ExchangeRates{
+			Rates: map[string]decimal.Decimal{EURCode: decimal.RequireFromString("0.8466")},
+			Base:  USDCode,
+			Date:  "2020-08-15",
+		})
+	default:
+		return &http.Response{
+			Status:     "Bad Request",
+			StatusCode: http.StatusBadRequest,
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(fmt.Sprintf(`{"error":"Base '%s' is not supported."}`, base)))),
+		}, nil
+	}
+	return &http.Response{
+		Status:     "OK",
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+func TestCurrencyExchanger_WithPivotCurrency(t *testing.T) {
+	t.Log("TestCurrencyExchanger_WithPivotCurrency")
+
+	doer := &StubRequestDoerPivot{}
+	ex, err := NewExchanger(&logger.DummyLogger{}, doer, RUBCode)
+	require.NoError(t, err, "NewExchanger Must return no errors")
+	ex.SetPivotCurrencies(USDCode, EURCode)
+
+	conversion, err := ex.GetConversion(context.Background(), decimal.NewFromInt(1000), USDCode)
+	require.NoError(t, err)
+	assert.Equal(t, "13.72", conversion.Amount.String())
+	assert.Equal(t, "0.013715203997984288", conversion.Rate.String(), "cross rate must keep 18 digits")
+	assert.Equal(t, EURCode, conversion.Pivot, "pivot currency must be recorded")
+	assert.Equal(t, []string{RUBCode, USDCode, EURCode}, doer.bases,
+		"pivot currency, which rates do not include base currency, must be skipped")
+
+	conversion, err = ex.ConvertBetween(context.Background(), decimal.NewFromInt(100), USDCode, RUBCode)
+	require.NoError(t, err)
+	assert.Equal(t, "7291.18", conversion.Amount.String())
+
+	rates, err := ex.GetRates(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, RUBCode, rates.Base, "rates must be given against base currency")
+	assert.Equal(t, EURCode, rates.Pivot)
+	assert.Equal(t, fmt.Sprintf(StackExchangeApiURL, EURCode), rates.Source)
+	assert.Equal(t, "0.011611246188608439", rates.Rates[EURCode].String())
+	assert.Equal(t, "1.462088120069574587", rates.Rates["JPY"].String())
+	assert.Equal(t, "1", rates.Rates[RUBCode].String())
+
+	//supported pivot currency is requested first
+	err = ex.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{RUBCode, USDCode, EURCode, EURCode}, doer.bases)
+
+	ex, err = NewExchanger(&logger.DummyLogger{}, &StubRequestDoerPivot{}, RUBCode)
+	require.NoError(t, err, "NewExchanger Must return no errors")
+	ex.SetPivotCurrencies(USDCode)
+	_, err = ex.GetConversion(context.Background(), decimal.NewFromInt(1000), USDCode)
+	assert.ErrorIs(t, err, ErrBaseCurrencyNameNotFound, "unsupported pivot currencies must fail")
+}
+
+func TestCurrencyExchanger_GetConversions(t *testing.T) {
+	t.Log("TestCurrencyExchanger_GetConversions")
+
+	doer := &StubRequestDoerFailingAfterFirstCall{}
+	ex, err := NewExchanger(&logger.DummyLogger{}, doer, RUBCode)
+	require.NoError(t, err, "NewExchanger Must return no errors")
+
+	conversions, err := ex.GetConversions(context.Background(), decimal.NewFromInt(10), []string{USDCode, RUBCode, "XYZ"})
+	require.NoError(t, err)
+	assert.Equal(t, "2020-08-15", conversions.RatesDate)
+	assert.False(t, conversions.Stale)
+	require.Len(t, conversions.Items, 3)
+	assert.Equal(t, "0.5", conversions.Items[0].Conversion.Amount.String())
+	assert.Equal(t, "10", conversions.Items[1].Conversion.Amount.String())
+	assert.ErrorIs(t, conversions.Items[2].Err, ErrTargetCurrencyNameNotFound, "unknown currency must fail its item")
+	assert.Equal(t, 1, doer.calls, "rates must be fetched once")
+}
@@ -0,0 +1,192 @@
+package fees
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+)
+
+//OperationType is a type of operation, fee is charged for
+type OperationType string
+
+const (
+	OperationTransfer OperationType = "transfer"
+	OperationWithdraw OperationType = "withdraw"
+)
+
+//DefaultTier is a tier of users, which are not assigned to any tier
+const DefaultTier = "standard"
+
+var hundred = decimal.NewFromInt(100)
+
+//Rule gives fee of operations of one type, amount range and user tier.
+//Fee is a percentage of operation amount plus flat fee, limited by min and max fee
+type Rule struct {
+	OperationType OperationType
+	//Tier of user, rule matches users of any tier if it is empty
+	Tier string
+	//MinAmount is an inclusive lower bound of operation amount
+	MinAmount decimal.Decimal
+	//MaxAmount is an exclusive upper bound of operation amount, zero means no bound
+	MaxAmount decimal.Decimal
+	//Percent of operation amount, e.g. 1.5 is 1.5%
+	Percent decimal.Decimal
+	Flat    decimal.Decimal
+	MinFee  decimal.Decimal
+	//MaxFee is a max fee, zero means no limit
+	MaxFee decimal.Decimal
+}
+
+//Validate checks rule settings
+func (r Rule) Validate() error {
+	if r.OperationType != OperationTransfer && r.OperationType != OperationWithdraw {
+		return fmt.Errorf("fee rule operation type must be %s or %s, got %q", OperationTransfer, OperationWithdraw,
+			r.OperationType)
+	}
+	if r.MinAmount.IsNegative() || r.MaxAmount.IsNegative() {
+		return fmt.Errorf("fee rule of %s, amount bounds must not be negative", r.OperationType)
+	}
+	if r.MaxAmount.IsPositive() && r.MaxAmount.LessThanOrEqual(r.MinAmount) {
+		return fmt.Errorf("fee rule of %s, max amount must be greater than min amount", r.OperationType)
+	}
+	if r.Percent.IsNegative() || r.Percent.GreaterThan(hundred) {
+		return fmt.Errorf("fee rule of %s, percent must be in [0, 100]", r.OperationType)
+	}
+	if r.Flat.IsNegative() || r.MinFee.IsNegative() || r.MaxFee.IsNegative() {
+		return fmt.Errorf("fee rule of %s, flat, min and max fees must not be negative", r.OperationType)
+	}
+	if r.MaxFee.IsPositive() && r.MaxFee.LessThan(r.MinFee) {
+		return fmt.Errorf("fee rule of %s, max fee must not be less than min fee", r.OperationType)
+	}
+	return nil
+}
+
+//Matches checks that rule is applied to operation of user tier
+func (r Rule) Matches(operationType OperationType, tier string, amount decimal.Decimal) bool {
+	if r.OperationType != operationType || (r.Tier != "" && r.Tier != tier) {
+		return false
+	}
+	if amount.LessThan(r.MinAmount) {
+		return false
+	}
+	return !r.MaxAmount.IsPositive() || amount.LessThan(r.MaxAmount)
+}
+
+//Fee returns fee of amount, it is not rounded to minor units of currency
+func (r Rule) Fee(amount decimal.Decimal) decimal.Decimal {
+	fee := amount.Mul(r.Percent).Div(hundred).Add(r.Flat)
+	if fee.LessThan(r.MinFee) {
+		fee = r.MinFee
+	}
+	if r.MaxFee.IsPositive() && fee.GreaterThan(r.MaxFee) {
+		fee = r.MaxFee
+	}
+	return fee
+}
+
+//Schedule keeps fee rules and tiers of users, it is not changed after creation and may be used concurrently
+type Schedule struct {
+	rules []Rule
+	tiers map[int64]string
+}
+
+//NewSchedule creates schedule of rules, first matching rule gives fee of operation.
+//Users of userTiers are assigned to tiers by tier name, other users have default tier
+func NewSchedule(rules []Rule, userTiers map[string][]int64) (*Schedule, error) {
+	s := &Schedule{rules: make([]Rule, 0, len(rules)), tiers: make(map[int64]string)}
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+		s.rules = append(s.rules, rule)
+	}
+
+	for tier, userIds := range userTiers {
+		for _, userId := range userIds {
+			if assigned, ok := s.tiers[userId]; ok && assigned != tier {
+				return nil, fmt.Errorf("user %d is assigned to tiers %s and %s", userId, assigned, tier)
+			}
+			s.tiers[userId] = tier
+		}
+	}
+	return s, nil
+}
+
+//Empty checks that schedule has no rules, so operations have no fees
+func (s *Schedule) Empty() bool {
+	return len(s.rules) == 0
+}
+
+//Tier returns tier of user
+func (s *Schedule) Tier(userId int64) string {
+	if tier, ok := s.tiers[userId]; ok {
+		return tier
+	}
+	return DefaultTier
+}
+
+//Fee returns fee of operation of user by first matching rule, operation without matching rule has no fee
+func (s *Schedule) Fee(operationType OperationType, userId int64, amount decimal.Decimal) decimal.Decimal {
+	tier := s.Tier(userId)
+	for _, rule := range s.rules {
+		if rule.Matches(operationType, tier, amount) {
+			return rule.Fee(amount)
+		}
+	}
+	return decimal.Zero
+}
+
+//RuleConfig is a fee rule of config file, amounts are decimal numbers, empty amount is zero
+type RuleConfig struct {
+	OperationType string `mapstructure:"operation_type"`
+	Tier          string `mapstructure:"tier"`
+	MinAmount     string `mapstructure:"min_amount"`
+	MaxAmount     string `mapstructure:"max_amount"`
+	Percent       string `mapstructure:"percent"`
+	Flat          string `mapstructure:"flat"`
+	MinFee        string `mapstructure:"min_fee"`
+	MaxFee        string `mapstructure:"max_fee"`
+}
+
+//TierConfig assigns users of config file to tier
+type TierConfig struct {
+	Tier    string  `mapstructure:"tier"`
+	UserIds []int64 `mapstructure:"user_ids"`
+}
+
+//NewScheduleFromConfig creates schedule of rules and tiers of config file
+func NewScheduleFromConfig(ruleConfigs []RuleConfig, tierConfigs []TierConfig) (*Schedule, error) {
+	rules := make([]Rule, 0, len(ruleConfigs))
+	for i, rc := range ruleConfigs {
+		rule := Rule{OperationType: OperationType(rc.OperationType), Tier: rc.Tier}
+		amounts := []struct {
+			name  string
+			value string
+			dst   *decimal.Decimal
+		}{
+			{"min_amount", rc.MinAmount, &rule.MinAmount}, {"max_amount", rc.MaxAmount, &rule.MaxAmount},
+			{"percent", rc.Percent, &rule.Percent}, {"flat", rc.Flat, &rule.Flat},
+			{"min_fee", rc.MinFee, &rule.MinFee}, {"max_fee", rc.MaxFee, &rule.MaxFee},
+		}
+		for _, amount := range amounts {
+			if amount.value == "" {
+				continue
+			}
+
+			value, err := decimal.NewFromString(amount.value)
+			if err != nil {
+				return nil, fmt.Errorf("fee rule %d, %s must be decimal number, err %v", i, amount.name, err)
+			}
+			*amount.dst = value
+		}
+		rules = append(rules, rule)
+	}
+
+	userTiers := make(map[string][]int64, len(tierConfigs))
+	for _, tc := range tierConfigs {
+		if tc.Tier == "" {
+			return nil, fmt.Errorf("tier name must not be empty")
+		}
+		userTiers[tc.Tier] = append(userTiers[tc.Tier], tc.UserIds...)
+	}
+	return NewSchedule(rules, userTiers)
+}
@@ -0,0 +1,99 @@
+package fees
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRule_Fee(t *testing.T) {
+	testCases := []struct {
+		caseName string
+		rule     Rule
+		amount   string
+		expected string
+	}{
+		{"percent", Rule{Percent: decimal.RequireFromString("1.5")}, "1000", "15"},
+		{"percent and flat", Rule{Percent: decimal.NewFromInt(1), Flat: decimal.NewFromInt(10)}, "1000", "20"},
+		{"flat", Rule{Flat: decimal.NewFromInt(30)}, "5", "30"},
+		{"min fee", Rule{Percent: decimal.NewFromInt(1), MinFee: decimal.NewFromInt(5)}, "100", "5"},
+		{"max fee", Rule{Percent: decimal.NewFromInt(1), MaxFee: decimal.NewFromInt(50)}, "10000", "50"},
+		{"not rounded", Rule{Percent: decimal.RequireFromString("1.5")}, "10.01", "0.15015"},
+	}
+
+	for _, testCase := range testCases {
+		amount := decimal.RequireFromString(testCase.amount)
+		assert.Equal(t, testCase.expected, testCase.rule.Fee(amount).String(), testCase.caseName)
+	}
+}
+
+func TestSchedule_Fee(t *testing.T) {
+	schedule, err := NewSchedule([]Rule{
+		{OperationType: OperationTransfer, Tier: "premium"},
+		{OperationType: OperationTransfer, MaxAmount: decimal.NewFromInt(1000), Flat: decimal.NewFromInt(10)},
+		{OperationType: OperationTransfer, MinAmount: decimal.NewFromInt(1000), Percent: decimal.NewFromInt(1),
+			MaxFee: decimal.NewFromInt(100)},
+		{OperationType: OperationWithdraw, Flat: decimal.NewFromInt(5)},
+	}, map[string][]int64{"premium": {7}})
+	require.NoError(t, err)
+	assert.False(t, schedule.Empty())
+
+	assert.Equal(t, "premium", schedule.Tier(7))
+	assert.Equal(t, DefaultTier, schedule.Tier(1))
+
+	fee := func(operationType OperationType, userId int64, amount string) string {
+		return schedule.Fee(operationType, userId, decimal.RequireFromString(amount)).String()
+	}
+	assert.Equal(t, "0", fee(OperationTransfer, 7, "5000"), "first matching rule must be applied")
+	assert.Equal(t, "10", fee(OperationTransfer, 1, "999.99"))
+	assert.Equal(t, "10", fee(OperationTransfer, 1, "1000"), "min amount is inclusive, max amount is exclusive")
+	assert.Equal(t, "100", fee(OperationTransfer, 1, "50000"))
+	assert.Equal(t, "5", fee(OperationWithdraw, 7, "1"))
+
+	empty, err := NewSchedule(nil, nil)
+	require.NoError(t, err)
+	assert.True(t, empty.Empty())
+	assert.Equal(t, "0", empty.Fee(OperationWithdraw, 1, decimal.NewFromInt(100)).String())
+}
+
+func TestNewSchedule_Validation(t *testing.T) {
+	testCases := []struct {
+		caseName string
+		rule     Rule
+	}{
+		{"unknown operation type", Rule{OperationType: "credit"}},
+		{"negative amount bound", Rule{OperationType: OperationTransfer, MinAmount: decimal.NewFromInt(-1)}},
+		{"empty amount range", Rule{OperationType: OperationTransfer, MinAmount: decimal.NewFromInt(10),
+			MaxAmount: decimal.NewFromInt(10)}},
+		{"percent over 100", Rule{OperationType: OperationTransfer, Percent: decimal.NewFromInt(101)}},
+		{"negative flat fee", Rule{OperationType: OperationWithdraw, Flat: decimal.NewFromInt(-1)}},
+		{"max fee less than min fee", Rule{OperationType: OperationWithdraw, MinFee: decimal.NewFromInt(10),
+			MaxFee: decimal.NewFromInt(5)}},
+	}
+
+	for _, testCase := range testCases {
+		_, err := NewSchedule([]Rule{testCase.rule}, nil)
+		assert.Error(t, err, testCase.caseName)
+	}
+
+	_, err := NewSchedule(nil, map[string][]int64{"premium": {1}, "gold": {1}})
+	assert.Error(t, err, "user must be assigned to one tier")
+}
+
+func TestNewScheduleFromConfig(t *testing.T) {
+	schedule, err := NewScheduleFromConfig([]RuleConfig{
+		{OperationType: "transfer", Tier: "premium", Percent: "0.5"},
+		{OperationType: "transfer", Percent: "1", MinFee: "5", MaxFee: "50"},
+	}, []TierConfig{{Tier: "premium", UserIds: []int64{7}}})
+	require.NoError(t, err)
+	assert.Equal(t, "premium", schedule.Tier(7))
+	assert.Equal(t, "10", schedule.Fee(OperationTransfer, 7, decimal.NewFromInt(2000)).String())
+	assert.Equal(t, "20", schedule.Fee(OperationTransfer, 1, decimal.NewFromInt(2000)).String())
+
+	_, err = NewScheduleFromConfig([]RuleConfig{{OperationType: "transfer", Flat: "ten"}}, nil)
+	assert.Error(t, err, "amount must be decimal number")
+
+	_, err = NewScheduleFromConfig(nil, []TierConfig{{UserIds: []int64{1}}})
+	assert.Error(t, err, "tier name must not be empty")
+}
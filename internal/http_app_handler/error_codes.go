@@ -0,0 +1,65 @@
+package http_app_handler
+
+import (
+	"errors"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/scheduler"
+)
+
+const (
+	CodeJsonMarshalFailed      app.ErrorCode = "JSON_MARSHAL_FAILED"
+	CodeJsonUnmarshalFailed    app.ErrorCode = "JSON_UNMARSHAL_FAILED"
+	CodeResponseWriteFailed    app.ErrorCode = "RESPONSE_WRITE_FAILED"
+	CodeRequestBodyReadFailed  app.ErrorCode = "REQUEST_BODY_READ_FAILED"
+	CodeUnsupportedContentType app.ErrorCode = "UNSUPPORTED_CONTENT_TYPE"
+	CodeUnsupportedMethod      app.ErrorCode = "UNSUPPORTED_METHOD"
+	CodeUnknownInternalError   app.ErrorCode = "UNKNOWN_INTERNAL_ERROR"
+	CodeAmbiguousResponseBody  app.ErrorCode = "AMBIGUOUS_RESPONSE_BODY"
+	CodeRequestTimeout         app.ErrorCode = "REQUEST_TIMEOUT"
+	CodeBadHttpCodeToResponse  app.ErrorCode = "BAD_RESPONSE_HTTP_CODE"
+	CodeBadPathParam           app.ErrorCode = "BAD_PATH_PARAM"
+	CodeBadQueryParam          app.ErrorCode = "BAD_QUERY_PARAM"
+	CodeRateLimitExceeded      app.ErrorCode = "RATE_LIMIT_EXCEEDED"
+)
+
+var handlerErrorCatalogue = []app.ErrorCatalogueEntry{
+	{Err: ErrJsonMarshalFailed, Code: CodeJsonMarshalFailed, Title: "Response json marshal failed"},
+	{Err: ErrJsonUnmarshalFailed, Code: CodeJsonUnmarshalFailed, Title: "Request json unmarshal failed"},
+	{Err: ErrResponseWriteFailed, Code: CodeResponseWriteFailed, Title: "Response write failed"},
+	{Err: ErrRequestBodyReadFailed, Code: CodeRequestBodyReadFailed, Title: "Request body read failed"},
+	{Err: ErrUnsupportedContentType, Code: CodeUnsupportedContentType, Title: "Unsupported content type"},
+	{Err: ErrUnsupportedMethod, Code: CodeUnsupportedMethod, Title: "Unsupported method"},
+	{Err: ErrUnknownError, Code: CodeUnknownInternalError, Title: "Unknown internal error"},
+	{Err: ErrAmbiguousResponseBody, Code: CodeAmbiguousResponseBody, Title: "Ambiguous response body"},
+	{Err: ErrRequestTimeout, Code: CodeRequestTimeout, Title: "Request timeout"},
+	{Err: ErrBadHttpCodeToResponse, Code: CodeBadHttpCodeToResponse, Title: "Bad response http code"},
+	{Err: ErrBadPathParam, Code: CodeBadPathParam, Title: "Bad path param"},
+	{Err: ErrBadQueryParam, Code: CodeBadQueryParam, Title: "Bad query param"},
+	{Err: ErrRateLimitExceeded, Code: CodeRateLimitExceeded, Title: "Rate limit exceeded"},
+}
+
+// ErrorCatalogue returns all errors, known to api clients: errors of http handler, app and scheduler
+func ErrorCatalogue() []app.ErrorCatalogueEntry {
+	catalogue := make([]app.ErrorCatalogueEntry, 0, len(handlerErrorCatalogue))
+	catalogue = append(catalogue, handlerErrorCatalogue...)
+	catalogue = append(catalogue, app.ErrorCatalogue()...)
+	return append(catalogue, scheduler.ErrorCatalogue()...)
+}
+
+// LookupErrorCode returns catalogue entry of given handler, app or scheduler error
+func LookupErrorCode(err error) app.ErrorCatalogueEntry {
+	for _, e := range handlerErrorCatalogue {
+		if errors.Is(err, e.Err) {
+			return e
+		}
+	}
+
+	for _, e := range scheduler.ErrorCatalogue() {
+		if errors.Is(err, e.Err) {
+			return e
+		}
+	}
+
+	entry, _ := app.LookupErrorCode(err)
+	return entry
+}
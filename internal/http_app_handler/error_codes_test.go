@@ -0,0 +1,49 @@
+package http_app_handler
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"testing"
+)
+
+type swaggerSpec struct {
+	Definitions map[string]struct {
+		Properties map[string]struct {
+			Enum []string `json:"enum"`
+		} `json:"properties"`
+	} `json:"definitions"`
+}
+
+//test intended to check that error codes in swagger specs match error catalogue
+func TestErrorCatalogue_MatchesSwaggerSpec(t *testing.T) {
+	catalogueCodes := make([]string, 0)
+	knownCodes := make(map[string]bool)
+	for _, entry := range ErrorCatalogue() {
+		assert.Falsef(t, knownCodes[string(entry.Code)], "error code %s must be unique", entry.Code)
+		knownCodes[string(entry.Code)] = true
+		catalogueCodes = append(catalogueCodes, string(entry.Code))
+	}
+
+	testCases := []struct {
+		specPath   string
+		definition string
+	}{
+		{"../../docs/swagger.json", "ErrorResponseBody"},
+		{"../../docs/v2/swagger.json", "ProblemResponseBody"},
+		{"../../docs/v2/swagger.json", "ProblemTypeDescription"},
+	}
+
+	for _, tc := range testCases {
+		b, err := ioutil.ReadFile(tc.specPath)
+		require.NoErrorf(t, err, "must be able to read swagger spec %s", tc.specPath)
+
+		spec := &swaggerSpec{}
+		err = json.Unmarshal(b, spec)
+		require.NoErrorf(t, err, "must be able to unmarshal swagger spec %s", tc.specPath)
+
+		assert.Equalf(t, catalogueCodes, spec.Definitions[tc.definition].Properties["code"].Enum,
+			"%s code enum in %s must match error catalogue", tc.definition, tc.specPath)
+	}
+}
@@ -13,4 +13,7 @@ var (
 	ErrAmbiguousResponseBody  = errors.New("got ambiguous response data to send")
 	ErrRequestTimeout         = errors.New("request processing timeout exceeded")
 	ErrBadHttpCodeToResponse  = errors.New("got invalid http code value to respond")
+	ErrBadPathParam           = errors.New("request path contains invalid param value")
+	ErrBadQueryParam          = errors.New("request query contains invalid param value")
+	ErrRateLimitExceeded      = errors.New("rate limit of requests is exceeded, request must be retried later")
 )
@@ -303,9 +303,7 @@ func TestAppHttpHandler_WithAppIntegration_WithStubExchanger(t *testing.T) {
 					UserId: 100,
 				},
 				RespStatus: http.StatusBadRequest,
-				RespBody: &ErrorResponseBody{
-					Error: app.ErrUserDoesNotExist.Error(),
-				},
+				RespBody: NewErrorResponseBody(app.ErrUserDoesNotExist),
 			},
 			{
 				CaseName:       "negative path, yet nonexistent user, Withdraw account",
@@ -319,9 +317,7 @@ func TestAppHttpHandler_WithAppIntegration_WithStubExchanger(t *testing.T) {
 					IdempotencyToken: uuid.NewV4().String(),
 				},
 				RespStatus: http.StatusBadRequest,
-				RespBody: &ErrorResponseBody{
-					Error: app.ErrUserDoesNotExist.Error(),
-				},
+				RespBody: NewErrorResponseBody(app.ErrUserDoesNotExist),
 			},
 			{
 				CaseName:       "positive path, nonexistent user, CreditUserAccount, new user creating",
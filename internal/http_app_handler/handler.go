@@ -3,8 +3,13 @@ package http_app_handler
 import (
 	"fmt"
 	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/breaker"
+	"job-backend-trainee-assignment/internal/exchanger"
 	"job-backend-trainee-assignment/internal/http_handler_router"
 	"job-backend-trainee-assignment/internal/logger"
+	"job-backend-trainee-assignment/internal/ratelimit"
+	"job-backend-trainee-assignment/internal/scheduler"
+	"job-backend-trainee-assignment/internal/statement_export"
 	"net/http"
 	"sync"
 	"time"
@@ -12,18 +17,35 @@ import (
 
 type Config struct {
 	RequestHandleTimeout time.Duration
+	//ExportPageSize is a number of operations, fetched at once on statement export
+	ExportPageSize int64
+	//RateLimitPerSecond is a steady rate of requests of one client address, zero disables limits
+	RateLimitPerSecond float64
+	//RateLimitBurst is a max number of requests of one client address at once, zero means requests of one second
+	RateLimitBurst int
 }
 
 type AppHttpHandler struct {
-	logger logger.ILogger
-	app    app.IBillingApp
-	router router.IRouter
-	cfg    *Config
-	mu     sync.Mutex
+	logger         logger.ILogger
+	app            app.IBillingApp
+	scheduler      scheduler.IScheduler
+	exporter       *statement_export.Exporter
+	limiter        *ratelimit.Limiter
+	configProvider IConfigProvider
+	breakers       []*breaker.Breaker
+	ratesProvider  exchanger.IRatesProvider
+	//currencyResponses are cached responses of currency routes by path
+	currencyResponses map[string]*cachedResponse
+	currencyCacheTTL  time.Duration
+	router            router.IRouter
+	//handler passes requests, allowed by rate limit, to router
+	handler http.HandlerFunc
+	cfg     *Config
+	mu      sync.Mutex
 }
 
 func (h *AppHttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h.router.ServeHTTP(w, r)
+	h.handler(w, r)
 }
 
 const (
@@ -38,6 +60,46 @@ const (
 	pathMethodGetOperationLog   = "/operations"
 )
 
+// paths of resource-oriented api v2, user identifier is passed as ":id" path param
+const (
+	pathPrefixV2         = "/v2/"
+	pathParamUserId      = "id"
+	pathParamProblemCode = "code"
+	pathParamScheduleId  = "id"
+	pathParamDate        = "date"
+	pathParamQuoteId     = "id"
+
+	pathV2GetProblemTypes = "/v2/problems"
+	pathV2GetProblemType  = "/v2/problems/:code"
+
+	pathV2GetUserBalance       = "/v2/users/:id/balance"
+	pathV2GetUserOperations    = "/v2/users/:id/operations"
+	pathV2CreditUserAccount    = "/v2/users/:id/credits"
+	pathV2WithdrawUserAccount  = "/v2/users/:id/withdrawals"
+	pathV2TransferUserMoney    = "/v2/users/:id/transfers"
+	pathV2GetUserBalanceOnDate = "/v2/users/:id/balance/:date"
+	pathV2GetUserStatement     = "/v2/users/:id/statement"
+	pathV2ExportUserStatement  = "/v2/users/:id/statement/export"
+	pathV2GrantUserBonus       = "/v2/users/:id/bonuses"
+	pathV2GetUserBonuses       = "/v2/users/:id/bonuses"
+
+	pathV2CreateUserSchedule = "/v2/users/:id/schedules"
+	pathV2GetUserSchedules   = "/v2/users/:id/schedules"
+	pathV2GetSchedule        = "/v2/schedules/:id"
+	pathV2CancelSchedule     = "/v2/schedules/:id"
+	pathV2GetScheduleRuns    = "/v2/schedules/:id/runs"
+
+	pathV2GetCurrencies = "/v2/currencies"
+	pathV2GetRates      = "/v2/rates"
+
+	pathV2CreateQuote = "/v2/quotes"
+	pathV2GetQuote    = "/v2/quotes/:id"
+
+	pathPrefixAdminV2        = "/v2/admin/"
+	pathV2GetEffectiveConfig = "/v2/admin/config"
+	pathV2GetHealth          = "/v2/admin/health"
+)
+
 func NewHttpAppHandler(logger logger.ILogger, router router.IRouter, app app.IBillingApp, cfg *Config) (*AppHttpHandler, error) {
 
 	if logger == nil {
@@ -57,13 +119,31 @@ func NewHttpAppHandler(logger logger.ILogger, router router.IRouter, app app.IBi
 		cfg = &Config{RequestHandleTimeout: 5 * time.Second}
 	}
 
+	var exporterCfg *statement_export.Config
+	if cfg.ExportPageSize != 0 {
+		exporterCfg = &statement_export.Config{PageSize: cfg.ExportPageSize}
+	}
+
+	exporter, err := statement_export.NewExporter(logger, app, exporterCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create statement exporter, %v", err)
+	}
+
+	limiter, err := ratelimit.NewLimiter(cfg.rateLimit())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rate limiter, %v", err)
+	}
+
 	h := &AppHttpHandler{
-		logger: logger,
-		app:    app,
-		router: router,
-		cfg:    cfg,
+		logger:   logger,
+		app:      app,
+		exporter: exporter,
+		limiter:  limiter,
+		router:   router,
+		cfg:      cfg,
 	}
 
+	h.handler = h.RateLimitMW(h.router.ServeHTTP)
 	h.router.SetMethodNotAllowedHandler(h.MethodNotAllowedHandler)
 
 	HandlerGetUserBalance := h.AccessLogMW(
@@ -87,5 +167,28 @@ func NewHttpAppHandler(logger logger.ILogger, router router.IRouter, app app.IBi
 	h.router.HandlerFunc(http.MethodPost, pathMethodTransferUserMoney, HandlerTransferUserMoney)
 	h.router.HandlerFunc(http.MethodPost, pathMethodGetOperationLog, HandlerGetUserOperationsLog)
 
+	h.router.HandlerFunc(http.MethodGet, pathV2GetProblemTypes, h.AccessLogMW(h.HandlerV2GetProblemTypes))
+	h.router.HandlerFunc(http.MethodGet, pathV2GetProblemType, h.AccessLogMW(h.HandlerV2GetProblemType))
+	h.router.HandlerFunc(http.MethodGet, pathV2GetUserBalance, h.AccessLogMW(h.HandlerV2GetUserBalance))
+	h.router.HandlerFunc(http.MethodGet, pathV2GetUserOperations, h.AccessLogMW(h.HandlerV2GetUserOperations))
+	h.router.HandlerFunc(http.MethodGet, pathV2GetUserBalanceOnDate, h.AccessLogMW(h.HandlerV2GetUserBalanceOnDate))
+	h.router.HandlerFunc(http.MethodGet, pathV2GetUserStatement, h.AccessLogMW(h.HandlerV2GetUserStatement))
+	h.router.HandlerFunc(http.MethodGet, pathV2ExportUserStatement, h.AccessLogMW(h.HandlerV2ExportUserStatement))
+
+	h.router.HandlerFunc(http.MethodPost, pathV2CreditUserAccount, h.AccessLogMW(
+		h.ContentTypeValidationMW(h.HandlerV2CreditUserAccount, contentTypeApplicationJson)))
+	h.router.HandlerFunc(http.MethodPost, pathV2WithdrawUserAccount, h.AccessLogMW(
+		h.ContentTypeValidationMW(h.HandlerV2WithdrawUserAccount, contentTypeApplicationJson)))
+	h.router.HandlerFunc(http.MethodPost, pathV2TransferUserMoney, h.AccessLogMW(
+		h.ContentTypeValidationMW(h.HandlerV2TransferUserMoney, contentTypeApplicationJson)))
+
+	h.router.HandlerFunc(http.MethodPost, pathV2GrantUserBonus, h.AccessLogMW(
+		h.ContentTypeValidationMW(h.HandlerV2GrantUserBonus, contentTypeApplicationJson)))
+	h.router.HandlerFunc(http.MethodGet, pathV2GetUserBonuses, h.AccessLogMW(h.HandlerV2GetUserBonuses))
+
+	h.router.HandlerFunc(http.MethodPost, pathV2CreateQuote, h.AccessLogMW(
+		h.ContentTypeValidationMW(h.HandlerV2CreateQuote, contentTypeApplicationJson)))
+	h.router.HandlerFunc(http.MethodGet, pathV2GetQuote, h.AccessLogMW(h.HandlerV2GetQuote))
+
 	return h, nil
 }
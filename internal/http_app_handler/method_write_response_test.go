@@ -1,9 +1,11 @@
 package http_app_handler
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
+	"job-backend-trainee-assignment/internal/app"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -40,3 +42,59 @@ func TestWriteResponseMethod(t *testing.T) {
 
 	}
 }
+
+func TestWriteErrorResponseMethod(t *testing.T) {
+	t.Logf("Given the need to test WriteErrorResponse Helper")
+	{
+		t.Run("testing v1 path, client does not accept problem details", func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, pathMethodGetUserBalance, nil)
+
+			err := WriteErrorResponse(rr, req, app.ErrUserDoesNotExist, http.StatusBadRequest)
+			require.NoError(t, err, "must get no errors")
+
+			responseBody, err := ioutil.ReadAll(rr.Body)
+			require.NoError(t, err)
+
+			assert.JSONEq(t, `{"error":"user with specified id does not exist","code":"USER_NOT_FOUND"}`,
+				string(responseBody), "must get expected error response")
+			assert.EqualValuesf(t, http.StatusBadRequest, rr.Code, "must get expected HttpCode")
+		})
+
+		t.Run("testing v1 path, client accepts problem details, error has details", func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, pathMethodWithdrawAccount, nil)
+			req.Header.Set("Accept", contentTypeApplicationProblemJson)
+
+			appErr := &app.AppError{Err: app.WithDetails(app.ErrUserDoesNotHaveEnoughMoney,
+				map[string]interface{}{"balance": "10", "amount": "100"}), Code: http.StatusBadRequest}
+			err := WriteErrorResponse(rr, req, appErr, appErr.Code)
+			require.NoError(t, err, "must get no errors")
+
+			responseBody, err := ioutil.ReadAll(rr.Body)
+			require.NoError(t, err)
+
+			assert.JSONEq(t, `{"type":"/v2/problems/INSUFFICIENT_FUNDS","title":"Insufficient funds","status":400,
+				"detail":"user with specified id does not have enough money on balance","instance":"/withdraw",
+				"code":"INSUFFICIENT_FUNDS","balance":"10","amount":"100"}`,
+				string(responseBody), "must get expected problem response")
+			assert.Equal(t, contentTypeApplicationProblemJson, rr.Header().Get("Content-Type"), "must get problem content type")
+		})
+
+		t.Run("testing unknown error gets internal server error status", func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/v2/users/1/balance", nil)
+
+			err := WriteErrorResponse(rr, req, errors.New("some error"), 0)
+			require.NoError(t, err, "must get no errors")
+
+			responseBody, err := ioutil.ReadAll(rr.Body)
+			require.NoError(t, err)
+
+			assert.JSONEq(t, `{"type":"/v2/problems/UNKNOWN_ERROR","title":"Unknown error","status":500,
+				"detail":"some error","instance":"/v2/users/1/balance","code":"UNKNOWN_ERROR"}`,
+				string(responseBody), "must get expected problem response")
+			assert.EqualValuesf(t, http.StatusInternalServerError, rr.Code, "must get expected HttpCode")
+		})
+	}
+}
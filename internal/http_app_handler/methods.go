@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"job-backend-trainee-assignment/internal/app"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -35,6 +36,36 @@ func WriteResponse(w http.ResponseWriter, responseBody interface{}, httpCode int
 	return nil
 }
 
+const (
+	contentTypeApplicationProblemJson = "application/problem+json"
+	problemTypeURIPrefix              = "/v2/problems/"
+)
+
+//WriteProblemResponse writes RFC 7807 problem details of given error
+func WriteProblemResponse(w http.ResponseWriter, r *http.Request, err error, httpCode int) error {
+	if httpCode == 0 {
+		httpCode = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", contentTypeApplicationProblemJson)
+	return WriteResponse(w, NewProblemResponseBody(r, err, httpCode), httpCode)
+}
+
+//WriteErrorResponse writes error response as problem details on api v2 paths or if client accepts
+//"application/problem+json", otherwise writes ErrorResponseBody
+func WriteErrorResponse(w http.ResponseWriter, r *http.Request, err error, httpCode int) error {
+	if httpCode == 0 {
+		httpCode = http.StatusInternalServerError
+	}
+
+	if strings.HasPrefix(r.URL.Path, pathPrefixV2) ||
+		strings.Contains(r.Header.Get("Accept"), contentTypeApplicationProblemJson) {
+		return WriteProblemResponse(w, r, err, httpCode)
+	}
+
+	return WriteResponse(w, NewErrorResponseBody(err), httpCode)
+}
+
 // swagger:route POST /balance methods GetUserBalance
 // Returns balance of user with given id.
 // responses:
@@ -59,7 +90,7 @@ func (h *AppHttpHandler) HandlerGetUserBalance(w http.ResponseWriter, r *http.Re
 	err := d.Decode(params)
 	if err != nil {
 		h.logger.Error("HandlerGetUserBalance, failed to unmarshal request body on Path %s, host %s, method:%s", r.URL, r.Host, r.Method)
-		err := WriteResponse(w, &ErrorResponseBody{Error: ErrJsonUnmarshalFailed.Error()}, http.StatusBadRequest)
+		err := WriteErrorResponse(w, r, ErrJsonUnmarshalFailed, http.StatusBadRequest)
 		if err != nil {
 			h.logger.Error("HandlerGetUserBalance, failed to write response on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
 			http.Error(w, fmt.Sprintf("{\"error\": \"%s\"}", ErrResponseWriteFailed.Error()), http.StatusInternalServerError)
@@ -77,7 +108,7 @@ func (h *AppHttpHandler) HandlerGetUserBalance(w http.ResponseWriter, r *http.Re
 		}
 
 		h.logger.Error("HandlerGetUserBalance err, on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
-		err = WriteResponse(w, &ErrorResponseBody{Error: err.Error()}, httpCode)
+		err = WriteErrorResponse(w, r, err, httpCode)
 		if err != nil {
 			h.logger.Error("HandlerGetUserBalance,  failed to write response on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
 			http.Error(w, fmt.Sprintf("{\"error\": \"%s\"}", ErrResponseWriteFailed.Error()), http.StatusInternalServerError)
@@ -118,7 +149,7 @@ func (h *AppHttpHandler) HandlerCreditUserAccount(w http.ResponseWriter, r *http
 	err := d.Decode(params)
 	if err != nil {
 		h.logger.Error("HandlerCreditUserAccount, failed to unmarshal request body on Path %s, host %s, method:%s", r.URL, r.Host, r.Method)
-		err := WriteResponse(w, &ErrorResponseBody{Error: ErrJsonUnmarshalFailed.Error()}, http.StatusBadRequest)
+		err := WriteErrorResponse(w, r, ErrJsonUnmarshalFailed, http.StatusBadRequest)
 		if err != nil {
 			h.logger.Error("HandlerCreditUserAccount, failed to write response on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
 			http.Error(w, fmt.Sprintf("{\"error\": \"%s\"}", ErrResponseWriteFailed.Error()), http.StatusInternalServerError)
@@ -136,7 +167,7 @@ func (h *AppHttpHandler) HandlerCreditUserAccount(w http.ResponseWriter, r *http
 		}
 
 		h.logger.Error("HandlerCreditUserAccount err, on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
-		err = WriteResponse(w, &ErrorResponseBody{Error: err.Error()}, httpCode)
+		err = WriteErrorResponse(w, r, err, httpCode)
 		if err != nil {
 			h.logger.Error("HandlerCreditUserAccount,  failed to write response on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
 			http.Error(w, fmt.Sprintf("{\"error\": \"%s\"}", ErrResponseWriteFailed.Error()), http.StatusInternalServerError)
@@ -177,7 +208,7 @@ func (h *AppHttpHandler) HandlerWithdrawUserAccount(w http.ResponseWriter, r *ht
 	err := d.Decode(params)
 	if err != nil {
 		h.logger.Error("HandlerWithdrawUserAccount, failed to unmarshal request body on Path %s, host %s, method:%s", r.URL, r.Host, r.Method)
-		err := WriteResponse(w, &ErrorResponseBody{Error: ErrJsonUnmarshalFailed.Error()}, http.StatusBadRequest)
+		err := WriteErrorResponse(w, r, ErrJsonUnmarshalFailed, http.StatusBadRequest)
 		if err != nil {
 			h.logger.Error("HandlerWithdrawUserAccount, failed to write response on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
 			http.Error(w, fmt.Sprintf("{\"error\": \"%s\"}", ErrResponseWriteFailed.Error()), http.StatusInternalServerError)
@@ -195,7 +226,7 @@ func (h *AppHttpHandler) HandlerWithdrawUserAccount(w http.ResponseWriter, r *ht
 		}
 
 		h.logger.Error("HandlerWithdrawUserAccount err, on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
-		err = WriteResponse(w, &ErrorResponseBody{Error: err.Error()}, httpCode)
+		err = WriteErrorResponse(w, r, err, httpCode)
 		if err != nil {
 			h.logger.Error("HandlerWithdrawUserAccount,  failed to write response on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
 			http.Error(w, fmt.Sprintf("{\"error\": \"%s\"}", ErrResponseWriteFailed.Error()), http.StatusInternalServerError)
@@ -236,7 +267,7 @@ func (h *AppHttpHandler) HandlerTransferUserMoney(w http.ResponseWriter, r *http
 	err := d.Decode(params)
 	if err != nil {
 		h.logger.Error("HandlerTransferUserMoney, failed to decode request body on Path %s, host %s, method:%s", r.URL, r.Host, r.Method)
-		err := WriteResponse(w, &ErrorResponseBody{Error: ErrJsonUnmarshalFailed.Error()}, http.StatusBadRequest)
+		err := WriteErrorResponse(w, r, ErrJsonUnmarshalFailed, http.StatusBadRequest)
 		if err != nil {
 			h.logger.Error("HandlerTransferUserMoney, failed to write response on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
 			http.Error(w, fmt.Sprintf("{\"error\": \"%s\"}", ErrResponseWriteFailed.Error()), http.StatusInternalServerError)
@@ -254,7 +285,7 @@ func (h *AppHttpHandler) HandlerTransferUserMoney(w http.ResponseWriter, r *http
 		}
 
 		h.logger.Error("HandlerTransferUserMoney err, on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
-		err = WriteResponse(w, &ErrorResponseBody{Error: err.Error()}, httpCode)
+		err = WriteErrorResponse(w, r, err, httpCode)
 		if err != nil {
 			h.logger.Error("HandlerTransferUserMoney,  failed to write response on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
 			http.Error(w, fmt.Sprintf("{\"error\": \"%s\"}", ErrResponseWriteFailed.Error()), http.StatusInternalServerError)
@@ -294,7 +325,7 @@ func (h *AppHttpHandler) HandlerGetUserOperationsLog(w http.ResponseWriter, r *h
 	err := d.Decode(params)
 	if err != nil {
 		h.logger.Error("HandlerGetUserOperationsLog, failed to decode request body on Path %s, host %s, method:%s", r.URL, r.Host, r.Method)
-		err := WriteResponse(w, &ErrorResponseBody{Error: ErrJsonUnmarshalFailed.Error()}, http.StatusBadRequest)
+		err := WriteErrorResponse(w, r, ErrJsonUnmarshalFailed, http.StatusBadRequest)
 		if err != nil {
 			h.logger.Error("HandlerGetUserOperationsLog, failed to write response on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
 			http.Error(w, fmt.Sprintf("{\"error\": \"%s\"}", ErrResponseWriteFailed.Error()), http.StatusInternalServerError)
@@ -312,7 +343,7 @@ func (h *AppHttpHandler) HandlerGetUserOperationsLog(w http.ResponseWriter, r *h
 		}
 
 		h.logger.Error("HandlerGetUserOperationsLog err, on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
-		err = WriteResponse(w, &ErrorResponseBody{Error: err.Error()}, httpCode)
+		err = WriteErrorResponse(w, r, err, httpCode)
 		if err != nil {
 			h.logger.Error("HandlerGetUserOperationsLog,  failed to write response on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
 			http.Error(w, fmt.Sprintf("{\"error\": \"%s\"}", ErrResponseWriteFailed.Error()), http.StatusInternalServerError)
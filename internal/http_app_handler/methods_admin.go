@@ -0,0 +1,132 @@
+package http_app_handler
+
+import (
+	"fmt"
+	"job-backend-trainee-assignment/internal/breaker"
+	"job-backend-trainee-assignment/internal/ratelimit"
+	"job-backend-trainee-assignment/internal/statement_export"
+	"net/http"
+)
+
+type IConfigProvider interface {
+	//EffectiveConfig returns active config, secrets must be masked
+	EffectiveConfig() map[string]interface{}
+}
+
+// Validate checks config, which may be changed on config reload
+func (cfg *Config) Validate() error {
+	if cfg.RequestHandleTimeout <= 0 {
+		return fmt.Errorf("request handle timeout must be positive")
+	}
+
+	if cfg.ExportPageSize < 0 {
+		return fmt.Errorf("export page size must be non-negative")
+	}
+	return cfg.rateLimit().Validate()
+}
+
+func (cfg *Config) rateLimit() *ratelimit.Config {
+	return &ratelimit.Config{RequestsPerSecond: cfg.RateLimitPerSecond, Burst: cfg.RateLimitBurst}
+}
+
+// GetConfig returns copy of current handler config
+func (h *AppHttpHandler) GetConfig() Config {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return *h.cfg
+}
+
+// SetConfig replaces handler config, it is used on config reload
+func (h *AppHttpHandler) SetConfig(cfg *Config) error {
+	if cfg == nil {
+		return fmt.Errorf("must provide non-nil config")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.ExportPageSize != 0 {
+		err := h.exporter.SetConfig(&statement_export.Config{PageSize: cfg.ExportPageSize})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := h.limiter.SetConfig(cfg.rateLimit()); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.cfg = cfg
+	h.mu.Unlock()
+	return nil
+}
+
+// RegisterAdminRoutes adds admin routes, showing effective config of given provider
+func (h *AppHttpHandler) RegisterAdminRoutes(p IConfigProvider) error {
+	if p == nil {
+		return fmt.Errorf("must provide a non-nil config provider instance")
+	}
+
+	h.mu.Lock()
+	h.configProvider = p
+	h.mu.Unlock()
+
+	h.router.HandlerFunc(http.MethodGet, pathV2GetEffectiveConfig, h.AccessLogMW(h.HandlerV2GetEffectiveConfig))
+
+	return nil
+}
+
+// swagger:route GET /v2/admin/config v2 V2GetEffectiveConfig
+// Returns active config of the service, including changes applied on reload. Secrets are masked.
+// responses:
+//   200: EffectiveConfigResponseBody (config sections, wrapped in SuccessResponseBody)
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2GetEffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	p := h.configProvider
+	h.mu.Unlock()
+
+	h.writeV2Result(w, r, "HandlerV2GetEffectiveConfig", p.EffectiveConfig(), http.StatusOK)
+}
+
+// RegisterHealthRoute adds health route, showing states of given circuit breakers
+func (h *AppHttpHandler) RegisterHealthRoute(breakers ...*breaker.Breaker) error {
+	for _, b := range breakers {
+		if b == nil {
+			return fmt.Errorf("must provide non-nil breaker instances")
+		}
+	}
+
+	h.mu.Lock()
+	h.breakers = breakers
+	h.mu.Unlock()
+
+	h.router.HandlerFunc(http.MethodGet, pathV2GetHealth, h.AccessLogMW(h.HandlerV2GetHealth))
+
+	return nil
+}
+
+// swagger:route GET /v2/admin/health v2 V2GetHealth
+// Returns states of circuit breakers around cache and exchange rates service.
+// While breaker is open, cache is skipped and balances are converted by last known rates.
+// responses:
+//   200: HealthResponseBody (health status, wrapped in SuccessResponseBody)
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2GetHealth(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	breakers := h.breakers
+	h.mu.Unlock()
+
+	result := &HealthStatus{Status: HealthStatusOk, Breakers: make([]breaker.Status, 0, len(breakers))}
+	for _, b := range breakers {
+		status := b.Status()
+		if status.State != breaker.StateClosed {
+			result.Status = HealthStatusDegraded
+		}
+		result.Breakers = append(result.Breakers, status)
+	}
+
+	h.writeV2Result(w, r, "HandlerV2GetHealth", result, http.StatusOK)
+}
@@ -0,0 +1,79 @@
+package http_app_handler
+
+import (
+	"encoding/json"
+	"job-backend-trainee-assignment/internal/app"
+	"net/http"
+)
+
+// swagger:route POST /v2/users/{id}/bonuses v2 V2GrantUserBonus
+// Grants promotional bonus to user with given id. Bonus is spent on withdrawals until expiration,
+// it may not be transferred to other users.
+// responses:
+//   200: BonusGrantResponseBody (operation with given token had already been done)
+//   201: BonusGrantResponseBody (ResultState model, wrapped in SuccessResponseBody)
+//   400: ProblemResponseBody
+//   404: ProblemResponseBody
+//   422: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2GrantUserBonus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	userId, err := getPathUserId(r)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GrantUserBonus", err, http.StatusBadRequest)
+		return
+	}
+
+	params := &BonusGrantRequestV2{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	defer r.Body.Close()
+
+	err = d.Decode(params)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GrantUserBonus", ErrJsonUnmarshalFailed, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.app.GrantBonus(ctx, &app.BonusGrantRequest{
+		UserId:           userId,
+		Amount:           params.Amount,
+		ExpiresAt:        params.ExpiresAt,
+		Purpose:          params.Purpose,
+		IdempotencyToken: params.IdempotencyToken,
+	})
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GrantUserBonus", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2GrantUserBonus", result, getCreatedStatusCode(result))
+}
+
+// swagger:route GET /v2/users/{id}/bonuses v2 V2GetUserBonuses
+// Returns bonus balance of user with given id and active bonus grants in order of spending.
+// responses:
+//   200: BonusBalanceResponseBody (BonusBalance model, wrapped in SuccessResponseBody)
+//   400: ProblemResponseBody
+//   404: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2GetUserBonuses(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	userId, err := getPathUserId(r)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetUserBonuses", err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.app.GetUserBonuses(ctx, &app.BonusBalanceRequest{UserId: userId})
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetUserBonuses", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2GetUserBonuses", result, http.StatusOK)
+}
@@ -0,0 +1,163 @@
+package http_app_handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/currency"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+//cachedResponse is a rendered body of currency route, it is served until expiration
+type cachedResponse struct {
+	body      []byte
+	etag      string
+	expiresAt time.Time
+}
+
+// RegisterCurrencyRoutes adds api v2 routes of supported currencies and current rates of given provider,
+// responses are cached for cacheTTL, zero TTL disables cache
+func (h *AppHttpHandler) RegisterCurrencyRoutes(p exchanger.IRatesProvider, cacheTTL time.Duration) error {
+	if p == nil {
+		return fmt.Errorf("must provide a non-nil rates provider instance")
+	}
+
+	if cacheTTL < 0 {
+		return fmt.Errorf("currency routes cache ttl must be non-negative")
+	}
+
+	h.mu.Lock()
+	h.ratesProvider = p
+	h.currencyCacheTTL = cacheTTL
+	h.currencyResponses = map[string]*cachedResponse{}
+	h.mu.Unlock()
+
+	h.router.HandlerFunc(http.MethodGet, pathV2GetCurrencies, h.AccessLogMW(h.HandlerV2GetCurrencies))
+	h.router.HandlerFunc(http.MethodGet, pathV2GetRates, h.AccessLogMW(h.HandlerV2GetRates))
+
+	return nil
+}
+
+// swagger:route GET /v2/currencies v2 V2GetCurrencies
+// Returns currencies, accepted as currency of balance: codes, names, minor units and current rates against base currency.
+// Response has ETag header, request with matching If-None-Match header gets 304 without body.
+// responses:
+//   200: CurrenciesResponseBody (CurrenciesCatalogue model, wrapped in SuccessResponseBody)
+//   503: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2GetCurrencies(w http.ResponseWriter, r *http.Request) {
+	h.writeCachedRatesResult(w, r, "HandlerV2GetCurrencies", pathV2GetCurrencies,
+		func(rates *exchanger.CurrentRates, currencies *currency.Registry) interface{} {
+			codes := make([]string, 0, len(rates.Rates)+1)
+			codes = append(codes, rates.Base)
+			for code := range rates.Rates {
+				if code != rates.Base {
+					codes = append(codes, code)
+				}
+			}
+			sort.Strings(codes)
+
+			catalogue := &CurrenciesCatalogue{Base: rates.Base, RatesDate: rates.Date, Source: rates.Source, Pivot: rates.Pivot,
+				Stale: rates.Stale, Currencies: make([]CurrencyDescription, 0, len(codes))}
+			for _, code := range codes {
+				rate := "1"
+				if code != rates.Base {
+					rate = rates.Rates[code].String()
+				}
+
+				c := currencies.Get(code)
+				catalogue.Currencies = append(catalogue.Currencies, CurrencyDescription{Code: code, Name: c.Name,
+					MinorUnits: c.MinorUnits, Rate: rate})
+			}
+			return catalogue
+		})
+}
+
+// swagger:route GET /v2/rates v2 V2GetRates
+// Returns current rates of currencies against base currency with date and source of rates.
+// Response has ETag header, request with matching If-None-Match header gets 304 without body.
+// responses:
+//   200: RatesResponseBody (RatesList model, wrapped in SuccessResponseBody)
+//   503: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2GetRates(w http.ResponseWriter, r *http.Request) {
+	h.writeCachedRatesResult(w, r, "HandlerV2GetRates", pathV2GetRates,
+		func(rates *exchanger.CurrentRates, currencies *currency.Registry) interface{} {
+			list := &RatesList{Base: rates.Base, Date: rates.Date, Source: rates.Source, Pivot: rates.Pivot,
+				Stale: rates.Stale, Rates: make(map[string]string, len(rates.Rates))}
+			for code, rate := range rates.Rates {
+				list.Rates[code] = rate.String()
+			}
+			return list
+		})
+}
+
+// writeCachedRatesResult writes cached response of route or renders result of current rates and caches it.
+// Result of last known rates is not cached, so it is rendered again once rates are updated
+func (h *AppHttpHandler) writeCachedRatesResult(w http.ResponseWriter, r *http.Request, handlerName string, path string,
+	render func(rates *exchanger.CurrentRates, currencies *currency.Registry) interface{}) {
+	now := time.Now()
+
+	h.mu.Lock()
+	provider := h.ratesProvider
+	cacheTTL := h.currencyCacheTTL
+	cached := h.currencyResponses[path]
+	h.mu.Unlock()
+
+	if cached == nil || !now.Before(cached.expiresAt) {
+		ctx, cancel := h.getV2RequestContext(r)
+		defer cancel()
+
+		rates, err := provider.GetRates(ctx)
+		if err != nil {
+			h.writeV2Error(w, r, handlerName, fmt.Errorf("%v, err: %w", err, app.ErrCurrencyExchangeFailed),
+				http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := json.Marshal(&SuccessResponseBody{Result: render(rates, provider.Currencies())})
+		if err != nil {
+			h.writeV2Error(w, r, handlerName, ErrJsonMarshalFailed, http.StatusInternalServerError)
+			return
+		}
+
+		sum := sha256.Sum256(body)
+		cached = &cachedResponse{body: body, etag: `"` + hex.EncodeToString(sum[:16]) + `"`, expiresAt: now}
+		if !rates.Stale && cacheTTL > 0 {
+			cached.expiresAt = now.Add(cacheTTL)
+
+			h.mu.Lock()
+			h.currencyResponses[path] = cached
+			h.mu.Unlock()
+		}
+	}
+
+	w.Header().Set("ETag", cached.etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int64(cached.expiresAt.Sub(now).Seconds())))
+
+	if etagMatches(r.Header.Get("If-None-Match"), cached.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(cached.body)
+	if err != nil {
+		h.logger.Error("%s, failed to write response on Path %s, host %s, method:%s, err:%s", handlerName, r.URL, r.Host, r.Method, err.Error())
+	}
+}
+
+// etagMatches checks If-None-Match header value, which is a list of entity tags or "*"
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package http_app_handler
+
+import (
+	"fmt"
+	"job-backend-trainee-assignment/internal/statement_export"
+	"net/http"
+)
+
+// swagger:route GET /v2/users/{id}/statement/export v2 V2ExportUserStatement
+// Streams statement of user account for the period as csv, excel compatible csv or printable html file.
+// responses:
+//   200: description: statement file
+//   400: ProblemResponseBody
+//   404: ProblemResponseBody
+//   422: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2ExportUserStatement(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	userId, err := getPathUserId(r)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2ExportUserStatement", err, http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	format, err := statement_export.ParseFormat(query.Get("format"))
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2ExportUserStatement",
+			fmt.Errorf("param format, err: %w", ErrBadQueryParam), http.StatusBadRequest)
+		return
+	}
+
+	header, err := h.exporter.NewHeader(ctx, userId, query.Get("from"), query.Get("to"),
+		query.Get("consistency_token"))
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2ExportUserStatement", err, GetV2StatusCode(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	disposition := "attachment"
+	if format == statement_export.FormatHTML {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, format.FileName(header)))
+	w.WriteHeader(http.StatusOK)
+
+	_, err = h.exporter.Export(ctx, w, format, header)
+	if err != nil {
+		//status code is already sent, so the connection is aborted to let client know, that file is incomplete
+		h.logger.Error("HandlerV2ExportUserStatement, export failed on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,60 @@
+package http_app_handler
+
+import (
+	"encoding/json"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/http_handler_router"
+	"net/http"
+)
+
+// swagger:route POST /v2/quotes v2 V2CreateQuote
+// Creates quote of exchange rate between base currency and another currency. Rate of quote is locked until
+// expiration, quote id may be passed to one credit, withdrawal or transfer, which is done by base currency amount of quote.
+// responses:
+//   201: QuoteResponseBody (Quote model, wrapped in SuccessResponseBody)
+//   400: ProblemResponseBody
+//   422: ProblemResponseBody
+//   500: ProblemResponseBody
+//   503: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2CreateQuote(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	params := &app.QuoteRequest{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	defer r.Body.Close()
+
+	err := d.Decode(params)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2CreateQuote", ErrJsonUnmarshalFailed, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.app.CreateQuote(ctx, params)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2CreateQuote", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2CreateQuote", result, http.StatusCreated)
+}
+
+// swagger:route GET /v2/quotes/{id} v2 V2GetQuote
+// Returns quote with given id, used quote has time of use and idempotency token of operation.
+// responses:
+//   200: QuoteResponseBody (Quote model, wrapped in SuccessResponseBody)
+//   404: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2GetQuote(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	result, err := h.app.GetQuote(ctx, &app.QuoteLookupRequest{QuoteId: router.PathParam(r, pathParamQuoteId)})
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetQuote", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2GetQuote", result, http.StatusOK)
+}
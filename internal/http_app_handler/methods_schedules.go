@@ -0,0 +1,202 @@
+package http_app_handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"job-backend-trainee-assignment/internal/http_handler_router"
+	"job-backend-trainee-assignment/internal/scheduler"
+	"net/http"
+	"strconv"
+)
+
+// RegisterSchedulerRoutes adds api v2 routes of scheduled payments, handled by given scheduler
+func (h *AppHttpHandler) RegisterSchedulerRoutes(s scheduler.IScheduler) error {
+	if s == nil {
+		return fmt.Errorf("must provide a non-nil scheduler instance")
+	}
+
+	h.mu.Lock()
+	h.scheduler = s
+	h.mu.Unlock()
+
+	h.router.HandlerFunc(http.MethodPost, pathV2CreateUserSchedule, h.AccessLogMW(
+		h.ContentTypeValidationMW(h.HandlerV2CreateUserSchedule, contentTypeApplicationJson)))
+	h.router.HandlerFunc(http.MethodGet, pathV2GetUserSchedules, h.AccessLogMW(h.HandlerV2GetUserSchedules))
+	h.router.HandlerFunc(http.MethodGet, pathV2GetSchedule, h.AccessLogMW(h.HandlerV2GetSchedule))
+	h.router.HandlerFunc(http.MethodDelete, pathV2CancelSchedule, h.AccessLogMW(h.HandlerV2CancelSchedule))
+	h.router.HandlerFunc(http.MethodGet, pathV2GetScheduleRuns, h.AccessLogMW(h.HandlerV2GetScheduleRuns))
+
+	return nil
+}
+
+func (h *AppHttpHandler) getScheduler() scheduler.IScheduler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.scheduler
+}
+
+// getPathScheduleId parses schedule identifier from request path
+func getPathScheduleId(r *http.Request) (int64, error) {
+	scheduleId, err := strconv.ParseInt(router.PathParam(r, pathParamScheduleId), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("param %s, err: %w", pathParamScheduleId, ErrBadPathParam)
+	}
+
+	return scheduleId, nil
+}
+
+// swagger:route POST /v2/users/{id}/schedules v2 V2CreateUserSchedule
+// Creates one-off or recurring withdraw or transfer of user with given id.
+// responses:
+//   201: ScheduleResponseBody (Schedule model, wrapped in SuccessResponseBody)
+//   400: ProblemResponseBody
+//   404: ProblemResponseBody
+//   422: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2CreateUserSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	userId, err := getPathUserId(r)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2CreateUserSchedule", err, http.StatusBadRequest)
+		return
+	}
+
+	params := &ScheduleRequestV2{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	defer r.Body.Close()
+
+	err = d.Decode(params)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2CreateUserSchedule", ErrJsonUnmarshalFailed, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.getScheduler().CreateSchedule(ctx, &scheduler.ScheduleRequest{
+		UserId:               userId,
+		OperationType:        params.OperationType,
+		ReceiverId:           params.ReceiverId,
+		Amount:               params.Amount,
+		Purpose:              params.Purpose,
+		ScheduleType:         params.ScheduleType,
+		RunAt:                params.RunAt,
+		IntervalSeconds:      params.IntervalSeconds,
+		CronExpression:       params.CronExpression,
+		MaxAttempts:          params.MaxAttempts,
+		RetryIntervalSeconds: params.RetryIntervalSeconds,
+		IdempotencyToken:     params.IdempotencyToken,
+	})
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2CreateUserSchedule", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2CreateUserSchedule", result, http.StatusCreated)
+}
+
+// swagger:route GET /v2/users/{id}/schedules v2 V2GetUserSchedules
+// Returns payment schedules of user with given id.
+// responses:
+//   200: SchedulesResponseBody (list of Schedule models, wrapped in SuccessResponseBody)
+//   400: ProblemResponseBody
+//   404: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2GetUserSchedules(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	userId, err := getPathUserId(r)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetUserSchedules", err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.getScheduler().GetUserSchedules(ctx, userId)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetUserSchedules", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2GetUserSchedules", result, http.StatusOK)
+}
+
+// swagger:route GET /v2/schedules/{id} v2 V2GetSchedule
+// Returns payment schedule with given id.
+// responses:
+//   200: ScheduleResponseBody (Schedule model, wrapped in SuccessResponseBody)
+//   400: ProblemResponseBody
+//   404: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2GetSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	scheduleId, err := getPathScheduleId(r)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetSchedule", err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.getScheduler().GetSchedule(ctx, scheduleId)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetSchedule", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2GetSchedule", result, http.StatusOK)
+}
+
+// swagger:route DELETE /v2/schedules/{id} v2 V2CancelSchedule
+// Cancels payment schedule with given id, run history is kept.
+// responses:
+//   200: ScheduleResponseBody (cancelled Schedule model, wrapped in SuccessResponseBody)
+//   400: ProblemResponseBody
+//   404: ProblemResponseBody
+//   409: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2CancelSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	scheduleId, err := getPathScheduleId(r)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2CancelSchedule", err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.getScheduler().CancelSchedule(ctx, scheduleId)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2CancelSchedule", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2CancelSchedule", result, http.StatusOK)
+}
+
+// swagger:route GET /v2/schedules/{id}/runs v2 V2GetScheduleRuns
+// Returns run history of payment schedule with given id, latest runs first.
+// responses:
+//   200: ScheduleRunsResponseBody (list of ScheduleRun models, wrapped in SuccessResponseBody)
+//   400: ProblemResponseBody
+//   404: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2GetScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	scheduleId, err := getPathScheduleId(r)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetScheduleRuns", err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.getScheduler().GetScheduleRuns(ctx, scheduleId)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetScheduleRuns", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2GetScheduleRuns", result, http.StatusOK)
+}
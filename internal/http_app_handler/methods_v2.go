@@ -0,0 +1,484 @@
+package http_app_handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/http_handler_router"
+	"job-backend-trainee-assignment/internal/scheduler"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// v2StatusCodes maps app errors to http status codes of resource-oriented api v2,
+// errors not listed here keep status code of app.AppError
+var v2StatusCodes = []struct {
+	err  error
+	code int
+}{
+	{app.ErrUserDoesNotExist, http.StatusNotFound},
+	{app.ErrMoneySenderDoesNotExist, http.StatusNotFound},
+	{app.ErrMoneyReceiverDoesNotExist, http.StatusNotFound},
+	{app.ErrMoneySenderAndReceiverDoNotExist, http.StatusNotFound},
+	{app.ErrQuoteDoesNotExist, http.StatusNotFound},
+
+	{app.ErrUserDoesNotHaveEnoughMoney, http.StatusConflict},
+	{app.ErrAmountToStoreExceedsMaximumValue, http.StatusConflict},
+	{app.ErrQuoteIsExpired, http.StatusConflict},
+	{app.ErrQuoteIsAlreadyUsed, http.StatusConflict},
+
+	{app.ErrIdempotencyTokenIsEmpty, http.StatusUnprocessableEntity},
+	{app.ErrAmountValueIsLessThanMin, http.StatusUnprocessableEntity},
+	{app.ErrAmountHasExcessiveFractionalDigits, http.StatusUnprocessableEntity},
+	{app.ErrAmountHasExcessiveWholeDigits, http.StatusUnprocessableEntity},
+	{app.ErrAmountValueIsNegative, http.StatusUnprocessableEntity},
+	{app.ErrFailedToCastAmountToDecimal, http.StatusUnprocessableEntity},
+	{app.ErrSenderIdIsEqualToReceiverId, http.StatusUnprocessableEntity},
+	{app.ErrCurrencyDoesNotExist, http.StatusUnprocessableEntity},
+	{app.ErrTooManyCurrencies, http.StatusUnprocessableEntity},
+	{app.ErrPageParamIsLessThanZero, http.StatusUnprocessableEntity},
+	{app.ErrLimitParamIsLessThanMin, http.StatusUnprocessableEntity},
+	{app.ErrBadOrderFieldParam, http.StatusUnprocessableEntity},
+	{app.ErrBadOrderDirectionParam, http.StatusUnprocessableEntity},
+	{app.ErrBadDateFormat, http.StatusUnprocessableEntity},
+	{app.ErrPeriodStartIsAfterEnd, http.StatusUnprocessableEntity},
+	{app.ErrDateIsInFuture, http.StatusUnprocessableEntity},
+	{app.ErrBadConsistencyToken, http.StatusUnprocessableEntity},
+	{app.ErrQuoteAmountMismatch, http.StatusUnprocessableEntity},
+	{app.ErrQuoteCurrencyIsNotBase, http.StatusUnprocessableEntity},
+	{app.ErrBonusExpirationIsNotInFuture, http.StatusUnprocessableEntity},
+
+	{app.ErrRatesAreStale, http.StatusServiceUnavailable},
+
+	{scheduler.ErrScheduleDoesNotExist, http.StatusNotFound},
+	{scheduler.ErrScheduleIsNotActive, http.StatusConflict},
+	{scheduler.ErrBadCronExpression, http.StatusUnprocessableEntity},
+	{scheduler.ErrBadOperationType, http.StatusUnprocessableEntity},
+	{scheduler.ErrBadScheduleType, http.StatusUnprocessableEntity},
+	{scheduler.ErrRunTimeIsNotSet, http.StatusUnprocessableEntity},
+	{scheduler.ErrIntervalIsLessThanMin, http.StatusUnprocessableEntity},
+	{scheduler.ErrMaxAttemptsIsLessThanMin, http.StatusUnprocessableEntity},
+	{scheduler.ErrRetryIntervalIsNegative, http.StatusUnprocessableEntity},
+	{scheduler.ErrReceiverIsNotSet, http.StatusUnprocessableEntity},
+	{scheduler.ErrAmountIsNotPositiveNumber, http.StatusUnprocessableEntity},
+}
+
+// GetV2StatusCode returns http status code of api v2 for error, returned by app method
+func GetV2StatusCode(err error) int {
+	for _, sc := range v2StatusCodes {
+		if errors.Is(err, sc.err) {
+			return sc.code
+		}
+	}
+
+	if appErr, ok := err.(*app.AppError); ok {
+		return appErr.Code
+	}
+
+	return http.StatusInternalServerError
+}
+
+// writeV2Error writes problem details response with status code of api v2
+func (h *AppHttpHandler) writeV2Error(w http.ResponseWriter, r *http.Request, handlerName string, err error, httpCode int) {
+	h.logger.Error("%s err, on Path %s, host %s, method:%s, err:%s", handlerName, r.URL, r.Host, r.Method, err.Error())
+	err = WriteProblemResponse(w, r, err, httpCode)
+	if err != nil {
+		h.logger.Error("%s, failed to write response on Path %s, host %s, method:%s, err:%s", handlerName, r.URL, r.Host, r.Method, err.Error())
+		http.Error(w, fmt.Sprintf("{\"error\": \"%s\"}", ErrResponseWriteFailed.Error()), http.StatusInternalServerError)
+	}
+}
+
+// writeV2Result writes successful response, wrapped in SuccessResponseBody
+func (h *AppHttpHandler) writeV2Result(w http.ResponseWriter, r *http.Request, handlerName string, result interface{}, httpCode int) {
+	err := WriteResponse(w, &SuccessResponseBody{Result: result}, httpCode)
+	if err != nil {
+		h.logger.Error("%s, failed to write response on Path %s, host %s, method:%s, err:%s", handlerName, r.URL, r.Host, r.Method, err.Error())
+		http.Error(w, fmt.Sprintf("{\"error\": \"%s\"}", ErrResponseWriteFailed.Error()), http.StatusInternalServerError)
+	}
+}
+
+// getV2RequestContext returns request context, limited with configured request handle timeout
+func (h *AppHttpHandler) getV2RequestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	var requestHandleTimeout time.Duration
+
+	h.mu.Lock()
+	requestHandleTimeout = h.cfg.RequestHandleTimeout
+	h.mu.Unlock()
+
+	return context.WithTimeout(r.Context(), requestHandleTimeout)
+}
+
+// getPathUserId parses user identifier from request path
+func getPathUserId(r *http.Request) (int64, error) {
+	userId, err := strconv.ParseInt(router.PathParam(r, pathParamUserId), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("param %s, err: %w", pathParamUserId, ErrBadPathParam)
+	}
+
+	return userId, nil
+}
+
+// getQueryInt parses optional integer query param, returns zero if param is not set
+func getQueryInt(r *http.Request, name string) (int64, error) {
+	strVal := r.URL.Query().Get(name)
+	if strVal == "" {
+		return 0, nil
+	}
+
+	val, err := strconv.ParseInt(strVal, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("param %s, err: %w", name, ErrBadQueryParam)
+	}
+
+	return val, nil
+}
+
+// getCreatedStatusCode returns 201 for newly performed operation and 200 for repeated request with used token
+func getCreatedStatusCode(result *app.ResultState) int {
+	if result != nil && result.State == app.OperationTokenIsAlreadyUsed {
+		return http.StatusOK
+	}
+	return http.StatusCreated
+}
+
+// getQueryList returns comma separated values of query param, param may be repeated
+func getQueryList(r *http.Request, param string) []string {
+	var list []string
+	for _, value := range r.URL.Query()[param] {
+		for _, item := range strings.Split(value, ",") {
+			if item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// swagger:route GET /v2/users/{id}/balance v2 V2GetUserBalance
+// Returns balance of user with given id.
+// With currencies param, balance is also returned in each of listed currencies, converted by one snapshot of rates.
+// responses:
+//   200: BalanceResponseBody (UserBalance model, wrapped in SuccessResponseBody)
+//   400: ProblemResponseBody
+//   404: ProblemResponseBody
+//   422: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2GetUserBalance(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	userId, err := getPathUserId(r)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetUserBalance", err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.app.GetUserBalance(ctx, &app.BalanceRequest{
+		UserId:           userId,
+		Currency:         r.URL.Query().Get("currency"),
+		Currencies:       getQueryList(r, "currencies"),
+		ConsistencyToken: r.URL.Query().Get("consistency_token"),
+	})
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetUserBalance", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2GetUserBalance", result, http.StatusOK)
+}
+
+// swagger:route GET /v2/users/{id}/operations v2 V2GetUserOperations
+// Get user operations log.
+// responses:
+//   200: OperationsLogResponseBody (OperationsLog model, wrapped in SuccessResponseBody)
+//   400: ProblemResponseBody
+//   404: ProblemResponseBody
+//   422: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2GetUserOperations(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	userId, err := getPathUserId(r)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetUserOperations", err, http.StatusBadRequest)
+		return
+	}
+
+	page, err := getQueryInt(r, "page")
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetUserOperations", err, http.StatusBadRequest)
+		return
+	}
+
+	limit := int64(-1)
+	if r.URL.Query().Get("limit") != "" {
+		limit, err = getQueryInt(r, "limit")
+		if err != nil {
+			h.writeV2Error(w, r, "HandlerV2GetUserOperations", err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	query := r.URL.Query()
+	result, err := h.app.GetUserOperations(ctx, &app.OperationLogRequest{
+		UserId:           userId,
+		OrderField:       query.Get("order_field"),
+		OrderDirection:   query.Get("order_direction"),
+		Page:             page,
+		Limit:            limit,
+		From:             query.Get("from"),
+		To:               query.Get("to"),
+		ConsistencyToken: query.Get("consistency_token"),
+	})
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetUserOperations", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2GetUserOperations", result, http.StatusOK)
+}
+
+// swagger:route GET /v2/users/{id}/balance/{date} v2 V2GetUserBalanceOnDate
+// Returns balance of user with given id at the end of given day (UTC).
+// responses:
+//   200: BalanceOnDateResponseBody (BalanceOnDate model, wrapped in SuccessResponseBody)
+//   400: ProblemResponseBody
+//   404: ProblemResponseBody
+//   422: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2GetUserBalanceOnDate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	userId, err := getPathUserId(r)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetUserBalanceOnDate", err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.app.GetUserBalanceOnDate(ctx, &app.BalanceOnDateRequest{
+		UserId: userId,
+		Date:   router.PathParam(r, pathParamDate),
+	})
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetUserBalanceOnDate", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2GetUserBalanceOnDate", result, http.StatusOK)
+}
+
+// swagger:route GET /v2/users/{id}/statement v2 V2GetUserStatement
+// Returns statement of user account for the period: opening balance, operations and closing balance.
+// responses:
+//   200: StatementResponseBody (Statement model, wrapped in SuccessResponseBody)
+//   400: ProblemResponseBody
+//   404: ProblemResponseBody
+//   422: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2GetUserStatement(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	userId, err := getPathUserId(r)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetUserStatement", err, http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	result, err := h.app.GetUserStatement(ctx, &app.StatementRequest{
+		UserId: userId,
+		From:   query.Get("from"),
+		To:     query.Get("to"),
+	})
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2GetUserStatement", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2GetUserStatement", result, http.StatusOK)
+}
+
+// swagger:route POST /v2/users/{id}/credits v2 V2CreditUserAccount
+// Adds given amount of money to account of user with given id, creates user account if it does not exist.
+// responses:
+//   200: CreditAccountResponseBody (operation with given token had already been done)
+//   201: CreditAccountResponseBody (ResultState model, wrapped in SuccessResponseBody)
+//   400: ProblemResponseBody
+//   409: ProblemResponseBody
+//   422: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2CreditUserAccount(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	userId, err := getPathUserId(r)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2CreditUserAccount", err, http.StatusBadRequest)
+		return
+	}
+
+	params := &CreditRequestV2{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	defer r.Body.Close()
+
+	err = d.Decode(params)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2CreditUserAccount", ErrJsonUnmarshalFailed, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.app.CreditUserAccount(ctx, &app.CreditAccountRequest{
+		UserId:           userId,
+		Name:             params.Name,
+		Purpose:          params.Purpose,
+		Amount:           params.Amount,
+		IdempotencyToken: params.IdempotencyToken,
+		QuoteId:          params.QuoteId,
+	})
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2CreditUserAccount", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2CreditUserAccount", result, getCreatedStatusCode(result))
+}
+
+// swagger:route POST /v2/users/{id}/withdrawals v2 V2WithdrawUserAccount
+// Withdraws given amount of money from account of user with given id.
+// responses:
+//   200: WithdrawAccountResponseBody (operation with given token had already been done)
+//   201: WithdrawAccountResponseBody (ResultState model, wrapped in SuccessResponseBody)
+//   400: ProblemResponseBody
+//   404: ProblemResponseBody
+//   409: ProblemResponseBody
+//   422: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2WithdrawUserAccount(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	userId, err := getPathUserId(r)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2WithdrawUserAccount", err, http.StatusBadRequest)
+		return
+	}
+
+	params := &WithdrawalRequestV2{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	defer r.Body.Close()
+
+	err = d.Decode(params)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2WithdrawUserAccount", ErrJsonUnmarshalFailed, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.app.WithdrawUserAccount(ctx, &app.WithdrawAccountRequest{
+		UserId:           userId,
+		Purpose:          params.Purpose,
+		Amount:           params.Amount,
+		IdempotencyToken: params.IdempotencyToken,
+		QuoteId:          params.QuoteId,
+	})
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2WithdrawUserAccount", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2WithdrawUserAccount", result, getCreatedStatusCode(result))
+}
+
+// swagger:route POST /v2/users/{id}/transfers v2 V2TransferUserMoney
+// Transfers money of user with given id to another user.
+// responses:
+//   200: MoneyTransferResponseBody (operation with given token had already been done)
+//   201: MoneyTransferResponseBody (ResultState model, wrapped in SuccessResponseBody)
+//   400: ProblemResponseBody
+//   404: ProblemResponseBody
+//   409: ProblemResponseBody
+//   422: ProblemResponseBody
+//   500: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2TransferUserMoney(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.getV2RequestContext(r)
+	defer cancel()
+
+	userId, err := getPathUserId(r)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2TransferUserMoney", err, http.StatusBadRequest)
+		return
+	}
+
+	params := &TransferRequestV2{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	defer r.Body.Close()
+
+	err = d.Decode(params)
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2TransferUserMoney", ErrJsonUnmarshalFailed, http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.app.TransferMoneyFromUserToUser(ctx, &app.MoneyTransferRequest{
+		SenderId:         userId,
+		ReceiverId:       params.ReceiverId,
+		Amount:           params.Amount,
+		Currency:         params.Currency,
+		IdempotencyToken: params.IdempotencyToken,
+		QuoteId:          params.QuoteId,
+	})
+	if err != nil {
+		h.writeV2Error(w, r, "HandlerV2TransferUserMoney", err, GetV2StatusCode(err))
+		return
+	}
+
+	h.writeV2Result(w, r, "HandlerV2TransferUserMoney", result, getCreatedStatusCode(result))
+}
+
+// swagger:route GET /v2/problems v2 V2GetProblemTypes
+// Returns catalogue of problem types (error codes), returned by api.
+// responses:
+//   200: ProblemTypesResponseBody (list of ProblemTypeDescription models, wrapped in SuccessResponseBody)
+func (h *AppHttpHandler) HandlerV2GetProblemTypes(w http.ResponseWriter, r *http.Request) {
+	catalogue := ErrorCatalogue()
+	result := make([]ProblemTypeDescription, 0, len(catalogue))
+	for _, entry := range catalogue {
+		result = append(result, ProblemTypeDescription{
+			Type:  problemTypeURIPrefix + string(entry.Code),
+			Code:  entry.Code,
+			Title: entry.Title,
+		})
+	}
+
+	h.writeV2Result(w, r, "HandlerV2GetProblemTypes", result, http.StatusOK)
+}
+
+// swagger:route GET /v2/problems/{code} v2 V2GetProblemType
+// Returns description of problem type with given code.
+// responses:
+//   200: ProblemTypeResponseBody (ProblemTypeDescription model, wrapped in SuccessResponseBody)
+//   404: ProblemResponseBody
+func (h *AppHttpHandler) HandlerV2GetProblemType(w http.ResponseWriter, r *http.Request) {
+	code := app.ErrorCode(router.PathParam(r, pathParamProblemCode))
+	for _, entry := range ErrorCatalogue() {
+		if entry.Code == code {
+			h.writeV2Result(w, r, "HandlerV2GetProblemType", &ProblemTypeDescription{
+				Type:  problemTypeURIPrefix + string(entry.Code),
+				Code:  entry.Code,
+				Title: entry.Title,
+			}, http.StatusOK)
+			return
+		}
+	}
+
+	h.writeV2Error(w, r, "HandlerV2GetProblemType", fmt.Errorf("problem code %s, err: %w", code, ErrBadPathParam), http.StatusNotFound)
+}
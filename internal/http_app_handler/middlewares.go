@@ -1,7 +1,11 @@
 package http_app_handler
 
 import (
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -10,8 +14,7 @@ func (h *AppHttpHandler) ContentTypeValidationMW(handlerFunc http.HandlerFunc, c
 	return func(w http.ResponseWriter, r *http.Request) {
 		if h := r.Header.Get("Content-Type"); h != contentType {
 			logger.Error("ContentTypeValidationMW, got wrong content type on Path %s, host %s, method:%s, content-type:%s", r.URL, r.Host, r.Method, r.Header.Get("Content-Type"))
-			err := WriteResponse(w, &ErrorResponseBody{Error: ErrUnsupportedContentType.Error()},
-				http.StatusUnsupportedMediaType)
+			err := WriteErrorResponse(w, r, ErrUnsupportedContentType, http.StatusUnsupportedMediaType)
 			if err != nil {
 				logger.Error("ContentTypeValidationMW, failed to write response on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
 			}
@@ -25,7 +28,7 @@ func (h *AppHttpHandler) ContentTypeValidationMW(handlerFunc http.HandlerFunc, c
 
 func (h *AppHttpHandler) MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
 	h.logger.Error("MethodNotAllowedHandler, got wrong method on Path %s, host %s, method:%s", r.URL, r.Host, r.Method)
-	err := WriteResponse(w, &ErrorResponseBody{Error: ErrUnsupportedMethod.Error()}, http.StatusMethodNotAllowed)
+	err := WriteErrorResponse(w, r, ErrUnsupportedMethod, http.StatusMethodNotAllowed)
 	if err != nil {
 		h.logger.Error("MethodValidMiddleware, failed to write response on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
 	}
@@ -40,3 +43,30 @@ func (h *AppHttpHandler) AccessLogMW(handler http.HandlerFunc) http.HandlerFunc
 		h.logger.Info("AccessLogMW, remote_host: %s, method: %s, url: %s, elapsed_time: %s", r.Host, r.Method, r.URL, time.Since(start))
 	}
 }
+
+//RateLimitMW limits rate of requests of each client address, admin routes are not limited,
+//so health and config stay available to operators
+func (h *AppHttpHandler) RateLimitMW(handler http.HandlerFunc) http.HandlerFunc {
+	logger := h.logger
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, pathPrefixAdminV2) {
+			client := r.RemoteAddr
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				client = host
+			}
+
+			if allowed, retryAfter := h.limiter.Allow(client); !allowed {
+				logger.Error("RateLimitMW, rate limit exceeded on Path %s, host %s, method:%s, client:%s", r.URL, r.Host, r.Method, client)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				err := WriteErrorResponse(w, r, ErrRateLimitExceeded, http.StatusTooManyRequests)
+				if err != nil {
+					logger.Error("RateLimitMW, failed to write response on Path %s, host %s, method:%s, err:%s", r.URL, r.Host, r.Method, err.Error())
+				}
+
+				return
+			}
+		}
+
+		handler(w, r)
+	}
+}
@@ -1,10 +1,100 @@
 package http_app_handler
 
+import (
+	"encoding/json"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/breaker"
+	"net/http"
+	"time"
+)
+
 //Error response wrapper
 //swagger:model ErrorResponseBody
 type ErrorResponseBody struct {
 	// Example: "user with given id does not exist"
 	Error string `json:"error"`
+	// stable machine-readable error code
+	// Example: USER_NOT_FOUND
+	Code app.ErrorCode `json:"code"`
+}
+
+//NewErrorResponseBody creates error response body with error code, found in error catalogue
+func NewErrorResponseBody(err error) *ErrorResponseBody {
+	return &ErrorResponseBody{Error: err.Error(), Code: LookupErrorCode(err).Code}
+}
+
+//RFC 7807 problem details response, returned with "application/problem+json" content type
+//swagger:model ProblemResponseBody
+type ProblemResponseBody struct {
+	//URI reference, identifying the problem type
+	//example: /v2/problems/INSUFFICIENT_FUNDS
+	Type string `json:"type"`
+	//short human-readable summary of the problem type
+	//example: Insufficient funds
+	Title string `json:"title"`
+	//http status code
+	//example: 409
+	Status int `json:"status"`
+	//human-readable explanation of this occurrence of the problem
+	//example: user with specified id does not have enough money on balance
+	Detail string `json:"detail"`
+	//URI reference, identifying this occurrence of the problem
+	//example: /v2/users/1/withdrawals
+	Instance string `json:"instance,omitempty"`
+	//stable machine-readable error code
+	//example: INSUFFICIENT_FUNDS
+	Code app.ErrorCode `json:"code"`
+	//extension members, specific to the problem type (e.g. "balance" and "amount" for INSUFFICIENT_FUNDS)
+	//example: {"balance": "10", "amount": "100"}
+	Extensions map[string]interface{} `json:"-"`
+}
+
+//MarshalJSON writes extension members on the top level of problem details object, as RFC 7807 requires
+func (p ProblemResponseBody) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+6)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	members["detail"] = p.Detail
+	members["code"] = p.Code
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+
+	return json.Marshal(members)
+}
+
+//NewProblemResponseBody creates problem details of given error, occurred on request
+func NewProblemResponseBody(r *http.Request, err error, httpCode int) *ProblemResponseBody {
+	entry := LookupErrorCode(err)
+
+	return &ProblemResponseBody{
+		Type:       problemTypeURIPrefix + string(entry.Code),
+		Title:      entry.Title,
+		Status:     httpCode,
+		Detail:     err.Error(),
+		Instance:   r.URL.Path,
+		Code:       entry.Code,
+		Extensions: app.GetErrorDetails(err),
+	}
+}
+
+//swagger:model ProblemTypeDescription
+//ProblemTypeDescription describes known problem type (error code)
+type ProblemTypeDescription struct {
+	//URI reference, identifying the problem type
+	//example: /v2/problems/INSUFFICIENT_FUNDS
+	Type string `json:"type"`
+	//stable machine-readable error code
+	//example: INSUFFICIENT_FUNDS
+	Code app.ErrorCode `json:"code"`
+	//short human-readable summary of the problem type
+	//example: Insufficient funds
+	Title string `json:"title"`
 }
 
 //Successful results wrapper
@@ -13,3 +103,219 @@ type SuccessResponseBody struct {
 	// Example: "{"balance":"100", "currency":"RUB"}"
 	Result interface{} `json:"result"`
 }
+
+//swagger:model CreditRequestV2
+//CreditRequestV2 represents a request body to credit account of user, specified in path
+type CreditRequestV2 struct {
+	//user name to be shown to other users
+	//example: Mr. Jones
+	Name string `json:"name"`
+	//money adding purpose
+	//example: payment from debit card
+	Purpose string `json:"purpose"`
+	//amount of money to be Added to account
+	//required: true
+	//example: 100
+	Amount string `json:"amount"`
+	//unique operation token (must be unique for any operation that changes data)
+	//required: true
+	//example: 123456789
+	IdempotencyToken string `json:"idempotency_token"`
+	//identifier of exchange rate quote, operation amount is the base currency amount of quote, locked at quote creation
+	//required: false
+	//example: 0b1c6f2e-8d7a-4a53-9d1e-3f1f1c5b2a7e
+	QuoteId string `json:"quote_id,omitempty"`
+}
+
+//swagger:model WithdrawalRequestV2
+//WithdrawalRequestV2 represents a request body to withdraw money from account of user, specified in path
+type WithdrawalRequestV2 struct {
+	//money withdraw purpose
+	//example: advertisement service payment
+	Purpose string `json:"purpose"`
+	//amount of money to be Withdrawn
+	//required: true
+	//example: 100
+	Amount string `json:"amount"`
+	//unique operation token (must be unique for any operation that changes data)
+	//required: true
+	//example: 123456789
+	IdempotencyToken string `json:"idempotency_token"`
+	//identifier of exchange rate quote, operation amount is the base currency amount of quote, locked at quote creation
+	//required: false
+	//example: 0b1c6f2e-8d7a-4a53-9d1e-3f1f1c5b2a7e
+	QuoteId string `json:"quote_id,omitempty"`
+}
+
+//swagger:model TransferRequestV2
+//TransferRequestV2 represents a request body to transfer money from user, specified in path, to another user
+type TransferRequestV2 struct {
+	//identifier of party receiving money
+	//required: true
+	//example: 2
+	ReceiverId int64 `json:"receiver_id"`
+	//amount of money to be sent to another user
+	//required: true
+	//example: 100
+	Amount string `json:"amount"`
+	//currency of amount. Balances are kept in base currency, so amount in other currency is converted to base currency
+	//by stored rate snapshot, receiver is credited with converted amount less conversion spread
+	//required: false
+	//default: RUB
+	//example: USD
+	Currency string `json:"currency,omitempty"`
+	//unique operation token (must be unique for any operation that changes data)
+	//required: true
+	//example: 123456789
+	IdempotencyToken string `json:"idempotency_token"`
+	//identifier of exchange rate quote, operation amount is the base currency amount of quote, locked at quote creation
+	//required: false
+	//example: 0b1c6f2e-8d7a-4a53-9d1e-3f1f1c5b2a7e
+	QuoteId string `json:"quote_id,omitempty"`
+}
+
+//swagger:model BonusGrantRequestV2
+//BonusGrantRequestV2 represents a request body to grant promotional bonus to user, specified in path
+type BonusGrantRequestV2 struct {
+	//amount of bonus in "RUB"
+	//required: true
+	//example: 100
+	Amount string `json:"amount"`
+	//date, the bonus expires at, in RFC 3339 format
+	//required: true
+	//example: 2020-09-15T00:00:00Z
+	ExpiresAt time.Time `json:"expires_at"`
+	//bonus grant purpose
+	//example: promo campaign
+	Purpose string `json:"purpose"`
+	//unique operation token (must be unique for any operation that changes data)
+	//required: true
+	//example: 123456789
+	IdempotencyToken string `json:"idempotency_token"`
+}
+
+//swagger:model ScheduleRequestV2
+//ScheduleRequestV2 represents a request body to create one-off or recurring payment of user, specified in path
+type ScheduleRequestV2 struct {
+	//type of scheduled operation
+	//required: true
+	//enum: withdraw,transfer
+	//example: withdraw
+	OperationType string `json:"operation_type"`
+	//identifier of user receiving money, required for transfers
+	//example: 2
+	ReceiverId int64 `json:"receiver_id"`
+	//amount of money to be withdrawn or transferred on every run
+	//required: true
+	//example: 100
+	Amount string `json:"amount"`
+	//purpose of scheduled withdraw
+	//example: monthly subscription
+	Purpose string `json:"purpose"`
+	//type of schedule
+	//required: true
+	//enum: once,interval,cron
+	//example: cron
+	ScheduleType string `json:"schedule_type"`
+	//time of one-off payment, or time of the first run for interval and cron schedules
+	//example: 2020-08-10T10:00:00Z
+	RunAt *time.Time `json:"run_at"`
+	//interval between runs in seconds, required for interval schedule
+	//example: 86400
+	IntervalSeconds int64 `json:"interval_seconds"`
+	//standard 5-field cron expression, required for cron schedule
+	//example: 0 10 1 * *
+	CronExpression string `json:"cron_expression"`
+	//max number of attempts of every run
+	//default: 1
+	//example: 3
+	MaxAttempts int `json:"max_attempts"`
+	//interval in seconds before first retry, doubles on every next retry
+	//default: 60
+	//example: 60
+	RetryIntervalSeconds int64 `json:"retry_interval_seconds"`
+	//unique token of schedule creation request
+	//required: true
+	//example: 123456789
+	IdempotencyToken string `json:"idempotency_token"`
+}
+
+//Health statuses of service
+const (
+	HealthStatusOk       = "ok"
+	HealthStatusDegraded = "degraded"
+)
+
+//swagger:model HealthStatus
+//HealthStatus represents state of service dependencies, protected by circuit breakers
+type HealthStatus struct {
+	//ok if all breakers are closed, degraded otherwise. Service keeps working in degraded mode
+	//example: ok
+	Status string `json:"status"`
+	//states of circuit breakers
+	Breakers []breaker.Status `json:"breakers"`
+}
+
+//swagger:model CurrencyDescription
+//CurrencyDescription represents currency, accepted as currency of balance
+type CurrencyDescription struct {
+	//ISO 4217 code of currency
+	//example: USD
+	Code string `json:"code"`
+	//name of currency, empty for currencies without ISO 4217 name
+	//example: US Dollar
+	Name string `json:"name"`
+	//number of digits after decimal point in amounts of currency
+	//example: 2
+	MinorUnits int32 `json:"minor_units"`
+	//amount of currency for one unit of base currency
+	//example: 0.013612547812345679
+	Rate string `json:"rate"`
+}
+
+//swagger:model CurrenciesCatalogue
+//CurrenciesCatalogue represents currencies, supported by exchange rates service
+type CurrenciesCatalogue struct {
+	//base currency, balances are kept in
+	//example: RUB
+	Base string `json:"base"`
+	//date of rates
+	//example: 2020-08-15
+	RatesDate string `json:"rates_date"`
+	//source of rates
+	//example: https://api.exchangeratesapi.io/latest?base=RUB
+	Source string `json:"source"`
+	//currency, rates were fetched in, if exchange rates service does not support base currency,
+	//rates of currencies are cross rates through it
+	//example: EUR
+	Pivot string `json:"pivot,omitempty"`
+	//true if last known rates are used, because exchange rates service is unavailable
+	//example: false
+	Stale bool `json:"stale"`
+	//currencies, sorted by code, base currency included
+	Currencies []CurrencyDescription `json:"currencies"`
+}
+
+//swagger:model RatesList
+//RatesList represents current rates of currencies against base currency
+type RatesList struct {
+	//base currency, balances are kept in
+	//example: RUB
+	Base string `json:"base"`
+	//date of rates
+	//example: 2020-08-15
+	Date string `json:"date"`
+	//source of rates
+	//example: https://api.exchangeratesapi.io/latest?base=RUB
+	Source string `json:"source"`
+	//currency, rates were fetched in, if exchange rates service does not support base currency,
+	//rates of currencies are cross rates through it
+	//example: EUR
+	Pivot string `json:"pivot,omitempty"`
+	//true if last known rates are used, because exchange rates service is unavailable
+	//example: false
+	Stale bool `json:"stale"`
+	//amounts of currencies for one unit of base currency by currency code
+	//example: {"EUR":"0.011534","USD":"0.013612547812345679"}
+	Rates map[string]string `json:"rates"`
+}
@@ -0,0 +1,60 @@
+package http_app_handler
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/http_handler_router"
+	"job-backend-trainee-assignment/internal/logger"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppHttpHandler_V2_ExportUserStatement_WithStubApp(t *testing.T) {
+	dummyLogger := &logger.DummyLogger{}
+
+	r, err := router.NewRouter(dummyLogger)
+	require.NoError(t, err, "NewRouter must not return error")
+
+	appHandler, err := NewHttpAppHandler(dummyLogger, r, &app.StubBillingAppCommon{}, &Config{RequestHandleTimeout: 5 * time.Second})
+	require.NoError(t, err, "NewHttpAppHandler must not return error")
+
+	t.Run("positive path, csv", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v2/users/1/statement/export?from=2020-08-01&to=2020-08-31", nil)
+		require.NoError(t, err, "must be able to create request obj")
+		rr := httptest.NewRecorder()
+
+		appHandler.ServeHTTP(rr, req)
+
+		responseBody, err := ioutil.ReadAll(rr.Body)
+		require.NoError(t, err, "Must be able to read response body")
+
+		assert.Equal(t, http.StatusOK, rr.Code, "response status mush match")
+		assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"), "content type must match")
+		assert.Equal(t, `attachment; filename="statement_1_2020-08-01_2020-08-31.csv"`, rr.Header().Get("Content-Disposition"),
+			"content disposition must match")
+		assert.Equal(t, "operation_id,date,purpose,amount,balance\n"+
+			"1,2020-08-11T07:23:58Z,incoming payment,10,10\n"+
+			"3,2020-08-11T07:23:58Z,transfer to Mr. Jones,-10,0\n", string(responseBody), "response body must match")
+	})
+
+	t.Run("positive path, html", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v2/users/1/statement/export?from=2020-08-01&to=2020-08-31&format=html", nil)
+		require.NoError(t, err, "must be able to create request obj")
+		rr := httptest.NewRecorder()
+
+		appHandler.ServeHTTP(rr, req)
+
+		responseBody, err := ioutil.ReadAll(rr.Body)
+		require.NoError(t, err, "Must be able to read response body")
+
+		assert.Equal(t, http.StatusOK, rr.Code, "response status mush match")
+		assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"), "content type must match")
+		assert.True(t, strings.Contains(string(responseBody), "User: Mr. Smith (id 1)"), "statement must contain user name")
+		assert.True(t, strings.Contains(string(responseBody), "Closing balance: 0.00 RUB"), "statement must contain totals")
+	})
+}
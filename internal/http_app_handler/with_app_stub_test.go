@@ -45,7 +45,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 			ReqContentType: contentTypeApplicationJson,
 			ReqBody:        &app.BalanceRequest{},
 			RespStatus:     http.StatusMethodNotAllowed,
-			RespBody:       &ErrorResponseBody{Error: ErrUnsupportedMethod.Error()},
+			RespBody:       NewErrorResponseBody(ErrUnsupportedMethod),
 		},
 		{
 			CaseName:       "negative path, handler GetUserBalance, unsupported content-type",
@@ -54,7 +54,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 			ReqContentType: "UNKNOWN_CONTENT_TYPE",
 			ReqBody:        &app.BalanceRequest{},
 			RespStatus:     http.StatusUnsupportedMediaType,
-			RespBody:       &ErrorResponseBody{Error: ErrUnsupportedContentType.Error()},
+			RespBody:       NewErrorResponseBody(ErrUnsupportedContentType),
 		},
 		{
 			CaseName:       "negative path, handler GetUserBalance, app method returned error (user not exist)",
@@ -65,7 +65,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 				UserId: 100500,
 			},
 			RespStatus: http.StatusBadRequest,
-			RespBody:   &ErrorResponseBody{Error: app.ErrUserDoesNotExist.Error()},
+			RespBody:   NewErrorResponseBody(app.ErrUserDoesNotExist),
 		},
 		{
 			CaseName:       "negative path, handler GetUserBalance, corrupted request json",
@@ -74,7 +74,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 			ReqContentType: contentTypeApplicationJson,
 			ReqBody:        `{"some":"corrupted json}`,
 			RespStatus:     http.StatusBadRequest,
-			RespBody:       &ErrorResponseBody{Error: ErrJsonUnmarshalFailed.Error()},
+			RespBody:       NewErrorResponseBody(ErrJsonUnmarshalFailed),
 		},
 
 		//Credit User Account Cases
@@ -99,7 +99,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 			ReqContentType: contentTypeApplicationJson,
 			ReqBody:        &app.CreditAccountRequest{},
 			RespStatus:     http.StatusMethodNotAllowed,
-			RespBody:       &ErrorResponseBody{Error: ErrUnsupportedMethod.Error()},
+			RespBody:       NewErrorResponseBody(ErrUnsupportedMethod),
 		},
 		{
 			CaseName:       "negative path, handler CreditUserAccount, unsupported content-type",
@@ -108,7 +108,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 			ReqContentType: "UNKNOWN_CONTENT_TYPE",
 			ReqBody:        &app.CreditAccountRequest{},
 			RespStatus:     http.StatusUnsupportedMediaType,
-			RespBody:       &ErrorResponseBody{Error: ErrUnsupportedContentType.Error()},
+			RespBody:       NewErrorResponseBody(ErrUnsupportedContentType),
 		},
 		{
 			CaseName:       "negative path, handler CreditUserAccount, corrupted request json",
@@ -117,7 +117,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 			ReqContentType: contentTypeApplicationJson,
 			ReqBody:        `{"some":"corrupted json}`,
 			RespStatus:     http.StatusBadRequest,
-			RespBody:       &ErrorResponseBody{Error: ErrJsonUnmarshalFailed.Error()},
+			RespBody:       NewErrorResponseBody(ErrJsonUnmarshalFailed),
 		},
 
 		//Withdraw User Account Cases
@@ -143,7 +143,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 			ReqContentType: contentTypeApplicationJson,
 			ReqBody:        &app.WithdrawAccountRequest{},
 			RespStatus:     http.StatusMethodNotAllowed,
-			RespBody:       &ErrorResponseBody{Error: ErrUnsupportedMethod.Error()},
+			RespBody:       NewErrorResponseBody(ErrUnsupportedMethod),
 		},
 		{
 			CaseName:       "negative path, handler WithdrawUserAccount, unsupported content-type",
@@ -152,7 +152,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 			ReqContentType: "UNKNOWN_CONTENT_TYPE",
 			ReqBody:        &app.WithdrawAccountRequest{},
 			RespStatus:     http.StatusUnsupportedMediaType,
-			RespBody:       &ErrorResponseBody{Error: ErrUnsupportedContentType.Error()},
+			RespBody:       NewErrorResponseBody(ErrUnsupportedContentType),
 		},
 		{
 			CaseName:       "negative path, handler WithdrawUserAccount, app method returned error (user does not exist)",
@@ -164,7 +164,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 				IdempotencyToken: uuid.NewV4().String(),
 			},
 			RespStatus: http.StatusBadRequest,
-			RespBody:   &ErrorResponseBody{Error: app.ErrUserDoesNotExist.Error()},
+			RespBody:   NewErrorResponseBody(app.ErrUserDoesNotExist),
 		},
 		{
 			CaseName:       "negative path, handler WithdrawUserAccount, corrupted request json",
@@ -173,7 +173,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 			ReqContentType: contentTypeApplicationJson,
 			ReqBody:        `{"some":"corrupted json}`,
 			RespStatus:     http.StatusBadRequest,
-			RespBody:       &ErrorResponseBody{Error: ErrJsonUnmarshalFailed.Error()},
+			RespBody:       NewErrorResponseBody(ErrJsonUnmarshalFailed),
 		},
 
 		//Transfer User Money Cases
@@ -199,7 +199,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 			ReqContentType: contentTypeApplicationJson,
 			ReqBody:        &app.MoneyTransferRequest{},
 			RespStatus:     http.StatusMethodNotAllowed,
-			RespBody:       &ErrorResponseBody{Error: ErrUnsupportedMethod.Error()},
+			RespBody:       NewErrorResponseBody(ErrUnsupportedMethod),
 		},
 		{
 			CaseName:       "negative path, handler TransferUserMoney, unsupported content-type",
@@ -208,7 +208,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 			ReqContentType: "UNKNOWN_CONTENT_TYPE",
 			ReqBody:        &app.MoneyTransferRequest{},
 			RespStatus:     http.StatusUnsupportedMediaType,
-			RespBody:       &ErrorResponseBody{Error: ErrUnsupportedContentType.Error()},
+			RespBody:       NewErrorResponseBody(ErrUnsupportedContentType),
 		},
 		{
 			CaseName:       "negative path, handler TransferUserMoney, app method returned error (user does not exist)",
@@ -222,7 +222,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 				IdempotencyToken: uuid.NewV4().String(),
 			},
 			RespStatus: http.StatusBadRequest,
-			RespBody:   &ErrorResponseBody{Error: app.ErrMoneySenderDoesNotExist.Error()},
+			RespBody:   NewErrorResponseBody(app.ErrMoneySenderDoesNotExist),
 		},
 		{
 			CaseName:       "negative path, handler TransferUserMoney, corrupted request json",
@@ -231,7 +231,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 			ReqMethod:      http.MethodPost,
 			ReqBody:        `{"some":"corrupted json}`,
 			RespStatus:     http.StatusBadRequest,
-			RespBody:       &ErrorResponseBody{Error: ErrJsonUnmarshalFailed.Error()},
+			RespBody:       NewErrorResponseBody(ErrJsonUnmarshalFailed),
 		},
 
 		//User operation log testing
@@ -271,7 +271,7 @@ func TestAppHttpHandler_WithStubApp_Common(t *testing.T) {
 			ReqMethod:      http.MethodPost,
 			ReqBody:        `{"some":"corrupted json}`,
 			RespStatus:     http.StatusBadRequest,
-			RespBody:       &ErrorResponseBody{Error: ErrJsonUnmarshalFailed.Error()},
+			RespBody:       NewErrorResponseBody(ErrJsonUnmarshalFailed),
 		},
 	}
 
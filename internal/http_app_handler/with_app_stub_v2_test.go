@@ -0,0 +1,491 @@
+package http_app_handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/http_handler_router"
+	"job-backend-trainee-assignment/internal/logger"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestProblem(path string, err error, httpCode int) *ProblemResponseBody {
+	entry := LookupErrorCode(err)
+	return &ProblemResponseBody{
+		Type:     problemTypeURIPrefix + string(entry.Code),
+		Title:    entry.Title,
+		Status:   httpCode,
+		Detail:   err.Error(),
+		Instance: path,
+		Code:     entry.Code,
+	}
+}
+
+func TestAppHttpHandler_V2_WithStubApp_Common(t *testing.T) {
+	operationCreateDatetime, _ := time.Parse(time.RFC3339, "2020-08-11T10:23:58+03:00")
+	stubQuote, err := (&app.StubBillingAppCommon{}).CreateQuote(context.Background(), &app.QuoteRequest{From: "USD",
+		To: "RUB", Amount: "100"})
+	require.NoError(t, err)
+
+	testCases := []TestCaseWithPath{
+		//Get User Balance Cases
+		//
+		{
+			CaseName:   "positive path, handler V2GetUserBalance, Common",
+			Path:       "/v2/users/2/balance?currency=RUB",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusOK,
+			RespBody: &SuccessResponseBody{Result: app.UserBalance{
+				Balance:  "10",
+				Currency: "RUB",
+			}},
+		},
+		{
+			CaseName:   "positive path, handler V2GetUserBalance, several currencies",
+			Path:       "/v2/users/2/balance?currency=RUB&currencies=USD,UNKNOWN_CURRENCY&currencies=EUR",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusOK,
+			RespBody: &SuccessResponseBody{Result: app.UserBalance{
+				Balance:   "10",
+				Currency:  "RUB",
+				RatesDate: "2020-08-15",
+				Balances: []app.CurrencyBalance{
+					{Currency: "USD", Balance: "10"},
+					{Currency: "UNKNOWN_CURRENCY", ErrorCode: app.CodeCurrencyNotFound,
+						Error: app.ErrCurrencyDoesNotExist.Error()},
+					{Currency: "EUR", Balance: "10"},
+				},
+			}},
+		},
+		{
+			CaseName:   "negative path, handler V2GetUserBalance, user does not exist",
+			Path:       "/v2/users/100500/balance",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusNotFound,
+			RespBody:   newTestProblem("/v2/users/100500/balance", app.ErrUserDoesNotExist, http.StatusNotFound),
+		},
+		{
+			CaseName:   "negative path, handler V2GetUserBalance, bad user id",
+			Path:       "/v2/users/abc/balance",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusBadRequest,
+			RespBody:   newTestProblem("/v2/users/abc/balance", fmt.Errorf("param id, err: %w", ErrBadPathParam), http.StatusBadRequest),
+		},
+		{
+			CaseName:   "negative path, handler V2GetUserBalance, unsupported request method",
+			Path:       "/v2/users/2/balance",
+			ReqMethod:  http.MethodDelete,
+			RespStatus: http.StatusMethodNotAllowed,
+			RespBody:   newTestProblem("/v2/users/2/balance", ErrUnsupportedMethod, http.StatusMethodNotAllowed),
+		},
+
+		//Get User Operations Cases
+		//
+		{
+			CaseName:   "positive path, handler V2GetUserOperations, Common",
+			Path:       "/v2/users/1/operations?order_field=date&order_direction=desc&page=1&limit=10",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusOK,
+			RespBody: &SuccessResponseBody{Result: app.OperationsLog{
+				OperationsNum: 2,
+				Operations: []app.Operation{{
+					Id:      1,
+					UserId:  1,
+					Comment: "incoming payment",
+					Amount:  decimal.NewFromInt(10),
+					Date:    operationCreateDatetime,
+				}, {
+					Id:      3,
+					UserId:  1,
+					Comment: "transfer to Mr. Jones",
+					Amount:  decimal.NewFromInt(-10),
+					Date:    operationCreateDatetime,
+				}},
+				Page:       1,
+				PagesTotal: 1,
+			}},
+		},
+		{
+			CaseName:   "negative path, handler V2GetUserOperations, bad limit param",
+			Path:       "/v2/users/1/operations?limit=ten",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusBadRequest,
+			RespBody:   newTestProblem("/v2/users/1/operations", fmt.Errorf("param limit, err: %w", ErrBadQueryParam), http.StatusBadRequest),
+		},
+
+		//Get User Balance On Date Cases
+		//
+		{
+			CaseName:   "positive path, handler V2GetUserBalanceOnDate, Common",
+			Path:       "/v2/users/2/balance/2020-08-11",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusOK,
+			RespBody: &SuccessResponseBody{Result: app.BalanceOnDate{
+				UserId:   2,
+				Date:     "2020-08-11",
+				Balance:  "10",
+				Currency: "RUB",
+			}},
+		},
+		{
+			CaseName:   "negative path, handler V2GetUserBalanceOnDate, bad date",
+			Path:       "/v2/users/2/balance/11.08.2020",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusUnprocessableEntity,
+			RespBody:   newTestProblem("/v2/users/2/balance/11.08.2020", app.ErrBadDateFormat, http.StatusUnprocessableEntity),
+		},
+
+		//Get User Statement Cases
+		//
+		{
+			CaseName:   "positive path, handler V2GetUserStatement, Common",
+			Path:       "/v2/users/1/statement?from=2020-08-01&to=2020-08-31",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusOK,
+			RespBody: &SuccessResponseBody{Result: app.Statement{
+				UserId:         1,
+				From:           "2020-08-01",
+				To:             "2020-08-31",
+				Currency:       "RUB",
+				OpeningBalance: "0",
+				Operations: []app.Operation{{
+					Id:      1,
+					UserId:  1,
+					Comment: "incoming payment",
+					Amount:  decimal.NewFromInt(10),
+					Date:    operationCreateDatetime,
+				}, {
+					Id:      3,
+					UserId:  1,
+					Comment: "transfer to Mr. Jones",
+					Amount:  decimal.NewFromInt(-10),
+					Date:    operationCreateDatetime,
+				}},
+				TotalCredits:   "10",
+				TotalDebits:    "-10",
+				ClosingBalance: "0",
+				Consistent:     true,
+				Discrepancy:    "0",
+			}},
+		},
+		{
+			CaseName:   "negative path, handler V2GetUserStatement, period start is after end",
+			Path:       "/v2/users/1/statement?from=2020-08-31&to=2020-08-01",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusUnprocessableEntity,
+			RespBody:   newTestProblem("/v2/users/1/statement", app.ErrPeriodStartIsAfterEnd, http.StatusUnprocessableEntity),
+		},
+
+		//Export User Statement Cases
+		//
+		{
+			CaseName:   "negative path, handler V2ExportUserStatement, unsupported format",
+			Path:       "/v2/users/1/statement/export?from=2020-08-01&to=2020-08-31&format=pdf",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusBadRequest,
+			RespBody:   newTestProblem("/v2/users/1/statement/export", fmt.Errorf("param format, err: %w", ErrBadQueryParam), http.StatusBadRequest),
+		},
+		{
+			CaseName:   "negative path, handler V2ExportUserStatement, bad date",
+			Path:       "/v2/users/1/statement/export?from=01.08.2020&to=2020-08-31",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusUnprocessableEntity,
+			RespBody:   newTestProblem("/v2/users/1/statement/export", app.ErrBadDateFormat, http.StatusUnprocessableEntity),
+		},
+		{
+			CaseName:   "negative path, handler V2ExportUserStatement, user does not exist",
+			Path:       "/v2/users/100500/statement/export?from=2020-08-01&to=2020-08-31",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusNotFound,
+			RespBody:   newTestProblem("/v2/users/100500/statement/export", app.ErrUserDoesNotExist, http.StatusNotFound),
+		},
+
+		//Credit User Account Cases
+		//
+		{
+			CaseName:       "positive path, handler V2CreditUserAccount, Common",
+			Path:           "/v2/users/1/credits",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody: &CreditRequestV2{
+				Amount:           "10",
+				IdempotencyToken: uuid.NewV4().String(),
+			},
+			RespStatus: http.StatusCreated,
+			RespBody:   &SuccessResponseBody{Result: app.ResultState{State: app.MsgAccountCreditingDone}},
+		},
+		{
+			CaseName:       "negative path, handler V2CreditUserAccount, unknown json field",
+			Path:           "/v2/users/1/credits",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody:        `{"user_id":1, "amount":"10"}`,
+			RespStatus:     http.StatusBadRequest,
+			RespBody:       newTestProblem("/v2/users/1/credits", ErrJsonUnmarshalFailed, http.StatusBadRequest),
+		},
+		{
+			CaseName:       "negative path, handler V2CreditUserAccount, unsupported content-type",
+			Path:           "/v2/users/1/credits",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: "UNKNOWN_CONTENT_TYPE",
+			ReqBody:        &CreditRequestV2{},
+			RespStatus:     http.StatusUnsupportedMediaType,
+			RespBody:       newTestProblem("/v2/users/1/credits", ErrUnsupportedContentType, http.StatusUnsupportedMediaType),
+		},
+
+		//Withdraw User Account Cases
+		//
+		{
+			CaseName:       "positive path, handler V2WithdrawUserAccount, Common",
+			Path:           "/v2/users/2/withdrawals",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody: &WithdrawalRequestV2{
+				Amount:           "10",
+				IdempotencyToken: uuid.NewV4().String(),
+			},
+			RespStatus: http.StatusCreated,
+			RespBody:   &SuccessResponseBody{Result: app.ResultState{State: app.MsgAccountWithdrawDone}},
+		},
+		{
+			CaseName:       "negative path, handler V2WithdrawUserAccount, not enough money",
+			Path:           "/v2/users/1/withdrawals",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody: &WithdrawalRequestV2{
+				Amount:           "10",
+				IdempotencyToken: uuid.NewV4().String(),
+			},
+			RespStatus: http.StatusConflict,
+			RespBody:   newTestProblem("/v2/users/1/withdrawals", app.ErrUserDoesNotHaveEnoughMoney, http.StatusConflict),
+		},
+		{
+			CaseName:       "negative path, handler V2WithdrawUserAccount, user does not exist",
+			Path:           "/v2/users/3/withdrawals",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody: &WithdrawalRequestV2{
+				Amount:           "10",
+				IdempotencyToken: uuid.NewV4().String(),
+			},
+			RespStatus: http.StatusNotFound,
+			RespBody:   newTestProblem("/v2/users/3/withdrawals", app.ErrUserDoesNotExist, http.StatusNotFound),
+		},
+
+		//Transfer User Money Cases
+		//
+		{
+			CaseName:       "positive path, handler V2TransferUserMoney, Common",
+			Path:           "/v2/users/2/transfers",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody: &TransferRequestV2{
+				ReceiverId:       1,
+				Amount:           "10",
+				IdempotencyToken: uuid.NewV4().String(),
+			},
+			RespStatus: http.StatusCreated,
+			RespBody:   &SuccessResponseBody{Result: app.ResultState{State: app.MsgMoneyTransferDone}},
+		},
+		{
+			CaseName:       "negative path, handler V2TransferUserMoney, receiver does not exist",
+			Path:           "/v2/users/2/transfers",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody: &TransferRequestV2{
+				ReceiverId:       3,
+				Amount:           "10",
+				IdempotencyToken: uuid.NewV4().String(),
+			},
+			RespStatus: http.StatusNotFound,
+			RespBody:   newTestProblem("/v2/users/2/transfers", app.ErrMoneyReceiverDoesNotExist, http.StatusNotFound),
+		},
+		{
+			CaseName:       "negative path, handler V2TransferUserMoney, not enough money",
+			Path:           "/v2/users/1/transfers",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody: &TransferRequestV2{
+				ReceiverId:       2,
+				Amount:           "10",
+				IdempotencyToken: uuid.NewV4().String(),
+			},
+			RespStatus: http.StatusConflict,
+			RespBody:   newTestProblem("/v2/users/1/transfers", app.ErrUserDoesNotHaveEnoughMoney, http.StatusConflict),
+		},
+		{
+			CaseName:       "negative path, handler V2WithdrawUserAccount, quote is expired",
+			Path:           "/v2/users/2/withdrawals",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody: &WithdrawalRequestV2{
+				QuoteId:          "2",
+				IdempotencyToken: uuid.NewV4().String(),
+			},
+			RespStatus: http.StatusConflict,
+			RespBody:   newTestProblem("/v2/users/2/withdrawals", app.ErrQuoteIsExpired, http.StatusConflict),
+		},
+		{
+			CaseName:       "negative path, handler V2TransferUserMoney, quote does not exist",
+			Path:           "/v2/users/2/transfers",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody: &TransferRequestV2{
+				ReceiverId:       1,
+				QuoteId:          "3",
+				IdempotencyToken: uuid.NewV4().String(),
+			},
+			RespStatus: http.StatusNotFound,
+			RespBody:   newTestProblem("/v2/users/2/transfers", app.ErrQuoteDoesNotExist, http.StatusNotFound),
+		},
+
+		//Quotes Cases
+		//
+		{
+			CaseName:       "positive path, handler V2CreateQuote, Common",
+			Path:           "/v2/quotes",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody:        &app.QuoteRequest{From: "USD", To: "RUB", Amount: "100"},
+			RespStatus:     http.StatusCreated,
+			RespBody:       &SuccessResponseBody{Result: stubQuote},
+		},
+		{
+			CaseName:       "negative path, handler V2CreateQuote, currencies do not include base currency",
+			Path:           "/v2/quotes",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody:        &app.QuoteRequest{From: "USD", To: "EUR", Amount: "100"},
+			RespStatus:     http.StatusUnprocessableEntity,
+			RespBody:       newTestProblem("/v2/quotes", app.ErrQuoteCurrencyIsNotBase, http.StatusUnprocessableEntity),
+		},
+		{
+			CaseName:   "positive path, handler V2GetQuote, Common",
+			Path:       "/v2/quotes/1",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusOK,
+			RespBody:   &SuccessResponseBody{Result: stubQuote},
+		},
+		{
+			CaseName:   "negative path, handler V2GetQuote, quote does not exist",
+			Path:       "/v2/quotes/3",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusNotFound,
+			RespBody:   newTestProblem("/v2/quotes/3", app.ErrQuoteDoesNotExist, http.StatusNotFound),
+		},
+
+		//Bonuses Cases
+		//
+		{
+			CaseName:       "positive path, handler V2GrantUserBonus, Common",
+			Path:           "/v2/users/2/bonuses",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody: &BonusGrantRequestV2{
+				Amount:           "100",
+				ExpiresAt:        operationCreateDatetime.AddDate(0, 1, 0),
+				Purpose:          "promo campaign",
+				IdempotencyToken: uuid.NewV4().String(),
+			},
+			RespStatus: http.StatusCreated,
+			RespBody:   &SuccessResponseBody{Result: app.ResultState{State: app.MsgBonusGrantDone}},
+		},
+		{
+			CaseName:       "negative path, handler V2GrantUserBonus, expiration is not set",
+			Path:           "/v2/users/2/bonuses",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody:        `{"amount":"100", "idempotency_token":"1"}`,
+			RespStatus:     http.StatusUnprocessableEntity,
+			RespBody: newTestProblem("/v2/users/2/bonuses", app.ErrBonusExpirationIsNotInFuture,
+				http.StatusUnprocessableEntity),
+		},
+		{
+			CaseName:       "negative path, handler V2GrantUserBonus, user does not exist",
+			Path:           "/v2/users/3/bonuses",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody: &BonusGrantRequestV2{
+				Amount:           "100",
+				ExpiresAt:        operationCreateDatetime.AddDate(0, 1, 0),
+				IdempotencyToken: uuid.NewV4().String(),
+			},
+			RespStatus: http.StatusNotFound,
+			RespBody:   newTestProblem("/v2/users/3/bonuses", app.ErrUserDoesNotExist, http.StatusNotFound),
+		},
+		{
+			CaseName:   "positive path, handler V2GetUserBonuses, Common",
+			Path:       "/v2/users/1/bonuses",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusOK,
+			RespBody: &SuccessResponseBody{Result: app.BonusBalance{UserId: 1, BonusBalance: "0", Currency: "RUB",
+				Grants: []app.BonusGrant{}}},
+		},
+		{
+			CaseName:   "negative path, handler V2GetUserBonuses, user does not exist",
+			Path:       "/v2/users/3/bonuses",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusNotFound,
+			RespBody:   newTestProblem("/v2/users/3/bonuses", app.ErrUserDoesNotExist, http.StatusNotFound),
+		},
+	}
+
+	dummyLogger := &logger.DummyLogger{}
+
+	commonApp := &app.StubBillingAppCommon{}
+
+	r, err := router.NewRouter(dummyLogger)
+	require.NoError(t, err, "NewRouter must not return error")
+
+	appHandler, err := NewHttpAppHandler(dummyLogger, r, commonApp, &Config{RequestHandleTimeout: 5 * time.Second})
+	require.NoError(t, err, "NewHttpAppHandler must not return error")
+
+	for caseIdx, tc := range testCases {
+		t.Logf("\ttesting case:%d \"%s\"", caseIdx, tc.CaseName)
+		{
+			var req *http.Request
+			if tc.ReqBody == nil {
+				req, err = http.NewRequest(tc.ReqMethod, tc.Path, nil)
+				require.NoError(t, err, "must be able to create request obj")
+			} else if str, ok := tc.ReqBody.(string); ok {
+				buf := bytes.NewBufferString(str)
+				req, err = http.NewRequest(tc.ReqMethod, tc.Path, buf)
+				require.NoError(t, err, "must be able to create request obj")
+			} else {
+				b, err := json.Marshal(tc.ReqBody)
+				require.NoError(t, err, "must be able to marshal request body")
+				buf := bytes.NewBuffer(b)
+				req, err = http.NewRequest(tc.ReqMethod, tc.Path, buf)
+				require.NoError(t, err, "must be able to create request obj")
+			}
+
+			if tc.ReqContentType != "" {
+				req.Header.Add("Content-Type", tc.ReqContentType)
+			}
+			rr := httptest.NewRecorder()
+
+			appHandler.ServeHTTP(rr, req)
+
+			responseBody, err := ioutil.ReadAll(rr.Body)
+			require.NoError(t, err, "Must be able to read response body")
+
+			expectedBody, err := json.Marshal(tc.RespBody)
+			require.NoError(t, err, "must be able to unmarshal response body")
+			assert.JSONEq(t, string(expectedBody), string(responseBody), "\t\tresponse body must match")
+			assert.Equal(t, tc.RespStatus, rr.Code, "\t\tresponse status mush match")
+			if _, isProblem := tc.RespBody.(*ProblemResponseBody); isProblem {
+				assert.Equal(t, contentTypeApplicationProblemJson, rr.Header().Get("Content-Type"), "\t\tproblem content type must match")
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package http_app_handler
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/breaker"
+	"job-backend-trainee-assignment/internal/http_handler_router"
+	"job-backend-trainee-assignment/internal/logger"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAppHttpHandler_V2_WithBreakers(t *testing.T) {
+	dummyLogger := &logger.DummyLogger{}
+
+	r, err := router.NewRouter(dummyLogger)
+	require.NoError(t, err, "NewRouter must not return error")
+
+	appHandler, err := NewHttpAppHandler(dummyLogger, r, &app.StubBillingAppCommon{}, &Config{RequestHandleTimeout: 5 * time.Second})
+	require.NoError(t, err, "NewHttpAppHandler must not return error")
+
+	err = appHandler.RegisterHealthRoute(nil)
+	assert.Error(t, err, "RegisterHealthRoute must return error on nil breaker")
+
+	cacheBreaker, err := breaker.NewBreaker("redis", &breaker.Config{FailureThreshold: 1, OpenTimeout: time.Hour})
+	require.NoError(t, err, "NewBreaker must not return error")
+	exchangerBreaker, err := breaker.NewBreaker("exchanger", nil)
+	require.NoError(t, err, "NewBreaker must not return error")
+
+	err = appHandler.RegisterHealthRoute(cacheBreaker, exchangerBreaker)
+	require.NoError(t, err, "RegisterHealthRoute must not return error")
+
+	getHealth := func(t *testing.T) *HealthStatus {
+		req, err := http.NewRequest(http.MethodGet, "/v2/admin/health", nil)
+		require.NoError(t, err, "must be able to create request obj")
+		rr := httptest.NewRecorder()
+
+		appHandler.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, "response status mush match")
+
+		responseBody, err := ioutil.ReadAll(rr.Body)
+		require.NoError(t, err, "Must be able to read response body")
+
+		result := &HealthStatus{}
+		err = json.Unmarshal(responseBody, &SuccessResponseBody{Result: result})
+		require.NoError(t, err, "must be able to unmarshal response body")
+		return result
+	}
+
+	t.Run("positive path, all breakers are closed", func(t *testing.T) {
+		health := getHealth(t)
+		assert.Equal(t, HealthStatusOk, health.Status, "status must match")
+		require.Len(t, health.Breakers, 2)
+		assert.Equal(t, "redis", health.Breakers[0].Name)
+		assert.Equal(t, breaker.StateClosed, health.Breakers[0].State)
+		assert.Nil(t, health.Breakers[0].OpenedAt)
+	})
+
+	t.Run("positive path, open breaker degrades status", func(t *testing.T) {
+		require.NoError(t, cacheBreaker.Allow())
+		cacheBreaker.Done(true)
+
+		health := getHealth(t)
+		assert.Equal(t, HealthStatusDegraded, health.Status, "status must match")
+		require.Len(t, health.Breakers, 2)
+		assert.Equal(t, breaker.StateOpen, health.Breakers[0].State)
+		assert.NotNil(t, health.Breakers[0].OpenedAt)
+		assert.Equal(t, breaker.StateClosed, health.Breakers[1].State)
+	})
+}
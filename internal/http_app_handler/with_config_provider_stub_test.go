@@ -0,0 +1,70 @@
+package http_app_handler
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/http_handler_router"
+	"job-backend-trainee-assignment/internal/logger"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type StubConfigProvider struct {
+}
+
+func (p *StubConfigProvider) EffectiveConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"log_params": map[string]interface{}{"log_level": 1},
+		"db_params":  map[string]interface{}{"password": "******"},
+	}
+}
+
+func TestAppHttpHandler_V2_WithStubConfigProvider(t *testing.T) {
+	dummyLogger := &logger.DummyLogger{}
+
+	r, err := router.NewRouter(dummyLogger)
+	require.NoError(t, err, "NewRouter must not return error")
+
+	appHandler, err := NewHttpAppHandler(dummyLogger, r, &app.StubBillingAppCommon{}, &Config{RequestHandleTimeout: 5 * time.Second})
+	require.NoError(t, err, "NewHttpAppHandler must not return error")
+
+	err = appHandler.RegisterAdminRoutes(nil)
+	assert.Error(t, err, "RegisterAdminRoutes must return error on nil provider")
+
+	provider := &StubConfigProvider{}
+	err = appHandler.RegisterAdminRoutes(provider)
+	require.NoError(t, err, "RegisterAdminRoutes must not return error")
+
+	t.Run("positive path, handler V2GetEffectiveConfig", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v2/admin/config", nil)
+		require.NoError(t, err, "must be able to create request obj")
+		rr := httptest.NewRecorder()
+
+		appHandler.ServeHTTP(rr, req)
+
+		responseBody, err := ioutil.ReadAll(rr.Body)
+		require.NoError(t, err, "Must be able to read response body")
+
+		expectedBody, err := json.Marshal(&SuccessResponseBody{Result: provider.EffectiveConfig()})
+		require.NoError(t, err, "must be able to marshal response body")
+		assert.JSONEq(t, string(expectedBody), string(responseBody), "response body must match")
+		assert.Equal(t, http.StatusOK, rr.Code, "response status mush match")
+	})
+
+	t.Run("positive path, SetConfig changes handler config", func(t *testing.T) {
+		err := appHandler.SetConfig(&Config{RequestHandleTimeout: time.Second, ExportPageSize: 10})
+		require.NoError(t, err, "SetConfig must not return error")
+		assert.Equal(t, Config{RequestHandleTimeout: time.Second, ExportPageSize: 10}, appHandler.GetConfig(), "config must match")
+	})
+
+	t.Run("negative path, SetConfig with bad config", func(t *testing.T) {
+		err := appHandler.SetConfig(&Config{RequestHandleTimeout: 0})
+		assert.Error(t, err, "SetConfig must return error")
+		assert.Equal(t, time.Second, appHandler.GetConfig().RequestHandleTimeout, "config must not be changed")
+	})
+}
@@ -0,0 +1,92 @@
+package http_app_handler
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/http_handler_router"
+	"job-backend-trainee-assignment/internal/logger"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAppHttpHandler_V2_WithRateLimit(t *testing.T) {
+	dummyLogger := &logger.DummyLogger{}
+
+	r, err := router.NewRouter(dummyLogger)
+	require.NoError(t, err, "NewRouter must not return error")
+
+	appHandler, err := NewHttpAppHandler(dummyLogger, r, &app.StubBillingAppCommon{},
+		&Config{RequestHandleTimeout: 5 * time.Second, RateLimitPerSecond: 0.001, RateLimitBurst: 2})
+	require.NoError(t, err, "NewHttpAppHandler must not return error")
+
+	err = appHandler.RegisterAdminRoutes(&StubConfigProvider{})
+	require.NoError(t, err, "RegisterAdminRoutes must not return error")
+
+	do := func(t *testing.T, path string, remoteAddr string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		require.NoError(t, err, "must be able to create request obj")
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+
+		appHandler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("negative path, requests over burst are rejected", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			rr := do(t, "/v2/users/2/balance", "10.0.0.1:1000")
+			assert.Equal(t, http.StatusOK, rr.Code, "requests within burst must pass")
+		}
+
+		rr := do(t, "/v2/users/2/balance", "10.0.0.1:1001")
+		require.Equal(t, http.StatusTooManyRequests, rr.Code, "response status mush match")
+		assert.Equal(t, contentTypeApplicationProblemJson, rr.Header().Get("Content-Type"), "problem content type must match")
+		retryAfter, err := strconv.Atoi(rr.Header().Get("Retry-After"))
+		require.NoError(t, err, "Retry-After header must be set in seconds")
+		assert.Greater(t, retryAfter, 0, "Retry-After must be positive")
+
+		responseBody, err := ioutil.ReadAll(rr.Body)
+		require.NoError(t, err, "Must be able to read response body")
+		problem := &ProblemResponseBody{}
+		require.NoError(t, json.Unmarshal(responseBody, problem), "must be able to unmarshal response body")
+		assert.Equal(t, CodeRateLimitExceeded, problem.Code, "problem code must match")
+		assert.Equal(t, http.StatusTooManyRequests, problem.Status, "problem status must match")
+	})
+
+	t.Run("positive path, clients are limited separately", func(t *testing.T) {
+		rr := do(t, "/v2/users/2/balance", "10.0.0.2:1000")
+		assert.Equal(t, http.StatusOK, rr.Code, "response status mush match")
+	})
+
+	t.Run("positive path, admin routes are not limited", func(t *testing.T) {
+		rr := do(t, "/v2/admin/config", "10.0.0.1:1000")
+		assert.Equal(t, http.StatusOK, rr.Code, "response status mush match")
+	})
+
+	t.Run("positive path, SetConfig changes limits", func(t *testing.T) {
+		err := appHandler.SetConfig(&Config{RequestHandleTimeout: 5 * time.Second, RateLimitPerSecond: 0.001, RateLimitBurst: 3})
+		require.NoError(t, err, "SetConfig must not return error")
+		rr := do(t, "/v2/users/2/balance", "10.0.0.1:1000")
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code, "bigger burst must not refill spent tokens")
+
+		err = appHandler.SetConfig(&Config{RequestHandleTimeout: 5 * time.Second})
+		require.NoError(t, err, "SetConfig must not return error")
+		for i := 0; i < 5; i++ {
+			rr := do(t, "/v2/users/2/balance", "10.0.0.1:1000")
+			assert.Equal(t, http.StatusOK, rr.Code, "zero rate must disable limit")
+		}
+	})
+
+	t.Run("negative path, SetConfig with bad limits", func(t *testing.T) {
+		err := appHandler.SetConfig(&Config{RequestHandleTimeout: 5 * time.Second, RateLimitPerSecond: -1})
+		assert.Error(t, err, "SetConfig must return error")
+		err = appHandler.SetConfig(&Config{RequestHandleTimeout: 5 * time.Second, RateLimitBurst: -1})
+		assert.Error(t, err, "SetConfig must return error")
+	})
+}
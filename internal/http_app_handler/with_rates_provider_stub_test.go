@@ -0,0 +1,193 @@
+package http_app_handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/currency"
+	"job-backend-trainee-assignment/internal/exchanger"
+	"job-backend-trainee-assignment/internal/http_handler_router"
+	"job-backend-trainee-assignment/internal/logger"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+//stubRatesProvider counts rates requests, returns stale rates or error if it is set
+type stubRatesProvider struct {
+	exchanger.StubExchanger
+	calls int
+	stale bool
+	err   error
+	mu    sync.Mutex
+}
+
+func (s *stubRatesProvider) GetRates(ctx context.Context) (*exchanger.CurrentRates, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	rates, err := s.StubExchanger.GetRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rates.Stale = s.stale
+	return rates, nil
+}
+
+func (s *stubRatesProvider) Currencies() *currency.Registry {
+	return s.StubExchanger.Currencies()
+}
+
+func (s *stubRatesProvider) getCalls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func newCurrencyRoutesHandler(t *testing.T, provider exchanger.IRatesProvider, cacheTTL time.Duration) *AppHttpHandler {
+	dummyLogger := &logger.DummyLogger{}
+
+	r, err := router.NewRouter(dummyLogger)
+	require.NoError(t, err, "NewRouter must not return error")
+
+	appHandler, err := NewHttpAppHandler(dummyLogger, r, &app.StubBillingAppCommon{}, &Config{RequestHandleTimeout: 5 * time.Second})
+	require.NoError(t, err, "NewHttpAppHandler must not return error")
+
+	err = appHandler.RegisterCurrencyRoutes(provider, cacheTTL)
+	require.NoError(t, err, "RegisterCurrencyRoutes must not return error")
+	return appHandler
+}
+
+func doGet(t *testing.T, h http.Handler, path string, ifNoneMatch string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	require.NoError(t, err, "must be able to create request obj")
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAppHttpHandler_V2_CurrencyRoutes(t *testing.T) {
+	t.Run("negative path, bad params", func(t *testing.T) {
+		dummyLogger := &logger.DummyLogger{}
+		r, err := router.NewRouter(dummyLogger)
+		require.NoError(t, err)
+		appHandler, err := NewHttpAppHandler(dummyLogger, r, &app.StubBillingAppCommon{}, nil)
+		require.NoError(t, err)
+
+		assert.Error(t, appHandler.RegisterCurrencyRoutes(nil, time.Minute), "must return error on nil provider")
+		assert.Error(t, appHandler.RegisterCurrencyRoutes(&stubRatesProvider{}, -time.Second), "must return error on negative ttl")
+	})
+
+	t.Run("positive path, currencies catalogue", func(t *testing.T) {
+		appHandler := newCurrencyRoutesHandler(t, &stubRatesProvider{}, time.Minute)
+
+		rr := doGet(t, appHandler, "/v2/currencies", "")
+		require.Equal(t, http.StatusOK, rr.Code, "response status must match")
+
+		catalogue := &CurrenciesCatalogue{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &SuccessResponseBody{Result: catalogue}))
+		assert.Equal(t, exchanger.RUBCode, catalogue.Base)
+		assert.Equal(t, "2020-08-15", catalogue.RatesDate)
+		assert.Equal(t, "stub", catalogue.Source)
+		assert.Equal(t, []CurrencyDescription{
+			{Code: "BTC", Name: "", MinorUnits: 2, Rate: "0.00000123"},
+			{Code: "EUR", Name: "Euro", MinorUnits: 2, Rate: "0.011"},
+			{Code: "RUB", Name: "Russian Ruble", MinorUnits: 2, Rate: "1"},
+			{Code: "USD", Name: "US Dollar", MinorUnits: 2, Rate: "0.013"},
+		}, catalogue.Currencies)
+	})
+
+	t.Run("positive path, rates", func(t *testing.T) {
+		appHandler := newCurrencyRoutesHandler(t, &stubRatesProvider{}, time.Minute)
+
+		rr := doGet(t, appHandler, "/v2/rates", "")
+		require.Equal(t, http.StatusOK, rr.Code, "response status must match")
+
+		rates := &RatesList{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &SuccessResponseBody{Result: rates}))
+		assert.Equal(t, exchanger.RUBCode, rates.Base)
+		assert.Equal(t, "2020-08-15", rates.Date)
+		assert.False(t, rates.Stale)
+		assert.Equal(t, map[string]string{"USD": "0.013", "EUR": "0.011", "BTC": "0.00000123"}, rates.Rates)
+	})
+
+	t.Run("positive path, cached response and conditional get", func(t *testing.T) {
+		provider := &stubRatesProvider{}
+		appHandler := newCurrencyRoutesHandler(t, provider, time.Minute)
+
+		rr := doGet(t, appHandler, "/v2/rates", "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		etag := rr.Header().Get("ETag")
+		require.NotEmpty(t, etag, "response must have ETag")
+		assert.Equal(t, "max-age=60", rr.Header().Get("Cache-Control"))
+
+		rr = doGet(t, appHandler, "/v2/rates", etag)
+		assert.Equal(t, http.StatusNotModified, rr.Code, "matching ETag must give 304")
+		assert.Empty(t, rr.Body.Bytes(), "304 must not have body")
+
+		rr = doGet(t, appHandler, "/v2/rates", `"other", W/`+etag)
+		assert.Equal(t, http.StatusNotModified, rr.Code, "ETag in list must match")
+
+		rr = doGet(t, appHandler, "/v2/rates", `"other"`)
+		assert.Equal(t, http.StatusOK, rr.Code, "other ETag must give full response")
+		assert.Equal(t, etag, rr.Header().Get("ETag"))
+		assert.Equal(t, 1, provider.getCalls(), "cached response must be served")
+
+		rr = doGet(t, appHandler, "/v2/currencies", "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEqual(t, etag, rr.Header().Get("ETag"), "routes must have own ETags")
+		assert.Equal(t, 2, provider.getCalls(), "routes must have own cache")
+	})
+
+	t.Run("positive path, without cache", func(t *testing.T) {
+		provider := &stubRatesProvider{}
+		appHandler := newCurrencyRoutesHandler(t, provider, 0)
+
+		rr := doGet(t, appHandler, "/v2/rates", "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		etag := rr.Header().Get("ETag")
+
+		rr = doGet(t, appHandler, "/v2/rates", etag)
+		assert.Equal(t, http.StatusNotModified, rr.Code, "unchanged rates must give the same ETag")
+		assert.Equal(t, 2, provider.getCalls())
+	})
+
+	t.Run("positive path, stale rates are not cached", func(t *testing.T) {
+		provider := &stubRatesProvider{stale: true}
+		appHandler := newCurrencyRoutesHandler(t, provider, time.Minute)
+
+		rr := doGet(t, appHandler, "/v2/rates", "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "max-age=0", rr.Header().Get("Cache-Control"))
+
+		rr = doGet(t, appHandler, "/v2/rates", "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, 2, provider.getCalls(), "stale rates must be requested again")
+	})
+
+	t.Run("negative path, rates are unavailable", func(t *testing.T) {
+		provider := &stubRatesProvider{err: errors.New("connection refused")}
+		appHandler := newCurrencyRoutesHandler(t, provider, time.Minute)
+
+		rr := doGet(t, appHandler, "/v2/currencies", "")
+		require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+		problem := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+		assert.Equal(t, string(app.CodeCurrencyExchangeFailed), problem["code"])
+		assert.Empty(t, rr.Header().Get("ETag"))
+	})
+}
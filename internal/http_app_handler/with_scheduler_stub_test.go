@@ -0,0 +1,148 @@
+package http_app_handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/http_handler_router"
+	"job-backend-trainee-assignment/internal/logger"
+	"job-backend-trainee-assignment/internal/scheduler"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAppHttpHandler_V2_WithStubScheduler_Common(t *testing.T) {
+	stub := &scheduler.StubSchedulerCommon{}
+	stubSchedule, _ := stub.GetSchedule(context.Background(), 1)
+	cancelledSchedule, _ := stub.CancelSchedule(context.Background(), 1)
+	stubRuns, _ := stub.GetScheduleRuns(context.Background(), 1)
+	createToken := uuid.NewV4().String()
+	createdSchedule, _ := stub.CreateSchedule(context.Background(), &scheduler.ScheduleRequest{
+		UserId:           2,
+		ScheduleType:     scheduler.ScheduleTypeCron,
+		IdempotencyToken: createToken,
+	})
+
+	testCases := []TestCaseWithPath{
+		{
+			CaseName:       "positive path, handler V2CreateUserSchedule, Common",
+			Path:           "/v2/users/2/schedules",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody: &ScheduleRequestV2{
+				OperationType:    scheduler.OperationTypeWithdraw,
+				Amount:           "10",
+				ScheduleType:     scheduler.ScheduleTypeCron,
+				CronExpression:   "0 10 1 * *",
+				IdempotencyToken: createToken,
+			},
+			RespStatus: http.StatusCreated,
+			RespBody:   &SuccessResponseBody{Result: createdSchedule},
+		},
+		{
+			CaseName:       "negative path, handler V2CreateUserSchedule, bad schedule type",
+			Path:           "/v2/users/2/schedules",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody: &ScheduleRequestV2{
+				ScheduleType:     "weekly",
+				IdempotencyToken: uuid.NewV4().String(),
+			},
+			RespStatus: http.StatusUnprocessableEntity,
+			RespBody:   newTestProblem("/v2/users/2/schedules", scheduler.ErrBadScheduleType, http.StatusUnprocessableEntity),
+		},
+		{
+			CaseName:       "negative path, handler V2CreateUserSchedule, user does not exist",
+			Path:           "/v2/users/3/schedules",
+			ReqMethod:      http.MethodPost,
+			ReqContentType: contentTypeApplicationJson,
+			ReqBody:        &ScheduleRequestV2{ScheduleType: scheduler.ScheduleTypeOnce},
+			RespStatus:     http.StatusNotFound,
+			RespBody:       newTestProblem("/v2/users/3/schedules", app.ErrUserDoesNotExist, http.StatusNotFound),
+		},
+		{
+			CaseName:   "positive path, handler V2GetUserSchedules, Common",
+			Path:       "/v2/users/1/schedules",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusOK,
+			RespBody:   &SuccessResponseBody{Result: []scheduler.Schedule{*stubSchedule}},
+		},
+		{
+			CaseName:   "positive path, handler V2GetSchedule, Common",
+			Path:       "/v2/schedules/1",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusOK,
+			RespBody:   &SuccessResponseBody{Result: stubSchedule},
+		},
+		{
+			CaseName:   "negative path, handler V2GetSchedule, schedule does not exist",
+			Path:       "/v2/schedules/2",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusNotFound,
+			RespBody:   newTestProblem("/v2/schedules/2", scheduler.ErrScheduleDoesNotExist, http.StatusNotFound),
+		},
+		{
+			CaseName:   "positive path, handler V2CancelSchedule, Common",
+			Path:       "/v2/schedules/1",
+			ReqMethod:  http.MethodDelete,
+			RespStatus: http.StatusOK,
+			RespBody:   &SuccessResponseBody{Result: cancelledSchedule},
+		},
+		{
+			CaseName:   "positive path, handler V2GetScheduleRuns, Common",
+			Path:       "/v2/schedules/1/runs",
+			ReqMethod:  http.MethodGet,
+			RespStatus: http.StatusOK,
+			RespBody:   &SuccessResponseBody{Result: stubRuns},
+		},
+	}
+
+	dummyLogger := &logger.DummyLogger{}
+
+	r, err := router.NewRouter(dummyLogger)
+	require.NoError(t, err, "NewRouter must not return error")
+
+	appHandler, err := NewHttpAppHandler(dummyLogger, r, &app.StubBillingAppCommon{}, &Config{RequestHandleTimeout: 5 * time.Second})
+	require.NoError(t, err, "NewHttpAppHandler must not return error")
+
+	err = appHandler.RegisterSchedulerRoutes(stub)
+	require.NoError(t, err, "RegisterSchedulerRoutes must not return error")
+
+	for caseIdx, tc := range testCases {
+		t.Logf("\ttesting case:%d \"%s\"", caseIdx, tc.CaseName)
+		{
+			var req *http.Request
+			if tc.ReqBody == nil {
+				req, err = http.NewRequest(tc.ReqMethod, tc.Path, nil)
+				require.NoError(t, err, "must be able to create request obj")
+			} else {
+				b, err := json.Marshal(tc.ReqBody)
+				require.NoError(t, err, "must be able to marshal request body")
+				req, err = http.NewRequest(tc.ReqMethod, tc.Path, bytes.NewBuffer(b))
+				require.NoError(t, err, "must be able to create request obj")
+			}
+
+			if tc.ReqContentType != "" {
+				req.Header.Add("Content-Type", tc.ReqContentType)
+			}
+			rr := httptest.NewRecorder()
+
+			appHandler.ServeHTTP(rr, req)
+
+			responseBody, err := ioutil.ReadAll(rr.Body)
+			require.NoError(t, err, "Must be able to read response body")
+
+			expectedBody, err := json.Marshal(tc.RespBody)
+			require.NoError(t, err, "must be able to unmarshal response body")
+			assert.JSONEq(t, string(expectedBody), string(responseBody), "\t\tresponse body must match")
+			assert.Equal(t, tc.RespStatus, rr.Code, "\t\tresponse status mush match")
+		}
+	}
+}
@@ -30,6 +30,12 @@ func (hr *HttpRouter) SetMethodNotAllowedHandler(h http.HandlerFunc) {
 	hr.router.MethodNotAllowed = h
 }
 
+// PathParam returns value of named path parameter (e.g. "id" in /v2/users/:id/balance) of given request,
+// or empty string if the route has no such parameter
+func PathParam(r *http.Request, name string) string {
+	return httprouter.ParamsFromContext(r.Context()).ByName(name)
+}
+
 func NewRouter(logger logger.ILogger) (*HttpRouter, error) {
 	if logger == nil {
 		return nil, fmt.Errorf("logger must be not nil")
@@ -0,0 +1,86 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"job-backend-trainee-assignment/internal/breaker"
+	"job-backend-trainee-assignment/internal/logger"
+	"time"
+)
+
+//BreakerStore is a IIdempotencyStore, which skips store calls while circuit breaker is open,
+//so operations go on at once and check token in database, instead of waiting for timeout of unavailable store
+type BreakerStore struct {
+	logger  logger.ILogger
+	store   IIdempotencyStore
+	breaker *breaker.Breaker
+}
+
+func NewBreakerStore(log logger.ILogger, store IIdempotencyStore, b *breaker.Breaker) (*BreakerStore, error) {
+	if log == nil {
+		return nil, fmt.Errorf("provided logger param is nil")
+	}
+
+	if store == nil {
+		return nil, fmt.Errorf("provided store param is nil")
+	}
+
+	if b == nil {
+		return nil, fmt.Errorf("provided breaker param is nil")
+	}
+
+	return &BreakerStore{logger: log, store: store, breaker: b}, nil
+}
+
+//allow returns ErrStoreIsUnavailable if breaker is open
+func (s *BreakerStore) allow(methodName string) error {
+	if err := s.breaker.Allow(); err != nil {
+		return fmt.Errorf("%s, %v, err: %w", methodName, err, ErrStoreIsUnavailable)
+	}
+	return nil
+}
+
+//done reports call result to breaker, cancellation of caller context is not a store failure
+func (s *BreakerStore) done(ctx context.Context, err error) {
+	before := s.breaker.Status().State
+	s.breaker.Done(err != nil && ctx.Err() == nil)
+	if after := s.breaker.Status().State; after != before {
+		s.logger.Error("idempotency store circuit breaker state changed from %s to %s, last err: %v", before, after, err)
+	}
+}
+
+func (s *BreakerStore) Begin(ctx context.Context, endpoint string, key string) (existing *Record, err error) {
+	if err := s.allow("Begin"); err != nil {
+		return nil, err
+	}
+	existing, err = s.store.Begin(ctx, endpoint, key)
+	s.done(ctx, err)
+	return existing, err
+}
+
+func (s *BreakerStore) Complete(ctx context.Context, endpoint string, key string) (err error) {
+	if err := s.allow("Complete"); err != nil {
+		return err
+	}
+	err = s.store.Complete(ctx, endpoint, key)
+	s.done(ctx, err)
+	return err
+}
+
+func (s *BreakerStore) Release(ctx context.Context, endpoint string, key string) (err error) {
+	if err := s.allow("Release"); err != nil {
+		return err
+	}
+	err = s.store.Release(ctx, endpoint, key)
+	s.done(ctx, err)
+	return err
+}
+
+func (s *BreakerStore) DeleteExpired(ctx context.Context, now time.Time) (deleted int64, err error) {
+	if err := s.allow("DeleteExpired"); err != nil {
+		return 0, err
+	}
+	deleted, err = s.store.DeleteExpired(ctx, now)
+	s.done(ctx, err)
+	return deleted, err
+}
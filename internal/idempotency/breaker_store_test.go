@@ -0,0 +1,73 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/breaker"
+	"job-backend-trainee-assignment/internal/logger"
+	"testing"
+	"time"
+)
+
+func TestBreakerStore_Common(t *testing.T) {
+	dummyLogger := &logger.DummyLogger{}
+
+	newBreaker := func(t *testing.T) *breaker.Breaker {
+		b, err := breaker.NewBreaker("redis", &breaker.Config{FailureThreshold: 2, OpenTimeout: time.Hour})
+		require.NoError(t, err, "NewBreaker must not return error")
+		return b
+	}
+
+	t.Run("negative path, faulty store is skipped after failures", func(t *testing.T) {
+		b := newBreaker(t)
+		s, err := NewBreakerStore(dummyLogger, &StubStoreFaulty{}, b)
+		require.NoError(t, err, "NewBreakerStore must not return error")
+
+		for i := 0; i < 2; i++ {
+			_, err := s.Begin(context.Background(), "credit", "1")
+			assert.True(t, errors.Is(err, ErrFailedToBeginKey), "error of store must be returned")
+		}
+		assert.Equal(t, breaker.StateOpen, b.Status().State, "breaker must be open")
+
+		_, err = s.Begin(context.Background(), "credit", "1")
+		assert.True(t, errors.Is(err, ErrStoreIsUnavailable), "Begin must be skipped")
+		err = s.Complete(context.Background(), "credit", "1")
+		assert.True(t, errors.Is(err, ErrStoreIsUnavailable), "Complete must be skipped")
+		err = s.Release(context.Background(), "credit", "1")
+		assert.True(t, errors.Is(err, ErrStoreIsUnavailable), "Release must be skipped")
+	})
+
+	t.Run("positive path, cancelled caller context is not a store failure", func(t *testing.T) {
+		b := newBreaker(t)
+		s, err := NewBreakerStore(dummyLogger, &StubStoreFaulty{}, b)
+		require.NoError(t, err, "NewBreakerStore must not return error")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		for i := 0; i < 3; i++ {
+			_ = s.Complete(ctx, "credit", "1")
+		}
+		assert.Equal(t, breaker.StateClosed, b.Status().State, "breaker must be closed")
+	})
+
+	t.Run("positive path, working store keeps breaker closed", func(t *testing.T) {
+		b := newBreaker(t)
+		memStore, err := NewMemoryStore(nil)
+		require.NoError(t, err, "NewMemoryStore must not return error")
+		s, err := NewBreakerStore(dummyLogger, memStore, b)
+		require.NoError(t, err, "NewBreakerStore must not return error")
+
+		rec, err := s.Begin(context.Background(), "credit", "1")
+		assert.NoError(t, err)
+		assert.Nil(t, rec, "new key must not have record")
+		require.NoError(t, s.Complete(context.Background(), "credit", "1"))
+
+		rec, err = s.Begin(context.Background(), "credit", "1")
+		assert.NoError(t, err)
+		require.NotNil(t, rec, "completed key must have record")
+		assert.Equal(t, StateCompleted, rec.State)
+		assert.Equal(t, breaker.StateClosed, b.Status().State, "breaker must be closed")
+	})
+}
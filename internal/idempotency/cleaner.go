@@ -0,0 +1,59 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"job-backend-trainee-assignment/internal/logger"
+	"time"
+)
+
+var defaultCleanupInterval = 10 * time.Minute
+
+//Cleaner periodically removes expired keys of store
+type Cleaner struct {
+	logger   logger.ILogger
+	store    IIdempotencyStore
+	interval time.Duration
+}
+
+func NewCleaner(logger logger.ILogger, store IIdempotencyStore, interval time.Duration) (*Cleaner, error) {
+	if logger == nil {
+		return nil, fmt.Errorf("must provide non-nil logger instance")
+	}
+
+	if store == nil {
+		return nil, fmt.Errorf("must provide non-nil idempotency store instance")
+	}
+
+	if interval == 0 {
+		interval = defaultCleanupInterval
+	}
+
+	if interval < 0 {
+		return nil, fmt.Errorf("cleanup interval must be positive")
+	}
+
+	return &Cleaner{logger: logger, store: store, interval: interval}, nil
+}
+
+//Run removes expired keys periodically until ctx is done
+func (c *Cleaner) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Info("Run, context is done, stopping idempotency keys cleaner")
+			return
+		case <-ticker.C:
+		}
+
+		deleted, err := c.store.DeleteExpired(ctx, time.Now())
+		if err != nil {
+			c.logger.Error("Run, failed to delete expired idempotency keys, err %v", err)
+		} else if deleted > 0 {
+			c.logger.Info("Run, deleted %d expired idempotency keys", deleted)
+		}
+	}
+}
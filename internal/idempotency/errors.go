@@ -0,0 +1,13 @@
+package idempotency
+
+import "fmt"
+
+var (
+	ErrUnknownBackend             = fmt.Errorf("unknown idempotency store backend")
+	ErrFailedToBeginKey           = fmt.Errorf("failed to mark idempotency key as in progress")
+	ErrFailedToCompleteKey        = fmt.Errorf("failed to mark idempotency key as completed")
+	ErrFailedToReleaseKey         = fmt.Errorf("failed to release idempotency key")
+	ErrFailedToDeleteExpiredKeys  = fmt.Errorf("failed to delete expired idempotency keys")
+	ErrFailedToParseStoredKeyData = fmt.Errorf("failed to parse stored idempotency key data")
+	ErrStoreIsUnavailable         = fmt.Errorf("idempotency store is unavailable")
+)
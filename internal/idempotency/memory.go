@@ -0,0 +1,77 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//MemoryStore keeps keys in memory of process, keys are lost on restart and are not shared between instances
+type MemoryStore struct {
+	cfg     *Config
+	records map[string]Record
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+func NewMemoryStore(cfg *Config) (*MemoryStore, error) {
+	if cfg == nil {
+		cfg = defaultConfig()
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &MemoryStore{
+		cfg:     cfg,
+		records: map[string]Record{},
+		now:     time.Now,
+		mu:      sync.Mutex{},
+	}, nil
+}
+
+func (s *MemoryStore) Begin(ctx context.Context, endpoint string, key string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if rec, ok := s.records[key]; ok && rec.ExpiresAt.After(now) {
+		return &rec, nil
+	}
+
+	s.records[key] = Record{Key: key, Endpoint: endpoint, State: StateInProgress, ExpiresAt: now.Add(s.cfg.InProgressTTL)}
+	return nil, nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, endpoint string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[key] = Record{Key: key, Endpoint: endpoint, State: StateCompleted, ExpiresAt: s.now().Add(s.cfg.TTL(endpoint))}
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, endpoint string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.records[key]; ok && rec.State == StateInProgress {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for key, rec := range s.records {
+		if !rec.ExpiresAt.After(now) {
+			delete(s.records, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
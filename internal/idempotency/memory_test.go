@@ -0,0 +1,107 @@
+package idempotency
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/logger"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_Common(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2020-08-11T10:23:58+03:00")
+	cfg := &Config{
+		InProgressTTL: time.Minute,
+		DefaultTTL:    time.Hour,
+		EndpointTTLs:  map[string]time.Duration{"transfer": 2 * time.Hour},
+	}
+
+	newStore := func(t *testing.T) *MemoryStore {
+		s, err := NewMemoryStore(cfg)
+		require.NoError(t, err, "NewMemoryStore must not return error")
+		s.now = func() time.Time { return now }
+		return s
+	}
+
+	t.Run("positive path, new key is marked as in progress", func(t *testing.T) {
+		s := newStore(t)
+		rec, err := s.Begin(context.Background(), "credit", "1")
+		assert.NoError(t, err, "must not return error")
+		assert.Nil(t, rec, "new key must not have record")
+
+		rec, err = s.Begin(context.Background(), "credit", "1")
+		assert.NoError(t, err, "must not return error")
+		require.NotNil(t, rec, "duplicate key must have record")
+		assert.Equal(t, StateInProgress, rec.State, "state must match")
+		assert.Equal(t, now.Add(time.Minute), rec.ExpiresAt, "expiration time must match")
+	})
+
+	t.Run("positive path, completed key is kept for endpoint ttl", func(t *testing.T) {
+		s := newStore(t)
+		_, err := s.Begin(context.Background(), "transfer", "1")
+		require.NoError(t, err, "must not return error")
+		require.NoError(t, s.Complete(context.Background(), "transfer", "1"), "must not return error")
+
+		rec, err := s.Begin(context.Background(), "withdraw", "1")
+		assert.NoError(t, err, "must not return error")
+		require.NotNil(t, rec, "completed key must have record")
+		assert.Equal(t, StateCompleted, rec.State, "state must match")
+		assert.Equal(t, "transfer", rec.Endpoint, "endpoint must match")
+		assert.Equal(t, now.Add(2*time.Hour), rec.ExpiresAt, "expiration time must match")
+
+		assert.NoError(t, s.Release(context.Background(), "transfer", "1"), "must not return error")
+		rec, _ = s.Begin(context.Background(), "transfer", "1")
+		assert.NotNil(t, rec, "completed key must not be released")
+	})
+
+	t.Run("positive path, released key may be used again", func(t *testing.T) {
+		s := newStore(t)
+		_, err := s.Begin(context.Background(), "credit", "1")
+		require.NoError(t, err, "must not return error")
+		require.NoError(t, s.Release(context.Background(), "credit", "1"), "must not return error")
+
+		rec, err := s.Begin(context.Background(), "credit", "1")
+		assert.NoError(t, err, "must not return error")
+		assert.Nil(t, rec, "released key must not have record")
+	})
+
+	t.Run("positive path, expired keys are ignored and deleted", func(t *testing.T) {
+		s := newStore(t)
+		_, _ = s.Begin(context.Background(), "credit", "1")
+		_, _ = s.Begin(context.Background(), "credit", "2")
+		require.NoError(t, s.Complete(context.Background(), "credit", "2"), "must not return error")
+
+		s.now = func() time.Time { return now.Add(2 * time.Minute) }
+		rec, err := s.Begin(context.Background(), "credit", "1")
+		assert.NoError(t, err, "must not return error")
+		assert.Nil(t, rec, "expired in progress key must be taken over")
+
+		deleted, err := s.DeleteExpired(context.Background(), now.Add(2*time.Hour))
+		assert.NoError(t, err, "must not return error")
+		assert.Equal(t, int64(2), deleted, "deleted keys number must match")
+	})
+
+	t.Run("negative path, bad config", func(t *testing.T) {
+		s, err := NewMemoryStore(&Config{InProgressTTL: time.Minute, DefaultTTL: time.Hour,
+			EndpointTTLs: map[string]time.Duration{"credit": 0}})
+		assert.Error(t, err, "must get error on NewMemoryStore creating")
+		assert.Nil(t, s, "ptr to store instance must be nil")
+	})
+}
+
+func TestNewStore(t *testing.T) {
+	s, err := NewStore(BackendMemory, nil, nil, nil)
+	assert.NoError(t, err, "must not return error")
+	assert.IsType(t, &MemoryStore{}, s, "store type must match")
+
+	_, err = NewStore(BackendRedis, nil, nil, nil)
+	assert.Error(t, err, "redis store without pool must return error")
+
+	_, err = NewStore("etcd", nil, nil, nil)
+	assert.Error(t, err, "unknown backend must return error")
+
+	c, err := NewCleaner(&logger.DummyLogger{}, &StubStoreFaulty{}, 0)
+	assert.NoError(t, err, "NewCleaner must not return error")
+	assert.Equal(t, defaultCleanupInterval, c.interval, "default interval must be used")
+}
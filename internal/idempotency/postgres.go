@@ -0,0 +1,99 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+//PostgresStore keeps keys in "IdempotencyKey" table, expired keys are removed by DeleteExpired
+type PostgresStore struct {
+	cfg *Config
+	db  *sqlx.DB
+}
+
+func NewPostgresStore(db *sqlx.DB, cfg *Config) (*PostgresStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("must provide non-nil sqlx.DB pointer")
+	}
+
+	if cfg == nil {
+		cfg = defaultConfig()
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &PostgresStore{cfg: cfg, db: db}, nil
+}
+
+func (s *PostgresStore) Begin(ctx context.Context, endpoint string, key string) (*Record, error) {
+	now := time.Now()
+	//expired key, not yet removed by cleanup, is taken over as a new one
+	res, err := s.db.ExecContext(ctx, `INSERT INTO "IdempotencyKey" (idempotency_key, endpoint, state, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (idempotency_key) DO UPDATE SET endpoint = EXCLUDED.endpoint, state = EXCLUDED.state, expires_at = EXCLUDED.expires_at
+		WHERE "IdempotencyKey".expires_at <= $5`,
+		key, endpoint, string(StateInProgress), now.Add(s.cfg.InProgressTTL), now)
+	if err != nil {
+		return nil, fmt.Errorf("%s, err: %w", ErrFailedToBeginKey.Error(), err)
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("%s, err: %w", ErrFailedToBeginKey.Error(), err)
+	}
+
+	if inserted == 1 {
+		return nil, nil
+	}
+
+	rec := &Record{}
+	err = s.db.GetContext(ctx, rec, `SELECT idempotency_key, endpoint, state, expires_at
+		FROM "IdempotencyKey" WHERE idempotency_key = $1`, key)
+	if err == sql.ErrNoRows {
+		//key is removed by cleanup after conflict, the operation may be started again
+		return s.Begin(ctx, endpoint, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s, err: %w", ErrFailedToBeginKey.Error(), err)
+	}
+
+	return rec, nil
+}
+
+func (s *PostgresStore) Complete(ctx context.Context, endpoint string, key string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO "IdempotencyKey" (idempotency_key, endpoint, state, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (idempotency_key) DO UPDATE SET endpoint = EXCLUDED.endpoint, state = EXCLUDED.state, expires_at = EXCLUDED.expires_at`,
+		key, endpoint, string(StateCompleted), time.Now().Add(s.cfg.TTL(endpoint)))
+	if err != nil {
+		return fmt.Errorf("%s, err: %w", ErrFailedToCompleteKey.Error(), err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Release(ctx context.Context, endpoint string, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM "IdempotencyKey" WHERE idempotency_key = $1 AND state = $2`,
+		key, string(StateInProgress))
+	if err != nil {
+		return fmt.Errorf("%s, err: %w", ErrFailedToReleaseKey.Error(), err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM "IdempotencyKey" WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("%s, err: %w", ErrFailedToDeleteExpiredKeys.Error(), err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s, err: %w", ErrFailedToDeleteExpiredKeys.Error(), err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,127 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"strings"
+	"time"
+)
+
+const redisKeyPrefix = "idempotency:"
+
+var defaultRedisTimeout = 2 * time.Second
+
+//beginScript stores in progress key, if key does not exist, otherwise returns stored value and its ttl
+const beginScript = `local v = redis.call('GET', KEYS[1])
+if v then return {v, redis.call('PTTL', KEYS[1])} end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return false`
+
+//releaseScript deletes key only if it is in progress
+const releaseScript = `local v = redis.call('GET', KEYS[1])
+if v and string.sub(v, 1, string.len(ARGV[1])) == ARGV[1] then return redis.call('DEL', KEYS[1]) end
+return 0`
+
+//RedisStore keeps keys in redis, keys expire by redis ttl, so DeleteExpired has nothing to do
+type RedisStore struct {
+	cfg   *Config
+	redis *redis.Pool
+}
+
+func NewRedisStore(redisPool *redis.Pool, cfg *Config) (*RedisStore, error) {
+	if redisPool == nil {
+		return nil, fmt.Errorf("must provide non-nil redis pool")
+	}
+
+	if cfg == nil {
+		cfg = defaultConfig()
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &RedisStore{cfg: cfg, redis: redisPool}, nil
+}
+
+func encodeRedisValue(state State, endpoint string) string {
+	return string(state) + "|" + endpoint
+}
+
+func decodeRedisValue(key string, value string, ttl time.Duration) (*Record, error) {
+	parts := strings.SplitN(value, "|", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("%s, value %q", ErrFailedToParseStoredKeyData.Error(), value)
+	}
+	return &Record{Key: key, Endpoint: parts[1], State: State(parts[0]), ExpiresAt: time.Now().Add(ttl)}, nil
+}
+
+//do performs redis command, timeout is taken from ctx deadline
+func (s *RedisStore) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	timeout := defaultRedisTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+		if timeout <= 0 {
+			return nil, ctx.Err()
+		}
+	}
+
+	conn, err := s.redis.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return redis.DoWithTimeout(conn, timeout, cmd, args...)
+}
+
+func (s *RedisStore) Begin(ctx context.Context, endpoint string, key string) (*Record, error) {
+	reply, err := s.do(ctx, "EVAL", beginScript, 1, redisKeyPrefix+key,
+		encodeRedisValue(StateInProgress, endpoint), s.cfg.InProgressTTL.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("%s, err: %w", ErrFailedToBeginKey.Error(), err)
+	}
+
+	if reply == nil {
+		return nil, nil
+	}
+
+	values, err := redis.Values(reply, nil)
+	if err != nil || len(values) != 2 {
+		return nil, fmt.Errorf("%s, reply %v, err: %v", ErrFailedToParseStoredKeyData.Error(), reply, err)
+	}
+
+	value, err := redis.String(values[0], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s, err: %w", ErrFailedToParseStoredKeyData.Error(), err)
+	}
+
+	ttlMs, err := redis.Int64(values[1], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s, err: %w", ErrFailedToParseStoredKeyData.Error(), err)
+	}
+
+	return decodeRedisValue(key, value, time.Duration(ttlMs)*time.Millisecond)
+}
+
+func (s *RedisStore) Complete(ctx context.Context, endpoint string, key string) error {
+	_, err := s.do(ctx, "SET", redisKeyPrefix+key, encodeRedisValue(StateCompleted, endpoint),
+		"PX", s.cfg.TTL(endpoint).Milliseconds())
+	if err != nil {
+		return fmt.Errorf("%s, err: %w", ErrFailedToCompleteKey.Error(), err)
+	}
+	return nil
+}
+
+func (s *RedisStore) Release(ctx context.Context, endpoint string, key string) error {
+	_, err := s.do(ctx, "EVAL", releaseScript, 1, redisKeyPrefix+key, string(StateInProgress)+"|")
+	if err != nil {
+		return fmt.Errorf("%s, err: %w", ErrFailedToReleaseKey.Error(), err)
+	}
+	return nil
+}
+
+func (s *RedisStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,101 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+//State is a state of idempotency key
+type State string
+
+const (
+	//StateInProgress marks key of operation, which is being performed now
+	StateInProgress State = "in_progress"
+	//StateCompleted marks key of done operation
+	StateCompleted State = "completed"
+)
+
+//Backend names, used to select store in config
+const (
+	BackendMemory   = "memory"
+	BackendRedis    = "redis"
+	BackendPostgres = "postgres"
+)
+
+//Record represents stored idempotency key
+type Record struct {
+	Key       string    `db:"idempotency_key"`
+	Endpoint  string    `db:"endpoint"`
+	State     State     `db:"state"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+//IIdempotencyStore keeps idempotency keys of operations. Keys are unique among all endpoints,
+//endpoint is used to choose key ttl
+type IIdempotencyStore interface {
+	//Begin marks key as in progress. If key is already stored, its record is returned and key is not changed
+	Begin(ctx context.Context, endpoint string, key string) (existing *Record, err error)
+	//Complete marks key as completed, key is kept for ttl of endpoint
+	Complete(ctx context.Context, endpoint string, key string) error
+	//Release removes in progress key of failed operation, so operation may be retried with the same key
+	Release(ctx context.Context, endpoint string, key string) error
+	//DeleteExpired removes expired keys and returns number of removed keys
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type Config struct {
+	//InProgressTTL limits lifetime of in progress key, so key of operation, interrupted by crash, is released
+	InProgressTTL time.Duration
+	//DefaultTTL is a ttl of completed key of endpoint, not listed in EndpointTTLs
+	DefaultTTL time.Duration
+	//EndpointTTLs are ttls of completed keys by endpoint name
+	EndpointTTLs map[string]time.Duration
+}
+
+var (
+	defaultInProgressTTL = time.Minute
+	defaultTTL           = 24 * time.Hour
+)
+
+func defaultConfig() *Config {
+	return &Config{InProgressTTL: defaultInProgressTTL, DefaultTTL: defaultTTL}
+}
+
+//Validate checks that all ttls are positive
+func (cfg *Config) Validate() error {
+	if cfg.InProgressTTL <= 0 || cfg.DefaultTTL <= 0 {
+		return fmt.Errorf("in progress ttl and default ttl must be positive")
+	}
+
+	for endpoint, ttl := range cfg.EndpointTTLs {
+		if ttl <= 0 {
+			return fmt.Errorf("ttl of endpoint %s must be positive", endpoint)
+		}
+	}
+	return nil
+}
+
+//TTL returns ttl of completed key of endpoint
+func (cfg *Config) TTL(endpoint string) time.Duration {
+	if ttl, ok := cfg.EndpointTTLs[endpoint]; ok {
+		return ttl
+	}
+	return cfg.DefaultTTL
+}
+
+//NewStore creates store of given backend, redis pool is required by redis backend, db is required by postgres backend
+func NewStore(backend string, redisPool *redis.Pool, db *sqlx.DB, cfg *Config) (IIdempotencyStore, error) {
+	switch backend {
+	case BackendMemory:
+		return NewMemoryStore(cfg)
+	case BackendRedis:
+		return NewRedisStore(redisPool, cfg)
+	case BackendPostgres:
+		return NewPostgresStore(db, cfg)
+	default:
+		return nil, fmt.Errorf("%s, backend %q", ErrUnknownBackend.Error(), backend)
+	}
+}
@@ -0,0 +1,126 @@
+// +build integration
+
+package idempotency
+
+import (
+	"context"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/cache"
+	"job-backend-trainee-assignment/internal/db_connector"
+	"job-backend-trainee-assignment/internal/logger"
+	"job-backend-trainee-assignment/internal/test_helpers"
+	"testing"
+	"time"
+)
+
+const filePathPrefix = "../../"
+
+func TestStores_Integration_Common(t *testing.T) {
+	v := viper.New()
+
+	v.AddConfigPath(".")
+	v.AddConfigPath("../../")
+	v.SetConfigName("config")
+	v.AutomaticEnv()
+
+	err := v.ReadInConfig()
+	require.NoErrorf(t, err, "failed to read config file at: %s, err %v", "config", err)
+
+	var pgHost string
+	if v.GetString("DATABASE_HOST") != "" {
+		pgHost = v.GetString("DATABASE_HOST")
+	} else {
+		pgHost = v.GetString("db_params.DATABASE_HOST")
+	}
+
+	dbConfig := &db_connector.Config{
+		DriverName:    v.GetString("db_params.driver_name"),
+		DBUser:        v.GetString("db_params.user"),
+		DBPass:        v.GetString("db_params.password"),
+		DBName:        v.GetString("db_params.db_name"),
+		DBPort:        v.GetString("db_params.port"),
+		DBHost:        pgHost,
+		SSLMode:       v.GetString("db_params.ssl_mode"),
+		RetryInterval: v.GetDuration("db_params.conn_retry_interval") * time.Second,
+	}
+
+	dbConnTimeout := v.GetDuration("db_params.conn_timeout") * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), dbConnTimeout)
+	defer cancel()
+	dummyLogger := &logger.DummyLogger{}
+
+	db, dbCloseFunc, err := db_connector.DBConnectWithTimeout(ctx, dbConfig, dummyLogger)
+	require.NoErrorf(t, err, "failed to connect to db,err %v", err)
+
+	defer dbCloseFunc()
+
+	var cacheHost string
+	if v.GetString("CACHE_HOST") != "" {
+		cacheHost = v.GetString("CACHE_HOST")
+	} else {
+		cacheHost = v.GetString("cache_params.CACHE_HOST")
+	}
+
+	redisPool, poolCloseFunc, err := cache.ConnectToRedisWithTimeout(ctx, dummyLogger, &cache.ConnConfig{
+		Host:          cacheHost,
+		DBName:        v.GetInt("cache_params.db_name"),
+		Port:          v.GetString("cache_params.port"),
+		Pass:          v.GetString("cache_params.pass"),
+		RetryInterval: v.GetDuration("cache_params.conn_retry_interval") * time.Second,
+		MaxConn:       v.GetInt("cache_params.max_conn"),
+		MaxIdleConn:   v.GetInt("cache_params.max_idle_conn"),
+		IdleTimeout:   v.GetDuration("cache_params.idle_timeout") * time.Second,
+	})
+	require.NoErrorf(t, err, "must be able to connect to redis cache,err: %v", err)
+
+	defer poolCloseFunc()
+
+	caseTimeout := v.GetDuration("testing_params.test_case_timeout") * time.Second
+	cfg := &Config{InProgressTTL: time.Minute, DefaultTTL: time.Hour, EndpointTTLs: map[string]time.Duration{"transfer": 2 * time.Hour}}
+
+	for _, backend := range []string{BackendRedis, BackendPostgres} {
+		t.Run("backend "+backend, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+			defer cancel()
+
+			err := test_helpers.PrepareDB(ctx, db, test_helpers.Config{
+				InitFilePath:    filePathPrefix + v.GetString("testing_params.db_init_file_path"),
+				CleanUpFilePath: filePathPrefix + v.GetString("testing_params.db_cleanup_file_path"),
+			})
+			require.NoError(t, err, "PrepareDB must not return error")
+
+			store, err := NewStore(backend, redisPool, db, cfg)
+			require.NoError(t, err, "NewStore must not return error")
+
+			key := uuid.NewV4().String()
+			rec, err := store.Begin(ctx, "transfer", key)
+			assert.NoError(t, err, "Begin must not return error")
+			assert.Nil(t, rec, "new key must not have record")
+
+			rec, err = store.Begin(ctx, "transfer", key)
+			assert.NoError(t, err, "Begin must not return error")
+			require.NotNil(t, rec, "duplicate key must have record")
+			assert.Equal(t, StateInProgress, rec.State, "state must match")
+
+			assert.NoError(t, store.Release(ctx, "transfer", key), "Release must not return error")
+			rec, err = store.Begin(ctx, "transfer", key)
+			assert.NoError(t, err, "Begin must not return error")
+			assert.Nil(t, rec, "released key must not have record")
+
+			assert.NoError(t, store.Complete(ctx, "transfer", key), "Complete must not return error")
+			assert.NoError(t, store.Release(ctx, "transfer", key), "Release must not return error")
+			rec, err = store.Begin(ctx, "transfer", key)
+			assert.NoError(t, err, "Begin must not return error")
+			require.NotNil(t, rec, "completed key must have record")
+			assert.Equal(t, StateCompleted, rec.State, "state must match")
+			assert.Equal(t, "transfer", rec.Endpoint, "endpoint must match")
+			assert.WithinDuration(t, time.Now().Add(2*time.Hour), rec.ExpiresAt, time.Minute, "expiration time must match")
+
+			_, err = store.DeleteExpired(ctx, time.Now().Add(3*time.Hour))
+			assert.NoError(t, err, "DeleteExpired must not return error")
+		})
+	}
+}
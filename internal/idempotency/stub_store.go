@@ -0,0 +1,68 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+)
+
+//StubStoreWithAnyKeyCompleted is a struct implementing IIdempotencyStore interface, made for tests,
+//reports any key as completed
+type StubStoreWithAnyKeyCompleted struct {
+}
+
+func (s *StubStoreWithAnyKeyCompleted) Begin(ctx context.Context, endpoint string, key string) (*Record, error) {
+	return &Record{Key: key, Endpoint: endpoint, State: StateCompleted, ExpiresAt: time.Now().Add(defaultTTL)}, nil
+}
+
+func (s *StubStoreWithAnyKeyCompleted) Complete(ctx context.Context, endpoint string, key string) error {
+	return nil
+}
+
+func (s *StubStoreWithAnyKeyCompleted) Release(ctx context.Context, endpoint string, key string) error {
+	return nil
+}
+
+func (s *StubStoreWithAnyKeyCompleted) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+//StubStoreWithAnyKeyInProgress is a struct implementing IIdempotencyStore interface, made for tests,
+//reports any key as in progress
+type StubStoreWithAnyKeyInProgress struct {
+}
+
+func (s *StubStoreWithAnyKeyInProgress) Begin(ctx context.Context, endpoint string, key string) (*Record, error) {
+	return &Record{Key: key, Endpoint: endpoint, State: StateInProgress, ExpiresAt: time.Now().Add(defaultInProgressTTL)}, nil
+}
+
+func (s *StubStoreWithAnyKeyInProgress) Complete(ctx context.Context, endpoint string, key string) error {
+	return nil
+}
+
+func (s *StubStoreWithAnyKeyInProgress) Release(ctx context.Context, endpoint string, key string) error {
+	return nil
+}
+
+func (s *StubStoreWithAnyKeyInProgress) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+//StubStoreFaulty is a struct implementing IIdempotencyStore interface, made for tests, its methods always return an error
+type StubStoreFaulty struct {
+}
+
+func (s *StubStoreFaulty) Begin(ctx context.Context, endpoint string, key string) (*Record, error) {
+	return nil, ErrFailedToBeginKey
+}
+
+func (s *StubStoreFaulty) Complete(ctx context.Context, endpoint string, key string) error {
+	return ErrFailedToCompleteKey
+}
+
+func (s *StubStoreFaulty) Release(ctx context.Context, endpoint string, key string) error {
+	return ErrFailedToReleaseKey
+}
+
+func (s *StubStoreFaulty) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return 0, ErrFailedToDeleteExpiredKeys
+}
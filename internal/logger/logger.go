@@ -66,3 +66,10 @@ func (l *FileLogger) Error(format string, v ...interface{}) {
 		}
 	}
 }
+
+//SetLogLevel changes level of messages, written by logger
+func (l *FileLogger) SetLogLevel(logLevel int64) {
+	l.mu.Lock()
+	l.logLevel = logLevel
+	l.mu.Unlock()
+}
@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+//sweepInterval is an interval of removal of idle buckets, idle bucket is full, so it is the same as new one
+var sweepInterval = time.Minute
+
+type Config struct {
+	//RequestsPerSecond is a steady rate of requests of one client, zero disables limits
+	RequestsPerSecond float64
+	//Burst is a max number of requests of one client at once, zero means requests of one second
+	Burst int
+}
+
+//Validate checks config, which may be changed on config reload
+func (cfg *Config) Validate() error {
+	if cfg.RequestsPerSecond < 0 || math.IsNaN(cfg.RequestsPerSecond) || math.IsInf(cfg.RequestsPerSecond, 0) {
+		return fmt.Errorf("requests per second must be non-negative number")
+	}
+	if cfg.Burst < 0 {
+		return fmt.Errorf("burst must be non-negative")
+	}
+	return nil
+}
+
+func (cfg *Config) burst() float64 {
+	if cfg.Burst > 0 {
+		return float64(cfg.Burst)
+	}
+	return math.Max(1, math.Ceil(cfg.RequestsPerSecond))
+}
+
+//bucket keeps tokens of one client, each request takes one token
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+//Limiter limits rate of requests of each client by token bucket, it may be used concurrently
+type Limiter struct {
+	cfg       Config
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+func NewLimiter(cfg *Config) (*Limiter, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Limiter{cfg: *cfg, buckets: map[string]*bucket{}, now: time.Now}, nil
+}
+
+//GetConfig returns copy of current limiter config
+func (l *Limiter) GetConfig() Config {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cfg
+}
+
+//SetConfig replaces limiter config, it is used on config reload.
+//Tokens of clients are kept, but limited by new burst
+func (l *Limiter) SetConfig(cfg *Config) error {
+	if cfg == nil {
+		return fmt.Errorf("must provide non-nil config")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cfg = *cfg
+	burst := l.cfg.burst()
+	for _, b := range l.buckets {
+		b.tokens = math.Min(b.tokens, burst)
+	}
+	return nil
+}
+
+//Allow takes token of client, if request is not allowed, retryAfter is a time until the next token
+func (l *Limiter) Allow(client string) (allowed bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cfg.RequestsPerSecond == 0 {
+		return true, 0
+	}
+
+	now := l.now()
+	burst := l.cfg.burst()
+	l.sweep(now, burst)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: burst, updatedAt: now}
+		l.buckets[client] = b
+	}
+
+	if elapsed := now.Sub(b.updatedAt); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed.Seconds()*l.cfg.RequestsPerSecond)
+	}
+	b.updatedAt = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	retryAfter = time.Duration((1 - b.tokens) / l.cfg.RequestsPerSecond * float64(time.Second))
+	return false, retryAfter
+}
+
+//sweep removes buckets, refilled up to burst, at most once per sweepInterval
+func (l *Limiter) sweep(now time.Time, burst float64) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	refillTime := time.Duration(burst / l.cfg.RequestsPerSecond * float64(time.Second))
+	for client, b := range l.buckets {
+		if now.Sub(b.updatedAt) >= refillTime {
+			delete(l.buckets, client)
+		}
+	}
+}
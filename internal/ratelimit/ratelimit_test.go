@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Common(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2020-08-11T10:23:58+03:00")
+
+	newLimiter := func(t *testing.T, cfg *Config) *Limiter {
+		l, err := NewLimiter(cfg)
+		require.NoError(t, err, "NewLimiter must not return error")
+		l.now = func() time.Time { return now }
+		return l
+	}
+
+	t.Run("positive path, burst is allowed, then requests are limited by rate", func(t *testing.T) {
+		l := newLimiter(t, &Config{RequestsPerSecond: 2, Burst: 3})
+		for i := 0; i < 3; i++ {
+			allowed, _ := l.Allow("client")
+			require.True(t, allowed, "request of burst must be allowed")
+		}
+
+		allowed, retryAfter := l.Allow("client")
+		assert.False(t, allowed, "request over burst must be limited")
+		assert.Equal(t, 500*time.Millisecond, retryAfter, "retry after must be time until next token")
+
+		allowed, _ = l.Allow("other client")
+		assert.True(t, allowed, "clients must be limited separately")
+
+		l.now = func() time.Time { return now.Add(500 * time.Millisecond) }
+		allowed, _ = l.Allow("client")
+		assert.True(t, allowed, "token must be refilled")
+		allowed, _ = l.Allow("client")
+		assert.False(t, allowed)
+	})
+
+	t.Run("positive path, zero rate disables limits", func(t *testing.T) {
+		l := newLimiter(t, nil)
+		for i := 0; i < 100; i++ {
+			allowed, _ := l.Allow("client")
+			require.True(t, allowed)
+		}
+	})
+
+	t.Run("positive path, SetConfig changes limits", func(t *testing.T) {
+		l := newLimiter(t, &Config{RequestsPerSecond: 10})
+		for i := 0; i < 5; i++ {
+			allowed, _ := l.Allow("client")
+			require.True(t, allowed)
+		}
+
+		require.NoError(t, l.SetConfig(&Config{RequestsPerSecond: 1, Burst: 2}))
+		assert.Equal(t, Config{RequestsPerSecond: 1, Burst: 2}, l.GetConfig())
+		for i := 0; i < 2; i++ {
+			allowed, _ := l.Allow("client")
+			require.True(t, allowed)
+		}
+		allowed, _ := l.Allow("client")
+		assert.False(t, allowed, "tokens must be limited by new burst")
+
+		require.NoError(t, l.SetConfig(&Config{}))
+		allowed, _ = l.Allow("client")
+		assert.True(t, allowed, "limits must be disabled")
+	})
+
+	t.Run("positive path, idle buckets are removed", func(t *testing.T) {
+		l := newLimiter(t, &Config{RequestsPerSecond: 1, Burst: 1})
+		allowed, _ := l.Allow("client")
+		require.True(t, allowed)
+		require.Len(t, l.buckets, 1)
+
+		l.now = func() time.Time { return now.Add(sweepInterval) }
+		allowed, _ = l.Allow("other client")
+		require.True(t, allowed)
+		assert.Len(t, l.buckets, 1, "refilled bucket must be removed")
+	})
+
+	t.Run("negative path, bad config", func(t *testing.T) {
+		_, err := NewLimiter(&Config{RequestsPerSecond: -1})
+		assert.Error(t, err)
+
+		l := newLimiter(t, &Config{RequestsPerSecond: 1})
+		assert.Error(t, l.SetConfig(&Config{Burst: -1}))
+		assert.Equal(t, Config{RequestsPerSecond: 1}, l.GetConfig(), "config must not be changed")
+	})
+}
@@ -0,0 +1,8 @@
+package reconciliation
+
+import "fmt"
+
+var (
+	ErrDBFailedToFetchBalanceRows      = fmt.Errorf("failed to fetch user balance rows from database")
+	ErrDBFailedToInsertDiscrepancyRows = fmt.Errorf("failed to insert balance discrepancy rows to database")
+)
@@ -0,0 +1,37 @@
+package reconciliation
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+//BalanceCheck represents stored balance of user and sum of his operations
+type BalanceCheck struct {
+	UserId        int64           `db:"user_id"`
+	Balance       decimal.Decimal `db:"balance"`
+	OperationsSum decimal.Decimal `db:"operations_sum"`
+}
+
+//Discrepancy represents user, whose stored balance differs from sum of his operations
+type Discrepancy struct {
+	//identifier of user
+	//example: 1
+	UserId int64 `json:"user_id" db:"user_id"`
+	//stored balance of user
+	//example: 100
+	Balance decimal.Decimal `json:"balance" db:"balance"`
+	//balance, recomputed from user operations
+	//example: 90
+	OperationsSum decimal.Decimal `json:"operations_sum" db:"operations_sum"`
+	//stored balance minus recomputed balance
+	//example: 10
+	Delta decimal.Decimal `json:"delta" db:"delta"`
+}
+
+//Report represents result of one reconciliation run
+type Report struct {
+	StartedAt     time.Time     `json:"started_at"`
+	FinishedAt    time.Time     `json:"finished_at"`
+	UsersChecked  int64         `json:"users_checked"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
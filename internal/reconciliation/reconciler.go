@@ -0,0 +1,151 @@
+package reconciliation
+
+import (
+	"context"
+	"fmt"
+	"job-backend-trainee-assignment/internal/logger"
+	"sync"
+	"time"
+)
+
+//Reconciler recomputes user balances from operations and reports users,
+//whose stored balance differs from sum of their operations
+type Reconciler struct {
+	logger logger.ILogger
+	store  IBalanceStore
+	cfg    *Config
+	mu     sync.Mutex
+}
+
+type Config struct {
+	//Interval is a period of reconciliation runs of Run
+	Interval time.Duration
+	//ChunkSize is a number of users checked by one query
+	ChunkSize int
+	//ChunkPause is a pause between chunks, it limits load of database
+	ChunkPause time.Duration
+	//WriteReport enables storing of found discrepancies in database
+	WriteReport bool
+}
+
+var (
+	defaultInterval  = 24 * time.Hour
+	defaultChunkSize = 1000
+)
+
+func NewReconciler(logger logger.ILogger, store IBalanceStore, cfg *Config) (*Reconciler, error) {
+	if logger == nil {
+		return nil, fmt.Errorf("must provide non-nil logger instance")
+	}
+
+	if store == nil {
+		return nil, fmt.Errorf("must provide non-nil balance store instance")
+	}
+
+	if cfg == nil {
+		cfg = &Config{Interval: defaultInterval, ChunkSize: defaultChunkSize}
+	}
+
+	if cfg.Interval <= 0 || cfg.ChunkSize <= 0 {
+		return nil, fmt.Errorf("interval and chunk size must be positive")
+	}
+
+	if cfg.ChunkPause < 0 {
+		return nil, fmt.Errorf("chunk pause must be non-negative")
+	}
+
+	return &Reconciler{
+		logger: logger,
+		store:  store,
+		cfg:    cfg,
+		mu:     sync.Mutex{},
+	}, nil
+}
+
+//Run reconciles balances periodically until ctx is done
+func (rc *Reconciler) Run(ctx context.Context) {
+	rc.mu.Lock()
+	interval := rc.cfg.Interval
+	rc.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := rc.Reconcile(ctx)
+		if err != nil {
+			rc.logger.Error("Run, reconciliation failed, err %v", err)
+			continue
+		}
+
+		rc.logger.Info("Run, checked %d users, found %d discrepancies", report.UsersChecked, len(report.Discrepancies))
+	}
+}
+
+//Reconcile checks all users chunk by chunk. Each chunk is read by one statement without locks,
+//so users, changed between chunks, are checked against their state at the moment of chunk read
+func (rc *Reconciler) Reconcile(ctx context.Context) (*Report, error) {
+	rc.mu.Lock()
+	chunkSize := rc.cfg.ChunkSize
+	chunkPause := rc.cfg.ChunkPause
+	writeReport := rc.cfg.WriteReport
+	rc.mu.Unlock()
+
+	report := &Report{StartedAt: time.Now(), Discrepancies: make([]Discrepancy, 0)}
+
+	var lastUserId int64 = 0
+	for {
+		checks, err := rc.store.FetchBalanceChecks(ctx, lastUserId, chunkSize)
+		if err != nil {
+			rc.logger.Error("Reconcile, failed to fetch users after %d, err %v", lastUserId, err)
+			return nil, err
+		}
+
+		chunkDiscrepancies := make([]Discrepancy, 0)
+		for _, check := range checks {
+			if !check.Balance.Equal(check.OperationsSum) {
+				d := Discrepancy{
+					UserId:        check.UserId,
+					Balance:       check.Balance,
+					OperationsSum: check.OperationsSum,
+					Delta:         check.Balance.Sub(check.OperationsSum),
+				}
+				rc.logger.Error("Reconcile, balance of user %d is %s, sum of operations is %s, delta %s",
+					d.UserId, d.Balance, d.OperationsSum, d.Delta)
+				chunkDiscrepancies = append(chunkDiscrepancies, d)
+			}
+		}
+		report.UsersChecked += int64(len(checks))
+		report.Discrepancies = append(report.Discrepancies, chunkDiscrepancies...)
+
+		if writeReport {
+			err = rc.store.SaveDiscrepancies(ctx, report.StartedAt, chunkDiscrepancies)
+			if err != nil {
+				rc.logger.Error("Reconcile, failed to save discrepancies, err %v", err)
+				return nil, err
+			}
+		}
+
+		if len(checks) < chunkSize {
+			break
+		}
+		lastUserId = checks[len(checks)-1].UserId
+
+		if chunkPause > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(chunkPause):
+			}
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
@@ -0,0 +1,99 @@
+package reconciliation
+
+import (
+	"context"
+	"errors"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/logger"
+	"testing"
+	"time"
+)
+
+var errStubFetchFailed = errors.New("stub fetch failed")
+
+//StubBalanceStore returns stored checks chunk by chunk
+type StubBalanceStore struct {
+	checks     []BalanceCheck
+	failAfter  int64
+	chunkCalls []int64
+	saved      []Discrepancy
+}
+
+func (ss *StubBalanceStore) FetchBalanceChecks(ctx context.Context, afterUserId int64, limit int) ([]BalanceCheck, error) {
+	ss.chunkCalls = append(ss.chunkCalls, afterUserId)
+	if ss.failAfter != 0 && afterUserId >= ss.failAfter {
+		return nil, errStubFetchFailed
+	}
+
+	res := make([]BalanceCheck, 0, limit)
+	for _, c := range ss.checks {
+		if c.UserId > afterUserId && len(res) < limit {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+func (ss *StubBalanceStore) SaveDiscrepancies(ctx context.Context, runStartedAt time.Time, discrepancies []Discrepancy) error {
+	ss.saved = append(ss.saved, discrepancies...)
+	return nil
+}
+
+func newStubChecks() []BalanceCheck {
+	return []BalanceCheck{
+		{UserId: 1, Balance: decimal.NewFromInt(10), OperationsSum: decimal.NewFromInt(10)},
+		{UserId: 2, Balance: decimal.NewFromInt(15), OperationsSum: decimal.NewFromInt(10)},
+		{UserId: 4, Balance: decimal.NewFromInt(0), OperationsSum: decimal.NewFromInt(0)},
+		{UserId: 7, Balance: decimal.RequireFromString("0.5"), OperationsSum: decimal.NewFromInt(1)},
+		{UserId: 9, Balance: decimal.NewFromInt(3), OperationsSum: decimal.RequireFromString("3.00")},
+	}
+}
+
+func TestReconciler_Reconcile(t *testing.T) {
+	t.Run("positive path, discrepancies are found in all chunks", func(t *testing.T) {
+		store := &StubBalanceStore{checks: newStubChecks()}
+		rc, err := NewReconciler(&logger.DummyLogger{}, store, &Config{Interval: time.Hour, ChunkSize: 2, WriteReport: true})
+		require.NoError(t, err, "NewReconciler must not return error")
+
+		report, err := rc.Reconcile(context.Background())
+		require.NoError(t, err, "Reconcile must not return error")
+
+		assert.Equal(t, []int64{0, 2, 7}, store.chunkCalls, "chunks must be fetched after last user of previous chunk")
+		assert.Equal(t, int64(5), report.UsersChecked, "checked users number must match")
+		require.Len(t, report.Discrepancies, 2, "discrepancies number must match")
+		assert.Equal(t, int64(2), report.Discrepancies[0].UserId, "user of discrepancy must match")
+		assert.Equal(t, "5", report.Discrepancies[0].Delta.String(), "delta must match")
+		assert.Equal(t, int64(7), report.Discrepancies[1].UserId, "user of discrepancy must match")
+		assert.Equal(t, "-0.5", report.Discrepancies[1].Delta.String(), "delta must match")
+		assert.Equal(t, report.Discrepancies, store.saved, "discrepancies must be saved")
+	})
+
+	t.Run("positive path, report is not written if disabled", func(t *testing.T) {
+		store := &StubBalanceStore{checks: newStubChecks()}
+		rc, err := NewReconciler(&logger.DummyLogger{}, store, nil)
+		require.NoError(t, err, "NewReconciler must not return error")
+
+		report, err := rc.Reconcile(context.Background())
+		require.NoError(t, err, "Reconcile must not return error")
+		assert.Len(t, report.Discrepancies, 2, "discrepancies number must match")
+		assert.Empty(t, store.saved, "discrepancies must not be saved")
+	})
+
+	t.Run("negative path, chunk fetch failure", func(t *testing.T) {
+		store := &StubBalanceStore{checks: newStubChecks(), failAfter: 2}
+		rc, err := NewReconciler(&logger.DummyLogger{}, store, &Config{Interval: time.Hour, ChunkSize: 2})
+		require.NoError(t, err, "NewReconciler must not return error")
+
+		report, err := rc.Reconcile(context.Background())
+		assert.True(t, errors.Is(err, errStubFetchFailed), "must return store error")
+		assert.Nil(t, report, "report must be nil")
+	})
+
+	t.Run("negative path, store is nil", func(t *testing.T) {
+		rc, err := NewReconciler(&logger.DummyLogger{}, nil, nil)
+		assert.Error(t, err, "must get error on NewReconciler creating")
+		assert.Nil(t, rc, "ptr to reconciler instance must be nil")
+	})
+}
@@ -0,0 +1,119 @@
+package reconciliation
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type IBalanceStore interface {
+	//FetchBalanceChecks returns balance checks of users with id greater than afterUserId, ordered by user id
+	FetchBalanceChecks(ctx context.Context, afterUserId int64, limit int) ([]BalanceCheck, error)
+	//SaveDiscrepancies stores discrepancies, found by reconciliation run, started at given time
+	SaveDiscrepancies(ctx context.Context, runStartedAt time.Time, discrepancies []Discrepancy) error
+}
+
+//PostgresBalanceStore reads balances with plain selects, so reconciliation takes no row locks
+//and does not block billing operations
+type PostgresBalanceStore struct {
+	db *sqlx.DB
+}
+
+func NewPostgresBalanceStore(db *sqlx.DB) (*PostgresBalanceStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("must provide non-nil sqlx.DB pointer")
+	}
+	return &PostgresBalanceStore{db: db}, nil
+}
+
+func (s *PostgresBalanceStore) FetchBalanceChecks(ctx context.Context, afterUserId int64, limit int) ([]BalanceCheck, error) {
+	checks := make([]BalanceCheck, 0, limit)
+	//single statement sees one snapshot of both tables, so balance and operations of user are consistent
+	err := s.db.SelectContext(ctx, &checks, `SELECT u.user_id, u.balance, COALESCE(o.operations_sum, 0) AS operations_sum
+		FROM "User" u
+		LEFT JOIN LATERAL (SELECT sum(amount) AS operations_sum FROM "Operation" WHERE user_id = u.user_id) o ON true
+		WHERE u.user_id > $1 ORDER BY u.user_id LIMIT $2`, afterUserId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s, err: %w", ErrDBFailedToFetchBalanceRows.Error(), err)
+	}
+	return checks, nil
+}
+
+func (s *PostgresBalanceStore) SaveDiscrepancies(ctx context.Context, runStartedAt time.Time, discrepancies []Discrepancy) error {
+	if len(discrepancies) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s, err: %w", ErrDBFailedToInsertDiscrepancyRows.Error(), err)
+	}
+	defer tx.Rollback()
+
+	for _, d := range discrepancies {
+		_, err = tx.ExecContext(ctx, `INSERT INTO "BalanceDiscrepancy"
+			(run_started_at, user_id, balance, operations_sum, delta) VALUES ($1, $2, $3, $4, $5)`,
+			runStartedAt, d.UserId, d.Balance, d.OperationsSum, d.Delta)
+		if err != nil {
+			return fmt.Errorf("%s, err: %w", ErrDBFailedToInsertDiscrepancyRows.Error(), err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%s, err: %w", ErrDBFailedToInsertDiscrepancyRows.Error(), err)
+	}
+	return nil
+}
+
+//MySQLBalanceStore reads balances with plain selects as PostgresBalanceStore does, mysql 8 schema is used
+type MySQLBalanceStore struct {
+	db *sqlx.DB
+}
+
+func NewMySQLBalanceStore(db *sqlx.DB) (*MySQLBalanceStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("must provide non-nil sqlx.DB pointer")
+	}
+	return &MySQLBalanceStore{db: db}, nil
+}
+
+func (s *MySQLBalanceStore) FetchBalanceChecks(ctx context.Context, afterUserId int64, limit int) ([]BalanceCheck, error) {
+	checks := make([]BalanceCheck, 0, limit)
+	//single statement reads one snapshot of both tables, so balance and operations of user are consistent
+	err := s.db.SelectContext(ctx, &checks, "SELECT u.user_id, u.balance, "+
+		"COALESCE((SELECT sum(o.amount) FROM `Operation` o WHERE o.user_id = u.user_id), 0) AS operations_sum "+
+		"FROM `User` u WHERE u.user_id > ? ORDER BY u.user_id LIMIT ?", afterUserId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s, err: %w", ErrDBFailedToFetchBalanceRows.Error(), err)
+	}
+	return checks, nil
+}
+
+func (s *MySQLBalanceStore) SaveDiscrepancies(ctx context.Context, runStartedAt time.Time, discrepancies []Discrepancy) error {
+	if len(discrepancies) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s, err: %w", ErrDBFailedToInsertDiscrepancyRows.Error(), err)
+	}
+	defer tx.Rollback()
+
+	for _, d := range discrepancies {
+		_, err = tx.ExecContext(ctx, "INSERT INTO `BalanceDiscrepancy` "+
+			"(run_started_at, user_id, balance, operations_sum, delta) VALUES (?, ?, ?, ?, ?)",
+			runStartedAt, d.UserId, d.Balance, d.OperationsSum, d.Delta)
+		if err != nil {
+			return fmt.Errorf("%s, err: %w", ErrDBFailedToInsertDiscrepancyRows.Error(), err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%s, err: %w", ErrDBFailedToInsertDiscrepancyRows.Error(), err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// +build integration
+
+package reconciliation
+
+import (
+	"context"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/db_connector"
+	"job-backend-trainee-assignment/internal/logger"
+	"job-backend-trainee-assignment/internal/test_helpers"
+	"testing"
+	"time"
+)
+
+const filePathPrefix = "../../"
+
+func TestReconciler_WithPostgresStore_Common(t *testing.T) {
+	v := viper.New()
+
+	v.AddConfigPath(".")
+	v.AddConfigPath("../../")
+	v.SetConfigName("config")
+	v.AutomaticEnv()
+
+	err := v.ReadInConfig()
+	require.NoErrorf(t, err, "failed to read config file at: %s, err %v", "config", err)
+
+	var pgHost string
+	if v.GetString("DATABASE_HOST") != "" {
+		pgHost = v.GetString("DATABASE_HOST")
+	} else {
+		pgHost = v.GetString("db_params.DATABASE_HOST")
+	}
+
+	dbConfig := &db_connector.Config{
+		DriverName:    v.GetString("db_params.driver_name"),
+		DBUser:        v.GetString("db_params.user"),
+		DBPass:        v.GetString("db_params.password"),
+		DBName:        v.GetString("db_params.db_name"),
+		DBPort:        v.GetString("db_params.port"),
+		DBHost:        pgHost,
+		SSLMode:       v.GetString("db_params.ssl_mode"),
+		RetryInterval: v.GetDuration("db_params.conn_retry_interval") * time.Second,
+	}
+
+	dbConnTimeout := v.GetDuration("db_params.conn_timeout") * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), dbConnTimeout)
+	defer cancel()
+	dummyLogger := &logger.DummyLogger{}
+
+	db, dbCloseFunc, err := db_connector.DBConnectWithTimeout(ctx, dbConfig, dummyLogger)
+	require.NoErrorf(t, err, "failed to connect to db,err %v", err)
+
+	defer dbCloseFunc()
+
+	store, err := NewPostgresBalanceStore(db)
+	require.NoError(t, err, "NewPostgresBalanceStore must not return error")
+
+	rc, err := NewReconciler(dummyLogger, store, &Config{Interval: time.Hour, ChunkSize: 1, WriteReport: true})
+	require.NoError(t, err, "NewReconciler must not return error")
+
+	caseTimeout := v.GetDuration("testing_params.test_case_timeout") * time.Second
+
+	prepareDB := func(ctx context.Context, t *testing.T) {
+		err := test_helpers.PrepareDB(ctx, db, test_helpers.Config{
+			InitFilePath:    filePathPrefix + v.GetString("testing_params.db_init_file_path"),
+			CleanUpFilePath: filePathPrefix + v.GetString("testing_params.db_cleanup_file_path"),
+		})
+		require.NoError(t, err, "PrepareDB must not return error")
+	}
+
+	t.Run("positive path, balances match operations", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		report, err := rc.Reconcile(ctx)
+		require.NoError(t, err, "Reconcile must not return error")
+		assert.Equal(t, int64(2), report.UsersChecked, "checked users number must match")
+		assert.Empty(t, report.Discrepancies, "discrepancies must not be found")
+	})
+
+	t.Run("positive path, balance changed by direct sql fix", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		_, err := db.ExecContext(ctx, `UPDATE "User" SET balance = balance + 5 WHERE user_id = 2`)
+		require.NoError(t, err, "must be able to update user balance")
+
+		report, err := rc.Reconcile(ctx)
+		require.NoError(t, err, "Reconcile must not return error")
+		require.Len(t, report.Discrepancies, 1, "discrepancies number must match")
+		assert.Equal(t, int64(2), report.Discrepancies[0].UserId, "user of discrepancy must match")
+		assert.Equal(t, "5", report.Discrepancies[0].Delta.String(), "delta must match")
+
+		var stored []Discrepancy
+		err = db.SelectContext(ctx, &stored, `SELECT user_id, balance, operations_sum, delta FROM "BalanceDiscrepancy"`)
+		require.NoError(t, err, "must be able to select discrepancies")
+		require.Len(t, stored, 1, "stored discrepancies number must match")
+		assert.Equal(t, int64(2), stored[0].UserId, "user of stored discrepancy must match")
+		assert.True(t, report.Discrepancies[0].Delta.Equal(stored[0].Delta), "stored delta must match")
+	})
+}
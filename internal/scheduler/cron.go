@@ -0,0 +1,167 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//CronExpression is a parsed standard 5-field cron expression: "minute hour day-of-month month day-of-week",
+//each field supports "*", values, ranges "a-b", lists "a,b" and steps "*/n", "a-b/n"
+type CronExpression struct {
+	minutes     map[int]bool
+	hours       map[int]bool
+	daysOfMonth map[int]bool
+	months      map[int]bool
+	daysOfWeek  map[int]bool
+
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+type cronFieldBounds struct {
+	name string
+	min  int
+	max  int
+}
+
+var cronFields = []cronFieldBounds{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+//cronSearchLimit limits search of next cron activation time
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+//ParseCronExpression parses standard 5-field cron expression
+func ParseCronExpression(expr string) (*CronExpression, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("expected %d fields, got %d, err: %w", len(cronFields), len(fields), ErrBadCronExpression)
+	}
+
+	parsed := make([]map[int]bool, len(cronFields))
+	for i, field := range fields {
+		values, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		parsed[i] = values
+	}
+
+	//day of week 7 is an alias for sunday
+	if parsed[4][7] {
+		parsed[4][0] = true
+		delete(parsed[4], 7)
+	}
+
+	return &CronExpression{
+		minutes:       parsed[0],
+		hours:         parsed[1],
+		daysOfMonth:   parsed[2],
+		months:        parsed[3],
+		daysOfWeek:    parsed[4],
+		anyDayOfMonth: fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, bounds cronFieldBounds) (map[int]bool, error) {
+	maxVal := bounds.max
+	if bounds.name == "day of week" {
+		maxVal = 7
+	}
+
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangePart := part
+		step := 1
+
+		if idx := strings.Index(part, "/"); idx != -1 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("bad step in %s field \"%s\", err: %w", bounds.name, field, ErrBadCronExpression)
+			}
+			rangePart = part[:idx]
+		}
+
+		from, to := bounds.min, maxVal
+		if rangePart != "*" {
+			bordersStr := strings.SplitN(rangePart, "-", 2)
+
+			var err error
+			from, err = strconv.Atoi(bordersStr[0])
+			if err != nil {
+				return nil, fmt.Errorf("bad value in %s field \"%s\", err: %w", bounds.name, field, ErrBadCronExpression)
+			}
+
+			to = from
+			if len(bordersStr) == 2 {
+				to, err = strconv.Atoi(bordersStr[1])
+				if err != nil {
+					return nil, fmt.Errorf("bad value in %s field \"%s\", err: %w", bounds.name, field, ErrBadCronExpression)
+				}
+			} else if step != 1 {
+				to = maxVal
+			}
+		}
+
+		if from < bounds.min || to > maxVal || from > to {
+			return nil, fmt.Errorf("value out of range in %s field \"%s\", err: %w", bounds.name, field, ErrBadCronExpression)
+		}
+
+		for v := from; v <= to; v += step {
+			values[v] = true
+		}
+	}
+
+	return values, nil
+}
+
+func (ce *CronExpression) matchesDay(t time.Time) bool {
+	domMatches := ce.daysOfMonth[t.Day()]
+	dowMatches := ce.daysOfWeek[int(t.Weekday())]
+
+	//as in standard cron, if both day fields are restricted, matching any of them is enough
+	if !ce.anyDayOfMonth && !ce.anyDayOfWeek {
+		return domMatches || dowMatches
+	}
+	return domMatches && dowMatches
+}
+
+//Next returns the first activation time, strictly after given time
+func (ce *CronExpression) Next(after time.Time) (time.Time, error) {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		if !ce.months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !ce.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !ce.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !ce.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("no activation time found in %v, err: %w", cronSearchLimit, ErrBadCronExpression)
+}
@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type CronTestCase struct {
+	caseName      string
+	expr          string
+	after         string
+	expectedNext  string
+	expectedError error
+}
+
+func TestCronExpression_Next(t *testing.T) {
+	testCases := []CronTestCase{
+		{
+			caseName:     "positive path, every minute",
+			expr:         "* * * * *",
+			after:        "2020-08-11T10:23:58Z",
+			expectedNext: "2020-08-11T10:24:00Z",
+		},
+		{
+			caseName:     "positive path, exact minute is not included",
+			expr:         "* * * * *",
+			after:        "2020-08-11T10:24:00Z",
+			expectedNext: "2020-08-11T10:25:00Z",
+		},
+		{
+			caseName:     "positive path, monthly at 10:00 on the first day",
+			expr:         "0 10 1 * *",
+			after:        "2020-08-11T10:23:58Z",
+			expectedNext: "2020-09-01T10:00:00Z",
+		},
+		{
+			caseName:     "positive path, every 15 minutes",
+			expr:         "*/15 * * * *",
+			after:        "2020-08-11T10:23:58Z",
+			expectedNext: "2020-08-11T10:30:00Z",
+		},
+		{
+			caseName:     "positive path, working days range and list of hours",
+			expr:         "30 9,18 * * 1-5",
+			after:        "2020-08-14T18:30:00Z",
+			expectedNext: "2020-08-17T09:30:00Z",
+		},
+		{
+			caseName:     "positive path, sunday as 7",
+			expr:         "0 0 * * 7",
+			after:        "2020-08-11T10:23:58Z",
+			expectedNext: "2020-08-16T00:00:00Z",
+		},
+		{
+			caseName:     "positive path, day of month or day of week when both are restricted",
+			expr:         "0 0 20 * 0",
+			after:        "2020-08-11T10:23:58Z",
+			expectedNext: "2020-08-16T00:00:00Z",
+		},
+		{
+			caseName:     "positive path, leap day",
+			expr:         "0 0 29 2 *",
+			after:        "2020-03-01T00:00:00Z",
+			expectedNext: "2024-02-29T00:00:00Z",
+		},
+		{
+			caseName:      "negative path, wrong number of fields",
+			expr:          "0 10 1 *",
+			expectedError: ErrBadCronExpression,
+		},
+		{
+			caseName:      "negative path, value out of range",
+			expr:          "60 * * * *",
+			expectedError: ErrBadCronExpression,
+		},
+		{
+			caseName:      "negative path, bad step",
+			expr:          "*/0 * * * *",
+			expectedError: ErrBadCronExpression,
+		},
+		{
+			caseName:      "negative path, reversed range",
+			expr:          "* 10-5 * * *",
+			expectedError: ErrBadCronExpression,
+		},
+		{
+			caseName:      "negative path, date which never comes",
+			expr:          "0 0 31 2 *",
+			after:         "2020-08-11T10:23:58Z",
+			expectedError: ErrBadCronExpression,
+		},
+	}
+
+	for caseIdx, tc := range testCases {
+		t.Logf("\ttesting case:%d \"%s\"", caseIdx, tc.caseName)
+
+		expr, err := ParseCronExpression(tc.expr)
+		if err == nil {
+			var after time.Time
+			after, err = time.Parse(time.RFC3339, tc.after)
+			require.NoError(t, err, "must be able to parse case time")
+
+			var next time.Time
+			next, err = expr.Next(after)
+			if err == nil {
+				assert.Equal(t, tc.expectedNext, next.Format(time.RFC3339), "\t\tnext activation time must match")
+			}
+		}
+
+		if tc.expectedError != nil {
+			assert.True(t, errors.Is(err, tc.expectedError), "\t\terror must match, got %v", err)
+		} else {
+			assert.NoError(t, err, "\t\tmust not return error")
+		}
+	}
+}
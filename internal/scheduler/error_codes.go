@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"job-backend-trainee-assignment/internal/app"
+)
+
+const (
+	CodeBadCronExpression         app.ErrorCode = "SCHEDULE_BAD_CRON_EXPRESSION"
+	CodeBadOperationType          app.ErrorCode = "SCHEDULE_BAD_OPERATION_TYPE"
+	CodeBadScheduleType           app.ErrorCode = "SCHEDULE_BAD_SCHEDULE_TYPE"
+	CodeRunTimeIsNotSet           app.ErrorCode = "SCHEDULE_RUN_TIME_NOT_SET"
+	CodeIntervalIsLessThanMin     app.ErrorCode = "SCHEDULE_INTERVAL_LESS_THAN_MIN"
+	CodeMaxAttemptsIsLessThanMin  app.ErrorCode = "SCHEDULE_MAX_ATTEMPTS_LESS_THAN_MIN"
+	CodeRetryIntervalIsNegative   app.ErrorCode = "SCHEDULE_RETRY_INTERVAL_NEGATIVE"
+	CodeReceiverIsNotSet          app.ErrorCode = "SCHEDULE_RECEIVER_NOT_SET"
+	CodeScheduleNotFound          app.ErrorCode = "SCHEDULE_NOT_FOUND"
+	CodeScheduleIsNotActive       app.ErrorCode = "SCHEDULE_NOT_ACTIVE"
+	CodeAmountIsNotPositiveNumber app.ErrorCode = "SCHEDULE_AMOUNT_NOT_POSITIVE"
+	CodeDBScheduleFetchFailed     app.ErrorCode = "DB_SCHEDULE_FETCH_FAILED"
+	CodeDBScheduleInsertFailed    app.ErrorCode = "DB_SCHEDULE_INSERT_FAILED"
+	CodeDBScheduleUpdateFailed    app.ErrorCode = "DB_SCHEDULE_UPDATE_FAILED"
+	CodeDBScheduleRunFetchFailed  app.ErrorCode = "DB_SCHEDULE_RUN_FETCH_FAILED"
+	CodeDBScheduleRunInsertFailed app.ErrorCode = "DB_SCHEDULE_RUN_INSERT_FAILED"
+)
+
+var errorCatalogue = []app.ErrorCatalogueEntry{
+	{Err: ErrBadCronExpression, Code: CodeBadCronExpression, Title: "Bad cron expression"},
+	{Err: ErrBadOperationType, Code: CodeBadOperationType, Title: "Bad scheduled operation type"},
+	{Err: ErrBadScheduleType, Code: CodeBadScheduleType, Title: "Bad schedule type"},
+	{Err: ErrRunTimeIsNotSet, Code: CodeRunTimeIsNotSet, Title: "Schedule run time is not set"},
+	{Err: ErrIntervalIsLessThanMin, Code: CodeIntervalIsLessThanMin, Title: "Schedule interval is less than minimum"},
+	{Err: ErrMaxAttemptsIsLessThanMin, Code: CodeMaxAttemptsIsLessThanMin, Title: "Max attempts number is less than minimum"},
+	{Err: ErrRetryIntervalIsNegative, Code: CodeRetryIntervalIsNegative, Title: "Retry interval is negative"},
+	{Err: ErrReceiverIsNotSet, Code: CodeReceiverIsNotSet, Title: "Transfer receiver is not set"},
+	{Err: ErrScheduleDoesNotExist, Code: CodeScheduleNotFound, Title: "Payment schedule not found"},
+	{Err: ErrScheduleIsNotActive, Code: CodeScheduleIsNotActive, Title: "Payment schedule is not active"},
+	{Err: ErrAmountIsNotPositiveNumber, Code: CodeAmountIsNotPositiveNumber, Title: "Scheduled amount is not positive"},
+	{Err: ErrDBFailedToFetchScheduleRows, Code: CodeDBScheduleFetchFailed, Title: "Payment schedule fetch failed"},
+	{Err: ErrDBFailedToInsertScheduleRow, Code: CodeDBScheduleInsertFailed, Title: "Payment schedule insert failed"},
+	{Err: ErrDBFailedToUpdateScheduleRow, Code: CodeDBScheduleUpdateFailed, Title: "Payment schedule update failed"},
+	{Err: ErrDBFailedToFetchRunRows, Code: CodeDBScheduleRunFetchFailed, Title: "Schedule run fetch failed"},
+	{Err: ErrDBFailedToInsertRunRow, Code: CodeDBScheduleRunInsertFailed, Title: "Schedule run insert failed"},
+}
+
+//ErrorCatalogue returns all errors of scheduler package, known to api clients
+func ErrorCatalogue() []app.ErrorCatalogueEntry {
+	catalogue := make([]app.ErrorCatalogueEntry, len(errorCatalogue))
+	copy(catalogue, errorCatalogue)
+	return catalogue
+}
@@ -0,0 +1,27 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrBadCronExpression         = errors.New("cron expression is invalid")
+	ErrBadOperationType          = errors.New("operation type must be either \"withdraw\" or \"transfer\"")
+	ErrBadScheduleType           = errors.New("schedule type must be one of \"once\", \"interval\" or \"cron\"")
+	ErrRunTimeIsNotSet           = errors.New("one-off schedule must include \"run_at\" time")
+	ErrIntervalIsLessThanMin     = errors.New("schedule interval is less than min")
+	ErrMaxAttemptsIsLessThanMin  = errors.New("max attempts number must be greater than zero")
+	ErrRetryIntervalIsNegative   = errors.New("retry interval must be non-negative number")
+	ErrReceiverIsNotSet          = errors.New("transfer schedule must include receiver id")
+	ErrScheduleDoesNotExist      = errors.New("payment schedule with specified id does not exist")
+	ErrScheduleIsNotActive       = errors.New("payment schedule with specified id is not active")
+	ErrAmountIsNotPositiveNumber = errors.New("amount must be positive decimal number")
+
+	ErrDBFailedToFetchScheduleRows  = fmt.Errorf("failed to fetch payment schedule rows from database")
+	ErrDBFailedToInsertScheduleRow  = fmt.Errorf("failed to insert payment schedule row to database")
+	ErrDBFailedToUpdateScheduleRow  = fmt.Errorf("failed to update payment schedule row in database")
+	ErrDBFailedToFetchRunRows       = fmt.Errorf("failed to fetch schedule run rows from database")
+	ErrDBFailedToInsertRunRow       = fmt.Errorf("failed to insert schedule run row to database")
+	ErrScheduledOperationTypeBroken = fmt.Errorf("stored payment schedule has unknown operation type")
+)
@@ -0,0 +1,350 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"github.com/shopspring/decimal"
+	"job-backend-trainee-assignment/internal/app"
+	"net/http"
+	"time"
+)
+
+//validateScheduleRequest checks request params and returns time of the first run
+func (s *Scheduler) validateScheduleRequest(in *ScheduleRequest, now time.Time) (decimal.Decimal, time.Time, error) {
+	if in.IdempotencyToken == "" {
+		return decimal.Decimal{}, time.Time{}, app.ErrIdempotencyTokenIsEmpty
+	}
+
+	amount, err := decimal.NewFromString(in.Amount)
+	if err != nil || !amount.IsPositive() {
+		return decimal.Decimal{}, time.Time{}, ErrAmountIsNotPositiveNumber
+	}
+
+	switch in.OperationType {
+	case OperationTypeWithdraw:
+	case OperationTypeTransfer:
+		if in.ReceiverId == 0 {
+			return decimal.Decimal{}, time.Time{}, ErrReceiverIsNotSet
+		}
+		if in.ReceiverId == in.UserId {
+			return decimal.Decimal{}, time.Time{}, app.ErrSenderIdIsEqualToReceiverId
+		}
+	default:
+		return decimal.Decimal{}, time.Time{}, ErrBadOperationType
+	}
+
+	if in.MaxAttempts < 0 {
+		return decimal.Decimal{}, time.Time{}, ErrMaxAttemptsIsLessThanMin
+	}
+
+	if in.RetryIntervalSeconds < 0 {
+		return decimal.Decimal{}, time.Time{}, ErrRetryIntervalIsNegative
+	}
+
+	s.mu.Lock()
+	minInterval := s.cfg.MinInterval
+	s.mu.Unlock()
+
+	var firstRun time.Time
+	switch in.ScheduleType {
+	case ScheduleTypeOnce:
+		if in.RunAt == nil {
+			return decimal.Decimal{}, time.Time{}, ErrRunTimeIsNotSet
+		}
+		firstRun = *in.RunAt
+	case ScheduleTypeInterval:
+		if time.Duration(in.IntervalSeconds)*time.Second < minInterval || in.IntervalSeconds <= 0 {
+			return decimal.Decimal{}, time.Time{}, app.WithDetails(ErrIntervalIsLessThanMin,
+				map[string]interface{}{"min_interval_seconds": int64(minInterval / time.Second)})
+		}
+		firstRun = now
+		if in.RunAt != nil {
+			firstRun = *in.RunAt
+		}
+	case ScheduleTypeCron:
+		expr, err := ParseCronExpression(in.CronExpression)
+		if err != nil {
+			return decimal.Decimal{}, time.Time{}, err
+		}
+		after := now
+		if in.RunAt != nil && in.RunAt.After(now) {
+			after = in.RunAt.Add(-time.Minute)
+		}
+		firstRun, err = expr.Next(after)
+		if err != nil {
+			return decimal.Decimal{}, time.Time{}, err
+		}
+	default:
+		return decimal.Decimal{}, time.Time{}, ErrBadScheduleType
+	}
+
+	return amount, firstRun.UTC(), nil
+}
+
+//CreateSchedule stores new payment schedule, repeated request with the same token returns already created schedule
+func (s *Scheduler) CreateSchedule(ctx context.Context, in *ScheduleRequest) (*Schedule, error) {
+	if in == nil {
+		s.logger.Error("CreateSchedule, %s", app.ErrParamsStructIsNil.Error())
+		return nil, &app.AppError{Err: app.ErrParamsStructIsNil, Code: http.StatusBadRequest}
+	}
+
+	now := time.Now()
+	amount, firstRun, err := s.validateScheduleRequest(in, now)
+	if err != nil {
+		s.logger.Error("CreateSchedule, %s", err.Error())
+		return nil, &app.AppError{Err: err, Code: http.StatusBadRequest}
+	}
+
+	maxAttempts := in.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	retryIntervalSeconds := in.RetryIntervalSeconds
+	if retryIntervalSeconds == 0 {
+		retryIntervalSeconds = defaultRetryIntervalSeconds
+	}
+
+	var receiverId *int64
+	if in.OperationType == OperationTypeTransfer {
+		receiverId = &in.ReceiverId
+	}
+
+	tx, err := s.beginTx(ctx, "CreateSchedule", false)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback("CreateSchedule", tx)
+
+	usersIds := []int64{in.UserId}
+	if receiverId != nil {
+		usersIds = append(usersIds, *receiverId)
+	}
+	for _, userId := range usersIds {
+		exists, err := tx.UserExists(ctx, userId)
+		if err != nil {
+			if ctxErr := app.GetCtxError(ctx, err); ctxErr != nil {
+				s.logger.Error("CreateSchedule, %s, err %v", ctxErr.Error(), err)
+				return nil, &app.AppError{Err: ctxErr, Code: http.StatusBadRequest}
+			}
+
+			s.logger.Error("CreateSchedule, %s, err %v", app.ErrDBFailedToFetchUserRow.Error(), err)
+			return nil, &app.AppError{Err: app.ErrDBFailedToFetchUserRow, Code: http.StatusInternalServerError}
+		}
+
+		if !exists {
+			notFoundErr := app.ErrUserDoesNotExist
+			if userId != in.UserId {
+				notFoundErr = app.ErrMoneyReceiverDoesNotExist
+			}
+			s.logger.Error("CreateSchedule, %s, user %d", notFoundErr.Error(), userId)
+			return nil, &app.AppError{Err: notFoundErr, Code: http.StatusBadRequest}
+		}
+	}
+
+	schedule := &Schedule{
+		UserId:               in.UserId,
+		ReceiverId:           receiverId,
+		OperationType:        in.OperationType,
+		Amount:               amount,
+		Purpose:              in.Purpose,
+		ScheduleType:         in.ScheduleType,
+		IntervalSeconds:      in.IntervalSeconds,
+		CronExpression:       in.CronExpression,
+		NextRunAt:            firstRun,
+		NextAttemptAt:        firstRun,
+		MaxAttempts:          maxAttempts,
+		RetryIntervalSeconds: retryIntervalSeconds,
+		Active:               true,
+		CreatedAt:            now,
+		IdempotencyToken:     in.IdempotencyToken,
+	}
+	inserted, err := tx.InsertSchedule(ctx, schedule)
+	if err == nil && !inserted {
+		s.logger.Info("CreateSchedule, schedule token found in database, returning existing schedule")
+		schedule, err = tx.GetScheduleByToken(ctx, in.IdempotencyToken)
+	}
+	if err != nil {
+		if ctxErr := app.GetCtxError(ctx, err); ctxErr != nil {
+			s.logger.Error("CreateSchedule, %s, err %v", ctxErr.Error(), err)
+			return nil, &app.AppError{Err: ctxErr, Code: http.StatusBadRequest}
+		}
+
+		s.logger.Error("CreateSchedule, %s, err %v", ErrDBFailedToInsertScheduleRow.Error(), err)
+		return nil, &app.AppError{Err: ErrDBFailedToInsertScheduleRow, Code: http.StatusInternalServerError}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		if ctxErr := app.GetCtxError(ctx, err); ctxErr != nil {
+			s.logger.Error("CreateSchedule, %s, err %v", ctxErr.Error(), err)
+			return nil, &app.AppError{Err: ctxErr, Code: http.StatusBadRequest}
+		}
+
+		s.logger.Error("CreateSchedule, %s, err %v", app.ErrDBTransactionCommitFailed.Error(), err)
+		return nil, &app.AppError{Err: app.ErrDBTransactionCommitFailed, Code: http.StatusInternalServerError}
+	}
+
+	return schedule, nil
+}
+
+//beginTx begins transaction of schedule store, errors are returned as app errors
+func (s *Scheduler) beginTx(ctx context.Context, methodName string, readOnly bool) (IScheduleTx, error) {
+	tx, err := s.store.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted, ReadOnly: readOnly})
+	if err != nil {
+		if ctxErr := app.GetCtxError(ctx, err); ctxErr != nil {
+			s.logger.Error("%s, %s, err %v", methodName, ctxErr.Error(), err)
+			return nil, &app.AppError{Err: ctxErr, Code: http.StatusBadRequest}
+		}
+
+		s.logger.Error("%s, %s, err %v", methodName, app.ErrDBTransactionBeginFailed.Error(), err)
+		return nil, &app.AppError{Err: app.ErrDBTransactionBeginFailed, Code: http.StatusInternalServerError}
+	}
+	return tx, nil
+}
+
+//rollback rolls back transaction, which is not committed
+func (s *Scheduler) rollback(methodName string, tx IScheduleTx) {
+	err := tx.Rollback()
+	if err != nil && err != sql.ErrTxDone {
+		s.logger.Error("%s, %s, err %v", methodName, app.ErrDBTransactionRollbackFailed.Error(), err)
+	}
+}
+
+func (s *Scheduler) GetSchedule(ctx context.Context, scheduleId int64) (*Schedule, error) {
+	tx, err := s.beginTx(ctx, "GetSchedule", true)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback("GetSchedule", tx)
+
+	return s.getSchedule(ctx, "GetSchedule", tx, scheduleId)
+}
+
+func (s *Scheduler) getSchedule(ctx context.Context, methodName string, tx IScheduleTx, scheduleId int64) (*Schedule, error) {
+	schedule, err := tx.GetSchedule(ctx, scheduleId)
+	if err != nil {
+		if ctxErr := app.GetCtxError(ctx, err); ctxErr != nil {
+			s.logger.Error("%s, %s, err %v", methodName, ctxErr.Error(), err)
+			return nil, &app.AppError{Err: ctxErr, Code: http.StatusBadRequest}
+		}
+
+		if err == sql.ErrNoRows {
+			s.logger.Error("%s, %s, err %v", methodName, ErrScheduleDoesNotExist.Error(), err)
+			return nil, &app.AppError{Err: ErrScheduleDoesNotExist, Code: http.StatusBadRequest}
+		}
+
+		s.logger.Error("%s, %s, err %v", methodName, ErrDBFailedToFetchScheduleRows.Error(), err)
+		return nil, &app.AppError{Err: ErrDBFailedToFetchScheduleRows, Code: http.StatusInternalServerError}
+	}
+
+	return schedule, nil
+}
+
+func (s *Scheduler) GetUserSchedules(ctx context.Context, userId int64) ([]Schedule, error) {
+	tx, err := s.beginTx(ctx, "GetUserSchedules", true)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback("GetUserSchedules", tx)
+
+	exists, err := tx.UserExists(ctx, userId)
+	if err != nil {
+		if ctxErr := app.GetCtxError(ctx, err); ctxErr != nil {
+			s.logger.Error("GetUserSchedules, %s, err %v", ctxErr.Error(), err)
+			return nil, &app.AppError{Err: ctxErr, Code: http.StatusBadRequest}
+		}
+
+		s.logger.Error("GetUserSchedules, %s, err %v", app.ErrDBFailedToFetchUserRow.Error(), err)
+		return nil, &app.AppError{Err: app.ErrDBFailedToFetchUserRow, Code: http.StatusInternalServerError}
+	}
+
+	if !exists {
+		s.logger.Error("GetUserSchedules, %s, user %d", app.ErrUserDoesNotExist.Error(), userId)
+		return nil, &app.AppError{Err: app.ErrUserDoesNotExist, Code: http.StatusBadRequest}
+	}
+
+	schedules, err := tx.ListUserSchedules(ctx, userId)
+	if err != nil {
+		if ctxErr := app.GetCtxError(ctx, err); ctxErr != nil {
+			s.logger.Error("GetUserSchedules, %s, err %v", ctxErr.Error(), err)
+			return nil, &app.AppError{Err: ctxErr, Code: http.StatusBadRequest}
+		}
+
+		s.logger.Error("GetUserSchedules, %s, err %v", ErrDBFailedToFetchScheduleRows.Error(), err)
+		return nil, &app.AppError{Err: ErrDBFailedToFetchScheduleRows, Code: http.StatusInternalServerError}
+	}
+
+	return schedules, nil
+}
+
+//CancelSchedule deactivates schedule, already performed runs are kept
+func (s *Scheduler) CancelSchedule(ctx context.Context, scheduleId int64) (*Schedule, error) {
+	tx, err := s.beginTx(ctx, "CancelSchedule", false)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback("CancelSchedule", tx)
+
+	schedule, err := tx.Deactivate(ctx, scheduleId)
+	if err != nil {
+		if ctxErr := app.GetCtxError(ctx, err); ctxErr != nil {
+			s.logger.Error("CancelSchedule, %s, err %v", ctxErr.Error(), err)
+			return nil, &app.AppError{Err: ctxErr, Code: http.StatusBadRequest}
+		}
+
+		if err == sql.ErrNoRows {
+			//distinguish missing schedule from already inactive one
+			_, getErr := s.getSchedule(ctx, "CancelSchedule", tx, scheduleId)
+			if getErr != nil {
+				return nil, getErr
+			}
+
+			s.logger.Error("CancelSchedule, %s, err %v", ErrScheduleIsNotActive.Error(), err)
+			return nil, &app.AppError{Err: ErrScheduleIsNotActive, Code: http.StatusBadRequest}
+		}
+
+		s.logger.Error("CancelSchedule, %s, err %v", ErrDBFailedToUpdateScheduleRow.Error(), err)
+		return nil, &app.AppError{Err: ErrDBFailedToUpdateScheduleRow, Code: http.StatusInternalServerError}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		if ctxErr := app.GetCtxError(ctx, err); ctxErr != nil {
+			s.logger.Error("CancelSchedule, %s, err %v", ctxErr.Error(), err)
+			return nil, &app.AppError{Err: ctxErr, Code: http.StatusBadRequest}
+		}
+
+		s.logger.Error("CancelSchedule, %s, err %v", app.ErrDBTransactionCommitFailed.Error(), err)
+		return nil, &app.AppError{Err: app.ErrDBTransactionCommitFailed, Code: http.StatusInternalServerError}
+	}
+
+	return schedule, nil
+}
+
+//GetScheduleRuns returns run history of schedule, latest runs first
+func (s *Scheduler) GetScheduleRuns(ctx context.Context, scheduleId int64) ([]ScheduleRun, error) {
+	tx, err := s.beginTx(ctx, "GetScheduleRuns", true)
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollback("GetScheduleRuns", tx)
+
+	_, err = s.getSchedule(ctx, "GetScheduleRuns", tx, scheduleId)
+	if err != nil {
+		return nil, err
+	}
+
+	runs, err := tx.ListRuns(ctx, scheduleId)
+	if err != nil {
+		if ctxErr := app.GetCtxError(ctx, err); ctxErr != nil {
+			s.logger.Error("GetScheduleRuns, %s, err %v", ctxErr.Error(), err)
+			return nil, &app.AppError{Err: ctxErr, Code: http.StatusBadRequest}
+		}
+
+		s.logger.Error("GetScheduleRuns, %s, err %v", ErrDBFailedToFetchRunRows.Error(), err)
+		return nil, &app.AppError{Err: ErrDBFailedToFetchRunRows, Code: http.StatusInternalServerError}
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+const (
+	OperationTypeWithdraw = "withdraw"
+	OperationTypeTransfer = "transfer"
+
+	ScheduleTypeOnce     = "once"
+	ScheduleTypeInterval = "interval"
+	ScheduleTypeCron     = "cron"
+
+	RunStatusSucceeded = "succeeded"
+	RunStatusRetrying  = "retrying"
+	RunStatusFailed    = "failed"
+)
+
+//swagger:model ScheduleRequest
+//ScheduleRequest represents a request to create a one-off or recurring payment
+type ScheduleRequest struct {
+	//identifier of user, whose account is charged by scheduled payment
+	//required: true
+	//example: 1
+	UserId int64 `json:"user_id"`
+	//type of scheduled operation
+	//required: true
+	//enum: withdraw,transfer
+	//example: withdraw
+	OperationType string `json:"operation_type"`
+	//identifier of user receiving money, required for transfers
+	//example: 2
+	ReceiverId int64 `json:"receiver_id"`
+	//amount of money to be withdrawn or transferred on every run
+	//required: true
+	//example: 100
+	Amount string `json:"amount"`
+	//purpose of scheduled withdraw
+	//example: monthly subscription
+	Purpose string `json:"purpose"`
+	//type of schedule
+	//required: true
+	//enum: once,interval,cron
+	//example: cron
+	ScheduleType string `json:"schedule_type"`
+	//time of one-off payment, or time of the first run for interval schedule
+	//example: 2020-08-10T10:00:00Z
+	RunAt *time.Time `json:"run_at"`
+	//interval between runs in seconds, required for interval schedule
+	//example: 86400
+	IntervalSeconds int64 `json:"interval_seconds"`
+	//standard 5-field cron expression, required for cron schedule
+	//example: 0 10 1 * *
+	CronExpression string `json:"cron_expression"`
+	//max number of attempts of every run, failed runs are retried with exponential backoff
+	//default: 1
+	//example: 3
+	MaxAttempts int `json:"max_attempts"`
+	//interval in seconds before first retry, doubles on every next retry
+	//default: 60
+	//example: 60
+	RetryIntervalSeconds int64 `json:"retry_interval_seconds"`
+	//unique token of schedule creation request
+	//required: true
+	//example: 123456789
+	IdempotencyToken string `json:"idempotency_token"`
+}
+
+//swagger:model Schedule
+//Schedule represents a stored one-off or recurring payment
+type Schedule struct {
+	//schedule identifier
+	//example: 1
+	Id int64 `json:"schedule_id" db:"schedule_id"`
+	//identifier of user, whose account is charged by scheduled payment
+	//example: 1
+	UserId int64 `json:"user_id" db:"user_id"`
+	//identifier of user receiving money, set for transfers only
+	//example: 2
+	ReceiverId *int64 `json:"receiver_id,omitempty" db:"receiver_id"`
+	//type of scheduled operation
+	//example: withdraw
+	OperationType string `json:"operation_type" db:"operation_type"`
+	//amount of money to be withdrawn or transferred on every run
+	//example: 100
+	Amount decimal.Decimal `json:"amount" db:"amount"`
+	//purpose of scheduled withdraw
+	//example: monthly subscription
+	Purpose string `json:"purpose" db:"purpose"`
+	//type of schedule
+	//example: cron
+	ScheduleType string `json:"schedule_type" db:"schedule_type"`
+	//interval between runs in seconds
+	//example: 86400
+	IntervalSeconds int64 `json:"interval_seconds,omitempty" db:"interval_seconds"`
+	//cron expression of schedule
+	//example: 0 10 1 * *
+	CronExpression string `json:"cron_expression,omitempty" db:"cron_expression"`
+	//time of the next (or the last, for inactive schedules) run
+	//example: 2020-08-10T10:00:00Z
+	NextRunAt time.Time `json:"next_run_at" db:"next_run_at"`
+	//time of the next attempt of the run, differs from next_run_at when run is being retried
+	//example: 2020-08-10T10:01:00Z
+	NextAttemptAt time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+	//number of failed attempts of the current run
+	//example: 0
+	Attempt int `json:"attempt" db:"attempt"`
+	//max number of attempts of every run
+	//example: 3
+	MaxAttempts int `json:"max_attempts" db:"max_attempts"`
+	//interval in seconds before first retry
+	//example: 60
+	RetryIntervalSeconds int64 `json:"retry_interval_seconds" db:"retry_interval_seconds"`
+	//false if schedule is cancelled or has no runs left
+	//example: true
+	Active bool `json:"active" db:"active"`
+	//date, the schedule was created
+	//example: 2020-08-10T10:00:00Z
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	//token of schedule creation request
+	//example: 123456789
+	IdempotencyToken string `json:"idempotency_token" db:"idempotency_token"`
+}
+
+//swagger:model ScheduleRun
+//ScheduleRun represents a record of scheduled payment run attempt
+type ScheduleRun struct {
+	//run identifier
+	//example: 1
+	Id int64 `json:"run_id" db:"run_id"`
+	//schedule identifier
+	//example: 1
+	ScheduleId int64 `json:"schedule_id" db:"schedule_id"`
+	//time the run was scheduled at
+	//example: 2020-08-10T10:00:00Z
+	ScheduledAt time.Time `json:"scheduled_at" db:"scheduled_at"`
+	//attempt number, starting from 1
+	//example: 1
+	Attempt int `json:"attempt" db:"attempt"`
+	//attempt start time
+	//example: 2020-08-10T10:00:01Z
+	StartedAt time.Time `json:"started_at" db:"started_at"`
+	//attempt finish time
+	//example: 2020-08-10T10:00:02Z
+	FinishedAt time.Time `json:"finished_at" db:"finished_at"`
+	//attempt result
+	//enum: succeeded,retrying,failed
+	//example: succeeded
+	Status string `json:"status" db:"status"`
+	//error message of failed attempt
+	//example: user does not have enough money
+	Error string `json:"error,omitempty" db:"error"`
+	//idempotency token of billing operation, the same for every attempt of the run
+	//example: schedule-1-1597053600
+	IdempotencyToken string `json:"idempotency_token" db:"idempotency_token"`
+}
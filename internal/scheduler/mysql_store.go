@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+//MySQLStore keeps payment schedules and their runs in mysql 8 database. Mysql has no RETURNING,
+//so changed rows are read back by key in the same transaction
+type MySQLStore struct {
+	db *sqlx.DB
+}
+
+func NewMySQLStore(db *sqlx.DB) (*MySQLStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("must provide non-nil sqlx.DB pointer")
+	}
+	return &MySQLStore{db: db}, nil
+}
+
+func (ms *MySQLStore) BeginTx(ctx context.Context, opts *sql.TxOptions) (IScheduleTx, error) {
+	tx, err := ms.db.BeginTxx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &mysqlScheduleTx{tx: tx}, nil
+}
+
+type mysqlScheduleTx struct {
+	tx *sqlx.Tx
+}
+
+func (mt *mysqlScheduleTx) UserExists(ctx context.Context, userId int64) (bool, error) {
+	var foundId int64
+	err := mt.tx.GetContext(ctx, &foundId, "SELECT user_id FROM `User` WHERE user_id = ?", userId)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (mt *mysqlScheduleTx) InsertSchedule(ctx context.Context, schedule *Schedule) (bool, error) {
+	//the update of duplicate row changes nothing, so affected rows number is 0 for already stored token
+	res, err := mt.tx.ExecContext(ctx, "INSERT INTO `PaymentSchedule` (user_id, receiver_id, operation_type, "+
+		"amount, purpose, schedule_type, interval_seconds, cron_expression, next_run_at, next_attempt_at, attempt, "+
+		"max_attempts, retry_interval_seconds, active, created_at, idempotency_token) "+
+		"VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE schedule_id = schedule_id",
+		schedule.UserId, schedule.ReceiverId, schedule.OperationType, schedule.Amount, schedule.Purpose,
+		schedule.ScheduleType, schedule.IntervalSeconds, schedule.CronExpression, schedule.NextRunAt,
+		schedule.NextAttemptAt, schedule.Attempt, schedule.MaxAttempts, schedule.RetryIntervalSeconds, schedule.Active,
+		schedule.CreatedAt, schedule.IdempotencyToken)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	schedule.Id, err = res.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (mt *mysqlScheduleTx) GetSchedule(ctx context.Context, scheduleId int64) (*Schedule, error) {
+	schedule := &Schedule{}
+	err := mt.tx.GetContext(ctx, schedule, "SELECT * FROM `PaymentSchedule` WHERE schedule_id = ?", scheduleId)
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (mt *mysqlScheduleTx) GetScheduleByToken(ctx context.Context, token string) (*Schedule, error) {
+	schedule := &Schedule{}
+	err := mt.tx.GetContext(ctx, schedule, "SELECT * FROM `PaymentSchedule` WHERE idempotency_token = ?", token)
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (mt *mysqlScheduleTx) ListUserSchedules(ctx context.Context, userId int64) ([]Schedule, error) {
+	schedules := make([]Schedule, 0)
+	err := mt.tx.SelectContext(ctx, &schedules, "SELECT * FROM `PaymentSchedule` WHERE user_id = ? ORDER BY schedule_id",
+		userId)
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (mt *mysqlScheduleTx) Deactivate(ctx context.Context, scheduleId int64) (*Schedule, error) {
+	res, err := mt.tx.ExecContext(ctx, "UPDATE `PaymentSchedule` SET active = false WHERE schedule_id = ? AND active",
+		scheduleId)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return mt.GetSchedule(ctx, scheduleId)
+}
+
+func (mt *mysqlScheduleTx) LockNextDue(ctx context.Context, now time.Time) (*Schedule, error) {
+	schedule := &Schedule{}
+	err := mt.tx.GetContext(ctx, schedule, "SELECT * FROM `PaymentSchedule` "+
+		"WHERE active AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT 1 FOR UPDATE SKIP LOCKED", now)
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (mt *mysqlScheduleTx) UpdateProgress(ctx context.Context, schedule *Schedule) error {
+	_, err := mt.tx.ExecContext(ctx, "UPDATE `PaymentSchedule` SET next_run_at = ?, next_attempt_at = ?, attempt = ?, "+
+		"active = ? WHERE schedule_id = ?",
+		schedule.NextRunAt, schedule.NextAttemptAt, schedule.Attempt, schedule.Active, schedule.Id)
+	return err
+}
+
+func (mt *mysqlScheduleTx) InsertRun(ctx context.Context, run *ScheduleRun) error {
+	res, err := mt.tx.ExecContext(ctx, "INSERT INTO `ScheduleRun` (schedule_id, scheduled_at, attempt, started_at, "+
+		"finished_at, status, error, idempotency_token) VALUES (?,?,?,?,?,?,?,?)",
+		run.ScheduleId, run.ScheduledAt, run.Attempt, run.StartedAt, run.FinishedAt, run.Status, run.Error,
+		run.IdempotencyToken)
+	if err != nil {
+		return err
+	}
+
+	run.Id, err = res.LastInsertId()
+	return err
+}
+
+func (mt *mysqlScheduleTx) ListRuns(ctx context.Context, scheduleId int64) ([]ScheduleRun, error) {
+	runs := make([]ScheduleRun, 0)
+	err := mt.tx.SelectContext(ctx, &runs, "SELECT * FROM `ScheduleRun` WHERE schedule_id = ? ORDER BY run_id DESC",
+		scheduleId)
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (mt *mysqlScheduleTx) Commit() error {
+	return mt.tx.Commit()
+}
+
+func (mt *mysqlScheduleTx) Rollback() error {
+	return mt.tx.Rollback()
+}
@@ -0,0 +1,57 @@
+// +build mysql_integration
+
+package scheduler
+
+import (
+	"context"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/db_connector"
+	"job-backend-trainee-assignment/internal/logger"
+	"testing"
+	"time"
+)
+
+func TestMySQLStore_Behaviour(t *testing.T) {
+	v := viper.New()
+
+	v.AddConfigPath(".")
+	v.AddConfigPath("../../")
+	v.SetConfigName("config")
+	v.AutomaticEnv()
+
+	err := v.ReadInConfig()
+	require.NoErrorf(t, err, "failed to read config file at: %s, err %v", "config", err)
+
+	var mysqlHost string
+	if v.GetString("MYSQL_DATABASE_HOST") != "" {
+		mysqlHost = v.GetString("MYSQL_DATABASE_HOST")
+	} else {
+		mysqlHost = v.GetString("testing_params.mysql_db_params.DATABASE_HOST")
+	}
+
+	dbConfig := &db_connector.Config{
+		DriverName:    db_connector.DriverMySQL,
+		DBUser:        v.GetString("testing_params.mysql_db_params.user"),
+		DBPass:        v.GetString("testing_params.mysql_db_params.password"),
+		DBName:        v.GetString("testing_params.mysql_db_params.db_name"),
+		DBPort:        v.GetString("testing_params.mysql_db_params.port"),
+		DBHost:        mysqlHost,
+		SSLMode:       "disable",
+		RetryInterval: v.GetDuration("db_params.conn_retry_interval") * time.Second,
+	}
+
+	dbConnTimeout := v.GetDuration("db_params.conn_timeout") * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), dbConnTimeout)
+	defer cancel()
+
+	db, dbCloseFunc, err := db_connector.DBConnectWithTimeout(ctx, dbConfig, &logger.DummyLogger{})
+	require.NoErrorf(t, err, "failed to connect to db,err %v", err)
+	defer dbCloseFunc()
+
+	store, err := NewMySQLStore(db)
+	require.NoError(t, err, "NewMySQLStore must not return error")
+
+	testScheduleStoreBehaviour(t, db, store, v.GetString("testing_params.mysql_db_init_file_path"),
+		v.GetString("testing_params.mysql_db_cleanup_file_path"), v.GetDuration("testing_params.test_case_timeout")*time.Second)
+}
@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+//PostgresStore keeps payment schedules and their runs in postgres database
+type PostgresStore struct {
+	db *sqlx.DB
+}
+
+func NewPostgresStore(db *sqlx.DB) (*PostgresStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("must provide non-nil sqlx.DB pointer")
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+func (ps *PostgresStore) BeginTx(ctx context.Context, opts *sql.TxOptions) (IScheduleTx, error) {
+	tx, err := ps.db.BeginTxx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresScheduleTx{tx: tx}, nil
+}
+
+type postgresScheduleTx struct {
+	tx *sqlx.Tx
+}
+
+func (pt *postgresScheduleTx) UserExists(ctx context.Context, userId int64) (bool, error) {
+	var foundId int64
+	err := pt.tx.GetContext(ctx, &foundId, `SELECT user_id FROM "User" WHERE user_id = $1`, userId)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (pt *postgresScheduleTx) InsertSchedule(ctx context.Context, schedule *Schedule) (bool, error) {
+	err := pt.tx.GetContext(ctx, &schedule.Id, `INSERT INTO "PaymentSchedule" (user_id, receiver_id, operation_type,
+		amount, purpose, schedule_type, interval_seconds, cron_expression, next_run_at, next_attempt_at, attempt,
+		max_attempts, retry_interval_seconds, active, created_at, idempotency_token)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)
+		ON CONFLICT (idempotency_token) DO NOTHING RETURNING schedule_id`,
+		schedule.UserId, schedule.ReceiverId, schedule.OperationType, schedule.Amount, schedule.Purpose,
+		schedule.ScheduleType, schedule.IntervalSeconds, schedule.CronExpression, schedule.NextRunAt,
+		schedule.NextAttemptAt, schedule.Attempt, schedule.MaxAttempts, schedule.RetryIntervalSeconds, schedule.Active,
+		schedule.CreatedAt, schedule.IdempotencyToken)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (pt *postgresScheduleTx) GetSchedule(ctx context.Context, scheduleId int64) (*Schedule, error) {
+	schedule := &Schedule{}
+	err := pt.tx.GetContext(ctx, schedule, `SELECT * FROM "PaymentSchedule" WHERE schedule_id = $1`, scheduleId)
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (pt *postgresScheduleTx) GetScheduleByToken(ctx context.Context, token string) (*Schedule, error) {
+	schedule := &Schedule{}
+	err := pt.tx.GetContext(ctx, schedule, `SELECT * FROM "PaymentSchedule" WHERE idempotency_token = $1`, token)
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (pt *postgresScheduleTx) ListUserSchedules(ctx context.Context, userId int64) ([]Schedule, error) {
+	schedules := make([]Schedule, 0)
+	err := pt.tx.SelectContext(ctx, &schedules, `SELECT * FROM "PaymentSchedule" WHERE user_id = $1 ORDER BY schedule_id`,
+		userId)
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (pt *postgresScheduleTx) Deactivate(ctx context.Context, scheduleId int64) (*Schedule, error) {
+	schedule := &Schedule{}
+	err := pt.tx.GetContext(ctx, schedule, `UPDATE "PaymentSchedule" SET active = false
+		WHERE schedule_id = $1 AND active RETURNING *`, scheduleId)
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (pt *postgresScheduleTx) LockNextDue(ctx context.Context, now time.Time) (*Schedule, error) {
+	schedule := &Schedule{}
+	err := pt.tx.GetContext(ctx, schedule, `SELECT * FROM "PaymentSchedule"
+		WHERE active AND next_attempt_at <= $1 ORDER BY next_attempt_at LIMIT 1 FOR UPDATE SKIP LOCKED`, now)
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (pt *postgresScheduleTx) UpdateProgress(ctx context.Context, schedule *Schedule) error {
+	_, err := pt.tx.ExecContext(ctx, `UPDATE "PaymentSchedule" SET next_run_at=$1, next_attempt_at=$2, attempt=$3,
+		active=$4 WHERE schedule_id=$5`,
+		schedule.NextRunAt, schedule.NextAttemptAt, schedule.Attempt, schedule.Active, schedule.Id)
+	return err
+}
+
+func (pt *postgresScheduleTx) InsertRun(ctx context.Context, run *ScheduleRun) error {
+	return pt.tx.GetContext(ctx, &run.Id, `INSERT INTO "ScheduleRun" (schedule_id, scheduled_at, attempt, started_at,
+		finished_at, status, error, idempotency_token) VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING run_id`,
+		run.ScheduleId, run.ScheduledAt, run.Attempt, run.StartedAt, run.FinishedAt, run.Status, run.Error,
+		run.IdempotencyToken)
+}
+
+func (pt *postgresScheduleTx) ListRuns(ctx context.Context, scheduleId int64) ([]ScheduleRun, error) {
+	runs := make([]ScheduleRun, 0)
+	err := pt.tx.SelectContext(ctx, &runs, `SELECT * FROM "ScheduleRun" WHERE schedule_id = $1 ORDER BY run_id DESC`,
+		scheduleId)
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (pt *postgresScheduleTx) Commit() error {
+	return pt.tx.Commit()
+}
+
+func (pt *postgresScheduleTx) Rollback() error {
+	return pt.tx.Rollback()
+}
@@ -0,0 +1,342 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/logger"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type IScheduler interface {
+	CreateSchedule(ctx context.Context, in *ScheduleRequest) (*Schedule, error)
+	GetSchedule(ctx context.Context, scheduleId int64) (*Schedule, error)
+	GetUserSchedules(ctx context.Context, userId int64) ([]Schedule, error)
+	CancelSchedule(ctx context.Context, scheduleId int64) (*Schedule, error)
+	GetScheduleRuns(ctx context.Context, scheduleId int64) ([]ScheduleRun, error)
+}
+
+//Scheduler stores payment schedules and runs due payments through billing app
+type Scheduler struct {
+	store  IScheduleStore
+	app    app.IBillingApp
+	logger logger.ILogger
+	cfg    *Config
+	mu     sync.Mutex
+}
+
+type Config struct {
+	//PollInterval is a period of due schedules lookup
+	PollInterval time.Duration
+	//BatchSize limits number of schedules processed on one poll
+	BatchSize int
+	//RunTimeout limits duration of one billing operation
+	RunTimeout time.Duration
+	//MinInterval is a min allowed interval of interval schedule
+	MinInterval time.Duration
+	//MaxRetryInterval limits exponential backoff of retries
+	MaxRetryInterval time.Duration
+}
+
+var (
+	defaultPollInterval     = 10 * time.Second
+	defaultBatchSize        = 100
+	defaultRunTimeout       = 10 * time.Second
+	defaultMinInterval      = time.Minute
+	defaultMaxRetryInterval = 24 * time.Hour
+
+	defaultMaxAttempts          = 1
+	defaultRetryIntervalSeconds = int64(60)
+)
+
+//NewScheduler creates scheduler, which keeps schedules in database of db, store is chosen by db driver
+func NewScheduler(logger logger.ILogger, db *sqlx.DB, billingApp app.IBillingApp, cfg *Config) (*Scheduler, error) {
+	store, err := NewStore(db)
+	if err != nil {
+		return nil, err
+	}
+	return NewSchedulerWithStore(logger, store, billingApp, cfg)
+}
+
+//NewSchedulerWithStore creates scheduler, which keeps schedules in given store
+func NewSchedulerWithStore(logger logger.ILogger, store IScheduleStore, billingApp app.IBillingApp, cfg *Config) (
+	*Scheduler, error) {
+	if logger == nil {
+		return nil, fmt.Errorf("must provide non-nil logger instance")
+	}
+
+	if store == nil {
+		return nil, fmt.Errorf("must provide non-nil schedule store instance")
+	}
+
+	if billingApp == nil {
+		return nil, fmt.Errorf("must provide non-nil billing app instance")
+	}
+
+	if cfg == nil {
+		cfg = &Config{
+			PollInterval:     defaultPollInterval,
+			BatchSize:        defaultBatchSize,
+			RunTimeout:       defaultRunTimeout,
+			MinInterval:      defaultMinInterval,
+			MaxRetryInterval: defaultMaxRetryInterval,
+		}
+	}
+
+	if cfg.PollInterval <= 0 || cfg.BatchSize <= 0 || cfg.RunTimeout <= 0 {
+		return nil, fmt.Errorf("poll interval, batch size and run timeout must be positive")
+	}
+
+	return &Scheduler{
+		store:  store,
+		app:    billingApp,
+		logger: logger,
+		cfg:    cfg,
+		mu:     sync.Mutex{},
+	}, nil
+}
+
+//Run polls due schedules until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	pollInterval := s.cfg.PollInterval
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		processed, err := s.ProcessDueSchedules(ctx)
+		if err != nil {
+			s.logger.Error("Run, failed to process due schedules, err %v", err)
+		} else if processed > 0 {
+			s.logger.Info("Run, processed %d due schedules", processed)
+		}
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("Run, context is done, stopping scheduler")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//ProcessDueSchedules runs at most BatchSize due schedules, returns number of processed schedules
+func (s *Scheduler) ProcessDueSchedules(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	batchSize := s.cfg.BatchSize
+	s.mu.Unlock()
+
+	processed := 0
+	for processed < batchSize {
+		found, err := s.processNextDueSchedule(ctx, time.Now())
+		if err != nil {
+			return processed, err
+		}
+		if !found {
+			break
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+//processNextDueSchedule locks one due schedule, so several scheduler instances never run the same schedule,
+//performs its operation and records the run
+func (s *Scheduler) processNextDueSchedule(ctx context.Context, now time.Time) (bool, error) {
+	tx, err := s.store.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted, ReadOnly: false})
+	if err != nil {
+		if ctxErr := app.GetCtxError(ctx, err); ctxErr != nil {
+			return false, ctxErr
+		}
+		return false, fmt.Errorf("%s, err %w", app.ErrDBTransactionBeginFailed.Error(), err)
+	}
+
+	defer s.rollback("processNextDueSchedule", tx)
+
+	schedule, err := tx.LockNextDue(ctx, now)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		if ctxErr := app.GetCtxError(ctx, err); ctxErr != nil {
+			return false, ctxErr
+		}
+		return false, fmt.Errorf("%s, err %w", ErrDBFailedToFetchScheduleRows.Error(), err)
+	}
+
+	s.mu.Lock()
+	runTimeout := s.cfg.RunTimeout
+	maxRetryInterval := s.cfg.MaxRetryInterval
+	s.mu.Unlock()
+
+	run := &ScheduleRun{
+		ScheduleId:       schedule.Id,
+		ScheduledAt:      schedule.NextRunAt,
+		Attempt:          schedule.Attempt + 1,
+		StartedAt:        time.Now(),
+		IdempotencyToken: RunIdempotencyToken(schedule),
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, runTimeout)
+	opErr := s.runOperation(runCtx, schedule, run.IdempotencyToken)
+	cancel()
+	run.FinishedAt = time.Now()
+
+	switch {
+	case opErr == nil:
+		run.Status = RunStatusSucceeded
+		s.advanceSchedule(schedule, run.FinishedAt)
+	case isRetryableError(opErr) && run.Attempt < schedule.MaxAttempts:
+		run.Status = RunStatusRetrying
+		run.Error = opErr.Error()
+		schedule.Attempt = run.Attempt
+		schedule.NextAttemptAt = run.FinishedAt.Add(RetryDelay(schedule, maxRetryInterval))
+	default:
+		run.Status = RunStatusFailed
+		run.Error = opErr.Error()
+		s.advanceSchedule(schedule, run.FinishedAt)
+	}
+
+	if run.Status != RunStatusSucceeded {
+		s.logger.Error("processNextDueSchedule, schedule %d run %s attempt %d %s, err %v",
+			schedule.Id, run.ScheduledAt.Format(time.RFC3339), run.Attempt, run.Status, opErr)
+	}
+
+	//the run is stored even if ctx is done, otherwise retry counters would be lost
+	storeCtx := context.Background()
+	err = tx.InsertRun(storeCtx, run)
+	if err != nil {
+		return false, fmt.Errorf("%s, err %w", ErrDBFailedToInsertRunRow.Error(), err)
+	}
+
+	err = tx.UpdateProgress(storeCtx, schedule)
+	if err != nil {
+		return false, fmt.Errorf("%s, err %w", ErrDBFailedToUpdateScheduleRow.Error(), err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("%s, err %w", app.ErrDBTransactionCommitFailed.Error(), err)
+	}
+
+	return true, nil
+}
+
+func (s *Scheduler) runOperation(ctx context.Context, schedule *Schedule, idempotencyToken string) error {
+	var err error
+	switch schedule.OperationType {
+	case OperationTypeWithdraw:
+		_, err = s.app.WithdrawUserAccount(ctx, &app.WithdrawAccountRequest{
+			UserId:           schedule.UserId,
+			Purpose:          schedule.Purpose,
+			Amount:           schedule.Amount.String(),
+			IdempotencyToken: idempotencyToken,
+		})
+	case OperationTypeTransfer:
+		var receiverId int64
+		if schedule.ReceiverId != nil {
+			receiverId = *schedule.ReceiverId
+		}
+		_, err = s.app.TransferMoneyFromUserToUser(ctx, &app.MoneyTransferRequest{
+			SenderId:         schedule.UserId,
+			ReceiverId:       receiverId,
+			Amount:           schedule.Amount.String(),
+			IdempotencyToken: idempotencyToken,
+		})
+	default:
+		err = ErrScheduledOperationTypeBroken
+	}
+	return err
+}
+
+//advanceSchedule moves schedule to its next run, missed runs are skipped, schedule without next run is deactivated
+func (s *Scheduler) advanceSchedule(schedule *Schedule, now time.Time) {
+	schedule.Attempt = 0
+
+	next, ok := NextRunTime(schedule, now)
+	if !ok {
+		schedule.Active = false
+		return
+	}
+	schedule.NextRunAt = next
+	schedule.NextAttemptAt = next
+}
+
+//RunIdempotencyToken returns token of billing operation of the current schedule run.
+//The token depends on schedule id and run time only, so retries and restarts of the worker
+//can never perform the same run twice
+func RunIdempotencyToken(schedule *Schedule) string {
+	return fmt.Sprintf("schedule-%d-%d", schedule.Id, schedule.NextRunAt.Unix())
+}
+
+//NextRunTime returns time of the first run of schedule after now, ok is false if schedule has no more runs
+func NextRunTime(schedule *Schedule, now time.Time) (next time.Time, ok bool) {
+	switch schedule.ScheduleType {
+	case ScheduleTypeInterval:
+		interval := time.Duration(schedule.IntervalSeconds) * time.Second
+		if interval <= 0 {
+			return time.Time{}, false
+		}
+		next = schedule.NextRunAt.Add(interval)
+		if !next.After(now) {
+			missed := now.Sub(next)/interval + 1
+			next = next.Add(missed * interval)
+		}
+		return next, true
+	case ScheduleTypeCron:
+		expr, err := ParseCronExpression(schedule.CronExpression)
+		if err != nil {
+			return time.Time{}, false
+		}
+		after := now
+		if schedule.NextRunAt.After(after) {
+			after = schedule.NextRunAt
+		}
+		next, err = expr.Next(after)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return next, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+//RetryDelay returns delay before the next attempt of the run, the delay doubles on every failed attempt
+func RetryDelay(schedule *Schedule, maxDelay time.Duration) time.Duration {
+	delay := time.Duration(schedule.RetryIntervalSeconds) * time.Second
+	for i := 1; i < schedule.Attempt; i++ {
+		delay *= 2
+		if maxDelay > 0 && delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+//isRetryableError returns true if operation may succeed on the next attempt:
+//on internal errors, timeouts and lack of money. Validation errors and missing users are permanent
+func isRetryableError(err error) bool {
+	if errors.Is(err, app.ErrUserDoesNotHaveEnoughMoney) ||
+		errors.Is(err, app.ErrContextDeadlineExceeded) ||
+		errors.Is(err, app.ErrContextCancelled) {
+		return true
+	}
+
+	var appErr *app.AppError
+	if errors.As(err, &appErr) {
+		return appErr.Code >= http.StatusInternalServerError
+	}
+	return false
+}
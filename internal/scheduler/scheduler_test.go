@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"github.com/stretchr/testify/assert"
+	"job-backend-trainee-assignment/internal/app"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNextRunTime(t *testing.T) {
+	runAt, _ := time.Parse(time.RFC3339, "2020-08-11T10:00:00Z")
+
+	testCases := []struct {
+		caseName     string
+		schedule     *Schedule
+		now          time.Time
+		expectedNext time.Time
+		expectedOk   bool
+	}{
+		{
+			caseName:   "one-off schedule has no next run",
+			schedule:   &Schedule{ScheduleType: ScheduleTypeOnce, NextRunAt: runAt},
+			now:        runAt,
+			expectedOk: false,
+		},
+		{
+			caseName:     "interval schedule, next run after interval",
+			schedule:     &Schedule{ScheduleType: ScheduleTypeInterval, IntervalSeconds: 3600, NextRunAt: runAt},
+			now:          runAt.Add(time.Minute),
+			expectedNext: runAt.Add(time.Hour),
+			expectedOk:   true,
+		},
+		{
+			caseName:     "interval schedule, missed runs are skipped",
+			schedule:     &Schedule{ScheduleType: ScheduleTypeInterval, IntervalSeconds: 3600, NextRunAt: runAt},
+			now:          runAt.Add(5*time.Hour + time.Minute),
+			expectedNext: runAt.Add(6 * time.Hour),
+			expectedOk:   true,
+		},
+		{
+			caseName:     "cron schedule, next activation",
+			schedule:     &Schedule{ScheduleType: ScheduleTypeCron, CronExpression: "0 10 * * *", NextRunAt: runAt},
+			now:          runAt.Add(time.Minute),
+			expectedNext: runAt.Add(24 * time.Hour),
+			expectedOk:   true,
+		},
+		{
+			caseName:   "cron schedule with broken expression has no next run",
+			schedule:   &Schedule{ScheduleType: ScheduleTypeCron, CronExpression: "0 10", NextRunAt: runAt},
+			now:        runAt,
+			expectedOk: false,
+		},
+	}
+
+	for caseIdx, tc := range testCases {
+		t.Logf("\ttesting case:%d \"%s\"", caseIdx, tc.caseName)
+		next, ok := NextRunTime(tc.schedule, tc.now)
+		assert.Equal(t, tc.expectedOk, ok, "\t\tok must match")
+		if tc.expectedOk {
+			assert.True(t, tc.expectedNext.Equal(next), "\t\tnext run must match, got %v", next)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	schedule := &Schedule{RetryIntervalSeconds: 60}
+
+	expectedDelays := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute}
+	for i, expected := range expectedDelays {
+		schedule.Attempt = i + 1
+		assert.Equal(t, expected, RetryDelay(schedule, 5*time.Minute), "\t\tdelay of attempt %d must match", i+1)
+	}
+}
+
+func TestRunIdempotencyToken(t *testing.T) {
+	runAt, _ := time.Parse(time.RFC3339, "2020-08-11T10:00:00Z")
+	schedule := &Schedule{Id: 1, NextRunAt: runAt, Attempt: 0}
+
+	token := RunIdempotencyToken(schedule)
+	schedule.Attempt = 2
+	assert.Equal(t, token, RunIdempotencyToken(schedule), "retries of the run must use the same token")
+
+	schedule.NextRunAt = runAt.Add(time.Hour)
+	assert.NotEqual(t, token, RunIdempotencyToken(schedule), "different runs must use different tokens")
+}
+
+func TestIsRetryableError(t *testing.T) {
+	assert.True(t, isRetryableError(&app.AppError{Err: app.ErrUserDoesNotHaveEnoughMoney, Code: http.StatusBadRequest}))
+	assert.True(t, isRetryableError(&app.AppError{Err: app.ErrDBTransactionBeginFailed, Code: http.StatusInternalServerError}))
+	assert.True(t, isRetryableError(&app.AppError{Err: app.ErrContextDeadlineExceeded, Code: http.StatusBadRequest}))
+	assert.False(t, isRetryableError(&app.AppError{Err: app.ErrUserDoesNotExist, Code: http.StatusBadRequest}))
+	assert.False(t, isRetryableError(ErrScheduledOperationTypeBroken))
+}
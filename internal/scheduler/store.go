@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"job-backend-trainee-assignment/internal/db_connector"
+	"time"
+)
+
+//IScheduleStore begins transactions, payment schedules and their runs are read and changed inside them.
+//Not found rows are reported with sql.ErrNoRows, finished transaction is reported with sql.ErrTxDone
+type IScheduleStore interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (IScheduleTx, error)
+}
+
+//IScheduleTx is a transaction of schedule store, changes are visible to other transactions after commit only
+type IScheduleTx interface {
+	UserExists(ctx context.Context, userId int64) (bool, error)
+	//InsertSchedule stores schedule and fills its id. If schedule with the same idempotency token is already stored,
+	//inserted is false and stored schedule is not changed
+	InsertSchedule(ctx context.Context, schedule *Schedule) (inserted bool, err error)
+	//GetSchedule returns sql.ErrNoRows if schedule does not exist
+	GetSchedule(ctx context.Context, scheduleId int64) (*Schedule, error)
+	//GetScheduleByToken returns sql.ErrNoRows if schedule with the token does not exist
+	GetScheduleByToken(ctx context.Context, token string) (*Schedule, error)
+	//ListUserSchedules returns schedules of user ordered by id
+	ListUserSchedules(ctx context.Context, userId int64) ([]Schedule, error)
+	//Deactivate marks active schedule as inactive and returns it, sql.ErrNoRows is returned
+	//if schedule does not exist or is not active
+	Deactivate(ctx context.Context, scheduleId int64) (*Schedule, error)
+	//LockNextDue returns active schedule with the earliest next attempt not later than now, the schedule is locked
+	//until transaction end. Schedules, locked by other transactions, are skipped, so several scheduler instances
+	//never run the same schedule. Returns sql.ErrNoRows if there is no due schedule
+	LockNextDue(ctx context.Context, now time.Time) (*Schedule, error)
+	//UpdateProgress stores next run, next attempt, attempt counter and active flag of schedule
+	UpdateProgress(ctx context.Context, schedule *Schedule) error
+	InsertRun(ctx context.Context, run *ScheduleRun) error
+	//ListRuns returns runs of schedule, latest runs first
+	ListRuns(ctx context.Context, scheduleId int64) ([]ScheduleRun, error)
+	Commit() error
+	Rollback() error
+}
+
+//NewStore creates schedule store in database of db, store is chosen by db driver
+func NewStore(db *sqlx.DB) (IScheduleStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("must provide non-nil sqlx.DB pointer")
+	}
+
+	if db.DriverName() == db_connector.DriverMySQL {
+		return NewMySQLStore(db)
+	}
+	return NewPostgresStore(db)
+}
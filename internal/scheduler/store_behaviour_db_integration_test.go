@@ -0,0 +1,143 @@
+// +build integration mysql_integration
+
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/logger"
+	"job-backend-trainee-assignment/internal/test_helpers"
+	"testing"
+	"time"
+)
+
+const filePathPrefix = "../../"
+
+//testScheduleStoreBehaviour checks scheduler with given store, database is prepared with given sql files
+func testScheduleStoreBehaviour(t *testing.T, db *sqlx.DB, store IScheduleStore, initFilePath string,
+	cleanUpFilePath string, caseTimeout time.Duration) {
+
+	s, err := NewSchedulerWithStore(&logger.DummyLogger{}, store, &app.StubBillingAppCommon{}, &Config{
+		PollInterval: time.Hour, BatchSize: 10, RunTimeout: 5 * time.Second, MinInterval: time.Minute})
+	require.NoError(t, err, "NewSchedulerWithStore must not return error")
+
+	prepareDB := func(ctx context.Context, t *testing.T) {
+		err := test_helpers.PrepareDB(ctx, db, test_helpers.Config{
+			InitFilePath:    filePathPrefix + initFilePath,
+			CleanUpFilePath: filePathPrefix + cleanUpFilePath,
+		})
+		require.NoError(t, err, "PrepareDB must not return error")
+	}
+
+	pastRun := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	newRequest := func(userId int64, token string) *ScheduleRequest {
+		return &ScheduleRequest{UserId: userId, OperationType: OperationTypeWithdraw, Amount: "1",
+			Purpose: "subscription", ScheduleType: ScheduleTypeInterval, RunAt: &pastRun, IntervalSeconds: 3600,
+			IdempotencyToken: token}
+	}
+
+	t.Run("positive path, schedule is created once per token", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		created, err := s.CreateSchedule(ctx, newRequest(2, "1"))
+		require.NoError(t, err, "CreateSchedule must not return error")
+		assert.NotZero(t, created.Id, "id must be assigned")
+		assert.True(t, created.Active, "schedule must be active")
+		assert.True(t, pastRun.Equal(created.NextRunAt), "next run must match")
+
+		repeated, err := s.CreateSchedule(ctx, newRequest(2, "1"))
+		require.NoError(t, err, "repeated CreateSchedule must not return error")
+		assert.Equal(t, created.Id, repeated.Id, "repeated request must return the same schedule")
+
+		schedules, err := s.GetUserSchedules(ctx, 2)
+		require.NoError(t, err, "GetUserSchedules must not return error")
+		assert.Len(t, schedules, 1, "only one schedule must be stored")
+
+		_, err = s.CreateSchedule(ctx, newRequest(100, "2"))
+		assert.ErrorIs(t, err, app.ErrUserDoesNotExist)
+	})
+
+	t.Run("positive path, due schedule is run and advanced", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		created, err := s.CreateSchedule(ctx, newRequest(2, "1"))
+		require.NoError(t, err, "CreateSchedule must not return error")
+
+		processed, err := s.ProcessDueSchedules(ctx)
+		require.NoError(t, err, "ProcessDueSchedules must not return error")
+		assert.Equal(t, 1, processed, "due schedule must be processed")
+
+		runs, err := s.GetScheduleRuns(ctx, created.Id)
+		require.NoError(t, err, "GetScheduleRuns must not return error")
+		require.Len(t, runs, 1, "run must be stored")
+		assert.Equal(t, RunStatusSucceeded, runs[0].Status)
+		assert.Equal(t, RunIdempotencyToken(created), runs[0].IdempotencyToken)
+
+		schedule, err := s.GetSchedule(ctx, created.Id)
+		require.NoError(t, err, "GetSchedule must not return error")
+		assert.True(t, schedule.NextRunAt.After(time.Now()), "schedule must be moved to the next run")
+		assert.True(t, schedule.Active, "interval schedule must stay active")
+	})
+
+	t.Run("positive path, schedules locked by other transaction are skipped", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		_, err := s.CreateSchedule(ctx, newRequest(1, "1"))
+		require.NoError(t, err, "CreateSchedule must not return error")
+		_, err = s.CreateSchedule(ctx, newRequest(2, "2"))
+		require.NoError(t, err, "CreateSchedule must not return error")
+
+		opts := &sql.TxOptions{Isolation: sql.LevelReadCommitted}
+		first, err := store.BeginTx(ctx, opts)
+		require.NoError(t, err)
+		defer first.Rollback()
+		second, err := store.BeginTx(ctx, opts)
+		require.NoError(t, err)
+		defer second.Rollback()
+		third, err := store.BeginTx(ctx, opts)
+		require.NoError(t, err)
+		defer third.Rollback()
+
+		locked, err := first.LockNextDue(ctx, time.Now())
+		require.NoError(t, err, "due schedule must be locked")
+		other, err := second.LockNextDue(ctx, time.Now())
+		require.NoError(t, err, "other due schedule must be locked")
+		assert.NotEqual(t, locked.Id, other.Id, "locked schedule must be skipped")
+
+		_, err = third.LockNextDue(ctx, time.Now())
+		assert.Equal(t, sql.ErrNoRows, err, "all due schedules are locked")
+	})
+
+	t.Run("negative path, cancelled schedule is not cancelled again", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		defer cancel()
+		prepareDB(ctx, t)
+
+		created, err := s.CreateSchedule(ctx, newRequest(2, "1"))
+		require.NoError(t, err, "CreateSchedule must not return error")
+
+		cancelled, err := s.CancelSchedule(ctx, created.Id)
+		require.NoError(t, err, "CancelSchedule must not return error")
+		assert.False(t, cancelled.Active, "schedule must be inactive")
+
+		_, err = s.CancelSchedule(ctx, created.Id)
+		assert.ErrorIs(t, err, ErrScheduleIsNotActive)
+
+		_, err = s.CancelSchedule(ctx, created.Id+100)
+		assert.ErrorIs(t, err, ErrScheduleDoesNotExist)
+
+		processed, err := s.ProcessDueSchedules(ctx)
+		require.NoError(t, err, "ProcessDueSchedules must not return error")
+		assert.Zero(t, processed, "cancelled schedule must not be run")
+	})
+}
@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"job-backend-trainee-assignment/internal/app"
+	"net/http"
+	"time"
+)
+
+//StubSchedulerCommon knows users 1 and 2 and the only schedule with id 1, owned by user 1
+type StubSchedulerCommon struct {
+}
+
+var stubScheduleTime, _ = time.Parse(time.RFC3339, "2020-08-11T10:23:58+03:00")
+
+func newStubSchedule() *Schedule {
+	return &Schedule{
+		Id:                   1,
+		UserId:               1,
+		OperationType:        OperationTypeWithdraw,
+		Amount:               decimal.NewFromInt(10),
+		Purpose:              "monthly subscription",
+		ScheduleType:         ScheduleTypeCron,
+		CronExpression:       "0 10 1 * *",
+		NextRunAt:            stubScheduleTime,
+		NextAttemptAt:        stubScheduleTime,
+		MaxAttempts:          1,
+		RetryIntervalSeconds: 60,
+		Active:               true,
+		CreatedAt:            stubScheduleTime,
+		IdempotencyToken:     "1",
+	}
+}
+
+func (ss *StubSchedulerCommon) CreateSchedule(ctx context.Context, in *ScheduleRequest) (*Schedule, error) {
+	if in.UserId != 1 && in.UserId != 2 {
+		return nil, &app.AppError{Err: app.ErrUserDoesNotExist, Code: http.StatusBadRequest}
+	}
+
+	if in.ScheduleType != ScheduleTypeOnce && in.ScheduleType != ScheduleTypeInterval && in.ScheduleType != ScheduleTypeCron {
+		return nil, &app.AppError{Err: ErrBadScheduleType, Code: http.StatusBadRequest}
+	}
+
+	schedule := newStubSchedule()
+	schedule.UserId = in.UserId
+	schedule.IdempotencyToken = in.IdempotencyToken
+	return schedule, nil
+}
+
+func (ss *StubSchedulerCommon) GetSchedule(ctx context.Context, scheduleId int64) (*Schedule, error) {
+	if scheduleId != 1 {
+		return nil, &app.AppError{Err: ErrScheduleDoesNotExist, Code: http.StatusBadRequest}
+	}
+	return newStubSchedule(), nil
+}
+
+func (ss *StubSchedulerCommon) GetUserSchedules(ctx context.Context, userId int64) ([]Schedule, error) {
+	if userId != 1 && userId != 2 {
+		return nil, &app.AppError{Err: app.ErrUserDoesNotExist, Code: http.StatusBadRequest}
+	}
+
+	if userId == 2 {
+		return []Schedule{}, nil
+	}
+	return []Schedule{*newStubSchedule()}, nil
+}
+
+func (ss *StubSchedulerCommon) CancelSchedule(ctx context.Context, scheduleId int64) (*Schedule, error) {
+	schedule, err := ss.GetSchedule(ctx, scheduleId)
+	if err != nil {
+		return nil, err
+	}
+	schedule.Active = false
+	return schedule, nil
+}
+
+func (ss *StubSchedulerCommon) GetScheduleRuns(ctx context.Context, scheduleId int64) ([]ScheduleRun, error) {
+	schedule, err := ss.GetSchedule(ctx, scheduleId)
+	if err != nil {
+		return nil, err
+	}
+
+	return []ScheduleRun{{
+		Id:               1,
+		ScheduleId:       schedule.Id,
+		ScheduledAt:      stubScheduleTime,
+		Attempt:          1,
+		StartedAt:        stubScheduleTime,
+		FinishedAt:       stubScheduleTime,
+		Status:           RunStatusSucceeded,
+		IdempotencyToken: RunIdempotencyToken(schedule),
+	}}, nil
+}
@@ -0,0 +1,107 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/logger"
+	"sync"
+	"time"
+)
+
+type IBalanceSnapshotter interface {
+	SnapshotUserBalances(ctx context.Context, day time.Time) (int64, error)
+}
+
+//Worker periodically stores closing balances of completed days
+type Worker struct {
+	logger      logger.ILogger
+	snapshotter IBalanceSnapshotter
+	cfg         *Config
+	mu          sync.Mutex
+}
+
+type Config struct {
+	//CheckInterval is a period of completed days check
+	CheckInterval time.Duration
+	//BackfillDays is a number of completed days, checked for missing snapshots,
+	//so snapshots of days, when app was down, are stored after restart
+	BackfillDays int
+}
+
+var (
+	defaultCheckInterval = time.Hour
+	defaultBackfillDays  = 7
+)
+
+func NewWorker(logger logger.ILogger, snapshotter IBalanceSnapshotter, cfg *Config) (*Worker, error) {
+	if logger == nil {
+		return nil, fmt.Errorf("must provide non-nil logger instance")
+	}
+
+	if snapshotter == nil {
+		return nil, fmt.Errorf("must provide non-nil balance snapshotter instance")
+	}
+
+	if cfg == nil {
+		cfg = &Config{CheckInterval: defaultCheckInterval, BackfillDays: defaultBackfillDays}
+	}
+
+	if cfg.CheckInterval <= 0 || cfg.BackfillDays <= 0 {
+		return nil, fmt.Errorf("check interval and backfill days number must be positive")
+	}
+
+	return &Worker{
+		logger:      logger,
+		snapshotter: snapshotter,
+		cfg:         cfg,
+		mu:          sync.Mutex{},
+	}, nil
+}
+
+//Run stores snapshots of completed days until ctx is done
+func (w *Worker) Run(ctx context.Context) {
+	w.mu.Lock()
+	checkInterval := w.cfg.CheckInterval
+	w.mu.Unlock()
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		stored, err := w.SnapshotCompletedDays(ctx, time.Now())
+		if err != nil {
+			w.logger.Error("Run, failed to store balance snapshots, err %v", err)
+		} else if stored > 0 {
+			w.logger.Info("Run, stored %d balance snapshots", stored)
+		}
+
+		select {
+		case <-ctx.Done():
+			w.logger.Info("Run, context is done, stopping snapshot worker")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//SnapshotCompletedDays stores missing snapshots of last completed days, oldest day first
+func (w *Worker) SnapshotCompletedDays(ctx context.Context, now time.Time) (int64, error) {
+	w.mu.Lock()
+	backfillDays := w.cfg.BackfillDays
+	w.mu.Unlock()
+
+	today := app.StartOfDay(now)
+
+	var storedTotal int64
+	for i := backfillDays; i >= 1; i-- {
+		day := today.AddDate(0, 0, -i)
+		stored, err := w.snapshotter.SnapshotUserBalances(ctx, day)
+		if err != nil {
+			return storedTotal, fmt.Errorf("day %s, err %w", day.Format("2006-01-02"), err)
+		}
+		storedTotal += stored
+	}
+
+	return storedTotal, nil
+}
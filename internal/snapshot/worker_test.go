@@ -0,0 +1,57 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/logger"
+	"testing"
+	"time"
+)
+
+var errStubSnapshotFailed = errors.New("stub snapshot failed")
+
+type StubSnapshotter struct {
+	days    []string
+	failDay string
+}
+
+func (ss *StubSnapshotter) SnapshotUserBalances(ctx context.Context, day time.Time) (int64, error) {
+	if day.Format("2006-01-02") == ss.failDay {
+		return 0, errStubSnapshotFailed
+	}
+	ss.days = append(ss.days, day.Format("2006-01-02"))
+	return 2, nil
+}
+
+func TestWorker_SnapshotCompletedDays(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2020-08-11T10:23:58+03:00")
+
+	t.Run("positive path, completed days are snapshotted oldest first", func(t *testing.T) {
+		snapshotter := &StubSnapshotter{}
+		w, err := NewWorker(&logger.DummyLogger{}, snapshotter, &Config{CheckInterval: time.Hour, BackfillDays: 3})
+		require.NoError(t, err, "NewWorker must not return error")
+
+		stored, err := w.SnapshotCompletedDays(context.Background(), now)
+		assert.NoError(t, err, "must not return error")
+		assert.Equal(t, int64(6), stored, "stored snapshots number must match")
+		assert.Equal(t, []string{"2020-08-08", "2020-08-09", "2020-08-10"}, snapshotter.days, "snapshotted days must match")
+	})
+
+	t.Run("negative path, snapshot failure stops the check", func(t *testing.T) {
+		snapshotter := &StubSnapshotter{failDay: "2020-08-09"}
+		w, err := NewWorker(&logger.DummyLogger{}, snapshotter, &Config{CheckInterval: time.Hour, BackfillDays: 3})
+		require.NoError(t, err, "NewWorker must not return error")
+
+		stored, err := w.SnapshotCompletedDays(context.Background(), now)
+		assert.True(t, errors.Is(err, errStubSnapshotFailed), "must return snapshotter error")
+		assert.Equal(t, int64(2), stored, "stored snapshots number must match")
+	})
+
+	t.Run("negative path, snapshotter is nil", func(t *testing.T) {
+		w, err := NewWorker(&logger.DummyLogger{}, nil, nil)
+		assert.Error(t, err, "must get error on NewWorker creating")
+		assert.Nil(t, w, "ptr to worker instance must be nil")
+	})
+}
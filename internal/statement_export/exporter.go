@@ -0,0 +1,246 @@
+package statement_export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"io"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/logger"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Format is a format of exported statement
+type Format string
+
+const (
+	//FormatCSV is a comma separated values file (RFC 4180)
+	FormatCSV Format = "csv"
+	//FormatExcel is a csv file, which is opened by spreadsheet editors (Excel, LibreOffice) without import dialog
+	FormatExcel Format = "excel"
+	//FormatHTML is a printable html page, it may be saved as pdf with browser print dialog
+	FormatHTML Format = "html"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported statement export format")
+
+//dateLayout is a layout of period days, days are counted in UTC
+const dateLayout = "2006-01-02"
+
+//ParseFormat returns export format by its name, empty name means csv
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case "", FormatCSV:
+		return FormatCSV, nil
+	case FormatExcel:
+		return FormatExcel, nil
+	case FormatHTML:
+		return FormatHTML, nil
+	}
+	return "", fmt.Errorf("format %s, err: %w", name, ErrUnsupportedFormat)
+}
+
+//ContentType returns content type of exported file
+func (f Format) ContentType() string {
+	if f == FormatHTML {
+		return "text/html; charset=utf-8"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+//FileName returns name of exported file for content disposition header
+func (f Format) FileName(h *Header) string {
+	ext := "csv"
+	if f == FormatHTML {
+		ext = "html"
+	}
+	return fmt.Sprintf("statement_%d_%s_%s.%s", h.UserId, h.From, h.To, ext)
+}
+
+type IOperationsSource interface {
+	GetUserInfo(ctx context.Context, in *app.UserInfoRequest) (*app.UserInfo, error)
+	StreamUserStatement(ctx context.Context, in *app.StatementStreamRequest, onOpening func(decimal.Decimal) error,
+		onPage func([]app.Operation) error) error
+}
+
+//Header contains statement data, known before operations are exported
+type Header struct {
+	UserId   int64
+	UserName string
+	Currency string
+	From     string
+	To       string
+	//OpeningBalance is read by Export with operations of the period, so they are consistent
+	OpeningBalance decimal.Decimal
+	CreatedAt      time.Time
+	//ConsistencyToken is a token, returned by write operation, export sees this write
+	ConsistencyToken string
+}
+
+//Totals contains statement data, computed while operations are exported
+type Totals struct {
+	OperationsNum  int64
+	Credits        decimal.Decimal
+	Debits         decimal.Decimal
+	ClosingBalance decimal.Decimal
+}
+
+//Exporter streams user operations of the period page by page,
+//so memory usage does not depend on operations number
+type Exporter struct {
+	logger logger.ILogger
+	source IOperationsSource
+	cfg    *Config
+	mu     sync.Mutex
+}
+
+type Config struct {
+	//PageSize is a number of operations, fetched and written at once
+	PageSize int64
+}
+
+var defaultPageSize int64 = 500
+
+func NewExporter(logger logger.ILogger, source IOperationsSource, cfg *Config) (*Exporter, error) {
+	if logger == nil {
+		return nil, fmt.Errorf("must provide non-nil logger instance")
+	}
+
+	if source == nil {
+		return nil, fmt.Errorf("must provide non-nil operations source instance")
+	}
+
+	if cfg == nil {
+		cfg = &Config{PageSize: defaultPageSize}
+	}
+
+	if cfg.PageSize <= 0 {
+		return nil, fmt.Errorf("page size must be positive")
+	}
+
+	return &Exporter{
+		logger: logger,
+		source: source,
+		cfg:    cfg,
+		mu:     sync.Mutex{},
+	}, nil
+}
+
+//SetConfig replaces exporter config, it is used on config reload
+func (e *Exporter) SetConfig(cfg *Config) error {
+	if cfg == nil || cfg.PageSize <= 0 {
+		return fmt.Errorf("page size must be positive")
+	}
+
+	e.mu.Lock()
+	e.cfg = cfg
+	e.mu.Unlock()
+	return nil
+}
+
+//NewHeader validates the period and consistency token and fetches user info of the statement.
+//Errors are returned before anything is written, so they may be sent to client as usual error response
+func (e *Exporter) NewHeader(ctx context.Context, userId int64, from, to string, consistencyToken string) (
+	*Header, error) {
+	fromDay, err := time.ParseInLocation(dateLayout, from, time.UTC)
+	if err != nil {
+		return nil, &app.AppError{Err: app.ErrBadDateFormat, Code: http.StatusBadRequest}
+	}
+
+	toDay, err := time.ParseInLocation(dateLayout, to, time.UTC)
+	if err != nil {
+		return nil, &app.AppError{Err: app.ErrBadDateFormat, Code: http.StatusBadRequest}
+	}
+
+	if fromDay.After(toDay) {
+		return nil, &app.AppError{Err: app.ErrPeriodStartIsAfterEnd, Code: http.StatusBadRequest}
+	}
+
+	//opening balance is a closing balance of the day before the period
+	if fromDay.AddDate(0, 0, -1).After(time.Now()) {
+		return nil, &app.AppError{Err: app.ErrDateIsInFuture, Code: http.StatusBadRequest}
+	}
+
+	if err := app.ValidateConsistencyToken(consistencyToken); err != nil {
+		return nil, &app.AppError{Err: err, Code: http.StatusBadRequest}
+	}
+
+	info, err := e.source.GetUserInfo(ctx, &app.UserInfoRequest{UserId: userId})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Header{
+		UserId:           info.UserId,
+		UserName:         info.Name,
+		Currency:         info.Currency,
+		From:             from,
+		To:               to,
+		CreatedAt:        info.CreatedAt,
+		ConsistencyToken: consistencyToken,
+	}, nil
+}
+
+//Export writes statement of the period in given format to w. Opening balance and operations are read
+//with StreamUserStatement in one transaction, operations are read and written page by page.
+//If w implements Flush, it is flushed after each page, so client gets data while export goes on
+func (e *Exporter) Export(ctx context.Context, w io.Writer, format Format, h *Header) (*Totals, error) {
+	e.mu.Lock()
+	pageSize := e.cfg.PageSize
+	e.mu.Unlock()
+
+	sw, err := newStatementWriter(w, format)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := &Totals{Credits: decimal.Zero, Debits: decimal.Zero}
+
+	writeOpening := func(openingBalance decimal.Decimal) error {
+		h.OpeningBalance = openingBalance
+		totals.ClosingBalance = openingBalance
+		return sw.WriteHeader(h)
+	}
+
+	writePage := func(operations []app.Operation) error {
+		for i := range operations {
+			op := &operations[i]
+			if op.Amount.IsPositive() {
+				totals.Credits = totals.Credits.Add(op.Amount)
+			} else {
+				totals.Debits = totals.Debits.Add(op.Amount)
+			}
+			totals.ClosingBalance = totals.ClosingBalance.Add(op.Amount)
+			totals.OperationsNum++
+
+			err := sw.WriteOperation(op, totals.ClosingBalance)
+			if err != nil {
+				return err
+			}
+		}
+		return sw.Flush()
+	}
+
+	err = e.source.StreamUserStatement(ctx, &app.StatementStreamRequest{
+		UserId:           h.UserId,
+		From:             h.From,
+		To:               h.To,
+		PageSize:         pageSize,
+		ConsistencyToken: h.ConsistencyToken,
+	}, writeOpening, writePage)
+	if err != nil {
+		e.logger.Error("Export, failed to export statement of user %d, err %v", h.UserId, err)
+		return nil, err
+	}
+
+	err = sw.WriteFooter(h, totals)
+	if err != nil {
+		return nil, err
+	}
+
+	return totals, sw.Flush()
+}
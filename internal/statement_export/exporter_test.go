@@ -0,0 +1,201 @@
+package statement_export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/logger"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errStubOperationsFetchFailed = errors.New("stub operations fetch failed")
+
+//StubOperationsSource streams opening balance and operations page by page, as StreamUserStatement does
+type StubOperationsSource struct {
+	operations []app.Operation
+	failPage   int64
+	requests   []*app.StatementStreamRequest
+	pages      int64
+}
+
+func (ss *StubOperationsSource) GetUserInfo(ctx context.Context, in *app.UserInfoRequest) (*app.UserInfo, error) {
+	return &app.UserInfo{UserId: in.UserId, Name: "Mr. <Smith>", Currency: "RUB"}, nil
+}
+
+func (ss *StubOperationsSource) StreamUserStatement(ctx context.Context, in *app.StatementStreamRequest,
+	onOpening func(decimal.Decimal) error, onPage func([]app.Operation) error) error {
+	ss.requests = append(ss.requests, in)
+	err := onOpening(decimal.NewFromInt(100))
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(ss.operations); start += int(in.PageSize) {
+		ss.pages++
+		if ss.pages == ss.failPage {
+			return errStubOperationsFetchFailed
+		}
+
+		end := start + int(in.PageSize)
+		if end > len(ss.operations) {
+			end = len(ss.operations)
+		}
+		err = onPage(ss.operations[start:end])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newStubOperations(amounts ...int64) []app.Operation {
+	date, _ := time.Parse(time.RFC3339, "2020-08-11T10:23:58+03:00")
+	operations := make([]app.Operation, 0, len(amounts))
+	for i, amount := range amounts {
+		operations = append(operations, app.Operation{
+			Id:      int64(i + 1),
+			UserId:  1,
+			Comment: "payment",
+			Amount:  decimal.NewFromInt(amount),
+			Date:    date,
+		})
+	}
+	return operations
+}
+
+func TestExporter_Export(t *testing.T) {
+	t.Run("positive path, csv is written page by page", func(t *testing.T) {
+		source := &StubOperationsSource{operations: newStubOperations(10, -20, 30, -5, 15)}
+		e, err := NewExporter(&logger.DummyLogger{}, source, &Config{PageSize: 2})
+		require.NoError(t, err, "NewExporter must not return error")
+
+		h, err := e.NewHeader(context.Background(), 1, "2020-08-01", "2020-08-31", "0/3000148")
+		require.NoError(t, err, "NewHeader must not return error")
+		assert.Equal(t, "Mr. <Smith>", h.UserName, "user name must match")
+
+		buf := &bytes.Buffer{}
+		totals, err := e.Export(context.Background(), buf, FormatCSV, h)
+		require.NoError(t, err, "Export must not return error")
+		assert.Equal(t, "100", h.OpeningBalance.String(), "opening balance must match")
+
+		require.Len(t, source.requests, 1, "statement must be streamed at once")
+		assert.Equal(t, &app.StatementStreamRequest{UserId: 1, From: "2020-08-01", To: "2020-08-31", PageSize: 2,
+			ConsistencyToken: "0/3000148"}, source.requests[0], "stream request must match")
+		assert.Equal(t, int64(3), source.pages, "operations must be written in 3 pages")
+
+		assert.Equal(t, int64(5), totals.OperationsNum, "operations number must match")
+		assert.Equal(t, "55", totals.Credits.String(), "credits must match")
+		assert.Equal(t, "-25", totals.Debits.String(), "debits must match")
+		assert.Equal(t, "130", totals.ClosingBalance.String(), "closing balance must match")
+
+		assert.Equal(t, "operation_id,date,purpose,amount,balance\n"+
+			"1,2020-08-11T07:23:58Z,payment,10,110\n"+
+			"2,2020-08-11T07:23:58Z,payment,-20,90\n"+
+			"3,2020-08-11T07:23:58Z,payment,30,120\n"+
+			"4,2020-08-11T07:23:58Z,payment,-5,115\n"+
+			"5,2020-08-11T07:23:58Z,payment,15,130\n", buf.String(), "csv must match")
+	})
+
+	t.Run("positive path, excel csv has byte order mark and semicolon separator", func(t *testing.T) {
+		source := &StubOperationsSource{operations: newStubOperations(10)}
+		e, err := NewExporter(&logger.DummyLogger{}, source, nil)
+		require.NoError(t, err, "NewExporter must not return error")
+
+		h, err := e.NewHeader(context.Background(), 1, "2020-08-01", "2020-08-31", "")
+		require.NoError(t, err, "NewHeader must not return error")
+
+		buf := &bytes.Buffer{}
+		_, err = e.Export(context.Background(), buf, FormatExcel, h)
+		require.NoError(t, err, "Export must not return error")
+		assert.Equal(t, "\uFEFFoperation_id;date;purpose;amount;balance\n"+
+			"1;2020-08-11 07:23:58;payment;10;110\n", buf.String(), "csv must match")
+	})
+
+	t.Run("positive path, formulas in purpose are neutralized", func(t *testing.T) {
+		operations := newStubOperations(10, -20, 30, -5, 15, 1, 2)
+		purposes := []string{`=HYPERLINK("http://example.com","x")`, "+cmd|' /C calc'!A0", "-2+3", "@SUM(A1)",
+			"\tcell", "\rcell", "payment = 10"}
+		for i := range operations {
+			operations[i].Comment = purposes[i]
+		}
+
+		for _, format := range []Format{FormatCSV, FormatExcel} {
+			source := &StubOperationsSource{operations: operations}
+			e, err := NewExporter(&logger.DummyLogger{}, source, nil)
+			require.NoError(t, err, "NewExporter must not return error")
+
+			h, err := e.NewHeader(context.Background(), 1, "2020-08-01", "2020-08-31", "")
+			require.NoError(t, err, "NewHeader must not return error")
+
+			buf := &bytes.Buffer{}
+			_, err = e.Export(context.Background(), buf, format, h)
+			require.NoError(t, err, "Export must not return error")
+
+			reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), "\uFEFF")))
+			if format == FormatExcel {
+				reader.Comma = ';'
+			}
+			records, err := reader.ReadAll()
+			require.NoError(t, err, "csv must be valid, format %s", format)
+			require.Len(t, records, len(operations)+1, "all operations must be written, format %s", format)
+
+			for i, record := range records[1:] {
+				expected := "'" + purposes[i]
+				if i == len(purposes)-1 {
+					expected = purposes[i]
+				}
+				assert.Equal(t, expected, record[2], "purpose must match, format %s", format)
+			}
+			assert.Equal(t, "-20", records[2][3], "amounts must not be changed, format %s", format)
+		}
+	})
+
+	t.Run("positive path, html is escaped and contains totals", func(t *testing.T) {
+		source := &StubOperationsSource{}
+		e, err := NewExporter(&logger.DummyLogger{}, source, nil)
+		require.NoError(t, err, "NewExporter must not return error")
+
+		h, err := e.NewHeader(context.Background(), 1, "2020-08-01", "2020-08-31", "")
+		require.NoError(t, err, "NewHeader must not return error")
+
+		buf := &bytes.Buffer{}
+		_, err = e.Export(context.Background(), buf, FormatHTML, h)
+		require.NoError(t, err, "Export must not return error")
+		assert.True(t, strings.Contains(buf.String(), "User: Mr. &lt;Smith&gt; (id 1)"), "user name must be escaped")
+		assert.True(t, strings.Contains(buf.String(), "Closing balance: 100.00 RUB"), "closing balance must match")
+	})
+
+	t.Run("negative path, operations fetch failure", func(t *testing.T) {
+		source := &StubOperationsSource{operations: newStubOperations(10, -20, 30), failPage: 2}
+		e, err := NewExporter(&logger.DummyLogger{}, source, &Config{PageSize: 2})
+		require.NoError(t, err, "NewExporter must not return error")
+
+		h, err := e.NewHeader(context.Background(), 1, "2020-08-01", "2020-08-31", "")
+		require.NoError(t, err, "NewHeader must not return error")
+
+		_, err = e.Export(context.Background(), &bytes.Buffer{}, FormatCSV, h)
+		assert.True(t, errors.Is(err, errStubOperationsFetchFailed), "must return source error")
+	})
+
+	t.Run("negative path, period start is after end", func(t *testing.T) {
+		e, err := NewExporter(&logger.DummyLogger{}, &StubOperationsSource{}, nil)
+		require.NoError(t, err, "NewExporter must not return error")
+
+		_, err = e.NewHeader(context.Background(), 1, "2020-08-31", "2020-08-01", "")
+		assert.True(t, errors.Is(err, app.ErrPeriodStartIsAfterEnd), "must return period error")
+
+		from := time.Now().AddDate(0, 0, 2).Format(dateLayout)
+		_, err = e.NewHeader(context.Background(), 1, from, from, "")
+		assert.True(t, errors.Is(err, app.ErrDateIsInFuture), "must return future period error")
+
+		_, err = e.NewHeader(context.Background(), 1, "2020-08-01", "2020-08-31", "bad token")
+		assert.True(t, errors.Is(err, app.ErrBadConsistencyToken), "must return consistency token error")
+	})
+}
@@ -0,0 +1,182 @@
+package statement_export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"github.com/shopspring/decimal"
+	"html/template"
+	"io"
+	"job-backend-trainee-assignment/internal/app"
+	"strconv"
+	"time"
+)
+
+//statementWriter writes statement parts in one of export formats
+type statementWriter interface {
+	WriteHeader(h *Header) error
+	WriteOperation(op *app.Operation, balance decimal.Decimal) error
+	WriteFooter(h *Header, t *Totals) error
+	//Flush writes buffered data to underlying writer and flushes it, if it supports flushing
+	Flush() error
+}
+
+type flusher interface {
+	Flush()
+}
+
+func newStatementWriter(w io.Writer, format Format) (statementWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, ',', time.RFC3339, false), nil
+	case FormatExcel:
+		//spreadsheet editors detect utf-8 by byte order mark and split columns by semicolon in most locales
+		return newCSVWriter(w, ';', "2006-01-02 15:04:05", true), nil
+	case FormatHTML:
+		return newHTMLWriter(w), nil
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+var csvColumns = []string{"operation_id", "date", "purpose", "amount", "balance"}
+
+type csvWriter struct {
+	w          io.Writer
+	csv        *csv.Writer
+	dateLayout string
+	withBOM    bool
+}
+
+func newCSVWriter(w io.Writer, comma rune, dateLayout string, withBOM bool) *csvWriter {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	return &csvWriter{w: w, csv: cw, dateLayout: dateLayout, withBOM: withBOM}
+}
+
+func (cw *csvWriter) WriteHeader(h *Header) error {
+	if cw.withBOM {
+		_, err := io.WriteString(cw.w, "\uFEFF")
+		if err != nil {
+			return err
+		}
+	}
+	return cw.csv.Write(csvColumns)
+}
+
+func (cw *csvWriter) WriteOperation(op *app.Operation, balance decimal.Decimal) error {
+	return cw.csv.Write([]string{
+		strconv.FormatInt(op.Id, 10),
+		op.Date.UTC().Format(cw.dateLayout),
+		neutralizeFormula(op.Comment),
+		op.Amount.String(),
+		balance.String(),
+	})
+}
+
+//neutralizeFormula prefixes text cell with quote, if spreadsheet editors would run the cell as formula,
+//purpose of operation is written by users, so it must never be executed when statement is opened
+func neutralizeFormula(cell string) string {
+	if cell == "" {
+		return cell
+	}
+
+	switch cell[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + cell
+	}
+	return cell
+}
+
+//WriteFooter writes nothing, csv contains operations only, so it may be processed by machines
+func (cw *csvWriter) WriteFooter(h *Header, t *Totals) error {
+	return nil
+}
+
+func (cw *csvWriter) Flush() error {
+	cw.csv.Flush()
+	if err := cw.csv.Error(); err != nil {
+		return err
+	}
+	if f, ok := cw.w.(flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+var htmlHeaderTemplate = template.Must(template.New("header").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Statement of account {{.UserId}}, {{.From}} - {{.To}}</title>
+<style>
+body { font-family: sans-serif; font-size: 12px; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #999; padding: 4px 6px; text-align: left; }
+td.amount { text-align: right; white-space: nowrap; }
+thead { display: table-header-group; }
+tr { page-break-inside: avoid; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>Statement of account</h1>
+<p>User: {{.UserName}} (id {{.UserId}})<br>
+Period: {{.From}} - {{.To}} (UTC)<br>
+Currency: {{.Currency}}<br>
+Opening balance: {{.OpeningBalance.StringFixed 2}}</p>
+<table>
+<thead><tr><th>Id</th><th>Date</th><th>Purpose</th><th>Amount</th><th>Balance</th></tr></thead>
+<tbody>
+`))
+
+var htmlOperationTemplate = template.Must(template.New("operation").Parse(
+	`<tr><td>{{.Op.Id}}</td><td>{{.Date}}</td><td>{{.Op.Comment}}</td><td class="amount">{{.Op.Amount.StringFixed 2}}</td><td class="amount">{{.Balance.StringFixed 2}}</td></tr>
+`))
+
+var htmlFooterTemplate = template.Must(template.New("footer").Parse(`</tbody>
+</table>
+<p>Operations: {{.Totals.OperationsNum}}<br>
+Total credits: {{.Totals.Credits.StringFixed 2}} {{.Header.Currency}}<br>
+Total debits: {{.Totals.Debits.StringFixed 2}} {{.Header.Currency}}<br>
+Closing balance: {{.Totals.ClosingBalance.StringFixed 2}} {{.Header.Currency}}</p>
+</body>
+</html>
+`))
+
+type htmlWriter struct {
+	w   io.Writer
+	buf *bufio.Writer
+}
+
+func newHTMLWriter(w io.Writer) *htmlWriter {
+	return &htmlWriter{w: w, buf: bufio.NewWriter(w)}
+}
+
+func (hw *htmlWriter) WriteHeader(h *Header) error {
+	return htmlHeaderTemplate.Execute(hw.buf, h)
+}
+
+func (hw *htmlWriter) WriteOperation(op *app.Operation, balance decimal.Decimal) error {
+	return htmlOperationTemplate.Execute(hw.buf, struct {
+		Op      *app.Operation
+		Date    string
+		Balance decimal.Decimal
+	}{op, op.Date.UTC().Format("2006-01-02 15:04:05"), balance})
+}
+
+func (hw *htmlWriter) WriteFooter(h *Header, t *Totals) error {
+	return htmlFooterTemplate.Execute(hw.buf, struct {
+		Header *Header
+		Totals *Totals
+	}{h, t})
+}
+
+func (hw *htmlWriter) Flush() error {
+	err := hw.buf.Flush()
+	if err != nil {
+		return err
+	}
+	if f, ok := hw.w.(flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
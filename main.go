@@ -17,31 +17,66 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	_ "github.com/jackc/pgx/stdlib"
 	"github.com/shopspring/decimal"
 	"github.com/spf13/viper"
+	"io"
 	_ "job-backend-trainee-assignment/docs"
+	_ "job-backend-trainee-assignment/docs/v2"
 	"job-backend-trainee-assignment/internal/app"
+	"job-backend-trainee-assignment/internal/bonus"
+	"job-backend-trainee-assignment/internal/breaker"
 	"job-backend-trainee-assignment/internal/cache"
+	"job-backend-trainee-assignment/internal/cli"
+	"job-backend-trainee-assignment/internal/config_reload"
+	"job-backend-trainee-assignment/internal/currency"
 	"job-backend-trainee-assignment/internal/db_connector"
 	"job-backend-trainee-assignment/internal/exchanger"
+	"job-backend-trainee-assignment/internal/fees"
 	"job-backend-trainee-assignment/internal/http_app_handler"
 	"job-backend-trainee-assignment/internal/http_handler_router"
+	"job-backend-trainee-assignment/internal/idempotency"
 	"job-backend-trainee-assignment/internal/logger"
+	"job-backend-trainee-assignment/internal/reconciliation"
+	"job-backend-trainee-assignment/internal/scheduler"
+	"job-backend-trainee-assignment/internal/snapshot"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
 
 var configPath = flag.String("config", "config", "specify the path to app's config.json file")
 
+const commandServe = "serve"
+
 func main() {
+	os.Exit(run())
+}
+
+//run runs command, given in args: http server by default or one of admin commands, returns exit code
+func run() int {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), cli.Usage)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
+
+	command := flag.Arg(0)
+	if command == "" {
+		command = commandServe
+	}
+	if command == "help" {
+		flag.Usage()
+		return 0
+	}
+
 	v := viper.New()
 	v.SetConfigName(*configPath)
 	v.AddConfigPath(".")
@@ -49,7 +84,7 @@ func main() {
 	err := v.ReadInConfig()
 	if err != nil {
 		log.Printf("ERROR failed to read config file at: %s, err %v", *configPath, err)
-		return
+		return 1
 	}
 
 	logFilePath := v.GetString("log_params.log_path")
@@ -57,11 +92,24 @@ func main() {
 	logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_APPEND|os.O_SYNC|os.O_WRONLY, os.ModePerm)
 	if err != nil {
 		log.Printf("ERROR failed to create or open log file at %s, err %v", logFilePath, err)
-		return
+		return 1
+	}
+
+	//all loggers are kept to change their level on config reload
+	loggers := make([]*logger.FileLogger, 0)
+	newLogger := func(out io.Writer, prefix string, logLevel int64) *logger.FileLogger {
+		l := logger.NewLogger(out, prefix, logLevel)
+		loggers = append(loggers, l)
+		return l
 	}
 
-	mainLogger := logger.NewLogger(logFile, "Main\t", logLevel)
-	mainLoggerToStdout := logger.NewLogger(os.Stdout, "Main\t", logLevel)
+	mainLogger := newLogger(logFile, "Main\t", logLevel)
+	//output of admin commands is written to stdout, so logs of admin commands are written to stderr
+	logOutput := os.Stdout
+	if command != commandServe {
+		logOutput = os.Stderr
+	}
+	mainLoggerToStdout := newLogger(logOutput, "Main\t", logLevel)
 
 	mainLogger.Info("starting application")
 	mainLoggerToStdout.Info("starting application")
@@ -88,26 +136,162 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), dbConnTimeout)
 	defer cancel()
 
-	mainLogger.Info("trying to connect to postgres database, on %v", fmt.Sprintf("%s:%s", pgHost, v.GetString("db_params.port")))
-	mainLoggerToStdout.Info("trying to connect to postgres database, on %v", fmt.Sprintf("%s:%s", pgHost, v.GetString("db_params.port")))
+	mainLogger.Info("trying to connect to %s database, on %v", dbConfig.DriverName, fmt.Sprintf("%s:%s", pgHost, v.GetString("db_params.port")))
+	mainLoggerToStdout.Info("trying to connect to %s database, on %v", dbConfig.DriverName, fmt.Sprintf("%s:%s", pgHost, v.GetString("db_params.port")))
 
 	db, dbCloseFunc, err := db_connector.DBConnectWithTimeout(ctx, dbConfig, mainLogger)
 	if err != nil {
 		mainLogger.Error("failed to connect to db,err %v", err)
 		mainLoggerToStdout.Error("failed to connect to db,err %v", err)
-		return
+		return 1
 	}
 	defer dbCloseFunc()
-	mainLogger.Info("connected to postgres database, on %v", fmt.Sprintf("%s:%s", pgHost, v.GetString("db_params.port")))
-	mainLoggerToStdout.Info("connected to postgres database, on %v", fmt.Sprintf("%s:%s", pgHost, v.GetString("db_params.port")))
+	mainLogger.Info("connected to %s database, on %v", dbConfig.DriverName, fmt.Sprintf("%s:%s", pgHost, v.GetString("db_params.port")))
+	mainLoggerToStdout.Info("connected to %s database, on %v", dbConfig.DriverName, fmt.Sprintf("%s:%s", pgHost, v.GetString("db_params.port")))
+
+	var replicaParams []struct {
+		Host string `mapstructure:"host"`
+		Port string `mapstructure:"port"`
+	}
+	err = v.UnmarshalKey("db_params.replicas", &replicaParams)
+	if err != nil {
+		mainLogger.Error("failed to read replicas config, err %v", err)
+		mainLoggerToStdout.Error("failed to read replicas config, err %v", err)
+		return 1
+	}
+
+	//read replicas use postgres wal positions
+	if len(replicaParams) > 0 && dbConfig.DriverName != db_connector.DriverPostgres {
+		mainLogger.Error("read replicas are supported with %s driver only", db_connector.DriverPostgres)
+		mainLoggerToStdout.Error("read replicas are supported with %s driver only", db_connector.DriverPostgres)
+		return 1
+	}
+
+	//unavailable replica is skipped, reads go to primary and other replicas
+	replicas := make([]*db_connector.Replica, 0, len(replicaParams))
+	for _, rp := range replicaParams {
+		replicaAddr := fmt.Sprintf("%s:%s", rp.Host, rp.Port)
+		replicaConfig := *dbConfig
+		replicaConfig.DBHost = rp.Host
+		replicaConfig.DBPort = rp.Port
+
+		mainLogger.Info("trying to connect to postgres replica, on %v", replicaAddr)
+		mainLoggerToStdout.Info("trying to connect to postgres replica, on %v", replicaAddr)
+
+		replicaCtx, replicaCancel := context.WithTimeout(context.Background(), dbConnTimeout)
+		replicaDB, replicaCloseFunc, err := db_connector.DBConnectWithTimeout(replicaCtx, &replicaConfig, mainLogger)
+		replicaCancel()
+		if err != nil {
+			mainLogger.Error("failed to connect to replica %s, it is not used, err %v", replicaAddr, err)
+			mainLoggerToStdout.Error("failed to connect to replica %s, it is not used, err %v", replicaAddr, err)
+			continue
+		}
+		defer replicaCloseFunc()
+		replicas = append(replicas, &db_connector.Replica{Name: replicaAddr, DB: replicaDB})
+
+		mainLogger.Info("connected to postgres replica, on %v", replicaAddr)
+		mainLoggerToStdout.Info("connected to postgres replica, on %v", replicaAddr)
+	}
+
+	replicaSet, err := db_connector.NewReplicaSet(mainLogger, &db_connector.ReplicaConfig{
+		MaxLag:        v.GetDuration("db_params.replica_max_lag") * time.Second,
+		CheckInterval: v.GetDuration("db_params.replica_check_interval") * time.Second,
+	}, replicas...)
+	if err != nil {
+		mainLogger.Error("failed to create NewReplicaSet, err %v", err)
+		mainLoggerToStdout.Error("failed to create NewReplicaSet, err %v", err)
+		return 1
+	}
+
+	reconciliationLogger := newLogger(logFile, "Reconciliation\t", logLevel)
+	var balanceStore reconciliation.IBalanceStore
+	if dbConfig.DriverName == db_connector.DriverMySQL {
+		balanceStore, err = reconciliation.NewMySQLBalanceStore(db)
+	} else {
+		balanceStore, err = reconciliation.NewPostgresBalanceStore(db)
+	}
+	if err != nil {
+		mainLogger.Error("failed to create balance store, err %v", err)
+		mainLoggerToStdout.Error("failed to create balance store, err %v", err)
+		return 1
+	}
 
-	exLogger := logger.NewLogger(logFile, "NewExchanger\t", logLevel)
+	reconciler, err := reconciliation.NewReconciler(reconciliationLogger, balanceStore, &reconciliation.Config{
+		Interval:    v.GetDuration("reconciliation_params.interval") * time.Second,
+		ChunkSize:   v.GetInt("reconciliation_params.chunk_size"),
+		ChunkPause:  v.GetDuration("reconciliation_params.chunk_pause") * time.Millisecond,
+		WriteReport: v.GetBool("reconciliation_params.write_report"),
+	})
+	if err != nil {
+		mainLogger.Error("failed to create NewReconciler, err %v", err)
+		mainLoggerToStdout.Error("failed to create NewReconciler, err %v", err)
+		return 1
+	}
+
+	configuredCurrencies := make([]currency.Currency, 0)
+	for code := range v.GetStringMap("app_params.currency_params.currencies") {
+		prefix := "app_params.currency_params.currencies." + code
+		minAmount := decimal.Zero
+		if v.GetString(prefix+".min_amount") != "" {
+			minAmount, err = decimal.NewFromString(v.GetString(prefix + ".min_amount"))
+			if err != nil {
+				mainLogger.Error("failed to parse min amount of currency %s, err %v", code, err)
+				mainLoggerToStdout.Error("failed to parse min amount of currency %s, err %v", code, err)
+				return 1
+			}
+		}
+
+		configuredCurrencies = append(configuredCurrencies, currency.Currency{
+			Code:       code,
+			Name:       v.GetString(prefix + ".name"),
+			MinorUnits: v.GetInt32(prefix + ".minor_units"),
+			MinAmount:  minAmount,
+			Rounding:   currency.RoundingMode(v.GetString(prefix + ".rounding_mode")),
+		})
+	}
+
+	currencies, err := currency.NewRegistry(currency.RoundingMode(v.GetString("app_params.currency_params.rounding_mode")),
+		configuredCurrencies...)
+	if err != nil {
+		mainLogger.Error("failed to create currency NewRegistry, err %v", err)
+		mainLoggerToStdout.Error("failed to create currency NewRegistry, err %v", err)
+		return 1
+	}
+
+	exLogger := newLogger(logFile, "NewExchanger\t", logLevel)
 	baseCurrencyCode := v.GetString("app_params.base_currency_code")
 	ex, err := exchanger.NewExchanger(exLogger, http.DefaultClient, baseCurrencyCode)
 	if err != nil {
 		mainLogger.Error("failed to create New NewExchanger,err %v", err)
 		mainLoggerToStdout.Error("failed to create New NewExchanger,err %v", err)
-		return
+		return 1
+	}
+	ex.SetCurrencies(currencies)
+	ex.SetPivotCurrencies(v.GetStringSlice("app_params.pivot_currency_codes")...)
+
+	exchangerBreaker, err := breaker.NewBreaker("exchanger", &breaker.Config{
+		FailureThreshold: v.GetInt("breaker_params.exchanger.failure_threshold"),
+		OpenTimeout:      v.GetDuration("breaker_params.exchanger.open_timeout") * time.Second,
+		HalfOpenProbes:   v.GetInt("breaker_params.exchanger.half_open_probes"),
+	})
+	if err != nil {
+		mainLogger.Error("failed to create exchanger NewBreaker, err %v", err)
+		mainLoggerToStdout.Error("failed to create exchanger NewBreaker, err %v", err)
+		return 1
+	}
+	ex.SetBreaker(exchangerBreaker)
+	ex.SetMaxRatesAge(v.GetDuration("rates_refresh_params.max_age") * time.Second)
+	ex.SetFetchTimeout(v.GetDuration("rates_refresh_params.fetch_timeout") * time.Second)
+
+	ratesRefresher, err := exchanger.NewRefresher(newLogger(logFile, "RatesRefresher\t", logLevel), ex,
+		&exchanger.RefresherConfig{
+			Interval: v.GetDuration("rates_refresh_params.interval") * time.Second,
+			Jitter:   v.GetDuration("rates_refresh_params.jitter") * time.Second,
+		})
+	if err != nil {
+		mainLogger.Error("failed to create exchanger NewRefresher, err %v", err)
+		mainLoggerToStdout.Error("failed to create exchanger NewRefresher, err %v", err)
+		return 1
 	}
 
 	var cacheHost string
@@ -116,33 +300,56 @@ func main() {
 	} else {
 		cacheHost = v.GetString("cache_params.CACHE_HOST")
 	}
-	cacheLogger := logger.NewLogger(logFile, "Cache\t", logLevel)
+	cacheLogger := newLogger(logFile, "Cache\t", logLevel)
 
 	redisConnTimeout := v.GetDuration("cache_params.conn_timeout") * time.Second
 	ctx, cancel = context.WithTimeout(context.Background(), redisConnTimeout)
 	defer cancel()
 
-	mainLogger.Info("trying to connect to redis, on %v", fmt.Sprintf("%s:%s", cacheHost, v.GetString("cache_params.port")))
-	mainLoggerToStdout.Info("trying to connect to redis, on %v", fmt.Sprintf("%s:%s", cacheHost, v.GetString("cache_params.port")))
-
-	redisPool, poolCloseFunc, err := cache.ConnectToRedisWithTimeout(ctx, cacheLogger, &cache.ConnConfig{
-		Host:          cacheHost,
-		Port:          v.GetString("cache_params.port"),
-		DBName:        v.GetInt("cache_params.db_name"),
-		Pass:          v.GetString("cache_params.pass"),
-		RetryInterval: v.GetDuration("cache_params.conn_retry_interval") * time.Second,
-		MaxConn:       v.GetInt("cache_params.max_conn"),
-		MaxIdleConn:   v.GetInt("cache_params.max_idle_conn"),
-		IdleTimeout:   v.GetDuration("cache_params.idle_timeout") * time.Second,
-	})
+	redisConnConfig := &cache.ConnConfig{
+		Mode:                  v.GetString("cache_params.mode"),
+		Host:                  cacheHost,
+		Port:                  v.GetString("cache_params.port"),
+		DBName:                v.GetInt("cache_params.db_name"),
+		Username:              v.GetString("cache_params.username"),
+		Pass:                  v.GetString("cache_params.pass"),
+		RetryInterval:         v.GetDuration("cache_params.conn_retry_interval") * time.Second,
+		MaxConn:               v.GetInt("cache_params.max_conn"),
+		MaxIdleConn:           v.GetInt("cache_params.max_idle_conn"),
+		IdleTimeout:           v.GetDuration("cache_params.idle_timeout") * time.Second,
+		UseTLS:                v.GetBool("cache_params.tls"),
+		TLSSkipVerify:         v.GetBool("cache_params.tls_skip_verify"),
+		TLSCAFile:             v.GetString("cache_params.tls_ca_file"),
+		SentinelAddrs:         v.GetStringSlice("cache_params.sentinel_addrs"),
+		SentinelMasterName:    v.GetString("cache_params.sentinel_master_name"),
+		SentinelUsername:      v.GetString("cache_params.sentinel_username"),
+		SentinelPass:          v.GetString("cache_params.sentinel_pass"),
+		SentinelCheckInterval: v.GetDuration("cache_params.sentinel_check_interval") * time.Second,
+		ClusterAddrs:          v.GetStringSlice("cache_params.cluster_addrs"),
+	}
+
+	var redisAddr string
+	switch redisConnConfig.Mode {
+	case cache.ModeSentinel:
+		redisAddr = fmt.Sprintf("master %s of sentinels %v", redisConnConfig.SentinelMasterName, redisConnConfig.SentinelAddrs)
+	case cache.ModeCluster:
+		redisAddr = fmt.Sprintf("cluster %v", redisConnConfig.ClusterAddrs)
+	default:
+		redisAddr = fmt.Sprintf("%s:%s", cacheHost, redisConnConfig.Port)
+	}
+
+	mainLogger.Info("trying to connect to redis, on %v", redisAddr)
+	mainLoggerToStdout.Info("trying to connect to redis, on %v", redisAddr)
+
+	redisPool, poolCloseFunc, err := cache.ConnectToRedisWithTimeout(ctx, cacheLogger, redisConnConfig)
 	if err != nil {
 		mainLogger.Error("failed to connect to Redis,err %v", err)
 		mainLoggerToStdout.Error("failed to connect to Redis,err %v", err)
-		return
+		return 1
 	}
 	defer poolCloseFunc()
-	mainLogger.Info("connected to redis, on %v", fmt.Sprintf("%s:%s", cacheHost, v.GetString("cache_params.port")))
-	mainLoggerToStdout.Info("connected to redis, on %v", fmt.Sprintf("%s:%s", cacheHost, v.GetString("cache_params.port")))
+	mainLogger.Info("connected to redis, on %v", redisAddr)
+	mainLoggerToStdout.Info("connected to redis, on %v", redisAddr)
 
 	cacheConfig := &cache.CacheConfig{
 		KeyExpirationTime: v.GetDuration("cache_params.key_expire_time") * time.Second,
@@ -153,52 +360,453 @@ func main() {
 	if err != nil {
 		mainLogger.Error("failed to create NewRedisCache,err %v", err)
 		mainLoggerToStdout.Error("failed to create NewRedisCache,err %v", err)
-		return
+		return 1
 	}
 
-	appLogger := logger.NewLogger(logFile, "BillingApp\t", logLevel)
+	cacheBreaker, err := breaker.NewBreaker("redis", &breaker.Config{
+		FailureThreshold: v.GetInt("breaker_params.cache.failure_threshold"),
+		OpenTimeout:      v.GetDuration("breaker_params.cache.open_timeout") * time.Second,
+		HalfOpenProbes:   v.GetInt("breaker_params.cache.half_open_probes"),
+	})
+	if err != nil {
+		mainLogger.Error("failed to create cache NewBreaker, err %v", err)
+		mainLoggerToStdout.Error("failed to create cache NewBreaker, err %v", err)
+		return 1
+	}
+
+	breakerCache, err := cache.NewBreakerCache(cacheLogger, redisCache, cacheBreaker)
+	if err != nil {
+		mainLogger.Error("failed to create NewBreakerCache,err %v", err)
+		mainLoggerToStdout.Error("failed to create NewBreakerCache,err %v", err)
+		return 1
+	}
+
+	appLogger := newLogger(logFile, "BillingApp\t", logLevel)
 	minAmountUnit := v.GetString("app_params.min_monetary_unit")
 	decimalMinAmount, err := decimal.NewFromString(minAmountUnit)
 	if err != nil {
 		mainLogger.Error("failed to create NewApp Config,err: %v", err)
 		mainLoggerToStdout.Error("failed to create NewApp Config,err: %v", err)
-		return
+		return 1
+	}
+
+	conversionSpread := decimal.Zero
+	if v.GetString("app_params.currency_params.conversion_spread") != "" {
+		conversionSpread, err = decimal.NewFromString(v.GetString("app_params.currency_params.conversion_spread"))
+		if err != nil {
+			mainLogger.Error("failed to parse conversion spread, err %v", err)
+			mainLoggerToStdout.Error("failed to parse conversion spread, err %v", err)
+			return 1
+		}
+	}
+
+	var feeRules []fees.RuleConfig
+	var feeTiers []fees.TierConfig
+	err = v.UnmarshalKey("fee_params.rules", &feeRules)
+	if err != nil {
+		mainLogger.Error("failed to read fee rules config, err %v", err)
+		mainLoggerToStdout.Error("failed to read fee rules config, err %v", err)
+		return 1
+	}
+
+	err = v.UnmarshalKey("fee_params.user_tiers", &feeTiers)
+	if err != nil {
+		mainLogger.Error("failed to read fee user tiers config, err %v", err)
+		mainLoggerToStdout.Error("failed to read fee user tiers config, err %v", err)
+		return 1
+	}
+
+	feeSchedule, err := fees.NewScheduleFromConfig(feeRules, feeTiers)
+	if err != nil {
+		mainLogger.Error("failed to create fee NewScheduleFromConfig, err %v", err)
+		mainLoggerToStdout.Error("failed to create fee NewScheduleFromConfig, err %v", err)
+		return 1
 	}
 
 	decimalWholeDigitNum := v.GetInt("app_params.money_value_params.decimal_whole_digits_num")
 	decimalFracDigitNum := v.GetInt("app_params.money_value_params.decimal_frac_digits_num")
-	billApp, err := app.NewApp(appLogger, db, ex, redisCache, &app.Config{
+	billApp, err := app.NewApp(appLogger, db, ex, breakerCache, &app.Config{
 		MinOpsMonetaryUnit:       decimalMinAmount,
 		MaxDecimalWholeDigitsNum: decimalWholeDigitNum,
 		MaxDecimalFracDigitsNum:  decimalFracDigitNum,
+		Currencies:               currencies,
+		QuoteTTL:                 v.GetDuration("app_params.currency_params.quote_ttl") * time.Second,
+		ConversionSpread:         conversionSpread,
+		RevenueAccountId:         v.GetInt64("app_params.currency_params.revenue_account_id"),
+		Fees:                     feeSchedule,
+		FeesAccountId:            v.GetInt64("fee_params.account_id"),
+		BonusWithdrawPolicy:      v.GetString("bonus_params.withdraw_policy"),
 	})
 	if err != nil {
 		mainLogger.Error("failed to create new App,err %v", err)
 		mainLoggerToStdout.Error("failed to create new App,err %v", err)
-		return
+		return 1
+	}
+
+	endpointTTLs := make(map[string]time.Duration)
+	for endpoint := range v.GetStringMap("idempotency_params.endpoint_ttls") {
+		endpointTTLs[endpoint] = v.GetDuration("idempotency_params.endpoint_ttls."+endpoint) * time.Second
+	}
+	if v.GetString("idempotency_params.backend") == idempotency.BackendPostgres && dbConfig.DriverName != db_connector.DriverPostgres {
+		mainLogger.Error("idempotency backend %s requires %s driver", idempotency.BackendPostgres, db_connector.DriverPostgres)
+		mainLoggerToStdout.Error("idempotency backend %s requires %s driver", idempotency.BackendPostgres, db_connector.DriverPostgres)
+		return 1
+	}
+
+	idempotencyLogger := newLogger(logFile, "Idempotency\t", logLevel)
+	idempotencyStore, err := idempotency.NewStore(v.GetString("idempotency_params.backend"), redisPool, db, &idempotency.Config{
+		InProgressTTL: v.GetDuration("idempotency_params.in_progress_ttl") * time.Second,
+		DefaultTTL:    v.GetDuration("idempotency_params.default_ttl") * time.Second,
+		EndpointTTLs:  endpointTTLs,
+	})
+	if err != nil {
+		mainLogger.Error("failed to create idempotency NewStore, err %v", err)
+		mainLoggerToStdout.Error("failed to create idempotency NewStore, err %v", err)
+		return 1
+	}
+
+	//redis store shares breaker with cache, so operations do not wait for unavailable redis and check token in database
+	if v.GetString("idempotency_params.backend") == idempotency.BackendRedis {
+		idempotencyStore, err = idempotency.NewBreakerStore(idempotencyLogger, idempotencyStore, cacheBreaker)
+		if err != nil {
+			mainLogger.Error("failed to create idempotency NewBreakerStore, err %v", err)
+			mainLoggerToStdout.Error("failed to create idempotency NewBreakerStore, err %v", err)
+			return 1
+		}
+	}
+
+	err = billApp.SetIdempotencyStore(idempotencyStore)
+	if err != nil {
+		mainLogger.Error("failed to set idempotency store, err %v", err)
+		mainLoggerToStdout.Error("failed to set idempotency store, err %v", err)
+		return 1
+	}
+
+	if len(replicas) > 0 {
+		err = billApp.SetReadReplicas(replicaSet)
+		if err != nil {
+			mainLogger.Error("failed to set read replicas, err %v", err)
+			mainLoggerToStdout.Error("failed to set read replicas, err %v", err)
+			return 1
+		}
+	}
+
+	idempotencyCleaner, err := idempotency.NewCleaner(idempotencyLogger, idempotencyStore,
+		v.GetDuration("idempotency_params.cleanup_interval")*time.Second)
+	if err != nil {
+		mainLogger.Error("failed to create idempotency NewCleaner, err %v", err)
+		mainLoggerToStdout.Error("failed to create idempotency NewCleaner, err %v", err)
+		return 1
+	}
+
+	if command != commandServe {
+		adminCli, err := cli.NewCLI(billApp, reconciler, os.Stdout)
+		if err != nil {
+			mainLogger.Error("failed to create NewCLI, err %v", err)
+			mainLoggerToStdout.Error("failed to create NewCLI, err %v", err)
+			return 1
+		}
+
+		err = adminCli.Run(context.Background(), flag.Args())
+		if err != nil {
+			mainLogger.Error("command %s failed, err %v", strings.Join(flag.Args(), " "), err)
+			mainLoggerToStdout.Error("command failed, err %v", err)
+			if errors.Is(err, cli.ErrBadCommandArgs) || errors.Is(err, cli.ErrUnknownCommand) {
+				flag.Usage()
+				return 2
+			}
+			return 1
+		}
+		return 0
 	}
 
-	routerLogger := logger.NewLogger(logFile, "Router\t", logLevel)
+	routerLogger := newLogger(logFile, "Router\t", logLevel)
 	r, err := router.NewRouter(routerLogger)
 	if err != nil {
 		mainLogger.Error("failed to create NewRouter, err %v", err)
 		mainLoggerToStdout.Error("failed to create NewRouter, err %v", err)
-		return
+		return 1
 	}
 
-	httpHandlerLogger := logger.NewLogger(logFile, "HttpHandler\t", logLevel)
+	httpHandlerLogger := newLogger(logFile, "HttpHandler\t", logLevel)
 	requestHandleTimeout := v.GetDuration("http_server_params.request_handle_timeout") * time.Second
 	cfg := &http_app_handler.Config{
 		RequestHandleTimeout: requestHandleTimeout,
+		ExportPageSize:       v.GetInt64("http_server_params.export_page_size"),
+		RateLimitPerSecond:   v.GetFloat64("http_server_params.rate_limit_per_second"),
+		RateLimitBurst:       v.GetInt("http_server_params.rate_limit_burst"),
 	}
 
 	appHandler, err := http_app_handler.NewHttpAppHandler(httpHandlerLogger, r, billApp, cfg)
 	if err != nil {
 		mainLogger.Error("failed to create NewHttpAppHandler, err %v", err)
 		mainLoggerToStdout.Error("failed to create NewHttpAppHandler, err %v", err)
-		return
+		return 1
 	}
 
+	//schedules are kept in database of db with any driver, missing key keeps scheduler enabled
+	schedulerEnabled := !v.IsSet("scheduler_params.enabled") || v.GetBool("scheduler_params.enabled")
+	var paymentScheduler *scheduler.Scheduler
+	if schedulerEnabled {
+		schedulerLogger := newLogger(logFile, "Scheduler\t", logLevel)
+		paymentScheduler, err = scheduler.NewScheduler(schedulerLogger, db, billApp, &scheduler.Config{
+			PollInterval:     v.GetDuration("scheduler_params.poll_interval") * time.Second,
+			BatchSize:        v.GetInt("scheduler_params.batch_size"),
+			RunTimeout:       v.GetDuration("scheduler_params.run_timeout") * time.Second,
+			MinInterval:      v.GetDuration("scheduler_params.min_interval") * time.Second,
+			MaxRetryInterval: v.GetDuration("scheduler_params.max_retry_interval") * time.Second,
+		})
+		if err != nil {
+			mainLogger.Error("failed to create NewScheduler, err %v", err)
+			mainLoggerToStdout.Error("failed to create NewScheduler, err %v", err)
+			return 1
+		}
+
+		err = appHandler.RegisterSchedulerRoutes(paymentScheduler)
+		if err != nil {
+			mainLogger.Error("failed to register scheduler routes, err %v", err)
+			mainLoggerToStdout.Error("failed to register scheduler routes, err %v", err)
+			return 1
+		}
+	} else {
+		mainLogger.Info("payment scheduler is disabled by scheduler_params.enabled")
+		mainLoggerToStdout.Info("payment scheduler is disabled by scheduler_params.enabled")
+	}
+
+	reloaderLogger := newLogger(logFile, "ConfigReload\t", logLevel)
+	reloader, err := config_reload.NewReloader(reloaderLogger, v, []string{
+		"log_params.log_level",
+		"http_server_params.request_handle_timeout",
+		"http_server_params.export_page_size",
+		"http_server_params.rate_limit_per_second",
+		"http_server_params.rate_limit_burst",
+		"cache_params.key_expire_time",
+		"cache_params.cache_lookup_timeout",
+		"cache_params.cache_set_timeout",
+		"app_params.min_monetary_unit",
+		"app_params.currency_params.quote_ttl",
+		"app_params.currency_params.conversion_spread",
+		"app_params.currency_params.revenue_account_id",
+		"fee_params.account_id",
+		"fee_params.rules",
+		"fee_params.user_tiers",
+		"bonus_params.withdraw_policy",
+	})
+	if err != nil {
+		mainLogger.Error("failed to create config NewReloader, err %v", err)
+		mainLoggerToStdout.Error("failed to create config NewReloader, err %v", err)
+		return 1
+	}
+
+	reloader.OnReload(func(rv *viper.Viper) (func(), error) {
+		level := rv.GetInt64("log_params.log_level")
+		return func() {
+			for _, l := range loggers {
+				l.SetLogLevel(level)
+			}
+		}, nil
+	})
+
+	reloader.OnReload(func(rv *viper.Viper) (func(), error) {
+		minAmountUnit, err := decimal.NewFromString(rv.GetString("app_params.min_monetary_unit"))
+		if err != nil {
+			return nil, fmt.Errorf("min monetary unit must be decimal number, err %v", err)
+		}
+
+		conversionSpread := decimal.Zero
+		if rv.GetString("app_params.currency_params.conversion_spread") != "" {
+			conversionSpread, err = decimal.NewFromString(rv.GetString("app_params.currency_params.conversion_spread"))
+			if err != nil {
+				return nil, fmt.Errorf("conversion spread must be decimal number, err %v", err)
+			}
+		}
+
+		appConfig := billApp.GetConfig()
+		appConfig.MinOpsMonetaryUnit = minAmountUnit
+		appConfig.QuoteTTL = rv.GetDuration("app_params.currency_params.quote_ttl") * time.Second
+		appConfig.ConversionSpread = conversionSpread
+		appConfig.RevenueAccountId = rv.GetInt64("app_params.currency_params.revenue_account_id")
+
+		var feeRules []fees.RuleConfig
+		var feeTiers []fees.TierConfig
+		if err := rv.UnmarshalKey("fee_params.rules", &feeRules); err != nil {
+			return nil, fmt.Errorf("failed to read fee rules, err %v", err)
+		}
+		if err := rv.UnmarshalKey("fee_params.user_tiers", &feeTiers); err != nil {
+			return nil, fmt.Errorf("failed to read fee user tiers, err %v", err)
+		}
+		appConfig.Fees, err = fees.NewScheduleFromConfig(feeRules, feeTiers)
+		if err != nil {
+			return nil, err
+		}
+		appConfig.FeesAccountId = rv.GetInt64("fee_params.account_id")
+		appConfig.BonusWithdrawPolicy = rv.GetString("bonus_params.withdraw_policy")
+		if err := appConfig.Validate(); err != nil {
+			return nil, err
+		}
+
+		return func() {
+			err := billApp.SetConfig(&appConfig)
+			if err != nil {
+				mainLogger.Error("failed to apply reloaded app config, err %v", err)
+			}
+		}, nil
+	})
+
+	reloader.OnReload(func(rv *viper.Viper) (func(), error) {
+		cacheConfig := redisCache.GetConfig()
+		cacheConfig.KeyExpirationTime = rv.GetDuration("cache_params.key_expire_time") * time.Second
+		cacheConfig.KeyLookupTimeout = rv.GetDuration("cache_params.cache_lookup_timeout") * time.Second
+		cacheConfig.KeySetTimeout = rv.GetDuration("cache_params.cache_set_timeout") * time.Second
+		if err := cacheConfig.Validate(); err != nil {
+			return nil, err
+		}
+
+		return func() {
+			err := redisCache.SetConfig(&cacheConfig)
+			if err != nil {
+				mainLogger.Error("failed to apply reloaded cache config, err %v", err)
+			}
+		}, nil
+	})
+
+	reloader.OnReload(func(rv *viper.Viper) (func(), error) {
+		handlerConfig := appHandler.GetConfig()
+		handlerConfig.RequestHandleTimeout = rv.GetDuration("http_server_params.request_handle_timeout") * time.Second
+		handlerConfig.ExportPageSize = rv.GetInt64("http_server_params.export_page_size")
+		handlerConfig.RateLimitPerSecond = rv.GetFloat64("http_server_params.rate_limit_per_second")
+		handlerConfig.RateLimitBurst = rv.GetInt("http_server_params.rate_limit_burst")
+		if err := handlerConfig.Validate(); err != nil {
+			return nil, err
+		}
+
+		return func() {
+			err := appHandler.SetConfig(&handlerConfig)
+			if err != nil {
+				mainLogger.Error("failed to apply reloaded http handler config, err %v", err)
+			}
+		}, nil
+	})
+
+	err = appHandler.RegisterAdminRoutes(reloader)
+	if err != nil {
+		mainLogger.Error("failed to register admin routes, err %v", err)
+		mainLoggerToStdout.Error("failed to register admin routes, err %v", err)
+		return 1
+	}
+
+	err = appHandler.RegisterHealthRoute(cacheBreaker, exchangerBreaker)
+	if err != nil {
+		mainLogger.Error("failed to register health route, err %v", err)
+		mainLoggerToStdout.Error("failed to register health route, err %v", err)
+		return 1
+	}
+
+	err = appHandler.RegisterCurrencyRoutes(ex, v.GetDuration("app_params.currency_params.catalogue_cache_ttl")*time.Second)
+	if err != nil {
+		mainLogger.Error("failed to register currency routes, err %v", err)
+		mainLoggerToStdout.Error("failed to register currency routes, err %v", err)
+		return 1
+	}
+
+	err = reloader.Watch()
+	if err != nil {
+		mainLogger.Error("failed to watch config file, err %v", err)
+		mainLoggerToStdout.Error("failed to watch config file, err %v", err)
+		return 1
+	}
+
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	go func() {
+		for range hupCh {
+			mainLogger.Info("got sighup signal, reloading config")
+			mainLoggerToStdout.Info("got sighup signal, reloading config")
+			_ = reloader.Reload()
+		}
+	}()
+
+	schedulerCtx, schedulerCancel := context.WithCancel(context.Background())
+	schedulerDoneCh := make(chan struct{})
+	go func() {
+		if paymentScheduler != nil {
+			paymentScheduler.Run(schedulerCtx)
+		}
+		close(schedulerDoneCh)
+	}()
+
+	snapshotLogger := newLogger(logFile, "Snapshot\t", logLevel)
+	snapshotWorker, err := snapshot.NewWorker(snapshotLogger, billApp, &snapshot.Config{
+		CheckInterval: v.GetDuration("snapshot_params.check_interval") * time.Second,
+		BackfillDays:  v.GetInt("snapshot_params.backfill_days"),
+	})
+	if err != nil {
+		schedulerCancel()
+		<-schedulerDoneCh
+		mainLogger.Error("failed to create snapshot NewWorker, err %v", err)
+		mainLoggerToStdout.Error("failed to create snapshot NewWorker, err %v", err)
+		return 1
+	}
+
+	snapshotCtx, snapshotCancel := context.WithCancel(context.Background())
+	snapshotDoneCh := make(chan struct{})
+	go func() {
+		snapshotWorker.Run(snapshotCtx)
+		close(snapshotDoneCh)
+	}()
+
+	bonusLogger := newLogger(logFile, "Bonus\t", logLevel)
+	bonusWorker, err := bonus.NewWorker(bonusLogger, billApp, &bonus.Config{
+		CheckInterval: v.GetDuration("bonus_params.expiry_check_interval") * time.Second,
+		BatchSize:     v.GetInt64("bonus_params.expiry_batch_size"),
+	})
+	if err != nil {
+		schedulerCancel()
+		snapshotCancel()
+		<-schedulerDoneCh
+		<-snapshotDoneCh
+		mainLogger.Error("failed to create bonus NewWorker, err %v", err)
+		mainLoggerToStdout.Error("failed to create bonus NewWorker, err %v", err)
+		return 1
+	}
+
+	bonusCtx, bonusCancel := context.WithCancel(context.Background())
+	bonusDoneCh := make(chan struct{})
+	go func() {
+		bonusWorker.Run(bonusCtx)
+		close(bonusDoneCh)
+	}()
+
+	reconciliationCtx, reconciliationCancel := context.WithCancel(context.Background())
+	reconciliationDoneCh := make(chan struct{})
+	go func() {
+		reconciler.Run(reconciliationCtx)
+		close(reconciliationDoneCh)
+	}()
+
+	cleanupCtx, cleanupCancel := context.WithCancel(context.Background())
+	cleanupDoneCh := make(chan struct{})
+	go func() {
+		idempotencyCleaner.Run(cleanupCtx)
+		close(cleanupDoneCh)
+	}()
+
+	replicasCtx, replicasCancel := context.WithCancel(context.Background())
+	replicasDoneCh := make(chan struct{})
+	go func() {
+		if len(replicas) > 0 {
+			replicaSet.Run(replicasCtx)
+		}
+		close(replicasDoneCh)
+	}()
+
+	ratesCtx, ratesCancel := context.WithCancel(context.Background())
+	ratesDoneCh := make(chan struct{})
+	go func() {
+		ratesRefresher.Run(ratesCtx)
+		close(ratesDoneCh)
+	}()
+
 	readTimeout := v.GetDuration("http_server_params.read_timeout") * time.Second
 	writeTimeout := v.GetDuration("http_server_params.write_timeout") * time.Second
 	serverLogger := log.New(os.Stdout, "HTTP Server\t", log.LstdFlags|log.Lshortfile|log.Lmicroseconds)
@@ -221,7 +829,7 @@ func main() {
 		ErrorLog:     serverLogger,
 	}
 
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	clientWaitCh := make(chan struct{})
 
@@ -254,10 +862,24 @@ func main() {
 	mainLogger.Info("starting listening on %v", hostPort)
 	mainLoggerToStdout.Info("starting listening on %v", hostPort)
 	err = server.ListenAndServe()
+	schedulerCancel()
+	snapshotCancel()
+	bonusCancel()
+	reconciliationCancel()
+	cleanupCancel()
+	replicasCancel()
+	ratesCancel()
+	<-schedulerDoneCh
+	<-snapshotDoneCh
+	<-bonusDoneCh
+	<-reconciliationDoneCh
+	<-cleanupDoneCh
+	<-replicasDoneCh
+	<-ratesDoneCh
 	if err != nil && err != http.ErrServerClosed {
 		mainLogger.Error("listen and serve, got err %v", err)
 		mainLoggerToStdout.Error("listen and serve, got err %v", err)
-		return
+		return 1
 	}
 
 	mainLogger.Info("waiting for server to serve remaining clients")
@@ -267,4 +889,5 @@ func main() {
 
 	mainLogger.Info("remaining clients had been served, exitting")
 	mainLoggerToStdout.Info("remaining clients had been served, exitting")
+	return 0
 }